@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"net/http"
+	"strconv"
+
+	"songmartyn/internal/avatar"
+	"songmartyn/internal/websocket"
+)
+
+// Guests keep a gallery of avatars to switch between, and can take their avatar home
+// as an animated GIF or WebP, or as a sticker PNG
+
+// Animated export sizes in pixels
+const (
+	animatedSize    = 256
+	minAnimatedSize = 32
+	maxAnimatedSize = 512
+)
+
+// handleAvatarGallery saves, switches to or deletes one of a guest's saved avatars
+func (app *App) handleAvatarGallery(client *websocket.Client, action websocket.MessageType, payload websocket.AvatarGalleryPayload) error {
+	sess := client.GetSession()
+	if sess == nil {
+		return nil
+	}
+
+	switch action {
+	case websocket.MsgAvatarSave:
+		// Save the avatar sent, or the one they're using
+		config := payload.Config
+		if config == nil {
+			config = sess.AvatarConfig
+		}
+		if config == nil {
+			return fmt.Errorf("Make an avatar before saving it")
+		}
+		if _, err := app.sessions.SaveAvatar(sess.MartynKey, payload.Name, *config); err != nil {
+			return err
+		}
+
+	case websocket.MsgAvatarUse:
+		// The manager swaps the avatar on the session it shares with the client, under its lock
+		if _, err := app.sessions.UseSavedAvatar(sess.MartynKey, payload.Name); err != nil {
+			return err
+		}
+		app.broadcastClientList()
+
+	case websocket.MsgAvatarDelete:
+		if err := app.sessions.DeleteSavedAvatar(sess.MartynKey, payload.Name); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unknown avatar action %q", action)
+	}
+
+	app.broadcastState()
+	return nil
+}
+
+// handleAvatarAnimated returns an avatar's idle animation as a looping GIF or WebP
+// Query params: the avatar as for /api/avatar, plus format (gif or webp), size,
+// seed (to change how it moves) and noenv
+func handleAvatarAnimated(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	config := avatarQueryConfig(q)
+
+	format := q.Get("format")
+	if format == "" {
+		format = "gif"
+	}
+	if format != "gif" && format != "webp" {
+		http.Error(w, "format must be gif or webp", http.StatusBadRequest)
+		return
+	}
+
+	size := parseIntParam(q.Get("size"), animatedSize)
+	if size < minAnimatedSize || size > maxAnimatedSize {
+		http.Error(w, fmt.Sprintf("size must be %d-%d pixels", minAnimatedSize, maxAnimatedSize), http.StatusBadRequest)
+		return
+	}
+
+	seed := config.DefaultSeed()
+	if s := q.Get("seed"); s != "" {
+		parsed, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid seed", http.StatusBadRequest)
+			return
+		}
+		seed = parsed
+	}
+
+	frames, err := config.IdleFrames(avatar.IdleOptions{
+		Size:       size,
+		Seed:       seed,
+		IncludeEnv: q.Get("noenv") != "true",
+	})
+	if err != nil {
+		http.Error(w, "Failed to render avatar", http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if format == "webp" {
+		err = avatar.EncodeWebP(&buf, frames, avatar.IdleFPS)
+	} else {
+		err = avatar.EncodeGIF(&buf, frames, avatar.IdleFPS)
+	}
+	if err != nil {
+		http.Error(w, "Failed to encode avatar", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/"+format)
+	w.Header().Set("Cache-Control", "public, max-age=86400") // Cache for 1 day
+	w.Write(buf.Bytes())
+}
+
+// handleAvatarSticker returns an avatar without its background as a transparent PNG
+// Query params: the avatar as for /api/avatar, plus size
+func handleAvatarSticker(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	config := avatarQueryConfig(q)
+
+	img, err := config.Sticker(parseIntParam(q.Get("size"), avatar.StickerSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		http.Error(w, "Failed to encode sticker", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=86400") // Cache for 1 day
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"songmartyn/pkg/models"
+)
+
+// ============================================================================
+// Avatar Gallery Tests
+// ============================================================================
+
+// ownEntry waits for a state update and returns the guest's own session in it
+func (g *guestConn) ownEntry() models.SessionView {
+	g.t.Helper()
+	var state models.RoomStateView
+	json.Unmarshal(g.read("state_update"), &state)
+	for _, s := range state.Sessions {
+		if s.MartynKey == g.key {
+			return s
+		}
+	}
+	g.t.Fatal("Expected the guest's own entry in the state")
+	return models.SessionView{}
+}
+
+func TestAvatarGallery(t *testing.T) {
+	app, _ := newTestApp(t)
+	go app.hub.Run()
+	srv := httptest.NewServer(app.routes())
+	defer srv.Close()
+
+	alice := dialGuest(t, srv, "Alice")
+
+	// Saving without a config keeps the avatar they're using
+	alice.send("avatar_save", map[string]string{"name": "Party"})
+	current := alice.ownEntry()
+	if got := current.SavedAvatars; len(got) != 1 || current.AvatarConfig == nil || !reflect.DeepEqual(got[0].Config, *current.AvatarConfig) {
+		t.Fatalf("Expected the current avatar saved, got %+v", got)
+	}
+
+	// Saving under the same name replaces it
+	party := models.AvatarConfig{Env: 1, Clo: 2, Head: 3, Mouth: 4, Eyes: 5, Top: 6}
+	work := models.AvatarConfig{Env: 7, Clo: 8, Head: 9, Mouth: 10, Eyes: 11, Top: 12}
+	alice.send("avatar_save", map[string]interface{}{"name": "party", "config": party})
+	alice.ownEntry()
+	alice.send("avatar_save", map[string]interface{}{"name": "Work", "config": work})
+	if got := alice.ownEntry().SavedAvatars; len(got) != 2 || got[0].Name != "party" || got[0].Config != party || got[1].Name != "Work" {
+		t.Fatalf("Expected party and Work saved, got %+v", got)
+	}
+
+	// Switching puts the saved avatar on the guest's profile
+	alice.send("avatar_use", map[string]string{"name": "work"})
+	if got := alice.ownEntry().AvatarConfig; got == nil || *got != work {
+		t.Errorf("Expected the Work avatar in use, got %+v", got)
+	}
+	if sess := app.sessions.Get(alice.key); sess == nil || sess.AvatarConfig == nil || *sess.AvatarConfig != work {
+		t.Error("Expected the Work avatar kept on the session")
+	}
+
+	alice.send("avatar_delete", map[string]string{"name": "Party"})
+	if got := alice.ownEntry().SavedAvatars; len(got) != 1 || got[0].Name != "Work" {
+		t.Errorf("Expected only Work left, got %+v", got)
+	}
+	alice.send("avatar_use", map[string]string{"name": "Party"})
+	if payload := string(alice.read("error")); payload == "" {
+		t.Error("Expected an error using a deleted avatar")
+	}
+}
+
+func TestAvatarExports(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/avatar/animated":
+			handleAvatarAnimated(w, r)
+		case "/api/avatar/sticker":
+			handleAvatarSticker(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	get := func(path string) (*http.Response, []byte) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, body
+	}
+
+	const avatarQuery = "env=3&clo=5&head=2&mouth=7&eyes=1&top=9"
+	for _, tt := range []struct {
+		format, contentType, magic string
+	}{
+		{"", "image/gif", "GIF89a"},
+		{"webp", "image/webp", "RIFF"},
+	} {
+		resp, body := get("/api/avatar/animated?" + avatarQuery + "&size=64&format=" + tt.format)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != tt.contentType {
+			t.Fatalf("Expected 200 %s, got %d %s", tt.contentType, resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		if !bytes.HasPrefix(body, []byte(tt.magic)) {
+			t.Errorf("Expected %s data, got %q", tt.contentType, body[:min(len(body), 8)])
+		}
+
+		// The same avatar always moves the same way unless the seed changes
+		_, again := get("/api/avatar/animated?" + avatarQuery + "&size=64&format=" + tt.format)
+		if !bytes.Equal(body, again) {
+			t.Errorf("Expected the same %s for the same avatar", tt.contentType)
+		}
+		_, reseeded := get("/api/avatar/animated?" + avatarQuery + "&size=64&format=" + tt.format + "&seed=42")
+		if bytes.Equal(body, reseeded) {
+			t.Errorf("Expected a different %s for a different seed", tt.contentType)
+		}
+	}
+
+	resp, body := get("/api/avatar/sticker?" + avatarQuery + "&size=128")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/png" {
+		t.Fatalf("Expected 200 image/png, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if !bytes.HasPrefix(body, []byte("\x89PNG")) {
+		t.Error("Expected PNG data")
+	}
+
+	for _, path := range []string{
+		"/api/avatar/animated?format=bmp",
+		"/api/avatar/animated?size=4096",
+		"/api/avatar/animated?seed=abc",
+		"/api/avatar/sticker?size=10",
+	} {
+		if resp, _ := get(path); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", path, resp.StatusCode)
+		}
+	}
+}
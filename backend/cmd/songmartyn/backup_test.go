@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"songmartyn/internal/backup"
+	"songmartyn/internal/configfile"
+	"songmartyn/internal/mpv"
+)
+
+// ============================================================================
+// Backup Tests
+// ============================================================================
+
+func TestBackupsTakenOnSchedule(t *testing.T) {
+	app, _ := newTestApp(t)
+	app.config.BackupEnabled = true
+	app.config.BackupIntervalHours = 24
+
+	app.backupIfDue(time.Now())
+	backups, err := backup.List(backupDir(app.config))
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("Expected a first backup straight away, got %v, %v", backups, err)
+	}
+
+	app.backupIfDue(time.Now().Add(time.Hour))
+	if backups, _ := backup.List(backupDir(app.config)); len(backups) != 1 {
+		t.Errorf("Expected no backup before the interval, got %d", len(backups))
+	}
+
+	// Taking another backup rotates out the oldest
+	app.config.BackupKeep = 1
+	old := filepath.Join(backupDir(app.config), backups[0].Name)
+	os.Rename(old, filepath.Join(backupDir(app.config), "songmartyn-20200101-000000.tar.gz"))
+	app.backupIfDue(time.Now().Add(25 * time.Hour))
+	backups, _ = backup.List(backupDir(app.config))
+	if len(backups) != 1 || backups[0].CreatedAt.Year() == 2020 {
+		t.Errorf("Expected only the new backup kept, got %+v", backups)
+	}
+
+	app.config.BackupEnabled = false
+	os.RemoveAll(backupDir(app.config))
+	app.backupIfDue(time.Now())
+	if backups, _ := backup.List(backupDir(app.config)); len(backups) != 0 {
+		t.Error("Expected no scheduled backups when disabled")
+	}
+}
+
+func TestBackupAndRestoreThroughAdminAPI(t *testing.T) {
+	app, _ := newTestApp(t)
+	app.config.ConfigPath = filepath.Join(t.TempDir(), "songmartyn.toml")
+	if err := configfile.Save(app.config.ConfigPath, configfile.Defaults()); err != nil {
+		t.Fatal(err)
+	}
+	restarted := make(chan struct{}, 1)
+	app.restart = func() { restarted <- struct{}{} }
+	queueTestSong(t, app, "s1", "alice")
+
+	rec := httptest.NewRecorder()
+	app.handleBackups(rec, httptest.NewRequest(http.MethodPost, "/api/admin/backups", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected backup to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		Backup backup.Info `json:"backup"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+
+	rec = httptest.NewRecorder()
+	app.handleBackups(rec, httptest.NewRequest(http.MethodGet, "/api/admin/backups", nil))
+	var listed struct {
+		Backups []backup.Info `json:"backups"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &listed)
+	if len(listed.Backups) != 1 || listed.Backups[0].Name != created.Backup.Name {
+		t.Fatalf("Expected the new backup listed, got %s", rec.Body.String())
+	}
+
+	// Download it
+	rec = httptest.NewRecorder()
+	app.handleBackupFile(rec, httptest.NewRequest(http.MethodGet, "/api/admin/backups/"+created.Backup.Name, nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/gzip" {
+		t.Fatalf("Expected the archive, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	download := rec.Body.Bytes()
+
+	rec = httptest.NewRecorder()
+	app.handleBackupFile(rec, httptest.NewRequest(http.MethodGet, "/api/admin/backups/..%2Fqueue.db", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 outside the backups, got %d", rec.Code)
+	}
+
+	// Anything that isn't a good backup is refused without a restart
+	rec = httptest.NewRecorder()
+	app.handleBackupRestore(rec, httptest.NewRequest(http.MethodPost, "/api/admin/backups/restore", strings.NewReader("junk")))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad archive, got %d", rec.Code)
+	}
+	select {
+	case <-restarted:
+		t.Fatal("Expected no restart after a rejected restore")
+	default:
+	}
+
+	// Restore the uploaded copy after the queue has moved on
+	queueTestSong(t, app, "s2", "bob")
+	rec = httptest.NewRecorder()
+	app.handleBackupRestore(rec, httptest.NewRequest(http.MethodPost, "/api/admin/backups/restore", bytes.NewReader(download)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected the restore to be accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+	select {
+	case <-restarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a restart to swap the data in")
+	}
+
+	// The next process applies it before opening the databases
+	config := app.config
+	if m, err := backup.ApplyPending(config.DataDir, backupSet(config)); err != nil || m == nil {
+		t.Fatalf("Expected the staged restore to apply, got %v, %v", m, err)
+	}
+	next, err := newAppWithPlayer(config, mpv.NewFakePlayer())
+	if err != nil {
+		t.Fatalf("Failed to start with the restored data: %v", err)
+	}
+	t.Cleanup(next.Shutdown)
+	if songs := next.queue.GetState().Songs; len(songs) != 1 || songs[0].ID != "s1" {
+		t.Errorf("Expected the backed up queue, got %+v", songs)
+	}
+
+	rec = httptest.NewRecorder()
+	app.handleBackupFile(rec, httptest.NewRequest(http.MethodDelete, "/api/admin/backups/"+created.Backup.Name, nil))
+	if backups, _ := backup.List(backupDir(config)); rec.Code != http.StatusOK || len(backups) != 0 {
+		t.Errorf("Expected the backup deleted, got %d with %d left", rec.Code, len(backups))
+	}
+}
@@ -0,0 +1,834 @@
+package main
+
+// Command-line administration: `songmartyn <command>` talks to a running server over its
+// admin socket (see startAdminSocket), or works on the files directly when it's stopped
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"songmartyn/internal/backup"
+	"songmartyn/internal/certs"
+	"songmartyn/internal/configfile"
+	"songmartyn/internal/library"
+	"songmartyn/internal/loudness"
+	"songmartyn/internal/queue"
+	"songmartyn/internal/session"
+	"songmartyn/pkg/models"
+)
+
+// command is a subcommand, e.g. `songmartyn queue add <song-id>`
+type command struct {
+	name    string // One or two words
+	args    string // Arguments, for the usage line
+	help    string
+	minArgs int
+	maxArgs int // -1 = any number
+	run     func(c *cli, args []string) error
+}
+
+var commands = []command{
+	{"library add", "<path> [name]", "Add a folder of songs to the library", 1, 2, (*cli).libraryAdd},
+	{"library scan", "[location-id]", "Scan one or every library folder for songs", 0, 1, (*cli).libraryScan},
+	{"library list", "", "List the library folders", 0, 0, (*cli).libraryList},
+	{"queue show", "", "Show the queue", 0, 0, (*cli).queueShow},
+	{"queue clear", "", "Remove every song from the queue", 0, 0, (*cli).queueClear},
+	{"queue add", "<song-id> [martyn-key]", "Queue a library song, optionally for a singer", 1, 2, (*cli).queueAdd},
+	{"sessions list", "", "List singers and who's blocked", 0, 0, (*cli).sessionsList},
+	{"sessions block", "<martyn-key> [minutes] [reason]", "Block a singer (no minutes = permanently)", 1, -1, (*cli).sessionsBlock},
+	{"sessions unblock", "<martyn-key>", "Unblock a singer", 1, 1, (*cli).sessionsUnblock},
+	{"admin set-pin", "<pin>", "Set the admin PIN (\"\" = admin panel from localhost only)", 1, 1, (*cli).adminSetPIN},
+	{"db backup", "", "Write a backup to the data directory's backups folder", 0, 0, (*cli).dbBackup},
+	{"db restore", "<archive>", "Check a backup and restore it", 1, 1, (*cli).dbRestore},
+	{"db vacuum", "", "Compact the databases", 0, 0, (*cli).dbVacuum},
+	{"doctor", "", "Check the installation and report problems", 0, 0, (*cli).doctor},
+}
+
+// findCommand returns the command args starts with and the arguments after its name
+func findCommand(args []string) (command, []string, bool) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) < len(words) {
+			continue
+		}
+		if strings.Join(args[:len(words)], " ") == cmd.name {
+			return cmd, args[len(words):], true
+		}
+	}
+	return command{}, nil, false
+}
+
+// printUsage lists the commands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: songmartyn [flags] [command]")
+	fmt.Fprintln(w, "\nWithout a command, runs the server. Commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.help)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "\nCommands use the running server when there is one, and the files in the data directory when not.")
+}
+
+// runCommand runs the subcommand in args, returning the exit code
+func runCommand(config Config, args []string, stdout, stderr io.Writer) int {
+	if args[0] == "help" {
+		printUsage(stdout)
+		return 0
+	}
+	cmd, rest, ok := findCommand(args)
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command: %s\n\n", strings.Join(args, " "))
+		printUsage(stderr)
+		return 2
+	}
+	if len(rest) < cmd.minArgs || (cmd.maxArgs >= 0 && len(rest) > cmd.maxArgs) {
+		fmt.Fprintf(stderr, "Usage: songmartyn %s %s\n", cmd.name, cmd.args)
+		return 2
+	}
+
+	c, err := newCLI(config, stdout)
+	if err == nil {
+		err = cmd.run(c, rest)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// cli runs commands against the server on the admin socket, or the files when it's stopped
+type cli struct {
+	config Config
+	out    io.Writer
+	client *http.Client // Set while a server is running
+	token  string
+}
+
+// newCLI connects to the running server's admin socket, if there is one
+func newCLI(config Config, out io.Writer) (*cli, error) {
+	c := &cli{config: config, out: out}
+	socket := filepath.Join(config.DataDir, adminSocketFile)
+	conn, err := net.DialTimeout("unix", socket, time.Second)
+	if err != nil {
+		return c, nil // Not running
+	}
+	conn.Close()
+
+	token, err := os.ReadFile(filepath.Join(config.DataDir, adminTokenFile))
+	if err != nil {
+		return nil, fmt.Errorf("the server is running but its admin token can't be read (run this as the server's user): %w", err)
+	}
+	c.token = strings.TrimSpace(string(token))
+	c.client = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+	return c, nil
+}
+
+// running reports whether commands go to a running server
+func (c *cli) running() bool {
+	return c.client != nil
+}
+
+// call sends a JSON request to the running server and decodes the JSON reply into result
+func (c *cli) call(method, path string, body, result any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = strings.NewReader(string(data))
+	}
+	return c.send(method, path, r, result)
+}
+
+// send sends body to the running server and decodes the JSON reply into result
+func (c *cli) send(method, path string, body io.Reader, result any) error {
+	req, err := http.NewRequest(method, "http://songmartyn"+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("can't reach the server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		if e.Error == "" {
+			e.Error = resp.Status
+		}
+		return errors.New(e.Error)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// The databases, opened directly while the server is stopped
+
+func (c *cli) openLibrary() (*library.Manager, error) {
+	return library.NewManager(filepath.Join(c.config.DataDir, "library.db"))
+}
+
+func (c *cli) openQueue() (*queue.Manager, error) {
+	return queue.NewManager(filepath.Join(c.config.DataDir, "queue.db"))
+}
+
+func (c *cli) openSessions() (*session.Manager, error) {
+	return session.NewManager(filepath.Join(c.config.DataDir, "sessions.db"))
+}
+
+// table starts tab-aligned output with a header row
+func (c *cli) table(header string) *tabwriter.Writer {
+	tw := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, header)
+	return tw
+}
+
+// ============================================================================
+// Library
+// ============================================================================
+
+func (c *cli) libraryAdd(args []string) error {
+	// Relative to where the command runs, not the server
+	path, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+	name := ""
+	if len(args) > 1 {
+		name = args[1]
+	}
+
+	var loc models.LibraryLocation
+	if c.running() {
+		err = c.call(http.MethodPost, "/api/library/locations", map[string]string{"path": path, "name": name}, &loc)
+	} else {
+		var lib *library.Manager
+		if lib, err = c.openLibrary(); err != nil {
+			return err
+		}
+		defer lib.Close()
+		var added *models.LibraryLocation
+		if added, err = lib.AddLocation(path, name); err == nil {
+			loc = *added
+		}
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Added location %d: %s\nRun `songmartyn library scan %d` to find its songs\n", loc.ID, loc.Path, loc.ID)
+	return nil
+}
+
+func (c *cli) libraryScan(args []string) error {
+	var lib *library.Manager
+	if !c.running() {
+		var err error
+		if lib, err = c.openLibrary(); err != nil {
+			return err
+		}
+		defer lib.Close()
+	}
+
+	locations, err := c.locations(lib)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid location ID %q", args[0])
+		}
+		var only []models.LibraryLocation
+		for _, loc := range locations {
+			if loc.ID == id {
+				only = append(only, loc)
+			}
+		}
+		if len(only) == 0 {
+			return fmt.Errorf("no library location %d", id)
+		}
+		locations = only
+	}
+	if len(locations) == 0 {
+		return errors.New("no library locations; add one with `songmartyn library add <path>`")
+	}
+
+	for _, loc := range locations {
+		var found int
+		if lib == nil {
+			var resp struct {
+				SongsFound int `json:"songs_found"`
+			}
+			err = c.call(http.MethodPost, fmt.Sprintf("/api/library/locations/%d/scan", loc.ID), nil, &resp)
+			found = resp.SongsFound
+		} else {
+			found, err = lib.ScanLocation(loc.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", loc.Path, err)
+		}
+		fmt.Fprintf(c.out, "%s: %d songs\n", loc.Path, found)
+	}
+	return nil
+}
+
+func (c *cli) libraryList(args []string) error {
+	var lib *library.Manager
+	if !c.running() {
+		var err error
+		if lib, err = c.openLibrary(); err != nil {
+			return err
+		}
+		defer lib.Close()
+	}
+	locations, err := c.locations(lib)
+	if err != nil {
+		return err
+	}
+	if len(locations) == 0 {
+		fmt.Fprintln(c.out, "No library locations")
+		return nil
+	}
+
+	tw := c.table("ID\tNAME\tSONGS\tLAST SCAN\tPATH")
+	for _, loc := range locations {
+		scanned := "never"
+		if !loc.LastScan.IsZero() {
+			scanned = loc.LastScan.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\n", loc.ID, loc.Name, loc.SongCount, scanned, loc.Path)
+	}
+	return tw.Flush()
+}
+
+// locations lists the library locations from lib, or the running server when lib is nil
+func (c *cli) locations(lib *library.Manager) ([]models.LibraryLocation, error) {
+	if lib != nil {
+		return lib.GetLocations()
+	}
+	var locations []models.LibraryLocation
+	err := c.call(http.MethodGet, "/api/library/locations", nil, &locations)
+	return locations, err
+}
+
+// ============================================================================
+// Queue
+// ============================================================================
+
+func (c *cli) queueShow(args []string) error {
+	var state models.QueueState
+	if c.running() {
+		if err := c.call(http.MethodGet, "/api/admin/queue", nil, &state); err != nil {
+			return err
+		}
+	} else {
+		q, err := c.openQueue()
+		if err != nil {
+			return err
+		}
+		defer q.Close()
+		state = q.GetState()
+	}
+	if state.Position >= len(state.Songs) {
+		fmt.Fprintln(c.out, "The queue is empty")
+		return nil
+	}
+
+	// Singers by name where they're known
+	sessions, _, err := c.sessions()
+	if err != nil {
+		return err
+	}
+	names := make(map[string]string)
+	for _, s := range sessions {
+		names[s.MartynKey] = s.DisplayName
+	}
+
+	tw := c.table("#\tSONG ID\tTITLE\tARTIST\tSINGER")
+	for i, song := range state.Songs[state.Position:] {
+		pos := strconv.Itoa(i + 1)
+		if i == 0 {
+			pos = "now"
+		}
+		singer := names[song.AddedBy]
+		if singer == "" {
+			singer = song.AddedBy
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", pos, song.ID, song.Title, song.Artist, singer)
+	}
+	return tw.Flush()
+}
+
+func (c *cli) queueClear(args []string) error {
+	if c.running() {
+		if err := c.call(http.MethodDelete, "/api/admin/queue", nil, nil); err != nil {
+			return err
+		}
+	} else {
+		q, err := c.openQueue()
+		if err != nil {
+			return err
+		}
+		defer q.Close()
+		if err := q.Clear(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(c.out, "Queue cleared")
+	return nil
+}
+
+func (c *cli) queueAdd(args []string) error {
+	singer := ""
+	if len(args) > 1 {
+		singer = args[1]
+	}
+
+	var song models.Song
+	if c.running() {
+		if err := c.call(http.MethodPost, "/api/admin/queue", map[string]string{"song_id": args[0], "singer": singer}, &song); err != nil {
+			return err
+		}
+	} else {
+		lib, err := c.openLibrary()
+		if err != nil {
+			return err
+		}
+		defer lib.Close()
+		libSong, err := lib.GetSong(args[0])
+		if err != nil || libSong == nil {
+			return fmt.Errorf("song not found: %s", args[0])
+		}
+
+		q, err := c.openQueue()
+		if err != nil {
+			return err
+		}
+		defer q.Close()
+		song = queueSongFromLibrary(libSong, models.VocalOff, singer)
+		if err := q.Add(song); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(c.out, "Queued %s - %s\n", song.Artist, song.Title)
+	return nil
+}
+
+// ============================================================================
+// Sessions
+// ============================================================================
+
+func (c *cli) sessionsList(args []string) error {
+	sessions, blocked, err := c.sessions()
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		fmt.Fprintln(c.out, "No singers yet")
+	} else {
+		tw := c.table("MARTYN KEY\tNAME\tONLINE\tLAST SEEN\tDEVICE")
+		for _, s := range sessions {
+			online := "no"
+			if s.IsOnline {
+				online = "yes"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.MartynKey, s.DisplayName, online, s.LastSeenAt.Local().Format("2006-01-02 15:04"), s.DeviceName)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(blocked) == 0 {
+		return nil
+	}
+	fmt.Fprintln(c.out, "\nBlocked:")
+	tw := c.table("MARTYN KEY\tNAME\tUNTIL\tREASON")
+	for _, b := range blocked {
+		until := "permanently"
+		if b.BlockedUntil != nil {
+			until = b.BlockedUntil.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", b.MartynKey, b.DisplayName, until, b.Reason)
+	}
+	return tw.Flush()
+}
+
+// sessions returns every known singer and the block list
+func (c *cli) sessions() ([]models.Session, []session.BlockedUser, error) {
+	if c.running() {
+		var resp struct {
+			Sessions []models.Session      `json:"sessions"`
+			Blocked  []session.BlockedUser `json:"blocked"`
+		}
+		err := c.call(http.MethodGet, "/api/admin/sessions", nil, &resp)
+		return resp.Sessions, resp.Blocked, err
+	}
+	sm, err := c.openSessions()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer sm.Close()
+	return sm.GetAllSessions(), sm.GetBlockedUsers(), nil
+}
+
+func (c *cli) sessionsBlock(args []string) error {
+	key := args[0]
+	minutes := 0
+	if len(args) > 1 {
+		m, err := strconv.Atoi(args[1])
+		if err != nil || m < 0 {
+			return fmt.Errorf("invalid minutes %q", args[1])
+		}
+		minutes = m
+	}
+	reason := "Blocked by admin"
+	if len(args) > 2 {
+		reason = strings.Join(args[2:], " ")
+	}
+
+	if c.running() {
+		body := map[string]interface{}{"minutes": minutes, "reason": reason}
+		if err := c.call(http.MethodPost, "/api/admin/clients/"+url.PathEscape(key)+"/block", body, nil); err != nil {
+			return err
+		}
+	} else {
+		sm, err := c.openSessions()
+		if err != nil {
+			return err
+		}
+		defer sm.Close()
+		if err := sm.BlockUser(key, time.Duration(minutes)*time.Minute, reason); err != nil {
+			return err
+		}
+
+		// Their songs leave the queue, as they would with the server running
+		q, err := c.openQueue()
+		if err != nil {
+			return err
+		}
+		defer q.Close()
+		if _, err := q.RemoveByUser(key); err != nil {
+			return err
+		}
+	}
+
+	if minutes == 0 {
+		fmt.Fprintf(c.out, "Blocked %s permanently\n", key)
+	} else {
+		fmt.Fprintf(c.out, "Blocked %s for %d minutes\n", key, minutes)
+	}
+	return nil
+}
+
+func (c *cli) sessionsUnblock(args []string) error {
+	key := args[0]
+	if c.running() {
+		if err := c.call(http.MethodDelete, "/api/admin/clients/"+url.PathEscape(key)+"/block", nil, nil); err != nil {
+			return err
+		}
+	} else {
+		sm, err := c.openSessions()
+		if err != nil {
+			return err
+		}
+		defer sm.Close()
+		if err := sm.UnblockUser(key); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(c.out, "Unblocked %s\n", key)
+	return nil
+}
+
+// ============================================================================
+// Admin
+// ============================================================================
+
+// adminSetPIN saves the PIN in the configuration file, which a running server reloads
+func (c *cli) adminSetPIN(args []string) error {
+	path := c.config.ConfigPath
+	f, err := configfile.Load(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	f.Server.AdminPIN = args[0]
+	if err := configfile.Save(path, f); err != nil {
+		return err
+	}
+	if c.running() {
+		if err := c.call(http.MethodPost, "/api/admin/server/reload", nil, nil); err != nil {
+			return err
+		}
+	}
+
+	if args[0] == "" {
+		fmt.Fprintf(c.out, "Admin PIN cleared in %s; the admin panel only works from this machine\n", path)
+	} else {
+		fmt.Fprintf(c.out, "Admin PIN saved in %s\n", path)
+	}
+	if _, ok := os.LookupEnv("ADMIN_PIN"); ok {
+		fmt.Fprintln(c.out, "Warning: ADMIN_PIN is set in the environment and overrides the file")
+	}
+	return nil
+}
+
+// ============================================================================
+// Database
+// ============================================================================
+
+func (c *cli) dbBackup(args []string) error {
+	var info backup.Info
+	if c.running() {
+		var resp struct {
+			Backup backup.Info `json:"backup"`
+		}
+		if err := c.call(http.MethodPost, "/api/admin/backups", nil, &resp); err != nil {
+			return err
+		}
+		info = resp.Backup
+	} else {
+		var err error
+		if info, err = backup.Save(context.Background(), backupDir(c.config), backupSet(c.config)); err != nil {
+			return err
+		}
+		if _, err := backup.Rotate(backupDir(c.config), c.config.BackupKeep); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(c.out, "Backup written to %s\n", filepath.Join(backupDir(c.config), info.Name))
+	return nil
+}
+
+// dbRestore restores an archive: a running server checks it and hot restarts with it,
+// a stopped one gets it the next time it starts
+func (c *cli) dbRestore(args []string) error {
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if c.running() {
+		var resp struct {
+			CreatedAt time.Time `json:"created_at"`
+		}
+		if err := c.send(http.MethodPost, "/api/admin/backups/restore", f, &resp); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Restoring the backup taken %s; the server is restarting\n", resp.CreatedAt.Local().Format(time.RFC1123))
+		return nil
+	}
+
+	m, err := backup.Stage(f, c.config.DataDir, backupSet(c.config))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Backup taken %s checked; it will be restored when SongMartyn next starts\n", m.CreatedAt.Local().Format(time.RFC1123))
+	return nil
+}
+
+func (c *cli) dbVacuum(args []string) error {
+	if c.running() {
+		var resp struct {
+			Message string `json:"message"`
+		}
+		if err := c.call(http.MethodPost, "/api/admin/database", map[string]string{"action": "vacuum"}, &resp); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, resp.Message)
+		return nil
+	}
+
+	freed, err := backup.Vacuum(context.Background(), backupSet(c.config))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Databases compacted, %.1f MB freed\n", float64(freed)/(1<<20))
+	return nil
+}
+
+// ============================================================================
+// Doctor
+// ============================================================================
+
+// Check results printed by doctor
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "FAIL"
+)
+
+// minFreeDisk is the free space below which doctor warns; backups and recordings need room
+const minFreeDisk = 1 << 30
+
+// certWarnBefore is how close to expiry a certificate file makes doctor warn
+const certWarnBefore = 30 * 24 * time.Hour
+
+// doctor checks everything the server needs, one line per check
+func (c *cli) doctor(args []string) error {
+	failures := 0
+	report := func(result, format string, a ...any) {
+		if result == checkFail {
+			failures++
+		}
+		fmt.Fprintf(c.out, "%-4s  %s\n", result, fmt.Sprintf(format, a...))
+	}
+
+	// Configuration (an invalid file stops the command before it gets here)
+	if _, err := os.Stat(c.config.ConfigPath); err == nil {
+		report(checkOK, "Configuration: %s", c.config.ConfigPath)
+	} else {
+		report(checkWarn, "Configuration: no file at %s, using defaults", c.config.ConfigPath)
+	}
+
+	if c.running() {
+		report(checkOK, "Server: running")
+	} else {
+		report(checkOK, "Server: not running")
+	}
+
+	// Data directory
+	if f, err := os.CreateTemp(c.config.DataDir, ".doctor-*"); err != nil {
+		report(checkFail, "Data directory %s is not writable: %v", c.config.DataDir, err)
+	} else {
+		f.Close()
+		os.Remove(f.Name())
+		report(checkOK, "Data directory: %s", c.config.DataDir)
+	}
+	if _, err := os.Stat(filepath.Join(c.config.DataDir, backup.PendingDir)); err == nil {
+		report(checkWarn, "A restored backup is waiting for the next start")
+	}
+
+	// Databases
+	for _, db := range backupSet(c.config).Databases {
+		version, err := backup.Check(db)
+		switch {
+		case err != nil:
+			report(checkFail, "%s: %v", db.Name, err)
+		case version == 0:
+			report(checkOK, "%s: not created yet", db.Name)
+		default:
+			report(checkOK, "%s: intact, schema version %d", db.Name, version)
+		}
+	}
+
+	// Tools
+	if c.config.PlayerBackend != "web" {
+		player := c.config.VideoPlayer
+		if player == "" {
+			player = "mpv"
+		}
+		c.checkTool(report, checkFail, player, "plays the songs")
+	}
+	decoder := c.config.LoudnessDecoder
+	if decoder == "" {
+		decoder = loudness.DefaultDecoder
+	}
+	c.checkTool(report, checkWarn, strings.Fields(decoder)[0], "measures loudness and saves recordings")
+	c.checkTool(report, checkWarn, "yt-dlp", "lets mpv play YouTube songs")
+
+	// Ports
+	for _, port := range []string{c.config.Port, c.config.HTTPPort} {
+		if port == "" {
+			continue
+		}
+		if c.running() {
+			report(checkOK, "Port %s: in use by the server", port)
+		} else if err := checkPortFree(port); err != nil {
+			report(checkFail, "Port %s: %v", port, err)
+		} else {
+			report(checkOK, "Port %s: free", port)
+		}
+	}
+
+	c.checkTLS(report)
+
+	// Disk space
+	if total, free, _ := getDiskInfo(c.config.DataDir); total == 0 {
+		report(checkWarn, "Disk: free space unknown")
+	} else if free < minFreeDisk {
+		report(checkWarn, "Disk: only %.1f GB free", float64(free)/(1<<30))
+	} else {
+		report(checkOK, "Disk: %.1f GB free", float64(free)/(1<<30))
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d problem(s) found", failures)
+	}
+	return nil
+}
+
+// checkTool reports whether a program is on the PATH, with result if it isn't
+func (c *cli) checkTool(report func(string, string, ...any), result, name, purpose string) {
+	if path, err := exec.LookPath(name); err != nil {
+		report(result, "%s not found (it %s)", name, purpose)
+	} else {
+		report(checkOK, "%s: %s", name, path)
+	}
+}
+
+// checkTLS reports on the certificate files, or the local CA without them
+func (c *cli) checkTLS(report func(string, string, ...any)) {
+	if hasCertFiles(c.config) {
+		cert, err := tls.LoadX509KeyPair(c.config.CertFile, c.config.KeyFile)
+		if err != nil {
+			report(checkFail, "TLS: %v", err)
+			return
+		}
+		switch expires := cert.Leaf.NotAfter; {
+		case time.Now().After(expires):
+			report(checkFail, "TLS: %s expired %s", c.config.CertFile, expires.Local().Format("2006-01-02"))
+		case time.Until(expires) < certWarnBefore:
+			report(checkWarn, "TLS: %s expires %s", c.config.CertFile, expires.Local().Format("2006-01-02"))
+		default:
+			report(checkOK, "TLS: %s, valid until %s", c.config.CertFile, expires.Local().Format("2006-01-02"))
+		}
+		return
+	}
+	if !c.config.LocalCA {
+		report(checkFail, "TLS: no certificate at %s and the local CA is off", c.config.CertFile)
+		return
+	}
+
+	dir := filepath.Join(c.config.DataDir, "ca")
+	if _, err := os.Stat(filepath.Join(dir, "ca.pem")); errors.Is(err, os.ErrNotExist) {
+		report(checkOK, "TLS: the local CA will be created on first start")
+		return
+	}
+	authority, err := certs.NewAuthority(dir)
+	if err != nil {
+		report(checkFail, "TLS: local CA: %v", err)
+		return
+	}
+	report(checkOK, "TLS: local CA %s (fingerprint %s)", authority.Name(), authority.Fingerprint())
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"songmartyn/internal/configfile"
+	"songmartyn/internal/library"
+	"songmartyn/internal/session"
+)
+
+// runTestCommand runs a command-line command, returning its exit code and output
+func runTestCommand(config Config, args ...string) (int, string) {
+	var out bytes.Buffer
+	code := runCommand(config, args, &out, &out)
+	return code, out.String()
+}
+
+// newTestSongs makes a folder holding one karaoke file
+func newTestSongs(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Toto - Africa.mp4"), []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// startTestSocket serves app's admin API on its admin socket
+func startTestSocket(t *testing.T, app *App) {
+	t.Helper()
+	app.handler = app.routes()
+	if err := app.startAdminSocket(); err != nil {
+		t.Fatalf("Failed to start admin socket: %v", err)
+	}
+	t.Cleanup(app.stopAdminSocket)
+}
+
+// ============================================================================
+// Command-Line Tests
+// ============================================================================
+
+func TestCommandUsageErrors(t *testing.T) {
+	config := Config{DataDir: t.TempDir()}
+
+	tests := []struct {
+		args []string
+		code int
+		want string
+	}{
+		{[]string{"help"}, 0, "sessions block"},
+		{[]string{"queue", "shuffle"}, 2, "Unknown command: queue shuffle"},
+		{[]string{"library", "add"}, 2, "Usage: songmartyn library add <path> [name]"},
+		{[]string{"sessions", "unblock", "a", "b"}, 2, "Usage: songmartyn sessions unblock"},
+		{[]string{"sessions", "block", "abc", "soon"}, 1, `invalid minutes "soon"`},
+	}
+	for _, tt := range tests {
+		code, out := runTestCommand(config, tt.args...)
+		if code != tt.code || !strings.Contains(out, tt.want) {
+			t.Errorf("%v: expected exit %d with %q, got %d: %s", tt.args, tt.code, tt.want, code, out)
+		}
+	}
+}
+
+func TestCommandsWorkOnFilesWhenStopped(t *testing.T) {
+	config := Config{DataDir: t.TempDir(), ConfigPath: filepath.Join(t.TempDir(), "songmartyn.toml"), BackupKeep: 7}
+	songs := newTestSongs(t)
+
+	run := func(want string, args ...string) string {
+		t.Helper()
+		code, out := runTestCommand(config, args...)
+		if code != 0 || !strings.Contains(out, want) {
+			t.Fatalf("%v: expected %q, got %d: %s", args, want, code, out)
+		}
+		return out
+	}
+
+	run("Added location 1", "library", "add", songs, "Karaoke")
+	run("1 songs", "library", "scan")
+	run("Karaoke", "library", "list")
+
+	lib, err := library.NewManager(filepath.Join(config.DataDir, "library.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, _ := lib.SearchSongs("Africa", 1)
+	lib.Close()
+	if len(found) != 1 {
+		t.Fatalf("Expected the scanned song in the library, got %v", found)
+	}
+	run("Queued Toto - Africa", "queue", "add", found[0].ID, "alice")
+	run("Africa", "queue", "show")
+
+	run("Blocked alice permanently", "sessions", "block", "alice", "0", "too", "loud")
+	sm, err := session.NewManager(filepath.Join(config.DataDir, "sessions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocked, reason := sm.IsBlocked("alice")
+	sm.Close()
+	if !blocked || reason != "too loud" {
+		t.Errorf("Expected alice blocked for being too loud, got %v %q", blocked, reason)
+	}
+	run("The queue is empty", "queue", "show") // Blocking drops their songs
+	run("too loud", "sessions", "list")
+	run("Unblocked alice", "sessions", "unblock", "alice")
+
+	run("Admin PIN saved", "admin", "set-pin", "2468")
+	if f, err := configfile.Load(config.ConfigPath); err != nil || f.Server.AdminPIN != "2468" {
+		t.Errorf("Expected the PIN in the configuration file, got %q, %v", f.Server.AdminPIN, err)
+	}
+
+	run("Backup written", "db", "backup")
+	run("MB freed", "db", "vacuum")
+	backups, _ := filepath.Glob(filepath.Join(backupDir(config), "*.tar.gz"))
+	if len(backups) != 1 {
+		t.Fatalf("Expected one backup, got %v", backups)
+	}
+	run("restored when SongMartyn next starts", "db", "restore", backups[0])
+
+	code, out := runTestCommand(config, "doctor")
+	if !strings.Contains(out, "library.db: intact") || !strings.Contains(out, "A restored backup is waiting") {
+		t.Errorf("Expected doctor to check the databases, got %d: %s", code, out)
+	}
+}
+
+func TestCommandsUseRunningServer(t *testing.T) {
+	app, _ := newTestApp(t)
+	startTestSocket(t, app)
+	queueTestSong(t, app, "s1", "alice")
+
+	run := func(want string, args ...string) {
+		t.Helper()
+		code, out := runTestCommand(app.config, args...)
+		if code != 0 || !strings.Contains(out, want) {
+			t.Fatalf("%v: expected %q, got %d: %s", args, want, code, out)
+		}
+	}
+
+	run("Song s1", "queue", "show")
+	run("Blocked alice for 30 minutes", "sessions", "block", "alice", "30")
+	if blocked, _ := app.sessions.IsBlocked("alice"); !blocked {
+		t.Error("Expected the server to block alice")
+	}
+	if !app.queue.IsEmpty() {
+		t.Error("Expected alice's songs dropped from the queue")
+	}
+	run("Unblocked alice", "sessions", "unblock", "alice")
+
+	queueTestSong(t, app, "s2", "bob")
+	run("Queue cleared", "queue", "clear")
+	if !app.queue.IsEmpty() {
+		t.Error("Expected the server's queue cleared")
+	}
+
+	// Doctor's other checks depend on this machine
+	if _, out := runTestCommand(app.config, "doctor"); !strings.Contains(out, "Server: running") || !strings.Contains(out, "in use by the server") {
+		t.Errorf("Expected doctor to find the server, got %s", out)
+	}
+}
+
+func TestAdminSocketNeedsToken(t *testing.T) {
+	app, _ := newTestApp(t)
+	startTestSocket(t, app)
+
+	c, err := newCLI(app.config, &bytes.Buffer{})
+	if err != nil || !c.running() {
+		t.Fatalf("Expected to reach the server, got %v", err)
+	}
+	c.token = "wrong"
+	if err := c.call(http.MethodGet, "/api/admin/queue", nil, nil); err == nil || !strings.Contains(err.Error(), "Invalid admin socket token") {
+		t.Errorf("Expected a wrong token refused, got %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(app.config.DataDir, adminTokenFile))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected a token only this user can read, got %v, %v", info, err)
+	}
+
+	// A second server can't take over the socket
+	if err := app.startAdminSocket(); err == nil {
+		t.Error("Expected the socket in use to be refused")
+	}
+
+	app.stopAdminSocket()
+	if _, err := os.Stat(filepath.Join(app.config.DataDir, adminTokenFile)); !os.IsNotExist(err) {
+		t.Error("Expected the token removed when the server stops")
+	}
+	if c, _ := newCLI(app.config, &bytes.Buffer{}); c.running() {
+		t.Error("Expected commands to work on the files once the server stops")
+	}
+}
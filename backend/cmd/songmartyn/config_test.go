@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"songmartyn/internal/configfile"
+)
+
+// ============================================================================
+// Configuration File Tests
+// ============================================================================
+
+func TestRuntimeSettingsSavedToConfigFile(t *testing.T) {
+	app, _ := newTestApp(t)
+	app.config.ConfigPath = filepath.Join(t.TempDir(), "songmartyn.toml")
+
+	rec := httptest.NewRecorder()
+	app.handleBGM(rec, httptest.NewRequest(http.MethodPost, "/api/admin/bgm",
+		strings.NewReader(`{"enabled": true, "source_type": "icecast", "url": "https://example.com/stream", "volume": 35}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected BGM update to succeed, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	app.handleLoudness(rec, httptest.NewRequest(http.MethodPost, "/api/admin/loudness",
+		strings.NewReader(`{"enabled": false, "target_lufs": -20}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected loudness update to succeed, got %d", rec.Code)
+	}
+
+	saved, err := configfile.Load(app.config.ConfigPath)
+	if err != nil {
+		t.Fatalf("Expected a valid configuration file: %v", err)
+	}
+	want := configfile.BGM{Enabled: true, Source: "icecast", URL: "https://example.com/stream", Volume: 35}
+	if saved.BGM != want {
+		t.Errorf("Expected BGM %+v saved, got %+v", want, saved.BGM)
+	}
+	if saved.Loudness.Normalization || saved.Loudness.TargetLUFS != -20 {
+		t.Errorf("Expected loudness settings saved, got %+v", saved.Loudness)
+	}
+
+	// Settings the schema rejects aren't applied or saved
+	rec = httptest.NewRecorder()
+	app.handleSettings(rec, httptest.NewRequest(http.MethodPost, "/api/admin/settings",
+		strings.NewReader(`{"https_port": "8443", "http_port": "8443", "admin_pin": "1234", "video_player": "mpv", "data_dir": "./data"}`)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "server.http_port") {
+		t.Errorf("Expected an error naming server.http_port, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if app.admin.GetPIN() == "1234" {
+		t.Error("Expected the rejected settings not to be applied")
+	}
+}
+
+func TestConfigFileEditsApplyLive(t *testing.T) {
+	app, _ := newTestApp(t)
+	app.config.ConfigPath = filepath.Join(t.TempDir(), "songmartyn.toml")
+
+	edit := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(app.config.ConfigPath, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		// Make sure the edit gets a new modification time
+		later := app.configModTime.Add(time.Second)
+		os.Chtimes(app.config.ConfigPath, later, later)
+		app.checkConfigFile()
+	}
+
+	edit(`
+[server]
+mdns_hostname = ""
+
+[features]
+fair_rotation = true
+
+[holding]
+message = "Last orders at 11"
+
+[transitions]
+song_fade_out = 3
+`)
+	if !app.config.FairRotationEnabled || app.holdingMessage != "Last orders at 11" {
+		t.Errorf("Expected the edit to be applied, got fair rotation %v, message %q", app.config.FairRotationEnabled, app.holdingMessage)
+	}
+	if got := app.transitions.Settings().SongFadeOut; got != 3*time.Second {
+		t.Errorf("Expected a 3s song fade-out, got %v", got)
+	}
+
+	// A broken edit leaves the running settings alone
+	edit("[features]\nfair_rotation = \"maybe\"\n")
+	if !app.config.FairRotationEnabled {
+		t.Error("Expected the invalid edit to be ignored")
+	}
+}
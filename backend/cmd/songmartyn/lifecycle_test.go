@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"songmartyn/internal/mpv"
+)
+
+// ============================================================================
+// Server Lifecycle Tests
+// ============================================================================
+
+// freePort returns a port nothing is listening on
+func freePort(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	defer ln.Close()
+	return strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+}
+
+func TestApplySettingsWithoutRestart(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	next := app.config
+	next.AdminPIN = "4321"
+	next.FairRotationEnabled = true
+	next.DataDir = t.TempDir()
+	restartNeeded, err := app.applySettings(next)
+	if err != nil {
+		t.Fatalf("applySettings failed: %v", err)
+	}
+	if app.admin.GetPIN() != "4321" || !app.config.FairRotationEnabled {
+		t.Errorf("Expected the PIN and fair rotation applied, got %q, %v", app.admin.GetPIN(), app.config.FairRotationEnabled)
+	}
+	if len(restartNeeded) != 1 || restartNeeded[0] != "server.data_dir" {
+		t.Errorf("Expected only server.data_dir to need a restart, got %v", restartNeeded)
+	}
+
+	// A certificate that doesn't load changes nothing
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "cert.pem"), []byte("cert"), 0644)
+	os.WriteFile(filepath.Join(dir, "key.pem"), []byte("key"), 0600)
+	next = app.config
+	next.AdminPIN = "9999"
+	next.CertFile, next.KeyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if _, err := app.applySettings(next); err == nil {
+		t.Error("Expected an error for an invalid certificate")
+	}
+	if app.admin.GetPIN() != "4321" || app.config.CertFile == next.CertFile {
+		t.Error("Expected settings to be left alone when the certificate fails to load")
+	}
+}
+
+func TestPortChangeMovesServers(t *testing.T) {
+	app, _ := newTestApp(t)
+	app.config.Port, app.config.HTTPPort = freePort(t), freePort(t)
+	app.handler = http.NotFoundHandler()
+	if err := app.startServers(); err != nil {
+		t.Fatalf("startServers failed: %v", err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	redirectsTo := func(httpPort, httpsPort string) bool {
+		resp, err := client.Get("http://127.0.0.1:" + httpPort + "/admin")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.Header.Get("Location") == "https://127.0.0.1:"+httpsPort+"/admin"
+	}
+
+	oldHTTPPort := app.config.HTTPPort
+	next := app.config
+	next.Port, next.HTTPPort = freePort(t), freePort(t)
+	if _, err := app.applySettings(next); err != nil {
+		t.Fatalf("applySettings failed: %v", err)
+	}
+	waitFor(t, "servers on the new ports", func() bool { return redirectsTo(next.HTTPPort, next.Port) })
+	if _, err := client.Get("http://127.0.0.1:" + oldHTTPPort + "/"); err == nil {
+		t.Error("Expected the old HTTP port to be closed")
+	}
+
+	// A port in use is refused before anything moves
+	busy, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer busy.Close()
+	moved := app.config
+	moved.HTTPPort = strconv.Itoa(busy.Addr().(*net.TCPAddr).Port)
+	if _, err := app.applySettings(moved); err == nil {
+		t.Error("Expected an error for a port in use")
+	}
+	if app.config.HTTPPort != next.HTTPPort || !redirectsTo(next.HTTPPort, next.Port) {
+		t.Error("Expected the servers to stay where they were")
+	}
+}
+
+func TestHotRestartAdoptsPlayingSong(t *testing.T) {
+	config := Config{DataDir: t.TempDir(), Port: "8443"}
+	player := mpv.NewFakePlayer()
+	app, err := newAppWithPlayer(config, player)
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	player.Start()
+	song := queueTestSong(t, app, "s1", "alice")
+	app.playCurrentSong()
+
+	if err := app.saveRestartState(); err != nil {
+		t.Fatalf("saveRestartState failed: %v", err)
+	}
+	app.shutdown(true)
+	if player.IsRunning() {
+		t.Fatal("Expected the old process to let go of the player")
+	}
+
+	// The new process finds the player still running
+	restarted, err := newAppWithPlayer(config, player)
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	t.Cleanup(restarted.Shutdown)
+	player.Start()
+	if !restarted.resumeAfterRestart() {
+		t.Fatal("Expected playback to carry on after the restart")
+	}
+	if restarted.idle || player.Current() != song.VideoURL {
+		t.Errorf("Expected %s still playing, got idle=%v current=%q", song.VideoURL, restarted.idle, player.Current())
+	}
+	if _, err := os.Stat(filepath.Join(config.DataDir, restartStateFile)); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected the restart state to be used once")
+	}
+
+	// Without an adopted player the app starts fresh
+	restarted.saveRestartState()
+	player.Stop()
+	player.Start()
+	if restarted.resumeAfterRestart() {
+		t.Error("Expected a fresh start when the player was restarted")
+	}
+}
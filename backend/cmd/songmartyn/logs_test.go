@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"songmartyn/internal/configfile"
+	"songmartyn/internal/logging"
+)
+
+// ============================================================================
+// Log Viewer Tests
+// ============================================================================
+
+// useTestLogs gives the app its own log so tests don't see each other's entries
+func useTestLogs(app *App) *logging.Log {
+	app.logs = logging.New(io.Discard, 100)
+	return app.logs
+}
+
+func TestLogViewerFiltersEntries(t *testing.T) {
+	app, _ := newTestApp(t)
+	logs := useTestLogs(app)
+	logs.Levels.Set("ws", slog.LevelDebug)
+	logs.Logger("ws").Debug("Connection attempt", "ip", "10.0.0.2")
+	logs.Logger("mpv").Error("Failed to load", "path", "/songs/Africa.mp4")
+	logs.Logger("mpv").Debug("Position") // Below mpv's level, never recorded
+
+	get := func(query string) (map[string]json.RawMessage, []logging.Entry) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		app.handleLogs(rec, httptest.NewRequest(http.MethodGet, "/api/admin/logs"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200 for %q, got %d: %s", query, rec.Code, rec.Body.String())
+		}
+		var resp map[string]json.RawMessage
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		var entries []logging.Entry
+		json.Unmarshal(resp["entries"], &entries)
+		return resp, entries
+	}
+
+	resp, entries := get("")
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %+v", entries)
+	}
+	var levels LogLevels
+	json.Unmarshal(resp["levels"], &levels)
+	if levels.Level != "info" || levels.Subsystems["ws"] != "debug" {
+		t.Errorf("Expected info with ws at debug, got %+v", levels)
+	}
+
+	if _, entries := get("?level=warn"); len(entries) != 1 || entries[0].Subsystem != "mpv" {
+		t.Errorf("Expected only the mpv error, got %+v", entries)
+	}
+	if _, entries := get("?subsystem=ws&q=10.0.0"); len(entries) != 1 || entries[0].Message != "Connection attempt" {
+		t.Errorf("Expected the ws entry, got %+v", entries)
+	}
+	if _, entries := get("?after=1"); len(entries) != 1 || entries[0].Seq != 2 {
+		t.Errorf("Expected entries after seq 1, got %+v", entries)
+	}
+
+	rec := httptest.NewRecorder()
+	app.handleLogs(rec, httptest.NewRequest(http.MethodGet, "/api/admin/logs?level=loud", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown level, got %d", rec.Code)
+	}
+}
+
+func TestLogStreamSendsBacklogThenNewEntries(t *testing.T) {
+	app, _ := newTestApp(t)
+	logs := useTestLogs(app)
+	logs.Logger("ws").Info("Client connected", "clients", 1)
+	logs.Logger("mpv").Info("Started")
+
+	srv := httptest.NewServer(http.HandlerFunc(app.handleLogStream))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "?subsystem=ws&after=0")
+	if err != nil {
+		t.Fatalf("Stream request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected an event stream, got %q", ct)
+	}
+
+	events := make(chan logging.Entry, 10)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				var e logging.Entry
+				json.Unmarshal([]byte(data), &e)
+				events <- e
+			}
+		}
+	}()
+	next := func() logging.Entry {
+		t.Helper()
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for a log event")
+			return logging.Entry{}
+		}
+	}
+
+	if e := next(); e.Message != "Client connected" || e.Seq != 1 {
+		t.Errorf("Expected the buffered ws entry first, got %+v", e)
+	}
+	logs.Logger("mpv").Info("Not for this viewer")
+	logs.Logger("ws").Info("Client disconnected", "clients", 0)
+	if e := next(); e.Message != "Client disconnected" || e.Attrs[0] != (logging.Attr{Key: "clients", Value: "0"}) {
+		t.Errorf("Expected the new ws entry, got %+v", e)
+	}
+}
+
+func TestLogLevelsChangeAtRuntime(t *testing.T) {
+	app, _ := newTestApp(t)
+	logs := useTestLogs(app)
+	app.config.ConfigPath = filepath.Join(t.TempDir(), "songmartyn.toml")
+
+	put := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		app.handleLogLevels(rec, httptest.NewRequest(http.MethodPut, "/api/admin/logs/levels", strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := put(`{"level": "warn", "subsystems": {"ws": "debug"}}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected level change to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !logs.Levels.Enabled("ws", slog.LevelDebug) || logs.Levels.Enabled("mpv", slog.LevelInfo) {
+		t.Error("Expected ws at debug and everything else at warn")
+	}
+	saved, err := configfile.Load(app.config.ConfigPath)
+	if err != nil {
+		t.Fatalf("Expected a valid configuration file: %v", err)
+	}
+	if saved.Logging.Level != "warn" || saved.Logging.Subsystems != "ws=debug" {
+		t.Errorf("Expected levels saved, got %+v", saved.Logging)
+	}
+
+	// An empty level removes the override
+	put(`{"subsystems": {"ws": ""}}`)
+	if logs.Levels.Enabled("ws", slog.LevelInfo) {
+		t.Error("Expected ws to follow the default again")
+	}
+
+	for _, body := range []string{`{"level": "loud"}`, `{"subsystems": {"a=b": "debug"}}`} {
+		if rec := put(body); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, rec.Code)
+		}
+	}
+
+	// Editing the file applies too
+	os.WriteFile(app.config.ConfigPath, []byte("[logging]\nlevel = \"error\"\nsubsystems = \"mpv=debug\"\nbuffer = 10\n"), 0600)
+	if _, err := app.reloadSettings(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if !logs.Levels.Enabled("mpv", slog.LevelDebug) || logs.Levels.Enabled("ws", slog.LevelWarn) {
+		t.Errorf("Expected the file's levels, got default %v overrides %v", logs.Levels.Default(), logs.Levels.Overrides())
+	}
+}
+
+func TestPlaybackLogsToSubsystems(t *testing.T) {
+	app, player := newTestApp(t)
+	queueTestSong(t, app, "s1", "alice")
+	last := logging.Default.Buffer.Entries(logging.Filter{MinLevel: slog.LevelDebug}, 1)
+	var after uint64
+	if len(last) > 0 {
+		after = last[0].Seq
+	}
+
+	app.playCurrentSong()
+	player.FinishTrack()
+
+	find := func(subsystem, message string) *logging.Entry {
+		for _, e := range logging.Default.Buffer.Entries(logging.Filter{MinLevel: slog.LevelDebug, Subsystems: []string{subsystem}, AfterSeq: after}, 0) {
+			if e.Message == message {
+				return &e
+			}
+		}
+		return nil
+	}
+	if e := find("mpv", "Playing"); e == nil || e.Level != "INFO" {
+		t.Errorf("Expected an mpv info entry for the song starting, got %+v", e)
+	}
+	if e := find("queue", "Song finished, moving to history"); e == nil || e.Level != "INFO" {
+		t.Errorf("Expected a queue info entry for the song finishing, got %+v", e)
+	}
+}
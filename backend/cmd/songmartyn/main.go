@@ -0,0 +1,7811 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+
+	"songmartyn/internal/admin"
+	"songmartyn/internal/avatar"
+	"songmartyn/internal/backup"
+	"songmartyn/internal/certs"
+	"songmartyn/internal/configfile"
+	"songmartyn/internal/device"
+	"songmartyn/internal/holdingscreen"
+	"songmartyn/internal/library"
+	"songmartyn/internal/logging"
+	"songmartyn/internal/loudness"
+	"songmartyn/internal/metrics"
+	"songmartyn/internal/mpv"
+	"songmartyn/internal/playlist"
+	"songmartyn/internal/queue"
+	"songmartyn/internal/recording"
+	"songmartyn/internal/session"
+	"songmartyn/internal/transition"
+	"songmartyn/internal/vocalassist"
+	"songmartyn/internal/webdisplay"
+	"songmartyn/internal/websocket"
+	"songmartyn/pkg/models"
+)
+
+// Re-export library.SearchLog for use in handlers
+type SearchLog = library.SearchLog
+
+// Config holds application configuration
+type Config struct {
+	Port          string // HTTPS port
+	HTTPPort      string // HTTP port (for redirect to HTTPS)
+	DataDir       string
+	StaticDir     string
+	DevMode       bool
+	AdminPIN      string
+	CertFile      string
+	KeyFile       string
+	LocalCA       bool // Issue certificates from a built-in CA when CertFile/KeyFile are missing
+	YouTubeAPIKey string
+	VideoPlayer   string
+	PlayerBackend string // "mpv" or "web" (browser display page)
+	WebDisplayKey string // Key the web display page must pass (empty = generated and saved in DataDir)
+	LaunchBrowser bool   // Auto-launch admin page on startup
+
+	// Display settings
+	TargetDisplay  string // Name of display to use for video player
+	AutoFullscreen bool   // Automatically fullscreen the player on startup
+
+	// Feature toggles
+	PitchControlEnabled    bool
+	TempoControlEnabled    bool
+	FairRotationEnabled    bool
+	ScrollingTickerEnabled bool
+	SingerNameOverlay      bool
+
+	// Loudness normalization
+	LoudnessNormalization bool    // Apply a per-song gain toward LoudnessTargetLUFS
+	LoudnessTargetLUFS    float64 // Target integrated loudness
+	LoudnessDecoder       string  // Command decoding songs to PCM for analysis ({input} = file)
+
+	// Live microphone effects
+	MicEffectsEnabled bool   // Route the mic through the current singer's effects preset
+	MicDevice         string // mpv capture URL, e.g. av://pulse:default
+
+	// Performance recording
+	RecordingEnabled       bool    // Record performances of singers who opt in
+	RecordingCommand       string  // Capture command for the mixed output ({output} = file)
+	RecordingExtension     string  // File extension matching the capture command's format
+	RecordingMaxMB         float64 // Disk cap across all recordings (0 = unlimited)
+	RecordingRetentionDays float64 // Recordings older than this are deleted (0 = keep)
+	RecordingLinkHours     float64 // How long download links stay valid
+
+	// Transitions (seconds; 0 turns one off)
+	BGMCrossfade  float64 // BGM fades out while the next song fades in
+	SongFadeOut   float64 // Songs fade out over their last seconds
+	HoldingFadeIn float64 // The holding screen fades in from black
+	TrimSilence   bool    // Skip leading and trailing silence found by loudness analysis
+
+	// mDNS settings
+	MDNSHostname string // Hostname to advertise via mDNS (e.g., "songmartyn" becomes "songmartyn.local")
+
+	// Settings also changed from the admin panel
+	HoldingMessage string
+	HoldingTheme   string
+	BGM            models.BGMSettings
+
+	// Logging
+	LogLevel      string // debug, info, warn or error
+	LogSubsystems string // Per-subsystem levels, e.g. "ws=debug,mpv=warn"
+	LogBuffer     int    // Recent entries kept for the admin log viewer
+
+	// Backups
+	BackupEnabled       bool    // Scheduled backups into DataDir/backups
+	BackupIntervalHours float64 // Time between scheduled backups
+	BackupKeep          int     // Newest backups kept (0 = keep all)
+
+	Rooms []configfile.Room // Extra karaoke rooms (see rooms.go)
+
+	ConfigPath string // Configuration file that runtime changes are saved to ("" = not saved)
+}
+
+// DiagnosticsInfo represents system diagnostics
+type DiagnosticsInfo struct {
+	PortChecks      []PortCheck   `json:"port_checks"`
+	Displays        []DisplayInfo `json:"displays"`
+	FirewallEnabled bool          `json:"firewall_enabled"`
+	FirewallStatus  string        `json:"firewall_status"`
+}
+
+// PortCheck represents a network port status check
+type PortCheck struct {
+	Port        int    `json:"port"`
+	Protocol    string `json:"protocol"`
+	Description string `json:"description"`
+	Status      string `json:"status"` // "open", "closed", "error"
+	Error       string `json:"error,omitempty"`
+}
+
+// DisplayInfo represents a connected display
+type DisplayInfo struct {
+	Name       string `json:"name"`
+	Resolution string `json:"resolution"`
+	Type       string `json:"type"`       // "built-in", "external", etc.
+	Connection string `json:"connection"` // "HDMI", "DisplayPort", etc.
+	Main       bool   `json:"main"`
+}
+
+// App holds the application state
+type App struct {
+	config        Config
+	mpv           mpv.Player
+	webDisplay    *webdisplay.Display // Set when the web display backend is in use
+	outputs       *mpv.Outputs // Extra screens (singer monitor, audience) following mpv
+	hub           *websocket.RoomHub // This room's view of the hub shared by every room
+	sessions      *session.Manager
+	queue         *queue.Manager
+	admin         *admin.Manager
+	library       *library.Manager
+	playlists     *playlist.Manager
+	holdingScreen *holdingscreen.Generator
+	holdingThemes *holdingscreen.Store // Built-in and uploaded holding screen themes
+	loudnessJob   *loudness.Job
+	mic           *mpv.MicInput // Set when live mic effects are enabled
+	recorder      *recording.Manager // Set when performance recording is enabled
+	transitions   *transition.Scheduler // Fades between songs, BGM and the holding screen
+
+	// BGM (Background Music) state
+	bgmSettings models.BGMSettings
+	bgmActive   bool
+
+	// Idle state (showing holding screen, not playing a song)
+	idle bool
+
+	// Live vocal assist for the song playing
+	vocalMu   sync.Mutex
+	vocalLive float64           // Vocal stem gain (0-1) applied to the current song
+	vocalAuto *vocalassist.Auto // Set while the current song uses AUTO
+	micLevel  micLevelSource    // Mic level for AUTO; the mic input when enabled
+
+	// Guest reactions (see reactions.go)
+	reactionLimit   reactionLimiter
+	reactionSprites reactionCache
+	reactionMu      sync.Mutex
+	reactionCounts  map[string]int // Reactions to the song playing, by reaction
+
+	// Holding screen message (admin-controlled)
+	holdingMessage   string
+	holdingMessageMu sync.RWMutex
+
+	// Inter-song countdown state
+	countdown       models.CountdownState
+	countdownTicker *time.Ticker
+	countdownStop   chan struct{}
+	countdownMu     sync.Mutex
+	countdownTick   time.Duration // One countdown "second" (shortened in tests)
+
+	// System diagnostics (cached at startup)
+	diagnostics   DiagnosticsInfo
+	diagnosticsMu sync.RWMutex
+
+	// mDNS server for local discovery
+	mdnsServer *zeroconf.Server
+
+	// Local CA serving TLS when no certificate files are configured
+	certs *certs.Authority
+
+	// Server lifecycle (see startServers)
+	handler     http.Handler                    // HTTPS routes, built by Run
+	servers     []*http.Server                  // HTTPS and HTTP redirect servers while serving
+	adminSocket *http.Server                    // Admin API for the command-line tools (see startAdminSocket)
+	serverMu    sync.Mutex                      // Guards servers and adminSocket
+	tlsCert     atomic.Pointer[tls.Certificate] // Configured certificate files, as last loaded
+	stopped     chan struct{}                   // Closed once the app has shut down
+	stopOnce    sync.Once
+
+	// Configuration file (see saveSettings)
+	configMu      sync.Mutex
+	configModTime time.Time // As last read or written, to notice edits
+
+	// Served on /metrics (see newAppMetrics)
+	metrics *appMetrics
+
+	// Subsystem levels and recent entries for the log viewer
+	logs *logging.Log
+
+	// Backups (see createBackup)
+	backupMu sync.Mutex // One backup or restore at a time
+	restart  func()     // Hot restart once a restore is staged (replaced in tests)
+
+	// Rooms (see rooms.go); extra rooms are Apps sharing the venue's library, sessions and servers
+	roomID       string                             // "" for the main room
+	roomName     string                             // As shown to guests picking a room
+	venue        *App                               // The main room, set on extra rooms
+	done         chan struct{}                      // Closed when an extra room is removed
+	roomAdmins   map[string]bool                    // MartynKeys signed in with the room's own PIN
+	roomAdminsMu sync.Mutex                         // Guards roomAdmins
+	rooms        map[string]*App                    // Extra rooms by ID, on the main room
+	roomsMu      sync.RWMutex                       // Guards rooms
+	roomPlayer   func(r configfile.Room) mpv.Player // Makes an extra room's player (replaced in tests)
+}
+
+// seconds converts a config value in seconds to a duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// updatedSettings returns the configuration file with update applied, checked against the schema
+// It starts from the file, not the running config, so flags and environment overrides stay out of it
+func (app *App) updatedSettings(update func(*configfile.File)) (configfile.File, error) {
+	file, err := configfile.Load(app.config.ConfigPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return file, err
+	}
+	update(&file)
+	return file, file.Validate()
+}
+
+// saveSettings saves a change made while running to the configuration file
+// Extra rooms save into their [[rooms]] entry (see saveRoomSettings)
+func (app *App) saveSettings(update func(*configfile.File)) error {
+	if app.config.ConfigPath == "" {
+		return nil
+	}
+	if app.venue != nil {
+		return app.venue.saveSettings(app.saveRoomSettings(update))
+	}
+	app.configMu.Lock()
+	defer app.configMu.Unlock()
+
+	file, err := app.updatedSettings(update)
+	if err != nil {
+		return err
+	}
+	if err := configfile.Save(app.config.ConfigPath, file); err != nil {
+		return err
+	}
+	if info, err := os.Stat(app.config.ConfigPath); err == nil {
+		app.configModTime = info.ModTime()
+	}
+	return nil
+}
+
+// openBrowser opens the default browser to the specified URL
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "linux":
+		cmd = exec.Command("xdg-open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		return fmt.Errorf("unsupported platform")
+	}
+	return cmd.Start()
+}
+
+// Subsystem loggers for the busiest paths; the rest of main still logs through the standard log bridge
+var (
+	wsLogger      = logging.For("ws")      // Client messages and admin actions
+	queueLogger   = logging.For("queue")   // Queue changes and countdowns
+	mpvLogger     = logging.For("mpv")     // Song playback
+	bgmLogger     = logging.For("bgm")     // Background music
+	holdingLogger = logging.For("holding") // Holding screens
+)
+
+// Command-line flags (override the configuration file and environment)
+var (
+	flagPort          = flag.String("port", "", "HTTPS server port (overrides HTTPS_PORT)")
+	flagHTTPPort      = flag.String("http-port", "", "HTTP port (overrides HTTP_PORT)")
+	flagDataDir       = flag.String("data", "", "Data directory (overrides DATA_DIR)")
+	flagStaticDir     = flag.String("static", "../frontend/dist", "Static files directory")
+	flagDevMode       = flag.Bool("dev", false, "Development mode (enables CORS)")
+	flagAdminPIN      = flag.String("pin", "", "Admin PIN (overrides ADMIN_PIN)")
+	flagCertFile      = flag.String("cert", "", "TLS certificate (overrides TLS_CERT)")
+	flagKeyFile       = flag.String("key", "", "TLS key (overrides TLS_KEY)")
+	flagYouTubeAPIKey = flag.String("youtube-api-key", "", "YouTube API key (overrides YOUTUBE_API_KEY)")
+	flagLaunchBrowser = flag.Bool("launch-browser", false, "Auto-launch admin page in browser (overrides LAUNCH_BROWSER)")
+	flagPlayerBackend = flag.String("player", "", "Player backend: mpv or web (overrides PLAYER_BACKEND)")
+	flagConfig        = flag.String("config", configfile.DefaultPath, "Configuration file")
+)
+
+func main() {
+	// Performance: Lock OS thread for audio timing on Ubuntu
+	if runtime.GOOS == "linux" {
+		runtime.LockOSThread()
+	}
+
+	// Parse flags (flags override the configuration file)
+	flag.Usage = func() {
+		printUsage(flag.CommandLine.Output())
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Standard log output goes through the subsystem levels and the log viewer
+	log.SetFlags(0)
+	log.SetOutput(logging.Default.LegacyWriter())
+	slog.SetDefault(logging.For(logging.DefaultSubsystem))
+
+	// Settings from an old .env file move into the configuration file
+	if migrated, err := configfile.MigrateEnv(".env", *flagConfig); err != nil {
+		log.Fatalf("Failed to migrate .env: %v", err)
+	} else if migrated {
+		log.Printf("Moved settings from .env to %s (the old file is now .env.migrated)", *flagConfig)
+	}
+	if overrides := configfile.EnvOverrides(os.LookupEnv); len(overrides) > 0 {
+		log.Printf("Environment overrides %s", strings.Join(overrides, ", "))
+	}
+
+	config, err := loadConfig(*flagConfig)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	configureLogging(logging.Default, config)
+
+	// Ensure data directory exists
+	os.MkdirAll(config.DataDir, 0755)
+
+	// Command-line administration (see cli.go)
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(config, flag.Args(), os.Stdout, os.Stderr))
+	}
+
+	// A restore staged by the admin panel or `db restore` goes in before anything opens the databases
+	if restored, err := backup.ApplyPending(config.DataDir, backupSet(config)); err != nil {
+		log.Fatalf("Failed to restore backup: %v", err)
+	} else if restored != nil {
+		log.Printf("Restored backup taken %s", restored.CreatedAt.Format(time.RFC1123))
+		prevDataDir := config.DataDir
+		if config, err = loadConfig(*flagConfig); err != nil {
+			log.Fatalf("Invalid configuration in restored backup: %v", err)
+		}
+		configureLogging(logging.Default, config)
+		if config.DataDir != prevDataDir {
+			log.Printf("Warning: The restored configuration uses data directory %s; the restored data is in %s", config.DataDir, prevDataDir)
+		}
+	}
+
+	app, err := NewApp(config)
+	if err != nil {
+		log.Fatalf("Failed to initialize app: %v", err)
+	}
+
+	// Graceful shutdown on SIGINT/SIGTERM, settings reload on SIGHUP, hot restart on SIGUSR2
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR2)
+
+	go func() {
+		for sig := range sigChan {
+			switch sig {
+			case syscall.SIGHUP:
+				log.Println("Reloading settings...")
+				if _, err := app.reloadSettings(); err != nil {
+					log.Printf("Failed to reload settings: %v", err)
+				}
+			case syscall.SIGUSR2:
+				app.restartProcess()
+			default:
+				log.Println("Shutting down...")
+				app.Shutdown()
+				os.Exit(0)
+			}
+		}
+	}()
+
+	// Auto-launch browser to admin page if configured
+	if config.LaunchBrowser {
+		adminURL := fmt.Sprintf("https://localhost:%s/admin", config.Port)
+		go func() {
+			// Small delay to ensure server is ready
+			time.Sleep(500 * time.Millisecond)
+			if err := openBrowser(adminURL); err != nil {
+				log.Printf("Failed to open browser: %v", err)
+			} else {
+				log.Printf("Opened admin page in browser: %s", adminURL)
+			}
+		}()
+	}
+
+	// Start the server
+	app.Run()
+}
+
+// loadConfig builds the configuration: flags > env > configuration file > defaults
+func loadConfig(path string) (Config, error) {
+	file, err := configfile.Load(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Config{}, err
+	}
+	if err := configfile.ApplyEnv(&file, os.LookupEnv); err != nil {
+		return Config{}, err
+	}
+	if err := file.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	config := configFromFile(file)
+	config.ConfigPath = path
+	config.StaticDir = *flagStaticDir
+	config.DevMode = *flagDevMode
+
+	// Flags override env values if provided
+	if *flagPort != "" {
+		config.Port = *flagPort
+	}
+	if *flagHTTPPort != "" {
+		config.HTTPPort = *flagHTTPPort
+	}
+	if *flagDataDir != "" {
+		config.DataDir = *flagDataDir
+	}
+	if *flagAdminPIN != "" {
+		config.AdminPIN = *flagAdminPIN
+	}
+	if *flagCertFile != "" {
+		config.CertFile = *flagCertFile
+	}
+	if *flagKeyFile != "" {
+		config.KeyFile = *flagKeyFile
+	}
+	if *flagYouTubeAPIKey != "" {
+		config.YouTubeAPIKey = *flagYouTubeAPIKey
+	}
+	if *flagLaunchBrowser {
+		config.LaunchBrowser = true
+	}
+	if *flagPlayerBackend != "" {
+		config.PlayerBackend = *flagPlayerBackend
+	}
+
+	return config, nil
+}
+
+// configFromFile maps the configuration file's settings onto Config
+func configFromFile(f configfile.File) Config {
+	return Config{
+		Port:          strconv.Itoa(f.Server.HTTPSPort),
+		HTTPPort:      strconv.Itoa(f.Server.HTTPPort),
+		DataDir:       f.Server.DataDir,
+		AdminPIN:      f.Server.AdminPIN,
+		CertFile:      f.TLS.Cert,
+		KeyFile:       f.TLS.Key,
+		LocalCA:       f.TLS.LocalCA,
+		YouTubeAPIKey: f.YouTube.APIKey,
+		VideoPlayer:   f.Player.VideoPlayer,
+		PlayerBackend: f.Player.Backend,
+		WebDisplayKey: f.Player.WebDisplayKey,
+		LaunchBrowser: f.Server.LaunchBrowser,
+
+		TargetDisplay:  f.Player.TargetDisplay,
+		AutoFullscreen: f.Player.AutoFullscreen,
+
+		PitchControlEnabled:    f.Features.PitchControl,
+		TempoControlEnabled:    f.Features.TempoControl,
+		FairRotationEnabled:    f.Features.FairRotation,
+		ScrollingTickerEnabled: f.Features.ScrollingTicker,
+		SingerNameOverlay:      f.Features.SingerNameOverlay,
+
+		LoudnessNormalization: f.Loudness.Normalization,
+		LoudnessTargetLUFS:    f.Loudness.TargetLUFS,
+		LoudnessDecoder:       f.Loudness.Decoder,
+
+		MicEffectsEnabled: f.Mic.EffectsEnabled,
+		MicDevice:         f.Mic.Device,
+
+		RecordingEnabled:       f.Recording.Enabled,
+		RecordingCommand:       f.Recording.Command,
+		RecordingExtension:     f.Recording.Extension,
+		RecordingMaxMB:         f.Recording.MaxMB,
+		RecordingRetentionDays: f.Recording.RetentionDays,
+		RecordingLinkHours:     f.Recording.LinkHours,
+
+		BGMCrossfade:  f.Transitions.BGMCrossfade,
+		SongFadeOut:   f.Transitions.SongFadeOut,
+		HoldingFadeIn: f.Transitions.HoldingFadeIn,
+		TrimSilence:   f.Transitions.TrimSilence,
+
+		MDNSHostname: f.Server.MDNSHostname,
+
+		HoldingMessage: f.Holding.Message,
+		HoldingTheme:   f.Holding.Theme,
+		BGM:            bgmFromFile(f.BGM),
+
+		LogLevel:      f.Logging.Level,
+		LogSubsystems: f.Logging.Subsystems,
+		LogBuffer:     f.Logging.Buffer,
+
+		BackupEnabled:       f.Backup.Enabled,
+		BackupIntervalHours: f.Backup.IntervalHours,
+		BackupKeep:          f.Backup.Keep,
+
+		Rooms: f.Rooms,
+	}
+}
+
+// bgmFromFile maps a [bgm] table onto the BGM settings
+func bgmFromFile(b configfile.BGM) models.BGMSettings {
+	return models.BGMSettings{
+		Enabled:    b.Enabled,
+		SourceType: models.BGMSourceType(b.Source),
+		URL:        b.URL,
+		Volume:     b.Volume,
+	}
+}
+
+// configureLogging sets log levels and the log viewer's buffer size
+// Unset or invalid values (the config was validated) leave the defaults
+func configureLogging(logs *logging.Log, config Config) {
+	level, err := logging.ParseLevel(config.LogLevel)
+	if err != nil {
+		level = slog.LevelInfo
+	}
+	overrides, _ := logging.ParseOverrides(config.LogSubsystems)
+	logs.Levels.Replace(level, overrides)
+	if config.LogBuffer > 0 {
+		logs.Buffer.Resize(config.LogBuffer)
+	}
+}
+
+// webDisplayKeyFile holds the generated web display key when none is configured
+const webDisplayKeyFile = "display.key"
+
+// NewApp creates and initializes the application
+func NewApp(config Config) (*App, error) {
+	switch config.PlayerBackend {
+	case "web":
+		key := config.WebDisplayKey
+		if key == "" {
+			var err error
+			if key, err = webdisplay.LoadOrCreateKey(filepath.Join(config.DataDir, webDisplayKeyFile)); err != nil {
+				return nil, fmt.Errorf("failed to create web display key: %w", err)
+			}
+		}
+		display := webdisplay.New(key)
+		app, err := newAppWithPlayer(config, display)
+		if err != nil {
+			return nil, err
+		}
+		app.webDisplay = display
+		return app, nil
+	case "", "mpv":
+		return newAppWithPlayer(config, mpv.NewController(config.VideoPlayer))
+	default:
+		return nil, fmt.Errorf("unknown player backend %q (use mpv or web)", config.PlayerBackend)
+	}
+}
+
+// newAppWithPlayer creates the application around a given player backend
+func newAppWithPlayer(config Config, player mpv.Player) (*App, error) {
+	// Initialize session manager
+	sessionDB := filepath.Join(config.DataDir, "sessions.db")
+	sessions, err := session.NewManager(sessionDB)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize queue manager
+	queueDB := filepath.Join(config.DataDir, "queue.db")
+	queueMgr, err := queue.NewManager(queueDB)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize WebSocket hub (extra rooms add themselves to it)
+	hub := websocket.NewHub().Room(websocket.DefaultRoom)
+
+	// Configure display settings
+	displaySettings := mpv.DisplaySettings{
+		TargetDisplay:  config.TargetDisplay,
+		ScreenIndex:    -1, // Will be resolved from display name
+		AutoFullscreen: config.AutoFullscreen,
+	}
+
+	// If a target display is specified, try to find its screen index
+	if config.TargetDisplay != "" {
+		displays := getConnectedDisplays()
+		for i, d := range displays {
+			if d.Name == config.TargetDisplay {
+				displaySettings.ScreenIndex = i
+				log.Printf("Resolved display '%s' to screen index %d", config.TargetDisplay, i)
+				break
+			}
+		}
+		if displaySettings.ScreenIndex < 0 {
+			log.Printf("Warning: Target display '%s' not found, using auto", config.TargetDisplay)
+		}
+	}
+
+	player.SetDisplaySettings(displaySettings)
+
+	// Extra output screens follow the primary player
+	outputs := mpv.NewOutputs(player, config.VideoPlayer)
+
+	// Initialize admin manager
+	adminMgr := admin.NewManager(config.AdminPIN)
+
+	// Set up admin auth callback to mark sessions as admin
+	adminMgr.SetOnAdminAuth(func(martynKey string) {
+		// Update in database
+		if err := sessions.SetAdmin(martynKey, true); err != nil {
+			log.Printf("Failed to set admin for %s: %v", martynKey[:8], err)
+			return
+		}
+		log.Printf("Marked session %s as admin in database", martynKey[:8])
+
+		// Also update live WebSocket session if connected
+		if client := hub.FindClientByMartynKey(martynKey); client != nil {
+			if sess := client.GetSession(); sess != nil {
+				sess.IsAdmin = true
+				log.Printf("Updated live session %s as admin", martynKey[:8])
+			}
+		}
+	})
+
+	// Initialize library manager
+	libraryDB := filepath.Join(config.DataDir, "library.db")
+	libraryMgr, err := library.NewManager(libraryDB)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize playlist manager
+	playlistDB := filepath.Join(config.DataDir, "playlists.db")
+	playlistMgr, err := playlist.NewManager(playlistDB)
+	if err != nil {
+		return nil, err
+	}
+
+	// Holding screen themes: the built-ins plus any uploaded to DataDir/themes
+	holdingThemes, err := holdingscreen.NewStore(filepath.Join(config.DataDir, "themes"))
+	if err != nil {
+		return nil, err
+	}
+	holdingScreenGen := newHoldingScreen(config, holdingThemes)
+
+	// Without certificate files of its own, TLS comes from the built-in local CA
+	var authority *certs.Authority
+	if config.LocalCA && !hasCertFiles(config) {
+		authority, err = certs.NewAuthority(filepath.Join(config.DataDir, "ca"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.LoudnessTargetLUFS == 0 {
+		config.LoudnessTargetLUFS = defaultLoudnessTarget
+	}
+
+	app := &App{
+		config:         config,
+		mpv:            player,
+		outputs:        outputs,
+		hub:            hub,
+		sessions:       sessions,
+		queue:          queueMgr,
+		admin:          adminMgr,
+		library:        libraryMgr,
+		playlists:      playlistMgr,
+		holdingScreen:  holdingScreenGen,
+		holdingThemes:  holdingThemes,
+		certs:          authority,
+		stopped:        make(chan struct{}),
+		logs:           logging.Default,
+		loudnessJob:    loudness.NewJob(libraryMgr, loudness.NewAnalyzer(config.LoudnessDecoder)),
+		holdingMessage: config.HoldingMessage,
+		countdownTick:  time.Second,
+		transitions:    transition.NewScheduler(transition.SystemClock{}, player, transitionSettings(config)),
+		roomPlayer:     newRoomPlayer,
+	}
+
+	// Start mDNS server if hostname is configured
+	if config.MDNSHostname != "" {
+		portNum, _ := strconv.Atoi(config.Port)
+		mdnsServer, err := zeroconf.Register(
+			config.MDNSHostname,       // Instance name
+			"_https._tcp",             // Service type
+			"local.",                  // Domain
+			portNum,                   // Port
+			[]string{"txtv=0", "lo=1", "path=/"},  // TXT records
+			nil,                       // Interfaces (nil = all)
+		)
+		if err != nil {
+			log.Printf("Warning: Failed to start mDNS server: %v", err)
+		} else {
+			app.mdnsServer = mdnsServer
+			log.Printf("mDNS: Advertising as %s.local:%d", config.MDNSHostname, portNum)
+		}
+	}
+
+	// Cover every address we can be reached on before the server starts
+	app.refreshCertificate()
+
+	if config.MicEffectsEnabled {
+		app.mic = mpv.NewMicInput(config.VideoPlayer, config.MicDevice)
+		app.micLevel = app.mic
+	}
+
+	// Initialize performance recorder
+	if config.RecordingEnabled {
+		recorder, err := recording.NewManager(filepath.Join(config.DataDir, "recordings.db"), recording.Options{
+			Dir:       filepath.Join(config.DataDir, "recordings"),
+			Command:   config.RecordingCommand,
+			Extension: config.RecordingExtension,
+			MaxBytes:  int64(config.RecordingMaxMB * 1024 * 1024),
+			MaxAge:    time.Duration(config.RecordingRetentionDays * 24 * float64(time.Hour)),
+			LinkTTL:   time.Duration(config.RecordingLinkHours * float64(time.Hour)),
+		})
+		if err != nil {
+			log.Printf("Warning: Failed to initialize recorder: %v", err)
+		} else {
+			app.recorder = recorder
+		}
+	}
+
+	app.bgmSettings = config.BGM
+	if app.bgmSettings.SourceType == "" {
+		app.bgmSettings.SourceType = models.BGMSourceYouTube
+	}
+	if info, err := os.Stat(config.ConfigPath); err == nil {
+		app.configModTime = info.ModTime()
+	}
+
+	// Apply feature settings
+	queueMgr.SetFairRotation(config.FairRotationEnabled)
+
+	app.restart = app.restartProcess
+
+	// Wire up handlers
+	app.setupHandlers()
+	app.metrics = newAppMetrics(app)
+
+	for _, r := range config.Rooms {
+		if err := app.addRoom(r); err != nil {
+			return nil, fmt.Errorf("room %s: %w", r.ID, err)
+		}
+	}
+
+	return app, nil
+}
+
+// newHoldingScreen creates a holding screen generator showing the configured theme
+// Returns nil if it can't be created - the holding screen isn't critical
+func newHoldingScreen(config Config, themes *holdingscreen.Store) *holdingscreen.Generator {
+	holdingScreenTempDir := filepath.Join(config.DataDir, "temp")
+	avatarAPIURL := fmt.Sprintf("https://localhost:%s", config.Port)
+	holdingScreenGen, err := holdingscreen.NewGenerator(holdingScreenTempDir, avatarAPIURL)
+	if err != nil {
+		log.Printf("Warning: Failed to initialize holding screen: %v", err)
+		return nil
+	}
+
+	themeID := config.HoldingTheme
+	if themeID == "" {
+		themeID = holdingscreen.DefaultThemeID
+	}
+	if theme := themes.Get(themeID); theme != nil {
+		holdingScreenGen.SetTheme(theme)
+	} else {
+		log.Printf("Warning: Holding screen theme %q not found, using %s", themeID, holdingscreen.DefaultThemeID)
+	}
+	return holdingScreenGen
+}
+
+// transitionSettings converts the configured transition times
+func transitionSettings(config Config) transition.Settings {
+	return transition.Settings{
+		BGMCrossfade:  seconds(config.BGMCrossfade),
+		SongFadeOut:   seconds(config.SongFadeOut),
+		HoldingFadeIn: seconds(config.HoldingFadeIn),
+		TrimSilence:   config.TrimSilence,
+	}
+}
+
+// appMetrics are the Prometheus metrics served on /metrics
+type appMetrics struct {
+	registry         *metrics.Registry
+	wsMessages       *metrics.Counter   // By direction and message type
+	wsMessageBytes   *metrics.Histogram // By direction
+	broadcastClients *metrics.Histogram // Clients reached, by message type
+	broadcastLatency *metrics.Histogram // By message type
+	songsPlayed      *metrics.Counter
+	songLoadFailures *metrics.Counter
+	mpvReconnects    *metrics.Counter
+	mpvRestarts      *metrics.Counter
+	searchLatency    *metrics.Histogram // By source (library, youtube)
+	queryDuration    *metrics.Histogram // By database and operation
+}
+
+// newAppMetrics registers the metrics and hooks them into the hub and managers
+func newAppMetrics(app *App) *appMetrics {
+	r := metrics.NewRegistry()
+	m := &appMetrics{
+		registry:         r,
+		wsMessages:       r.NewCounter("songmartyn_websocket_messages_total", "WebSocket messages by direction and type.", "direction", "type"),
+		wsMessageBytes:   r.NewHistogram("songmartyn_websocket_message_bytes", "WebSocket message sizes in bytes.", metrics.ByteBuckets, "direction"),
+		broadcastClients: r.NewHistogram("songmartyn_websocket_broadcast_clients", "Clients reached by each broadcast.", []float64{1, 2, 5, 10, 20, 50, 100}, "type"),
+		broadcastLatency: r.NewHistogram("songmartyn_websocket_broadcast_seconds", "Time to queue a broadcast for every client.", metrics.DefBuckets, "type"),
+		songsPlayed:      r.NewCounter("songmartyn_songs_played_total", "Songs played to the end."),
+		songLoadFailures: r.NewCounter("songmartyn_song_load_failures_total", "Songs skipped because they failed to load."),
+		mpvReconnects:    r.NewCounter("songmartyn_mpv_reconnects_total", "Player starts that reconnected to an mpv already running."),
+		mpvRestarts:      r.NewCounter("songmartyn_mpv_restarts_total", "Player restarts, automatic or requested."),
+		searchLatency:    r.NewHistogram("songmartyn_search_duration_seconds", "Song search latency by source.", metrics.DefBuckets, "source"),
+		queryDuration:    r.NewHistogram("songmartyn_sqlite_query_duration_seconds", "SQLite operation latency by database and operation.", metrics.DefBuckets, "db", "op"),
+	}
+	r.NewGaugeFunc("songmartyn_websocket_clients", "Open WebSocket connections.", func() float64 {
+		return float64(app.hub.ConnectionCount())
+	})
+	r.NewGaugeFunc("songmartyn_queue_length", "Songs in the queue from the one playing onward.", func() float64 {
+		state := app.queue.GetState()
+		return float64(max(len(state.Songs)-state.Position, 0))
+	})
+	r.NewGauge("songmartyn_start_time_seconds", "When the server started, in Unix seconds.").Set(float64(time.Now().Unix()))
+
+	app.hub.SetObserver(websocket.Observer{
+		OnReceive: func(msgType websocket.MessageType, bytes int) {
+			m.wsMessages.Inc("in", string(msgType))
+			m.wsMessageBytes.Observe(float64(bytes), "in")
+		},
+		OnSend: func(msgType websocket.MessageType, bytes int) {
+			m.wsMessages.Inc("out", string(msgType))
+			m.wsMessageBytes.Observe(float64(bytes), "out")
+		},
+		OnBroadcast: func(msgType websocket.MessageType, clients int, took time.Duration) {
+			m.broadcastClients.Observe(float64(clients), string(msgType))
+			m.broadcastLatency.ObserveDuration(took, string(msgType))
+		},
+	})
+	observeDB := func(db string) metrics.QueryObserver {
+		return func(op string, took time.Duration) {
+			m.queryDuration.ObserveDuration(took, db, op)
+		}
+	}
+	app.sessions.SetQueryObserver(observeDB("sessions"))
+	app.queue.SetQueryObserver(observeDB("queue"))
+	app.library.SetQueryObserver(observeDB("library"))
+	app.playlists.SetQueryObserver(observeDB("playlists"))
+	return m
+}
+
+// timeSearch starts timing a search; call the result when the results are in
+func (app *App) timeSearch(source string) func() {
+	start := time.Now()
+	return func() { app.metrics.searchLatency.ObserveDuration(time.Since(start), source) }
+}
+
+// startPlayer starts mpv, counting reconnects to an instance that was already running
+func (app *App) startPlayer() error {
+	if err := app.mpv.Start(); err != nil {
+		return err
+	}
+	if app.mpv.Adopted() {
+		app.metrics.mpvReconnects.Inc()
+	}
+	return nil
+}
+
+// restartPlayer restarts mpv, counting each attempt
+func (app *App) restartPlayer() error {
+	app.metrics.mpvRestarts.Inc()
+	return app.mpv.Restart()
+}
+
+// setupHandlers configures WebSocket message handlers
+func (app *App) setupHandlers() {
+	app.hub.SetHandlers(websocket.HubHandlers{
+		OnHandshake: func(client *websocket.Client, payload websocket.HandshakePayload) (*models.Session, *models.RoomState) {
+			// Check if user is blocked before allowing connection
+			if payload.MartynKey != "" {
+				if blocked, reason := app.sessions.IsBlocked(payload.MartynKey); blocked {
+					wsLogger.Warn("Blocked user attempted to connect", "key", payload.MartynKey[:8], "reason", reason)
+					// Send kicked message and reject connection
+					app.hub.SendTo(client, websocket.MsgKicked, map[string]string{
+						"reason": "You are blocked: " + reason,
+					})
+					return nil, nil
+				}
+			}
+
+			// The Martyn Handshake - restore or create session
+			sess := app.sessions.GetOrCreate(payload.MartynKey, payload.DisplayName)
+
+			// Update device info
+			userAgent := client.GetUserAgent()
+			deviceName := device.GetFriendlyName(userAgent)
+			app.sessions.UpdateDeviceInfo(sess.MartynKey, client.GetIPAddress(), userAgent, deviceName)
+
+			// Mark as online
+			sess.IsOnline = true
+			sess.IPAddress = client.GetIPAddress()
+			sess.UserAgent = userAgent
+			sess.DeviceName = deviceName
+
+			roomState := app.getRoomState()
+			wsLogger.Info("Session restored/created", "name", sess.DisplayName, "key", sess.MartynKey[:8],
+				"ip", sess.IPAddress, "device", deviceName)
+
+			// Broadcast updated client list to admins
+			app.broadcastClientList()
+
+			return sess, &roomState
+		},
+
+		OnSearch: func(client *websocket.Client, query string) {
+			// TODO: Implement YouTube search via yt-dlp
+			wsLogger.Debug("Search request", "query", query)
+			if sess := client.GetSession(); sess != nil {
+				app.sessions.AddSearchHistory(sess.MartynKey, query)
+			}
+		},
+
+		OnQueueAdd: func(client *websocket.Client, songID string, vocalAssist models.VocalAssistLevel) {
+			// Fetch song from library
+			libSong, err := app.library.GetSong(songID)
+			if err != nil {
+				queueLogger.Warn("Failed to get song", "song", songID, "err", err)
+				app.hub.SendTo(client, websocket.MsgError, map[string]string{"error": "Song not found"})
+				return
+			}
+
+			// Use vocal assist from request, or default to OFF
+			if vocalAssist == "" {
+				vocalAssist = models.VocalOff
+			}
+
+			// Convert LibrarySong to queue Song
+			song := queueSongFromLibrary(libSong, vocalAssist, client.GetSession().MartynKey)
+			song.VocalGain = client.GetSession().VocalGain
+
+			// Add to queue
+			if err := app.addToQueue(song); err != nil {
+				queueLogger.Error("Failed to add song to queue", "err", err)
+				app.hub.SendTo(client, websocket.MsgError, map[string]string{"error": "Failed to add to queue"})
+				return
+			}
+
+			queueLogger.Info("Song added to queue", "title", song.Title, "by", client.GetSession().DisplayName)
+		},
+
+		OnQueueRemove: func(client *websocket.Client, songID string) {
+			// Get the current singer's key before removal (for countdown logic)
+			currentSingerKey := ""
+			if current := app.queue.Current(); current != nil {
+				currentSingerKey = current.AddedBy
+			}
+
+			currentRemoved, _ := app.queue.Remove(songID)
+			queueLogger.Info("Song removed from queue", "by", client.GetSession().DisplayName)
+
+			if currentRemoved {
+				// Stop current playback
+				app.stopPlayback()
+
+				// Check if there's a next song to play
+				if next := app.queue.Current(); next != nil {
+					queueLogger.Info("Current song removed - starting countdown for next song")
+					app.startCountdown(currentSingerKey)
+				} else {
+					queueLogger.Info("Current song removed - queue empty")
+					// Start BGM if enabled, otherwise show holding screen
+					if app.bgmSettings.Enabled && app.bgmSettings.URL != "" {
+						app.startBGM()
+					} else {
+						app.showHoldingScreen()
+					}
+				}
+			}
+
+			app.broadcastState()
+		},
+
+		OnQueueMove: func(client *websocket.Client, from int, to int) {
+			if err := app.queue.Move(from, to); err != nil {
+				queueLogger.Error("Failed to move song in queue", "err", err)
+				return
+			}
+			queueLogger.Info("Queue reordered", "by", client.GetSession().DisplayName, "from", from, "to", to)
+			app.broadcastState()
+		},
+
+		OnQueueClear: func(client *websocket.Client) {
+			if err := app.clearQueue(); err != nil {
+				queueLogger.Error("Failed to clear queue", "err", err)
+				return
+			}
+			queueLogger.Info("Queue cleared", "by", client.GetSession().DisplayName)
+		},
+
+		OnPlay: func(client *websocket.Client) {
+			if err := app.mpv.Play(); err != nil {
+				mpvLogger.Error("Failed to play", "err", err)
+			}
+			app.outputs.Play()
+			app.broadcastState()
+		},
+
+		OnPause: func(client *websocket.Client) {
+			if err := app.mpv.Pause(); err != nil {
+				mpvLogger.Error("Failed to pause", "err", err)
+			}
+			app.outputs.Pause()
+			app.broadcastState()
+		},
+
+		OnSkip: func(client *websocket.Client) {
+			queueLogger.Info("Skip requested", "by", client.GetSession().DisplayName)
+			// Get current singer before advancing queue
+			currentSingerKey := ""
+			if current := app.queue.Current(); current != nil {
+				currentSingerKey = current.AddedBy
+			}
+			app.mpv.Stop()
+			app.outputs.StopPlayback()
+			app.finishRecording(0) // Skipped songs aren't in the history
+			app.finishReactions(0)
+			if next := app.queue.Next(); next != nil {
+				// Use countdown system for consistent transitions
+				app.startCountdown(currentSingerKey)
+			} else {
+				queueLogger.Info("Skip: no more songs in queue")
+				app.showHoldingScreen()
+			}
+			app.broadcastState()
+		},
+
+		OnSeek: func(client *websocket.Client, position float64) {
+			if err := app.mpv.Seek(position); err != nil {
+				mpvLogger.Error("Failed to seek", "position", position, "err", err)
+			}
+			app.outputs.Seek(position)
+		},
+
+		OnVocalAssist: func(client *websocket.Client, level models.VocalAssistLevel) {
+			sess := client.GetSession()
+			if sess == nil {
+				return
+			}
+			if !models.ValidVocalAssist(level) {
+				app.hub.SendTo(client, websocket.MsgError, map[string]string{"error": "Unknown vocal assist level: " + string(level)})
+				return
+			}
+			if level == models.VocalAuto && app.micLevel == nil {
+				app.hub.SendTo(client, websocket.MsgError, map[string]string{"error": "Automatic vocal assist needs the microphone input"})
+				return
+			}
+			app.sessions.UpdateVocalAssist(sess.MartynKey, level)
+			// Update current playback if this is the current singer
+			app.updateVocalMix(level, sess.VocalGain)
+		},
+
+		OnVocalGain: func(client *websocket.Client, gain float64) {
+			sess := client.GetSession()
+			if sess == nil {
+				return
+			}
+			app.sessions.UpdateVocalGain(sess.MartynKey, gain)
+			if updated := app.sessions.Get(sess.MartynKey); updated != nil {
+				app.updateVocalMix(updated.VocalAssist, updated.VocalGain)
+			}
+		},
+
+		OnMicPreset: func(client *websocket.Client, preset models.MicPreset) {
+			sess := client.GetSession()
+			if sess == nil {
+				return
+			}
+			if _, err := mpv.MicEffectsFor(preset); err != nil {
+				app.hub.SendTo(client, websocket.MsgError, map[string]string{"error": err.Error()})
+				return
+			}
+			app.sessions.UpdateMicPreset(sess.MartynKey, preset)
+
+			// Switch live if this singer is performing now
+			if current := app.queue.Current(); current != nil && !app.idle && current.AddedBy == sess.MartynKey {
+				app.applyMicPreset(sess.MartynKey)
+			}
+		},
+
+		OnSetRecording: func(client *websocket.Client, enabled bool) {
+			sess := client.GetSession()
+			if sess == nil {
+				return
+			}
+			if app.recorder == nil {
+				app.hub.SendTo(client, websocket.MsgError, map[string]string{"error": "Recording is not enabled"})
+				return
+			}
+			app.sessions.UpdateRecordPerformances(sess.MartynKey, enabled)
+			app.broadcastState()
+		},
+
+		OnReaction: app.handleReaction,
+
+		OnGetRecordings: func(client *websocket.Client) {
+			sess := client.GetSession()
+			if sess == nil || app.recorder == nil {
+				return
+			}
+			recs, err := app.recorder.ForSinger(sess.MartynKey)
+			if err != nil {
+				app.hub.SendTo(client, websocket.MsgError, map[string]string{"error": err.Error()})
+				return
+			}
+			views := make([]recordingView, 0, len(recs))
+			for _, rec := range recs {
+				views = append(views, app.recordingWithLink(rec))
+			}
+			app.hub.SendTo(client, websocket.MsgRecordings, views)
+		},
+
+		OnVolume: func(client *websocket.Client, volume float64) {
+			if err := app.mpv.SetVolume(volume); err != nil {
+				mpvLogger.Error("Failed to set volume", "volume", volume, "err", err)
+			}
+		},
+
+		OnKeyChange: func(client *websocket.Client, semitones int) {
+			// Check if pitch control is enabled
+			if !app.config.PitchControlEnabled {
+				wsLogger.Debug("Pitch control disabled - ignoring key change request")
+				return
+			}
+			// Clamp to valid range
+			if semitones < -12 {
+				semitones = -12
+			}
+			if semitones > 12 {
+				semitones = 12
+			}
+			if err := app.mpv.SetPitch(semitones); err != nil {
+				mpvLogger.Error("Failed to set key change", "semitones", semitones, "err", err)
+			} else {
+				mpvLogger.Info("Key changed", "semitones", semitones, "by", client.GetSession().DisplayName)
+			}
+		},
+
+		OnTempoChange: func(client *websocket.Client, speed float64) {
+			// Check if tempo control is enabled
+			if !app.config.TempoControlEnabled {
+				wsLogger.Debug("Tempo control disabled - ignoring tempo change request")
+				return
+			}
+			// Clamp to valid range (0.5 to 2.0)
+			if speed < 0.5 {
+				speed = 0.5
+			}
+			if speed > 2.0 {
+				speed = 2.0
+			}
+			app.outputs.SetTempo(speed)
+			if err := app.mpv.SetTempo(speed); err != nil {
+				mpvLogger.Error("Failed to set tempo", "speed", speed, "err", err)
+			} else {
+				mpvLogger.Info("Tempo changed", "speed", speed, "by", client.GetSession().DisplayName)
+			}
+		},
+
+		OnSetDisplayName: func(client *websocket.Client, name string, avatarID string, avatarConfig *models.AvatarConfig) {
+			if sess := client.GetSession(); sess != nil {
+				// Only update name if provided (non-empty)
+				if name != "" {
+					sess.DisplayName = name
+				}
+				sess.AvatarID = avatarID
+				sess.AvatarConfig = avatarConfig
+				app.sessions.UpdateProfile(sess.MartynKey, sess.DisplayName, avatarID)
+				app.sessions.UpdateAvatarConfig(sess.MartynKey, avatarConfig)
+				app.broadcastState()
+				app.broadcastClientList()
+			}
+		},
+
+		OnAutoplay: func(client *websocket.Client, enabled bool) {
+			app.queue.SetAutoplay(enabled)
+			queueLogger.Info("Autoplay changed", "enabled", enabled, "by", client.GetSession().DisplayName)
+			app.broadcastState()
+		},
+
+		OnQueueShuffle: func(client *websocket.Client) {
+			app.queue.Shuffle()
+			queueLogger.Info("Queue shuffled", "by", client.GetSession().DisplayName)
+			// Refresh holding screen in case next song changed
+			app.updateHoldingScreenIfIdle()
+			app.broadcastState()
+		},
+
+		OnQueueRequeue: func(client *websocket.Client, songID string, martynKey string) {
+			if err := app.queue.Requeue(songID, martynKey); err != nil {
+				queueLogger.Error("Failed to requeue song", "song", songID, "err", err)
+				return
+			}
+			queueLogger.Info("Song requeued",
+				"song", songID[:min(8, len(songID))],
+				"by", client.GetSession().DisplayName,
+				"for", martynKey[:min(8, len(martynKey))])
+			// Update holding screen if idle (shows next up info)
+			app.updateHoldingScreenIfIdle()
+			app.broadcastState()
+		},
+
+		OnSetAFK: func(client *websocket.Client, isAFK bool) {
+			sess := client.GetSession()
+			if sess == nil {
+				return
+			}
+
+			// Update session AFK status
+			sess.IsAFK = isAFK
+			app.sessions.SetAFK(sess.MartynKey, isAFK)
+
+			// If going AFK, bump their songs to end of queue
+			if isAFK {
+				app.queue.BumpUserToEnd(sess.MartynKey)
+				queueLogger.Info("Singer went AFK - songs bumped to end", "name", sess.DisplayName)
+			} else {
+				queueLogger.Info("Singer is back from AFK", "name", sess.DisplayName)
+			}
+
+			app.broadcastState()
+			app.broadcastClientList()
+		},
+
+		OnAddFavorite: func(client *websocket.Client, songID string) {
+			sess := client.GetSession()
+			if sess == nil {
+				return
+			}
+			if err := app.sessions.AddFavorite(sess.MartynKey, songID); err != nil {
+				wsLogger.Error("Failed to add favorite", "err", err)
+				return
+			}
+			// Update in-memory session
+			sess.Favorites = app.sessions.GetFavorites(sess.MartynKey)
+			wsLogger.Info("Added favorite", "name", sess.DisplayName, "song", songID)
+			// Broadcast updated state so client gets updated favorites list
+			app.broadcastState()
+		},
+
+		OnRemoveFavorite: func(client *websocket.Client, songID string) {
+			sess := client.GetSession()
+			if sess == nil {
+				return
+			}
+			if err := app.sessions.RemoveFavorite(sess.MartynKey, songID); err != nil {
+				wsLogger.Error("Failed to remove favorite", "err", err)
+				return
+			}
+			// Update in-memory session
+			sess.Favorites = app.sessions.GetFavorites(sess.MartynKey)
+			wsLogger.Info("Removed favorite", "name", sess.DisplayName, "song", songID)
+			// Broadcast updated state so client gets updated favorites list
+			app.broadcastState()
+		},
+
+		OnGetRecommendations: func(client *websocket.Client) {
+			sess := client.GetSession()
+			if sess == nil {
+				return
+			}
+			recs, err := app.getRecommendations(sess.MartynKey, recommendationLimit)
+			if err != nil {
+				wsLogger.Error("Failed to get recommendations", "key", sess.MartynKey[:8], "err", err)
+				app.hub.SendTo(client, websocket.MsgError, map[string]string{"error": "Could not load recommendations"})
+				return
+			}
+			app.hub.SendTo(client, websocket.MsgRecommendations, recs)
+		},
+
+		OnPlaylist: app.handlePlaylistAction,
+
+		OnAvatarGallery: app.handleAvatarGallery,
+
+		OnAdminSetAdmin: func(client *websocket.Client, martynKey string, isAdmin bool) error {
+			if err := app.sessions.SetAdmin(martynKey, isAdmin); err != nil {
+				return err
+			}
+			// Update the target client's session if online
+			if targetClient := app.hub.FindClientByMartynKey(martynKey); targetClient != nil {
+				if targetSess := targetClient.GetSession(); targetSess != nil {
+					targetSess.IsAdmin = isAdmin
+				}
+			}
+			app.broadcastClientList()
+			wsLogger.Info("Admin changed admin status", "admin", client.GetSession().MartynKey[:8], "key", martynKey[:8], "is_admin", isAdmin)
+			return nil
+		},
+
+		OnAdminKick: func(client *websocket.Client, martynKey string, reason string) error {
+			targetClient := app.hub.FindClientByMartynKey(martynKey)
+			if targetClient == nil {
+				return fmt.Errorf("client not found")
+			}
+
+			// Remove all of their songs from queue
+			currentRemoved, _ := app.queue.RemoveByUser(martynKey)
+			if currentRemoved {
+				// Stop current playback and skip to next song or show holding screen
+				if err := app.stopPlayback(); err != nil {
+					mpvLogger.Warn("Failed to stop playback", "err", err)
+				}
+				if next := app.queue.Current(); next != nil {
+					app.playCurrentSong()
+				} else {
+					app.showHoldingScreen()
+				}
+			}
+
+			app.hub.KickClient(targetClient, reason)
+			wsLogger.Info("Admin kicked user", "admin", client.GetSession().MartynKey[:8], "key", martynKey[:8], "reason", reason)
+			app.broadcastState()
+			return nil
+		},
+
+		OnAdminBlock: func(client *websocket.Client, martynKey string, durationMinutes int, reason string) error {
+			if err := app.blockUser(martynKey, time.Duration(durationMinutes)*time.Minute, reason); err != nil {
+				return err
+			}
+			if durationMinutes == 0 {
+				wsLogger.Info("Admin permanently blocked user", "admin", client.GetSession().MartynKey[:8], "key", martynKey[:8], "reason", reason)
+			} else {
+				wsLogger.Info("Admin blocked user", "admin", client.GetSession().MartynKey[:8], "key", martynKey[:8], "minutes", durationMinutes, "reason", reason)
+			}
+			return nil
+		},
+
+		OnAdminUnblock: func(client *websocket.Client, martynKey string) error {
+			if err := app.unblockUser(martynKey); err != nil {
+				return err
+			}
+			wsLogger.Info("Admin unblocked user", "admin", client.GetSession().MartynKey[:8], "key", martynKey[:8])
+			return nil
+		},
+
+		OnAdminSetAFK: func(client *websocket.Client, martynKey string, isAFK bool) error {
+			// Update session AFK status
+			if err := app.sessions.SetAFK(martynKey, isAFK); err != nil {
+				return err
+			}
+
+			// Update live session if online
+			if targetClient := app.hub.FindClientByMartynKey(martynKey); targetClient != nil {
+				if targetSess := targetClient.GetSession(); targetSess != nil {
+					targetSess.IsAFK = isAFK
+				}
+			}
+
+			// If going AFK, bump their songs to end of queue
+			if isAFK {
+				app.queue.BumpUserToEnd(martynKey)
+				queueLogger.Info("Admin set singer AFK - songs bumped to end", "admin", client.GetSession().MartynKey[:8], "key", martynKey[:8])
+			} else {
+				queueLogger.Info("Admin set singer back from AFK", "admin", client.GetSession().MartynKey[:8], "key", martynKey[:8])
+			}
+
+			app.broadcastState()
+			app.broadcastClientList()
+			return nil
+		},
+
+		OnAdminPlayNext: func(client *websocket.Client) error {
+			queueLogger.Info("Admin triggered play", "admin", client.GetSession().MartynKey[:8])
+			// Start a countdown before playing (gives singer time to get ready)
+			app.startPlayCountdown(10) // 10 second countdown
+			return nil
+		},
+
+		OnAdminStartNow: func(client *websocket.Client) error {
+			queueLogger.Info("Admin triggered immediate start", "admin", client.GetSession().MartynKey[:8])
+			// Skip countdown and play immediately
+			app.startPlayCountdown(0) // 0 = play immediately
+			return nil
+		},
+
+		OnAdminStop: func(client *websocket.Client) error {
+			mpvLogger.Info("Admin stopped playback", "admin", client.GetSession().MartynKey[:8])
+			// Stop any active countdown
+			app.stopCountdown()
+			// Stop current playback but keep MPV running
+			if err := app.stopPlayback(); err != nil {
+				mpvLogger.Warn("Failed to stop playback", "err", err)
+			}
+			// Skip current song (moves it to history)
+			app.queue.Skip()
+			// Brief delay to ensure MPV is ready for new content
+			time.Sleep(100 * time.Millisecond)
+			// Start BGM if enabled, otherwise show holding screen
+			if app.bgmSettings.Enabled && app.bgmSettings.URL != "" {
+				app.startBGM()
+			} else {
+				app.showHoldingScreen()
+			}
+			app.broadcastState()
+			return nil
+		},
+
+		OnAdminSetName: func(client *websocket.Client, martynKey string, displayName string) error {
+			wsLogger.Info("Admin changed display name",
+				"admin", client.GetSession().MartynKey[:8], "key", martynKey[:8], "name", displayName)
+			if err := app.sessions.AdminSetDisplayName(martynKey, displayName); err != nil {
+				return err
+			}
+			// Update live session if online
+			if targetClient := app.hub.FindClientByMartynKey(martynKey); targetClient != nil {
+				if targetSess := targetClient.GetSession(); targetSess != nil {
+					targetSess.DisplayName = displayName
+				}
+			}
+			app.broadcastState()
+			app.broadcastClientList()
+			return nil
+		},
+
+		OnAdminSetNameLock: func(client *websocket.Client, martynKey string, locked bool) error {
+			wsLogger.Info("Admin changed name lock",
+				"admin", client.GetSession().MartynKey[:8], "key", martynKey[:8], "locked", locked)
+			if err := app.sessions.SetNameLocked(martynKey, locked); err != nil {
+				return err
+			}
+			// Update live session if online
+			if targetClient := app.hub.FindClientByMartynKey(martynKey); targetClient != nil {
+				if targetSess := targetClient.GetSession(); targetSess != nil {
+					targetSess.NameLocked = locked
+				}
+			}
+			app.broadcastState()
+			app.broadcastClientList()
+			return nil
+		},
+
+		OnAdminToggleBGM: func(client *websocket.Client) error {
+			// Can only toggle BGM when idle (no song playing) and no countdown
+			if !app.idle {
+				return fmt.Errorf("cannot toggle BGM while a song is playing")
+			}
+			if app.countdown.Active {
+				return fmt.Errorf("cannot toggle BGM during countdown")
+			}
+
+			if app.bgmActive {
+				bgmLogger.Info("Admin stopping BGM", "admin", client.GetSession().MartynKey[:8])
+				app.stopBGM()
+			} else {
+				if !app.bgmSettings.Enabled || app.bgmSettings.URL == "" {
+					return fmt.Errorf("BGM is not configured - set up in Settings")
+				}
+				bgmLogger.Info("Admin starting BGM", "admin", client.GetSession().MartynKey[:8])
+				app.startBGM()
+			}
+			app.broadcastState()
+			return nil
+		},
+
+		OnAdminSetMessage: func(client *websocket.Client, message string) error {
+			app.holdingMessageMu.Lock()
+			app.holdingMessage = message
+			app.holdingMessageMu.Unlock()
+			app.config.HoldingMessage = message
+			bgmLogger.Info("Admin set holding screen message", "admin", client.GetSession().DisplayName, "message", message)
+
+			if err := app.saveSettings(func(f *configfile.File) { f.Holding.Message = message }); err != nil {
+				bgmLogger.Warn("Failed to save holding message", "err", err)
+			}
+
+			// Refresh the holding screen to show the new message
+			app.showHoldingScreen()
+			return nil
+		},
+
+		OnClientDisconnect: func(client *websocket.Client) {
+			if sess := client.GetSession(); sess != nil {
+				app.sessions.SetOnline(sess.MartynKey, false)
+				app.broadcastClientList()
+			}
+		},
+		IsRoomAdmin: app.isRoomAdmin,
+	})
+
+	// Queue change callback
+	app.queue.OnChange(func() {
+		app.broadcastState()
+		app.updateTicker()
+	})
+
+	// mpv track end callback
+	app.mpv.OnTrackEnd(func() {
+		mpvLogger.Info("Track ended - song finished playing")
+
+		// Get the current singer before advancing
+		currentSong := app.queue.Current()
+		currentSingerKey := ""
+		if currentSong != nil {
+			currentSingerKey = currentSong.AddedBy
+			queueLogger.Info("Song finished, moving to history", "title", currentSong.Title)
+
+			// Record the performance (feeds history, LastSungAt and recommendations)
+			// as queued, so songs from outside the library are kept too
+			historyID, err := app.library.RecordQueuedSongPlayed(*currentSong, currentSingerKey)
+			if err != nil {
+				queueLogger.Warn("Could not record song history", "song", currentSong.ID, "err", err)
+			}
+			app.metrics.songsPlayed.Inc()
+			app.finishRecording(historyID)
+			app.finishReactions(historyID)
+			go app.pushRecommendations()
+		} else {
+			app.finishRecording(0)
+			app.finishReactions(0)
+		}
+
+		// Always advance the queue position (moves current song to history)
+		// Use Skip() instead of Next() because Skip() advances even at the last song
+		nextSong := app.queue.Skip()
+
+		// Check if autoplay is enabled
+		if !app.queue.GetAutoplay() {
+			queueLogger.Info("Autoplay disabled - showing holding screen, waiting for Play button")
+			// Start BGM if enabled while waiting
+			if app.bgmSettings.Enabled && app.bgmSettings.URL != "" {
+				app.startBGM()
+			} else {
+				app.showHoldingScreen()
+			}
+			app.broadcastState()
+			return
+		}
+
+		// Autoplay is enabled - continue to next song or show holding screen
+		if nextSong != nil {
+			// Start the inter-song countdown
+			app.startCountdown(currentSingerKey)
+		} else {
+			// Start BGM if enabled
+			if app.bgmSettings.Enabled && app.bgmSettings.URL != "" {
+				app.startBGM()
+			} else {
+				queueLogger.Info("Queue empty - showing holding screen")
+				app.showHoldingScreen()
+			}
+			app.broadcastState()
+		}
+	})
+
+	// mpv state change callback
+	app.mpv.OnStateChange(func(state models.PlayerState) {
+		app.broadcastState()
+	})
+}
+
+// Recommendation push settings
+const (
+	recommendationLimit        = 10
+	recommendationPushInterval = 5 * time.Minute
+)
+
+// getRecommendations returns song suggestions for a singer, leaving out songs already queued
+func (app *App) getRecommendations(martynKey string, limit int) ([]library.Recommendation, error) {
+	queueState := app.queue.GetState()
+	exclude := make([]string, 0, len(queueState.Songs))
+	for _, song := range queueState.Songs {
+		exclude = append(exclude, song.ID)
+	}
+	return app.library.Recommend(martynKey, library.RecommendOptions{
+		Limit:      limit,
+		ExcludeIDs: exclude,
+	})
+}
+
+// pushRecommendations sends fresh suggestions to idle phones
+// A phone is idle when its singer has nothing waiting in the queue and isn't AFK
+func (app *App) pushRecommendations() {
+	queueState := app.queue.GetState()
+	waiting := make(map[string]bool)
+	for i, song := range queueState.Songs {
+		if i >= queueState.Position {
+			waiting[song.AddedBy] = true
+		}
+	}
+
+	for _, client := range app.hub.GetConnectedClients() {
+		if client.Room != app.hub.ID() || client.IsAFK || waiting[client.MartynKey] {
+			continue
+		}
+		recs, err := app.getRecommendations(client.MartynKey, recommendationLimit)
+		if err != nil || len(recs) == 0 {
+			continue
+		}
+		app.hub.SendToMartynKey(client.MartynKey, websocket.MsgRecommendations, recs)
+	}
+}
+
+// runRecommendationPush periodically pushes suggestions to idle phones
+func (app *App) runRecommendationPush() {
+	ticker := time.NewTicker(recommendationPushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-app.done:
+			return
+		case <-ticker.C:
+			app.pushRecommendations()
+		}
+	}
+}
+
+// positionTickInterval is how often delta-sync clients get a playback position tick
+const positionTickInterval = time.Second
+
+// runPositionTicks sends position ticks while a song is playing
+// Delta-sync clients don't get position in state deltas, so this keeps their progress bars moving
+func (app *App) runPositionTicks() {
+	ticker := time.NewTicker(positionTickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-app.done:
+			return
+		case <-ticker.C:
+		}
+		if app.idle || !app.mpv.IsRunning() {
+			continue
+		}
+		playerState, err := app.mpv.GetState()
+		if err != nil {
+			continue
+		}
+		app.hub.BroadcastPosition(playerState)
+	}
+}
+
+// queueSongFromLibrary converts a library song into a queue entry
+func queueSongFromLibrary(libSong *models.LibrarySong, vocalAssist models.VocalAssistLevel, addedBy string) models.Song {
+	return models.Song{
+		ID:           libSong.ID,
+		Title:        libSong.Title,
+		Artist:       libSong.Artist,
+		Duration:     libSong.Duration,
+		ThumbnailURL: libSong.ThumbnailURL,
+		VideoURL:     libSong.FilePath, // Use file path as video URL
+		VocalPath:    libSong.VocalPath,
+		InstrPath:    libSong.InstrPath,
+		CDGPath:      libSong.CDGPath,   // CDG graphics file
+		AudioPath:    libSong.AudioPath, // Audio for CDG
+		VocalAssist:  vocalAssist,
+		AddedBy:      addedBy,
+	}
+}
+
+// addToQueue queues a song, updating the holding screen's "Next Up" and starting
+// playback if it's the first song and autoplay is on
+func (app *App) addToQueue(song models.Song) error {
+	wasEmpty := app.queue.IsEmpty()
+	if err := app.queue.Add(song); err != nil {
+		return err
+	}
+
+	// Always update holding screen to show "Next Up" info
+	app.showHoldingScreen()
+
+	// Auto-start playback if this is the first song and autoplay is enabled
+	if wasEmpty && app.queue.GetAutoplay() {
+		queueLogger.Info("First song added to empty queue - starting playback in 2 seconds")
+		// Brief delay to show "Next Up" on holding screen before playing
+		go func() {
+			time.Sleep(2 * time.Second)
+			app.playCurrentSong()
+			app.broadcastState()
+		}()
+	}
+
+	app.broadcastState()
+	return nil
+}
+
+// clearQueue empties the queue, stopping playback for the holding screen
+func (app *App) clearQueue() error {
+	if err := app.queue.Clear(); err != nil {
+		return err
+	}
+	app.stopPlayback()
+	app.showHoldingScreen()
+	app.broadcastState()
+	return nil
+}
+
+// blockUser blocks a singer (duration 0 = permanently), drops their songs from the
+// queue and disconnects them
+func (app *App) blockUser(martynKey string, duration time.Duration, reason string) error {
+	if err := app.sessions.BlockUser(martynKey, duration, reason); err != nil {
+		return err
+	}
+
+	// Remove all of their songs from queue
+	currentRemoved, _ := app.queue.RemoveByUser(martynKey)
+	if currentRemoved {
+		// Stop current playback and skip to next song or show holding screen
+		if err := app.stopPlayback(); err != nil {
+			mpvLogger.Warn("Failed to stop playback", "err", err)
+		}
+		if next := app.queue.Current(); next != nil {
+			app.playCurrentSong()
+		} else {
+			app.showHoldingScreen()
+		}
+	}
+
+	// Also kick them if they're connected
+	targetClient := app.hub.FindClientByMartynKey(martynKey)
+	if targetClient != nil {
+		app.hub.KickClient(targetClient, "You have been blocked: "+reason)
+	}
+
+	app.broadcastClientList()
+	app.broadcastState()
+	return nil
+}
+
+// unblockUser lets a blocked singer back in
+func (app *App) unblockUser(martynKey string) error {
+	if err := app.sessions.UnblockUser(martynKey); err != nil {
+		return err
+	}
+	app.broadcastClientList()
+	return nil
+}
+
+// handlePlaylistAction handles all playlist websocket messages
+func (app *App) handlePlaylistAction(client *websocket.Client, action websocket.MessageType, payload websocket.PlaylistPayload) error {
+	sess := client.GetSession()
+	key := sess.MartynKey
+
+	var p *models.Playlist
+	var err error
+
+	switch action {
+	case websocket.MsgPlaylistList:
+		return app.sendPlaylists(key)
+
+	case websocket.MsgPlaylistCreate:
+		p, err = app.playlists.Create(key, payload.Name, payload.SongIDs)
+
+	case websocket.MsgPlaylistRename:
+		p, err = app.playlists.Rename(payload.PlaylistID, key, payload.Name)
+
+	case websocket.MsgPlaylistDelete:
+		// Load first so everyone it was shared with gets the update
+		p, err = app.playlists.Get(payload.PlaylistID)
+		if err == nil {
+			err = app.playlists.Delete(payload.PlaylistID, key)
+		}
+
+	case websocket.MsgPlaylistAddSong:
+		if _, err := app.library.GetSong(payload.SongID); err != nil {
+			return fmt.Errorf("song not found")
+		}
+		p, err = app.playlists.AddSong(payload.PlaylistID, key, payload.SongID)
+
+	case websocket.MsgPlaylistRemoveSong:
+		p, err = app.playlists.RemoveSong(payload.PlaylistID, key, payload.SongID)
+
+	case websocket.MsgPlaylistMoveSong:
+		p, err = app.playlists.MoveSong(payload.PlaylistID, key, payload.From, payload.To)
+
+	case websocket.MsgPlaylistShare:
+		target := app.sessions.GetByPublicID(payload.PublicID)
+		if target == nil {
+			return fmt.Errorf("singer not found")
+		}
+		p, err = app.playlists.Share(payload.PlaylistID, key, target.MartynKey, payload.CanEdit)
+
+	case websocket.MsgPlaylistUnshare:
+		target := key // Leaving a playlist shared with you
+		if payload.PublicID != "" {
+			member := app.sessions.GetByPublicID(payload.PublicID)
+			if member == nil {
+				return fmt.Errorf("singer not found")
+			}
+			target = member.MartynKey
+		}
+		var before *models.Playlist
+		if before, err = app.playlists.Get(payload.PlaylistID); err == nil {
+			if p, err = app.playlists.Unshare(payload.PlaylistID, key, target); err == nil {
+				p = before // Notify the removed singer too
+			}
+		}
+
+	case websocket.MsgPlaylistShareLink:
+		token, err := app.playlists.CreateShareLink(payload.PlaylistID, key, payload.Rotate)
+		if err != nil {
+			return err
+		}
+		app.hub.SendTo(client, websocket.MsgPlaylistShareLink, map[string]string{
+			"playlist_id": payload.PlaylistID,
+			"token":       token,
+			"url":         app.getConnectURL() + "/?playlist=" + token,
+		})
+		return nil
+
+	case websocket.MsgPlaylistJoin:
+		p, err = app.playlists.JoinByShareToken(payload.ShareToken, key)
+		if err == nil {
+			wsLogger.Info("Joined playlist", "name", sess.DisplayName, "playlist", p.Name)
+		}
+
+	case websocket.MsgPlaylistQueue:
+		return app.queuePlaylist(client, payload.PlaylistID, payload.VocalAssist)
+
+	default:
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+	app.notifyPlaylistMembers(p)
+	return nil
+}
+
+// sendPlaylists sends a singer their own and shared playlists
+func (app *App) sendPlaylists(martynKey string) error {
+	lists, err := app.playlistViews(martynKey)
+	if err != nil {
+		return err
+	}
+	app.hub.SendToMartynKey(martynKey, websocket.MsgPlaylists, lists)
+	return nil
+}
+
+// playlistViews returns a singer's own and shared playlists as they may see them
+func (app *App) playlistViews(martynKey string) ([]models.PlaylistView, error) {
+	lists, err := app.playlists.ListForUser(martynKey)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]models.PlaylistView, 0, len(lists))
+	for _, p := range lists {
+		view := models.PlaylistView{
+			ID:         p.ID,
+			Name:       p.Name,
+			SongIDs:    p.SongIDs,
+			Owner:      app.playlistMember(p.OwnerKey),
+			IsOwner:    p.OwnerKey == martynKey,
+			CanEdit:    playlist.CanEdit(&p, martynKey),
+			SharedWith: make([]models.PlaylistMember, 0, len(p.SharedWith)),
+			CreatedAt:  p.CreatedAt,
+			UpdatedAt:  p.UpdatedAt,
+		}
+		for _, key := range p.SharedWith {
+			member := app.playlistMember(key)
+			member.CanEdit = slices.Contains(p.Editors, key)
+			view.SharedWith = append(view.SharedWith, member)
+		}
+		if view.IsOwner {
+			view.ShareToken = p.ShareToken
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// playlistMember returns the public profile of a playlist's owner or member
+func (app *App) playlistMember(martynKey string) models.PlaylistMember {
+	member := models.PlaylistMember{PublicID: models.PublicID(martynKey)}
+	if sess := app.sessions.Get(martynKey); sess != nil {
+		member.DisplayName = sess.DisplayName
+	}
+	return member
+}
+
+// notifyPlaylistMembers refreshes the playlist list for the owner and everyone it is shared with
+func (app *App) notifyPlaylistMembers(p *models.Playlist) {
+	for _, key := range playlist.Members(p) {
+		if err := app.sendPlaylists(key); err != nil {
+			wsLogger.Warn("Failed to send playlists", "key", key[:min(8, len(key))], "err", err)
+		}
+	}
+}
+
+// queuePlaylist adds every song in a playlist to the queue for this singer
+// Songs the singer already has waiting are skipped; fair rotation still applies
+func (app *App) queuePlaylist(client *websocket.Client, playlistID string, vocalAssist models.VocalAssistLevel) error {
+	sess := client.GetSession()
+	p, err := app.playlists.Get(playlistID)
+	if err != nil {
+		return err
+	}
+	if !playlist.CanView(p, sess.MartynKey) {
+		return playlist.ErrNotAllowed
+	}
+	if vocalAssist == "" {
+		vocalAssist = models.VocalOff
+	}
+
+	queueState := app.queue.GetState()
+	waiting := make(map[string]bool)
+	for i, song := range queueState.Songs {
+		if i >= queueState.Position && song.AddedBy == sess.MartynKey {
+			waiting[song.ID] = true
+		}
+	}
+
+	libSongs, err := app.library.GetSongsByIDs(p.SongIDs)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]*models.LibrarySong, len(libSongs))
+	for i := range libSongs {
+		byID[libSongs[i].ID] = &libSongs[i]
+	}
+
+	// Keep playlist order; skip songs missing from the library
+	songs := []models.Song{}
+	for _, id := range p.SongIDs {
+		libSong, ok := byID[id]
+		if !ok || waiting[id] {
+			continue
+		}
+		song := queueSongFromLibrary(libSong, vocalAssist, sess.MartynKey)
+		song.VocalGain = sess.VocalGain
+		songs = append(songs, song)
+	}
+	if len(songs) == 0 {
+		return fmt.Errorf("nothing to queue from this playlist")
+	}
+
+	wasEmpty := app.queue.IsEmpty()
+	if err := app.queue.AddMany(songs); err != nil {
+		queueLogger.Error("Failed to queue playlist", "err", err)
+		return fmt.Errorf("failed to add to queue")
+	}
+	queueLogger.Info("Queued playlist", "name", sess.DisplayName, "songs", len(songs), "playlist", p.Name)
+
+	app.showHoldingScreen()
+	if wasEmpty && app.queue.GetAutoplay() {
+		go func() {
+			time.Sleep(2 * time.Second)
+			app.playCurrentSong()
+			app.broadcastState()
+		}()
+	}
+	app.broadcastState()
+	return nil
+}
+
+// getRoomState returns the current room state
+func (app *App) getRoomState() models.RoomState {
+	playerState, _ := app.mpv.GetState()
+	playerState.CurrentSong = app.queue.Current()
+	playerState.BGMActive = app.bgmActive
+	playerState.BGMEnabled = app.bgmSettings.Enabled
+	playerState.Idle = app.idle
+	if playerState.CurrentSong != nil && !app.idle {
+		playerState.VocalAssist = playerState.CurrentSong.VocalAssist
+	}
+	app.vocalMu.Lock()
+	playerState.VocalGain = math.Round(app.vocalLive*100) / 100 // Whole percent steps
+	app.vocalMu.Unlock()
+
+	// Get countdown state safely
+	app.countdownMu.Lock()
+	countdownState := app.countdown
+	app.countdownMu.Unlock()
+
+	return models.RoomState{
+		Player:    playerState,
+		Queue:     app.queue.GetState(),
+		Sessions:  app.sessions.GetActiveSessions(),
+		Countdown: countdownState,
+	}
+}
+
+// broadcastState sends the current state to all connected clients
+func (app *App) broadcastState() {
+	state := app.getRoomState()
+	app.hub.BroadcastState(state)
+}
+
+// updateTicker updates the scrolling ticker on mpv and the audience next-up panel with upcoming singers
+func (app *App) updateTicker() {
+	// Get upcoming songs (after current position)
+	queueState := app.queue.GetState()
+	var entries []mpv.TickerEntry
+
+	// Show up to 5 upcoming songs
+	maxEntries := 5
+	for i := queueState.Position + 1; i < len(queueState.Songs) && len(entries) < maxEntries; i++ {
+		song := queueState.Songs[i]
+
+		// Look up singer name from their session
+		singerName := "Unknown"
+		if sess := app.sessions.Get(song.AddedBy); sess != nil && sess.DisplayName != "" {
+			singerName = sess.DisplayName
+		}
+
+		entries = append(entries, mpv.TickerEntry{
+			SingerName: singerName,
+			SongTitle:  song.Title,
+		})
+	}
+
+	// Audience screens list who's up next whether or not the ticker scrolls
+	app.outputs.SetUpNext(entries)
+
+	if !app.config.ScrollingTickerEnabled {
+		app.mpv.HideTicker()
+		app.outputs.HideTicker()
+		return
+	}
+
+	if err := app.mpv.ShowTicker(entries); err != nil {
+		queueLogger.Warn("Failed to update ticker", "err", err)
+	}
+	app.outputs.ShowTicker(entries)
+}
+
+// broadcastClientList sends the client list to all admin clients
+func (app *App) broadcastClientList() {
+	// Get connected clients
+	clients := app.hub.GetConnectedClients()
+
+	// Create a map of existing clients for quick lookup
+	clientMap := make(map[string]*websocket.ClientInfo)
+	for i := range clients {
+		clientMap[clients[i].MartynKey] = &clients[i]
+	}
+
+	// Add blocked users to the list
+	blockedUsers := app.sessions.GetBlockedUsers()
+	for _, bu := range blockedUsers {
+		if existing, ok := clientMap[bu.MartynKey]; ok {
+			// Update existing client with block info
+			existing.IsBlocked = true
+			existing.BlockReason = bu.Reason
+		} else {
+			// Add blocked user as offline client
+			sess := app.sessions.Get(bu.MartynKey)
+			deviceName := ""
+			ipAddress := ""
+			deviceType := ""
+			deviceOS := ""
+			deviceBrowser := ""
+			var avatarConfig *models.AvatarConfig
+			nameLocked := false
+			if sess != nil {
+				deviceName = sess.DeviceName
+				ipAddress = sess.IPAddress
+				avatarConfig = sess.AvatarConfig
+				nameLocked = sess.NameLocked
+				// Parse device info from stored user agent
+				if sess.UserAgent != "" {
+					deviceInfo := device.ParseUserAgent(sess.UserAgent)
+					deviceType = deviceInfo.Type
+					deviceOS = deviceInfo.OS
+					deviceBrowser = deviceInfo.Browser
+				}
+			}
+			clients = append(clients, websocket.ClientInfo{
+				MartynKey:     bu.MartynKey,
+				DisplayName:   bu.DisplayName,
+				DeviceName:    deviceName,
+				DeviceType:    deviceType,
+				DeviceOS:      deviceOS,
+				DeviceBrowser: deviceBrowser,
+				IPAddress:     ipAddress,
+				IsAdmin:       false,
+				IsOnline:      false,
+				IsBlocked:     true,
+				BlockReason:   bu.Reason,
+				AvatarConfig:  avatarConfig,
+				NameLocked:    nameLocked,
+			})
+		}
+	}
+
+	app.hub.BroadcastToAdmins(websocket.MsgClientList, clients)
+}
+
+// playCurrentSong starts playing the current song in the queue
+// startBGM starts background music playback with holding screen visible
+func (app *App) startBGM() {
+	if !app.bgmSettings.Enabled || app.bgmSettings.URL == "" {
+		bgmLogger.Warn("Cannot start - not enabled or no URL configured")
+		return
+	}
+
+	bgmLogger.Info("Starting BGM", "url", app.bgmSettings.URL, "volume", app.bgmSettings.Volume)
+
+	// Mark as idle (no song playing)
+	app.idle = true
+
+	// Generate the holding screen image
+	imagePath := app.generateHoldingScreenImage()
+	if imagePath == "" {
+		bgmLogger.Error("Failed to generate holding screen, cannot start BGM")
+		return
+	}
+
+	// BGM plays at its own volume, not the last song's normalization gain
+	app.mpv.SetGain(0)
+	app.transitions.ShowHolding()
+	app.transitions.CutBGM(1) // Cancel a crossfade still stopping the last BGM
+
+	// Load BGM with holding screen image (includes fade-in)
+	if err := app.mpv.LoadBGMWithImage(imagePath, app.bgmSettings.URL, app.bgmSettings.Volume); err != nil {
+		bgmLogger.Error("Failed to load BGM with image", "err", err)
+		app.bgmActive = false
+		// Show holding screen on failure
+		app.showHoldingScreen()
+		return
+	}
+	app.showOutputHoldingScreens(imagePath)
+
+	app.bgmActive = true
+	app.broadcastState()
+}
+
+// stopBGM stops background music playback with fade-out
+// It broadcasts state to notify all clients (including admin UI) that BGM stopped
+func (app *App) stopBGM() {
+	if !app.bgmActive {
+		return
+	}
+
+	bgmLogger.Info("Stopping BGM with fade-out")
+
+	// Stop the BGM audio with 2-second fade-out
+	if err := app.mpv.StopBGMWithFade(2 * time.Second); err != nil {
+		bgmLogger.Error("Failed to stop BGM audio", "err", err)
+	}
+
+	app.bgmActive = false
+
+	// Broadcast state to notify clients that BGM stopped
+	app.broadcastState()
+
+	// Show holding screen after stopping BGM (if we're still idle)
+	if app.idle {
+		app.showHoldingScreen()
+	}
+}
+
+// Holding screen widget data
+const (
+	holdingQueuePreview = 10             // Queued songs and top singers passed to themes
+	tonightWindow       = 12 * time.Hour // How far back "top singers tonight" looks
+)
+
+// holdingScreenContent gathers what the holding screen widgets show
+func (app *App) holdingScreenContent() holdingscreen.Content {
+	venue := app.venueApp()
+	content := holdingscreen.Content{
+		ConnectURL: venue.autoDetectConnectURL(),
+		Now:        time.Now(),
+	}
+	// Guests scan through the install page so new devices learn to trust the local CA
+	content.JoinURL = venue.certInstallURL(content.ConnectURL)
+	// Extra rooms' codes join that room
+	if app.venue != nil {
+		content.ConnectURL += app.roomQuery()
+		if content.JoinURL != "" {
+			content.JoinURL += "?room=" + url.QueryEscape(app.roomID)
+		}
+	}
+	queueState := app.queue.GetState()
+
+	// Only show "next up" if there's actually an upcoming song
+	// (position must be within bounds - not exhausted/in history)
+	if queueState.Position < len(queueState.Songs) {
+		content.NextUp = app.holdingScreenSong(queueState.Songs[queueState.Position])
+		for _, song := range queueState.Songs[queueState.Position+1:] {
+			if len(content.Queue) == holdingQueuePreview {
+				break
+			}
+			content.Queue = append(content.Queue, *app.holdingScreenSong(song))
+		}
+	}
+
+	// Tonight's busiest singers who are still known to the session manager
+	if top, err := app.library.TopSingers(time.Now().Add(-tonightWindow), holdingQueuePreview); err == nil {
+		for _, s := range top {
+			if singer := app.sessions.Get(s.MartynKey); singer != nil {
+				content.TopSingers = append(content.TopSingers, holdingscreen.SingerStat{Name: singer.DisplayName, Songs: s.Songs})
+			}
+		}
+	}
+
+	// Get the admin message if set
+	app.holdingMessageMu.RLock()
+	content.Message = app.holdingMessage
+	app.holdingMessageMu.RUnlock()
+
+	return content
+}
+
+// holdingScreenSong describes a queued song and its singer for the holding screen
+func (app *App) holdingScreenSong(song models.Song) *holdingscreen.NextUpInfo {
+	info := &holdingscreen.NextUpInfo{
+		SongTitle:  song.Title,
+		SongArtist: song.Artist,
+		SingerName: "Unknown",
+	}
+	if singer := app.sessions.Get(song.AddedBy); singer != nil {
+		info.SingerName = singer.DisplayName
+		info.AvatarConfig = singer.AvatarConfig
+	}
+	return info
+}
+
+// showOutputHoldingScreens shows role-specific holding screens on the extra outputs
+// mainImage is the room holding screen already generated for the primary output
+func (app *App) showOutputHoldingScreens(mainImage string) {
+	images := map[mpv.Role]string{mpv.RoleMain: mainImage}
+
+	// Only render the singer screen if a singer monitor is configured
+	for _, cfg := range app.outputs.Configs() {
+		if cfg.Role == mpv.RoleSinger {
+			content := app.holdingScreenContent()
+			if path, err := app.holdingScreen.GenerateSinger(content.NextUp, content.Message); err != nil {
+				holdingLogger.Error("Failed to generate singer holding screen", "err", err)
+			} else {
+				images[mpv.RoleSinger] = path
+			}
+			break
+		}
+	}
+
+	app.outputs.ShowHolding(images)
+}
+
+// stopPlayback stops playback on the primary player and every extra output
+func (app *App) stopPlayback() error {
+	app.finishRecording(0)
+	app.finishReactions(0)
+	app.outputs.StopPlayback()
+	return app.mpv.StopPlayback()
+}
+
+// generateHoldingScreenImage creates and returns the path to the holding screen image
+func (app *App) generateHoldingScreenImage() string {
+	if app.holdingScreen == nil {
+		return ""
+	}
+
+	// Generate the holding screen
+	imagePath, err := app.holdingScreen.Generate(app.holdingScreenContent())
+	if err != nil {
+		holdingLogger.Error("Failed to generate holding screen", "err", err)
+		return ""
+	}
+
+	return imagePath
+}
+
+// showHoldingScreen displays the holding screen with QR code and next-up info
+// If BGM is currently active, it reloads the image while preserving audio
+func (app *App) showHoldingScreen() {
+	if app.holdingScreen == nil {
+		return
+	}
+
+	// Mark as idle (not playing a song)
+	app.idle = true
+
+	// Host announcements between songs use a dry mic
+	app.applyMicPreset("")
+
+	content := app.holdingScreenContent()
+
+	// Generate the holding screen image
+	imagePath, err := app.holdingScreen.Generate(content)
+	if err != nil {
+		holdingLogger.Error("Failed to generate holding screen", "err", err)
+		return
+	}
+
+	// Extra outputs never play BGM audio, so they just load their images
+	app.showOutputHoldingScreens(imagePath)
+
+	// If BGM is active, update just the image while keeping audio playing
+	if app.bgmActive {
+		if err := app.mpv.UpdateBGMImage(imagePath); err != nil {
+			bgmLogger.Error("Failed to update BGM image", "err", err)
+		} else {
+			holdingLogger.Debug("Holding screen updated while BGM playing")
+		}
+		return
+	}
+
+	app.transitions.ShowHolding()
+
+	// Themes with a background video loop it under the widgets
+	if video := app.holdingScreen.Theme().BackgroundVideo(); video != "" {
+		overlayPath, err := app.holdingScreen.GenerateOverlay(content)
+		if err == nil {
+			err = app.mpv.LoadHoldingVideo(video, overlayPath)
+		}
+		if err == nil {
+			holdingLogger.Debug("Holding screen displayed over theme video")
+			return
+		}
+		holdingLogger.Warn("Failed to show holding screen video, showing the still image", "err", err)
+	}
+
+	if err := app.mpv.LoadImage(imagePath); err != nil {
+		holdingLogger.Error("Failed to load holding screen", "err", err)
+		return
+	}
+
+	holdingLogger.Debug("Holding screen displayed")
+}
+
+// runHoldingClock redraws the holding screen on the minute while its theme shows the time
+func (app *App) runHoldingClock() {
+	for {
+		now := time.Now()
+		select {
+		case <-app.done:
+			return
+		case <-time.After(now.Truncate(time.Minute).Add(time.Minute).Sub(now)):
+		}
+		if app.idle && app.holdingScreen != nil && app.holdingScreen.Theme().Ticking() {
+			app.showHoldingScreen()
+		}
+	}
+}
+
+// updateHoldingScreenIfIdle refreshes the holding screen if no song is currently playing
+func (app *App) updateHoldingScreenIfIdle() {
+	// Only update if we're not currently playing a song
+	if app.queue.Current() == nil || !app.queue.GetAutoplay() {
+		app.showHoldingScreen()
+	}
+}
+
+// startCountdown starts the inter-song countdown
+// currentSingerKey is the MartynKey of the singer who just finished
+func (app *App) startCountdown(currentSingerKey string) {
+	app.countdownMu.Lock()
+
+	// Stop any existing countdown
+	if app.countdownTicker != nil {
+		app.countdownTicker.Stop()
+		close(app.countdownStop)
+	}
+
+	// Get the next song
+	nextSong := app.queue.Current()
+	if nextSong == nil {
+		queueLogger.Debug("startCountdown: no next song")
+		app.countdownMu.Unlock()
+		return
+	}
+
+	// Check if next singer is same as current
+	requiresApproval := nextSong.AddedBy != currentSingerKey
+
+	// Initialize countdown state
+	app.countdown = models.CountdownState{
+		Active:           true,
+		SecondsRemaining: 15,
+		NextSongID:       nextSong.ID,
+		NextSingerKey:    nextSong.AddedBy,
+		RequiresApproval: requiresApproval,
+	}
+
+	// Create ticker and stop channel
+	app.countdownTicker = time.NewTicker(app.countdownTick)
+	app.countdownStop = make(chan struct{})
+
+	queueLogger.Info("Starting 15-second countdown for next song", "requires_approval", requiresApproval)
+
+	// Unlock BEFORE calling broadcastState to avoid deadlock
+	// (broadcastState -> getRoomState -> countdownMu.Lock would deadlock)
+	app.countdownMu.Unlock()
+
+	// Show holding screen with next up info
+	app.showHoldingScreen()
+	app.broadcastState()
+
+	go func() {
+		for {
+			select {
+			case <-app.countdownStop:
+				return
+			case <-app.countdownTicker.C:
+				app.countdownMu.Lock()
+				if !app.countdown.Active {
+					app.countdownMu.Unlock()
+					return
+				}
+
+				app.countdown.SecondsRemaining--
+
+				if app.countdown.SecondsRemaining <= 0 {
+					// Countdown finished
+					if !app.countdown.RequiresApproval {
+						// Same user - auto-play
+						queueLogger.Info("Countdown finished - auto-playing next song (same user)")
+						app.countdown.Active = false
+						app.countdownMu.Unlock()
+						app.playNextSongNow()
+						return
+					} else {
+						// Different user - wait for admin approval
+						queueLogger.Info("Countdown finished - waiting for admin approval (different user)")
+						app.countdown.SecondsRemaining = 0
+						app.countdownMu.Unlock()
+						// Broadcast AFTER releasing lock to avoid deadlock
+						app.broadcastState()
+						return
+					}
+				}
+
+				app.countdownMu.Unlock()
+				// Broadcast AFTER releasing lock to avoid deadlock
+				app.broadcastState()
+			}
+		}
+	}()
+}
+
+// startPlayCountdown starts a countdown before playing (admin-initiated)
+// This gives the singer time to get ready before the song starts
+func (app *App) startPlayCountdown(seconds int) {
+	queueLogger.Debug("startPlayCountdown called", "seconds", seconds)
+
+	// Check if MPV is running, restart if not
+	if !app.mpv.IsRunning() {
+		mpvLogger.Warn("mpv not running - restarting before countdown")
+		if err := app.restartPlayer(); err != nil {
+			mpvLogger.Error("Failed to restart mpv", "err", err)
+			return
+		}
+		mpvLogger.Info("mpv restarted successfully")
+	} else {
+		mpvLogger.Debug("mpv is running")
+	}
+
+	// Stop BGM immediately when countdown starts
+	if app.bgmActive {
+		bgmLogger.Debug("Stopping BGM for countdown")
+		app.stopBGM()
+	}
+
+	app.countdownMu.Lock()
+	queueLogger.Debug("Acquired countdown mutex")
+
+	// Stop any existing countdown
+	if app.countdownTicker != nil {
+		queueLogger.Debug("Stopping existing countdown ticker")
+		app.countdownTicker.Stop()
+		app.countdownTicker = nil
+		if app.countdownStop != nil {
+			close(app.countdownStop)
+			app.countdownStop = nil
+		}
+	}
+
+	// Get the next song
+	nextSong := app.queue.Current()
+	if nextSong == nil {
+		queueLogger.Warn("startPlayCountdown: no song in queue - aborting")
+		app.countdownMu.Unlock()
+		return
+	}
+	queueLogger.Debug("Next song", "title", nextSong.Title, "artist", nextSong.Artist, "id", nextSong.ID)
+
+	// If seconds is 0, skip countdown and play immediately
+	if seconds <= 0 {
+		queueLogger.Debug("Immediate start requested (0 seconds) - playing now")
+		app.countdownMu.Unlock()
+		app.playNextSongNow()
+		return
+	}
+
+	// Initialize countdown state - RequiresApproval=false means it will auto-play
+	app.countdown = models.CountdownState{
+		Active:           true,
+		SecondsRemaining: seconds,
+		NextSongID:       nextSong.ID,
+		NextSingerKey:    nextSong.AddedBy,
+		RequiresApproval: false, // Admin initiated, will auto-play
+	}
+	queueLogger.Debug("Countdown state set", "active", app.countdown.Active, "seconds", app.countdown.SecondsRemaining)
+
+	// Create ticker and stop channel
+	app.countdownTicker = time.NewTicker(app.countdownTick)
+	app.countdownStop = make(chan struct{})
+
+	queueLogger.Info("Starting play countdown", "seconds", seconds)
+
+	// Unlock BEFORE calling broadcastState to avoid deadlock
+	app.countdownMu.Unlock()
+	queueLogger.Debug("Released countdown mutex")
+
+	// Show holding screen with next up info and countdown
+	queueLogger.Debug("Calling showHoldingScreen")
+	app.showHoldingScreen()
+	queueLogger.Debug("Calling broadcastState")
+	app.broadcastState()
+	queueLogger.Debug("Countdown setup complete, goroutine starting")
+
+	go func() {
+		for {
+			select {
+			case <-app.countdownStop:
+				return
+			case <-app.countdownTicker.C:
+				app.countdownMu.Lock()
+				if !app.countdown.Active {
+					app.countdownMu.Unlock()
+					return
+				}
+
+				app.countdown.SecondsRemaining--
+
+				if app.countdown.SecondsRemaining <= 0 {
+					// Countdown finished - start playing
+					queueLogger.Info("Play countdown finished - starting song")
+					app.countdown.Active = false
+					app.countdownMu.Unlock()
+					app.playNextSongNow()
+					return
+				}
+
+				app.countdownMu.Unlock()
+				app.broadcastState()
+			}
+		}
+	}()
+}
+
+// stopCountdown stops the countdown without playing
+func (app *App) stopCountdown() {
+	app.countdownMu.Lock()
+	defer app.countdownMu.Unlock()
+
+	if app.countdownTicker != nil {
+		app.countdownTicker.Stop()
+		close(app.countdownStop)
+		app.countdownTicker = nil
+	}
+
+	app.countdown = models.CountdownState{}
+}
+
+// playNextSongNow plays the next song immediately (admin action or auto-play)
+func (app *App) playNextSongNow() {
+	app.countdownMu.Lock()
+	if app.countdownTicker != nil {
+		app.countdownTicker.Stop()
+		app.countdownTicker = nil
+	}
+	if app.countdownStop != nil {
+		close(app.countdownStop)
+		app.countdownStop = nil
+	}
+	app.countdown = models.CountdownState{}
+	app.countdownMu.Unlock()
+
+	// Play the current song in queue
+	app.playCurrentSong()
+	app.broadcastState()
+}
+
+func (app *App) playCurrentSong() {
+	// Crossfade from BGM into the song, or stop BGM if active
+	crossfade := app.transitions.Settings().BGMCrossfade
+	fromBGM := app.bgmActive && crossfade > 0 && app.queue.Current() != nil
+	if !fromBGM {
+		app.stopBGM()
+	}
+
+	// Mark as not idle (playing a song)
+	app.idle = false
+
+	song := app.queue.Current()
+	if song == nil {
+		queueLogger.Warn("playCurrentSong: no current song in queue")
+		return
+	}
+
+	if !fromBGM {
+		app.startSong(song, 0)
+		return
+	}
+
+	// The BGM plays apart from songs, so it fades out under the song as it fades in
+	bgmLogger.Info("Crossfading from BGM into song", "title", song.Title)
+	app.bgmActive = false
+	app.broadcastState()
+	app.transitions.FadeOutBGM(crossfade, func() {
+		if err := app.mpv.StopBGM(); err != nil {
+			bgmLogger.Error("Failed to stop BGM audio", "err", err)
+		}
+	})
+	app.startSong(song, crossfade)
+}
+
+// startSong loads a song into the player, fading it in over fadeIn
+func (app *App) startSong(song *models.Song, fadeIn time.Duration) {
+	mpvLogger.Info("Playing", "title", song.Title, "artist", song.Artist, "file", song.VideoURL)
+
+	// Get singer's display name for overlay
+	singerName := "Unknown"
+	if singer := app.sessions.Get(song.AddedBy); singer != nil {
+		singerName = singer.DisplayName
+		// Save current singer's avatar to PNG file for external use
+		if singer.AvatarConfig != nil {
+			if avatarPath, err := app.holdingScreen.SaveCurrentSingerAvatar(singer.AvatarConfig); err != nil {
+				mpvLogger.Warn("Failed to save singer avatar", "err", err)
+			} else if avatarPath != "" {
+				mpvLogger.Debug("Saved current singer avatar", "path", avatarPath)
+			}
+		}
+	}
+
+	// Switch the mic to the singer's effects preset
+	app.applyMicPreset(song.AddedBy)
+
+	// Capture the performance if the singer opted in
+	app.startRecording(song)
+
+	// Vocal assist only applies to stems; the branch below re-arms it
+	app.endVocalAssist()
+
+	// Normalize loudness before any audio starts
+	loudnessGain := app.songGain(song)
+	if err := app.mpv.SetGain(loudnessGain); err != nil {
+		mpvLogger.Error("Failed to set loudness gain", "err", err)
+	} else if loudnessGain != 0 {
+		mpvLogger.Debug("Loudness gain", "db", loudnessGain)
+	}
+
+	// Skip leading and trailing silence, and fade in if coming from BGM
+	lead, tail := app.songTrim(song)
+	if err := app.mpv.SetTrim(lead, tail); err != nil {
+		mpvLogger.Error("Failed to set silence trim", "err", err)
+	} else if lead > 0 || tail > 0 {
+		mpvLogger.Debug("Trimming silence", "lead", lead, "tail", tail)
+	}
+	app.transitions.StartSong(fadeIn)
+
+	// Check for CDG+Audio pair first
+	if song.CDGPath != "" && song.AudioPath != "" {
+		mpvLogger.Info("Using CDG+Audio", "cdg", song.CDGPath, "audio", song.AudioPath)
+		if err := app.mpv.LoadCDG(song.CDGPath, song.AudioPath); err != nil {
+			mpvLogger.Error("Failed to load CDG", "cdg", song.CDGPath, "err", err)
+			app.handleSongLoadError(song)
+		} else {
+			app.outputs.LoadSong(song.VideoURL, song.CDGPath)
+			app.showSingerOverlay(singerName, song.Title)
+		}
+		return
+	}
+
+	// If stems are available, use vocal mixing
+	if song.InstrPath != "" && song.VocalPath != "" {
+		gain := app.beginVocalAssist(song)
+		mpvLogger.Info("Using vocal mix", "instr", song.InstrPath, "vocal", song.VocalPath, "gain", gain)
+		if err := app.mpv.SetVocalMix(song.InstrPath, song.VocalPath, gain); err != nil {
+			mpvLogger.Error("Failed to set vocal mix", "err", err)
+			app.handleSongLoadError(song)
+		} else {
+			app.outputs.LoadSong(song.VideoURL, "")
+			app.showSingerOverlay(singerName, song.Title)
+		}
+		return
+	}
+
+	// Play original video/audio
+	app.mpv.SetPlayingSong(true) // Mark as song playback for end detection
+	if err := app.mpv.LoadFile(song.VideoURL); err != nil {
+		mpvLogger.Error("Failed to load file", "file", song.VideoURL, "err", err)
+		app.handleSongLoadError(song)
+	} else {
+		app.mpv.StartPlaybackMonitor() // Start monitoring for song end
+		app.outputs.LoadSong(song.VideoURL, "")
+		app.showSingerOverlay(singerName, song.Title)
+	}
+}
+
+// defaultLoudnessTarget is the normalization target when LOUDNESS_TARGET_LUFS isn't set
+const defaultLoudnessTarget = -18.0
+
+// songGain returns the normalization gain for a queued song
+// Songs that aren't in the library or haven't been analyzed yet play unchanged
+func (app *App) songGain(song *models.Song) float64 {
+	if !app.config.LoudnessNormalization {
+		return 0
+	}
+	libSong, err := app.library.GetSong(song.ID)
+	if err != nil || libSong.LoudnessLUFS == nil {
+		return 0
+	}
+	return loudness.GainDB(loudness.Result{
+		IntegratedLUFS: *libSong.LoudnessLUFS,
+		TruePeakDBTP:   *libSong.TruePeakDBTP,
+	}, app.config.LoudnessTargetLUFS)
+}
+
+// songTrim returns the seconds of leading and trailing silence to skip for a queued song
+// CD+G graphics are timed to the start of their audio, so those songs are never trimmed
+func (app *App) songTrim(song *models.Song) (float64, float64) {
+	if !app.transitions.Settings().TrimSilence || song.CDGPath != "" {
+		return 0, 0
+	}
+	libSong, err := app.library.GetSong(song.ID)
+	if err != nil {
+		return 0, 0
+	}
+	return transition.Trim(libSong.LeadingSilence, libSong.TrailingSilence)
+}
+
+// startLoudnessAnalysis measures any library songs that have no loudness yet
+func (app *App) startLoudnessAnalysis() error {
+	if err := app.loudnessJob.Start(); err != nil && !errors.Is(err, loudness.ErrJobRunning) {
+		return err
+	}
+	return nil
+}
+
+// handleSongLoadError handles recovery when a song fails to load
+// It advances to the next song or shows the holding screen if queue is empty
+func (app *App) handleSongLoadError(failedSong *models.Song) {
+	queueLogger.Warn("Skipping failed song", "title", failedSong.Title, "artist", failedSong.Artist)
+	app.metrics.songLoadFailures.Inc()
+	if app.recorder != nil {
+		app.recorder.Discard()
+	}
+
+	// Advance to next song in queue (Skip advances position)
+	next := app.queue.Skip()
+	app.broadcastState()
+
+	// Check if there's another song to play
+	if next != nil {
+		queueLogger.Info("Attempting to play next song", "title", next.Title, "artist", next.Artist)
+		// Use a short delay to avoid rapid-fire retries if multiple songs fail
+		time.AfterFunc(500*time.Millisecond, func() {
+			app.playCurrentSong()
+			app.broadcastState()
+		})
+	} else {
+		queueLogger.Info("No more songs in queue, showing holding screen")
+		app.idle = true
+		app.showHoldingScreen()
+	}
+}
+
+// showSingerOverlay displays the singer's name on screen if enabled
+func (app *App) showSingerOverlay(singerName, songTitle string) {
+	if !app.config.SingerNameOverlay {
+		return
+	}
+	// Show "Now singing: [Name]" for 5 seconds
+	overlayText := fmt.Sprintf("🎤 %s", singerName)
+	if err := app.mpv.ShowOverlay(overlayText, 5000); err != nil {
+		mpvLogger.Warn("Failed to show singer overlay", "err", err)
+	}
+	app.outputs.ShowOverlay(overlayText, 5000)
+}
+
+// applyMicPreset switches the mic effects to a singer's preset ("" for dry)
+func (app *App) applyMicPreset(martynKey string) {
+	if app.mic == nil {
+		return
+	}
+	var preset models.MicPreset
+	if sess := app.sessions.Get(martynKey); sess != nil {
+		preset = sess.MicPreset
+	}
+	effects, err := mpv.MicEffectsFor(preset)
+	if err != nil {
+		mpvLogger.Warn("Mic preset unavailable, using dry", "err", err)
+	}
+	if err := app.mic.SetEffects(effects); err != nil {
+		mpvLogger.Error("Failed to set mic effects", "err", err)
+	}
+}
+
+// startRecording starts capturing a song if its singer opted in to recording
+// Any capture still running (e.g. after a skip) is finished first.
+func (app *App) startRecording(song *models.Song) {
+	if app.recorder == nil {
+		return
+	}
+	sess := app.sessions.Get(song.AddedBy)
+	if sess == nil || !sess.RecordPerformances {
+		app.finishRecording(0)
+		return
+	}
+	if _, err := app.recorder.Start(recording.Entry{
+		SongID:    song.ID,
+		Title:     song.Title,
+		Artist:    song.Artist,
+		MartynKey: song.AddedBy,
+	}); err != nil {
+		log.Printf("Failed to start recording: %v", err)
+	}
+}
+
+// finishRecording ends the current capture, links it to its song_history row
+// (0 when the song didn't finish) and sends the singer a download link once the
+// file is finished, without holding up the track change
+func (app *App) finishRecording(historyID int64) {
+	if app.recorder == nil {
+		return
+	}
+	app.recorder.Finish(historyID, func(rec *recording.Recording, err error) {
+		if err != nil {
+			log.Printf("Failed to finish recording: %v", err)
+			return
+		}
+		if rec == nil || rec.Status != recording.StatusComplete {
+			return
+		}
+		log.Printf("Recorded '%s' (%s, %d bytes)", rec.SongTitle, rec.Duration().Round(time.Second), rec.SizeBytes)
+		app.hub.SendToMartynKey(rec.MartynKey, websocket.MsgRecordingReady, app.recordingWithLink(*rec))
+	})
+}
+
+// recordingView is a recording as sent to its singer
+type recordingView struct {
+	recording.Recording
+	Download *recording.Link `json:"download,omitempty"`
+}
+
+// recordingWithLink pairs a recording with a fresh private download link
+func (app *App) recordingWithLink(rec recording.Recording) recordingView {
+	view := recordingView{Recording: rec}
+	link, err := app.recorder.Link(rec.ID)
+	if err != nil {
+		log.Printf("Failed to create download link for recording %d: %v", rec.ID, err)
+		return view
+	}
+	view.Download = link
+	return view
+}
+
+// micLevelSource reports the singer's mic level in dBFS
+type micLevelSource interface {
+	Level() (float64, error)
+}
+
+// beginVocalAssist returns the starting vocal gain for a stems song and arms AUTO mode
+func (app *App) beginVocalAssist(song *models.Song) float64 {
+	gain := models.VocalGainFor(song.VocalAssist, song.VocalGain)
+
+	app.vocalMu.Lock()
+	defer app.vocalMu.Unlock()
+	app.vocalLive = gain
+	app.vocalAuto = nil
+	if song.VocalAssist == models.VocalAuto && app.micLevel != nil {
+		app.vocalAuto = vocalassist.NewAuto(gain)
+	}
+	return gain
+}
+
+// endVocalAssist clears the live vocal state when no stems are playing
+func (app *App) endVocalAssist() {
+	app.vocalMu.Lock()
+	defer app.vocalMu.Unlock()
+	app.vocalLive = 0
+	app.vocalAuto = nil
+}
+
+// updateVocalMix changes the vocal assist of the current song live, without reloading it
+func (app *App) updateVocalMix(level models.VocalAssistLevel, customGain float64) {
+	song := app.queue.Current()
+	if app.idle || song == nil || song.InstrPath == "" || song.VocalPath == "" {
+		return
+	}
+	if _, err := app.queue.UpdateCurrentVocal(level, customGain); err != nil {
+		mpvLogger.Warn("Failed to save vocal assist", "err", err)
+	}
+
+	gain := app.beginVocalAssist(song)
+	mpvLogger.Info("Updating vocal mix", "level", level, "gain", gain)
+	if err := app.mpv.SetVocalGain(gain); err != nil {
+		mpvLogger.Error("Failed to update vocal mix", "err", err)
+	}
+	app.broadcastState()
+}
+
+// autoVocalInterval is how often AUTO vocal assist reads the mic
+const autoVocalInterval = 200 * time.Millisecond
+
+// runAutoVocal drives the vocal stem from the mic level while a singer uses AUTO
+func (app *App) runAutoVocal() {
+	ticker := time.NewTicker(autoVocalInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for now := range ticker.C {
+		app.stepAutoVocal(now.Sub(last))
+		last = now
+	}
+}
+
+// stepAutoVocal feeds one mic reading taken dt after the last to AUTO vocal assist
+func (app *App) stepAutoVocal(dt time.Duration) {
+	app.vocalMu.Lock()
+	auto := app.vocalAuto
+	app.vocalMu.Unlock()
+	if auto == nil || app.idle {
+		return
+	}
+
+	level, err := app.micLevel.Level()
+	if err != nil {
+		return
+	}
+
+	app.vocalMu.Lock()
+	if app.vocalAuto != auto {
+		app.vocalMu.Unlock()
+		return // The song or mode changed while reading the mic
+	}
+	before := app.vocalLive
+	gain := auto.Update(level, dt)
+	app.vocalLive = gain
+	app.vocalMu.Unlock()
+
+	if math.Abs(gain-before) < 0.005 {
+		return
+	}
+	if err := app.mpv.SetVocalGain(gain); err != nil {
+		mpvLogger.Error("Failed to update vocal mix", "err", err)
+	}
+	// Phones show the level in 5% steps
+	if int(gain*20) != int(before*20) {
+		app.broadcastState()
+	}
+}
+
+// Run starts the HTTP server and WebSocket hub
+func (app *App) Run() {
+	// Start WebSocket hub
+	go app.hub.Run()
+
+	// Periodically suggest songs to idle singers
+	go app.runRecommendationPush()
+
+	// Keep delta-sync clients' playback position current
+	go app.runPositionTicks()
+
+	// Keep holding screen clocks and countdowns current
+	go app.runHoldingClock()
+
+	// Reissue the local CA's certificate as network interfaces come and go
+	if app.certs != nil {
+		go app.runCertRotation()
+	}
+
+	// Apply edits to the configuration file as they're saved
+	if app.config.ConfigPath != "" {
+		go app.runConfigWatch()
+	}
+
+	// Take scheduled backups
+	go app.runBackups()
+
+	// Follow the singer's mic for AUTO vocal assist
+	if app.micLevel != nil {
+		go app.runAutoVocal()
+	}
+
+	// Start live mic monitoring
+	if app.mic != nil {
+		if err := app.mic.Start(); err != nil {
+			log.Printf("Warning: Failed to start mic effects: %v", err)
+		}
+	}
+
+	// Measure loudness of songs added since the last run
+	if app.config.LoudnessNormalization {
+		if err := app.startLoudnessAnalysis(); err != nil {
+			log.Printf("Warning: Loudness analysis unavailable: %v", err)
+		}
+	}
+
+	// Start mpv
+	mpvReady := false
+	if err := app.startPlayer(); err != nil {
+		mpvLogger.Warn("Failed to start mpv", "err", err)
+		mpvLogger.Warn("Continuing without video playback...")
+	} else {
+		mpvLogger.Info("mpv started successfully")
+		mpvReady = true
+
+		// Start any extra output screens and keep them in sync
+		app.startOutputs()
+	}
+
+	// Start every extra room's player
+	for _, room := range app.roomList() {
+		room.startRoom()
+	}
+
+	// Show holding screen and run initial diagnostics after HTTP server starts
+	go func() {
+		// Wait for HTTP server to be ready
+		time.Sleep(500 * time.Millisecond)
+
+		// Run initial diagnostics (port checks need server running)
+		app.refreshDiagnostics()
+
+		if !mpvReady {
+			return
+		}
+
+		// After a hot restart, carry on with whatever the adopted player is showing
+		if app.resumeAfterRestart() {
+			return
+		}
+
+		// Always start with holding screen - require manual Play button
+		// This prevents unexpected playback when restarting the server
+		queueLogger.Info("Startup - showing holding screen (manual play required)")
+		app.showHoldingScreen()
+	}()
+
+	app.handler = app.routes()
+	app.serve()
+}
+
+// routes builds the HTTPS server's handler
+func (app *App) routes() http.Handler {
+	// Setup routes
+	mux := http.NewServeMux()
+
+	// WebSocket endpoint
+	mux.HandleFunc("/ws", app.hub.ServeWS)
+
+	// Web display player (when used instead of mpv)
+	if app.webDisplay != nil {
+		mux.HandleFunc(webdisplay.PagePath, app.webDisplay.ServePage)
+		mux.HandleFunc(webdisplay.WSPath, app.webDisplay.ServeWS)
+		mux.HandleFunc(webdisplay.MediaPath, app.webDisplay.ServeMedia)
+	}
+
+	// Certificate install page and CA downloads
+	if app.certs != nil {
+		mux.Handle(certs.PagePath, app.certs.Handler(app.httpsPort))
+	}
+
+	// API endpoints
+	mux.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"ok","version":"0.1.0"}`))
+	})
+
+	// Public status endpoint
+	mux.HandleFunc("/api/status", app.withRoom((*App).handleStatus))
+
+	// Rooms guests can join (public)
+	mux.HandleFunc("/api/rooms", app.handleRooms)
+
+	// Prometheus metrics (admin only: scrape from this machine, the admin socket or with an admin token)
+	mux.HandleFunc("/metrics", app.admin.Middleware(app.metrics.registry.Handler().ServeHTTP))
+
+	// Feature flags endpoint (public)
+	mux.HandleFunc("/api/features", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"youtube_enabled":         app.config.YouTubeAPIKey != "",
+			"admin_localhost_only":    app.admin.IsLocalhostOnly(),
+			"pitch_control_enabled":   app.config.PitchControlEnabled,
+			"tempo_control_enabled":   app.config.TempoControlEnabled,
+			"fair_rotation_enabled":   app.config.FairRotationEnabled,
+			"scrolling_ticker_enabled": app.config.ScrollingTickerEnabled,
+			"singer_name_overlay":     app.config.SingerNameOverlay,
+			"mic_effects_enabled":     app.mic != nil,
+			"recording_enabled":       app.recorder != nil,
+			"vocal_auto_enabled":      app.micLevel != nil,
+		})
+	})
+
+	// Mic effects presets singers can pick from (public)
+	mux.HandleFunc("/api/mic/presets", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"enabled": app.mic != nil,
+			"running": app.mic != nil && app.mic.IsRunning(),
+			"presets": mpv.MicPresets(),
+		})
+	})
+
+	// Performance recording downloads (the token is the credential)
+	mux.HandleFunc(recording.DownloadPath, app.handleRecordingDownload)
+
+	// Admin API endpoints
+	// Endpoints wrapped by inRoom act on the room named by ?room= and accept its own PIN
+	mux.HandleFunc("/api/admin/auth", app.withRoom((*App).handleRoomAuth))
+	mux.HandleFunc("/api/admin/check", app.withRoom((*App).handleRoomCheckAuth))
+	mux.HandleFunc("/api/admin/set-pin", app.admin.HandleSetPIN) // localhost only
+	mux.HandleFunc("/api/admin/clients", app.admin.Middleware(app.handleAdminClients))
+	mux.HandleFunc("/api/admin/clients/", app.admin.Middleware(app.handleAdminClientAction))
+	mux.HandleFunc("/api/admin/sessions", app.admin.Middleware(app.handleAdminSessions))
+	mux.HandleFunc("/api/admin/queue", app.inRoom((*App).handleAdminQueue))
+	mux.HandleFunc("/api/admin/rooms", app.admin.Middleware(app.handleAdminRooms))
+	mux.HandleFunc("/api/admin/rooms/", app.admin.Middleware(app.handleAdminRoomAction))
+
+	// Library API endpoints (admin only)
+	mux.HandleFunc("/api/library/locations", app.admin.Middleware(app.handleLibraryLocations))
+	mux.HandleFunc("/api/library/locations/", app.admin.Middleware(app.handleLibraryLocationAction))
+	mux.HandleFunc("/api/admin/library/songs/", app.admin.Middleware(app.handleAdminLibrarySong))
+	mux.HandleFunc("/api/library/search", app.handleLibrarySearch)
+	mux.HandleFunc("/api/library/stats", app.handleLibraryStats)
+	mux.HandleFunc("/api/library/popular", app.handleLibraryPopular)
+	mux.HandleFunc("/api/library/songs", app.handleLibrarySongsByIDs)
+	mux.HandleFunc("/api/library/history", app.handleLibraryHistory)
+	mux.HandleFunc("/api/library/browse/", app.handleLibraryBrowse)
+	mux.HandleFunc("/api/library/recommendations", app.handleLibraryRecommendations)
+
+	// Playlist endpoints (changes go through the websocket)
+	mux.HandleFunc("/api/playlists", app.handlePlaylists)
+	mux.HandleFunc("/api/playlists/shared/", app.handleSharedPlaylist)
+
+	// YouTube search endpoint
+	mux.HandleFunc("/api/youtube/search", app.handleYouTubeSearch)
+
+	// Song selection logging endpoint (public - called when user selects a song)
+	mux.HandleFunc("/api/library/select", app.handleSongSelection)
+
+	// Search logs endpoints (admin only)
+	mux.HandleFunc("/api/admin/search-logs", app.admin.Middleware(app.handleSearchLogs))
+	mux.HandleFunc("/api/admin/search-stats", app.admin.Middleware(app.handleSearchStats))
+	mux.HandleFunc("/api/admin/song-selections", app.admin.Middleware(app.handleSongSelections))
+
+	// Settings endpoints (admin only)
+	mux.HandleFunc("/api/admin/settings", app.admin.Middleware(app.handleSettings))
+	mux.HandleFunc("/api/admin/server/reload", app.admin.Middleware(app.handleServerReload))
+	mux.HandleFunc("/api/admin/server/restart", app.admin.Middleware(app.handleServerRestart))
+	mux.HandleFunc("/api/admin/logs", app.admin.Middleware(app.handleLogs))
+	mux.HandleFunc("/api/admin/logs/stream", app.admin.Middleware(app.handleLogStream))
+	mux.HandleFunc("/api/admin/logs/levels", app.admin.Middleware(app.handleLogLevels))
+	mux.HandleFunc("/api/admin/system-info", app.admin.Middleware(app.handleSystemInfo))
+	mux.HandleFunc("/api/admin/networks", app.admin.Middleware(app.handleNetworkEnumeration))
+	mux.HandleFunc("/api/admin/player", app.inRoom((*App).handlePlayer))
+	mux.HandleFunc("/api/admin/outputs", app.admin.Middleware(app.handleOutputs))
+	mux.HandleFunc("/api/admin/outputs/", app.admin.Middleware(app.handleOutputAction))
+	mux.HandleFunc("/api/admin/database", app.admin.Middleware(app.handleDatabase))
+	mux.HandleFunc("/api/admin/backups", app.admin.Middleware(app.handleBackups))
+	mux.HandleFunc("/api/admin/backups/", app.admin.Middleware(app.handleBackupFile))
+	mux.HandleFunc("/api/admin/backups/restore", app.admin.Middleware(app.handleBackupRestore))
+	mux.HandleFunc("/api/admin/bgm", app.inRoom((*App).handleBGM))
+	mux.HandleFunc("/api/admin/holding-message", app.inRoom((*App).handleHoldingMessage))
+	mux.HandleFunc("/api/admin/holding-themes", app.admin.Middleware(app.handleHoldingThemes))
+	mux.HandleFunc("/api/admin/holding-themes/active", app.inRoom((*App).handleHoldingThemeActive))
+	mux.HandleFunc("/api/admin/loudness", app.admin.Middleware(app.handleLoudness))
+	mux.HandleFunc("/api/admin/loudness/analyze", app.admin.Middleware(app.handleLoudnessAnalyze))
+	mux.HandleFunc("/api/admin/recordings", app.admin.Middleware(app.handleAdminRecordings))
+	mux.HandleFunc("/api/admin/icecast-streams", app.admin.Middleware(app.handleIcecastStreams))
+	mux.HandleFunc("/api/admin/browse-dirs", app.admin.Middleware(app.handleBrowseDirs))
+	mux.HandleFunc("/api/admin/diagnostics", app.admin.Middleware(app.handleDiagnostics))
+	mux.HandleFunc("/api/admin/mdns", app.admin.Middleware(app.handleMDNS))
+	mux.HandleFunc("/api/admin/mpv-check", app.admin.Middleware(app.handleMPVCheck))
+	mux.HandleFunc("/api/connect-url", app.handleConnectURL) // Public - returns selected connection URL
+
+	// Avatar API endpoints
+	mux.HandleFunc("/api/avatar", handleAvatar)
+	mux.HandleFunc("/api/avatar/png", handleAvatarPNG)
+	mux.HandleFunc("/api/avatar/debug", handleAvatarDebug)
+	mux.HandleFunc("/api/avatar/random", handleAvatarRandom)
+	mux.HandleFunc("/api/avatar/animated", handleAvatarAnimated)
+	mux.HandleFunc("/api/avatar/sticker", handleAvatarSticker)
+
+	// Static files (frontend build) with SPA fallback
+	if _, err := os.Stat(app.config.StaticDir); err == nil {
+		mux.HandleFunc("/", spaHandler(app.config.StaticDir))
+	}
+
+	// CORS middleware for dev mode
+	var handler http.Handler = mux
+	if app.config.DevMode {
+		handler = corsMiddleware(mux)
+		log.Println("Development mode enabled - CORS allowed from all origins")
+	}
+	return handler
+}
+
+// serve serves HTTPS, the HTTP redirect and the admin socket until shutdown
+func (app *App) serve() {
+	httpsAddr := ":" + app.config.Port
+	httpAddr := ":" + app.config.HTTPPort
+
+	log.Printf("SongMartyn starting on https://localhost%s", httpsAddr)
+	log.Printf("HTTP redirect server on http://localhost%s", httpAddr)
+	log.Printf("WebSocket endpoint: wss://localhost%s/ws", httpsAddr)
+	if app.webDisplay != nil {
+		log.Printf("Web display: https://localhost%s%s", httpsAddr, app.webDisplay.PageURL())
+	}
+	log.Printf("Admin panel: https://localhost%s/admin", httpsAddr)
+
+	// Log admin access mode
+	if app.admin.IsLocalhostOnly() {
+		log.Printf("Admin access: localhost only (no PIN configured)")
+	} else {
+		log.Printf("Admin PIN: %s", app.admin.GetPIN())
+	}
+
+	// Log YouTube status
+	if app.config.YouTubeAPIKey != "" {
+		log.Printf("YouTube search: enabled")
+	} else {
+		log.Printf("YouTube search: disabled (no API key)")
+	}
+
+	// Serve HTTPS and the HTTP redirect until shutdown
+	if err := app.reloadTLS(); err != nil {
+		log.Fatalf("TLS setup failed: %v", err)
+	}
+	if app.tlsCert.Load() == nil {
+		log.Printf("TLS enabled with local CA %q (fingerprint %s)", app.certs.Name(), app.certs.Fingerprint())
+		log.Printf("Certificate install page: http://localhost%s%s", httpAddr, certs.PagePath)
+	}
+	if err := app.startServers(); err != nil {
+		log.Fatalf("HTTPS server failed: %v", err)
+	}
+	if err := app.startAdminSocket(); err != nil {
+		log.Printf("Warning: Command-line tools can't reach this server: %v", err)
+	}
+	<-app.stopped
+}
+
+// Server lifecycle timings
+const (
+	serverDrainTimeout = 10 * time.Second // In-flight requests get this long to finish
+	clientDrainTimeout = 2 * time.Second  // WebSocket clients get this long to receive the restart notice
+	reconnectHint      = 3 * time.Second  // Clients are told to wait this long before reconnecting
+)
+
+// httpsPort returns the HTTPS port currently served
+func (app *App) httpsPort() string {
+	return app.config.Port
+}
+
+// redirectHandler serves the HTTP port: the certificate install page, and redirects to HTTPS
+func (app *App) redirectHandler() http.Handler {
+	redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Build HTTPS URL
+		host := r.Host
+		// Replace HTTP port with HTTPS port in host
+		if app.config.HTTPPort != "80" {
+			host = strings.TrimSuffix(host, ":"+app.config.HTTPPort)
+		}
+		if app.config.Port != "443" {
+			host = host + ":" + app.config.Port
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+	if app.certs == nil {
+		return redirect
+	}
+
+	// Guests fetch the CA over plain HTTP, before their devices trust HTTPS
+	mux := http.NewServeMux()
+	mux.Handle(certs.PagePath, app.certs.Handler(app.httpsPort))
+	mux.Handle("/", redirect)
+	return mux
+}
+
+// startServers binds the HTTPS and HTTP ports, then serves both in the background
+// Nothing is served unless both ports can be bound
+func (app *App) startServers() error {
+	httpsLn, err := net.Listen("tcp", ":"+app.config.Port)
+	if err != nil {
+		return fmt.Errorf("HTTPS port %s: %w", app.config.Port, err)
+	}
+	httpLn, err := net.Listen("tcp", ":"+app.config.HTTPPort)
+	if err != nil {
+		httpsLn.Close()
+		return fmt.Errorf("HTTP port %s: %w", app.config.HTTPPort, err)
+	}
+
+	// Long-lived requests (log streams) see their context end when the server starts draining
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	httpsServer := &http.Server{
+		Handler:     app.handler,
+		TLSConfig:   &tls.Config{GetCertificate: app.getCertificate},
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	httpsServer.RegisterOnShutdown(cancelRequests)
+	redirectServer := &http.Server{Handler: app.redirectHandler()}
+	go func() {
+		if err := httpsServer.ServeTLS(httpsLn, "", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTPS server error: %v", err)
+		}
+	}()
+	go func() {
+		if err := redirectServer.Serve(httpLn); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP redirect server error: %v", err)
+		}
+	}()
+
+	app.serverMu.Lock()
+	app.servers = []*http.Server{httpsServer, redirectServer}
+	app.serverMu.Unlock()
+	app.hub.Resume()
+
+	log.Printf("Serving HTTPS on :%s, HTTP redirect on :%s", app.config.Port, app.config.HTTPPort)
+	return nil
+}
+
+// stopServers tells WebSocket clients to reconnect, stops accepting connections and waits
+// for in-flight requests; the hub drains WebSockets since the servers don't track them
+func (app *App) stopServers(notice websocket.RestartPayload) {
+	app.hub.Drain(notice, clientDrainTimeout)
+
+	app.serverMu.Lock()
+	servers := app.servers
+	app.servers = nil
+	app.serverMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), serverDrainTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("Server didn't drain in time, closing: %v", err)
+			srv.Close()
+		}
+	}
+}
+
+// restartServers moves the servers to the configured ports, telling clients where to reconnect
+// If the new ports can't be bound the old ones are restored
+func (app *App) restartServers(oldPort, oldHTTPPort string) error {
+	log.Printf("Moving servers from :%s/:%s to :%s/:%s", oldPort, oldHTTPPort, app.config.Port, app.config.HTTPPort)
+	app.stopServers(websocket.RestartPayload{
+		Reason:      "Server moving to a new port",
+		ReconnectMs: int(reconnectHint / time.Millisecond),
+		URL:         app.autoDetectConnectURL(),
+	})
+
+	err := app.startServers()
+	if err == nil {
+		app.reregisterMDNS()
+		return nil
+	}
+	log.Printf("Failed to move servers, restoring the old ports: %v", err)
+	app.config.Port, app.config.HTTPPort = oldPort, oldHTTPPort
+	if restoreErr := app.startServers(); restoreErr != nil {
+		log.Printf("Failed to restore servers: %v", restoreErr)
+	}
+	return err
+}
+
+// reregisterMDNS re-advertises the mDNS hostname, e.g. after it or the HTTPS port changed
+// An empty hostname turns mDNS off
+func (app *App) reregisterMDNS() {
+	if app.mdnsServer != nil {
+		app.mdnsServer.Shutdown()
+		app.mdnsServer = nil
+	}
+	if app.config.MDNSHostname == "" {
+		return
+	}
+
+	portNum, _ := strconv.Atoi(app.config.Port)
+	mdnsServer, err := zeroconf.Register(app.config.MDNSHostname, "_https._tcp", "local.", portNum,
+		[]string{"txtv=0", "lo=1", "path=/"}, nil)
+	if err != nil {
+		log.Printf("Failed to restart mDNS server: %v", err)
+		return
+	}
+	app.mdnsServer = mdnsServer
+	log.Printf("mDNS: Now advertising as %s.local:%d", app.config.MDNSHostname, portNum)
+}
+
+// Command-line tools reach a running server through these files in the data directory
+const (
+	adminSocketFile = "admin.sock"  // Unix socket serving the admin API
+	adminTokenFile  = "admin.token" // Secret the tools send with each request, readable only by this user
+)
+
+// startAdminSocket serves the admin API on a Unix socket in the data directory for the
+// command-line tools, which authenticate with a token written alongside it
+func (app *App) startAdminSocket() error {
+	path := filepath.Join(app.config.DataDir, adminSocketFile)
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("another server is already using %s", path)
+	}
+	os.Remove(path) // Left behind by a server that didn't shut down
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(secret)
+	tokenPath := filepath.Join(app.config.DataDir, adminTokenFile)
+	os.Remove(tokenPath) // WriteFile keeps the permissions of a file that already exists
+	if err := os.WriteFile(tokenPath, []byte(token), 0600); err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return err
+	}
+
+	srv := &http.Server{Handler: app.adminSocketHandler(token)}
+	app.serverMu.Lock()
+	app.adminSocket = srv
+	app.serverMu.Unlock()
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Admin socket error: %v", err)
+		}
+	}()
+	log.Printf("Admin socket for command-line tools: %s", path)
+	return nil
+}
+
+// adminSocketHandler serves the admin API to requests carrying token
+func (app *App) adminSocketHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid admin socket token"})
+			return
+		}
+		app.handler.ServeHTTP(w, admin.Trusted(r))
+	})
+}
+
+// stopAdminSocket stops serving the command-line tools, waiting for requests in flight
+func (app *App) stopAdminSocket() {
+	app.serverMu.Lock()
+	srv := app.adminSocket
+	app.adminSocket = nil
+	app.serverMu.Unlock()
+	if srv == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), serverDrainTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		srv.Close()
+	}
+	os.Remove(filepath.Join(app.config.DataDir, adminTokenFile))
+}
+
+// checkPortFree reports an error if port can't be bound
+func checkPortFree(port string) error {
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+	return ln.Close()
+}
+
+// getCertificate serves the configured certificate files, or the local CA's certificate without them
+func (app *App) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cert := app.tlsCert.Load(); cert != nil {
+		return cert, nil
+	}
+	if app.certs != nil {
+		return app.certs.GetCertificate(hello)
+	}
+	return nil, fmt.Errorf("no TLS certificate loaded")
+}
+
+// reloadTLS (re)loads the configured certificate files, falling back to the local CA without them
+// A certificate that fails to load leaves the current one in place
+func (app *App) reloadTLS() error {
+	if hasCertFiles(app.config) {
+		cert, err := tls.LoadX509KeyPair(app.config.CertFile, app.config.KeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		app.tlsCert.Store(&cert)
+		log.Printf("TLS enabled with cert: %s, key: %s", app.config.CertFile, app.config.KeyFile)
+		return nil
+	}
+	if app.certs == nil {
+		return fmt.Errorf("no TLS certificate at %s and the local CA is off (TLS_LOCAL_CA)", app.config.CertFile)
+	}
+	app.tlsCert.Store(nil)
+	app.refreshCertificate()
+	return nil
+}
+
+// certCheckInterval is how often interfaces are checked for new addresses
+const certCheckInterval = 30 * time.Second
+
+// hasCertFiles reports whether the configured certificate and key both exist
+func hasCertFiles(config Config) bool {
+	_, certErr := os.Stat(config.CertFile)
+	_, keyErr := os.Stat(config.KeyFile)
+	return certErr == nil && keyErr == nil
+}
+
+// certHosts lists every name and address guests might use to reach this machine
+func (app *App) certHosts() []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if app.config.MDNSHostname != "" {
+		hosts = append(hosts, app.config.MDNSHostname+".local")
+	}
+	for _, addr := range listNetworkAddresses() {
+		hosts = append(hosts, addr.IP.String())
+	}
+	return hosts
+}
+
+// refreshCertificate makes sure the local CA's certificate covers certHosts
+func (app *App) refreshCertificate() {
+	if app.certs == nil {
+		return
+	}
+	rotated, err := app.certs.Issue(app.certHosts())
+	if err != nil {
+		log.Printf("Warning: Failed to issue TLS certificate: %v", err)
+		return
+	}
+	if rotated {
+		log.Printf("TLS: Issued certificate for %s", strings.Join(app.certs.Hosts(), ", "))
+	}
+}
+
+// runCertRotation reissues the certificate when the machine's addresses change
+func (app *App) runCertRotation() {
+	for {
+		time.Sleep(certCheckInterval)
+		app.refreshCertificate()
+	}
+}
+
+// configCheckInterval is how often the configuration file is checked for edits
+const configCheckInterval = 2 * time.Second
+
+// runConfigWatch reloads the configuration file whenever it's edited
+func (app *App) runConfigWatch() {
+	for {
+		time.Sleep(configCheckInterval)
+		app.checkConfigFile()
+	}
+}
+
+// checkConfigFile reloads the configuration file if it changed since it was last read or saved
+// A file that doesn't validate is reported and the running settings are kept
+func (app *App) checkConfigFile() {
+	info, err := os.Stat(app.config.ConfigPath)
+	if err != nil {
+		return
+	}
+	app.configMu.Lock()
+	changed := !info.ModTime().Equal(app.configModTime)
+	app.configModTime = info.ModTime()
+	app.configMu.Unlock()
+	if !changed {
+		return
+	}
+
+	log.Printf("%s changed, reloading", app.config.ConfigPath)
+	if _, err := app.reloadSettings(); err != nil {
+		log.Printf("Configuration not applied: %v", err)
+	}
+}
+
+// certInstallURL returns the plain-HTTP certificate install page on connectURL's host,
+// or "" when TLS doesn't come from the local CA
+func (app *App) certInstallURL(connectURL string) string {
+	if app.certs == nil || app.tlsCert.Load() != nil {
+		return ""
+	}
+	u, err := url.Parse(connectURL)
+	if err != nil {
+		return ""
+	}
+	host := u.Hostname()
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if app.config.HTTPPort != "80" {
+		host += ":" + app.config.HTTPPort
+	}
+	return "http://" + host + certs.PagePath
+}
+
+// Shutdown gracefully shuts down the application
+func (app *App) Shutdown() {
+	app.shutdown(false)
+}
+
+// shutdown drains clients and in-flight requests, then stops every component
+// keepPlayer leaves mpv and the output screens running for a restarted process to adopt
+func (app *App) shutdown(keepPlayer bool) {
+	notice := websocket.RestartPayload{Reason: "Server shutting down", ReconnectMs: int(reconnectHint / time.Millisecond)}
+	if keepPlayer {
+		notice.Reason = "Server restarting"
+	}
+	app.stopServers(notice)
+	app.stopAdminSocket()
+
+	// Stop mDNS server first
+	if app.mdnsServer != nil {
+		app.mdnsServer.Shutdown()
+		log.Println("mDNS server stopped")
+	}
+
+	app.loudnessJob.Stop()
+	app.transitions.Stop()
+	if app.mic != nil {
+		app.mic.Stop()
+	}
+	if app.recorder != nil {
+		app.recorder.Close()
+	}
+	if keepPlayer {
+		app.outputs.DetachAll()
+		app.mpv.Detach()
+	} else {
+		app.outputs.StopAll()
+		app.mpv.Stop()
+	}
+	for _, room := range app.roomList() {
+		room.stopRoom(keepPlayer)
+	}
+	app.sessions.Close()
+	app.queue.Close()
+	app.library.Close()
+	app.playlists.Close()
+	app.stopOnce.Do(func() { close(app.stopped) })
+}
+
+// restartStateFile remembers what was playing across a hot restart
+const restartStateFile = "restart.json"
+
+// restartState is what the next process needs to carry on with an adopted player
+type restartState struct {
+	SongID string `json:"song_id,omitempty"` // Song playing ("" when idle)
+	BGM    bool   `json:"bgm"`               // BGM playing under the holding screen
+}
+
+// restartProcess replaces this process with a fresh copy of itself
+// Clients are told to reconnect and mpv keeps playing for the new process to adopt
+func (app *App) restartProcess() {
+	exe, err := os.Executable()
+	if err != nil {
+		log.Printf("Hot restart failed: %v", err)
+		return
+	}
+	log.Println("Hot restart: handing over to a new process")
+
+	for _, room := range append([]*App{app}, app.roomList()...) {
+		if err := room.saveRestartState(); err != nil {
+			log.Printf("Warning: Failed to save playback state for restart: %v", err)
+		}
+	}
+	app.shutdown(true)
+
+	if err := syscall.Exec(exe, os.Args, os.Environ()); err != nil {
+		log.Fatalf("Hot restart failed: %v", err)
+	}
+}
+
+// saveRestartState records what's playing for resumeAfterRestart
+func (app *App) saveRestartState() error {
+	state := restartState{BGM: app.bgmActive}
+	if song := app.queue.Current(); song != nil && !app.idle {
+		state.SongID = song.ID
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(app.config.DataDir, restartStateFile), data, 0644)
+}
+
+// resumeAfterRestart picks up where the previous process left off if the player was adopted
+// Returns false when the app should start fresh with the holding screen
+func (app *App) resumeAfterRestart() bool {
+	path := filepath.Join(app.config.DataDir, restartStateFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	os.Remove(path)
+
+	var state restartState
+	if err := json.Unmarshal(data, &state); err != nil || !app.mpv.Adopted() {
+		return false
+	}
+
+	if state.SongID == "" {
+		log.Println("Hot restart: holding screen still showing")
+		app.idle = true
+		if state.BGM {
+			// The BGM's own mpv quits with the old process, so start it again
+			app.startBGM()
+			return true
+		}
+		app.broadcastState()
+		return true
+	}
+
+	song := app.queue.Current()
+	if song == nil || song.ID != state.SongID {
+		return false
+	}
+	log.Printf("Hot restart: '%s' still playing", song.Title)
+	app.idle = false
+	app.applyMicPreset(song.AddedBy)
+	app.mpv.SetPlayingSong(true)
+	app.mpv.StartPlaybackMonitor()
+	app.broadcastState()
+	return true
+}
+
+// handleAvatar generates an SVG avatar from config parameters
+func handleAvatar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	config := avatarQueryConfig(q)
+
+	// Check if we should include environment (background)
+	includeEnv := q.Get("noenv") != "true"
+
+	// Generate SVG
+	svg := config.ToSVGWithEnv(includeEnv)
+
+	// Set headers for SVG
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "public, max-age=86400") // Cache for 1 day
+	w.Write([]byte(svg))
+}
+
+// avatarQueryConfig reads an avatar config from query parameters: one per part
+// (env, clo, ...) with optional c_<part> colors, or a whole config as JSON in "config"
+func avatarQueryConfig(q url.Values) avatar.Config {
+	config := avatar.Config{
+		Env:   parseIntParam(q.Get("env"), 0),
+		Clo:   parseIntParam(q.Get("clo"), 0),
+		Head:  parseIntParam(q.Get("head"), 0),
+		Mouth: parseIntParam(q.Get("mouth"), 0),
+		Eyes:  parseIntParam(q.Get("eyes"), 0),
+		Top:   parseIntParam(q.Get("top"), 0),
+	}
+
+	// Parse custom colors (optional)
+	if hasColorParams(q) {
+		config.Colors = &avatar.Colors{
+			Env:   q.Get("c_env"),
+			Clo:   q.Get("c_clo"),
+			Head:  q.Get("c_head"),
+			Mouth: q.Get("c_mouth"),
+			Eyes:  q.Get("c_eyes"),
+			Top:   q.Get("c_top"),
+		}
+	}
+
+	// Check if JSON config is provided
+	if configJSON := q.Get("config"); configJSON != "" {
+		if parsed, err := avatar.FromJSON(configJSON); err == nil {
+			config = parsed
+		}
+	}
+
+	config.Normalize()
+	return config
+}
+
+// hasColorParams checks if any color parameters are provided
+func hasColorParams(q url.Values) bool {
+	return q.Get("c_env") != "" || q.Get("c_clo") != "" || q.Get("c_head") != "" ||
+		q.Get("c_mouth") != "" || q.Get("c_eyes") != "" || q.Get("c_top") != ""
+}
+
+// handleAvatarRandom generates a random avatar config with colors
+func handleAvatarRandom(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	config := avatar.NewRandomWithColors()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(config)
+}
+
+// parseIntParam parses an int from string with default
+func parseIntParam(s string, defaultVal int) int {
+	if s == "" {
+		return defaultVal
+	}
+	var v int
+	if _, err := fmt.Sscanf(s, "%d", &v); err != nil {
+		return defaultVal
+	}
+	return v
+}
+
+// spaHandler serves static files with SPA fallback to index.html
+func spaHandler(staticDir string) http.HandlerFunc {
+	fs := http.Dir(staticDir)
+	fileServer := http.FileServer(fs)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+
+		// Try to open the file
+		f, err := fs.Open(path)
+		if err != nil {
+			// File doesn't exist, serve index.html for SPA routing
+			r.URL.Path = "/"
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+		f.Close()
+
+		// Check if it's a directory
+		stat, err := os.Stat(filepath.Join(staticDir, path))
+		if err == nil && stat.IsDir() {
+			// Check for index.html in directory
+			indexPath := filepath.Join(staticDir, path, "index.html")
+			if _, err := os.Stat(indexPath); err != nil {
+				// No index.html, serve root index.html
+				r.URL.Path = "/"
+				fileServer.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		// Serve the file
+		fileServer.ServeHTTP(w, r)
+	}
+}
+
+// corsMiddleware adds CORS headers for development
+func corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleStatus handles GET /api/status - public system status page
+func (app *App) handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	type ServiceStatus struct {
+		Status string `json:"status"`
+	}
+
+	type StatusResponse struct {
+		Database    ServiceStatus `json:"database"`
+		WebSocket   ServiceStatus `json:"websocket"`
+		Library     ServiceStatus `json:"library"`
+		MediaPlayer ServiceStatus `json:"media_player"`
+		Internet    ServiceStatus `json:"internet"`
+	}
+
+	status := StatusResponse{
+		Database:    ServiceStatus{Status: "unavailable"},
+		WebSocket:   ServiceStatus{Status: "unavailable"},
+		Library:     ServiceStatus{Status: "unavailable"},
+		MediaPlayer: ServiceStatus{Status: "unavailable"},
+		Internet:    ServiceStatus{Status: "unavailable"},
+	}
+
+	// Check database (via library stats)
+	if _, _, err := app.library.GetStats(); err == nil {
+		status.Database.Status = "connected"
+	}
+
+	// Check WebSocket hub
+	if app.hub != nil {
+		status.WebSocket.Status = "connected"
+	}
+
+	// Check library (has locations configured)
+	if locations, err := app.library.GetLocations(); err == nil && len(locations) > 0 {
+		status.Library.Status = "connected"
+	}
+
+	// Check media player (MPV)
+	if app.mpv != nil {
+		if _, err := app.mpv.GetState(); err == nil {
+			status.MediaPlayer.Status = "connected"
+		}
+	}
+
+	// Check internet connectivity (quick timeout)
+	client := &http.Client{Timeout: 2 * time.Second}
+	if resp, err := client.Head("https://www.google.com"); err == nil {
+		resp.Body.Close()
+		status.Internet.Status = "connected"
+	}
+
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleAdminClients handles GET /api/admin/clients - list all clients
+func (app *App) handleAdminClients(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	clients := app.hub.GetConnectedClients()
+	json.NewEncoder(w).Encode(clients)
+}
+
+// handleAdminClientAction handles POST/DELETE /api/admin/clients/{key}/...
+func (app *App) handleAdminClientAction(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse path: /api/admin/clients/{key}/{action}
+	path := r.URL.Path
+	// Remove prefix "/api/admin/clients/"
+	path = path[len("/api/admin/clients/"):]
+
+	parts := splitPath(path)
+	if len(parts) < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid path"})
+		return
+	}
+
+	martynKey := parts[0]
+	action := ""
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "admin" && r.Method == http.MethodPost:
+		// Toggle admin status
+		var req struct {
+			IsAdmin bool `json:"is_admin"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+			return
+		}
+
+		if err := app.sessions.SetAdmin(martynKey, req.IsAdmin); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		// Update live session if online
+		if client := app.hub.FindClientByMartynKey(martynKey); client != nil {
+			if sess := client.GetSession(); sess != nil {
+				sess.IsAdmin = req.IsAdmin
+			}
+		}
+
+		app.broadcastClientList()
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+
+	case action == "" && r.Method == http.MethodDelete:
+		// Kick client
+		client := app.hub.FindClientByMartynKey(martynKey)
+		if client == nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Client not found"})
+			return
+		}
+
+		app.hub.KickClient(client, "Kicked by admin")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+
+	case action == "block" && r.Method == http.MethodPost:
+		// Block, optionally for a number of minutes (0 = permanently)
+		var req struct {
+			Minutes int    `json:"minutes"`
+			Reason  string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Minutes < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+			return
+		}
+		if err := app.blockUser(martynKey, time.Duration(req.Minutes)*time.Minute, req.Reason); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Admin blocked user %s: %s", martynKey, req.Reason)
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+
+	case action == "block" && r.Method == http.MethodDelete:
+		if err := app.unblockUser(martynKey); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Admin unblocked user %s", martynKey)
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown action"})
+	}
+}
+
+// handleAdminSessions handles GET /api/admin/sessions - every known singer and the block list
+func (app *App) handleAdminSessions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	blocked := app.sessions.GetBlockedUsers()
+	if blocked == nil {
+		blocked = []session.BlockedUser{}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessions": app.sessions.GetAllSessions(),
+		"blocked":  blocked,
+	})
+}
+
+// handleAdminQueue handles GET/POST/DELETE /api/admin/queue
+// GET returns the queue, POST adds a library song for a singer, DELETE clears it
+func (app *App) handleAdminQueue(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(app.queue.GetState())
+
+	case http.MethodPost:
+		var req struct {
+			SongID string `json:"song_id"`
+			Singer string `json:"singer"` // MartynKey the song is queued for
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SongID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+			return
+		}
+
+		libSong, err := app.library.GetSong(req.SongID)
+		if err != nil || libSong == nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Song not found: " + req.SongID})
+			return
+		}
+		song := queueSongFromLibrary(libSong, models.VocalOff, req.Singer)
+		if err := app.addToQueue(song); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		queueLogger.Info("Admin queued song", "artist", song.Artist, "title", song.Title)
+		json.NewEncoder(w).Encode(song)
+
+	case http.MethodDelete:
+		if err := app.clearQueue(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// splitPath splits a URL path into segments
+func splitPath(path string) []string {
+	var parts []string
+	for _, p := range splitOn(path, '/') {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}
+
+// splitOn splits a string on a separator
+func splitOn(s string, sep rune) []string {
+	var parts []string
+	current := ""
+	for _, c := range s {
+		if c == sep {
+			parts = append(parts, current)
+			current = ""
+		} else {
+			current += string(c)
+		}
+	}
+	parts = append(parts, current)
+	return parts
+}
+
+// handleLibraryLocations handles GET/POST /api/library/locations
+func (app *App) handleLibraryLocations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		locations, err := app.library.GetLocations()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if locations == nil {
+			locations = []models.LibraryLocation{}
+		}
+		json.NewEncoder(w).Encode(locations)
+
+	case http.MethodPost:
+		var req struct {
+			Path string `json:"path"`
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+			return
+		}
+
+		location, err := app.library.AddLocation(req.Path, req.Name)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		json.NewEncoder(w).Encode(location)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleLibraryLocationAction handles actions on specific locations
+func (app *App) handleLibraryLocationAction(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse path: /api/library/locations/{id}/{action}
+	path := r.URL.Path[len("/api/library/locations/"):]
+	parts := splitPath(path)
+	if len(parts) < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid path"})
+		return
+	}
+
+	var locationID int64
+	fmt.Sscanf(parts[0], "%d", &locationID)
+
+	action := ""
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "scan" && r.Method == http.MethodPost:
+		// Scan location for media files
+		count, err := app.library.ScanLocation(locationID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":     "ok",
+			"songs_found": count,
+		})
+
+		// Measure any newly found songs in the background
+		if app.config.LoudnessNormalization {
+			if err := app.startLoudnessAnalysis(); err != nil {
+				log.Printf("Loudness analysis not started: %v", err)
+			}
+		}
+
+	case action == "rules" && len(parts) == 2 && r.Method == http.MethodGet:
+		// List metadata rules
+		rules, err := app.library.GetRules(locationID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if rules == nil {
+			rules = []library.MetadataRule{}
+		}
+		json.NewEncoder(w).Encode(rules)
+
+	case action == "rules" && r.Method == http.MethodPost:
+		// Add a metadata rule, or preview it with /rules/preview
+		var rule library.MetadataRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+			return
+		}
+		rule.LibraryID = locationID
+
+		if len(parts) > 2 && parts[2] == "preview" {
+			changes, err := app.library.PreviewRule(rule)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"changes": changes,
+			})
+			return
+		}
+
+		saved, changed, err := app.library.AddRule(rule)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"rule":          saved,
+			"songs_changed": changed,
+		})
+
+	case action == "rules" && len(parts) > 2 && r.Method == http.MethodDelete:
+		// Delete a metadata rule
+		var ruleID int64
+		fmt.Sscanf(parts[2], "%d", &ruleID)
+		changed, err := app.library.DeleteRule(locationID, ruleID)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":        "ok",
+			"songs_changed": changed,
+		})
+
+	case action == "" && r.Method == http.MethodDelete:
+		// Delete location
+		if err := app.library.RemoveLocation(locationID); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown action"})
+	}
+}
+
+// handleAdminLibrarySong handles GET/PATCH /api/admin/library/songs/{id} and POST .../{id}/revert
+func (app *App) handleAdminLibrarySong(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse path: /api/admin/library/songs/{id}/{action}
+	parts := splitPath(r.URL.Path[len("/api/admin/library/songs/"):])
+	if len(parts) < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Song ID required"})
+		return
+	}
+	songID := parts[0]
+
+	action := ""
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		song, err := app.library.GetSong(songID)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Song not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(song)
+
+	case action == "" && (r.Method == http.MethodPatch || r.Method == http.MethodPut):
+		var edit library.SongMetadataEdit
+		if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+			return
+		}
+		song, err := app.library.UpdateSongMetadata(songID, edit)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Library song %s metadata edited: %s - %s", songID, song.Artist, song.Title)
+		json.NewEncoder(w).Encode(song)
+
+	case action == "revert" && r.Method == http.MethodPost:
+		var req struct {
+			Fields []string `json:"fields"` // Empty reverts every manual field
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		song, err := app.library.RevertSongMetadata(songID, req.Fields)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(song)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown action"})
+	}
+}
+
+// handleLibrarySearch handles GET /api/library/search?q=query
+func (app *App) handleLibrarySearch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		json.NewEncoder(w).Encode([]models.LibrarySong{})
+		return
+	}
+
+	searched := app.timeSearch("library")
+	songs, err := app.library.SearchSongs(query, 50)
+	searched()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if songs == nil {
+		songs = []models.LibrarySong{}
+	}
+
+	// Log the search
+	ipAddress := getClientIP(r)
+	martynKey := r.URL.Query().Get("key") // Optional martyn key from client
+	app.library.LogSearch(query, "library", len(songs), martynKey, ipAddress)
+
+	json.NewEncoder(w).Encode(songs)
+}
+
+// handleLibraryStats handles GET /api/library/stats
+func (app *App) handleLibraryStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	totalSongs, totalPlays, err := app.library.GetStats()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]int{
+		"total_songs": totalSongs,
+		"total_plays": totalPlays,
+	})
+}
+
+// handleLibraryPopular handles GET /api/library/popular
+func (app *App) handleLibraryPopular(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	songs, err := app.library.GetPopularSongs(20)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if songs == nil {
+		songs = []models.LibrarySong{}
+	}
+	json.NewEncoder(w).Encode(songs)
+}
+
+// handleLibrarySongsByIDs handles GET /api/library/songs?ids=comma,separated,ids
+func (app *App) handleLibrarySongsByIDs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	idsParam := r.URL.Query().Get("ids")
+	if idsParam == "" {
+		json.NewEncoder(w).Encode([]models.LibrarySong{})
+		return
+	}
+
+	ids := strings.Split(idsParam, ",")
+	songs, err := app.library.GetSongsByIDs(ids)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if songs == nil {
+		songs = []models.LibrarySong{}
+	}
+	json.NewEncoder(w).Encode(songs)
+}
+
+// handleLibraryBrowse handles GET /api/library/browse/{artists|albums|genres|decades|letters|songs}
+// Supports q, artist, album, genre, language, decade and letter filters plus limit/offset paging
+func (app *App) handleLibraryBrowse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Path: /api/library/browse/{dimension}
+	parts := splitPath(r.URL.Path)
+	if len(parts) < 4 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "browse dimension required"})
+		return
+	}
+	dimension := parts[3]
+
+	q := r.URL.Query()
+	filter := library.BrowseFilter{
+		Query:    q.Get("q"),
+		Artist:   q.Get("artist"),
+		Album:    q.Get("album"),
+		Genre:    q.Get("genre"),
+		Language: q.Get("language"),
+		Decade:   library.ParseDecade(q.Get("decade")),
+		Letter:   q.Get("letter"),
+	}
+	limit := parseIntParam(q.Get("limit"), library.DefaultBrowseLimit)
+	offset := parseIntParam(q.Get("offset"), 0)
+
+	if dimension == "songs" {
+		songs, total, err := app.library.BrowseSongs(filter, limit, offset)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"songs":  songs,
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		})
+		return
+	}
+
+	// Accept plural path segments: artists -> artist
+	by := strings.TrimSuffix(dimension, "s")
+	groups, total, err := app.library.BrowseGroups(by, filter, limit, offset)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"by":     by,
+		"groups": groups,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// handleSongSelection handles POST /api/library/select - logs when a user selects a song
+func (app *App) handleSongSelection(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		SongID      string `json:"song_id"`
+		SongTitle   string `json:"song_title"`
+		SongArtist  string `json:"song_artist"`
+		Source      string `json:"source"`
+		SearchQuery string `json:"search_query"`
+		MartynKey   string `json:"martyn_key"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	ipAddress := getClientIP(r)
+	if err := app.library.LogSongSelection(req.SongID, req.SongTitle, req.SongArtist, req.Source, req.SearchQuery, req.MartynKey, ipAddress); err != nil {
+		log.Printf("Failed to log song selection: %v", err)
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleLibraryHistory handles GET /api/library/history?key=martynKey
+func (app *App) handleLibraryHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	martynKey := r.URL.Query().Get("key")
+	if martynKey == "" {
+		json.NewEncoder(w).Encode([]models.SongHistory{})
+		return
+	}
+
+	history, err := app.library.GetUserHistory(martynKey, 50)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if history == nil {
+		history = []models.SongHistory{}
+	}
+	json.NewEncoder(w).Encode(history)
+}
+
+// handleLibraryRecommendations handles GET /api/library/recommendations?limit=10
+// for the singer whose session makes the request (see requestSession)
+func (app *App) handleLibraryRecommendations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	sess := app.requestSession(r)
+	if sess == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Session required"})
+		return
+	}
+
+	limit := parseIntParam(r.URL.Query().Get("limit"), recommendationLimit)
+	recs, err := app.getRecommendations(sess.MartynKey, limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(recs)
+}
+
+// handlePlaylists handles GET /api/playlists for the singer whose session makes the request
+// (see requestSession)
+func (app *App) handlePlaylists(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	sess := app.requestSession(r)
+	if sess == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Session required"})
+		return
+	}
+
+	lists, err := app.playlistViews(sess.MartynKey)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(lists)
+}
+
+// handleSharedPlaylist handles GET /api/playlists/shared/{token}
+// Returns a preview of a shared playlist with song details so a singer can decide to join
+func (app *App) handleSharedPlaylist(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimPrefix(r.URL.Path, "/api/playlists/shared/")
+	p, err := app.playlists.GetByShareToken(token)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Playlist not found"})
+		return
+	}
+
+	songs, err := app.library.GetSongsByIDs(p.SongIDs)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if songs == nil {
+		songs = []models.LibrarySong{}
+	}
+
+	ownerName := ""
+	if owner := app.sessions.Get(p.OwnerKey); owner != nil {
+		ownerName = owner.DisplayName
+	}
+
+	// Don't expose who else it is shared with or the owner's key
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":         p.ID,
+		"name":       p.Name,
+		"owner_name": ownerName,
+		"songs":      songs,
+	})
+}
+
+// YouTubeResult represents a YouTube search result
+type YouTubeResult struct {
+	ID           string `json:"id"`
+	Title        string `json:"title"`
+	Channel      string `json:"channel"`
+	Duration     int    `json:"duration"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+// YouTubeAPIResponse represents the YouTube Data API search response
+type YouTubeAPIResponse struct {
+	Items []struct {
+		ID struct {
+			VideoID string `json:"videoId"`
+		} `json:"id"`
+		Snippet struct {
+			Title        string `json:"title"`
+			ChannelTitle string `json:"channelTitle"`
+			Thumbnails   struct {
+				Medium struct {
+					URL string `json:"url"`
+				} `json:"medium"`
+			} `json:"thumbnails"`
+		} `json:"snippet"`
+	} `json:"items"`
+}
+
+// YouTubeVideoDetailsResponse represents the YouTube video details response
+type YouTubeVideoDetailsResponse struct {
+	Items []struct {
+		ID             string `json:"id"`
+		ContentDetails struct {
+			Duration string `json:"duration"`
+		} `json:"contentDetails"`
+	} `json:"items"`
+}
+
+// parseDuration converts ISO 8601 duration to seconds (e.g., PT4M30S -> 270)
+func parseDuration(isoDuration string) int {
+	// Remove PT prefix
+	d := strings.TrimPrefix(isoDuration, "PT")
+
+	var hours, minutes, seconds int
+
+	// Parse hours
+	if idx := strings.Index(d, "H"); idx != -1 {
+		fmt.Sscanf(d[:idx], "%d", &hours)
+		d = d[idx+1:]
+	}
+
+	// Parse minutes
+	if idx := strings.Index(d, "M"); idx != -1 {
+		fmt.Sscanf(d[:idx], "%d", &minutes)
+		d = d[idx+1:]
+	}
+
+	// Parse seconds
+	if idx := strings.Index(d, "S"); idx != -1 {
+		fmt.Sscanf(d[:idx], "%d", &seconds)
+	}
+
+	return hours*3600 + minutes*60 + seconds
+}
+
+// handleYouTubeSearch handles GET /api/youtube/search?q=query
+func (app *App) handleYouTubeSearch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		json.NewEncoder(w).Encode([]YouTubeResult{})
+		return
+	}
+
+	// Check if API key is configured
+	if app.config.YouTubeAPIKey == "" {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "YouTube search is not available",
+		})
+		return
+	}
+
+	// Covers both API calls, failed or not
+	defer app.timeSearch("youtube")()
+
+	// Build YouTube Data API search URL
+	searchURL := fmt.Sprintf(
+		"https://www.googleapis.com/youtube/v3/search?part=snippet&type=video&maxResults=20&q=%s&key=%s",
+		url.QueryEscape(query),
+		app.config.YouTubeAPIKey,
+	)
+
+	// Make the search request
+	resp, err := http.Get(searchURL)
+	if err != nil {
+		log.Printf("YouTube API request failed: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "YouTube API request failed"})
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		log.Printf("YouTube API error (status %d): %s", resp.StatusCode, string(body))
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{"error": "YouTube API returned an error"})
+		return
+	}
+
+	var searchResp YouTubeAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&searchResp); err != nil {
+		log.Printf("Failed to parse YouTube API response: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to parse YouTube response"})
+		return
+	}
+
+	// Collect video IDs to fetch durations
+	var videoIDs []string
+	for _, item := range searchResp.Items {
+		if item.ID.VideoID != "" {
+			videoIDs = append(videoIDs, item.ID.VideoID)
+		}
+	}
+
+	// Fetch video durations in a single request
+	durations := make(map[string]int)
+	if len(videoIDs) > 0 {
+		detailsURL := fmt.Sprintf(
+			"https://www.googleapis.com/youtube/v3/videos?part=contentDetails&id=%s&key=%s",
+			url.QueryEscape(strings.Join(videoIDs, ",")),
+			app.config.YouTubeAPIKey,
+		)
+
+		detailsResp, err := http.Get(detailsURL)
+		if err == nil {
+			defer detailsResp.Body.Close()
+			if detailsResp.StatusCode == http.StatusOK {
+				var videoDetails YouTubeVideoDetailsResponse
+				if json.NewDecoder(detailsResp.Body).Decode(&videoDetails) == nil {
+					for _, item := range videoDetails.Items {
+						durations[item.ID] = parseDuration(item.ContentDetails.Duration)
+					}
+				}
+			}
+		}
+	}
+
+	// Build results
+	var results []YouTubeResult
+	for _, item := range searchResp.Items {
+		if item.ID.VideoID == "" {
+			continue
+		}
+		results = append(results, YouTubeResult{
+			ID:           item.ID.VideoID,
+			Title:        item.Snippet.Title,
+			Channel:      item.Snippet.ChannelTitle,
+			Duration:     durations[item.ID.VideoID],
+			ThumbnailURL: item.Snippet.Thumbnails.Medium.URL,
+		})
+	}
+
+	// Log the search
+	ipAddress := getClientIP(r)
+	martynKey := r.URL.Query().Get("key")
+	app.library.LogSearch(query, "youtube", len(results), martynKey, ipAddress)
+
+	log.Printf("YouTube search: %q returned %d results", query, len(results))
+	json.NewEncoder(w).Encode(results)
+}
+
+// getClientIP extracts the client IP address from the request
+func getClientIP(r *http.Request) string {
+	// Check for forwarded headers first
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		parts := strings.Split(xff, ",")
+		return strings.TrimSpace(parts[0])
+	}
+	if xri := r.Header.Get("X-Real-IP"); xri != "" {
+		return xri
+	}
+	// Fall back to RemoteAddr
+	ip := r.RemoteAddr
+	if idx := strings.LastIndex(ip, ":"); idx != -1 {
+		ip = ip[:idx]
+	}
+	return ip
+}
+
+// martynKeyHeader carries a guest's MartynKey on API requests for their own data
+const martynKeyHeader = "X-Martyn-Key"
+
+// requestSession returns the session making a request, from its X-Martyn-Key header,
+// or nil if there's no such session. The key is the session's credential, so it's
+// never taken from the URL, where it would end up in logs and browser history.
+func (app *App) requestSession(r *http.Request) *models.Session {
+	key := r.Header.Get(martynKeyHeader)
+	if key == "" {
+		return nil
+	}
+	sess := app.sessions.Get(key)
+	if sess == nil {
+		return nil
+	}
+	if blocked, _ := app.sessions.IsBlocked(key); blocked {
+		return nil
+	}
+	return sess
+}
+
+// handleSearchLogs handles GET /api/admin/search-logs
+func (app *App) handleSearchLogs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := app.library.ClearSearchLogs(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		return
+	}
+
+	source := r.URL.Query().Get("source")
+	logs, err := app.library.GetSearchLogs(100, source)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if logs == nil {
+		logs = []library.SearchLog{}
+	}
+	json.NewEncoder(w).Encode(logs)
+}
+
+// handleSongSelections handles GET /api/admin/song-selections
+func (app *App) handleSongSelections(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	source := r.URL.Query().Get("source")
+	selections, err := app.library.GetSongSelections(100, source)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if selections == nil {
+		selections = []library.SongSelection{}
+	}
+	json.NewEncoder(w).Encode(selections)
+}
+
+// handleSearchStats handles GET /api/admin/search-stats
+func (app *App) handleSearchStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	totalSearches, uniqueQueries, notFoundCount, topQueries, err := app.library.GetSearchStats()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total_searches":  totalSearches,
+		"unique_queries":  uniqueQueries,
+		"not_found_count": notFoundCount,
+		"top_not_found":   topQueries,
+	})
+}
+
+// SettingsPayload represents the settings that can be updated
+type SettingsPayload struct {
+	HTTPSPort     string `json:"https_port"`
+	HTTPPort      string `json:"http_port"`
+	AdminPIN      string `json:"admin_pin"`
+	YouTubeAPIKey string `json:"youtube_api_key"`
+	VideoPlayer   string `json:"video_player"`
+	DataDir       string `json:"data_dir"`
+
+	// Display settings
+	TargetDisplay  string `json:"target_display"`
+	AutoFullscreen bool   `json:"auto_fullscreen"`
+
+	// Feature toggles
+	PitchControlEnabled    bool `json:"pitch_control_enabled"`
+	TempoControlEnabled    bool `json:"tempo_control_enabled"`
+	FairRotationEnabled    bool `json:"fair_rotation_enabled"`
+	ScrollingTickerEnabled bool `json:"scrolling_ticker_enabled"`
+	SingerNameOverlay      bool `json:"singer_name_overlay"`
+}
+
+// handleSettings handles GET/POST /api/admin/settings
+func (app *App) handleSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		// Return current settings (mask sensitive values for display)
+		settings := SettingsPayload{
+			HTTPSPort:     app.config.Port,
+			HTTPPort:      app.config.HTTPPort,
+			AdminPIN:      app.config.AdminPIN,
+			YouTubeAPIKey: app.config.YouTubeAPIKey,
+			VideoPlayer:   app.config.VideoPlayer,
+			DataDir:       app.config.DataDir,
+
+			// Display settings
+			TargetDisplay:  app.config.TargetDisplay,
+			AutoFullscreen: app.config.AutoFullscreen,
+
+			// Feature toggles
+			PitchControlEnabled:    app.config.PitchControlEnabled,
+			TempoControlEnabled:    app.config.TempoControlEnabled,
+			FairRotationEnabled:    app.config.FairRotationEnabled,
+			ScrollingTickerEnabled: app.config.ScrollingTickerEnabled,
+			SingerNameOverlay:      app.config.SingerNameOverlay,
+		}
+		json.NewEncoder(w).Encode(settings)
+
+	case http.MethodPost:
+		var settings SettingsPayload
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+			return
+		}
+
+		httpsPort, httpsErr := strconv.Atoi(settings.HTTPSPort)
+		httpPort, httpErr := strconv.Atoi(settings.HTTPPort)
+		if httpsErr != nil || httpErr != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Ports must be numbers"})
+			return
+		}
+		update := func(f *configfile.File) {
+			f.Server.HTTPSPort = httpsPort
+			f.Server.HTTPPort = httpPort
+			f.Server.AdminPIN = settings.AdminPIN
+			f.Server.DataDir = settings.DataDir
+			f.YouTube.APIKey = settings.YouTubeAPIKey
+			f.Player.VideoPlayer = settings.VideoPlayer
+			f.Player.TargetDisplay = settings.TargetDisplay
+			f.Player.AutoFullscreen = settings.AutoFullscreen
+			f.Features.PitchControl = settings.PitchControlEnabled
+			f.Features.TempoControl = settings.TempoControlEnabled
+			f.Features.FairRotation = settings.FairRotationEnabled
+			f.Features.ScrollingTicker = settings.ScrollingTickerEnabled
+			f.Features.SingerNameOverlay = settings.SingerNameOverlay
+		}
+		if _, err := app.updatedSettings(update); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		next := app.config
+		next.Port = settings.HTTPSPort
+		next.HTTPPort = settings.HTTPPort
+		next.AdminPIN = settings.AdminPIN
+		next.YouTubeAPIKey = settings.YouTubeAPIKey
+		next.VideoPlayer = settings.VideoPlayer
+		next.DataDir = settings.DataDir
+		next.TargetDisplay = settings.TargetDisplay
+		next.AutoFullscreen = settings.AutoFullscreen
+		next.PitchControlEnabled = settings.PitchControlEnabled
+		next.TempoControlEnabled = settings.TempoControlEnabled
+		next.FairRotationEnabled = settings.FairRotationEnabled
+		next.ScrollingTickerEnabled = settings.ScrollingTickerEnabled
+		next.SingerNameOverlay = settings.SingerNameOverlay
+
+		// Apply everything that can change live; nothing is saved if it can't be applied
+		pinChanged := settings.AdminPIN != app.config.AdminPIN
+		restartNeeded, err := app.applySettings(next)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		if err := app.saveSettings(update); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Settings applied but not saved: " + err.Error()})
+			return
+		}
+
+		message := "Settings saved and applied."
+		if len(restartNeeded) > 0 {
+			message = "Settings saved. Restart the server to apply " + strings.Join(restartNeeded, ", ") + "."
+		}
+		if pinChanged {
+			message += " PIN changed - all non-local admin sessions have been invalidated."
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":         "ok",
+			"message":        message,
+			"pin_changed":    pinChanged,
+			"restart_needed": restartNeeded,
+		})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// applySettings switches the running app over to next without a restart where it can
+// Moving ports restarts the servers in the background, with clients told where to reconnect
+// Returns the settings that only take effect after a restart
+func (app *App) applySettings(next Config) ([]string, error) {
+	prev := app.config
+
+	// Check what could fail before changing anything
+	serving := app.handler != nil
+	portsChanged := next.Port != prev.Port || next.HTTPPort != prev.HTTPPort
+	if serving && portsChanged {
+		for _, port := range []string{next.Port, next.HTTPPort} {
+			if port == prev.Port || port == prev.HTTPPort {
+				continue
+			}
+			if err := checkPortFree(port); err != nil {
+				return nil, fmt.Errorf("port %s is unavailable: %w", port, err)
+			}
+		}
+	}
+	certsChanged := next.CertFile != prev.CertFile || next.KeyFile != prev.KeyFile
+	if certsChanged && hasCertFiles(next) {
+		if _, err := tls.LoadX509KeyPair(next.CertFile, next.KeyFile); err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+	}
+
+	app.applyRoomSettings(next)
+	app.config.YouTubeAPIKey = next.YouTubeAPIKey
+	app.syncRooms(next)
+
+	// TLS
+	if certsChanged || hasCertFiles(next) != hasCertFiles(prev) {
+		app.config.CertFile, app.config.KeyFile = next.CertFile, next.KeyFile
+		if app.certs != nil || hasCertFiles(next) {
+			if err := app.reloadTLS(); err != nil {
+				log.Printf("Failed to reload TLS: %v", err)
+			}
+		}
+	}
+
+	if next.MDNSHostname != prev.MDNSHostname {
+		app.config.MDNSHostname = next.MDNSHostname
+		app.reregisterMDNS()
+	}
+
+	if portsChanged {
+		app.config.Port, app.config.HTTPPort = next.Port, next.HTTPPort
+		if serving {
+			go app.restartServers(prev.Port, prev.HTTPPort)
+		}
+	}
+
+	if next.LogLevel != prev.LogLevel || next.LogSubsystems != prev.LogSubsystems || next.LogBuffer != prev.LogBuffer {
+		app.config.LogLevel, app.config.LogSubsystems, app.config.LogBuffer = next.LogLevel, next.LogSubsystems, next.LogBuffer
+		configureLogging(app.logs, next)
+	}
+
+	// Picked up by the next scheduled backup check
+	app.config.BackupEnabled = next.BackupEnabled
+	app.config.BackupIntervalHours = next.BackupIntervalHours
+	app.config.BackupKeep = next.BackupKeep
+
+	// Wired into components at startup
+	var restartNeeded []string
+	needsRestart := func(changed bool, key string) {
+		if changed {
+			restartNeeded = append(restartNeeded, key)
+		}
+	}
+	needsRestart(next.DataDir != prev.DataDir, "server.data_dir")
+	needsRestart(next.VideoPlayer != prev.VideoPlayer, "player.video_player")
+	needsRestart(next.PlayerBackend != prev.PlayerBackend, "player.backend")
+	needsRestart(next.WebDisplayKey != prev.WebDisplayKey, "player.web_display_key")
+	needsRestart(next.LocalCA && app.certs == nil && !hasCertFiles(next), "tls.local_ca")
+	needsRestart(next.LoudnessDecoder != prev.LoudnessDecoder, "loudness.decoder")
+	needsRestart(next.MicEffectsEnabled != prev.MicEffectsEnabled || next.MicDevice != prev.MicDevice, "mic")
+	needsRestart(next.RecordingEnabled != prev.RecordingEnabled || next.RecordingCommand != prev.RecordingCommand ||
+		next.RecordingExtension != prev.RecordingExtension || next.RecordingMaxMB != prev.RecordingMaxMB ||
+		next.RecordingRetentionDays != prev.RecordingRetentionDays || next.RecordingLinkHours != prev.RecordingLinkHours, "recording")
+	return restartNeeded, nil
+}
+
+// applyRoomSettings applies the settings every room has its own copy of:
+// admin PIN, display, features, loudness, transitions, holding screen and BGM
+func (app *App) applyRoomSettings(next Config) {
+	prev := app.config
+
+	if next.AdminPIN != prev.AdminPIN {
+		app.admin.SetPIN(next.AdminPIN)
+		log.Printf("Admin PIN changed - all non-local admin sessions have been invalidated")
+	}
+	app.config.AdminPIN = next.AdminPIN
+
+	// Display settings
+	app.config.TargetDisplay = next.TargetDisplay
+	app.config.AutoFullscreen = next.AutoFullscreen
+	app.applyDisplaySettings()
+
+	// Feature toggles
+	app.config.PitchControlEnabled = next.PitchControlEnabled
+	app.config.TempoControlEnabled = next.TempoControlEnabled
+	app.config.FairRotationEnabled = next.FairRotationEnabled
+	app.config.ScrollingTickerEnabled = next.ScrollingTickerEnabled
+	app.config.SingerNameOverlay = next.SingerNameOverlay
+	app.queue.SetFairRotation(next.FairRotationEnabled)
+	app.updateTicker()
+
+	// Loudness normalization and transitions apply from the next song
+	app.config.LoudnessNormalization = next.LoudnessNormalization
+	app.config.LoudnessTargetLUFS = next.LoudnessTargetLUFS
+	app.config.BGMCrossfade = next.BGMCrossfade
+	app.config.SongFadeOut = next.SongFadeOut
+	app.config.HoldingFadeIn = next.HoldingFadeIn
+	app.config.TrimSilence = next.TrimSilence
+	app.transitions.SetSettings(transitionSettings(next))
+
+	// Holding screen
+	if next.HoldingMessage != prev.HoldingMessage || next.HoldingTheme != prev.HoldingTheme {
+		app.holdingMessageMu.Lock()
+		app.holdingMessage = next.HoldingMessage
+		app.holdingMessageMu.Unlock()
+		app.config.HoldingMessage = next.HoldingMessage
+
+		if theme := app.holdingThemes.Get(next.HoldingTheme); theme == nil {
+			log.Printf("Warning: Holding screen theme %q not found", next.HoldingTheme)
+		} else if app.holdingScreen != nil {
+			app.holdingScreen.SetTheme(theme)
+			app.config.HoldingTheme = theme.ID
+		}
+		app.updateHoldingScreenIfIdle()
+	}
+
+	// Background music
+	if next.BGM != prev.BGM {
+		app.config.BGM = next.BGM
+		app.bgmSettings = next.BGM
+		if !next.BGM.Enabled && app.bgmActive {
+			app.stopBGM()
+		}
+		app.broadcastState()
+	}
+}
+
+// applyDisplaySettings passes the display settings to mpv so Restart Player uses them
+func (app *App) applyDisplaySettings() {
+	displaySettings := mpv.DisplaySettings{
+		TargetDisplay:  app.config.TargetDisplay,
+		ScreenIndex:    -1, // Default to auto
+		AutoFullscreen: app.config.AutoFullscreen,
+	}
+	// Resolve display name to screen index
+	if app.config.TargetDisplay != "" {
+		displays := getConnectedDisplays()
+		for i, d := range displays {
+			if d.Name == app.config.TargetDisplay {
+				displaySettings.ScreenIndex = i
+				log.Printf("Display settings updated: resolved '%s' to screen index %d", app.config.TargetDisplay, i)
+				break
+			}
+		}
+	}
+	app.mpv.SetDisplaySettings(displaySettings)
+}
+
+// reloadSettings re-reads the configuration file and applies it, as on SIGHUP or when the file changes
+func (app *App) reloadSettings() ([]string, error) {
+	next, err := loadConfig(app.config.ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	restartNeeded, err := app.applySettings(next)
+	if err != nil {
+		return nil, err
+	}
+	if len(restartNeeded) > 0 {
+		log.Printf("Settings reloaded; restart to apply %s", strings.Join(restartNeeded, ", "))
+	} else {
+		log.Println("Settings reloaded")
+	}
+	return restartNeeded, nil
+}
+
+// handleServerReload handles POST /api/admin/server/reload
+// Re-reads the configuration and certificate files without dropping anyone
+func (app *App) handleServerReload(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	restartNeeded, err := app.reloadSettings()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "ok",
+		"restart_needed": restartNeeded,
+	})
+}
+
+// handleServerRestart handles POST /api/admin/server/restart
+// Restarts the process; mpv keeps playing and clients reconnect on their own
+func (app *App) handleServerRestart(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":       "restarting",
+		"reconnect_ms": int(reconnectHint / time.Millisecond),
+	})
+	go app.restartProcess()
+}
+
+const (
+	logViewerLimit   = 500              // Entries returned by GET /api/admin/logs without ?limit
+	logStreamBacklog = 256              // Entries a slow log stream may fall behind before missing some
+	logStreamPing    = 15 * time.Second // Keeps idle log streams open through proxies
+)
+
+// LogLevels are the log levels as shown to and set from the admin panel
+type LogLevels struct {
+	Level      string            `json:"level"`
+	Subsystems map[string]string `json:"subsystems"`
+}
+
+// logLevels returns the current levels, lowercase like the configuration file
+func (app *App) logLevels() LogLevels {
+	levels := LogLevels{
+		Level:      strings.ToLower(app.logs.Levels.Default().String()),
+		Subsystems: make(map[string]string),
+	}
+	for name, level := range app.logs.Levels.Overrides() {
+		levels.Subsystems[name] = strings.ToLower(level.String())
+	}
+	return levels
+}
+
+// logFilter reads ?level=, ?subsystem= (comma-separated), ?q= and ?after= for the log viewer
+// Without ?level= everything down to debug matches
+func logFilter(query url.Values) (logging.Filter, error) {
+	filter := logging.Filter{MinLevel: slog.LevelDebug, Contains: query.Get("q")}
+	if level := query.Get("level"); level != "" {
+		parsed, err := logging.ParseLevel(level)
+		if err != nil {
+			return filter, err
+		}
+		filter.MinLevel = parsed
+	}
+	for _, name := range strings.Split(query.Get("subsystem"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			filter.Subsystems = append(filter.Subsystems, name)
+		}
+	}
+	if after := query.Get("after"); after != "" {
+		seq, err := strconv.ParseUint(after, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("after must be an entry sequence number")
+		}
+		filter.AfterSeq = seq
+	}
+	return filter, nil
+}
+
+// handleLogs handles GET /api/admin/logs
+// Returns recent entries matching the filter (see logFilter), oldest first, with the current levels
+func (app *App) handleLogs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := logFilter(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	limit := logViewerLimit
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+
+	entries := app.logs.Buffer.Entries(filter, limit)
+	if entries == nil {
+		entries = []logging.Entry{}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries":    entries,
+		"levels":     app.logLevels(),
+		"subsystems": app.logs.Subsystems(),
+	})
+}
+
+// handleLogStream handles GET /api/admin/logs/stream
+// Streams entries matching the filter as server-sent events, starting after ?after= when given
+// (so a viewer can fetch /api/admin/logs, then stream from its last entry without gaps)
+func (app *App) handleLogStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Streaming not supported"})
+		return
+	}
+	filter, err := logFilter(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Subscribe before reading the backlog so nothing logged in between is lost
+	live, stop := app.logs.Buffer.Subscribe(logStreamBacklog)
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	// Never log in here: each line would be streamed back and logged again
+	send := func(e logging.Entry) {
+		data, _ := json.Marshal(e)
+		fmt.Fprintf(w, "id: %d\ndata: %s\n\n", e.Seq, data)
+		filter.AfterSeq = e.Seq
+	}
+	if r.URL.Query().Has("after") {
+		for _, e := range app.logs.Buffer.Entries(filter, 0) {
+			send(e)
+		}
+	}
+	flusher.Flush()
+
+	ping := time.NewTicker(logStreamPing)
+	defer ping.Stop()
+	for {
+		select {
+		case e := <-live:
+			if filter.Match(e) {
+				send(e)
+				flusher.Flush()
+			}
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-app.stopped:
+			return
+		}
+	}
+}
+
+// handleLogLevels handles GET/PUT /api/admin/logs/levels
+// PUT {"level": "info", "subsystems": {"ws": "debug", "mpv": ""}} changes levels at once
+// and saves them to the configuration file; an empty subsystem level removes its override
+func (app *App) handleLogLevels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(app.logLevels())
+
+	case http.MethodPut:
+		var req LogLevels
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+			return
+		}
+
+		level := app.logs.Levels.Default()
+		overrides := app.logs.Levels.Overrides()
+		var err error
+		if req.Level != "" {
+			level, err = logging.ParseLevel(req.Level)
+		}
+		for name, value := range req.Subsystems {
+			if err != nil {
+				break
+			}
+			if name == "" || strings.ContainsAny(name, "=, ") {
+				err = fmt.Errorf("invalid subsystem name %q", name)
+				break
+			}
+			if value == "" {
+				delete(overrides, name)
+				continue
+			}
+			overrides[name], err = logging.ParseLevel(value)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		app.logs.Levels.Replace(level, overrides)
+		app.config.LogLevel = strings.ToLower(level.String())
+		app.config.LogSubsystems = logging.FormatOverrides(overrides)
+		if err := app.saveSettings(func(f *configfile.File) {
+			f.Logging.Level = app.config.LogLevel
+			f.Logging.Subsystems = app.config.LogSubsystems
+		}); err != nil {
+			log.Printf("Failed to save log levels: %v", err)
+		}
+		log.Printf("Log levels changed: level=%s subsystems=%q", app.config.LogLevel, app.config.LogSubsystems)
+		json.NewEncoder(w).Encode(app.logLevels())
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// SystemInfo represents system information
+type SystemInfo struct {
+	OS           string  `json:"os"`
+	Arch         string  `json:"arch"`
+	Hostname     string  `json:"hostname"`
+	CPUCount     int     `json:"cpu_count"`
+	MemoryTotal  uint64  `json:"memory_total"`
+	MemoryFree   uint64  `json:"memory_free"`
+	MemoryUsed   uint64  `json:"memory_used"`
+	DiskTotal    uint64  `json:"disk_total"`
+	DiskFree     uint64  `json:"disk_free"`
+	DiskUsed     uint64  `json:"disk_used"`
+	GoVersion    string  `json:"go_version"`
+	ServerUptime string  `json:"server_uptime"`
+	NetworkAddrs []string `json:"network_addrs"`
+}
+
+var serverStartTime = time.Now()
+
+// handleSystemInfo handles GET /api/admin/system-info
+func (app *App) handleSystemInfo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	hostname, _ := os.Hostname()
+	uptime := time.Since(serverStartTime).Round(time.Second).String()
+
+	info := SystemInfo{
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		Hostname:     hostname,
+		CPUCount:     runtime.NumCPU(),
+		GoVersion:    runtime.Version(),
+		ServerUptime: uptime,
+		NetworkAddrs: getNetworkAddresses(),
+	}
+
+	// Get memory info (platform-specific)
+	info.MemoryTotal, info.MemoryFree, info.MemoryUsed = getMemoryInfo()
+
+	// Get disk info
+	info.DiskTotal, info.DiskFree, info.DiskUsed = getDiskInfo(app.config.DataDir)
+
+	json.NewEncoder(w).Encode(info)
+}
+
+// getNetworkAddresses returns all network interface addresses
+func getNetworkAddresses() []string {
+	var addrs []string
+	for _, addr := range listNetworkAddresses() {
+		addrs = append(addrs, fmt.Sprintf("%s (%s)", addr.IP.String(), addr.Interface))
+	}
+	return addrs
+}
+
+// networkAddress is an address on one of this machine's interfaces
+type networkAddress struct {
+	IP        net.IP
+	Interface string
+}
+
+// listNetworkAddresses returns the addresses of up, non-loopback interfaces
+func listNetworkAddresses() []networkAddress {
+	var addrs []networkAddress
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return addrs
+	}
+
+	for _, iface := range ifaces {
+		// Skip loopback and down interfaces
+		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+
+		ifAddrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range ifAddrs {
+			var ip net.IP
+			switch v := addr.(type) {
+			case *net.IPNet:
+				ip = v.IP
+			case *net.IPAddr:
+				ip = v.IP
+			}
+
+			// Skip IPv6 link-local and loopback
+			if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+				continue
+			}
+
+			addrs = append(addrs, networkAddress{IP: ip, Interface: iface.Name})
+		}
+	}
+	return addrs
+}
+
+// getMemoryInfo returns memory statistics (cross-platform basic implementation)
+func getMemoryInfo() (total, free, used uint64) {
+	// This is a simplified implementation
+	// For more accurate info, consider using github.com/shirou/gopsutil
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	// These are Go runtime stats, not system-wide
+	// Return what we can
+	used = m.Sys // Total memory obtained from the OS
+	return 0, 0, used
+}
+
+// getDiskInfo returns disk statistics for a given path
+func getDiskInfo(path string) (total, free, used uint64) {
+	// Use syscall for disk info - works on Unix-like systems
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, 0
+	}
+
+	total = stat.Blocks * uint64(stat.Bsize)
+	free = stat.Bavail * uint64(stat.Bsize)
+	used = total - free
+	return
+}
+
+// NetworkInterface represents a network interface with its addresses
+type NetworkInterface struct {
+	Name         string   `json:"name"`
+	DisplayName  string   `json:"display_name"`
+	Type         string   `json:"type"`
+	MacAddress   string   `json:"mac_address"`
+	IPv4         []string `json:"ipv4"`
+	IPv6         []string `json:"ipv6"`
+	IsUp         bool     `json:"is_up"`
+	IsLoopback   bool     `json:"is_loopback"`
+	IsWireless   bool     `json:"is_wireless"`
+	ConnectURLs  []string `json:"connect_urls"`
+}
+
+// handleNetworkEnumeration handles GET /api/admin/networks
+func (app *App) handleNetworkEnumeration(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	var result []NetworkInterface
+	for _, iface := range interfaces {
+		ni := NetworkInterface{
+			Name:       iface.Name,
+			MacAddress: iface.HardwareAddr.String(),
+			IsUp:       iface.Flags&net.FlagUp != 0,
+			IsLoopback: iface.Flags&net.FlagLoopback != 0,
+			IPv4:       []string{},
+			IPv6:       []string{},
+			ConnectURLs: []string{},
+		}
+
+		// Generate display name
+		ni.DisplayName = getInterfaceDisplayName(iface.Name)
+		ni.Type = getInterfaceType(iface.Name)
+		ni.IsWireless = isWirelessInterface(iface.Name)
+
+		// Get addresses
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			var ip net.IP
+			switch v := addr.(type) {
+			case *net.IPNet:
+				ip = v.IP
+			case *net.IPAddr:
+				ip = v.IP
+			}
+
+			if ip == nil || ip.IsLinkLocalUnicast() {
+				continue
+			}
+
+			if ip.To4() != nil {
+				ni.IPv4 = append(ni.IPv4, ip.String())
+				// Generate connect URL for non-loopback IPv4
+				if !ni.IsLoopback {
+					connectURL := fmt.Sprintf("https://%s:%s", ip.String(), app.config.Port)
+					ni.ConnectURLs = append(ni.ConnectURLs, connectURL)
+				}
+			} else {
+				ni.IPv6 = append(ni.IPv6, ip.String())
+			}
+		}
+
+		// Only include interfaces that are up and have addresses
+		if ni.IsUp && (len(ni.IPv4) > 0 || len(ni.IPv6) > 0) {
+			result = append(result, ni)
+		}
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// getInterfaceDisplayName returns a friendly name for the interface
+func getInterfaceDisplayName(name string) string {
+	// Common interface name patterns
+	switch {
+	case strings.HasPrefix(name, "en"):
+		if strings.HasPrefix(name, "en0") {
+			return "Wi-Fi / Ethernet (Primary)"
+		}
+		return "Ethernet"
+	case strings.HasPrefix(name, "wlan") || strings.HasPrefix(name, "wlp"):
+		return "Wi-Fi"
+	case strings.HasPrefix(name, "eth"):
+		return "Ethernet"
+	case strings.HasPrefix(name, "lo"):
+		return "Loopback"
+	case strings.HasPrefix(name, "docker"):
+		return "Docker Network"
+	case strings.HasPrefix(name, "br-"):
+		return "Bridge Network"
+	case strings.HasPrefix(name, "veth"):
+		return "Virtual Ethernet"
+	case strings.HasPrefix(name, "utun"):
+		return "VPN Tunnel"
+	case strings.HasPrefix(name, "tun") || strings.HasPrefix(name, "tap"):
+		return "VPN"
+	case strings.HasPrefix(name, "awdl"):
+		return "Apple Wireless Direct Link"
+	case strings.HasPrefix(name, "bridge"):
+		return "Network Bridge"
+	default:
+		return name
+	}
+}
+
+// getInterfaceType returns the type of interface
+func getInterfaceType(name string) string {
+	switch {
+	case strings.HasPrefix(name, "wlan") || strings.HasPrefix(name, "wlp"):
+		return "wireless"
+	case strings.HasPrefix(name, "en0") && runtime.GOOS == "darwin":
+		return "wifi_or_ethernet"
+	case strings.HasPrefix(name, "en") || strings.HasPrefix(name, "eth"):
+		return "ethernet"
+	case strings.HasPrefix(name, "lo"):
+		return "loopback"
+	case strings.HasPrefix(name, "docker") || strings.HasPrefix(name, "br-") || strings.HasPrefix(name, "veth"):
+		return "virtual"
+	case strings.HasPrefix(name, "utun") || strings.HasPrefix(name, "tun") || strings.HasPrefix(name, "tap"):
+		return "vpn"
+	default:
+		return "other"
+	}
+}
+
+// isWirelessInterface checks if the interface is wireless
+func isWirelessInterface(name string) bool {
+	return strings.HasPrefix(name, "wlan") ||
+		strings.HasPrefix(name, "wlp") ||
+		(strings.HasPrefix(name, "en0") && runtime.GOOS == "darwin") // macOS primary is often Wi-Fi
+}
+
+// getConnectURL returns the admin-selected connection URL, or an auto-detected one
+func (app *App) getConnectURL() string {
+	data, err := os.ReadFile(filepath.Join(app.config.DataDir, "connect_url.txt"))
+	if err == nil && len(data) > 0 {
+		return strings.TrimSpace(string(data))
+	}
+
+	// Auto-detect: find first non-loopback IPv4 address
+	return app.autoDetectConnectURL()
+}
+
+// handleConnectURL handles GET/POST /api/connect-url
+// GET returns the selected connection URL for QR codes
+// POST (admin only) sets the preferred connection URL
+func (app *App) handleConnectURL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	connectURLFile := filepath.Join(app.config.DataDir, "connect_url.txt")
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]string{"url": app.getConnectURL()})
+
+	case http.MethodPost:
+		// Check admin auth for POST
+		if !app.admin.IsAuthorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+			return
+		}
+
+		var req struct {
+			URL string `json:"url"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+			return
+		}
+
+		// Save to file
+		if err := os.WriteFile(connectURLFile, []byte(req.URL), 0644); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok", "url": req.URL})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// autoDetectConnectURL finds the best connection URL
+// If mDNS is configured, returns the .local hostname instead of IP
+func (app *App) autoDetectConnectURL() string {
+	// Use mDNS hostname if configured and server is running
+	if app.config.MDNSHostname != "" && app.mdnsServer != nil {
+		return fmt.Sprintf("https://%s.local:%s", app.config.MDNSHostname, app.config.Port)
+	}
+
+	// Fallback to IP-based detection
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return fmt.Sprintf("https://localhost:%s", app.config.Port)
+	}
+
+	for _, iface := range interfaces {
+		// Skip loopback and down interfaces
+		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			var ip net.IP
+			switch v := addr.(type) {
+			case *net.IPNet:
+				ip = v.IP
+			case *net.IPAddr:
+				ip = v.IP
+			}
+
+			// Only IPv4, non-loopback
+			if ip != nil && ip.To4() != nil && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() {
+				return fmt.Sprintf("https://%s:%s", ip.String(), app.config.Port)
+			}
+		}
+	}
+
+	return fmt.Sprintf("https://localhost:%s", app.config.Port)
+}
+
+// handleMDNS handles GET/POST /api/admin/mdns
+// GET returns current mDNS settings
+// POST updates the mDNS hostname and restarts the service
+func (app *App) handleMDNS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		// Return current mDNS settings
+		hostname := app.config.MDNSHostname
+		enabled := app.mdnsServer != nil
+		var mdnsURL string
+		if enabled && hostname != "" {
+			mdnsURL = fmt.Sprintf("https://%s.local:%s", hostname, app.config.Port)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"hostname": hostname,
+			"enabled":  enabled,
+			"url":      mdnsURL,
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Hostname string `json:"hostname"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid request"}`, http.StatusBadRequest)
+			return
+		}
+
+		// Validate hostname (alphanumeric and hyphens only, no leading/trailing hyphens)
+		hostname := strings.ToLower(strings.TrimSpace(req.Hostname))
+		if hostname != "" {
+			// Basic validation for mDNS hostname
+			for _, c := range hostname {
+				if !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-') {
+					http.Error(w, `{"error":"hostname can only contain letters, numbers, and hyphens"}`, http.StatusBadRequest)
+					return
+				}
+			}
+			if strings.HasPrefix(hostname, "-") || strings.HasSuffix(hostname, "-") {
+				http.Error(w, `{"error":"hostname cannot start or end with a hyphen"}`, http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Stop existing mDNS server if running
+		if app.mdnsServer != nil {
+			app.mdnsServer.Shutdown()
+			app.mdnsServer = nil
+			log.Println("mDNS server stopped for reconfiguration")
+		}
+
+		// Update config
+		app.config.MDNSHostname = hostname
+		if err := app.saveSettings(func(f *configfile.File) { f.Server.MDNSHostname = hostname }); err != nil {
+			log.Printf("Warning: Failed to save mDNS hostname: %v", err)
+		}
+
+		// Start new mDNS server if hostname is set
+		if hostname != "" {
+			portNum, _ := strconv.Atoi(app.config.Port)
+			mdnsServer, err := zeroconf.Register(
+				hostname,
+				"_https._tcp",
+				"local.",
+				portNum,
+				[]string{"txtv=0", "lo=1", "path=/"},
+				nil,
+			)
+			if err != nil {
+				log.Printf("Failed to start mDNS server: %v", err)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"success": false,
+					"error":   err.Error(),
+				})
+				return
+			}
+			app.mdnsServer = mdnsServer
+			log.Printf("mDNS: Now advertising as %s.local:%d", hostname, portNum)
+		}
+
+		// The certificate has to cover the new name
+		app.refreshCertificate()
+
+		// Refresh holding screen to show new URL
+		app.showHoldingScreen()
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"hostname": hostname,
+			"url":      fmt.Sprintf("https://%s.local:%s", hostname, app.config.Port),
+		})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePlayer handles GET/POST /api/admin/player
+func (app *App) handlePlayer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		// Return player status
+		status := map[string]interface{}{
+			"is_running": app.mpv.IsRunning(),
+			"backend":    "mpv",
+		}
+		if app.webDisplay != nil {
+			status["backend"] = "web"
+			status["display_url"] = app.webDisplay.PageURL()
+			status["connected_pages"] = app.webDisplay.ConnectedPages()
+		}
+		json.NewEncoder(w).Encode(status)
+
+	case http.MethodPost:
+		// Launch or restart the player
+		var req struct {
+			Action string `json:"action"` // "launch" or "restart"
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		if req.Action == "" {
+			req.Action = "launch"
+		}
+
+		var err error
+		switch req.Action {
+		case "restart":
+			err = app.restartPlayer()
+		case "launch":
+			if app.mpv.IsRunning() {
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"status":     "ok",
+					"message":    "Player is already running",
+					"is_running": true,
+				})
+				return
+			}
+			err = app.startPlayer()
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid action"})
+			return
+		}
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to " + req.Action + " player: " + err.Error()})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":     "ok",
+			"message":    "Player " + req.Action + "ed successfully",
+			"is_running": app.mpv.IsRunning(),
+		})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// outputsFile returns the path of the saved extra output configuration
+func (app *App) outputsFile() string {
+	return filepath.Join(app.config.DataDir, "outputs.json")
+}
+
+// resolveOutputScreen fills in the screen index for an output targeting a display by name
+func resolveOutputScreen(cfg *mpv.OutputConfig) {
+	if cfg.TargetDisplay == "" {
+		return
+	}
+	for i, d := range getConnectedDisplays() {
+		if d.Name == cfg.TargetDisplay {
+			cfg.ScreenIndex = i
+			return
+		}
+	}
+	log.Printf("Warning: Output '%s' display '%s' not found, using screen %d", cfg.Name, cfg.TargetDisplay, cfg.ScreenIndex)
+}
+
+// startOutputs starts the saved extra output screens and the position sync loop
+func (app *App) startOutputs() {
+	data, err := os.ReadFile(app.outputsFile())
+	if err == nil {
+		var configs []mpv.OutputConfig
+		if err := json.Unmarshal(data, &configs); err != nil {
+			log.Printf("Warning: Invalid outputs.json: %v", err)
+		}
+		for _, cfg := range configs {
+			resolveOutputScreen(&cfg)
+			if err := app.outputs.Add(cfg); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+	}
+	app.outputs.StartSync(mpv.DefaultSyncInterval)
+}
+
+// saveOutputs persists the extra output configuration
+func (app *App) saveOutputs() error {
+	data, err := json.MarshalIndent(app.outputs.Configs(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(app.outputsFile(), data, 0644)
+}
+
+// handleOutputs handles GET/POST /api/admin/outputs
+// GET lists extra output screens; POST adds one
+func (app *App) handleOutputs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"outputs":  app.outputs.Status(),
+			"displays": getConnectedDisplays(),
+		})
+
+	case http.MethodPost:
+		cfg := mpv.OutputConfig{ScreenIndex: -1, AutoFullscreen: true}
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+			return
+		}
+		if err := cfg.Validate(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		resolveOutputScreen(&cfg)
+
+		if err := app.outputs.Register(cfg); err != nil {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if err := app.saveOutputs(); err != nil {
+			log.Printf("Failed to save outputs: %v", err)
+		}
+
+		// A failed start keeps the output registered so it can be restarted later
+		if err := app.outputs.Start(cfg.Name); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		// Bring the new screen up to date with what's on the primary
+		if app.idle {
+			app.showHoldingScreen()
+		} else if song := app.queue.Current(); song != nil {
+			app.outputs.LoadSong(song.VideoURL, song.CDGPath)
+		}
+
+		log.Printf("Added %s output '%s' on screen %d", cfg.Role, cfg.Name, cfg.ScreenIndex)
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "outputs": app.outputs.Status()})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleOutputAction handles /api/admin/outputs/{name} and /api/admin/outputs/{name}/restart
+// DELETE removes an output; POST .../restart restarts its player
+func (app *App) handleOutputAction(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	parts := splitPath(strings.TrimPrefix(r.URL.Path, "/api/admin/outputs/"))
+	if len(parts) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	name := parts[0]
+
+	var err error
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		if err = app.outputs.Remove(name); err == nil {
+			if saveErr := app.saveOutputs(); saveErr != nil {
+				log.Printf("Failed to save outputs: %v", saveErr)
+			}
+		}
+	case len(parts) == 2 && parts[1] == "restart" && r.Method == http.MethodPost:
+		if err = app.outputs.Restart(name); err == nil && app.idle {
+			app.showHoldingScreen()
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "outputs": app.outputs.Status()})
+}
+
+// handleDatabase handles GET/POST /api/admin/database - database management
+func (app *App) handleDatabase(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		// Return database stats
+		queueState := app.queue.GetState()
+		stats := map[string]interface{}{
+			"sessions": map[string]interface{}{
+				"count": app.sessions.GetSessionCount(),
+			},
+			"blocked_users": map[string]interface{}{
+				"count": app.sessions.GetBlockedUserCount(),
+			},
+			"search_logs": map[string]interface{}{
+				"count": app.library.GetSearchLogCount(),
+			},
+			"song_history": map[string]interface{}{
+				"count": app.library.GetSongHistoryCount(),
+			},
+			"queue": map[string]interface{}{
+				"count": len(queueState.Songs),
+			},
+		}
+		json.NewEncoder(w).Encode(stats)
+
+	case http.MethodPost:
+		// Perform database operation
+		var req struct {
+			Action string `json:"action"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+			return
+		}
+
+		var err error
+		var message string
+
+		switch req.Action {
+		case "flush_sessions":
+			err = app.sessions.FlushSessions()
+			message = "All sessions cleared"
+			// Disconnect all WebSocket clients
+			app.hub.DisconnectAll("Session data cleared by admin")
+
+		case "flush_blocked":
+			err = app.sessions.FlushBlockedUsers()
+			message = "All blocked users cleared"
+
+		case "flush_queue":
+			app.queue.Clear()
+			message = "Queue cleared"
+
+		case "flush_search_logs":
+			err = app.library.ClearSearchLogs()
+			message = "Search logs cleared"
+
+		case "flush_song_history":
+			err = app.library.ClearSongHistory()
+			message = "Song history cleared"
+
+		case "flush_all":
+			// Flush everything except library songs
+			app.sessions.FlushSessions()
+			app.sessions.FlushBlockedUsers()
+			app.queue.Clear()
+			app.library.ClearSearchLogs()
+			app.library.ClearSongHistory()
+			app.library.ClearSongSelections()
+			app.hub.DisconnectAll("All data cleared by admin")
+			message = "All data cleared (library songs preserved)"
+
+		case "vacuum":
+			var freed int64
+			freed, err = backup.Vacuum(r.Context(), backupSet(app.config))
+			message = fmt.Sprintf("Databases compacted, %.1f MB freed", float64(freed)/(1<<20))
+
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Unknown action: " + req.Action})
+			return
+		}
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":  "ok",
+			"message": message,
+		})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// backupCheckInterval is how often the scheduler looks for a backup that's due
+const backupCheckInterval = 10 * time.Minute
+
+// maxRestoreUpload caps backup archives uploaded for a restore
+const maxRestoreUpload = 1 << 30
+
+// backupDir is where backups are kept
+func backupDir(config Config) string {
+	return filepath.Join(config.DataDir, "backups")
+}
+
+// backupSet is everything a backup covers: every database (each room's queue too), the output screens and the configuration file
+func backupSet(config Config) backup.Set {
+	db := func(name string, version int) backup.Database {
+		return backup.Database{Name: name, Path: filepath.Join(config.DataDir, name), Version: version}
+	}
+	set := backup.Set{
+		Databases: []backup.Database{
+			db("sessions.db", session.SchemaVersion),
+			db("queue.db", queue.SchemaVersion),
+			db("library.db", library.SchemaVersion),
+			db("playlists.db", playlist.SchemaVersion),
+			db("recordings.db", recording.SchemaVersion),
+		},
+		Files: []backup.File{{Name: "outputs.json", Path: filepath.Join(config.DataDir, "outputs.json")}},
+	}
+	if config.ConfigPath != "" {
+		set.Files = append(set.Files, backup.File{Name: "songmartyn.toml", Path: config.ConfigPath})
+	}
+
+	// Extra rooms' queues, including rooms since removed whose queue is still kept
+	rooms := make(map[string]bool)
+	for _, r := range config.Rooms {
+		rooms[r.ID] = true
+	}
+	if dirs, err := os.ReadDir(filepath.Join(config.DataDir, "rooms")); err == nil {
+		for _, dir := range dirs {
+			if dir.IsDir() {
+				rooms[dir.Name()] = true
+			}
+		}
+	}
+	ids := make([]string, 0, len(rooms))
+	for id := range rooms {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		set.Databases = append(set.Databases, db("rooms/"+id+"/queue.db", queue.SchemaVersion))
+	}
+	return set
+}
+
+// runBackups takes a backup whenever the newest is older than the configured interval
+// Going by the newest archive means restarts don't each take one
+func (app *App) runBackups() {
+	for {
+		app.backupIfDue(time.Now())
+		select {
+		case <-app.stopped:
+			return
+		case <-time.After(backupCheckInterval):
+		}
+	}
+}
+
+// backupIfDue takes a scheduled backup if the newest is older than the interval
+func (app *App) backupIfDue(now time.Time) {
+	if !app.config.BackupEnabled || app.config.BackupIntervalHours <= 0 {
+		return
+	}
+	backups, err := backup.List(backupDir(app.config))
+	if err != nil {
+		log.Printf("Failed to list backups: %v", err)
+		return
+	}
+	interval := time.Duration(app.config.BackupIntervalHours * float64(time.Hour))
+	if len(backups) > 0 && now.Sub(backups[0].CreatedAt) < interval {
+		return
+	}
+	if _, err := app.createBackup(context.Background()); err != nil {
+		log.Printf("Scheduled backup failed: %v", err)
+	}
+}
+
+// createBackup writes a backup, then deletes the oldest beyond BackupKeep
+func (app *App) createBackup(ctx context.Context) (backup.Info, error) {
+	app.backupMu.Lock()
+	defer app.backupMu.Unlock()
+
+	dir := backupDir(app.config)
+	info, err := backup.Save(ctx, dir, backupSet(app.config))
+	if err != nil {
+		return info, err
+	}
+	log.Printf("Backup written: %s (%d bytes)", info.Name, info.Size)
+
+	removed, err := backup.Rotate(dir, app.config.BackupKeep)
+	if err != nil {
+		log.Printf("Failed to delete old backups: %v", err)
+	}
+	for _, name := range removed {
+		log.Printf("Deleted old backup %s", name)
+	}
+	return info, nil
+}
+
+// handleBackups handles GET/POST /api/admin/backups
+// GET lists backups (newest first) and the schedule, POST takes a backup now
+func (app *App) handleBackups(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		backups, err := backup.List(backupDir(app.config))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"backups":        backups,
+			"enabled":        app.config.BackupEnabled,
+			"interval_hours": app.config.BackupIntervalHours,
+			"keep":           app.config.BackupKeep,
+		})
+
+	case http.MethodPost:
+		info, err := app.createBackup(r.Context())
+		if err != nil {
+			log.Printf("Backup failed: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "backup": info})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleBackupFile handles GET/DELETE /api/admin/backups/{name}
+// GET downloads the archive, DELETE removes it
+func (app *App) handleBackupFile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	name := strings.TrimPrefix(r.URL.Path, "/api/admin/backups/")
+	path, err := backup.Path(backupDir(app.config), name)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		http.ServeFile(w, r, path)
+
+	case http.MethodDelete:
+		if err := os.Remove(path); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Deleted backup %s", name)
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleBackupRestore handles POST /api/admin/backups/restore
+// Restores a kept backup (?name=) or an archive uploaded as the request body. The archive
+// is checked before anything changes; if it's good the server hot restarts to swap it in.
+func (app *App) handleBackupRestore(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var archive io.Reader = http.MaxBytesReader(w, r.Body, maxRestoreUpload)
+	if name := r.URL.Query().Get("name"); name != "" {
+		path, err := backup.Path(backupDir(app.config), name)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		defer f.Close()
+		archive = f
+	}
+
+	app.backupMu.Lock()
+	manifest, err := backup.Stage(archive, app.config.DataDir, backupSet(app.config))
+	app.backupMu.Unlock()
+	if err != nil {
+		log.Printf("Restore rejected: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	log.Printf("Restoring backup taken %s", manifest.CreatedAt.Format(time.RFC1123))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":       "restoring",
+		"created_at":   manifest.CreatedAt,
+		"reconnect_ms": int(reconnectHint / time.Millisecond),
+	})
+	go app.restart()
+}
+
+// handleBGM handles GET/POST /api/admin/bgm - background music settings
+func (app *App) handleBGM(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		// Return current BGM settings
+		json.NewEncoder(w).Encode(app.bgmSettings)
+
+	case http.MethodPost:
+		// Update BGM settings
+		var settings models.BGMSettings
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+			return
+		}
+
+		// Default to YouTube if source type not specified
+		if settings.SourceType == "" {
+			settings.SourceType = models.BGMSourceYouTube
+		}
+
+		// Update settings
+		app.bgmSettings = settings
+		app.config.BGM = settings
+		log.Printf("BGM settings updated: enabled=%v, source=%s, url=%s, volume=%.0f",
+			settings.Enabled, settings.SourceType, settings.URL, settings.Volume)
+
+		if err := app.saveSettings(func(f *configfile.File) {
+			f.BGM = configfile.BGM{
+				Enabled: settings.Enabled,
+				Source:  string(settings.SourceType),
+				URL:     settings.URL,
+				Volume:  settings.Volume,
+			}
+		}); err != nil {
+			log.Printf("Warning: Failed to save BGM settings: %v", err)
+		}
+
+		// If BGM was disabled, stop any active BGM
+		if !settings.Enabled && app.bgmActive {
+			app.stopBGM()
+		}
+
+		// Always broadcast state so clients get updated bgm_enabled flag
+		app.broadcastState()
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":   "ok",
+			"settings": app.bgmSettings,
+		})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// LoudnessSettings is the admin view of loudness normalization
+type LoudnessSettings struct {
+	Enabled    bool                `json:"enabled"`
+	TargetLUFS float64             `json:"target_lufs"`
+	Analysis   *loudness.JobStatus `json:"analysis,omitempty"`
+}
+
+// handleLoudness handles GET/POST /api/admin/loudness - normalization settings and analysis progress
+func (app *App) handleLoudness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		status := app.loudnessJob.Status()
+		json.NewEncoder(w).Encode(LoudnessSettings{
+			Enabled:    app.config.LoudnessNormalization,
+			TargetLUFS: app.config.LoudnessTargetLUFS,
+			Analysis:   &status,
+		})
+
+	case http.MethodPost:
+		var settings LoudnessSettings
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+			return
+		}
+		if settings.TargetLUFS < -40 || settings.TargetLUFS > -5 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "target_lufs must be between -40 and -5"})
+			return
+		}
+
+		// Takes effect from the next song
+		app.config.LoudnessNormalization = settings.Enabled
+		app.config.LoudnessTargetLUFS = settings.TargetLUFS
+		log.Printf("Loudness settings updated: enabled=%v, target=%.1f LUFS", settings.Enabled, settings.TargetLUFS)
+
+		if err := app.saveSettings(func(f *configfile.File) {
+			f.Loudness.Normalization = settings.Enabled
+			f.Loudness.TargetLUFS = settings.TargetLUFS
+		}); err != nil {
+			log.Printf("Warning: Failed to save loudness settings: %v", err)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":   "ok",
+			"settings": settings,
+		})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleLoudnessAnalyze handles POST /api/admin/loudness/analyze - measure unanalyzed songs
+// With ?all=true every song is measured again
+func (app *App) handleLoudnessAnalyze(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if app.loudnessJob.Status().Running {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": loudness.ErrJobRunning.Error()})
+		return
+	}
+
+	if err := app.loudnessJob.Available(); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if r.URL.Query().Get("all") == "true" {
+		if err := app.library.ResetLoudness(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	if err := app.loudnessJob.Start(); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, loudness.ErrJobRunning) {
+			status = http.StatusConflict
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "ok",
+		"analysis": app.loudnessJob.Status(),
+	})
+}
+
+// handleRecordingDownload handles GET /api/recordings/{token} - download a performance recording
+func (app *App) handleRecordingDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeError := func(status int, msg string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": msg})
+	}
+	if app.recorder == nil {
+		writeError(http.StatusNotFound, "Recording is not enabled")
+		return
+	}
+
+	token := strings.TrimPrefix(r.URL.Path, recording.DownloadPath)
+	rec, path, err := app.recorder.Resolve(token)
+	switch {
+	case errors.Is(err, recording.ErrLinkExpired):
+		writeError(http.StatusGone, "Download link expired")
+		return
+	case err != nil:
+		writeError(http.StatusNotFound, "Recording not found")
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		writeError(http.StatusNotFound, "Recording not found")
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		writeError(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	name := rec.SongTitle
+	if rec.SongArtist != "" {
+		name = rec.SongArtist + " - " + name
+	}
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 32 {
+			return '_'
+		}
+		return r
+	}, name) + filepath.Ext(path)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// handleAdminRecordings handles GET/DELETE /api/admin/recordings - list or delete (?id=) recordings
+func (app *App) handleAdminRecordings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if app.recorder == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Recording is not enabled"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		recs, err := app.recorder.List()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if recs == nil {
+			recs = []recording.Recording{}
+		}
+		var total int64
+		for _, rec := range recs {
+			total += rec.SizeBytes
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"recordings":  recs,
+			"total_bytes": total,
+			"max_bytes":   int64(app.config.RecordingMaxMB * 1024 * 1024),
+			"recording":   app.recorder.Recording(),
+		})
+
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid recording ID"})
+			return
+		}
+		if err := app.recorder.Delete(id); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, recording.ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleHoldingMessage handles GET /api/admin/holding-message - get current holding screen message
+func (app *App) handleHoldingMessage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	app.holdingMessageMu.RLock()
+	message := app.holdingMessage
+	app.holdingMessageMu.RUnlock()
+
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// maxThemeUpload caps theme uploads; zipped themes can carry a background video
+const maxThemeUpload = holdingscreen.MaxThemeBytes + 1<<20
+
+// handleHoldingThemes handles /api/admin/holding-themes
+// GET lists the themes, POST uploads a .json or .zip theme in the "theme" form field, DELETE ?id= removes one
+func (app *App) handleHoldingThemes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if app.holdingScreen == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Holding screen is not available"})
+		return
+	}
+	active := app.holdingScreen.Theme().ID
+
+	switch r.Method {
+	case http.MethodGet:
+		themes := []holdingscreen.ThemeInfo{}
+		for _, theme := range app.holdingThemes.List() {
+			info := theme.Info()
+			info.Active = theme.ID == active
+			themes = append(themes, info)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"themes": themes,
+			"active": active,
+		})
+
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, maxThemeUpload)
+		file, header, err := r.FormFile("theme")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Upload a theme file in the \"theme\" field"})
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Theme upload is too large or incomplete"})
+			return
+		}
+
+		theme, err := app.holdingThemes.Install(header.Filename, data)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Holding screen theme %q uploaded", theme.ID)
+
+		// Replacing the theme on screen shows the new version straight away
+		if theme.ID == active {
+			app.setHoldingTheme(theme)
+		}
+		info := theme.Info()
+		info.Active = theme.ID == active
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "ok",
+			"theme":  info,
+		})
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if err := app.holdingThemes.Delete(id); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, holdingscreen.ErrThemeNotFound) {
+				status = http.StatusNotFound
+			}
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Holding screen theme %q deleted", id)
+		if id == active {
+			app.setHoldingTheme(holdingscreen.DefaultTheme())
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleHoldingThemeActive handles POST /api/admin/holding-themes/active - switch the holding screen theme
+func (app *App) handleHoldingThemeActive(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if app.holdingScreen == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Holding screen is not available"})
+		return
+	}
+
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+		return
+	}
+	theme := app.holdingThemes.Get(req.ID)
+	if theme == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Theme not found"})
+		return
+	}
+
+	app.setHoldingTheme(theme)
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "active": theme.ID})
+}
+
+// setHoldingTheme switches the holding screen theme, saves the choice and redraws the screen
+func (app *App) setHoldingTheme(theme *holdingscreen.Theme) {
+	app.holdingScreen.SetTheme(theme)
+	app.config.HoldingTheme = theme.ID
+	log.Printf("Holding screen theme set to %q", theme.ID)
+
+	if err := app.saveSettings(func(f *configfile.File) { f.Holding.Theme = theme.ID }); err != nil {
+		log.Printf("Warning: Failed to save holding theme: %v", err)
+	}
+
+	app.updateHoldingScreenIfIdle()
+}
+
+// handleIcecastStreams handles GET /api/admin/icecast-streams - returns popular Icecast music streams
+func (app *App) handleIcecastStreams(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Curated list of popular music-only Icecast streams (no presenters/talk)
+	// These are reliable, high-quality streams focused on instrumental/background music
+	streams := []models.IcecastStream{
+		// SomaFM streams - excellent quality, no ads, instrumental/ambient
+		{
+			Name:        "SomaFM - Groove Salad",
+			URL:         "https://ice1.somafm.com/groovesalad-128-mp3",
+			Genre:       "Ambient/Downtempo",
+			Description: "Chill ambient/downtempo beats, perfect background music",
+			Bitrate:     128,
+			Format:      "MP3",
+		},
+		{
+			Name:        "SomaFM - Drone Zone",
+			URL:         "https://ice1.somafm.com/dronezone-128-mp3",
+			Genre:       "Ambient/Space",
+			Description: "Ambient soundscapes for space exploration",
+			Bitrate:     128,
+			Format:      "MP3",
+		},
+		{
+			Name:        "SomaFM - Space Station",
+			URL:         "https://ice1.somafm.com/spacestation-128-mp3",
+			Genre:       "Ambient/Electronic",
+			Description: "Spaced-out ambient and mid-tempo electronic",
+			Bitrate:     128,
+			Format:      "MP3",
+		},
+		{
+			Name:        "SomaFM - Deep Space One",
+			URL:         "https://ice1.somafm.com/deepspaceone-128-mp3",
+			Genre:       "Ambient/Experimental",
+			Description: "Deep ambient electronic exploration",
+			Bitrate:     128,
+			Format:      "MP3",
+		},
+		{
+			Name:        "SomaFM - Suburbs of Goa",
+			URL:         "https://ice1.somafm.com/suburbsofgoa-128-mp3",
+			Genre:       "World/Ambient",
+			Description: "Desi-influenced Asian world beats and beyond",
+			Bitrate:     128,
+			Format:      "MP3",
+		},
+		{
+			Name:        "SomaFM - Lush",
+			URL:         "https://ice1.somafm.com/lush-128-mp3",
+			Genre:       "Electronic/Downtempo",
+			Description: "Sensuous, mellow electronic vocals",
+			Bitrate:     128,
+			Format:      "MP3",
+		},
+		{
+			Name:        "SomaFM - Boot Liquor",
+			URL:         "https://ice1.somafm.com/bootliquor-128-mp3",
+			Genre:       "Americana/Country",
+			Description: "Americana roots music for dusty back roads",
+			Bitrate:     128,
+			Format:      "MP3",
+		},
+		{
+			Name:        "SomaFM - Secret Agent",
+			URL:         "https://ice1.somafm.com/secretagent-128-mp3",
+			Genre:       "Lounge/Jazz",
+			Description: "Spy-themed lounge, exotica and jazz",
+			Bitrate:     128,
+			Format:      "MP3",
+		},
+		{
+			Name:        "SomaFM - Illinois Street Lounge",
+			URL:         "https://ice1.somafm.com/illstreet-128-mp3",
+			Genre:       "Lounge/Exotica",
+			Description: "Classic bachelor pad, retro-modern exotica",
+			Bitrate:     128,
+			Format:      "MP3",
+		},
+		{
+			Name:        "SomaFM - Cliqhop IDM",
+			URL:         "https://ice1.somafm.com/cliqhop-128-mp3",
+			Genre:       "Electronic/IDM",
+			Description: "Blips and bleeps with a beat",
+			Bitrate:     128,
+			Format:      "MP3",
+		},
+		// Radio Paradise - high quality eclectic mix
+		{
+			Name:        "Radio Paradise - Main Mix",
+			URL:         "https://stream.radioparadise.com/aac-128",
+			Genre:       "Eclectic/Rock",
+			Description: "Eclectic mix of rock, world, and electronic",
+			Bitrate:     128,
+			Format:      "AAC",
+		},
+		{
+			Name:        "Radio Paradise - Mellow",
+			URL:         "https://stream.radioparadise.com/mellow-128",
+			Genre:       "Mellow/Acoustic",
+			Description: "Softer, acoustic selections",
+			Bitrate:     128,
+			Format:      "AAC",
+		},
+		// Jazz streams
+		{
+			Name:        "SomaFM - Jazz Riot",
+			URL:         "https://ice1.somafm.com/jazzriot-128-mp3",
+			Genre:       "Jazz",
+			Description: "Jazz from the classic era to modern",
+			Bitrate:     128,
+			Format:      "MP3",
+		},
+		// Classical
+		{
+			Name:        "WCPE Classical",
+			URL:         "https://audio-ogg.ibiblio.org:8000/wcpe.ogg",
+			Genre:       "Classical",
+			Description: "24/7 classical music, no interruptions",
+			Bitrate:     128,
+			Format:      "OGG",
+		},
+		// Chillout/Lo-fi
+		{
+			Name:        "SomaFM - DEF CON Radio",
+			URL:         "https://ice1.somafm.com/defcon-128-mp3",
+			Genre:       "Electronic/Hacker",
+			Description: "Music for hacking - electronic and synth",
+			Bitrate:     128,
+			Format:      "MP3",
+		},
+		{
+			Name:        "SomaFM - Beat Blender",
+			URL:         "https://ice1.somafm.com/beatblender-128-mp3",
+			Genre:       "Electronic/Trip-hop",
+			Description: "Deep house and downtempo chill",
+			Bitrate:     128,
+			Format:      "MP3",
+		},
+	}
+
+	json.NewEncoder(w).Encode(streams)
+}
+
+// handleBrowseDirs handles GET /api/admin/browse-dirs - list directories for file browser
+func (app *App) handleBrowseDirs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get path from query parameter, default to user's home directory
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			http.Error(w, "Failed to get home directory", http.StatusInternalServerError)
+			return
+		}
+		path = home
+	}
+
+	// Clean and resolve the path
+	path = filepath.Clean(path)
+
+	// Check if path exists and is a directory
+	info, err := os.Stat(path)
+	if err != nil {
+		http.Error(w, "Path not found: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !info.IsDir() {
+		http.Error(w, "Path is not a directory", http.StatusBadRequest)
+		return
+	}
+
+	// Read directory contents
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		http.Error(w, "Failed to read directory: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type DirEntry struct {
+		Name  string `json:"name"`
+		Path  string `json:"path"`
+		IsDir bool   `json:"is_dir"`
+	}
+
+	var dirs []DirEntry
+	for _, entry := range entries {
+		// Only include directories, skip hidden files (starting with .)
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			dirs = append(dirs, DirEntry{
+				Name:  entry.Name(),
+				Path:  filepath.Join(path, entry.Name()),
+				IsDir: true,
+			})
+		}
+	}
+
+	// Sort alphabetically
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.ToLower(dirs[i].Name) < strings.ToLower(dirs[j].Name)
+	})
+
+	response := struct {
+		Current string     `json:"current"`
+		Parent  string     `json:"parent"`
+		Dirs    []DirEntry `json:"dirs"`
+	}{
+		Current: path,
+		Parent:  filepath.Dir(path),
+		Dirs:    dirs,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// refreshDiagnostics updates the cached diagnostics info
+func (app *App) refreshDiagnostics() {
+	app.diagnosticsMu.Lock()
+	defer app.diagnosticsMu.Unlock()
+
+	app.diagnostics.PortChecks = app.checkPorts()
+	app.diagnostics.Displays = getConnectedDisplays()
+	app.diagnostics.FirewallEnabled, app.diagnostics.FirewallStatus = checkFirewallStatus()
+
+	log.Printf("Diagnostics refreshed: %d ports checked, %d displays found",
+		len(app.diagnostics.PortChecks), len(app.diagnostics.Displays))
+}
+
+// getDiagnostics returns the cached diagnostics info
+func (app *App) getDiagnostics() DiagnosticsInfo {
+	app.diagnosticsMu.RLock()
+	defer app.diagnosticsMu.RUnlock()
+	return app.diagnostics
+}
+
+// handleDiagnostics handles GET/POST /api/admin/diagnostics
+func (app *App) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		// Return cached diagnostics
+		json.NewEncoder(w).Encode(app.getDiagnostics())
+
+	case http.MethodPost:
+		// Refresh diagnostics and return updated data
+		app.refreshDiagnostics()
+		json.NewEncoder(w).Encode(app.getDiagnostics())
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// checkPorts checks if the server ports are accessible
+func (app *App) checkPorts() []PortCheck {
+	var checks []PortCheck
+
+	// Check HTTPS port
+	httpsPort := 8443 // Default
+	if app.config.Port != "" {
+		if p, err := strconv.Atoi(app.config.Port); err == nil {
+			httpsPort = p
+		}
+	}
+
+	checks = append(checks, checkPort(httpsPort, "tcp", "HTTPS Server"))
+
+	// Check HTTP redirect port
+	httpPort := 8080 // Default
+	if app.config.HTTPPort != "" {
+		if p, err := strconv.Atoi(app.config.HTTPPort); err == nil {
+			httpPort = p
+		}
+	}
+
+	checks = append(checks, checkPort(httpPort, "tcp", "HTTP Redirect"))
+
+	return checks
+}
+
+// checkPort attempts to connect to a port
+func checkPort(port int, protocol, description string) PortCheck {
+	check := PortCheck{
+		Port:        port,
+		Protocol:    protocol,
+		Description: description,
+	}
+
+	// Try to connect to localhost
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	conn, err := net.DialTimeout(protocol, addr, 2*time.Second)
+	if err != nil {
+		check.Status = "closed"
+		check.Error = err.Error()
+	} else {
+		check.Status = "open"
+		conn.Close()
+	}
+
+	return check
+}
+
+// getConnectedDisplays returns info about connected displays
+func getConnectedDisplays() []DisplayInfo {
+	var displays []DisplayInfo
+
+	switch runtime.GOOS {
+	case "darwin":
+		displays = getDisplaysMacOS()
+	case "linux":
+		displays = getDisplaysLinux()
+	case "windows":
+		displays = getDisplaysWindows()
+	}
+
+	return displays
+}
+
+// getDisplaysMacOS gets display info on macOS using system_profiler
+func getDisplaysMacOS() []DisplayInfo {
+	var displays []DisplayInfo
+
+	cmd := exec.Command("system_profiler", "SPDisplaysDataType", "-json")
+	output, err := cmd.Output()
+	if err != nil {
+		log.Printf("Failed to get display info: %v", err)
+		return displays
+	}
+
+	// Parse JSON output
+	var data map[string]interface{}
+	if err := json.Unmarshal(output, &data); err != nil {
+		log.Printf("Failed to parse display info: %v", err)
+		return displays
+	}
+
+	// Navigate to displays
+	if spDisplays, ok := data["SPDisplaysDataType"].([]interface{}); ok {
+		for _, gpu := range spDisplays {
+			if gpuMap, ok := gpu.(map[string]interface{}); ok {
+				// Get displays connected to this GPU
+				if ndrvs, ok := gpuMap["spdisplays_ndrvs"].([]interface{}); ok {
+					for i, disp := range ndrvs {
+						if dispMap, ok := disp.(map[string]interface{}); ok {
+							display := DisplayInfo{}
+
+							if name, ok := dispMap["_name"].(string); ok {
+								display.Name = name
+							}
+
+							// Get resolution
+							if res, ok := dispMap["_spdisplays_resolution"].(string); ok {
+								display.Resolution = res
+							} else if res, ok := dispMap["spdisplays_resolution"].(string); ok {
+								display.Resolution = res
+							}
+
+							// Determine connection type
+							if conn, ok := dispMap["spdisplays_connection_type"].(string); ok {
+								display.Connection = conn
+							}
+
+							// Check if it's the main display
+							if main, ok := dispMap["spdisplays_main"].(string); ok && main == "spdisplays_yes" {
+								display.Main = true
+							}
+
+							// Determine type
+							if dispMap["spdisplays_display_type"] != nil {
+								display.Type = "external"
+							} else {
+								display.Type = "built-in"
+							}
+
+							// Only add if we have a name
+							if display.Name != "" || i == 0 {
+								if display.Name == "" {
+									display.Name = fmt.Sprintf("Display %d", i+1)
+								}
+								displays = append(displays, display)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return displays
+}
+
+// getDisplaysLinux gets display info on Linux using xrandr
+func getDisplaysLinux() []DisplayInfo {
+	var displays []DisplayInfo
+
+	cmd := exec.Command("xrandr", "--query")
+	output, err := cmd.Output()
+	if err != nil {
+		return displays
+	}
+
+	lines := strings.Split(string(output), "\n")
+	for _, line := range lines {
+		if strings.Contains(line, " connected") {
+			parts := strings.Fields(line)
+			if len(parts) >= 3 {
+				display := DisplayInfo{
+					Name:       parts[0],
+					Connection: parts[0],
+				}
+
+				// Look for resolution (e.g., "1920x1080+0+0")
+				for _, part := range parts {
+					if strings.Contains(part, "x") && strings.Contains(part, "+") {
+						resParts := strings.Split(part, "+")
+						if len(resParts) > 0 {
+							display.Resolution = resParts[0]
+						}
+						break
+					}
+				}
+
+				if strings.Contains(line, "primary") {
+					display.Main = true
+				}
+
+				displays = append(displays, display)
+			}
+		}
+	}
+
+	return displays
+}
+
+// getDisplaysWindows gets display info on Windows using PowerShell
+func getDisplaysWindows() []DisplayInfo {
+	var displays []DisplayInfo
+
+	// Use PowerShell to query WMI for monitor information
+	psScript := `
+Get-CimInstance -Namespace root\wmi -ClassName WmiMonitorBasicDisplayParams -ErrorAction SilentlyContinue | ForEach-Object {
+    $id = $_.InstanceName
+    $connInfo = Get-CimInstance -Namespace root\wmi -ClassName WmiMonitorConnectionParams -ErrorAction SilentlyContinue | Where-Object { $_.InstanceName -eq $id }
+    $connType = if ($connInfo) { $connInfo.VideoOutputTechnology } else { -1 }
+    [PSCustomObject]@{
+        Name = $_.InstanceName
+        Active = $_.Active
+        MaxHorizontalImageSize = $_.MaxHorizontalImageSize
+        MaxVerticalImageSize = $_.MaxVerticalImageSize
+        ConnectionType = $connType
+    }
+} | ConvertTo-Json -Compress
+`
+	cmd := exec.Command("powershell", "-NoProfile", "-Command", psScript)
+	output, err := cmd.Output()
+	if err != nil {
+		// Fallback: try to get basic display info from Win32_VideoController
+		return getDisplaysWindowsFallback()
+	}
+
+	// Parse JSON output
+	outputStr := strings.TrimSpace(string(output))
+	if outputStr == "" || outputStr == "null" {
+		return getDisplaysWindowsFallback()
+	}
+
+	// Handle single object vs array
+	var monitors []map[string]interface{}
+	if strings.HasPrefix(outputStr, "[") {
+		if err := json.Unmarshal([]byte(outputStr), &monitors); err != nil {
+			return getDisplaysWindowsFallback()
+		}
+	} else {
+		var single map[string]interface{}
+		if err := json.Unmarshal([]byte(outputStr), &single); err != nil {
+			return getDisplaysWindowsFallback()
+		}
+		monitors = []map[string]interface{}{single}
+	}
+
+	for i, mon := range monitors {
+		display := DisplayInfo{
+			Name: fmt.Sprintf("Display %d", i+1),
+		}
+
+		if name, ok := mon["Name"].(string); ok && name != "" {
+			// Clean up the instance name
+			parts := strings.Split(name, "\\")
+			if len(parts) > 1 {
+				display.Name = parts[1]
+			}
+		}
+
+		// Calculate resolution from physical size (approximate)
+		if h, ok := mon["MaxHorizontalImageSize"].(float64); ok {
+			if v, ok := mon["MaxVerticalImageSize"].(float64); ok {
+				if h > 0 && v > 0 {
+					display.Resolution = fmt.Sprintf("%dcm x %dcm", int(h), int(v))
+				}
+			}
+		}
+
+		// Determine connection type
+		if connType, ok := mon["ConnectionType"].(float64); ok {
+			switch int(connType) {
+			case 0:
+				display.Connection = "VGA"
+			case 4:
+				display.Connection = "DVI"
+			case 5:
+				display.Connection = "HDMI"
+			case 10:
+				display.Connection = "DisplayPort"
+			case -1:
+				display.Connection = "Internal"
+				display.Type = "built-in"
+			default:
+				display.Connection = "Other"
+			}
+		}
+
+		if display.Type == "" {
+			display.Type = "external"
+		}
+
+		// First display is typically the main one
+		if i == 0 {
+			display.Main = true
+		}
+
+		displays = append(displays, display)
+	}
+
+	return displays
+}
+
+// getDisplaysWindowsFallback uses Win32_VideoController as fallback
+func getDisplaysWindowsFallback() []DisplayInfo {
+	var displays []DisplayInfo
+
+	psScript := `Get-CimInstance Win32_VideoController | Select-Object Name, CurrentHorizontalResolution, CurrentVerticalResolution | ConvertTo-Json -Compress`
+	cmd := exec.Command("powershell", "-NoProfile", "-Command", psScript)
+	output, err := cmd.Output()
+	if err != nil {
+		return displays
+	}
+
+	outputStr := strings.TrimSpace(string(output))
+	if outputStr == "" || outputStr == "null" {
+		return displays
+	}
+
+	var controllers []map[string]interface{}
+	if strings.HasPrefix(outputStr, "[") {
+		json.Unmarshal([]byte(outputStr), &controllers)
+	} else {
+		var single map[string]interface{}
+		if err := json.Unmarshal([]byte(outputStr), &single); err == nil {
+			controllers = []map[string]interface{}{single}
+		}
+	}
+
+	for i, ctrl := range controllers {
+		display := DisplayInfo{
+			Name: fmt.Sprintf("Display %d", i+1),
+			Type: "external",
+		}
+
+		if name, ok := ctrl["Name"].(string); ok {
+			display.Name = name
+		}
+
+		if h, ok := ctrl["CurrentHorizontalResolution"].(float64); ok {
+			if v, ok := ctrl["CurrentVerticalResolution"].(float64); ok {
+				display.Resolution = fmt.Sprintf("%d x %d", int(h), int(v))
+			}
+		}
+
+		if i == 0 {
+			display.Main = true
+		}
+
+		displays = append(displays, display)
+	}
+
+	return displays
+}
+
+// checkFirewallStatus checks the system firewall status
+func checkFirewallStatus() (enabled bool, status string) {
+	switch runtime.GOOS {
+	case "darwin":
+		// macOS: check Application Firewall status
+		cmd := exec.Command("/usr/libexec/ApplicationFirewall/socketfilterfw", "--getglobalstate")
+		output, err := cmd.Output()
+		if err != nil {
+			return false, "Unable to check firewall status"
+		}
+
+		outputStr := string(output)
+		if strings.Contains(outputStr, "enabled") {
+			return true, "Application Firewall is enabled"
+		}
+		return false, "Application Firewall is disabled"
+
+	case "linux":
+		// Linux: check ufw or iptables
+		cmd := exec.Command("ufw", "status")
+		output, err := cmd.Output()
+		if err == nil {
+			if strings.Contains(string(output), "active") {
+				return true, "UFW firewall is active"
+			}
+			return false, "UFW firewall is inactive"
+		}
+
+		// Try iptables
+		cmd = exec.Command("iptables", "-L", "-n")
+		_, err = cmd.Output()
+		if err == nil {
+			return true, "iptables is configured"
+		}
+		return false, "Unable to determine firewall status"
+
+	case "windows":
+		// Windows: check Windows Firewall status using netsh
+		cmd := exec.Command("netsh", "advfirewall", "show", "allprofiles", "state")
+		output, err := cmd.Output()
+		if err != nil {
+			return false, "Unable to check firewall status"
+		}
+
+		outputStr := string(output)
+		if strings.Contains(outputStr, "ON") {
+			return true, "Windows Firewall is enabled"
+		}
+		return false, "Windows Firewall is disabled"
+	}
+
+	return false, "Firewall check not supported on this OS"
+}
+
+// MPVStatus represents the status of the mpv installation
+type MPVStatus struct {
+	Installed       bool   `json:"installed"`
+	Version         string `json:"version,omitempty"`
+	Path            string `json:"path,omitempty"`
+	Error           string `json:"error,omitempty"`
+	Platform        string `json:"platform"`
+	InstallCommand  string `json:"install_command"`
+	InstallMethod   string `json:"install_method"`
+	DownloadURL     string `json:"download_url"`
+	AlternativeNote string `json:"alternative_note,omitempty"`
+}
+
+// handleMPVCheck handles GET /api/admin/mpv-check
+// Returns the status of the mpv installation and platform-specific install instructions
+func (app *App) handleMPVCheck(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := MPVStatus{
+		Platform: runtime.GOOS,
+	}
+
+	// Set platform-specific installation instructions
+	switch runtime.GOOS {
+	case "darwin":
+		status.InstallCommand = "brew install mpv"
+		status.InstallMethod = "Homebrew"
+		status.DownloadURL = "https://mpv.io/installation/"
+		status.AlternativeNote = "If you don't have Homebrew, install it from https://brew.sh first, or download mpv directly from the website."
+	case "linux":
+		// Try to detect distro
+		distro := detectLinuxDistro()
+		switch distro {
+		case "debian", "ubuntu":
+			status.InstallCommand = "sudo apt install mpv"
+			status.InstallMethod = "APT"
+		case "fedora":
+			status.InstallCommand = "sudo dnf install mpv"
+			status.InstallMethod = "DNF"
+		case "arch":
+			status.InstallCommand = "sudo pacman -S mpv"
+			status.InstallMethod = "Pacman"
+		default:
+			status.InstallCommand = "sudo apt install mpv"
+			status.InstallMethod = "Package Manager"
+			status.AlternativeNote = "Command shown is for Debian/Ubuntu. Use your distro's package manager."
+		}
+		status.DownloadURL = "https://mpv.io/installation/"
+	case "windows":
+		status.InstallCommand = "choco install mpv"
+		status.InstallMethod = "Chocolatey"
+		status.DownloadURL = "https://mpv.io/installation/"
+		status.AlternativeNote = "You can also download mpv directly from the website and add it to your PATH."
+	default:
+		status.InstallCommand = ""
+		status.InstallMethod = "Manual"
+		status.DownloadURL = "https://mpv.io/installation/"
+	}
+
+	// Check if mpv is installed
+	executable := app.config.VideoPlayer
+	if executable == "" {
+		executable = "mpv"
+	}
+
+	// Try to find mpv in PATH or at the specified path
+	mpvPath, err := exec.LookPath(executable)
+	if err != nil {
+		status.Installed = false
+		status.Error = fmt.Sprintf("mpv not found: %v", err)
+		json.NewEncoder(w).Encode(status)
+		return
+	}
+
+	status.Path = mpvPath
+
+	// Get mpv version
+	cmd := exec.Command(mpvPath, "--version")
+	output, err := cmd.Output()
+	if err != nil {
+		status.Installed = false
+		status.Error = fmt.Sprintf("mpv found but failed to get version: %v", err)
+		json.NewEncoder(w).Encode(status)
+		return
+	}
+
+	// Parse version from output (first line usually contains "mpv X.X.X ...")
+	lines := strings.Split(string(output), "\n")
+	if len(lines) > 0 {
+		// Extract version from first line
+		firstLine := strings.TrimSpace(lines[0])
+		if strings.HasPrefix(firstLine, "mpv") {
+			status.Version = firstLine
+		} else {
+			status.Version = "unknown"
+		}
+	}
+
+	status.Installed = true
+	json.NewEncoder(w).Encode(status)
+}
+
+// detectLinuxDistro attempts to detect the Linux distribution
+func detectLinuxDistro() string {
+	// Try /etc/os-release first (most modern distros)
+	data, err := os.ReadFile("/etc/os-release")
+	if err == nil {
+		content := strings.ToLower(string(data))
+		if strings.Contains(content, "ubuntu") {
+			return "ubuntu"
+		}
+		if strings.Contains(content, "debian") {
+			return "debian"
+		}
+		if strings.Contains(content, "fedora") {
+			return "fedora"
+		}
+		if strings.Contains(content, "arch") {
+			return "arch"
+		}
+		if strings.Contains(content, "centos") || strings.Contains(content, "rhel") {
+			return "fedora" // Uses dnf/yum
+		}
+	}
+
+	// Fallback: check for package managers
+	if _, err := exec.LookPath("apt"); err == nil {
+		return "debian"
+	}
+	if _, err := exec.LookPath("dnf"); err == nil {
+		return "fedora"
+	}
+	if _, err := exec.LookPath("pacman"); err == nil {
+		return "arch"
+	}
+
+	return "unknown"
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"songmartyn/internal/admin"
+)
+
+// ============================================================================
+// Metrics Tests
+// ============================================================================
+
+// scrapeMetrics fetches /metrics the way Prometheus would
+func scrapeMetrics(t *testing.T, app *App) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	app.metrics.registry.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from /metrics, got %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestMetricsFollowPlayback(t *testing.T) {
+	app, player := newTestApp(t)
+	broken := queueTestSong(t, app, "s1", "alice")
+	good := queueTestSong(t, app, "s2", "bob")
+	queueTestSong(t, app, "s3", "carol")
+	player.FailLoad(broken.VideoURL, errors.New("no such file"))
+
+	if out := scrapeMetrics(t, app); !strings.Contains(out, "songmartyn_queue_length 3\n") {
+		t.Errorf("Expected 3 queued songs, got:\n%s", out)
+	}
+
+	app.playCurrentSong()
+	waitFor(t, "next song after the failure", func() bool { return player.Current() == good.VideoURL })
+	player.FinishTrack()
+	waitFor(t, "the finished song to count", func() bool { return app.metrics.songsPlayed.Value() == 1 })
+
+	out := scrapeMetrics(t, app)
+	for _, line := range []string{
+		"songmartyn_song_load_failures_total 1",
+		"songmartyn_songs_played_total 1",
+		"songmartyn_websocket_clients 0",
+		`songmartyn_sqlite_query_duration_seconds_count{db="library",op="record_play"} 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Expected %q in:\n%s", line, out)
+		}
+	}
+	if app.metrics.broadcastLatency.Count("state_update") == 0 {
+		t.Error("Expected state broadcasts to be timed")
+	}
+	if app.metrics.queryDuration.Count("queue", "save_song") != 3 {
+		t.Errorf("Expected 3 queue saves, got %d", app.metrics.queryDuration.Count("queue", "save_song"))
+	}
+}
+
+func TestMetricsCountPlayerRestartsAndSearches(t *testing.T) {
+	app, player := newTestApp(t)
+	queueTestSong(t, app, "s1", "alice")
+	player.Crash()
+
+	app.startPlayCountdown(0)
+	if app.metrics.mpvRestarts.Value() != 1 {
+		t.Errorf("Expected 1 restart, got %v", app.metrics.mpvRestarts.Value())
+	}
+
+	rec := httptest.NewRecorder()
+	app.handleLibrarySearch(rec, httptest.NewRequest(http.MethodGet, "/api/library/search?q=africa", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from search, got %d", rec.Code)
+	}
+	if n := app.metrics.searchLatency.Count("library"); n != 1 {
+		t.Errorf("Expected 1 library search, got %d", n)
+	}
+	if n := app.metrics.queryDuration.Count("library", "log_search"); n != 1 {
+		t.Errorf("Expected the search log write to be timed, got %d", n)
+	}
+}
+
+func TestMetricsRequireAdmin(t *testing.T) {
+	app, _ := newTestApp(t)
+	app.admin = admin.NewManager("2468")
+	handler := app.routes()
+
+	scrape := func(remoteAddr, token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := scrape("192.168.1.20:50000", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected guests to be refused, got %d", code)
+	}
+	if code := scrape("192.168.1.20:50000", "not-a-token"); code == http.StatusOK {
+		t.Error("Expected an invalid token to be refused")
+	}
+	if code := scrape("192.168.1.20:50000", app.admin.GenerateToken("admin-key")); code != http.StatusOK {
+		t.Errorf("Expected an admin token to be accepted, got %d", code)
+	}
+	if code := scrape("127.0.0.1:50000", ""); code != http.StatusOK {
+		t.Errorf("Expected scrapes from this machine to be accepted, got %d", code)
+	}
+}
@@ -0,0 +1,805 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"songmartyn/internal/configfile"
+	"songmartyn/internal/mpv"
+	"songmartyn/internal/recording"
+	"songmartyn/internal/transition"
+	"songmartyn/internal/vocalassist"
+	"songmartyn/internal/webdisplay"
+	"songmartyn/pkg/models"
+)
+
+// newTestApp creates an app driving a fake player, with countdowns sped up
+func newTestApp(t *testing.T) (*App, *mpv.FakePlayer) {
+	t.Helper()
+	player := mpv.NewFakePlayer()
+	app, err := newAppWithPlayer(Config{DataDir: t.TempDir(), Port: "8443"}, player)
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	app.countdownTick = time.Millisecond
+	if err := player.Start(); err != nil {
+		t.Fatalf("Failed to start player: %v", err)
+	}
+	t.Cleanup(func() {
+		app.stopCountdown()
+		app.Shutdown()
+	})
+	return app, player
+}
+
+// queueTestSong adds a song sung by singer to the queue
+func queueTestSong(t *testing.T, app *App, id, singer string) models.Song {
+	t.Helper()
+	song := models.Song{ID: id, Title: "Song " + id, VideoURL: "/media/" + id + ".mp4", AddedBy: singer}
+	if err := app.queue.Add(song); err != nil {
+		t.Fatalf("Failed to queue %s: %v", id, err)
+	}
+	return song
+}
+
+// waitFor polls until cond holds or fails the test
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// countdownState reads the countdown under its lock
+func countdownState(app *App) models.CountdownState {
+	app.countdownMu.Lock()
+	defer app.countdownMu.Unlock()
+	return app.countdown
+}
+
+// guestConn is a phone connected to the app's websocket
+type guestConn struct {
+	t        *testing.T
+	conn     *websocket.Conn
+	key      string
+	publicID string // From the welcome, how others see this guest
+}
+
+// dialGuest connects a guest and completes the handshake
+func dialGuest(t *testing.T, srv *httptest.Server, name string) *guestConn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	g := &guestConn{t: t, conn: conn}
+	g.send("handshake", map[string]string{"display_name": name})
+	var welcome struct {
+		Session  models.Session `json:"session"`
+		PublicID string         `json:"public_id"`
+	}
+	json.Unmarshal(g.read("welcome"), &welcome)
+	g.key = welcome.Session.MartynKey
+	g.publicID = welcome.PublicID
+	return g
+}
+
+func (g *guestConn) send(msgType string, payload interface{}) {
+	g.t.Helper()
+	if err := g.conn.WriteJSON(map[string]interface{}{"type": msgType, "payload": payload}); err != nil {
+		g.t.Fatalf("Failed to send %s: %v", msgType, err)
+	}
+}
+
+// read skips messages until one of msgType arrives and returns its payload
+func (g *guestConn) read(msgType string) json.RawMessage {
+	g.t.Helper()
+	g.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg struct {
+			Type    string          `json:"type"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := g.conn.ReadJSON(&msg); err != nil {
+			g.t.Fatalf("Timed out waiting for %s: %v", msgType, err)
+		}
+		if msg.Type == msgType {
+			return msg.Payload
+		}
+	}
+}
+
+// ============================================================================
+// Autoplay and Countdown Tests
+// ============================================================================
+
+func TestAutoplaySameSingerPlaysAfterCountdown(t *testing.T) {
+	app, player := newTestApp(t)
+	app.queue.SetAutoplay(true)
+	first := queueTestSong(t, app, "s1", "alice")
+	second := models.Song{ID: "s2", Title: "Stems", InstrPath: "/media/s2-instr.wav", VocalPath: "/media/s2-vocal.wav", AddedBy: "alice"}
+	app.queue.Add(second)
+
+	app.playCurrentSong()
+	if player.Current() != first.VideoURL {
+		t.Fatalf("Expected %s loaded, got %q", first.VideoURL, player.Current())
+	}
+
+	player.FinishTrack()
+	if cd := countdownState(app); !cd.Active || cd.RequiresApproval || cd.NextSongID != "s2" {
+		t.Errorf("Expected an auto-play countdown for s2, got %+v", cd)
+	}
+	if !player.ShowingImage() {
+		t.Error("Expected holding screen during the countdown")
+	}
+
+	waitFor(t, "second song to start", func() bool { return player.Current() == second.InstrPath })
+	if cd := countdownState(app); cd.Active {
+		t.Errorf("Expected countdown to be cleared, got %+v", cd)
+	}
+	if app.idle {
+		t.Error("Expected app not to be idle while a song plays")
+	}
+
+	// Stem playback detects its own end too
+	player.FinishTrack()
+	waitFor(t, "holding screen after the last song", player.ShowingImage)
+	if app.queue.Current() != nil {
+		t.Errorf("Expected queue to be exhausted, got %v", app.queue.Current())
+	}
+}
+
+func TestCountdownWaitsForApprovalForNextSinger(t *testing.T) {
+	app, player := newTestApp(t)
+	app.queue.SetAutoplay(true)
+	queueTestSong(t, app, "s1", "alice")
+	next := queueTestSong(t, app, "s2", "bob")
+
+	app.playCurrentSong()
+	player.FinishTrack()
+
+	waitFor(t, "countdown to run out", func() bool {
+		cd := countdownState(app)
+		return cd.Active && cd.SecondsRemaining == 0
+	})
+	if !countdownState(app).RequiresApproval {
+		t.Error("Expected a different singer to require approval")
+	}
+	time.Sleep(20 * app.countdownTick)
+	if !player.ShowingImage() {
+		t.Fatalf("Expected to keep waiting on the holding screen, got %q", player.Current())
+	}
+
+	// Admin "start now"
+	app.startPlayCountdown(0)
+	if player.Current() != next.VideoURL {
+		t.Errorf("Expected %s after approval, got %q", next.VideoURL, player.Current())
+	}
+}
+
+func TestAutoplayDisabledWaitsOnHoldingScreen(t *testing.T) {
+	app, player := newTestApp(t)
+	app.queue.SetAutoplay(false)
+	queueTestSong(t, app, "s1", "alice")
+	queueTestSong(t, app, "s2", "alice")
+
+	app.playCurrentSong()
+	player.FinishTrack()
+
+	if !player.ShowingImage() {
+		t.Errorf("Expected holding screen, got %q", player.Current())
+	}
+	if cd := countdownState(app); cd.Active {
+		t.Errorf("Expected no countdown with autoplay off, got %+v", cd)
+	}
+	if cur := app.queue.Current(); cur == nil || cur.ID != "s2" {
+		t.Errorf("Expected queue to advance to s2, got %v", cur)
+	}
+}
+
+func TestAdminPlayCountdown(t *testing.T) {
+	app, player := newTestApp(t)
+	song := queueTestSong(t, app, "s1", "alice")
+
+	app.startPlayCountdown(5)
+	if cd := countdownState(app); !cd.Active || cd.SecondsRemaining > 5 || cd.NextSongID != "s1" {
+		t.Errorf("Expected a 5-second countdown for s1, got %+v", cd)
+	}
+
+	waitFor(t, "song to start after the countdown", func() bool { return player.Current() == song.VideoURL })
+	if !player.IsPlayingSong() {
+		t.Error("Expected the player to treat the file as a song")
+	}
+}
+
+// ============================================================================
+// Error Recovery Tests
+// ============================================================================
+
+func TestLoadErrorSkipsToNextSong(t *testing.T) {
+	app, player := newTestApp(t)
+	broken := queueTestSong(t, app, "s1", "alice")
+	good := queueTestSong(t, app, "s2", "bob")
+	player.FailLoad(broken.VideoURL, errors.New("no such file"))
+
+	app.playCurrentSong()
+
+	waitFor(t, "next song after the failure", func() bool { return player.Current() == good.VideoURL })
+	for _, path := range player.Loaded() {
+		if path == broken.VideoURL {
+			t.Error("Broken song should never have loaded")
+		}
+	}
+}
+
+func TestLoadErrorOnLastSongShowsHoldingScreen(t *testing.T) {
+	app, player := newTestApp(t)
+	broken := queueTestSong(t, app, "s1", "alice")
+	player.FailLoad(broken.VideoURL, errors.New("no such file"))
+
+	app.playCurrentSong()
+
+	if !player.ShowingImage() {
+		t.Errorf("Expected holding screen, got %q", player.Current())
+	}
+	if !app.idle {
+		t.Error("Expected app to be idle")
+	}
+	if app.queue.Current() != nil {
+		t.Errorf("Expected the broken song to be skipped, got %v", app.queue.Current())
+	}
+}
+
+func TestPlayCountdownRestartsCrashedPlayer(t *testing.T) {
+	app, player := newTestApp(t)
+	song := queueTestSong(t, app, "s1", "alice")
+	player.Crash()
+
+	app.startPlayCountdown(0)
+
+	if !player.IsRunning() {
+		t.Fatal("Expected the player to be restarted")
+	}
+	if player.Current() != song.VideoURL {
+		t.Errorf("Expected %s after restart, got %q", song.VideoURL, player.Current())
+	}
+}
+
+func TestPlayCountdownGivesUpWhenRestartFails(t *testing.T) {
+	app, player := newTestApp(t)
+	queueTestSong(t, app, "s1", "alice")
+	player.Crash()
+	player.FailStart(errors.New("no display"))
+
+	app.startPlayCountdown(0)
+
+	if cd := countdownState(app); cd.Active {
+		t.Errorf("Expected no countdown without a player, got %+v", cd)
+	}
+	if cur := app.queue.Current(); cur == nil || cur.ID != "s1" {
+		t.Errorf("Expected s1 to stay queued, got %v", cur)
+	}
+}
+
+// ============================================================================
+// Loudness Normalization Tests
+// ============================================================================
+
+func TestLoudnessGainAppliedPerSong(t *testing.T) {
+	app, player := newTestApp(t)
+	app.config.LoudnessNormalization = true
+	app.config.LoudnessTargetLUFS = -18
+
+	dir := t.TempDir()
+	for _, name := range []string{"Loud - Song.mp4", "Unmeasured - Song.mp4"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644)
+	}
+	loc, err := app.library.AddLocation(dir, "Songs")
+	if err != nil {
+		t.Fatalf("Failed to add location: %v", err)
+	}
+	app.library.ScanLocation(loc.ID)
+	loud, _ := app.library.SearchSongs("Loud", 1)
+	unmeasured, _ := app.library.SearchSongs("Unmeasured", 1)
+	if len(loud) != 1 || len(unmeasured) != 1 {
+		t.Fatalf("Expected both songs in the library, got %d and %d", len(loud), len(unmeasured))
+	}
+	app.library.SetLoudness(loud[0].ID, -9, -0.5)
+
+	app.queue.Add(queueSongFromLibrary(&loud[0], models.VocalOff, "alice"))
+	app.queue.Add(queueSongFromLibrary(&unmeasured[0], models.VocalOff, "bob"))
+
+	app.playCurrentSong()
+	if gain := player.Gain(); math.Abs(gain-(-9)) > 1e-9 {
+		t.Errorf("Expected -9 dB for a -9 LUFS song, got %.2f", gain)
+	}
+
+	app.queue.Skip()
+	app.playCurrentSong()
+	if gain := player.Gain(); gain != 0 {
+		t.Errorf("Expected unmeasured song to play without gain, got %.2f", gain)
+	}
+
+	app.config.LoudnessNormalization = false
+	app.queue.Previous()
+	app.playCurrentSong()
+	if gain := player.Gain(); gain != 0 {
+		t.Errorf("Expected no gain with normalization off, got %.2f", gain)
+	}
+}
+
+// ============================================================================
+// Mic Effects Tests
+// ============================================================================
+
+func TestMicPresetFollowsSinger(t *testing.T) {
+	app, player := newTestApp(t)
+	app.mic = mpv.NewMicInput("", "") // Not started; effects are recorded for the next start
+	alice := app.sessions.GetOrCreate("", "Alice")
+	app.sessions.UpdateMicPreset(alice.MartynKey, models.MicPresetStadium)
+	bob := app.sessions.GetOrCreate("", "Bob")
+	app.sessions.UpdateMicPreset(bob.MartynKey, models.MicPresetEcho)
+
+	queueTestSong(t, app, "s1", alice.MartynKey)
+	next := queueTestSong(t, app, "s2", bob.MartynKey)
+
+	app.playCurrentSong()
+	stadium, _ := mpv.MicEffectsFor(models.MicPresetStadium)
+	if got := app.mic.Effects().FilterChain(); got != stadium.FilterChain() {
+		t.Errorf("Expected Alice's stadium preset, got %s", got)
+	}
+
+	// The host talks over the holding screen with a dry mic
+	player.FinishTrack()
+	if got := app.mic.Effects().FilterChain(); got != "anull" {
+		t.Errorf("Expected a dry mic between songs, got %s", got)
+	}
+
+	app.startPlayCountdown(0)
+	if player.Current() != next.VideoURL {
+		t.Fatalf("Expected Bob's song to start, got %q", player.Current())
+	}
+	echo, _ := mpv.MicEffectsFor(models.MicPresetEcho)
+	if got := app.mic.Effects().FilterChain(); got != echo.FilterChain() {
+		t.Errorf("Expected Bob's echo preset, got %s", got)
+	}
+}
+
+// ============================================================================
+// Performance Recording Tests
+// ============================================================================
+
+func TestRecordingFollowsPlaybackLifecycle(t *testing.T) {
+	app, player := newTestApp(t)
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "capture.sh")
+	os.WriteFile(script, []byte("trap 'exit 0' INT\nhead -c 1000 /dev/zero > \"$1\"\nwhile :; do sleep 0.05; done\n"), 0644)
+	recorder, err := recording.NewManager(filepath.Join(dir, "recordings.db"), recording.Options{
+		Dir:     filepath.Join(dir, "recordings"),
+		Command: "sh " + script + " {output}",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	app.recorder = recorder
+
+	songsDir := t.TempDir()
+	for _, name := range []string{"Alice - Opted In.mp4", "Bob - Not Recorded.mp4"} {
+		os.WriteFile(filepath.Join(songsDir, name), []byte("x"), 0644)
+	}
+	loc, _ := app.library.AddLocation(songsDir, "Songs")
+	app.library.ScanLocation(loc.ID)
+	optedIn, _ := app.library.SearchSongs("Opted", 1)
+	notRecorded, _ := app.library.SearchSongs("Recorded", 1)
+	if len(optedIn) != 1 || len(notRecorded) != 1 {
+		t.Fatalf("Expected both songs in the library, got %d and %d", len(optedIn), len(notRecorded))
+	}
+
+	alice := app.sessions.GetOrCreate("", "Alice")
+	app.sessions.UpdateRecordPerformances(alice.MartynKey, true)
+	bob := app.sessions.GetOrCreate("", "Bob")
+	app.queue.Add(queueSongFromLibrary(&optedIn[0], models.VocalOff, alice.MartynKey))
+	app.queue.Add(queueSongFromLibrary(&notRecorded[0], models.VocalOff, bob.MartynKey))
+
+	app.playCurrentSong()
+	if !recorder.Recording() {
+		t.Fatal("Expected Alice's performance to be recorded")
+	}
+	waitFor(t, "capture to write audio", func() bool {
+		recs, _ := recorder.List()
+		if len(recs) != 1 {
+			return false
+		}
+		info, err := os.Stat(filepath.Join(dir, "recordings", recs[0].FileName))
+		return err == nil && info.Size() > 0
+	})
+
+	// The song finishing stops the capture and links it to the history row
+	player.FinishTrack()
+	if recorder.Recording() {
+		t.Error("Expected recording to stop when the track ended")
+	}
+	var recs []recording.Recording
+	waitFor(t, "recording to finish", func() bool {
+		recs, _ = recorder.ForSinger(alice.MartynKey)
+		return len(recs) == 1 && recs[0].Status == recording.StatusComplete
+	})
+	history, _ := app.library.GetUserHistory(alice.MartynKey, 1)
+	if len(history) != 1 || recs[0].HistoryID != history[0].ID {
+		t.Errorf("Expected recording linked to history %v, got %d", history, recs[0].HistoryID)
+	}
+
+	// The private link downloads the file
+	view := app.recordingWithLink(recs[0])
+	if view.Download == nil {
+		t.Fatal("Expected a download link")
+	}
+	rec := httptest.NewRecorder()
+	app.handleRecordingDownload(rec, httptest.NewRequest(http.MethodGet, view.Download.URL, nil))
+	if rec.Code != http.StatusOK || rec.Body.Len() != 1000 {
+		t.Errorf("Expected 1000-byte download, got %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+	if !strings.Contains(rec.Header().Get("Content-Disposition"), "Opted In") {
+		t.Errorf("Expected the song title in the file name, got %q", rec.Header().Get("Content-Disposition"))
+	}
+
+	// Bob didn't opt in
+	app.playCurrentSong()
+	if recorder.Recording() {
+		t.Error("Expected Bob's performance not to be recorded")
+	}
+}
+
+// ============================================================================
+// Vocal Assist Tests
+// ============================================================================
+
+// fakeMicLevel stands in for the mic input's level meter
+type fakeMicLevel struct{ db float64 }
+
+func (m *fakeMicLevel) Level() (float64, error) { return m.db, nil }
+
+// queueStemsSong adds a song with separated stems sung by alice
+func queueStemsSong(t *testing.T, app *App, level models.VocalAssistLevel) models.Song {
+	t.Helper()
+	song := models.Song{ID: "s1", Title: "Stems", InstrPath: "/media/s1-instr.wav", VocalPath: "/media/s1-vocal.wav", AddedBy: "alice", VocalAssist: level}
+	if err := app.queue.Add(song); err != nil {
+		t.Fatalf("Failed to queue song: %v", err)
+	}
+	return song
+}
+
+func TestVocalGainChangesWithoutReload(t *testing.T) {
+	app, player := newTestApp(t)
+	queueStemsSong(t, app, models.VocalLow)
+
+	app.playCurrentSong()
+	if player.VocalGain() != models.VocalGainMap[models.VocalLow] {
+		t.Errorf("Expected LOW vocal gain, got %.2f", player.VocalGain())
+	}
+	player.Advance(30 * time.Second)
+	loads := len(player.Loaded())
+
+	app.updateVocalMix(models.VocalCustom, 0.65)
+	if player.VocalGain() != 0.65 {
+		t.Errorf("Expected vocal gain 0.65, got %.2f", player.VocalGain())
+	}
+	if len(player.Loaded()) != loads {
+		t.Errorf("Expected no reload, got %v", player.Loaded())
+	}
+	if state, _ := player.GetState(); state.Position < 30 {
+		t.Errorf("Expected position to be kept, got %.1f", state.Position)
+	}
+
+	state := app.getRoomState().Player
+	if state.VocalAssist != models.VocalCustom || state.VocalGain != 0.65 {
+		t.Errorf("Expected CUSTOM at 0.65, got %s at %.2f", state.VocalAssist, state.VocalGain)
+	}
+	if cur := app.queue.Current(); cur.VocalAssist != models.VocalCustom || cur.VocalGain != 0.65 {
+		t.Errorf("Expected queue entry to keep the new level, got %s/%.2f", cur.VocalAssist, cur.VocalGain)
+	}
+}
+
+func TestAutoVocalFollowsMicLevel(t *testing.T) {
+	app, player := newTestApp(t)
+	mic := &fakeMicLevel{db: -60}
+	app.micLevel = mic
+	queueStemsSong(t, app, models.VocalAuto)
+
+	app.playCurrentSong()
+	if player.VocalGain() != models.VocalGainFor(models.VocalAuto, 0) {
+		t.Errorf("Expected AUTO start gain, got %.2f", player.VocalGain())
+	}
+
+	// A quiet singer gets the guide vocal once the hold has passed
+	for i := 0; i < 50; i++ {
+		app.stepAutoVocal(autoVocalInterval)
+	}
+	if math.Abs(player.VocalGain()-vocalassist.DefaultMaxGain) > 0.01 {
+		t.Errorf("Expected vocal at %.2f while quiet, got %.2f", vocalassist.DefaultMaxGain, player.VocalGain())
+	}
+
+	// Singing takes it back out
+	mic.db = -20
+	for i := 0; i < 10; i++ {
+		app.stepAutoVocal(autoVocalInterval)
+	}
+	if player.VocalGain() > 0.01 {
+		t.Errorf("Expected vocal out while singing, got %.2f", player.VocalGain())
+	}
+
+	// Leaving AUTO stops following the mic
+	app.updateVocalMix(models.VocalMed, 0)
+	mic.db = -60
+	for i := 0; i < 50; i++ {
+		app.stepAutoVocal(autoVocalInterval)
+	}
+	if player.VocalGain() != models.VocalGainMap[models.VocalMed] {
+		t.Errorf("Expected MEDIUM gain after leaving AUTO, got %.2f", player.VocalGain())
+	}
+}
+
+// ============================================================================
+// Transition Tests
+// ============================================================================
+
+// useTransitions swaps in a transition scheduler driven by a fake clock
+func useTransitions(app *App, player *mpv.FakePlayer, settings transition.Settings) *transition.FakeClock {
+	clock := transition.NewFakeClock()
+	app.transitions = transition.NewScheduler(clock, player, settings)
+	return clock
+}
+
+func TestBGMCrossfadesIntoSong(t *testing.T) {
+	app, player := newTestApp(t)
+	clock := useTransitions(app, player, transition.Settings{BGMCrossfade: 2 * time.Second, HoldingFadeIn: time.Second})
+	app.bgmSettings = models.BGMSettings{Enabled: true, URL: "https://radio.example/stream", Volume: 40}
+	song := queueTestSong(t, app, "s1", "alice")
+
+	app.startBGM()
+	if player.BGMAudio() == "" || player.Fade() != 0 {
+		t.Fatalf("Expected BGM to start faded out, got %q at %.2f", player.BGMAudio(), player.Fade())
+	}
+	clock.Advance(time.Second)
+	if player.Fade() != 1 || player.BGMFade() != 1 {
+		t.Errorf("Expected BGM holding screen faded in, got %.2f / BGM %.2f", player.Fade(), player.BGMFade())
+	}
+
+	// The song loads at once and fades in while the BGM fades out under it
+	app.playCurrentSong()
+	if player.Current() != song.VideoURL || player.Fade() != 0 {
+		t.Fatalf("Expected %s loaded silent, got %q at %.2f", song.VideoURL, player.Current(), player.Fade())
+	}
+	if app.bgmActive {
+		t.Error("Expected BGM to be marked inactive once the crossfade starts")
+	}
+
+	clock.Advance(time.Second)
+	if player.BGMAudio() == "" {
+		t.Fatal("Expected the BGM to keep playing under the song")
+	}
+	if math.Abs(player.Fade()-0.5) > 1e-9 || math.Abs(player.BGMFade()-0.5) > 1e-9 {
+		t.Errorf("Expected both halfway through the crossfade, got song %.2f / BGM %.2f", player.Fade(), player.BGMFade())
+	}
+
+	clock.Advance(time.Second)
+	if player.Fade() != 1 {
+		t.Errorf("Expected song faded in, got %.2f", player.Fade())
+	}
+	if player.BGMAudio() != "" {
+		t.Errorf("Expected the BGM stopped once faded out, got %q", player.BGMAudio())
+	}
+	if player.Current() != song.VideoURL {
+		t.Errorf("Expected the song to keep playing after the BGM stops, got %q", player.Current())
+	}
+}
+
+func TestSongTrimAndFadeOut(t *testing.T) {
+	app, player := newTestApp(t)
+	clock := useTransitions(app, player, transition.Settings{SongFadeOut: 3 * time.Second, HoldingFadeIn: time.Second, TrimSilence: true})
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "Quiet - Intro.mp4"), []byte("x"), 0644)
+	loc, _ := app.library.AddLocation(dir, "Songs")
+	app.library.ScanLocation(loc.ID)
+	songs, _ := app.library.SearchSongs("Intro", 1)
+	if len(songs) != 1 {
+		t.Fatalf("Expected the song in the library, got %d", len(songs))
+	}
+	app.library.SetSilence(songs[0].ID, 4.3, 10.3)
+	player.SetDuration(songs[0].FilePath, 200)
+	app.queue.Add(queueSongFromLibrary(&songs[0], models.VocalOff, "alice"))
+
+	app.playCurrentSong()
+	state, _ := player.GetState()
+	if math.Abs(state.Position-4) > 1e-9 || math.Abs(state.Duration-190) > 1e-9 {
+		t.Errorf("Expected playback from 4s to 190s, got %.1f to %.1f", state.Position, state.Duration)
+	}
+
+	// The fade-out starts three seconds before the trimmed end
+	player.Advance(180 * time.Second)
+	clock.Advance(time.Second)
+	if player.Fade() != 1 {
+		t.Errorf("Expected full level mid-song, got %.2f", player.Fade())
+	}
+	player.Advance(3 * time.Second)
+	clock.Advance(transition.WatchInterval + 1500*time.Millisecond)
+	if f := player.Fade(); f <= 0 || f >= 1 {
+		t.Errorf("Expected the song to be fading out, got %.2f", f)
+	}
+
+	// The holding screen fades in after the song ends
+	player.FinishTrack()
+	waitFor(t, "holding screen", player.ShowingImage)
+	if player.Fade() != 0 {
+		t.Errorf("Expected holding screen to start dark, got %.2f", player.Fade())
+	}
+	clock.Advance(time.Second)
+	if player.Fade() != 1 {
+		t.Errorf("Expected holding screen faded in, got %.2f", player.Fade())
+	}
+
+	// CD+G songs are never trimmed
+	if lead, tail := app.songTrim(&models.Song{ID: songs[0].ID, CDGPath: "/media/x.cdg"}); lead != 0 || tail != 0 {
+		t.Errorf("Expected no trim for CD+G, got %.1f / %.1f", lead, tail)
+	}
+}
+
+// ============================================================================
+// Web Display Tests
+// ============================================================================
+
+func TestWebDisplayTrackEndDrivesAutoplay(t *testing.T) {
+	dataDir := t.TempDir()
+	app, err := NewApp(Config{DataDir: dataDir, Port: "8443", PlayerBackend: "web"})
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	app.countdownTick = time.Millisecond
+	t.Cleanup(func() {
+		app.stopCountdown()
+		app.Shutdown()
+	})
+	display := app.webDisplay
+	display.Start()
+
+	srv := httptest.NewServer(app.routes())
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + webdisplay.WSPath
+
+	// Without a configured key the display gets a generated one, so nobody else can drive it
+	if _, _, err := websocket.DefaultDialer.Dial(wsURL, nil); err == nil {
+		t.Fatal("Expected a display page without the key to be rejected")
+	}
+	key, err := os.ReadFile(filepath.Join(dataDir, webDisplayKeyFile))
+	if err != nil || display.PageURL() != webdisplay.PagePath+"?key="+string(key) {
+		t.Fatalf("Expected the generated key saved and in the page URL, got %q (%v)", display.PageURL(), err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?key="+string(key), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	waitFor(t, "display page to connect", display.IsRunning)
+
+	// nextLoad skips overlays, tickers and holding screens until a song loads
+	nextLoad := func() webdisplay.Command {
+		t.Helper()
+		for {
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			var cmd webdisplay.Command
+			if err := conn.ReadJSON(&cmd); err != nil {
+				t.Fatalf("Expected a load command: %v", err)
+			}
+			if cmd.Type == "load" {
+				return cmd
+			}
+		}
+	}
+
+	app.queue.SetAutoplay(true)
+	queueTestSong(t, app, "s1", "alice")
+	queueTestSong(t, app, "s2", "alice")
+
+	app.startPlayCountdown(0)
+	first := nextLoad()
+
+	// The page reports the end of the song; the countdown then starts the next one
+	conn.WriteJSON(webdisplay.Report{Type: "ended", ID: first.ID})
+	second := nextLoad()
+	if second.ID == first.ID {
+		t.Error("Expected a new load for the second song")
+	}
+	if cur := app.queue.Current(); cur == nil || cur.ID != "s2" {
+		t.Errorf("Expected s2 to be playing, got %v", cur)
+	}
+}
+
+// ============================================================================
+// Holding Theme Tests
+// ============================================================================
+
+func TestUploadAndSwitchHoldingTheme(t *testing.T) {
+	app, player := newTestApp(t)
+	app.config.ConfigPath = filepath.Join(t.TempDir(), "songmartyn.toml")
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("theme", "midnight.json")
+	part.Write([]byte(`{"name": "Midnight", "background": {"color": "#101020"}, "widgets": [{"type": "clock", "region": {"x": 0, "y": 0, "w": 400, "h": 120}}]}`))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/holding-themes", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	app.handleHoldingThemes(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected upload to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if app.holdingThemes.Get("midnight") == nil {
+		t.Fatal("Expected the uploaded theme to be stored as midnight")
+	}
+
+	loads := len(player.Loaded())
+	rec = httptest.NewRecorder()
+	app.handleHoldingThemeActive(rec, httptest.NewRequest(http.MethodPost, "/api/admin/holding-themes/active", strings.NewReader(`{"id": "midnight"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected switch to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if id := app.holdingScreen.Theme().ID; id != "midnight" {
+		t.Errorf("Expected active theme midnight, got %s", id)
+	}
+	if len(player.Loaded()) == loads || !player.ShowingImage() {
+		t.Error("Expected the holding screen to be redrawn")
+	}
+	if saved, _ := configfile.Load(app.config.ConfigPath); saved.Holding.Theme != "midnight" {
+		t.Errorf("Expected the theme to be saved, got %q", saved.Holding.Theme)
+	}
+
+	// Deleting the active theme falls back to the default
+	rec = httptest.NewRecorder()
+	app.handleHoldingThemes(rec, httptest.NewRequest(http.MethodDelete, "/api/admin/holding-themes?id=midnight", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected delete to succeed, got %d", rec.Code)
+	}
+	if id := app.holdingScreen.Theme().ID; id != "classic" {
+		t.Errorf("Expected fallback to classic, got %s", id)
+	}
+
+	rec = httptest.NewRecorder()
+	app.handleHoldingThemes(rec, httptest.NewRequest(http.MethodDelete, "/api/admin/holding-themes?id=classic", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected built-in delete to be refused, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	app.handleHoldingThemes(rec, httptest.NewRequest(http.MethodGet, "/api/admin/holding-themes", nil))
+	var list struct {
+		Themes []struct {
+			ID     string `json:"id"`
+			Active bool   `json:"active"`
+		} `json:"themes"`
+		Active string `json:"active"`
+	}
+	json.NewDecoder(rec.Body).Decode(&list)
+	if len(list.Themes) != 2 || list.Active != "classic" || !list.Themes[0].Active {
+		t.Errorf("Expected the two built-ins with classic active, got %+v", list)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"songmartyn/pkg/models"
+)
+
+// ============================================================================
+// Playlist Sharing Tests
+// ============================================================================
+
+// assertNoKeys fails if any of the given MartynKeys appear in a payload
+func assertNoKeys(t *testing.T, label string, payload []byte, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		if strings.Contains(string(payload), key) {
+			t.Errorf("%s leaks MartynKey %q: %s", label, key, payload)
+		}
+	}
+}
+
+func TestPlaylistSharingHidesKeys(t *testing.T) {
+	app, _ := newTestApp(t)
+	go app.hub.Run()
+	srv := httptest.NewServer(app.routes())
+	defer srv.Close()
+
+	alice := dialGuest(t, srv, "Alice")
+	bob := dialGuest(t, srv, "Bob")
+
+	alice.send("playlist_create", map[string]string{"name": "Duets"})
+	var lists []models.PlaylistView
+	json.Unmarshal(alice.read("playlists"), &lists)
+	if len(lists) != 1 || !lists[0].IsOwner {
+		t.Fatalf("Expected Alice's new playlist, got %+v", lists)
+	}
+	id := lists[0].ID
+
+	// Alice only knows Bob by his public ID
+	alice.send("playlist_share_link", map[string]string{"playlist_id": id})
+	alice.read("playlist_share_link")
+	alice.send("playlist_share", map[string]string{"playlist_id": id, "public_id": bob.publicID})
+	shared := bob.read("playlists")
+	assertNoKeys(t, "Bob's playlists", shared, alice.key, bob.key)
+	json.Unmarshal(shared, &lists)
+	if len(lists) != 1 || lists[0].IsOwner || lists[0].Owner.DisplayName != "Alice" {
+		t.Fatalf("Expected Alice's playlist shared with Bob, got %+v", lists)
+	}
+	if lists[0].ShareToken != "" {
+		t.Error("Only the owner should get the share token")
+	}
+	if len(lists[0].SharedWith) != 1 || lists[0].SharedWith[0].PublicID != bob.publicID {
+		t.Errorf("Expected Bob among the members by public ID, got %+v", lists[0].SharedWith)
+	}
+
+	alice.send("playlist_list", nil)
+	json.Unmarshal(alice.read("playlists"), &lists)
+	if len(lists) != 1 || lists[0].ShareToken == "" {
+		t.Error("Expected the owner to get the share token")
+	}
+
+	// The HTTP listing is for the requesting session only
+	get := func(key, query string) (int, []byte) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/playlists"+query, nil)
+		if key != "" {
+			req.Header.Set(martynKeyHeader, key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET /api/playlists failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, body
+	}
+	if code, _ := get("", "?key="+alice.key); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a key in the URL, got %d", code)
+	}
+	code, body := get(bob.key, "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200 for Bob's session, got %d: %s", code, body)
+	}
+	assertNoKeys(t, "Bob's HTTP playlists", body, alice.key, bob.key)
+	if strings.Contains(string(body), `"share_token"`) {
+		t.Errorf("Expected no share token for Bob, got %s", body)
+	}
+
+	alice.send("playlist_unshare", map[string]string{"playlist_id": id, "public_id": bob.publicID})
+	json.Unmarshal(bob.read("playlists"), &lists)
+	if len(lists) != 0 {
+		t.Errorf("Expected Bob removed from the playlist, got %+v", lists)
+	}
+	alice.send("playlist_share", map[string]string{"playlist_id": id, "public_id": "nobody"})
+	if payload := string(alice.read("error")); !strings.Contains(payload, "singer not found") {
+		t.Errorf("Expected an unknown singer error, got %s", payload)
+	}
+}
+
+func TestPlaylistLinkJoinIsViewOnly(t *testing.T) {
+	app, _ := newTestApp(t)
+	go app.hub.Run()
+	srv := httptest.NewServer(app.routes())
+	defer srv.Close()
+
+	alice := dialGuest(t, srv, "Alice")
+	dave := dialGuest(t, srv, "Dave")
+
+	alice.send("playlist_create", map[string]interface{}{"name": "Party", "song_ids": []string{"s1"}})
+	var lists []models.PlaylistView
+	json.Unmarshal(alice.read("playlists"), &lists)
+	id := lists[0].ID
+	alice.send("playlist_share_link", map[string]string{"playlist_id": id})
+	var link struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(alice.read("playlist_share_link"), &link)
+
+	dave.send("playlist_join", map[string]string{"share_token": link.Token})
+	json.Unmarshal(dave.read("playlists"), &lists)
+	if len(lists) != 1 || lists[0].CanEdit {
+		t.Fatalf("Expected Dave to see the playlist view-only, got %+v", lists)
+	}
+	dave.send("playlist_remove_song", map[string]string{"playlist_id": id, "song_id": "s1"})
+	if payload := string(dave.read("error")); !strings.Contains(payload, "not allowed") {
+		t.Errorf("Expected a link-joiner's change to be refused, got %s", payload)
+	}
+	if p, _ := app.playlists.Get(id); len(p.SongIDs) != 1 {
+		t.Errorf("Expected the playlist unchanged, got %v", p.SongIDs)
+	}
+
+	// Alice grants edit explicitly
+	alice.send("playlist_share", map[string]interface{}{"playlist_id": id, "public_id": dave.publicID, "can_edit": true})
+	json.Unmarshal(dave.read("playlists"), &lists)
+	if len(lists) != 1 || !lists[0].CanEdit {
+		t.Fatalf("Expected Dave granted edit, got %+v", lists)
+	}
+	dave.send("playlist_remove_song", map[string]string{"playlist_id": id, "song_id": "s1"})
+	json.Unmarshal(dave.read("playlists"), &lists)
+	if len(lists[0].SongIDs) != 0 {
+		t.Errorf("Expected Dave's edit to apply, got %v", lists[0].SongIDs)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"songmartyn/internal/avatar"
+	"songmartyn/internal/mpv"
+	"songmartyn/internal/websocket"
+	"songmartyn/pkg/models"
+)
+
+// Guests react from their phones: their avatar floats up the display with a badge
+// (clap, heart, fire or laugh), and each performance's reactions are kept with its history
+
+const (
+	reactionSize     = 180              // Sprite size in pixels
+	reactionFrames   = 12               // Frames in one loop of the animation
+	reactionFPS      = 12               // Loop frame rate
+	reactionDuration = 4 * time.Second  // Time to float up the screen
+	reactionBurst    = 3                // Reactions a guest can send...
+	reactionWindow   = 10 * time.Second // ...in this long
+	reactionCacheMax = 16               // Rendered sprites kept (about 1.5 MB each)
+)
+
+// errReactionLimit is returned to a guest sending reactions too quickly
+var errReactionLimit = fmt.Errorf("Slow down - you can send %d reactions every %d seconds", reactionBurst, int(reactionWindow/time.Second))
+
+// reactionLimiter allows each guest reactionBurst reactions per reactionWindow
+type reactionLimiter struct {
+	mu   sync.Mutex
+	sent map[string][]time.Time // Recent reactions by MartynKey, oldest first
+}
+
+// allow records a reaction from a guest at now, or reports that they're over the limit
+func (l *reactionLimiter) allow(martynKey string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sent == nil {
+		l.sent = make(map[string][]time.Time)
+	}
+	// Forget guests whose reactions have all aged out, so the map only holds recent senders
+	for key, times := range l.sent {
+		if now.Sub(times[len(times)-1]) >= reactionWindow {
+			delete(l.sent, key)
+		}
+	}
+	recent := l.sent[martynKey]
+	for len(recent) > 0 && now.Sub(recent[0]) >= reactionWindow {
+		recent = recent[1:]
+	}
+	if len(recent) >= reactionBurst {
+		l.sent[martynKey] = recent
+		return false
+	}
+	l.sent[martynKey] = append(recent, now)
+	return true
+}
+
+// reactionKey identifies a rendered reaction sprite
+type reactionKey struct {
+	parts    [6]int
+	colors   avatar.Colors
+	reaction string
+}
+
+// reactionCache keeps recently rendered reaction sprites, so a guest reacting again
+// doesn't re-render their avatar on the websocket read path
+type reactionCache struct {
+	mu     sync.Mutex
+	frames map[reactionKey][]*image.RGBA
+	order  []reactionKey // Least recently used first
+}
+
+// get returns the frames for an avatar's reaction, rendering them on a miss
+// The frames are shared, so callers must not draw on them
+func (c *reactionCache) get(config avatar.Config, reaction string) ([]*image.RGBA, error) {
+	key := reactionKey{parts: [6]int{config.Env, config.Clo, config.Head, config.Mouth, config.Eyes, config.Top}, reaction: reaction}
+	if config.Colors != nil {
+		key.colors = *config.Colors
+	}
+
+	c.mu.Lock()
+	frames, ok := c.frames[key]
+	if ok {
+		c.touchLocked(key)
+	}
+	c.mu.Unlock()
+	if ok {
+		return frames, nil
+	}
+
+	// Render outside the lock so other guests' cached reactions aren't held up
+	frames, err := config.ReactionFrames(reaction, reactionSize, reactionFrames)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.frames == nil {
+		c.frames = make(map[reactionKey][]*image.RGBA)
+	}
+	if _, ok := c.frames[key]; !ok && len(c.order) >= reactionCacheMax {
+		delete(c.frames, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.frames[key] = frames
+	c.touchLocked(key)
+	return frames, nil
+}
+
+// touchLocked marks key as the most recently used; caller holds mu
+func (c *reactionCache) touchLocked(key reactionKey) {
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	c.order = append(c.order, key)
+}
+
+// avatarFor returns a guest's avatar, or the default one if they haven't made one
+func avatarFor(sess *models.Session) avatar.Config {
+	var config avatar.Config
+	if sess.AvatarConfig == nil {
+		return config
+	}
+	c := sess.AvatarConfig
+	config = avatar.Config{Env: c.Env, Clo: c.Clo, Head: c.Head, Mouth: c.Mouth, Eyes: c.Eyes, Top: c.Top}
+	if c.Colors != nil {
+		config.Colors = &avatar.Colors{
+			Env:   c.Colors.Env,
+			Clo:   c.Colors.Clo,
+			Head:  c.Colors.Head,
+			Mouth: c.Colors.Mouth,
+			Eyes:  c.Colors.Eyes,
+			Top:   c.Colors.Top,
+		}
+	}
+	config.Normalize()
+	return config
+}
+
+// handleReaction floats a guest's avatar with a reaction up the display
+// and counts it towards the song playing
+func (app *App) handleReaction(client *websocket.Client, reaction string) error {
+	sess := client.GetSession()
+	if sess == nil {
+		return nil
+	}
+	if !avatar.IsReaction(reaction) {
+		return fmt.Errorf("unknown reaction %q", reaction)
+	}
+	if !app.reactionLimit.allow(sess.MartynKey, time.Now()) {
+		return errReactionLimit
+	}
+
+	frames, err := app.reactionSprites.get(avatarFor(sess), reaction)
+	if err != nil {
+		return err
+	}
+	r := mpv.Reaction{
+		Frames:   frames,
+		FPS:      reactionFPS,
+		Label:    sess.DisplayName,
+		X:        rand.Float64(),
+		Duration: reactionDuration,
+	}
+	if err := app.mpv.ShowReaction(r); err != nil {
+		if errors.Is(err, mpv.ErrReactionsBusy) {
+			return fmt.Errorf("The screen is full of reactions - try again in a moment")
+		}
+		mpvLogger.Warn("Failed to show reaction", "err", err)
+		return fmt.Errorf("Reactions aren't available right now")
+	}
+	app.outputs.ShowReaction(r)
+
+	// Only reactions to a performance are kept
+	if !app.idle && app.queue.Current() != nil {
+		app.reactionMu.Lock()
+		if app.reactionCounts == nil {
+			app.reactionCounts = make(map[string]int)
+		}
+		app.reactionCounts[reaction]++
+		app.reactionMu.Unlock()
+	}
+	return nil
+}
+
+// finishReactions stores the reactions to the song that just ended with its song_history
+// row, or drops them when it didn't finish (historyID 0)
+func (app *App) finishReactions(historyID int64) {
+	app.reactionMu.Lock()
+	counts := app.reactionCounts
+	app.reactionCounts = nil
+	app.reactionMu.Unlock()
+
+	if historyID == 0 || len(counts) == 0 {
+		return
+	}
+	if err := app.library.SetHistoryReactions(historyID, counts); err != nil {
+		log.Printf("Failed to save reactions: %v", err)
+	}
+}
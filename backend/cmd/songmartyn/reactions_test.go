@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"songmartyn/internal/avatar"
+	"songmartyn/pkg/models"
+)
+
+// ============================================================================
+// Reaction Tests
+// ============================================================================
+
+func TestReactionsShownAndKeptWithThePerformance(t *testing.T) {
+	app, player := newTestApp(t)
+	go app.hub.Run()
+	srv := httptest.NewServer(app.routes())
+	defer srv.Close()
+
+	songsDir := t.TempDir()
+	os.WriteFile(filepath.Join(songsDir, "Toto - Africa.mp4"), []byte("x"), 0644)
+	loc, _ := app.library.AddLocation(songsDir, "Songs")
+	app.library.ScanLocation(loc.ID)
+	africa, _ := app.library.SearchSongs("Africa", 1)
+	if len(africa) != 1 {
+		t.Fatal("Expected Africa in the library")
+	}
+
+	alice := dialGuest(t, srv, "Alice")
+	bob := dialGuest(t, srv, "Bob")
+
+	// Reactions float over the holding screen too, but only a performance keeps them
+	alice.send("reaction", "heart")
+	waitFor(t, "Alice's reaction", func() bool { return len(player.Reactions()) == 1 })
+
+	app.queue.Add(queueSongFromLibrary(&africa[0], models.VocalOff, alice.key))
+	app.playCurrentSong()
+	for _, reaction := range []string{"clap", "fire", "clap"} {
+		bob.send("reaction", reaction)
+	}
+	waitFor(t, "Bob's reactions", func() bool { return len(player.Reactions()) == 4 })
+	if got := player.Reactions()[3]; got.Label != "Bob" || len(got.Frames) != reactionFrames {
+		t.Errorf("Expected Bob's animated reaction, got label %q with %d frames", got.Label, len(got.Frames))
+	}
+
+	// Guests can't flood the screen
+	bob.send("reaction", "clap")
+	if payload := string(bob.read("error")); !strings.Contains(payload, "Slow down") {
+		t.Errorf("Expected the rate limit error, got %s", payload)
+	}
+	alice.send("reaction", "boo")
+	if payload := string(alice.read("error")); !strings.Contains(payload, "unknown reaction") {
+		t.Errorf("Expected unknown reaction error, got %s", payload)
+	}
+	if n := len(player.Reactions()); n != 4 {
+		t.Errorf("Expected 4 reactions shown, got %d", n)
+	}
+
+	player.FinishTrack()
+	history, _ := app.library.GetUserHistory(alice.key, 1)
+	if len(history) != 1 {
+		t.Fatalf("Expected Alice's performance in her history, got %d entries", len(history))
+	}
+	if got := history[0].Reactions; len(got) != 2 || got["clap"] != 2 || got["fire"] != 1 {
+		t.Errorf("Expected clap 2 and fire 1, got %v", got)
+	}
+}
+
+func TestReactionLimiterWindow(t *testing.T) {
+	var l reactionLimiter
+	start := time.Now()
+	for i := 0; i < reactionBurst; i++ {
+		if !l.allow("alice", start) {
+			t.Fatalf("Expected reaction %d allowed", i+1)
+		}
+	}
+	if l.allow("alice", start.Add(time.Second)) {
+		t.Error("Expected reaction over the burst to be refused")
+	}
+	if !l.allow("bob", start.Add(time.Second)) {
+		t.Error("Expected other guests unaffected")
+	}
+	if !l.allow("alice", start.Add(reactionWindow)) {
+		t.Error("Expected reactions allowed again once the window passes")
+	}
+
+	// Guests who stop reacting are forgotten once their window passes
+	l.allow("carol", start.Add(2*reactionWindow))
+	if _, ok := l.sent["carol"]; !ok || len(l.sent) != 1 {
+		t.Errorf("Expected only carol tracked, got %v", l.sent)
+	}
+}
+
+func TestReactionCacheReusesSprites(t *testing.T) {
+	var c reactionCache
+	alice := avatar.Config{Env: 3, Clo: 20, Head: 5, Mouth: 7, Eyes: 40, Top: 11}
+
+	first, err := c.get(alice, "clap")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	again, _ := c.get(alice, "clap")
+	if &again[0] != &first[0] {
+		t.Error("Expected the same avatar and reaction to come from the cache")
+	}
+	if heart, _ := c.get(alice, "heart"); &heart[0] == &first[0] {
+		t.Error("Expected another reaction to render its own sprite")
+	}
+	recolored := alice
+	recolored.Colors = &avatar.Colors{Head: "#ff0000"}
+	if other, _ := c.get(recolored, "clap"); &other[0] == &first[0] {
+		t.Error("Expected a recolored avatar to render its own sprite")
+	}
+
+	// The least recently used sprites make way once the cache is full
+	for i := 0; i < reactionCacheMax; i++ {
+		c.get(avatar.Config{Env: i}, "fire")
+	}
+	if len(c.frames) != reactionCacheMax || len(c.order) != reactionCacheMax {
+		t.Errorf("Expected %d sprites kept, got %d (%d ordered)", reactionCacheMax, len(c.frames), len(c.order))
+	}
+	if again, _ := c.get(alice, "clap"); &again[0] == &first[0] {
+		t.Error("Expected the oldest sprite evicted")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"songmartyn/internal/library"
+)
+
+// ============================================================================
+// Recommendation Endpoint Tests
+// ============================================================================
+
+func TestRecommendationsRequireSession(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := app.sessions.GetOrCreate("", "Alice")
+
+	get := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if key != "" {
+			req.Header.Set(martynKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		app.handleLibraryRecommendations(w, req)
+		return w
+	}
+
+	// A key in the URL or a made-up key isn't a session
+	for _, tt := range []struct{ path, key string }{
+		{"/api/library/recommendations", ""},
+		{"/api/library/recommendations?key=" + alice.MartynKey, ""},
+		{"/api/library/recommendations", "not-a-session"},
+	} {
+		if w := get(tt.path, tt.key); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for %s with key %q, got %d", tt.path, tt.key, w.Code)
+		}
+	}
+
+	w := get("/api/library/recommendations?limit=5", alice.MartynKey)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for Alice's session, got %d: %s", w.Code, w.Body)
+	}
+	var recs []library.Recommendation
+	if err := json.Unmarshal(w.Body.Bytes(), &recs); err != nil {
+		t.Errorf("Expected a list of recommendations, got %s", w.Body)
+	}
+}
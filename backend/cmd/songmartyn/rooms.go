@@ -0,0 +1,523 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"songmartyn/internal/admin"
+	"songmartyn/internal/configfile"
+	"songmartyn/internal/mpv"
+	"songmartyn/internal/queue"
+	"songmartyn/internal/transition"
+	"songmartyn/internal/websocket"
+)
+
+// mainRoomName is how the main room is listed next to the extra rooms
+const mainRoomName = "Main Room"
+
+// Extra rooms run as Apps of their own around the venue's shared managers:
+// the library, sessions, playlists, holding screen themes, loudness analysis and
+// the servers belong to the venue (the main room), while each room has its own
+// queue, mpv player, holding screen, BGM, countdown and admin PIN.
+// Recording and live mic effects stay with the main room.
+
+// newRoomPlayer starts an extra room's player on its own mpv instance
+func newRoomPlayer(r configfile.Room) mpv.Player {
+	return mpv.NewRoomController(r.ID, r.Player.VideoPlayer)
+}
+
+// roomConfig is the configuration an extra room runs with: the venue's, with the room's
+// own player, holding screen, BGM and admin PIN, and its data under DataDir/rooms/<id>
+func roomConfig(venue Config, r configfile.Room) Config {
+	config := venue
+	config.DataDir = filepath.Join(venue.DataDir, "rooms", r.ID)
+	config.AdminPIN = r.AdminPIN
+	config.PlayerBackend = "mpv"
+	config.VideoPlayer = r.Player.VideoPlayer
+	config.TargetDisplay = r.Player.TargetDisplay
+	config.AutoFullscreen = r.Player.AutoFullscreen
+	config.HoldingMessage = r.Holding.Message
+	config.HoldingTheme = r.Holding.Theme
+	config.BGM = bgmFromFile(r.BGM)
+	config.MicEffectsEnabled = false
+	config.RecordingEnabled = false
+	config.MDNSHostname = ""
+	config.Rooms = nil
+	return config
+}
+
+// newRoom creates an extra room around the venue's shared managers
+// The room's player isn't started until startRoom
+func newRoom(venue *App, r configfile.Room) (*App, error) {
+	config := roomConfig(venue.config, r)
+	if err := os.MkdirAll(config.DataDir, 0755); err != nil {
+		return nil, err
+	}
+	queueMgr, err := queue.NewManager(filepath.Join(config.DataDir, "queue.db"))
+	if err != nil {
+		return nil, err
+	}
+	player := venue.roomPlayer(r)
+
+	room := &App{
+		config:         config,
+		mpv:            player,
+		outputs:        mpv.NewOutputs(player, config.VideoPlayer), // Extra output screens are a main room feature
+		hub:            venue.hub.Room(r.ID),
+		sessions:       venue.sessions,
+		queue:          queueMgr,
+		admin:          admin.NewManager(r.AdminPIN),
+		library:        venue.library,
+		playlists:      venue.playlists,
+		holdingScreen:  newHoldingScreen(config, venue.holdingThemes),
+		holdingThemes:  venue.holdingThemes,
+		loudnessJob:    venue.loudnessJob,
+		stopped:        venue.stopped,
+		logs:           venue.logs,
+		metrics:        venue.metrics,
+		restart:        venue.restart,
+		holdingMessage: config.HoldingMessage,
+		countdownTick:  venue.countdownTick,
+		transitions:    transition.NewScheduler(transition.SystemClock{}, player, transitionSettings(config)),
+		bgmSettings:    config.BGM,
+		roomID:         r.ID,
+		roomName:       r.Name,
+		venue:          venue,
+		done:           make(chan struct{}),
+	}
+	room.applyDisplaySettings()
+	room.admin.SetOnAdminAuth(room.markRoomAdmin)
+	queueMgr.SetFairRotation(config.FairRotationEnabled)
+	room.setupHandlers()
+	return room, nil
+}
+
+// addRoom creates an extra room and adds it to the venue
+func (app *App) addRoom(r configfile.Room) error {
+	room, err := newRoom(app, r)
+	if err != nil {
+		return err
+	}
+	app.roomsMu.Lock()
+	if app.rooms == nil {
+		app.rooms = make(map[string]*App)
+	}
+	app.rooms[r.ID] = room
+	app.roomsMu.Unlock()
+	return nil
+}
+
+// startRoom starts an extra room's player and background work
+func (app *App) startRoom() {
+	go app.runRecommendationPush()
+	go app.runPositionTicks()
+	go app.runHoldingClock()
+
+	if err := app.startPlayer(); err != nil {
+		mpvLogger.Warn("Failed to start mpv", "room", app.roomID, "err", err)
+		return
+	}
+	mpvLogger.Info("mpv started", "room", app.roomID)
+
+	go func() {
+		// After a hot restart, carry on with whatever the adopted player is showing
+		if !app.resumeAfterRestart() {
+			app.showHoldingScreen()
+		}
+	}()
+}
+
+// stopRoom stops an extra room's player and background work and closes its queue
+// keepPlayer leaves mpv running for a restarted process to adopt
+func (app *App) stopRoom(keepPlayer bool) {
+	close(app.done)
+	app.stopCountdown()
+	app.transitions.Stop()
+	if keepPlayer {
+		app.mpv.Detach()
+	} else {
+		app.mpv.Stop()
+	}
+	app.queue.Close()
+}
+
+// removeRoom stops an extra room and sends its guests away
+// The room's queue stays under DataDir/rooms/<id> in case it's added back
+func (app *App) removeRoom(id string) {
+	app.roomsMu.Lock()
+	room := app.rooms[id]
+	delete(app.rooms, id)
+	app.roomsMu.Unlock()
+	if room == nil {
+		return
+	}
+	app.hub.RemoveRoom(id, "This room has closed")
+	room.stopRoom(false)
+	log.Printf("Room %s removed", id)
+}
+
+// syncRooms adds, updates and removes extra rooms to match next
+func (app *App) syncRooms(next Config) {
+	// Room data stays where this process keeps it until a restart moves it
+	venue := next
+	venue.DataDir = app.config.DataDir
+
+	wanted := make(map[string]bool)
+	for _, r := range next.Rooms {
+		wanted[r.ID] = true
+	}
+	for _, room := range app.roomList() {
+		if !wanted[room.roomID] {
+			app.removeRoom(room.roomID)
+		}
+	}
+
+	for _, r := range next.Rooms {
+		if room := app.room(r.ID); room != nil {
+			room.roomName = r.Name
+			room.applyRoomSettings(roomConfig(venue, r))
+			continue
+		}
+		if err := app.addRoom(r); err != nil {
+			log.Printf("Failed to add room %s: %v", r.ID, err)
+			continue
+		}
+		log.Printf("Room %s added", r.ID)
+		if app.handler != nil {
+			app.room(r.ID).startRoom()
+		}
+	}
+	app.config.Rooms = next.Rooms
+}
+
+// room returns the room with an ID, the main room for "" or "main", or nil
+func (app *App) room(id string) *App {
+	if id == "" || id == websocket.DefaultRoom {
+		return app
+	}
+	app.roomsMu.RLock()
+	defer app.roomsMu.RUnlock()
+	return app.rooms[id]
+}
+
+// roomList returns the extra rooms ordered by ID
+func (app *App) roomList() []*App {
+	app.roomsMu.RLock()
+	rooms := make([]*App, 0, len(app.rooms))
+	for _, room := range app.rooms {
+		rooms = append(rooms, room)
+	}
+	app.roomsMu.RUnlock()
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].roomID < rooms[j].roomID })
+	return rooms
+}
+
+// venueApp returns the main room, which owns the servers and shared managers
+func (app *App) venueApp() *App {
+	if app.venue != nil {
+		return app.venue
+	}
+	return app
+}
+
+// roomQuery is what join links add to the app's address to land in this room
+func (app *App) roomQuery() string {
+	if app.venue == nil {
+		return ""
+	}
+	return "/?room=" + url.QueryEscape(app.roomID)
+}
+
+// markRoomAdmin records a guest who signed in with the room's own PIN
+func (app *App) markRoomAdmin(martynKey string) {
+	app.roomAdminsMu.Lock()
+	defer app.roomAdminsMu.Unlock()
+	if app.roomAdmins == nil {
+		app.roomAdmins = make(map[string]bool)
+	}
+	app.roomAdmins[martynKey] = true
+	log.Printf("Room %s: session signed in as room admin", app.roomID)
+}
+
+// isRoomAdmin reports whether a client signed in with the room's own PIN
+func (app *App) isRoomAdmin(client *websocket.Client) bool {
+	sess := client.GetSession()
+	if sess == nil {
+		return false
+	}
+	app.roomAdminsMu.Lock()
+	defer app.roomAdminsMu.Unlock()
+	return app.roomAdmins[sess.MartynKey]
+}
+
+// saveRoomSettings turns a change to the main room's sections into one to this room's
+// [[rooms]] entry, so handlers save the same way in every room
+func (app *App) saveRoomSettings(update func(*configfile.File)) func(*configfile.File) {
+	return func(f *configfile.File) {
+		for i := range f.Rooms {
+			if f.Rooms[i].ID != app.roomID {
+				continue
+			}
+			scratch := configfile.File{BGM: f.Rooms[i].BGM, Holding: f.Rooms[i].Holding}
+			update(&scratch)
+			f.Rooms[i].BGM, f.Rooms[i].Holding = scratch.BGM, scratch.Holding
+		}
+	}
+}
+
+// withRoom serves a request for the room named by ?room= (the main room without one)
+func (app *App) withRoom(handler func(*App, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		room := app.room(r.URL.Query().Get("room"))
+		if room == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Unknown room"})
+			return
+		}
+		handler(room, w, r)
+	}
+}
+
+// inRoom is withRoom for admin endpoints
+// Venue admins can manage every room; a room's own PIN only unlocks that room
+func (app *App) inRoom(handler func(*App, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return app.withRoom(func(room *App, w http.ResponseWriter, r *http.Request) {
+		if room != app && room.admin.IsAuthorized(r) {
+			handler(room, w, r)
+			return
+		}
+		app.admin.Middleware(func(w http.ResponseWriter, r *http.Request) {
+			handler(room, w, r)
+		})(w, r)
+	})
+}
+
+// handleRoomAuth handles POST /api/admin/auth?room= - sign in with the room's PIN
+func (app *App) handleRoomAuth(w http.ResponseWriter, r *http.Request) {
+	app.admin.HandleAuth(w, r)
+}
+
+// handleRoomCheckAuth handles GET /api/admin/check?room=
+// Venue admins count as admins of every room
+func (app *App) handleRoomCheckAuth(w http.ResponseWriter, r *http.Request) {
+	if app.venue != nil && app.venue.admin.IsAuthorized(r) {
+		app.venue.admin.HandleCheckAuth(w, r)
+		return
+	}
+	app.admin.HandleCheckAuth(w, r)
+}
+
+// roomInfo is a room as listed for guests choosing where to sing
+type roomInfo struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	JoinURL string `json:"join_url"`
+	Clients int    `json:"clients"` // Open connections
+	Waiting int    `json:"waiting"` // Songs queued from the one playing onward
+}
+
+// adminRoomInfo adds what venue admins see about a room
+type adminRoomInfo struct {
+	roomInfo
+	HasPIN        bool   `json:"has_pin"`
+	PlayerRunning bool   `json:"player_running"`
+	TargetDisplay string `json:"target_display"`
+}
+
+// allRooms returns the main room followed by the extra rooms
+func (app *App) allRooms() []*App {
+	return append([]*App{app}, app.roomList()...)
+}
+
+// info describes the room for the room list
+func (app *App) info(connectURL string, clients map[string]int) roomInfo {
+	id, name := websocket.DefaultRoom, mainRoomName
+	if app.venue != nil {
+		id, name = app.roomID, app.roomName
+	}
+	state := app.queue.GetState()
+	return roomInfo{
+		ID:      id,
+		Name:    name,
+		JoinURL: connectURL + app.roomQuery(),
+		Clients: clients[id],
+		Waiting: max(len(state.Songs)-state.Position, 0),
+	}
+}
+
+// handleRooms handles GET /api/rooms (public) - the rooms a phone can join
+func (app *App) handleRooms(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	connectURL, clients := app.getConnectURL(), app.hub.RoomCounts()
+	rooms := make([]roomInfo, 0, len(app.rooms)+1)
+	for _, room := range app.allRooms() {
+		rooms = append(rooms, room.info(connectURL, clients))
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"rooms": rooms})
+}
+
+// roomRequest is the body of POST /api/admin/rooms and PUT /api/admin/rooms/<id>
+// Fields left out of a PUT keep their value
+type roomRequest struct {
+	ID             string  `json:"id"`
+	Name           *string `json:"name"`
+	AdminPIN       *string `json:"admin_pin"`
+	VideoPlayer    *string `json:"video_player"`
+	TargetDisplay  *string `json:"target_display"`
+	AutoFullscreen *bool   `json:"auto_fullscreen"`
+}
+
+// apply copies the fields that were sent onto r
+func (req roomRequest) apply(r *configfile.Room) {
+	if req.Name != nil {
+		r.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.AdminPIN != nil {
+		r.AdminPIN = *req.AdminPIN
+	}
+	if req.VideoPlayer != nil {
+		r.Player.VideoPlayer = *req.VideoPlayer
+	}
+	if req.TargetDisplay != nil {
+		r.Player.TargetDisplay = *req.TargetDisplay
+	}
+	if req.AutoFullscreen != nil {
+		r.Player.AutoFullscreen = *req.AutoFullscreen
+	}
+}
+
+// errNoRoom is returned when a room to change isn't in the configuration file
+var errNoRoom = errors.New("room not found")
+
+// handleAdminRooms handles GET/POST /api/admin/rooms
+// GET lists every room; POST adds one to the configuration file and opens it
+func (app *App) handleAdminRooms(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		connectURL, clients := app.getConnectURL(), app.hub.RoomCounts()
+		rooms := make([]adminRoomInfo, 0, len(app.rooms)+1)
+		for _, room := range app.allRooms() {
+			rooms = append(rooms, adminRoomInfo{
+				roomInfo:      room.info(connectURL, clients),
+				HasPIN:        room.config.AdminPIN != "",
+				PlayerRunning: room.mpv.IsRunning(),
+				TargetDisplay: room.config.TargetDisplay,
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"rooms": rooms})
+
+	case http.MethodPost:
+		var req roomRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+			return
+		}
+		room := configfile.NewRoom(strings.TrimSpace(req.ID), "")
+		req.apply(&room)
+		app.changeRooms(w, http.StatusCreated, func(f *configfile.File) error {
+			f.Rooms = append(f.Rooms, room)
+			return nil
+		})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminRoomAction handles PUT/DELETE /api/admin/rooms/<id>
+// PUT changes a room's name, PIN or player; DELETE closes it
+func (app *App) handleAdminRoomAction(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	parts := splitPath(strings.TrimPrefix(r.URL.Path, "/api/admin/rooms/"))
+	if len(parts) != 1 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	id := parts[0]
+
+	switch r.Method {
+	case http.MethodPut:
+		var req roomRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+			return
+		}
+		app.changeRooms(w, http.StatusOK, func(f *configfile.File) error {
+			for i := range f.Rooms {
+				if f.Rooms[i].ID == id {
+					req.apply(&f.Rooms[i])
+					return nil
+				}
+			}
+			return errNoRoom
+		})
+
+	case http.MethodDelete:
+		app.changeRooms(w, http.StatusOK, func(f *configfile.File) error {
+			for i := range f.Rooms {
+				if f.Rooms[i].ID == id {
+					f.Rooms = append(f.Rooms[:i], f.Rooms[i+1:]...)
+					return nil
+				}
+			}
+			return errNoRoom
+		})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// changeRooms saves a change to the [[rooms]] entries and reloads, so rooms open and
+// close the same way as when the configuration file is edited by hand
+func (app *App) changeRooms(w http.ResponseWriter, status int, change func(*configfile.File) error) {
+	if app.config.ConfigPath == "" {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Rooms are kept in the configuration file, and this server doesn't have one"})
+		return
+	}
+
+	var changeErr error
+	err := app.saveSettings(func(f *configfile.File) {
+		changeErr = change(f)
+	})
+	if changeErr != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Room not found"})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if _, err := app.reloadSettings(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(status)
+	app.roomsMu.RLock()
+	count := len(app.rooms)
+	app.roomsMu.RUnlock()
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "rooms": count + 1})
+}
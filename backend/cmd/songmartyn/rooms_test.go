@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"songmartyn/internal/configfile"
+	"songmartyn/internal/mpv"
+)
+
+// ============================================================================
+// Room Tests
+// ============================================================================
+
+// useRoomPlayers gives rooms added from now on fake players, by room ID
+func useRoomPlayers(app *App) map[string]*mpv.FakePlayer {
+	players := make(map[string]*mpv.FakePlayer)
+	app.roomPlayer = func(r configfile.Room) mpv.Player {
+		players[r.ID] = mpv.NewFakePlayer()
+		return players[r.ID]
+	}
+	return players
+}
+
+// newRoomTestApp is a test app with a configuration file for rooms to be saved to
+func newRoomTestApp(t *testing.T) (*App, map[string]*mpv.FakePlayer) {
+	t.Helper()
+	app, _ := newTestApp(t)
+	app.config.ConfigPath = filepath.Join(t.TempDir(), "songmartyn.toml")
+	f := configfile.Defaults()
+	f.Server.MDNSHostname = ""
+	if err := configfile.Save(app.config.ConfigPath, f); err != nil {
+		t.Fatal(err)
+	}
+	return app, useRoomPlayers(app)
+}
+
+func TestRoomsAddedAndRemovedThroughAdminAPI(t *testing.T) {
+	app, players := newRoomTestApp(t)
+
+	rec := httptest.NewRecorder()
+	app.handleAdminRooms(rec, httptest.NewRequest(http.MethodPost, "/api/admin/rooms",
+		strings.NewReader(`{"id": "bar", "name": "The Bar", "admin_pin": "4321", "target_display": "HDMI-2"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected the room to be added, got %d: %s", rec.Code, rec.Body.String())
+	}
+	bar := app.room("bar")
+	if bar == nil || players["bar"] == nil {
+		t.Fatal("Expected bar to be open with its own player")
+	}
+	if bar.roomName != "The Bar" || bar.config.TargetDisplay != "HDMI-2" {
+		t.Errorf("Expected bar's settings applied, got %q on %q", bar.roomName, bar.config.TargetDisplay)
+	}
+	saved, err := configfile.Load(app.config.ConfigPath)
+	if err != nil || len(saved.Rooms) != 1 || saved.Rooms[0].AdminPIN != "4321" {
+		t.Fatalf("Expected bar saved to the configuration file, got %+v (%v)", saved.Rooms, err)
+	}
+
+	// Names the schema rejects aren't added
+	rec = httptest.NewRecorder()
+	app.handleAdminRooms(rec, httptest.NewRequest(http.MethodPost, "/api/admin/rooms",
+		strings.NewReader(`{"id": "main", "name": "Another Main"}`)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "rooms[1].id") {
+		t.Errorf("Expected an error naming rooms[1].id, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	app.handleAdminRoomAction(rec, httptest.NewRequest(http.MethodPut, "/api/admin/rooms/bar",
+		strings.NewReader(`{"name": "Upstairs Bar"}`)))
+	if rec.Code != http.StatusOK || bar.roomName != "Upstairs Bar" {
+		t.Errorf("Expected bar renamed, got %d %q", rec.Code, bar.roomName)
+	}
+	rec = httptest.NewRecorder()
+	app.handleAdminRoomAction(rec, httptest.NewRequest(http.MethodPut, "/api/admin/rooms/cellar",
+		strings.NewReader(`{"name": "Cellar"}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown room, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	app.handleAdminRoomAction(rec, httptest.NewRequest(http.MethodDelete, "/api/admin/rooms/bar", nil))
+	if rec.Code != http.StatusOK || app.room("bar") != nil {
+		t.Fatalf("Expected bar removed, got %d", rec.Code)
+	}
+	if app.hub.HasRoom("bar") {
+		t.Error("Expected guests to be unable to join bar")
+	}
+	// Its queue is kept for if it opens again
+	if _, err := os.Stat(filepath.Join(app.config.DataDir, "rooms", "bar", "queue.db")); err != nil {
+		t.Errorf("Expected bar's queue kept: %v", err)
+	}
+}
+
+func TestRoomsHaveTheirOwnQueueAndAdmins(t *testing.T) {
+	app, _ := newRoomTestApp(t)
+	if err := app.addRoom(configfile.NewRoom("bar", "The Bar")); err != nil {
+		t.Fatal(err)
+	}
+	bar := app.room("bar")
+	bar.admin.SetPIN("4321")
+
+	queueTestSong(t, bar, "s1", "alice")
+	if songs := app.queue.GetState().Songs; len(songs) != 0 {
+		t.Errorf("Expected the main queue untouched, got %d songs", len(songs))
+	}
+
+	// Guests list the rooms to pick one
+	rec := httptest.NewRecorder()
+	app.handleRooms(rec, httptest.NewRequest(http.MethodGet, "/api/rooms", nil))
+	var listed struct {
+		Rooms []roomInfo `json:"rooms"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &listed)
+	if len(listed.Rooms) != 2 || listed.Rooms[0].ID != "main" || listed.Rooms[1].ID != "bar" {
+		t.Fatalf("Expected main and bar listed, got %s", rec.Body.String())
+	}
+	if listed.Rooms[1].Waiting != 1 || !strings.HasSuffix(listed.Rooms[1].JoinURL, "/?room=bar") {
+		t.Errorf("Expected bar's queue and join link, got %+v", listed.Rooms[1])
+	}
+
+	// The room's PIN opens its own admin endpoints and no others
+	handler := app.routes()
+	request := func(target, token string) int {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.RemoteAddr = "192.168.1.20:50000"
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	token := bar.admin.GenerateToken("guest-key")
+	if code := request("/api/admin/queue?room=bar", token); code != http.StatusOK {
+		t.Errorf("Expected bar's PIN to open bar's queue, got %d", code)
+	}
+	if code := request("/api/admin/queue", token); code == http.StatusOK {
+		t.Error("Expected bar's PIN not to open the main queue")
+	}
+	if code := request("/api/admin/rooms", token); code == http.StatusOK {
+		t.Error("Expected bar's PIN not to manage rooms")
+	}
+	if code := request("/api/admin/queue?room=cellar", token); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown room, got %d", code)
+	}
+}
+
+func TestRoomSettingsSavedToTheirEntry(t *testing.T) {
+	app, _ := newRoomTestApp(t)
+	f, _ := configfile.Load(app.config.ConfigPath)
+	f.Rooms = []configfile.Room{configfile.NewRoom("bar", "The Bar")}
+	if err := configfile.Save(app.config.ConfigPath, f); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.reloadSettings(); err != nil {
+		t.Fatal(err)
+	}
+	bar := app.room("bar")
+	if bar == nil {
+		t.Fatal("Expected bar opened from the configuration file")
+	}
+
+	rec := httptest.NewRecorder()
+	bar.handleBGM(rec, httptest.NewRequest(http.MethodPost, "/api/admin/bgm?room=bar",
+		strings.NewReader(`{"enabled": true, "source_type": "icecast", "url": "https://example.com/bar", "volume": 20}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected the BGM update to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	saved, err := configfile.Load(app.config.ConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.BGM.URL != "" || saved.Rooms[0].BGM.URL != "https://example.com/bar" {
+		t.Errorf("Expected the BGM saved to bar only, got %q and %q", saved.BGM.URL, saved.Rooms[0].BGM.URL)
+	}
+	if app.bgmSettings.URL != "" {
+		t.Error("Expected the main room's BGM untouched")
+	}
+}
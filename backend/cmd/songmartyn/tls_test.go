@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"songmartyn/internal/mpv"
+)
+
+// ============================================================================
+// Local CA Tests
+// ============================================================================
+
+func TestLocalCAServesTLSWithoutCertFiles(t *testing.T) {
+	dataDir := t.TempDir()
+	config := Config{DataDir: dataDir, Port: "8443", HTTPPort: "8080", LocalCA: true, CertFile: filepath.Join(dataDir, "missing.pem")}
+	app, err := newAppWithPlayer(config, mpv.NewFakePlayer())
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	t.Cleanup(app.Shutdown)
+
+	if app.certs == nil {
+		t.Fatal("Expected the local CA to be used")
+	}
+	cert, err := app.certs.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("Expected a certificate at startup: %v", err)
+	}
+	if err := cert.Leaf.VerifyHostname("localhost"); err != nil {
+		t.Errorf("Expected the certificate to cover localhost: %v", err)
+	}
+
+	join := app.certInstallURL("https://karaoke.local:8443")
+	if join != "http://karaoke.local:8080/ca/" {
+		t.Errorf("Expected the install page on the HTTP port, got %s", join)
+	}
+	if content := app.holdingScreenContent(); !strings.HasPrefix(content.JoinURL, "http://") {
+		t.Errorf("Expected the holding screen QR to open the install page, got %q", content.JoinURL)
+	}
+
+	// Configured certificate files take precedence
+	os.WriteFile(filepath.Join(dataDir, "cert.pem"), []byte("cert"), 0644)
+	os.WriteFile(filepath.Join(dataDir, "key.pem"), []byte("key"), 0600)
+	config.DataDir = t.TempDir()
+	config.CertFile = filepath.Join(dataDir, "cert.pem")
+	config.KeyFile = filepath.Join(dataDir, "key.pem")
+	own, err := newAppWithPlayer(config, mpv.NewFakePlayer())
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	t.Cleanup(own.Shutdown)
+	if own.certs != nil || own.certInstallURL("https://karaoke.local:8443") != "" {
+		t.Error("Expected configured certificate files to be used instead of the local CA")
+	}
+}
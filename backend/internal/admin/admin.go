@@ -1,6 +1,7 @@
 package admin
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
@@ -151,8 +152,21 @@ func (m *Manager) CleanupExpiredTokens() {
 	}
 }
 
+// trustedKey marks requests from a local administrator that didn't come over TCP
+type trustedKey struct{}
+
+// Trusted marks r as coming from a local administrator, e.g. over the admin socket
+// The admin checks treat it like a request from localhost
+func Trusted(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), trustedKey{}, true))
+}
+
 // IsLocalRequest checks if a request is from localhost
 func IsLocalRequest(r *http.Request) bool {
+	if trusted, _ := r.Context().Value(trustedKey{}).(bool); trusted {
+		return true
+	}
+
 	host, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
 		host = r.RemoteAddr
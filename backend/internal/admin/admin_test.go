@@ -379,6 +379,30 @@ func TestMiddleware_LocalRequest(t *testing.T) {
 	}
 }
 
+func TestMiddleware_TrustedRequest(t *testing.T) {
+	m := NewManager("")
+
+	handlerCalled := false
+	handler := m.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		handlerCalled = true
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// Admin socket requests have no IP address at all
+	req := mockRemoteRequest("GET", "/api/admin/test", nil)
+	req.RemoteAddr = "@"
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	if handlerCalled {
+		t.Fatal("Expected an untrusted request without an IP to be rejected")
+	}
+
+	handler(httptest.NewRecorder(), Trusted(req))
+	if !handlerCalled {
+		t.Error("Expected handler to be called for a trusted request")
+	}
+}
+
 func TestMiddleware_RemoteWithValidToken(t *testing.T) {
 	m := NewManager("1234")
 
@@ -0,0 +1,141 @@
+package avatar
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"math/rand/v2"
+)
+
+// IdleFPS is the frame rate idle animations are made for
+const IdleFPS = 12
+
+// IdleFrameCount is the length of an idle loop: three seconds at IdleFPS
+const IdleFrameCount = 36
+
+// IdleOptions control an idle animation
+type IdleOptions struct {
+	Size       int   // Frame size in pixels
+	Frames     int   // Frames in the loop (IdleFrameCount if 0)
+	Seed       int64 // Picks when the avatar blinks and how its mouth moves
+	IncludeEnv bool  // Draw the background; without it frames are transparent
+}
+
+// blinkScale is how far the eyes close on each frame of a blink
+var blinkScale = []float64{0.55, 0.1, 0.55}
+
+// DefaultSeed is the seed an avatar animates with when none is given,
+// so the same avatar always moves the same way
+func (c Config) DefaultSeed() int64 {
+	var h uint64 = 14695981039346656037 // FNV-1a
+	for _, b := range []byte(c.ToJSON()) {
+		h ^= uint64(b)
+		h *= 1099511628211
+	}
+	return int64(h >> 1)
+}
+
+// IdleFrames renders an idle loop built from the avatar's own parts: the eyes blink and
+// the mouth moves while everything else holds still. The same config, options and seed
+// always give the same frames.
+func (c Config) IdleFrames(opts IdleOptions) ([]*image.RGBA, error) {
+	if opts.Frames == 0 {
+		opts.Frames = IdleFrameCount
+	}
+	if opts.Size <= 0 || opts.Frames <= 0 {
+		return nil, fmt.Errorf("invalid animation size %d or frame count %d", opts.Size, opts.Frames)
+	}
+	c.Normalize()
+	size := float64(opts.Size)
+	rng := rand.New(rand.NewPCG(uint64(opts.Seed), 0x1d1e))
+
+	// Parts drawn under and over the moving ones are the same on every frame
+	under := c.partSVG("head", c.Head) + c.partSVG("clo", c.Clo)
+	if opts.IncludeEnv {
+		under = c.partSVG("env", c.Env) + under
+	}
+	base := image.NewRGBA(image.Rect(0, 0, opts.Size, opts.Size))
+	if err := drawSVG(base, SvgStart+under+SvgEnd, 0, 0, size); err != nil {
+		return nil, err
+	}
+	top := image.NewRGBA(base.Bounds())
+	if err := drawSVG(top, SvgStart+c.partSVG("top", c.Top)+SvgEnd, 0, 0, size); err != nil {
+		return nil, err
+	}
+
+	// Scale the eyes and mouth about their own middles
+	eyes, mouth := c.partSVG("eyes", c.Eyes), c.partSVG("mouth", c.Mouth)
+	eyesTop, eyesBottom, err := partRows(eyes)
+	if err != nil {
+		return nil, err
+	}
+	mouthTop, _, err := partRows(mouth)
+	if err != nil {
+		return nil, err
+	}
+	eyeLine := (eyesTop + eyesBottom) / 2
+
+	// The seed picks when the blink starts, whether it's a double blink,
+	// and how often and how far the mouth opens
+	blinkAt := rng.IntN(max(1, opts.Frames-len(blinkScale)))
+	doubleBlink := opts.Frames >= 2*len(blinkScale)+2 && rng.IntN(3) == 0
+	mouthCycles := 1 + rng.IntN(2)
+	mouthOpen := 0.12 + 0.18*rng.Float64()
+	mouthPhase := 2 * math.Pi * rng.Float64()
+
+	frames := make([]*image.RGBA, opts.Frames)
+	for i := range frames {
+		eyeScale := 1.0
+		for b, scale := range blinkScale {
+			if i == blinkAt+b || (doubleBlink && i == (blinkAt+len(blinkScale)+1+b)%opts.Frames) {
+				eyeScale = scale
+			}
+		}
+		t := 2 * math.Pi * float64(mouthCycles*i) / float64(opts.Frames)
+		mouthScale := 1 + mouthOpen*(0.5-0.5*math.Cos(t+mouthPhase))
+
+		img := image.NewRGBA(base.Bounds())
+		copy(img.Pix, base.Pix)
+		moving := scaledPart(mouth, mouthTop, mouthScale) + scaledPart(eyes, eyeLine, eyeScale)
+		if err := drawSVG(img, SvgStart+moving+SvgEnd, 0, 0, size); err != nil {
+			return nil, err
+		}
+		draw.Draw(img, img.Bounds(), top, image.Point{}, draw.Over)
+		frames[i] = img
+	}
+	return frames, nil
+}
+
+// scaledPart wraps a part to scale it vertically about the line y (in viewBox units)
+func scaledPart(part string, y, scale float64) string {
+	if scale == 1 {
+		return part
+	}
+	return fmt.Sprintf(`<g transform="matrix(1 0 0 %.4f 0 %.4f)">%s</g>`, scale, y*(1-scale), part)
+}
+
+// partRows finds the first and last rows a part covers, in viewBox units
+func partRows(part string) (top, bottom float64, err error) {
+	const size = 231 // One pixel per viewBox unit
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	if err := drawSVG(img, SvgStart+part+SvgEnd, 0, 0, size); err != nil {
+		return 0, 0, err
+	}
+	first, last := -1, -1
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if img.Pix[img.PixOffset(x, y)+3] != 0 {
+				if first < 0 {
+					first = y
+				}
+				last = y
+				break
+			}
+		}
+	}
+	if first < 0 {
+		return size / 2, size / 2, nil // Nothing drawn, nothing to move
+	}
+	return float64(first), float64(last + 1), nil
+}
@@ -311,20 +311,23 @@ func (c Config) ToSVGWithEnv(includeEnv bool) string {
 		if pc.name == "env" && !includeEnv {
 			continue
 		}
-
-		designIdx, variant := getDesignAndVariant(pc.value)
-		svgTemplate := getSVG(pc.name, designIdx)
-		colors := getColors(pc.name, designIdx, variant)
-		customColor := c.getCustomColor(pc.name)
-
-		// Apply colors to the SVG template (with optional custom color override)
-		partSVG := applyColors(svgTemplate, colors, customColor)
-		parts = append(parts, partSVG)
+		parts = append(parts, c.partSVG(pc.name, pc.value))
 	}
 
 	return SvgStart + strings.Join(parts, "") + SvgEnd
 }
 
+// partSVG returns one part's SVG elements with its colors applied
+func (c Config) partSVG(name string, value int) string {
+	designIdx, variant := getDesignAndVariant(value)
+	svgTemplate := getSVG(name, designIdx)
+	colors := getColors(name, designIdx, variant)
+	customColor := c.getCustomColor(name)
+
+	// Apply colors to the SVG template (with optional custom color override)
+	return applyColors(svgTemplate, colors, customColor)
+}
+
 // Normalize ensures all values are within valid range
 func (c *Config) Normalize() {
 	normalize := func(v int) int {
@@ -986,8 +989,15 @@ func (c Config) Preview() map[string]string {
 
 // ToImage generates a rasterized image of the avatar at the specified size
 func (c Config) ToImage(size int, includeEnv bool) (image.Image, error) {
-	svg := c.ToSVGWithEnv(includeEnv)
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	if err := drawSVG(img, c.ToSVGWithEnv(includeEnv), 0, 0, float64(size)); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
 
+// drawSVG rasterizes a square SVG onto img at x, y, scaled to size pixels
+func drawSVG(img *image.RGBA, svg string, x, y, size float64) error {
 	// Normalize SVG: convert inline style attributes to element attributes
 	// This improves oksvg compatibility, especially for stroke properties
 	svg = NormalizeSVG(svg)
@@ -995,19 +1005,16 @@ func (c Config) ToImage(size int, includeEnv bool) (image.Image, error) {
 	// Parse SVG
 	icon, err := oksvg.ReadIconStream(bytes.NewReader([]byte(svg)))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse SVG: %w", err)
+		return fmt.Errorf("failed to parse SVG: %w", err)
 	}
 
-	// Set target size
-	icon.SetTarget(0, 0, float64(size), float64(size))
-
-	// Create RGBA image
-	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	// Set target position and size
+	icon.SetTarget(x, y, size, size)
 
 	// Rasterize
-	scanner := rasterx.NewScannerGV(size, size, img, img.Bounds())
-	raster := rasterx.NewDasher(size, size, scanner)
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	scanner := rasterx.NewScannerGV(w, h, img, img.Bounds())
+	raster := rasterx.NewDasher(w, h, scanner)
 	icon.Draw(raster, 1.0)
-
-	return img, nil
+	return nil
 }
@@ -1,6 +1,7 @@
 package avatar
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 )
@@ -143,3 +144,53 @@ func TestToImageWithCustomColors(t *testing.T) {
 		t.Errorf("Image size = %dx%d, want 128x128", bounds.Dx(), bounds.Dy())
 	}
 }
+
+func TestReactionFramesDeterministic(t *testing.T) {
+	config := Config{Env: 3, Clo: 20, Head: 5, Mouth: 7, Eyes: 40, Top: 11}
+
+	first, err := config.ReactionFrames("heart", 96, 6)
+	if err != nil {
+		t.Fatalf("ReactionFrames failed: %v", err)
+	}
+	second, _ := config.ReactionFrames("heart", 96, 6)
+
+	if len(first) != 6 {
+		t.Fatalf("Expected 6 frames, got %d", len(first))
+	}
+	for i := range first {
+		if b := first[i].Bounds(); b.Dx() != 96 || b.Dy() != 96 {
+			t.Errorf("Frame %d size = %dx%d, want 96x96", i, b.Dx(), b.Dy())
+		}
+		if !bytes.Equal(first[i].Pix, second[i].Pix) {
+			t.Errorf("Frame %d differs between renders", i)
+		}
+	}
+	if bytes.Equal(first[0].Pix, first[1].Pix) {
+		t.Error("Expected frames to animate")
+	}
+	// No background, so the top left corner stays transparent
+	if a := first[0].RGBAAt(0, 0).A; a != 0 {
+		t.Errorf("Expected transparent corner, got alpha %d", a)
+	}
+}
+
+func TestReactionFramesEveryReaction(t *testing.T) {
+	for _, reaction := range Reactions {
+		if !IsReaction(reaction) {
+			t.Errorf("IsReaction(%q) = false", reaction)
+		}
+		if _, err := (Config{}).ReactionFrames(reaction, 48, 2); err != nil {
+			t.Errorf("Reaction %q failed to render: %v", reaction, err)
+		}
+	}
+
+	if IsReaction("boo") {
+		t.Error("Expected boo not to be a reaction")
+	}
+	if _, err := (Config{}).ReactionFrames("boo", 48, 2); err == nil {
+		t.Error("Expected error for unknown reaction")
+	}
+	if _, err := (Config{}).ReactionFrames("clap", 0, 2); err == nil {
+		t.Error("Expected error for zero size")
+	}
+}
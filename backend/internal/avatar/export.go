@@ -0,0 +1,267 @@
+package avatar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"math"
+	"sort"
+)
+
+// Sticker sizes in pixels: messaging apps expect 512, and smaller ones are for previews
+const (
+	StickerSize    = 512
+	MinStickerSize = 96
+)
+
+// Sticker renders the avatar without its background on a transparent square, with a
+// margin so nothing touches the edge
+func (c Config) Sticker(size int) (*image.RGBA, error) {
+	if size < MinStickerSize || size > StickerSize {
+		return nil, fmt.Errorf("sticker size must be %d-%d pixels", MinStickerSize, StickerSize)
+	}
+	c.Normalize()
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	margin := math.Round(float64(size) * 0.04)
+	if err := drawSVG(img, c.ToSVGWithEnv(false), margin, margin, float64(size)-2*margin); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// checkFrames makes sure there's something to encode and every frame is the same size
+func checkFrames(frames []*image.RGBA, fps float64) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames to encode")
+	}
+	if fps <= 0 {
+		return fmt.Errorf("invalid frame rate %v", fps)
+	}
+	for _, f := range frames {
+		if f.Bounds() != frames[0].Bounds() {
+			return fmt.Errorf("frames must be the same size")
+		}
+	}
+	return nil
+}
+
+// EncodeGIF writes frames as a looping animated GIF. Colors come from one palette built
+// from the frames, and pixels under half opacity become transparent.
+func EncodeGIF(w io.Writer, frames []*image.RGBA, fps float64) error {
+	if err := checkFrames(frames, fps); err != nil {
+		return err
+	}
+	pal, index := gifPalette(frames)
+	delay := int(math.Round(100 / fps))
+
+	anim := &gif.GIF{}
+	for _, frame := range frames {
+		bounds := frame.Bounds()
+		p := image.NewPaletted(bounds, pal)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				p.SetColorIndex(x, y, index(frame.RGBAAt(x, y)))
+			}
+		}
+		anim.Image = append(anim.Image, p)
+		anim.Delay = append(anim.Delay, delay)
+		// Clear each frame so transparent pixels don't show the one before
+		anim.Disposal = append(anim.Disposal, gif.DisposalBackground)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// gifPalette picks up to 256 colors for frames, the transparent one first when needed,
+// and returns a function mapping pixels onto it
+func gifPalette(frames []*image.RGBA) (color.Palette, func(color.RGBA) uint8) {
+	// Count colors in 5-bit buckets, keeping sums to average each bucket
+	type bucket struct {
+		count   int
+		r, g, b int
+		key     int
+	}
+	buckets := make(map[int]*bucket)
+	bucketOf := func(c color.NRGBA) int {
+		return int(c.R>>3)<<10 | int(c.G>>3)<<5 | int(c.B>>3)
+	}
+	transparent := false
+	for _, frame := range frames {
+		for i := 0; i < len(frame.Pix); i += 4 {
+			px := color.RGBA{frame.Pix[i], frame.Pix[i+1], frame.Pix[i+2], frame.Pix[i+3]}
+			if px.A < 128 {
+				transparent = true
+				continue
+			}
+			c := color.NRGBAModel.Convert(px).(color.NRGBA)
+			key := bucketOf(c)
+			bk := buckets[key]
+			if bk == nil {
+				bk = &bucket{key: key}
+				buckets[key] = bk
+			}
+			bk.count++
+			bk.r += int(c.R)
+			bk.g += int(c.G)
+			bk.b += int(c.B)
+		}
+	}
+
+	sorted := make([]*bucket, 0, len(buckets))
+	for _, bk := range buckets {
+		sorted = append(sorted, bk)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].key < sorted[j].key
+	})
+
+	var pal color.Palette
+	if transparent {
+		pal = append(pal, color.RGBA{})
+	}
+	for _, bk := range sorted {
+		if len(pal) == 256 {
+			break
+		}
+		pal = append(pal, color.RGBA{uint8(bk.r / bk.count), uint8(bk.g / bk.count), uint8(bk.b / bk.count), 255})
+	}
+	if len(pal) == 0 {
+		pal = append(pal, color.RGBA{A: 255}) // Nothing visible at all
+	}
+
+	// Buckets that didn't make the palette take the nearest color that did
+	first := 0
+	if transparent {
+		first = 1
+	}
+	nearest := make(map[int]uint8, len(buckets))
+	for _, bk := range sorted {
+		c := color.RGBA{uint8(bk.r / bk.count), uint8(bk.g / bk.count), uint8(bk.b / bk.count), 255}
+		best, bestDist := first, math.MaxInt
+		for i := first; i < len(pal); i++ {
+			p := pal[i].(color.RGBA)
+			dr, dg, db := int(c.R)-int(p.R), int(c.G)-int(p.G), int(c.B)-int(p.B)
+			if d := dr*dr + dg*dg + db*db; d < bestDist {
+				best, bestDist = i, d
+			}
+		}
+		nearest[bk.key] = uint8(best)
+	}
+
+	return pal, func(px color.RGBA) uint8 {
+		if px.A < 128 {
+			return 0
+		}
+		return nearest[bucketOf(color.NRGBAModel.Convert(px).(color.NRGBA))]
+	}
+}
+
+// EncodeWebP writes frames as a looping animated lossless WebP. After the first frame
+// only the part that changed is stored, and frames that don't change just show longer.
+func EncodeWebP(w io.Writer, frames []*image.RGBA, fps float64) error {
+	if err := checkFrames(frames, fps); err != nil {
+		return err
+	}
+	bounds := frames[0].Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > 1<<14 || height > 1<<14 {
+		return fmt.Errorf("frames too large for WebP")
+	}
+	delay := int(math.Round(1000 / fps)) // Milliseconds
+
+	type webpFrame struct {
+		frame    *image.RGBA
+		rect     image.Rectangle // The part of the canvas this frame replaces
+		duration int             // Milliseconds
+	}
+	var planned []webpFrame
+	for i, frame := range frames {
+		if i == 0 {
+			planned = append(planned, webpFrame{frame: frame, rect: bounds, duration: delay})
+			continue
+		}
+		changed := changedRect(frames[i-1], frame)
+		if changed.Empty() {
+			planned[len(planned)-1].duration += delay
+			continue
+		}
+		// Frame offsets are stored halved, so start on even pixels
+		changed.Min.X -= (changed.Min.X - bounds.Min.X) % 2
+		changed.Min.Y -= (changed.Min.Y - bounds.Min.Y) % 2
+		planned = append(planned, webpFrame{frame: frame, rect: changed, duration: delay})
+	}
+
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x10 | 0x02 // Alpha, animation
+	putUint24(vp8x[4:], width-1)
+	putUint24(vp8x[7:], height-1)
+	writeChunk(&body, "VP8X", vp8x)
+	writeChunk(&body, "ANIM", []byte{0, 0, 0, 0, 0, 0}) // Transparent background, loop forever
+
+	for _, f := range planned {
+		bitstream := encodeVP8L(f.frame.SubImage(f.rect).(*image.RGBA))
+
+		anmf := make([]byte, 16, 16+8+len(bitstream)+1)
+		putUint24(anmf[0:], (f.rect.Min.X-bounds.Min.X)/2)
+		putUint24(anmf[3:], (f.rect.Min.Y-bounds.Min.Y)/2)
+		putUint24(anmf[6:], f.rect.Dx()-1)
+		putUint24(anmf[9:], f.rect.Dy()-1)
+		putUint24(anmf[12:], min(f.duration, 1<<24-1))
+		anmf[15] = 0x02 // Replace the area rather than blending, and keep it for the next frame
+		var vp8l bytes.Buffer
+		writeChunk(&vp8l, "VP8L", bitstream)
+		writeChunk(&body, "ANMF", append(anmf, vp8l.Bytes()...))
+	}
+
+	if _, err := io.WriteString(w, "RIFF"); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(body.Len())); err != nil {
+		return err
+	}
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+// changedRect is the smallest rectangle holding every pixel that differs between a and b
+func changedRect(a, b *image.RGBA) image.Rectangle {
+	bounds := a.Bounds()
+	changed := image.Rectangle{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		rowA := a.Pix[a.PixOffset(bounds.Min.X, y):a.PixOffset(bounds.Max.X, y)]
+		rowB := b.Pix[b.PixOffset(bounds.Min.X, y):b.PixOffset(bounds.Max.X, y)]
+		if bytes.Equal(rowA, rowB) {
+			continue
+		}
+		for x := 0; x < len(rowA); x += 4 {
+			if !bytes.Equal(rowA[x:x+4], rowB[x:x+4]) {
+				px := image.Rect(bounds.Min.X+x/4, y, bounds.Min.X+x/4+1, y+1)
+				changed = changed.Union(px)
+			}
+		}
+	}
+	return changed
+}
+
+// writeChunk writes a RIFF chunk, padded to an even length
+func writeChunk(w *bytes.Buffer, fourCC string, data []byte) {
+	w.WriteString(fourCC)
+	binary.Write(w, binary.LittleEndian, uint32(len(data)))
+	w.Write(data)
+	if len(data)%2 == 1 {
+		w.WriteByte(0)
+	}
+}
+
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
@@ -0,0 +1,270 @@
+package avatar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"testing"
+
+	"golang.org/x/image/vp8l"
+)
+
+var exportTestConfig = Config{Env: 3, Clo: 20, Head: 5, Mouth: 3, Eyes: 17, Top: 11}
+
+// ============================================================================
+// Idle Animation Tests
+// ============================================================================
+
+func TestIdleFramesSeeded(t *testing.T) {
+	opts := IdleOptions{Size: 64, Seed: 42, IncludeEnv: true}
+	first, err := exportTestConfig.IdleFrames(opts)
+	if err != nil {
+		t.Fatalf("IdleFrames failed: %v", err)
+	}
+	second, _ := exportTestConfig.IdleFrames(opts)
+	if len(first) != IdleFrameCount {
+		t.Fatalf("Expected %d frames, got %d", IdleFrameCount, len(first))
+	}
+	for i := range first {
+		if !bytes.Equal(first[i].Pix, second[i].Pix) {
+			t.Fatalf("Frame %d differs between renders with the same seed", i)
+		}
+	}
+
+	// Another seed moves differently
+	opts.Seed = 43
+	other, _ := exportTestConfig.IdleFrames(opts)
+	same := true
+	for i := range first {
+		if !bytes.Equal(first[i].Pix, other[i].Pix) {
+			same = false
+		}
+	}
+	if same {
+		t.Error("Expected a different seed to change the animation")
+	}
+}
+
+func TestIdleFramesMoveOnlyTheFace(t *testing.T) {
+	frames, err := exportTestConfig.IdleFrames(IdleOptions{Size: 96, Seed: 7, IncludeEnv: true})
+	if err != nil {
+		t.Fatalf("IdleFrames failed: %v", err)
+	}
+	var moved image.Rectangle
+	for i := 1; i < len(frames); i++ {
+		moved = moved.Union(changedRect(frames[0], frames[i]))
+	}
+	if moved.Empty() {
+		t.Fatal("Expected the avatar to move")
+	}
+	// Eyes and mouth sit in the middle of the face; the hair and clothes hold still
+	if moved.Min.Y < 96*25/100 || moved.Max.Y > 96*75/100 {
+		t.Errorf("Expected only the face to move, got rows %d-%d", moved.Min.Y, moved.Max.Y)
+	}
+
+	still, _ := exportTestConfig.IdleFrames(IdleOptions{Size: 96, Frames: 4, Seed: 7})
+	if len(still) != 4 {
+		t.Errorf("Expected 4 frames, got %d", len(still))
+	}
+	if a := still[0].RGBAAt(0, 0).A; a != 0 {
+		t.Errorf("Expected a transparent corner without the background, got alpha %d", a)
+	}
+	if _, err := exportTestConfig.IdleFrames(IdleOptions{Size: 0}); err == nil {
+		t.Error("Expected error for zero size")
+	}
+}
+
+func TestDefaultSeedFollowsConfig(t *testing.T) {
+	other := exportTestConfig
+	other.Mouth++
+	if exportTestConfig.DefaultSeed() != exportTestConfig.DefaultSeed() {
+		t.Error("Expected the same avatar to get the same seed")
+	}
+	if exportTestConfig.DefaultSeed() == other.DefaultSeed() {
+		t.Error("Expected different avatars to get different seeds")
+	}
+}
+
+// ============================================================================
+// Export Tests
+// ============================================================================
+
+// decodeAnimatedWebP plays an animated WebP's frames onto a canvas, the way a viewer
+// would for frames that replace their area and are kept
+func decodeAnimatedWebP(t *testing.T, data []byte) (frames []*image.NRGBA, durations []int) {
+	t.Helper()
+	if string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		t.Fatalf("Not a WebP file")
+	}
+	if int(binary.LittleEndian.Uint32(data[4:8])) != len(data)-8 {
+		t.Fatalf("RIFF size %d doesn't match file size %d", binary.LittleEndian.Uint32(data[4:8]), len(data))
+	}
+	uint24 := func(b []byte) int { return int(b[0]) | int(b[1])<<8 | int(b[2])<<16 }
+
+	var canvas *image.NRGBA
+	for pos := 12; pos < len(data); {
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		chunk := data[pos+8 : pos+8+size]
+		pos += 8 + size + size%2
+
+		switch fourCC {
+		case "VP8X":
+			if chunk[0]&0x02 == 0 {
+				t.Fatal("Expected the animation flag")
+			}
+			canvas = image.NewNRGBA(image.Rect(0, 0, uint24(chunk[4:])+1, uint24(chunk[7:])+1))
+		case "ANMF":
+			x, y := uint24(chunk[0:])*2, uint24(chunk[3:])*2
+			w, h := uint24(chunk[6:])+1, uint24(chunk[9:])+1
+			if string(chunk[16:20]) != "VP8L" {
+				t.Fatalf("Expected a VP8L frame, got %s", chunk[16:20])
+			}
+			m, err := vp8l.Decode(bytes.NewReader(chunk[24:]))
+			if err != nil {
+				t.Fatalf("Frame %d failed to decode: %v", len(frames), err)
+			}
+			if m.Bounds().Dx() != w || m.Bounds().Dy() != h {
+				t.Fatalf("Frame %d is %v, header says %dx%d", len(frames), m.Bounds(), w, h)
+			}
+			draw.Draw(canvas, image.Rect(x, y, x+w, y+h), m, image.Point{}, draw.Src)
+			frame := image.NewNRGBA(canvas.Bounds())
+			copy(frame.Pix, canvas.Pix)
+			frames = append(frames, frame)
+			durations = append(durations, uint24(chunk[12:]))
+		}
+	}
+	return frames, durations
+}
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	frames, _ := exportTestConfig.IdleFrames(IdleOptions{Size: 80, Seed: 3})
+	frames = append(frames, frames[len(frames)-1]) // A repeated frame just shows longer
+
+	var buf bytes.Buffer
+	if err := EncodeWebP(&buf, frames, IdleFPS); err != nil {
+		t.Fatalf("EncodeWebP failed: %v", err)
+	}
+	decoded, durations := decodeAnimatedWebP(t, buf.Bytes())
+
+	// Expand the stored frames back out by duration and compare every pixel
+	const delay = 1000 / IdleFPS // 83ms a frame
+	var played []*image.NRGBA
+	total := 0
+	for i, d := range durations {
+		total += d
+		for n := 0; n < d/delay; n++ {
+			played = append(played, decoded[i])
+		}
+	}
+	if total != delay*len(frames) || len(played) != len(frames) {
+		t.Fatalf("Expected %d frames of %dms, got %d frames lasting %dms", len(frames), delay, len(played), total)
+	}
+	for i := range frames {
+		want := frames[i]
+		for y := 0; y < 80; y++ {
+			for x := 0; x < 80; x++ {
+				got := played[i].NRGBAAt(x, y)
+				if got != color.NRGBAModel.Convert(want.RGBAAt(x, y)) {
+					t.Fatalf("Frame %d pixel (%d, %d): got %v, want %v", i, x, y, got, want.RGBAAt(x, y))
+				}
+			}
+		}
+	}
+
+	// Later frames only store what changed
+	if len(decoded) >= len(frames) {
+		t.Errorf("Expected the repeated frame to be merged, got %d stored frames", len(decoded))
+	}
+	var again bytes.Buffer
+	EncodeWebP(&again, frames, IdleFPS)
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Error("Expected the same frames to encode to the same bytes")
+	}
+}
+
+func TestVP8LCodesEdgeCases(t *testing.T) {
+	// A single color needs no bits per pixel; odd sizes and a full range of values don't break it
+	solid := image.NewRGBA(image.Rect(0, 0, 5, 3))
+	for i := range solid.Pix {
+		solid.Pix[i] = 200
+	}
+	ramp := image.NewRGBA(image.Rect(0, 0, 256, 3))
+	for x := 0; x < 256; x++ {
+		ramp.SetRGBA(x, 0, color.RGBA{uint8(x), uint8(255 - x), uint8(x / 2), 255})
+		ramp.SetRGBA(x, 1, color.RGBA{uint8(x / 4), uint8(x / 3), uint8(x / 5), uint8(x)})
+	}
+	for _, img := range []*image.RGBA{solid, ramp} {
+		m, err := vp8l.Decode(bytes.NewReader(encodeVP8L(img)))
+		if err != nil {
+			t.Fatalf("%v image failed to decode: %v", img.Bounds(), err)
+		}
+		for y := 0; y < img.Bounds().Dy(); y++ {
+			for x := 0; x < img.Bounds().Dx(); x++ {
+				if got, want := m.At(x, y), color.NRGBAModel.Convert(img.RGBAAt(x, y)); got != want {
+					t.Fatalf("%v image pixel (%d, %d): got %v, want %v", img.Bounds(), x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeGIF(t *testing.T) {
+	frames, _ := exportTestConfig.IdleFrames(IdleOptions{Size: 64, Seed: 3})
+	var buf bytes.Buffer
+	if err := EncodeGIF(&buf, frames, IdleFPS); err != nil {
+		t.Fatalf("EncodeGIF failed: %v", err)
+	}
+	anim, err := gif.DecodeAll(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to decode GIF: %v", err)
+	}
+	if len(anim.Image) != len(frames) || anim.LoopCount != 0 || anim.Delay[0] != 8 {
+		t.Fatalf("Expected %d looping frames of 8/100s, got %d (loop %d, delay %d)", len(frames), len(anim.Image), anim.LoopCount, anim.Delay[0])
+	}
+	// Transparent where the avatar isn't, close to the original where it is
+	if _, _, _, a := anim.Image[0].At(0, 0).RGBA(); a != 0 {
+		t.Errorf("Expected a transparent corner, got alpha %d", a)
+	}
+	want := color.NRGBAModel.Convert(frames[0].RGBAAt(32, 32)).(color.NRGBA)
+	got := color.NRGBAModel.Convert(anim.Image[0].At(32, 32)).(color.NRGBA)
+	if diff := int(got.R) - int(want.R) + int(got.G) - int(want.G) + int(got.B) - int(want.B); diff < -24 || diff > 24 {
+		t.Errorf("Expected the center close to %v, got %v", want, got)
+	}
+
+	var again bytes.Buffer
+	EncodeGIF(&again, frames, IdleFPS)
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Error("Expected the same frames to encode to the same bytes")
+	}
+	if err := EncodeGIF(&buf, nil, IdleFPS); err == nil {
+		t.Error("Expected error for no frames")
+	}
+}
+
+func TestSticker(t *testing.T) {
+	img, err := exportTestConfig.Sticker(StickerSize)
+	if err != nil {
+		t.Fatalf("Sticker failed: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != StickerSize || b.Dy() != StickerSize {
+		t.Errorf("Sticker size = %dx%d, want %dx%d", b.Dx(), b.Dy(), StickerSize, StickerSize)
+	}
+	// The margin stays clear all round
+	for i := 0; i < StickerSize; i++ {
+		for _, p := range []image.Point{{i, 0}, {i, StickerSize - 1}, {0, i}, {StickerSize - 1, i}} {
+			if a := img.RGBAAt(p.X, p.Y).A; a != 0 {
+				t.Fatalf("Expected a transparent edge, got alpha %d at %v", a, p)
+			}
+		}
+	}
+	if a := img.RGBAAt(StickerSize/2, StickerSize/2).A; a != 255 {
+		t.Errorf("Expected the avatar in the middle, got alpha %d", a)
+	}
+	if _, err := exportTestConfig.Sticker(StickerSize * 2); err == nil {
+		t.Error("Expected error for an oversized sticker")
+	}
+}
@@ -0,0 +1,76 @@
+package avatar
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// reactionBadges are the badges drawn on an avatar when its guest reacts (100x100 viewBox)
+var reactionBadges = map[string]string{
+	"clap": `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">` +
+		`<path d="M74,14 L82,5 M80,26 L93,21 M84,39 L96,40" fill="none" stroke="#FFC83D" stroke-width="5" stroke-linecap="round"/>` +
+		`<path d="M40,92 C26,86 20,72 24,58 L32,26 C34,19 43,20 42,28 L40,44 L47,18 C49,11 58,13 57,21 L52,44 L60,22 C62,15 71,18 69,25 L62,50 L68,38 C71,31 79,35 76,42 L68,70 C63,86 52,96 40,92 Z" fill="#F0A830" stroke="#FFFFFF" stroke-width="4"/>` +
+		`<path d="M30,94 C16,88 10,74 14,60 L22,28 C24,21 33,22 32,30 L30,46 L37,20 C39,13 48,15 47,23 L42,46 L50,24 C52,17 61,20 59,27 L52,52 L58,40 C61,33 69,37 66,44 L58,72 C53,88 42,98 30,94 Z" fill="#FFC83D" stroke="#FFFFFF" stroke-width="4"/>` +
+		`</svg>`,
+	"heart": `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">` +
+		`<path d="M50,90 C22,70 6,52 6,32 C6,17 17,7 31,7 C40,7 46,12 50,19 C54,12 60,7 69,7 C83,7 94,17 94,32 C94,52 78,70 50,90 Z" fill="#FF3B5C" stroke="#FFFFFF" stroke-width="5"/>` +
+		`<path d="M24,24 C28,18 34,17 38,19" fill="none" stroke="#FFFFFF" stroke-width="5" stroke-linecap="round"/>` +
+		`</svg>`,
+	"fire": `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">` +
+		`<path d="M50,95 C25,95 12,78 12,60 C12,42 24,32 30,18 C34,28 38,34 44,36 C44,22 52,10 62,4 C62,22 88,36 88,62 C88,80 74,95 50,95 Z" fill="#FF6A00" stroke="#FFFFFF" stroke-width="5"/>` +
+		`<path d="M50,90 C38,90 31,82 31,72 C31,62 38,56 42,48 C45,56 49,58 52,58 C52,50 56,44 62,40 C64,52 70,60 70,72 C70,82 62,90 50,90 Z" fill="#FFD23F"/>` +
+		`</svg>`,
+	"laugh": `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">` +
+		`<circle cx="50" cy="50" r="44" fill="#FFCC33" stroke="#FFFFFF" stroke-width="5"/>` +
+		`<path d="M26,40 Q33,29 40,40 M60,40 Q67,29 74,40" fill="none" stroke="#5A3A00" stroke-width="5" stroke-linecap="round"/>` +
+		`<path d="M25,52 L75,52 Q73,82 50,82 Q27,82 25,52 Z" fill="#5A3A00"/>` +
+		`<path d="M18,44 Q9,58 15,63 Q24,66 22,50 Z M82,44 Q91,58 85,63 Q76,66 78,50 Z" fill="#4FC3F7"/>` +
+		`</svg>`,
+}
+
+// Reactions lists the reactions guests can send, in the order phones show them
+var Reactions = []string{"clap", "heart", "fire", "laugh"}
+
+// IsReaction reports whether name is a reaction guests can send
+func IsReaction(name string) bool {
+	_, ok := reactionBadges[name]
+	return ok
+}
+
+// ReactionFrames renders one loop of an avatar reacting: the avatar (without its background)
+// bobbing and swaying, with the reaction's badge pulsing at its lower right. Frames are
+// size x size with a transparent background. Rendering is deterministic, so the same avatar
+// and reaction always give the same frames.
+func (c Config) ReactionFrames(reaction string, size, count int) ([]*image.RGBA, error) {
+	badge, ok := reactionBadges[reaction]
+	if !ok {
+		return nil, fmt.Errorf("unknown reaction %q", reaction)
+	}
+	if size <= 0 || count <= 0 {
+		return nil, fmt.Errorf("invalid reaction size %d or frame count %d", size, count)
+	}
+
+	avatarSVG := c.ToSVGWithEnv(false)
+	s := float64(size)
+	frames := make([]*image.RGBA, count)
+	for i := range frames {
+		phase := 2 * math.Pi * float64(i) / float64(count)
+		img := image.NewRGBA(image.Rect(0, 0, size, size))
+
+		// Two bobs per sway, so the loop joins up
+		avatarSize := s * (0.78 + 0.03*math.Sin(2*phase))
+		x := s*0.04 + s*0.03*math.Sin(phase)
+		y := s*0.06 - s*0.04*math.Abs(math.Sin(phase))
+		if err := drawSVG(img, avatarSVG, x, y, avatarSize); err != nil {
+			return nil, err
+		}
+
+		badgeSize := s * (0.40 + 0.06*math.Sin(2*phase))
+		if err := drawSVG(img, badge, s-badgeSize-s*0.01, s-badgeSize-s*0.01, badgeSize); err != nil {
+			return nil, err
+		}
+		frames[i] = img
+	}
+	return frames, nil
+}
@@ -0,0 +1,266 @@
+package avatar
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// A small lossless WebP (VP8L) encoder for animated exports. It writes each frame as plain
+// ARGB with one set of Huffman codes: no transforms, color cache or backward references.
+// That keeps it short while flat avatar colors still compress well.
+
+// codeLengthCodeOrder is the order VP8L stores the code length code's lengths in
+var codeLengthCodeOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// VP8L alphabet sizes: green includes the 24 length prefix codes
+const (
+	greenAlphabet    = 256 + 24
+	distanceAlphabet = 40
+)
+
+// bitWriter packs bits least significant first, the order VP8L reads them in
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (b *bitWriter) write(v uint32, n uint) {
+	b.acc |= uint64(v) << b.nbits
+	b.nbits += n
+	for b.nbits >= 8 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc >>= 8
+		b.nbits -= 8
+	}
+}
+
+// bytes flushes any partial byte and returns everything written
+func (b *bitWriter) bytes() []byte {
+	if b.nbits > 0 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc, b.nbits = 0, 0
+	}
+	return b.buf
+}
+
+// prefixCode is a canonical Huffman code
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint32 // Bit reversed, ready to write
+}
+
+func (p prefixCode) write(b *bitWriter, symbol int) {
+	b.write(p.codes[symbol], uint(p.lengths[symbol]))
+}
+
+// encodeVP8L encodes an image as a VP8L bitstream (the payload of a VP8L chunk)
+func encodeVP8L(img *image.RGBA) []byte {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	// VP8L stores unpremultiplied ARGB
+	pixels := make([]color.NRGBA, 0, w*h)
+	var counts [4][]int
+	counts[0] = make([]int, greenAlphabet)
+	for i := 1; i < 4; i++ {
+		counts[i] = make([]int, 256)
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.RGBAAt(x, y)).(color.NRGBA)
+			pixels = append(pixels, c)
+			counts[0][c.G]++
+			counts[1][c.R]++
+			counts[2][c.B]++
+			counts[3][c.A]++
+		}
+	}
+
+	var b bitWriter
+	b.write(0x2f, 8) // Signature
+	b.write(uint32(w-1), 14)
+	b.write(uint32(h-1), 14)
+	b.write(1, 1) // Alpha is used
+	b.write(0, 3) // Version
+	b.write(0, 1) // No transforms
+	b.write(0, 1) // No color cache
+	b.write(0, 1) // One set of prefix codes for the whole image
+
+	var codes [4]prefixCode
+	for i := range codes {
+		codes[i] = writePrefixCode(&b, counts[i])
+	}
+	writePrefixCode(&b, make([]int, distanceAlphabet)) // No backward references
+
+	for _, c := range pixels {
+		codes[0].write(&b, int(c.G))
+		codes[1].write(&b, int(c.R))
+		codes[2].write(&b, int(c.B))
+		codes[3].write(&b, int(c.A))
+	}
+	return b.bytes()
+}
+
+// writePrefixCode writes the code for symbols with the given counts and returns it
+func writePrefixCode(b *bitWriter, counts []int) prefixCode {
+	var used []int
+	for s, n := range counts {
+		if n > 0 {
+			used = append(used, s)
+		}
+	}
+
+	// Zero or one symbols: a "simple" code, which takes no bits per symbol
+	if len(used) <= 1 {
+		symbol := 0
+		if len(used) == 1 {
+			symbol = used[0]
+		}
+		b.write(1, 1) // Simple code
+		b.write(0, 1) // One symbol
+		if symbol < 2 {
+			b.write(0, 1)
+			b.write(uint32(symbol), 1)
+		} else {
+			b.write(1, 1)
+			b.write(uint32(symbol), 8)
+		}
+		return prefixCode{lengths: make([]uint8, len(counts)), codes: make([]uint32, len(counts))}
+	}
+
+	code := newPrefixCode(huffmanLengths(counts, 15))
+
+	// The lengths themselves are written with a code length code
+	lengthCounts := make([]int, len(codeLengthCodeOrder))
+	for _, l := range code.lengths {
+		lengthCounts[l]++
+	}
+	lengthCode := newPrefixCode(huffmanLengths(lengthCounts, 7))
+	n := 4
+	for i, s := range codeLengthCodeOrder {
+		if lengthCode.lengths[s] != 0 && i+1 > n {
+			n = i + 1
+		}
+	}
+	b.write(0, 1) // Normal code
+	b.write(uint32(n-4), 4)
+	for _, s := range codeLengthCodeOrder[:n] {
+		b.write(uint32(lengthCode.lengths[s]), 3)
+	}
+	b.write(0, 1) // A length for every symbol follows
+	for _, l := range code.lengths {
+		lengthCode.write(b, int(l))
+	}
+	return code
+}
+
+// huffmanLengths returns Huffman code lengths of at most maxLength bits for symbol counts.
+// Ties break on symbol order, so the same counts always give the same code. At least two
+// symbols get a length, as a one-symbol normal code can't be read.
+func huffmanLengths(counts []int, maxLength int) []uint8 {
+	type node struct {
+		count       int
+		symbol      int // Leaves only
+		left, right int // Children; -1 for leaves
+	}
+	weights := append([]int(nil), counts...)
+	used := 0
+	for _, n := range weights {
+		if n > 0 {
+			used++
+		}
+	}
+	if used < 2 {
+		for s := range weights {
+			if weights[s] == 0 {
+				weights[s] = 1
+				if used++; used == 2 {
+					break
+				}
+			}
+		}
+	}
+
+	for {
+		var nodes []node
+		for s, n := range weights {
+			if n > 0 {
+				nodes = append(nodes, node{count: n, symbol: s, left: -1, right: -1})
+			}
+		}
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].count < nodes[j].count })
+
+		// Two queues: leaves in count order, then merged nodes as they're made
+		leaves := len(nodes)
+		next, merged := 0, leaves
+		take := func() int {
+			if next < leaves && (merged >= len(nodes) || nodes[next].count <= nodes[merged].count) {
+				next++
+				return next - 1
+			}
+			merged++
+			return merged - 1
+		}
+		for len(nodes)-leaves < leaves-1 {
+			a := take()
+			c := take()
+			nodes = append(nodes, node{count: nodes[a].count + nodes[c].count, left: a, right: c})
+		}
+
+		lengths := make([]uint8, len(counts))
+		deepest := 0
+		var walk func(n, depth int)
+		walk = func(n, depth int) {
+			if nodes[n].left < 0 {
+				lengths[nodes[n].symbol] = uint8(depth)
+				deepest = max(deepest, depth)
+				return
+			}
+			walk(nodes[n].left, depth+1)
+			walk(nodes[n].right, depth+1)
+		}
+		walk(len(nodes)-1, 0)
+		if deepest <= maxLength {
+			return lengths
+		}
+
+		// Too deep: flatten the counts and try again
+		for s, n := range weights {
+			if n > 0 {
+				weights[s] = (n + 1) / 2
+			}
+		}
+	}
+}
+
+// newPrefixCode assigns canonical codes to code lengths
+func newPrefixCode(lengths []uint8) prefixCode {
+	var perLength [16]uint32
+	for _, l := range lengths {
+		perLength[l]++
+	}
+	perLength[0] = 0
+	var next [16]uint32
+	code := uint32(0)
+	for l := 1; l < 16; l++ {
+		code = (code + perLength[l-1]) << 1
+		next[l] = code
+	}
+	codes := make([]uint32, len(lengths))
+	for s, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		c := next[l]
+		next[l]++
+		// Codes are read from their most significant bit, so write them reversed
+		var reversed uint32
+		for i := uint8(0); i < l; i++ {
+			reversed = reversed<<1 | (c>>i)&1
+		}
+		codes[s] = reversed
+	}
+	return prefixCode{lengths: lengths, codes: codes}
+}
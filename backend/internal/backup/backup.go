@@ -0,0 +1,435 @@
+// Package backup snapshots SongMartyn's databases and configuration into one archive
+// and restores them, checking schema versions before anything is replaced
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// FormatVersion is the archive layout written by Create
+const FormatVersion = 1
+
+// ManifestName is the archive entry describing what's in it
+const ManifestName = "manifest.json"
+
+// Archive names: songmartyn-20060102-150405.tar.gz
+const (
+	namePrefix = "songmartyn-"
+	nameLayout = "20060102-150405"
+	Extension  = ".tar.gz"
+)
+
+// PendingDir holds a validated restore, under the data directory, until the next start
+const PendingDir = "restore-pending"
+
+// PreviousSuffix is added to files a restore replaced, so the last state can be recovered by hand
+const PreviousSuffix = ".before-restore"
+
+const (
+	backupStep  = 256                   // Pages copied per step, so writers aren't locked out for long
+	backupPause = 10 * time.Millisecond // Pause between steps, and before retrying a busy database
+
+	vacuumBusyTimeout = 10 * time.Second // How long Vacuum waits for a database in use
+)
+
+var (
+	ErrNotFound       = errors.New("backup not found")
+	ErrInvalidArchive = errors.New("not a SongMartyn backup")
+)
+
+// Database is a SQLite database to back up
+type Database struct {
+	Name    string // Name inside the archive, e.g. "queue.db"
+	Path    string
+	Version int // Newest schema version this build understands
+}
+
+// File is any other file to back up, e.g. the configuration file
+type File struct {
+	Name string // Name inside the archive
+	Path string
+}
+
+// Set is everything a backup covers; missing files are left out
+type Set struct {
+	Databases []Database
+	Files     []File
+}
+
+// database returns the database stored under name
+func (s Set) database(name string) (Database, bool) {
+	for _, db := range s.Databases {
+		if db.Name == name {
+			return db, true
+		}
+	}
+	return Database{}, false
+}
+
+// file returns the file stored under name
+func (s Set) file(name string) (File, bool) {
+	for _, f := range s.Files {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return File{}, false
+}
+
+// Manifest describes an archive's contents
+type Manifest struct {
+	Format    int            `json:"format"`
+	CreatedAt time.Time      `json:"created_at"`
+	Databases map[string]int `json:"databases"` // Name -> schema version
+	Files     []string       `json:"files"`
+}
+
+// Info is a backup archive on disk
+type Info struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Create writes a gzipped tar archive of set to w
+// Databases are copied with SQLite's online backup, so they stay consistent while in use
+func Create(ctx context.Context, w io.Writer, set Set) (Manifest, error) {
+	m := Manifest{Format: FormatVersion, CreatedAt: time.Now(), Databases: map[string]int{}, Files: []string{}}
+
+	tmp, err := os.MkdirTemp("", "songmartyn-backup-")
+	if err != nil {
+		return m, err
+	}
+	defer os.RemoveAll(tmp)
+
+	var entries []File
+	for _, db := range set.Databases {
+		if _, err := os.Stat(db.Path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		// Names can have folders, e.g. rooms/<id>/queue.db
+		snapshot := filepath.Join(tmp, db.Name)
+		if err := os.MkdirAll(filepath.Dir(snapshot), 0700); err != nil {
+			return m, err
+		}
+		if err := copyDatabase(ctx, db.Path, snapshot); err != nil {
+			return m, fmt.Errorf("%s: %w", db.Name, err)
+		}
+		version, err := schemaVersion(snapshot)
+		if err != nil {
+			return m, fmt.Errorf("%s: %w", db.Name, err)
+		}
+		m.Databases[db.Name] = version
+		entries = append(entries, File{Name: db.Name, Path: snapshot})
+	}
+	for _, f := range set.Files {
+		if _, err := os.Stat(f.Path); err != nil {
+			continue
+		}
+		m.Files = append(m.Files, f.Name)
+		entries = append(entries, f)
+	}
+
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return m, err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	// The manifest goes first so readers know what they're dealing with straight away
+	if err := tw.WriteHeader(&tar.Header{Name: ManifestName, Mode: 0600, Size: int64(len(manifest)), ModTime: m.CreatedAt}); err != nil {
+		return m, err
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return m, err
+	}
+	for _, e := range entries {
+		if err := addFile(tw, e); err != nil {
+			return m, fmt.Errorf("%s: %w", e.Name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return m, err
+	}
+	return m, gz.Close()
+}
+
+// addFile copies a file into the archive
+func addFile(tw *tar.Writer, f File) error {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: f.Name, Mode: 0600, Size: info.Size(), ModTime: info.ModTime()}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+// copyDatabase copies src to dst with the SQLite backup API, a few pages at a time
+func copyDatabase(ctx context.Context, src, dst string) error {
+	srcDB, err := sql.Open("sqlite3", src)
+	if err != nil {
+		return err
+	}
+	defer srcDB.Close()
+	dstDB, err := sql.Open("sqlite3", dst)
+	if err != nil {
+		return err
+	}
+	defer dstDB.Close()
+
+	srcConn, err := srcDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+	dstConn, err := dstDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	return dstConn.Raw(func(dstRaw any) error {
+		return srcConn.Raw(func(srcRaw any) error {
+			b, err := dstRaw.(*sqlite3.SQLiteConn).Backup("main", srcRaw.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			for {
+				// Busy and locked come back as not done, so they're retried after the pause
+				done, err := b.Step(backupStep)
+				if err != nil {
+					b.Close()
+					return err
+				}
+				if done {
+					return b.Finish()
+				}
+				select {
+				case <-ctx.Done():
+					b.Close()
+					return ctx.Err()
+				case <-time.After(backupPause):
+				}
+			}
+		})
+	})
+}
+
+// schemaVersion reads a database's user_version
+func schemaVersion(path string) (int, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	var version int
+	err = db.QueryRow("PRAGMA user_version").Scan(&version)
+	return version, err
+}
+
+// checkDatabase opens a restored database and checks it's intact
+func checkDatabase(path string) (int, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	var result string
+	if err := db.QueryRow("PRAGMA quick_check").Scan(&result); err != nil {
+		return 0, err
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("database is damaged: %s", result)
+	}
+	var version int
+	err = db.QueryRow("PRAGMA user_version").Scan(&version)
+	return version, err
+}
+
+// Check checks db is intact and no newer than this build understands, returning its schema version
+// A database that hasn't been created yet passes with version 0
+func Check(db Database) (int, error) {
+	if _, err := os.Stat(db.Path); errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	version, err := checkDatabase(db.Path)
+	if err != nil {
+		return version, err
+	}
+	if version > db.Version {
+		return version, fmt.Errorf("schema version %d is from a newer SongMartyn (this one supports up to %d)", version, db.Version)
+	}
+	return version, nil
+}
+
+// Vacuum rebuilds each database in set to give back unused space, returning the bytes freed
+// It waits for writers on other connections, so the databases can stay in use
+func Vacuum(ctx context.Context, set Set) (int64, error) {
+	var freed int64
+	for _, db := range set.Databases {
+		before, err := os.Stat(db.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return freed, err
+		}
+		if err := vacuum(ctx, db.Path); err != nil {
+			return freed, fmt.Errorf("%s: %w", db.Name, err)
+		}
+		if after, err := os.Stat(db.Path); err == nil {
+			freed += before.Size() - after.Size()
+		}
+	}
+	return freed, nil
+}
+
+// vacuum runs VACUUM on the database at path
+func vacuum(ctx context.Context, path string) error {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=%d", path, vacuumBusyTimeout.Milliseconds()))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, err = db.ExecContext(ctx, "VACUUM")
+	return err
+}
+
+// Save writes a new archive of set into dir, named for when it was taken
+func Save(ctx context.Context, dir string, set Set) (Info, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return Info{}, err
+	}
+	tmp, err := os.CreateTemp(dir, ".songmartyn-*.tmp")
+	if err != nil {
+		return Info{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	m, err := Create(ctx, tmp, set)
+	if err != nil {
+		tmp.Close()
+		return Info{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return Info{}, err
+	}
+
+	name := namePrefix + m.CreatedAt.Format(nameLayout) + Extension
+	path := filepath.Join(dir, name)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return Info{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{Name: name, Size: info.Size(), CreatedAt: m.CreatedAt}, nil
+}
+
+// List returns the archives in dir, newest first
+func List(dir string) ([]Info, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Info{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []Info{}
+	for _, e := range entries {
+		created, ok := parseName(e.Name())
+		if !ok || !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Info{Name: e.Name(), Size: info.Size(), CreatedAt: created})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// parseName returns when an archive named by Save was taken
+func parseName(name string) (time.Time, bool) {
+	stamp, ok := strings.CutPrefix(name, namePrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	stamp, ok = strings.CutSuffix(stamp, Extension)
+	if !ok {
+		return time.Time{}, false
+	}
+	created, err := time.ParseInLocation(nameLayout, stamp, time.Local)
+	return created, err == nil
+}
+
+// Path returns the path of the archive called name in dir
+// Only names List would return are accepted, so name can come from a request
+func Path(dir, name string) (string, error) {
+	if _, ok := parseName(name); !ok || filepath.Base(name) != name {
+		return "", ErrNotFound
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrNotFound
+	}
+	return path, nil
+}
+
+// Delete removes the archive called name from dir
+func Delete(dir, name string) error {
+	path, err := Path(dir, name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Rotate deletes all but the newest keep archives in dir (keep <= 0 keeps everything)
+// Returns the names deleted
+func Rotate(dir string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	backups, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, b := range backups[min(keep, len(backups)):] {
+		if err := os.Remove(filepath.Join(dir, b.Name)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, b.Name)
+	}
+	return removed, nil
+}
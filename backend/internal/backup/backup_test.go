@@ -0,0 +1,394 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSet makes a data directory with one database and a configuration file
+func newTestSet(t *testing.T) (string, Set) {
+	t.Helper()
+	dir := t.TempDir()
+	set := Set{
+		Databases: []Database{
+			{Name: "queue.db", Path: filepath.Join(dir, "queue.db"), Version: 2},
+			{Name: "recordings.db", Path: filepath.Join(dir, "recordings.db"), Version: 1},
+		},
+		Files: []File{{Name: "songmartyn.toml", Path: filepath.Join(dir, "songmartyn.toml")}},
+	}
+	exec(t, set.Databases[0].Path,
+		"CREATE TABLE queue (id TEXT PRIMARY KEY, title TEXT)",
+		"INSERT INTO queue VALUES ('s1', 'Africa')",
+		"PRAGMA user_version = 2")
+	writeTestFile(t, set.Files[0].Path, "[server]\nhttps_port = 8443\n")
+	return dir, set
+}
+
+func exec(t *testing.T, path string, statements ...string) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, s := range statements {
+		if _, err := db.Exec(s); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+}
+
+func queueTitles(t *testing.T, path string) []string {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT title FROM queue ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var titles []string
+	for rows.Next() {
+		var title string
+		rows.Scan(&title)
+		titles = append(titles, title)
+	}
+	return titles
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// archive builds an archive by hand, for ones Create wouldn't write
+func archive(t *testing.T, files map[string][]byte) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data))})
+		tw.Write(data)
+	}
+	tw.Close()
+	gz.Close()
+	return &buf
+}
+
+// ============================================================================
+// Backup Tests
+// ============================================================================
+
+func TestBackupAndRestore(t *testing.T) {
+	dir, set := newTestSet(t)
+
+	// The database stays open and in use while it's backed up
+	live, err := sql.Open("sqlite3", set.Databases[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+
+	var buf bytes.Buffer
+	m, err := Create(context.Background(), &buf, set)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if m.Databases["queue.db"] != 2 || len(m.Databases) != 1 {
+		t.Errorf("Expected queue.db at version 2 (recordings.db doesn't exist), got %v", m.Databases)
+	}
+	if len(m.Files) != 1 || m.Files[0] != "songmartyn.toml" {
+		t.Errorf("Expected the configuration file, got %v", m.Files)
+	}
+
+	// Everything changes after the backup
+	live.Exec("INSERT INTO queue VALUES ('s2', 'Toto')")
+	writeTestFile(t, set.Files[0].Path, "[server]\nhttps_port = 9443\n")
+
+	if _, err := Stage(&buf, dir, set); err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	if got := queueTitles(t, set.Databases[0].Path); len(got) != 2 {
+		t.Errorf("Expected staging to leave the live data alone, got %v", got)
+	}
+	live.Close()
+
+	restored, err := ApplyPending(dir, set)
+	if err != nil || restored == nil {
+		t.Fatalf("Expected the staged restore to apply, got %v, %v", restored, err)
+	}
+	if got := queueTitles(t, set.Databases[0].Path); len(got) != 1 || got[0] != "Africa" {
+		t.Errorf("Expected the backed up queue, got %v", got)
+	}
+	if got := readTestFile(t, set.Files[0].Path); !strings.Contains(got, "8443") {
+		t.Errorf("Expected the backed up configuration, got %q", got)
+	}
+	if got := queueTitles(t, set.Databases[0].Path+PreviousSuffix); len(got) != 2 {
+		t.Errorf("Expected the replaced database kept alongside, got %v", got)
+	}
+	if _, err := os.Stat(set.Databases[1].Path); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected databases missing from the backup to be left alone")
+	}
+
+	if m, err := ApplyPending(dir, set); m != nil || err != nil {
+		t.Errorf("Expected nothing left to apply, got %v, %v", m, err)
+	}
+}
+
+func TestBackupNestedNames(t *testing.T) {
+	dir, set := newTestSet(t)
+	room := Database{Name: "rooms/studio-b/queue.db", Path: filepath.Join(dir, "rooms", "studio-b", "queue.db"), Version: 2}
+	os.MkdirAll(filepath.Dir(room.Path), 0755)
+	exec(t, room.Path,
+		"CREATE TABLE queue (id TEXT PRIMARY KEY, title TEXT)",
+		"INSERT INTO queue VALUES ('s9', 'Rosanna')",
+		"PRAGMA user_version = 2")
+	set.Databases = append(set.Databases, room)
+
+	var buf bytes.Buffer
+	if _, err := Create(context.Background(), &buf, set); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "rooms")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Stage(&buf, dir, set); err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	if _, err := ApplyPending(dir, set); err != nil {
+		t.Fatalf("ApplyPending failed: %v", err)
+	}
+	if got := queueTitles(t, room.Path); len(got) != 1 || got[0] != "Rosanna" {
+		t.Errorf("Expected the room's queue restored, got %v", got)
+	}
+}
+
+func TestRestoreRejectsNewerSchema(t *testing.T) {
+	dir, set := newTestSet(t)
+	var buf bytes.Buffer
+	if _, err := Create(context.Background(), &buf, set); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// An older SongMartyn only knows schema 1
+	older := set
+	older.Databases = []Database{{Name: "queue.db", Path: set.Databases[0].Path, Version: 1}}
+	_, err := Stage(&buf, dir, older)
+	if err == nil || !strings.Contains(err.Error(), "newer SongMartyn") {
+		t.Errorf("Expected a newer schema to be refused, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, PendingDir)); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected nothing staged")
+	}
+}
+
+func TestRestoreRejectsBadArchives(t *testing.T) {
+	dir, set := newTestSet(t)
+	db := []byte(readTestFile(t, set.Databases[0].Path))
+
+	tests := []struct {
+		name  string
+		data  *bytes.Buffer
+		error string
+	}{
+		{"not gzip", bytes.NewBufferString("hello"), "not a SongMartyn backup"},
+		{"no manifest", archive(t, map[string][]byte{"queue.db": db}), "no manifest.json"},
+		{"future format", archive(t, map[string][]byte{
+			ManifestName: []byte(`{"format": 99, "databases": {"queue.db": 2}}`),
+			"queue.db":   db,
+		}), "format 99"},
+		{"version mismatch", archive(t, map[string][]byte{
+			ManifestName: []byte(`{"format": 1, "databases": {"queue.db": 1}}`),
+			"queue.db":   db,
+		}), "doesn't match"},
+		{"damaged database", archive(t, map[string][]byte{
+			ManifestName: []byte(`{"format": 1, "databases": {"queue.db": 0}}`),
+			"queue.db":   []byte("not a database"),
+		}), "queue.db"},
+		{"missing database", archive(t, map[string][]byte{
+			ManifestName: []byte(`{"format": 1, "databases": {"queue.db": 2}}`),
+		}), "missing"},
+		{"unknown database", archive(t, map[string][]byte{
+			ManifestName: []byte(`{"format": 1, "databases": {"other.db": 1}}`),
+			"other.db":   db,
+		}), "unknown database"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Stage(tt.data, dir, set)
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("Expected an error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestRestoreIgnoresPathsOutsideTheSet(t *testing.T) {
+	dir, set := newTestSet(t)
+	db := []byte(readTestFile(t, set.Databases[0].Path))
+	data := archive(t, map[string][]byte{
+		ManifestName:           []byte(`{"format": 1, "databases": {"queue.db": 2}}`),
+		"queue.db":             db,
+		"../escape.txt":        []byte("nope"),
+		"songmartyn.toml/../x": []byte("nope"),
+	})
+	if _, err := Stage(data, dir, set); err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected entries outside the set to be skipped")
+	}
+}
+
+func TestSaveListAndRotate(t *testing.T) {
+	_, set := newTestSet(t)
+	dir := filepath.Join(t.TempDir(), "backups")
+
+	if backups, err := List(dir); err != nil || len(backups) != 0 {
+		t.Errorf("Expected no backups before the first, got %v, %v", backups, err)
+	}
+
+	// Archives are named to the second, so make three from different times
+	now := time.Now()
+	for i := range 3 {
+		info, err := Save(context.Background(), dir, set)
+		if err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		stamp := now.Add(time.Duration(i-3) * time.Hour)
+		name := namePrefix + stamp.Format(nameLayout) + Extension
+		os.Rename(filepath.Join(dir, info.Name), filepath.Join(dir, name))
+	}
+	writeTestFile(t, filepath.Join(dir, "notes.txt"), "not a backup")
+
+	backups, err := List(dir)
+	if err != nil || len(backups) != 3 {
+		t.Fatalf("Expected 3 backups, got %v, %v", backups, err)
+	}
+	if !backups[0].CreatedAt.After(backups[1].CreatedAt) || backups[0].Size == 0 {
+		t.Errorf("Expected newest first with sizes, got %+v", backups)
+	}
+
+	removed, err := Rotate(dir, 2)
+	if err != nil || len(removed) != 1 || removed[0] != backups[2].Name {
+		t.Errorf("Expected the oldest removed, got %v, %v", removed, err)
+	}
+	if backups, _ := List(dir); len(backups) != 2 {
+		t.Errorf("Expected 2 backups kept, got %d", len(backups))
+	}
+
+	if err := Delete(dir, backups[0].Name); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	for _, name := range []string{"notes.txt", "../" + backups[1].Name, backups[0].Name} {
+		if _, err := Path(dir, name); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected %q not found, got %v", name, err)
+		}
+	}
+}
+
+func TestBackupWhileWriting(t *testing.T) {
+	_, set := newTestSet(t)
+	live, err := sql.Open("sqlite3", set.Databases[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				live.Exec("INSERT INTO queue VALUES (?, ?)", fmt.Sprintf("w%d", i), "Song")
+			}
+		}
+	}()
+
+	var buf bytes.Buffer
+	_, err = Create(context.Background(), &buf, set)
+	close(stop)
+	<-done
+	if err != nil {
+		t.Fatalf("Create failed while the database was being written: %v", err)
+	}
+	if _, err := Stage(&buf, t.TempDir(), set); err != nil {
+		t.Errorf("Expected a consistent snapshot, got %v", err)
+	}
+}
+
+// ============================================================================
+// Maintenance Tests
+// ============================================================================
+
+func TestVacuumFreesSpace(t *testing.T) {
+	_, set := newTestSet(t)
+	path := set.Databases[0].Path
+	exec(t, path, "CREATE TABLE filler (data BLOB)")
+	for range 20 {
+		exec(t, path, "INSERT INTO filler VALUES (zeroblob(65536))")
+	}
+	exec(t, path, "DELETE FROM filler")
+
+	freed, err := Vacuum(context.Background(), set)
+	if err != nil {
+		t.Fatalf("Vacuum failed: %v", err)
+	}
+	if freed < 20*65536/2 {
+		t.Errorf("Expected the deleted rows' space back, got %d bytes", freed)
+	}
+	if got := queueTitles(t, path); len(got) != 1 {
+		t.Errorf("Expected the data kept, got %v", got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	_, set := newTestSet(t)
+
+	if version, err := Check(set.Databases[0]); err != nil || version != 2 {
+		t.Errorf("Expected version 2, got %d, %v", version, err)
+	}
+	if version, err := Check(set.Databases[1]); err != nil || version != 0 {
+		t.Errorf("Expected a database not created yet to pass, got %d, %v", version, err)
+	}
+
+	older := set.Databases[0]
+	older.Version = 1
+	if _, err := Check(older); err == nil || !strings.Contains(err.Error(), "newer SongMartyn") {
+		t.Errorf("Expected a newer schema to fail, got %v", err)
+	}
+
+	writeTestFile(t, set.Databases[1].Path, strings.Repeat("not a database", 100))
+	if _, err := Check(set.Databases[1]); err == nil {
+		t.Error("Expected a damaged database to fail")
+	}
+}
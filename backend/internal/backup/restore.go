@@ -0,0 +1,215 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Stage unpacks an archive into the data directory's PendingDir and checks it can be
+// restored: a known format, intact databases, and no schema newer than this build's.
+// Nothing in use is touched; ApplyPending swaps the data in before the databases are opened.
+// A previously staged restore is replaced.
+func Stage(r io.Reader, dataDir string, set Set) (Manifest, error) {
+	tmp, err := os.MkdirTemp(dataDir, ".restore-")
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer os.RemoveAll(tmp)
+
+	m, err := extract(r, tmp, set)
+	if err != nil {
+		return m, err
+	}
+	if err := validate(tmp, m, set); err != nil {
+		return m, err
+	}
+	manifest, err := json.Marshal(m)
+	if err != nil {
+		return m, err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, ManifestName), manifest, 0600); err != nil {
+		return m, err
+	}
+
+	pending := filepath.Join(dataDir, PendingDir)
+	if err := os.RemoveAll(pending); err != nil {
+		return m, err
+	}
+	return m, os.Rename(tmp, pending)
+}
+
+// extract unpacks the manifest and the entries set knows about into dir
+// Anything else in the archive is ignored, so names can't point outside dir
+func extract(r io.Reader, dir string, set Set) (Manifest, error) {
+	var m Manifest
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return m, ErrInvalidArchive
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	sawManifest := false
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return m, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if hdr.Name == ManifestName {
+			if err := json.NewDecoder(tr).Decode(&m); err != nil {
+				return m, fmt.Errorf("%w: bad manifest: %v", ErrInvalidArchive, err)
+			}
+			sawManifest = true
+			continue
+		}
+		_, isDB := set.database(hdr.Name)
+		_, isFile := set.file(hdr.Name)
+		if !isDB && !isFile {
+			continue
+		}
+		if err := writeFile(filepath.Join(dir, hdr.Name), tr); err != nil {
+			return m, err
+		}
+	}
+	if !sawManifest {
+		return m, fmt.Errorf("%w: no %s", ErrInvalidArchive, ManifestName)
+	}
+	return m, nil
+}
+
+// writeFile copies r into a new file at path
+func writeFile(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// validate checks the unpacked archive in dir against its manifest and this build
+func validate(dir string, m Manifest, set Set) error {
+	if m.Format != FormatVersion {
+		return fmt.Errorf("backup format %d is not supported (expected %d)", m.Format, FormatVersion)
+	}
+	if len(m.Databases) == 0 {
+		return fmt.Errorf("%w: no databases", ErrInvalidArchive)
+	}
+
+	for name, version := range m.Databases {
+		db, ok := set.database(name)
+		if !ok {
+			return fmt.Errorf("%s: unknown database", name)
+		}
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("%s: missing from the archive", name)
+		}
+		actual, err := checkDatabase(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if actual != version {
+			return fmt.Errorf("%s: schema version %d doesn't match the manifest's %d", name, actual, version)
+		}
+		// Older schemas are upgraded by the usual migrations when the database is opened
+		if version > db.Version {
+			return fmt.Errorf("%s: schema version %d is from a newer SongMartyn (this one supports up to %d)", name, version, db.Version)
+		}
+	}
+	for _, name := range m.Files {
+		if _, ok := set.file(name); !ok {
+			return fmt.Errorf("%s: unknown file", name)
+		}
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("%s: missing from the archive", name)
+		}
+	}
+	return nil
+}
+
+// ApplyPending moves a staged restore into place, keeping each replaced file
+// alongside with PreviousSuffix. Call it before any database in set is opened.
+// Returns nil when no restore is waiting.
+func ApplyPending(dataDir string, set Set) (*Manifest, error) {
+	pending := filepath.Join(dataDir, PendingDir)
+	data, err := os.ReadFile(filepath.Join(pending, ManifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%w: bad manifest: %v", ErrInvalidArchive, err)
+	}
+
+	for name := range m.Databases {
+		db, ok := set.database(name)
+		if !ok {
+			continue
+		}
+		// A journal left by the replaced database would be replayed into the restored one
+		for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+			os.Remove(db.Path + suffix)
+		}
+		if err := replace(filepath.Join(pending, name), db.Path); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	for _, name := range m.Files {
+		f, ok := set.file(name)
+		if !ok {
+			continue
+		}
+		if err := replace(filepath.Join(pending, name), f.Path); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return &m, os.RemoveAll(pending)
+}
+
+// replace moves src to dst, keeping the old dst with PreviousSuffix
+// A missing src was moved by an earlier, interrupted ApplyPending
+func replace(src, dst string) error {
+	if _, err := os.Stat(src); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(dst, dst+PreviousSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	// Different filesystems (e.g. a configuration file outside the data directory)
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return writeFile(dst, in)
+}
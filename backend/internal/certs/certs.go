@@ -0,0 +1,321 @@
+package certs
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Files kept in the authority's directory
+const (
+	caCertFile   = "ca.pem"
+	caKeyFile    = "ca-key.pem"
+	leafCertFile = "cert.pem"
+	leafKeyFile  = "key.pem"
+)
+
+// Lifetimes
+const (
+	caLifetime   = 10 * 365 * 24 * time.Hour
+	leafLifetime = 397 * 24 * time.Hour // Apple rejects server certificates valid for more than 398 days
+	renewBefore  = 30 * 24 * time.Hour
+)
+
+// The CA may only vouch for local names and private addresses, so a leaked key
+// can't be used against guests' phones anywhere else
+var (
+	permittedDomains = []string{"local", "localhost"}
+	permittedRanges  = mustParseCIDRs(
+		"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "169.254.0.0/16", "127.0.0.0/8",
+		"fc00::/7", "fe80::/10", "::1/128",
+	)
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+// Authority is SongMartyn's own root CA and the server certificate it issues
+type Authority struct {
+	dir   string
+	now   func() time.Time
+	ca    *x509.Certificate
+	caKey *ecdsa.PrivateKey
+
+	mu    sync.RWMutex
+	leaf  *tls.Certificate
+	hosts []string
+}
+
+// NewAuthority loads the CA in dir, creating it on first run
+// A server certificate left by a previous run is reused until Issue replaces it
+func NewAuthority(dir string) (*Authority, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create CA dir: %w", err)
+	}
+	a := &Authority{dir: dir, now: time.Now}
+
+	if err := a.loadCA(); errors.Is(err, os.ErrNotExist) {
+		err = a.createCA()
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	a.loadLeaf()
+	return a, nil
+}
+
+// loadCA reads the CA certificate and key
+func (a *Authority) loadCA() error {
+	pair, err := tls.LoadX509KeyPair(filepath.Join(a.dir, caCertFile), filepath.Join(a.dir, caKeyFile))
+	if err != nil {
+		if _, statErr := os.Stat(filepath.Join(a.dir, caCertFile)); errors.Is(statErr, os.ErrNotExist) {
+			return statErr
+		}
+		return fmt.Errorf("failed to load CA: %w", err)
+	}
+	key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return fmt.Errorf("failed to load CA: unsupported key type")
+	}
+	a.ca, a.caKey = pair.Leaf, key
+	return nil
+}
+
+// createCA generates and saves a new root certificate
+func (a *Authority) createCA() error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return err
+	}
+
+	name := "SongMartyn Local CA"
+	if host, err := os.Hostname(); err == nil && host != "" {
+		name += " (" + host + ")"
+	}
+	now := a.now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name, Organization: []string{"SongMartyn"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caLifetime),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+		PermittedDNSDomains:   permittedDomains,
+		PermittedIPRanges:     permittedRanges,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to create CA: %w", err)
+	}
+	if err := writePair(filepath.Join(a.dir, caCertFile), filepath.Join(a.dir, caKeyFile), der, key); err != nil {
+		return err
+	}
+	a.ca, _ = x509.ParseCertificate(der)
+	a.caKey = key
+	return nil
+}
+
+// loadLeaf picks up the last server certificate if this CA signed it
+func (a *Authority) loadLeaf() {
+	pair, err := tls.LoadX509KeyPair(filepath.Join(a.dir, leafCertFile), filepath.Join(a.dir, leafKeyFile))
+	if err != nil || pair.Leaf.CheckSignatureFrom(a.ca) != nil {
+		return
+	}
+	a.leaf = &pair
+	a.hosts = leafHosts(pair.Leaf)
+}
+
+// Issue makes sure the server certificate covers exactly hosts (names or IP
+// addresses) and isn't close to expiring, issuing a new one if not
+// Hosts outside the CA's local-only constraints are left out
+// Returns whether a new certificate was issued
+func (a *Authority) Issue(hosts []string) (bool, error) {
+	hosts = normalizeHosts(hosts)
+	if len(hosts) == 0 {
+		return false, fmt.Errorf("no local hosts to issue a certificate for")
+	}
+	now := a.now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.leaf != nil && slices.Equal(a.hosts, hosts) && now.Before(a.leaf.Leaf.NotAfter.Add(-renewBefore)) {
+		return false, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return false, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return false, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: hosts[0], Organization: []string{"SongMartyn"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(leafLifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.ca, &key.PublicKey, a.caKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to issue certificate: %w", err)
+	}
+	if err := writePair(filepath.Join(a.dir, leafCertFile), filepath.Join(a.dir, leafKeyFile), der, key); err != nil {
+		return false, err
+	}
+
+	leaf, _ := x509.ParseCertificate(der)
+	a.leaf = &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+	a.hosts = hosts
+	return true, nil
+}
+
+// GetCertificate serves the current server certificate; use it as tls.Config.GetCertificate
+func (a *Authority) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.leaf == nil {
+		return nil, fmt.Errorf("no certificate issued yet")
+	}
+	return a.leaf, nil
+}
+
+// Hosts returns the names and addresses the server certificate covers
+func (a *Authority) Hosts() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return slices.Clone(a.hosts)
+}
+
+// Name returns the CA's common name
+func (a *Authority) Name() string {
+	return a.ca.Subject.CommonName
+}
+
+// Certificate returns the CA certificate
+func (a *Authority) Certificate() *x509.Certificate {
+	return a.ca
+}
+
+// Fingerprint returns the CA certificate's SHA-256 fingerprint, as devices show it
+func (a *Authority) Fingerprint() string {
+	sum := sha256.Sum256(a.ca.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// permits reports whether the CA's name constraints allow host
+func permits(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		for _, n := range permittedRanges {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, domain := range permittedDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHosts lowercases, drops duplicates and hosts the CA can't vouch for,
+// and sorts names before addresses
+func normalizeHosts(hosts []string) []string {
+	var names, ips []string
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+		if ip := net.ParseIP(host); ip != nil {
+			host = ip.String()
+		}
+		if host == "" || !permits(host) || slices.Contains(names, host) || slices.Contains(ips, host) {
+			continue
+		}
+		if net.ParseIP(host) != nil {
+			ips = append(ips, host)
+		} else {
+			names = append(names, host)
+		}
+	}
+	slices.Sort(names)
+	slices.Sort(ips)
+	return append(names, ips...)
+}
+
+// leafHosts lists a certificate's subject alternative names the way Issue stores them
+func leafHosts(cert *x509.Certificate) []string {
+	hosts := slices.Clone(cert.DNSNames)
+	for _, ip := range cert.IPAddresses {
+		hosts = append(hosts, ip.String())
+	}
+	return normalizeHosts(hosts)
+}
+
+// randomSerial returns a random 128-bit serial number
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// writePair saves a certificate and its key as PEM, the key readable only by us
+func writePair(certPath, keyPath string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	var certPEM, keyPEM bytes.Buffer
+	pem.Encode(&certPEM, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	pem.Encode(&keyPEM, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(keyPath, keyPEM.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(certPath, certPEM.Bytes(), 0644)
+}
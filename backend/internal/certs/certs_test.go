@@ -0,0 +1,185 @@
+package certs
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verify checks the server certificate chains to the CA for host
+func verify(t *testing.T, a *Authority, host string) error {
+	t.Helper()
+	cert, err := a.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate failed: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(a.Certificate())
+	_, err = cert.Leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots})
+	return err
+}
+
+// ============================================================================
+// Authority Tests
+// ============================================================================
+
+func TestAuthorityPersistsCA(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewAuthority(dir)
+	if err != nil {
+		t.Fatalf("NewAuthority failed: %v", err)
+	}
+	if !a.Certificate().IsCA {
+		t.Error("Expected a CA certificate")
+	}
+	if _, err := a.Issue([]string{"karaoke.local", "192.168.1.20"}); err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	again, err := NewAuthority(dir)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if again.Fingerprint() != a.Fingerprint() {
+		t.Error("Expected the same CA after reload")
+	}
+	if hosts := again.Hosts(); !slices.Equal(hosts, []string{"karaoke.local", "192.168.1.20"}) {
+		t.Errorf("Expected the previous certificate's hosts, got %v", hosts)
+	}
+	if rotated, _ := again.Issue([]string{"192.168.1.20", "karaoke.local"}); rotated {
+		t.Error("Expected the reloaded certificate to be reused")
+	}
+}
+
+func TestIssueCoversLocalHosts(t *testing.T) {
+	a, err := NewAuthority(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewAuthority failed: %v", err)
+	}
+	rotated, err := a.Issue([]string{"Karaoke.local", "localhost", "192.168.1.20", "10.0.0.5", "::1", "8.8.8.8", "example.com", "localhost"})
+	if err != nil || !rotated {
+		t.Fatalf("Expected a new certificate, got %v, %v", rotated, err)
+	}
+
+	want := []string{"karaoke.local", "localhost", "10.0.0.5", "192.168.1.20", "::1"}
+	if hosts := a.Hosts(); !slices.Equal(hosts, want) {
+		t.Errorf("Expected hosts %v, got %v", want, hosts)
+	}
+	for _, host := range want {
+		if err := verify(t, a, host); err != nil {
+			t.Errorf("Expected certificate to be valid for %s: %v", host, err)
+		}
+	}
+	if err := verify(t, a, "example.com"); err == nil {
+		t.Error("Expected example.com to be left out")
+	}
+
+	if _, err := a.Issue([]string{"8.8.8.8"}); err == nil {
+		t.Error("Expected an error with no local hosts")
+	}
+}
+
+func TestIssueRotates(t *testing.T) {
+	a, err := NewAuthority(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewAuthority failed: %v", err)
+	}
+	now := time.Now()
+	a.now = func() time.Time { return now }
+
+	a.Issue([]string{"karaoke.local", "192.168.1.20"})
+	first, _ := a.GetCertificate(nil)
+
+	if rotated, _ := a.Issue([]string{"karaoke.local", "192.168.1.20"}); rotated {
+		t.Error("Expected no rotation for the same hosts")
+	}
+
+	// Moving to another network changes the addresses
+	if rotated, _ := a.Issue([]string{"karaoke.local", "10.0.0.7"}); !rotated {
+		t.Error("Expected rotation when the addresses change")
+	}
+	second, _ := a.GetCertificate(nil)
+	if second.Leaf.SerialNumber.Cmp(first.Leaf.SerialNumber) == 0 {
+		t.Error("Expected a new certificate")
+	}
+	if err := verify(t, a, "192.168.1.20"); err == nil {
+		t.Error("Expected the old address to be dropped")
+	}
+
+	// Nearing expiry renews even with the same hosts
+	now = second.Leaf.NotAfter.Add(-renewBefore + time.Hour)
+	if rotated, _ := a.Issue([]string{"karaoke.local", "10.0.0.7"}); !rotated {
+		t.Error("Expected renewal near expiry")
+	}
+}
+
+// ============================================================================
+// Install Page Tests
+// ============================================================================
+
+func TestHandlerServesCA(t *testing.T) {
+	a, err := NewAuthority(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewAuthority failed: %v", err)
+	}
+	handler := a.Handler(func() string { return "8443" })
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://karaoke.local:8080"+path, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get(CertPath)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-x509-ca-cert" {
+		t.Fatalf("Expected the certificate download, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if cert, err := x509.ParseCertificate(rec.Body.Bytes()); err != nil || !cert.Equal(a.Certificate()) {
+		t.Errorf("Expected the CA certificate in DER, got %v", err)
+	}
+
+	rec = get(ProfilePath)
+	profile := rec.Body.String()
+	if !strings.Contains(profile, "com.apple.security.root") {
+		t.Error("Expected a root certificate payload in the profile")
+	}
+	if get(ProfilePath).Body.String() != profile {
+		t.Error("Expected the profile to be stable across downloads")
+	}
+
+	rec = get(PagePath)
+	page := rec.Body.String()
+	if !strings.Contains(page, a.Fingerprint()) {
+		t.Error("Expected the fingerprint on the install page")
+	}
+	if !strings.Contains(page, `"https://karaoke.local:8443"`) {
+		t.Error("Expected the page to continue to the HTTPS app on the same host")
+	}
+	if page := get(PagePath + "?room=studio-b").Body.String(); !strings.Contains(page, `"https://karaoke.local:8443/?room=studio-b"`) {
+		t.Error("Expected the page to continue into the room that was scanned")
+	}
+
+	if get("/ca/other").Code != http.StatusNotFound {
+		t.Error("Expected 404 for unknown paths")
+	}
+}
+
+func TestAppURL(t *testing.T) {
+	tests := []struct {
+		host, port, want string
+	}{
+		{"karaoke.local:8080", "8443", "https://karaoke.local:8443"},
+		{"192.168.1.20", "443", "https://192.168.1.20"},
+		{"[fe80::1]:8080", "8443", "https://[fe80::1]:8443"},
+	}
+	for _, tt := range tests {
+		if got := appURL(tt.host, tt.port); got != tt.want {
+			t.Errorf("appURL(%q, %q): expected %s, got %s", tt.host, tt.port, tt.want, got)
+		}
+	}
+}
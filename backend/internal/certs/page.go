@@ -0,0 +1,135 @@
+package certs
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Paths served by Handler
+const (
+	PagePath    = "/ca/"
+	CertPath    = "/ca/songmartyn-ca.crt"
+	ProfilePath = "/ca/songmartyn.mobileconfig"
+)
+
+// installHTML walks guests through trusting the CA, then sends them on to the app
+//
+//go:embed install.html
+var installHTML string
+
+var installPage = template.Must(template.New("install").Parse(installHTML))
+
+// profileNamespace seeds the profile's UUIDs so reinstalling replaces the old profile
+var profileNamespace = uuid.MustParse("6f1c2a4e-8d0b-4a5e-9f3c-2b7d1e0a9c54")
+
+// Handler serves the install page and the CA downloads
+// Trusted devices are sent on to the app's HTTPS port, as appPort reports it, at the host they asked for
+func (a *Authority) Handler(appPort func() string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case CertPath:
+			w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+			w.Header().Set("Content-Disposition", `attachment; filename="songmartyn-ca.crt"`)
+			w.Write(a.ca.Raw)
+
+		case ProfilePath:
+			w.Header().Set("Content-Type", "application/x-apple-aspen-config")
+			w.Header().Set("Content-Disposition", `attachment; filename="songmartyn.mobileconfig"`)
+			w.Write(a.MobileConfig())
+
+		case PagePath:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			installPage.Execute(w, map[string]string{
+				"Name":        a.Name(),
+				"Fingerprint": a.Fingerprint(),
+				"CertPath":    CertPath,
+				"ProfilePath": ProfilePath,
+				"AppURL":      appURL(r.Host, appPort()) + roomQuery(r),
+			})
+
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// roomQuery carries the room a guest scanned into on to the app
+func roomQuery(r *http.Request) string {
+	if room := r.URL.Query().Get("room"); room != "" {
+		return "/?room=" + url.QueryEscape(room)
+	}
+	return ""
+}
+
+// appURL is the HTTPS address of the app on the host the page was loaded from
+func appURL(requestHost, port string) string {
+	host, _, err := net.SplitHostPort(requestHost)
+	if err != nil {
+		host = strings.Trim(requestHost, "[]")
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port == "" || port == "443" {
+		return "https://" + host
+	}
+	return "https://" + host + ":" + port
+}
+
+// MobileConfig returns an Apple configuration profile that installs the CA
+func (a *Authority) MobileConfig() []byte {
+	caUUID := uuid.NewSHA1(profileNamespace, a.ca.Raw)
+	profileUUID := uuid.NewSHA1(caUUID, []byte("profile"))
+	name := template.HTMLEscapeString(a.Name())
+
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PayloadContent</key>
+	<array>
+		<dict>
+			<key>PayloadCertificateFileName</key>
+			<string>songmartyn-ca.crt</string>
+			<key>PayloadContent</key>
+			<data>%s</data>
+			<key>PayloadDescription</key>
+			<string>Lets this device connect securely to SongMartyn on the local network.</string>
+			<key>PayloadDisplayName</key>
+			<string>%s</string>
+			<key>PayloadIdentifier</key>
+			<string>app.songmartyn.ca.%s</string>
+			<key>PayloadType</key>
+			<string>com.apple.security.root</string>
+			<key>PayloadUUID</key>
+			<string>%s</string>
+			<key>PayloadVersion</key>
+			<integer>1</integer>
+		</dict>
+	</array>
+	<key>PayloadDisplayName</key>
+	<string>%s</string>
+	<key>PayloadIdentifier</key>
+	<string>app.songmartyn.profile.%s</string>
+	<key>PayloadRemovalDisallowed</key>
+	<false/>
+	<key>PayloadType</key>
+	<string>Configuration</string>
+	<key>PayloadUUID</key>
+	<string>%s</string>
+	<key>PayloadVersion</key>
+	<integer>1</integer>
+</dict>
+</plist>
+`, base64.StdEncoding.EncodeToString(a.ca.Raw), name, caUUID, strings.ToUpper(caUUID.String()),
+		name, profileUUID, strings.ToUpper(profileUUID.String())))
+}
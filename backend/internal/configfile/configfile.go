@@ -0,0 +1,481 @@
+// Package configfile reads and writes SongMartyn's configuration file (TOML)
+package configfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+
+	"songmartyn/internal/holdingscreen"
+	"songmartyn/internal/logging"
+	"songmartyn/internal/loudness"
+	"songmartyn/internal/mpv"
+	"songmartyn/internal/recording"
+	"songmartyn/pkg/models"
+)
+
+// DefaultPath is where the configuration file lives unless told otherwise
+const DefaultPath = "songmartyn.toml"
+
+// File is the configuration file's schema
+// Every setting also has the environment variable it was read from before the file existed
+type File struct {
+	Server      Server      `toml:"server"`
+	TLS         TLS         `toml:"tls"`
+	Player      Player      `toml:"player"`
+	Features    Features    `toml:"features"`
+	YouTube     YouTube     `toml:"youtube"`
+	Loudness    Loudness    `toml:"loudness"`
+	Mic         Mic         `toml:"mic"`
+	Recording   Recording   `toml:"recording"`
+	Transitions Transitions `toml:"transitions"`
+	Holding     Holding     `toml:"holding"`
+	BGM         BGM         `toml:"bgm"`
+	Logging     Logging     `toml:"logging"`
+	Backup      Backup      `toml:"backup"`
+	Rooms       []Room      `toml:"rooms"` // Extra karaoke rooms; the sections above are the main room
+}
+
+// Server is the [server] section
+type Server struct {
+	HTTPSPort     int    `toml:"https_port" env:"HTTPS_PORT"`
+	HTTPPort      int    `toml:"http_port" env:"HTTP_PORT"` // Redirects to HTTPS and serves the CA install page
+	DataDir       string `toml:"data_dir" env:"DATA_DIR"`
+	AdminPIN      string `toml:"admin_pin" env:"ADMIN_PIN"` // Empty = admin panel from localhost only
+	MDNSHostname  string `toml:"mdns_hostname" env:"MDNS_HOSTNAME"`
+	LaunchBrowser bool   `toml:"launch_browser" env:"LAUNCH_BROWSER"`
+}
+
+// TLS is the [tls] section
+type TLS struct {
+	Cert    string `toml:"cert" env:"TLS_CERT"`
+	Key     string `toml:"key" env:"TLS_KEY"`
+	LocalCA bool   `toml:"local_ca" env:"TLS_LOCAL_CA"` // Issue certificates from a built-in CA when Cert/Key are missing
+}
+
+// Player is the [player] section
+type Player struct {
+	Backend        string `toml:"backend" env:"PLAYER_BACKEND"` // "mpv" or "web"
+	VideoPlayer    string `toml:"video_player" env:"VIDEO_PLAYER"`
+	WebDisplayKey  string `toml:"web_display_key" env:"WEB_DISPLAY_KEY"`
+	TargetDisplay  string `toml:"target_display" env:"TARGET_DISPLAY"`
+	AutoFullscreen bool   `toml:"auto_fullscreen" env:"AUTO_FULLSCREEN"`
+}
+
+// Features is the [features] section
+type Features struct {
+	PitchControl      bool `toml:"pitch_control" env:"PITCH_CONTROL_ENABLED"`
+	TempoControl      bool `toml:"tempo_control" env:"TEMPO_CONTROL_ENABLED"`
+	FairRotation      bool `toml:"fair_rotation" env:"FAIR_ROTATION_ENABLED"`
+	ScrollingTicker   bool `toml:"scrolling_ticker" env:"SCROLLING_TICKER_ENABLED"`
+	SingerNameOverlay bool `toml:"singer_name_overlay" env:"SINGER_NAME_OVERLAY"`
+}
+
+// YouTube is the [youtube] section
+type YouTube struct {
+	APIKey string `toml:"api_key" env:"YOUTUBE_API_KEY"` // Empty = YouTube search disabled
+}
+
+// Loudness is the [loudness] section
+type Loudness struct {
+	Normalization bool    `toml:"normalization" env:"LOUDNESS_NORMALIZATION"`
+	TargetLUFS    float64 `toml:"target_lufs" env:"LOUDNESS_TARGET_LUFS"`
+	Decoder       string  `toml:"decoder" env:"LOUDNESS_DECODER"`
+}
+
+// Mic is the [mic] section
+type Mic struct {
+	EffectsEnabled bool   `toml:"effects_enabled" env:"MIC_EFFECTS_ENABLED"`
+	Device         string `toml:"device" env:"MIC_DEVICE"`
+}
+
+// Recording is the [recording] section
+type Recording struct {
+	Enabled       bool    `toml:"enabled" env:"RECORDING_ENABLED"`
+	Command       string  `toml:"command" env:"RECORDING_COMMAND"`
+	Extension     string  `toml:"extension" env:"RECORDING_EXTENSION"`
+	MaxMB         float64 `toml:"max_mb" env:"RECORDING_MAX_MB"`                 // 0 = unlimited
+	RetentionDays float64 `toml:"retention_days" env:"RECORDING_RETENTION_DAYS"` // 0 = keep
+	LinkHours     float64 `toml:"link_hours" env:"RECORDING_LINK_HOURS"`
+}
+
+// Transitions is the [transitions] section, in seconds (0 turns one off)
+type Transitions struct {
+	BGMCrossfade  float64 `toml:"bgm_crossfade" env:"TRANSITION_BGM_CROSSFADE"`
+	SongFadeOut   float64 `toml:"song_fade_out" env:"TRANSITION_SONG_FADE_OUT"`
+	HoldingFadeIn float64 `toml:"holding_fade_in" env:"TRANSITION_HOLDING_FADE_IN"`
+	TrimSilence   bool    `toml:"trim_silence" env:"TRIM_SILENCE"`
+}
+
+// Holding is the [holding] section
+type Holding struct {
+	Message string `toml:"message" env:"HOLDING_MESSAGE"`
+	Theme   string `toml:"theme" env:"HOLDING_THEME"`
+}
+
+// BGM is the [bgm] section
+type BGM struct {
+	Enabled bool    `toml:"enabled" env:"BGM_ENABLED"`
+	Source  string  `toml:"source" env:"BGM_SOURCE"` // "youtube" or "icecast"
+	URL     string  `toml:"url" env:"BGM_URL"`
+	Volume  float64 `toml:"volume" env:"BGM_VOLUME"` // 0-100
+}
+
+// Room is a [[rooms]] entry: a karaoke room with its own queue, player and admin PIN
+// The library, sessions and every setting not listed here are shared with the main room
+type Room struct {
+	ID       string     `toml:"id"` // Used in join links and under data_dir/rooms
+	Name     string     `toml:"name"`
+	AdminPIN string     `toml:"admin_pin"` // Empty = only venue admins manage the room
+	Player   RoomPlayer `toml:"player"`
+	BGM      BGM        `toml:"bgm"`
+	Holding  Holding    `toml:"holding"`
+}
+
+// RoomPlayer is a room's [rooms.player] table; extra rooms always play through mpv
+type RoomPlayer struct {
+	VideoPlayer    string `toml:"video_player"`
+	TargetDisplay  string `toml:"target_display"`
+	AutoFullscreen bool   `toml:"auto_fullscreen"`
+}
+
+// roomID is what room IDs may look like, so they're safe in URLs and paths
+var roomID = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// NewRoom returns a room with the default settings for anything the file leaves out
+func NewRoom(id, name string) Room {
+	d := Defaults()
+	return Room{
+		ID:   id,
+		Name: name,
+		Player: RoomPlayer{
+			VideoPlayer:    d.Player.VideoPlayer,
+			AutoFullscreen: d.Player.AutoFullscreen,
+		},
+		BGM:     d.BGM,
+		Holding: d.Holding,
+	}
+}
+
+// Logging is the [logging] section
+type Logging struct {
+	Level      string `toml:"level" env:"LOG_LEVEL"`           // debug, info, warn or error
+	Subsystems string `toml:"subsystems" env:"LOG_SUBSYSTEMS"` // Per-subsystem levels, e.g. "ws=debug,mpv=warn"
+	Buffer     int    `toml:"buffer" env:"LOG_BUFFER"`         // Recent entries kept for the admin log viewer
+}
+
+// Backup is the [backup] section
+type Backup struct {
+	Enabled       bool    `toml:"enabled" env:"BACKUP_ENABLED"`               // Scheduled backups into data_dir/backups
+	IntervalHours float64 `toml:"interval_hours" env:"BACKUP_INTERVAL_HOURS"` // Time between scheduled backups
+	Keep          int     `toml:"keep" env:"BACKUP_KEEP"`                     // Newest backups kept (0 = keep all)
+}
+
+// Defaults returns the configuration used for anything the file leaves out
+func Defaults() File {
+	return File{
+		Server: Server{
+			HTTPSPort:    8443,
+			HTTPPort:     8080,
+			DataDir:      "./data",
+			MDNSHostname: "karaoke",
+		},
+		TLS: TLS{
+			Cert:    "./certs/cert.pem",
+			Key:     "./certs/key.pem",
+			LocalCA: true,
+		},
+		Player: Player{
+			Backend:        "mpv",
+			VideoPlayer:    "mpv",
+			AutoFullscreen: true,
+		},
+		Features: Features{
+			PitchControl:      true,
+			TempoControl:      true,
+			ScrollingTicker:   true,
+			SingerNameOverlay: true,
+		},
+		Loudness: Loudness{
+			Normalization: true,
+			TargetLUFS:    -18,
+			Decoder:       loudness.DefaultDecoder,
+		},
+		Mic: Mic{
+			Device: mpv.DefaultMicDevice(),
+		},
+		Recording: Recording{
+			Command:       recording.DefaultCommand,
+			Extension:     "ogg",
+			MaxMB:         2048,
+			RetentionDays: 30,
+			LinkHours:     24,
+		},
+		Transitions: Transitions{
+			BGMCrossfade:  2,
+			HoldingFadeIn: 1,
+		},
+		Holding: Holding{
+			Theme: holdingscreen.DefaultThemeID,
+		},
+		BGM: BGM{
+			Source: string(models.BGMSourceYouTube),
+			Volume: 50,
+		},
+		Logging: Logging{
+			Level:  "info",
+			Buffer: logging.DefaultBufferSize,
+		},
+		Backup: Backup{
+			Enabled:       true,
+			IntervalHours: 24,
+			Keep:          7,
+		},
+	}
+}
+
+// KeyError is a setting with a bad value, named by its key (e.g. "server.https_port")
+type KeyError struct {
+	Key string
+	Msg string
+}
+
+func (e *KeyError) Error() string {
+	return e.Key + ": " + e.Msg
+}
+
+// Load reads the file at path on top of the defaults and validates it
+// Unknown keys and values of the wrong type are errors naming the key
+func Load(path string) (File, error) {
+	f := Defaults()
+
+	// Rooms get defaults too, so count them first and decode on top of default rooms
+	var count struct {
+		Rooms []struct{} `toml:"rooms"`
+	}
+	if _, err := toml.DecodeFile(path, &count); err == nil {
+		for range count.Rooms {
+			f.Rooms = append(f.Rooms, NewRoom("", ""))
+		}
+	}
+
+	md, err := toml.DecodeFile(path, &f)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return f, err
+		}
+		return f, fmt.Errorf("%s: %w", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return f, fmt.Errorf("%s: %w", path, &KeyError{Key: undecoded[0].String(), Msg: "unknown setting"})
+	}
+	if err := f.Validate(); err != nil {
+		return f, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Save writes f to path, replacing the file in one step so a reload never sees half of it
+func Save(path string, f File) error {
+	var buf bytes.Buffer
+	buf.WriteString("# SongMartyn configuration\n# Changes are picked up while the server runs; see songmartyn.example.toml for every setting\n\n")
+	if err := toml.NewEncoder(&buf).Encode(f); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".songmartyn-*.toml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// The file holds the admin PIN and API keys
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Validate checks every setting, returning all the problems found
+func (f File) Validate() error {
+	var errs []error
+	check := func(ok bool, key, msg string) {
+		if !ok {
+			errs = append(errs, &KeyError{Key: key, Msg: msg})
+		}
+	}
+
+	validPort := func(p int) bool { return p > 0 && p <= 65535 }
+	check(validPort(f.Server.HTTPSPort), "server.https_port", "must be between 1 and 65535")
+	check(validPort(f.Server.HTTPPort), "server.http_port", "must be between 1 and 65535")
+	check(f.Server.HTTPSPort != f.Server.HTTPPort, "server.http_port", "must differ from server.https_port")
+	check(f.Server.DataDir != "", "server.data_dir", "must not be empty")
+
+	check(f.Player.Backend == "mpv" || f.Player.Backend == "web", "player.backend", `must be "mpv" or "web"`)
+	check(f.Player.VideoPlayer != "", "player.video_player", "must not be empty")
+
+	check(f.Loudness.TargetLUFS >= -40 && f.Loudness.TargetLUFS <= -5, "loudness.target_lufs", "must be between -40 and -5")
+
+	check(f.Recording.Extension != "", "recording.extension", "must not be empty")
+	check(f.Recording.MaxMB >= 0, "recording.max_mb", "must not be negative")
+	check(f.Recording.RetentionDays >= 0, "recording.retention_days", "must not be negative")
+	check(f.Recording.LinkHours > 0, "recording.link_hours", "must be positive")
+
+	check(f.Transitions.BGMCrossfade >= 0, "transitions.bgm_crossfade", "must not be negative")
+	check(f.Transitions.SongFadeOut >= 0, "transitions.song_fade_out", "must not be negative")
+	check(f.Transitions.HoldingFadeIn >= 0, "transitions.holding_fade_in", "must not be negative")
+
+	source := models.BGMSourceType(f.BGM.Source)
+	check(source == models.BGMSourceYouTube || source == models.BGMSourceIcecast, "bgm.source", `must be "youtube" or "icecast"`)
+	check(f.BGM.Volume >= 0 && f.BGM.Volume <= 100, "bgm.volume", "must be between 0 and 100")
+
+	_, err := logging.ParseLevel(f.Logging.Level)
+	check(err == nil, "logging.level", "must be debug, info, warn or error")
+	if _, err := logging.ParseOverrides(f.Logging.Subsystems); err != nil {
+		errs = append(errs, &KeyError{Key: "logging.subsystems", Msg: err.Error()})
+	}
+	check(f.Logging.Buffer > 0, "logging.buffer", "must be positive")
+	check(f.Backup.IntervalHours > 0, "backup.interval_hours", "must be positive")
+	check(f.Backup.Keep >= 0, "backup.keep", "must not be negative")
+
+	ids := make(map[string]bool)
+	for i, r := range f.Rooms {
+		key := fmt.Sprintf("rooms[%d].", i)
+		check(roomID.MatchString(r.ID), key+"id", "must be up to 32 lowercase letters, digits and dashes")
+		check(r.ID != "main", key+"id", `"main" is the main room`)
+		check(!ids[r.ID], key+"id", "must be unique")
+		ids[r.ID] = true
+		check(r.Name != "", key+"name", "must not be empty")
+		check(r.Player.VideoPlayer != "", key+"player.video_player", "must not be empty")
+		source := models.BGMSourceType(r.BGM.Source)
+		check(source == models.BGMSourceYouTube || source == models.BGMSourceIcecast, key+"bgm.source", `must be "youtube" or "icecast"`)
+		check(r.BGM.Volume >= 0 && r.BGM.Volume <= 100, key+"bgm.volume", "must be between 0 and 100")
+	}
+
+	return errors.Join(errs...)
+}
+
+// setting is one field of the file with its key and environment variable
+type setting struct {
+	key   string
+	env   string
+	value reflect.Value
+}
+
+// settings lists every field of f
+func (f *File) settings() []setting {
+	var list []setting
+	file := reflect.ValueOf(f).Elem()
+	for i := 0; i < file.NumField(); i++ {
+		section := file.Field(i)
+		if section.Kind() != reflect.Struct {
+			continue // Rooms are only set in the file
+		}
+		sectionKey := file.Type().Field(i).Tag.Get("toml")
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Type().Field(j)
+			list = append(list, setting{
+				key:   sectionKey + "." + field.Tag.Get("toml"),
+				env:   field.Tag.Get("env"),
+				value: section.Field(j),
+			})
+		}
+	}
+	return list
+}
+
+// set parses an environment variable value into the setting
+// Booleans read the way .env files always have: "true", "1" or "yes"
+func (s setting) set(raw string) error {
+	switch s.value.Kind() {
+	case reflect.String:
+		s.value.SetString(raw)
+	case reflect.Bool:
+		s.value.SetBool(raw == "true" || raw == "1" || raw == "yes")
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", raw)
+		}
+		s.value.SetInt(int64(n))
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		s.value.SetFloat(n)
+	}
+	return nil
+}
+
+// ApplyEnv overrides settings with the environment variables lookup finds
+// Empty values are ignored, as they always were
+func ApplyEnv(f *File, lookup func(string) (string, bool)) error {
+	for _, s := range f.settings() {
+		raw, ok := lookup(s.env)
+		if !ok || raw == "" {
+			continue
+		}
+		if err := s.set(raw); err != nil {
+			return fmt.Errorf("%s (%s): %w", s.env, s.key, err)
+		}
+	}
+	return nil
+}
+
+// EnvOverrides lists the keys the environment overrides, for logging
+func EnvOverrides(lookup func(string) (string, bool)) []string {
+	var keys []string
+	for _, s := range new(File).settings() {
+		if raw, ok := lookup(s.env); ok && raw != "" {
+			keys = append(keys, s.key+" ("+s.env+")")
+		}
+	}
+	return keys
+}
+
+// MigrateEnv turns an old .env file into the configuration file at path
+// Nothing happens if the configuration file already exists or there's no .env
+// The .env file is renamed to .env.migrated so it isn't read again
+// Returns whether a migration happened
+func MigrateEnv(envPath, path string) (bool, error) {
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	}
+	env, err := godotenv.Read(envPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", envPath, err)
+	}
+
+	f := Defaults()
+	lookup := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+	if err := ApplyEnv(&f, lookup); err != nil {
+		return false, fmt.Errorf("%s: %w", envPath, err)
+	}
+	if err := f.Validate(); err != nil {
+		return false, fmt.Errorf("%s: %w", envPath, err)
+	}
+	if err := Save(path, f); err != nil {
+		return false, err
+	}
+	return true, os.Rename(envPath, envPath+".migrated")
+}
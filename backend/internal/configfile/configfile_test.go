@@ -0,0 +1,250 @@
+package configfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFile writes content to name in dir and returns the path
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// ============================================================================
+// Load Tests
+// ============================================================================
+
+func TestDefaultsAreValid(t *testing.T) {
+	if err := Defaults().Validate(); err != nil {
+		t.Errorf("Expected valid defaults, got %v", err)
+	}
+}
+
+func TestLoadFillsDefaults(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "songmartyn.toml", `
+[server]
+https_port = 9443
+admin_pin = "1234"
+
+[bgm]
+enabled = true
+url = "https://example.com/stream"
+`)
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if f.Server.HTTPSPort != 9443 || f.Server.AdminPIN != "1234" || !f.BGM.Enabled {
+		t.Errorf("Expected the file's values, got %+v %+v", f.Server, f.BGM)
+	}
+	if f.Server.HTTPPort != 8080 || f.BGM.Volume != 50 || !f.TLS.LocalCA {
+		t.Errorf("Expected defaults for missing keys, got %+v %+v", f.Server, f.BGM)
+	}
+}
+
+func TestLoadErrorsNameTheKey(t *testing.T) {
+	tests := []struct {
+		name, content, key string
+	}{
+		{"unknown key", "[server]\nhttps_prot = 9443\n", "server.https_prot"},
+		{"wrong type", "[server]\nhttps_port = \"9443\"\n", "server.https_port"},
+		{"out of range", "[bgm]\nvolume = 150\n", "bgm.volume"},
+		{"bad choice", "[player]\nbackend = \"vlc\"\n", "player.backend"},
+	}
+	for _, tt := range tests {
+		path := writeFile(t, t.TempDir(), "songmartyn.toml", tt.content)
+		_, err := Load(path)
+		if err == nil || !strings.Contains(err.Error(), tt.key) {
+			t.Errorf("%s: expected an error naming %s, got %v", tt.name, tt.key, err)
+		}
+	}
+
+	var keyErr *KeyError
+	_, err := Load(writeFile(t, t.TempDir(), "songmartyn.toml", "[loudness]\ntarget_lufs = 0\n"))
+	if !errors.As(err, &keyErr) || keyErr.Key != "loudness.target_lufs" {
+		t.Errorf("Expected a KeyError for loudness.target_lufs, got %v", err)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.toml")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected ErrNotExist for a missing file, got %v", err)
+	}
+}
+
+func TestExampleFileIsValid(t *testing.T) {
+	f, err := Load("../../songmartyn.example.toml")
+	if err != nil {
+		t.Fatalf("Expected the example file to load: %v", err)
+	}
+	if !reflect.DeepEqual(f, Defaults()) {
+		t.Errorf("Expected the example file to show the defaults, got %+v", f)
+	}
+}
+
+func TestSaveRoundTrips(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "songmartyn.toml")
+	f := Defaults()
+	f.Holding.Message = "Happy birthday \"Sam\"!"
+	f.BGM.Source = "icecast"
+	f.Transitions.SongFadeOut = 2.5
+	f.Rooms = []Room{NewRoom("studio-b", "Studio B")}
+	f.Rooms[0].AdminPIN = "4321"
+	f.Rooms[0].BGM.Enabled = true
+	if err := Save(path, f); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, f) {
+		t.Errorf("Expected %+v, got %+v", f, loaded)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the file to be private, got %v", info.Mode().Perm())
+	}
+}
+
+// ============================================================================
+// Room Tests
+// ============================================================================
+
+func TestLoadRoomsFillDefaults(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "songmartyn.toml", `
+[bgm]
+volume = 20
+
+[[rooms]]
+id = "studio-b"
+name = "Studio B"
+admin_pin = "4321"
+
+[rooms.player]
+target_display = "1"
+
+[[rooms]]
+id = "vip"
+name = "VIP Lounge"
+
+[rooms.bgm]
+enabled = true
+`)
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(f.Rooms) != 2 {
+		t.Fatalf("Expected 2 rooms, got %+v", f.Rooms)
+	}
+	b, vip := f.Rooms[0], f.Rooms[1]
+	if b.ID != "studio-b" || b.AdminPIN != "4321" || b.Player.TargetDisplay != "1" {
+		t.Errorf("Expected the file's values, got %+v", b)
+	}
+	if b.Player.VideoPlayer != "mpv" || !b.Player.AutoFullscreen || b.Holding.Theme != "classic" {
+		t.Errorf("Expected defaults for missing keys, got %+v", b)
+	}
+	// Rooms start from the defaults, not from the main room's settings
+	if !vip.BGM.Enabled || vip.BGM.Volume != 50 || vip.BGM.Source != "youtube" {
+		t.Errorf("Expected the room's own BGM settings, got %+v", vip.BGM)
+	}
+}
+
+func TestValidateRooms(t *testing.T) {
+	tests := []struct {
+		name string
+		room func(r *Room)
+		key  string
+	}{
+		{"bad id", func(r *Room) { r.ID = "Studio B" }, "rooms[1].id"},
+		{"reserved id", func(r *Room) { r.ID = "main" }, "rooms[1].id"},
+		{"duplicate id", func(r *Room) { r.ID = "studio-b" }, "rooms[1].id"},
+		{"no name", func(r *Room) { r.Name = "" }, "rooms[1].name"},
+		{"bad volume", func(r *Room) { r.BGM.Volume = -1 }, "rooms[1].bgm.volume"},
+	}
+	for _, tt := range tests {
+		f := Defaults()
+		f.Rooms = []Room{NewRoom("studio-b", "Studio B"), NewRoom("vip", "VIP Lounge")}
+		tt.room(&f.Rooms[1])
+		if err := f.Validate(); err == nil || !strings.Contains(err.Error(), tt.key) {
+			t.Errorf("%s: expected an error naming %s, got %v", tt.name, tt.key, err)
+		}
+	}
+}
+
+// ============================================================================
+// Environment Tests
+// ============================================================================
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{"HTTPS_PORT": "9443", "BGM_ENABLED": "yes", "BGM_VOLUME": "30", "ADMIN_PIN": ""}
+	lookup := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	f := Defaults()
+	f.Server.AdminPIN = "1234"
+	if err := ApplyEnv(&f, lookup); err != nil {
+		t.Fatalf("ApplyEnv failed: %v", err)
+	}
+	if f.Server.HTTPSPort != 9443 || !f.BGM.Enabled || f.BGM.Volume != 30 {
+		t.Errorf("Expected the environment's values, got %+v %+v", f.Server, f.BGM)
+	}
+	if f.Server.AdminPIN != "1234" {
+		t.Error("Expected empty variables to be ignored")
+	}
+	if got := EnvOverrides(lookup); len(got) != 3 {
+		t.Errorf("Expected 3 overrides, got %v", got)
+	}
+
+	env["HTTP_PORT"] = "eighty"
+	if err := ApplyEnv(&f, lookup); err == nil || !strings.Contains(err.Error(), "server.http_port") {
+		t.Errorf("Expected an error naming server.http_port, got %v", err)
+	}
+}
+
+func TestMigrateEnv(t *testing.T) {
+	dir := t.TempDir()
+	envPath := writeFile(t, dir, ".env", `# SongMartyn Configuration
+HTTPS_PORT=9443
+ADMIN_PIN=4321
+FAIR_ROTATION_ENABLED=true
+HOLDING_MESSAGE=Welcome to karaoke night
+HOLDING_THEME=spotlight
+BGM_SOURCE=icecast
+BGM_URL=https://example.com/stream
+`)
+	path := filepath.Join(dir, "songmartyn.toml")
+
+	migrated, err := MigrateEnv(envPath, path)
+	if err != nil || !migrated {
+		t.Fatalf("Expected a migration, got %v, %v", migrated, err)
+	}
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if f.Server.HTTPSPort != 9443 || f.Server.AdminPIN != "4321" || !f.Features.FairRotation {
+		t.Errorf("Expected the .env server settings, got %+v %+v", f.Server, f.Features)
+	}
+	if f.Holding.Message != "Welcome to karaoke night" || f.Holding.Theme != "spotlight" || f.BGM.Source != "icecast" {
+		t.Errorf("Expected the .env runtime settings, got %+v %+v", f.Holding, f.BGM)
+	}
+	if _, err := os.Stat(envPath + ".migrated"); err != nil {
+		t.Errorf("Expected .env to be set aside: %v", err)
+	}
+
+	// Only ever once
+	writeFile(t, dir, ".env", "HTTPS_PORT=7443\n")
+	if migrated, _ := MigrateEnv(envPath, path); migrated {
+		t.Error("Expected no migration over an existing configuration file")
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
 	"image/jpeg"
 	"image/png"
 	"io"
@@ -17,9 +16,9 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/fogleman/gg"
-	"github.com/nfnt/resize"
 	"songmartyn/internal/avatar"
 	"songmartyn/pkg/models"
 )
@@ -46,6 +45,9 @@ var insecureClient = &http.Client{
 //go:embed assets/logo.jpeg
 var logoFS embed.FS
 
+//go:embed assets/themes/*.json
+var themeFS embed.FS
+
 const (
 	canvasWidth  = 1920
 	canvasHeight = 1080
@@ -64,9 +66,15 @@ type Generator struct {
 	logoImage    image.Image
 	tempDir      string
 	avatarAPIURL string
+	fonts        *fontCache
+	qrCode       func(data string, size int) (image.Image, error)
+
+	mu          sync.Mutex             // Serializes rendering and guards the caches
+	theme       *Theme                 // Active theme
+	backgrounds map[string]image.Image // Background images already scaled to the canvas
 }
 
-// NewGenerator creates a new holding screen generator
+// NewGenerator creates a new holding screen generator using the classic theme
 func NewGenerator(tempDir, avatarAPIURL string) (*Generator, error) {
 	// Load embedded logo
 	logoData, err := logoFS.ReadFile("assets/logo.jpeg")
@@ -88,6 +96,10 @@ func NewGenerator(tempDir, avatarAPIURL string) (*Generator, error) {
 		logoImage:    logoImg,
 		tempDir:      tempDir,
 		avatarAPIURL: avatarAPIURL,
+		fonts:        newFontCache(systemFontPaths),
+		qrCode:       fetchQRCode,
+		theme:        DefaultTheme(),
+		backgrounds:  make(map[string]image.Image),
 	}, nil
 }
 
@@ -99,30 +111,41 @@ type NextUpInfo struct {
 	AvatarConfig *models.AvatarConfig
 }
 
-// Generate creates a holding screen image and returns the file path
-func (g *Generator) Generate(connectURL string, nextUp *NextUpInfo, message string) (string, error) {
-	// Create canvas
-	dc := gg.NewContext(canvasWidth, canvasHeight)
-
-	// Draw logo as full background (cover the canvas)
-	g.drawBackground(dc)
+// SetTheme switches the theme used for new holding screens
+// Cached images and fonts are dropped in case a replaced theme changed its files
+func (g *Generator) SetTheme(theme *Theme) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.theme = theme
+	g.backgrounds = make(map[string]image.Image)
+	g.fonts = newFontCache(g.fonts.systemPaths)
+}
 
-	// Draw semi-transparent overlay at bottom for content area
-	g.drawBottomOverlay(dc)
+// Theme returns the active theme
+func (g *Generator) Theme() *Theme {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.theme
+}
 
-	// Draw admin message banner if set
-	if message != "" {
-		g.drawMessageBanner(dc, message)
-	}
+// Generate renders the holding screen with the active theme and returns the file path
+func (g *Generator) Generate(content Content) (string, error) {
+	return g.save(content, false, "holding-screen.png")
+}
 
-	// Draw QR code section (bottom left)
-	g.drawQRSection(dc, connectURL)
+// GenerateOverlay renders the theme's widgets over a transparent background,
+// to be shown on top of the theme's background video
+func (g *Generator) GenerateOverlay(content Content) (string, error) {
+	return g.save(content, true, "holding-screen-overlay.png")
+}
 
-	// Draw "Next Up" section (bottom right) - always show, with placeholder if no song
-	g.drawNextUpSection(dc, nextUp)
+// save renders the active theme to a file in the temp dir
+func (g *Generator) save(content Content, overlay bool, name string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 
-	// Save to temp file
-	outputPath := filepath.Join(g.tempDir, "holding-screen.png")
+	dc := g.render(g.theme, content, overlay)
+	outputPath := filepath.Join(g.tempDir, name)
 	if err := dc.SavePNG(outputPath); err != nil {
 		return "", fmt.Errorf("failed to save holding screen: %w", err)
 	}
@@ -130,51 +153,62 @@ func (g *Generator) Generate(connectURL string, nextUp *NextUpInfo, message stri
 	return outputPath, nil
 }
 
-// drawBackground draws the logo scaled to cover the entire canvas
-func (g *Generator) drawBackground(dc *gg.Context) {
-	bounds := g.logoImage.Bounds()
-	srcW := float64(bounds.Dx())
-	srcH := float64(bounds.Dy())
-
-	// Calculate scale to cover (fill) the canvas while maintaining aspect ratio
-	scaleX := float64(canvasWidth) / srcW
-	scaleY := float64(canvasHeight) / srcH
-	scale := scaleX
-	if scaleY > scaleX {
-		scale = scaleY
+// GenerateSinger creates the holding screen for a singer-facing confidence monitor
+// It leaves out the QR code and shows who is up next in large type
+func (g *Generator) GenerateSinger(nextUp *NextUpInfo, message string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	dc := gg.NewContext(canvasWidth, canvasHeight)
+
+	// Dimmed logo background keeps the text readable from the stage
+	g.drawBackground(dc)
+	dc.SetRGBA(0, 0, 0, 0.75)
+	dc.DrawRectangle(0, 0, canvasWidth, canvasHeight)
+	dc.Fill()
+
+	if message != "" {
+		g.drawMessageBanner(dc, message)
 	}
 
-	// Calculate new dimensions
-	newW := uint(srcW * scale)
-	newH := uint(srcH * scale)
+	centerX := float64(canvasWidth) / 2
+	centerY := float64(canvasHeight) / 2
 
-	// Resize the image
-	resized := resize.Resize(newW, newH, g.logoImage, resize.Lanczos3)
+	dc.SetColor(yellowColor)
+	if err := loadFont(dc, 48); err == nil {
+		dc.DrawStringAnchored("NEXT UP", centerX, centerY-160, 0.5, 0.5)
+	}
 
-	// Calculate offset to center the image
-	offsetX := (int(newW) - canvasWidth) / 2
-	offsetY := (int(newH) - canvasHeight) / 2
+	if nextUp != nil && nextUp.SongTitle != "" {
+		dc.SetColor(cyanColor)
+		if err := loadFont(dc, 96); err == nil {
+			dc.DrawStringAnchored(truncateString(stripEmoji(nextUp.SingerName), 24), centerX, centerY-40, 0.5, 0.5)
+		}
+		dc.SetColor(whiteColor)
+		if err := loadFont(dc, 64); err == nil {
+			dc.DrawStringAnchored(truncateString(nextUp.SongTitle, 40), centerX, centerY+80, 0.5, 0.5)
+		}
+		dc.SetColor(grayColor)
+		if err := loadFont(dc, 44); err == nil {
+			dc.DrawStringAnchored(truncateString(nextUp.SongArtist, 50), centerX, centerY+160, 0.5, 0.5)
+		}
+	} else {
+		dc.SetColor(grayColor)
+		if err := loadFont(dc, 64); err == nil {
+			dc.DrawStringAnchored("Waiting for songs...", centerX, centerY, 0.5, 0.5)
+		}
+	}
 
-	// Create a sub-image that fits the canvas
-	subImg := image.NewRGBA(image.Rect(0, 0, canvasWidth, canvasHeight))
-	draw.Draw(subImg, subImg.Bounds(), resized, image.Point{offsetX, offsetY}, draw.Src)
+	outputPath := filepath.Join(g.tempDir, "holding-screen-singer.png")
+	if err := dc.SavePNG(outputPath); err != nil {
+		return "", fmt.Errorf("failed to save singer holding screen: %w", err)
+	}
 
-	dc.DrawImage(subImg, 0, 0)
+	return outputPath, nil
 }
 
-// drawBottomOverlay draws a semi-transparent black gradient at the bottom
-func (g *Generator) drawBottomOverlay(dc *gg.Context) {
-	// Draw gradient from transparent to semi-opaque black at the bottom
-	overlayHeight := 350.0
-	startY := float64(canvasHeight) - overlayHeight
-
-	for y := 0; y < int(overlayHeight); y++ {
-		// Gradient from 0 alpha at top to 200 alpha at bottom
-		alpha := float64(y) / overlayHeight * 0.85
-		dc.SetRGBA(0, 0, 0, alpha)
-		dc.DrawRectangle(0, startY+float64(y), canvasWidth, 1)
-		dc.Fill()
-	}
+// drawBackground draws the logo scaled to cover the entire canvas
+func (g *Generator) drawBackground(dc *gg.Context) {
+	dc.DrawImage(g.coverImage(nil, LogoImage), 0, 0)
 }
 
 // drawMessageBanner draws an admin message banner at the top of the screen
@@ -212,139 +246,6 @@ func (g *Generator) drawMessageBanner(dc *gg.Context, message string) {
 	}
 }
 
-// drawQRSection draws the QR code and connection info on bottom left
-func (g *Generator) drawQRSection(dc *gg.Context, connectURL string) {
-	qrSize := 240 // Large for room visibility
-	padding := 50.0
-	qrX := padding
-	qrY := float64(canvasHeight) - float64(qrSize) - padding - 30
-
-	// Draw semi-transparent background box for QR section
-	boxPadding := 20.0
-	dc.SetRGBA(0, 0, 0, 0.6)
-	dc.DrawRoundedRectangle(qrX-boxPadding, qrY-boxPadding-40, float64(qrSize)+boxPadding*2+320, float64(qrSize)+boxPadding*2+50, 16)
-	dc.Fill()
-
-	// Fetch QR code
-	qrURL := fmt.Sprintf("https://api.qrserver.com/v1/create-qr-code/?size=%dx%d&data=%s&bgcolor=ffffff&color=000000",
-		qrSize, qrSize, url.QueryEscape(connectURL))
-
-	qrImg, err := fetchImage(qrURL)
-	if err == nil && qrImg != nil {
-		dc.DrawImage(qrImg, int(qrX), int(qrY))
-	} else {
-		// Fallback placeholder
-		dc.SetRGBA(1, 1, 1, 0.3)
-		dc.DrawRoundedRectangle(qrX, qrY, float64(qrSize), float64(qrSize), 8)
-		dc.Fill()
-	}
-
-	// Draw text to the right of QR
-	textX := qrX + float64(qrSize) + 30
-	textY := qrY + 50
-
-	// "Scan to join!" in yellow
-	dc.SetColor(yellowColor)
-	if err := loadFont(dc, 42); err == nil {
-		dc.DrawString("Scan to join!", textX, textY)
-	}
-
-	// URL in white
-	dc.SetColor(whiteColor)
-	if err := loadFont(dc, 32); err == nil {
-		dc.DrawString(connectURL, textX, textY+55)
-	}
-
-	// Subtitle
-	dc.SetColor(grayColor)
-	if err := loadFont(dc, 24); err == nil {
-		dc.DrawString("Join the karaoke session", textX, textY+100)
-	}
-}
-
-// drawNextUpSection draws the "Next Up" info box on bottom right
-func (g *Generator) drawNextUpSection(dc *gg.Context, nextUp *NextUpInfo) {
-	boxWidth := 900.0  // ~50% of screen width
-	boxHeight := 200.0 // Larger for room visibility
-	padding := 50.0
-	boxX := float64(canvasWidth) - boxWidth - padding
-	boxY := float64(canvasHeight) - boxHeight - padding - 30
-	innerPadding := 25.0
-	avatarSize := 150.0 // Larger avatar
-
-	// Draw semi-transparent background
-	dc.SetRGBA(0, 0, 0, 0.6)
-	dc.DrawRoundedRectangle(boxX, boxY, boxWidth, boxHeight, 16)
-	dc.Fill()
-
-	// Draw accent line on left
-	dc.SetColor(yellowColor)
-	dc.DrawRoundedRectangle(boxX, boxY, 6, boxHeight, 3)
-	dc.Fill()
-
-	// Draw "NEXT UP" label
-	dc.SetColor(yellowColor)
-	if err := loadFont(dc, 22); err == nil {
-		dc.DrawString("NEXT UP", boxX+innerPadding+avatarSize+25, boxY+innerPadding+20)
-	}
-
-	// Avatar area
-	avatarX := boxX + innerPadding
-	avatarY := boxY + (boxHeight-avatarSize)/2
-
-	if nextUp != nil && nextUp.AvatarConfig != nil {
-		// Fetch and draw avatar
-		avatarImg := g.generateAvatar(nextUp.AvatarConfig)
-		if avatarImg != nil {
-			bounds := avatarImg.Bounds()
-			scale := avatarSize / float64(bounds.Dx())
-			dc.Push()
-			dc.Translate(avatarX, avatarY)
-			dc.Scale(scale, scale)
-			dc.DrawImage(avatarImg, 0, 0)
-			dc.Pop()
-		} else {
-			g.drawPlaceholderAvatar(dc, avatarX, avatarY, avatarSize)
-		}
-	} else {
-		g.drawPlaceholderAvatar(dc, avatarX, avatarY, avatarSize)
-	}
-
-	// Text area
-	textX := boxX + innerPadding + avatarSize + 25
-
-	if nextUp != nil && nextUp.SongTitle != "" {
-		// Song title - large and readable
-		dc.SetColor(whiteColor)
-		if err := loadFont(dc, 36); err == nil {
-			title := truncateString(nextUp.SongTitle, 35)
-			dc.DrawString(title, textX, boxY+innerPadding+65)
-		}
-
-		// Artist
-		dc.SetColor(grayColor)
-		if err := loadFont(dc, 28); err == nil {
-			dc.DrawString(nextUp.SongArtist, textX, boxY+innerPadding+105)
-		}
-
-		// Singer name
-		dc.SetColor(cyanColor)
-		if err := loadFont(dc, 24); err == nil {
-			dc.DrawString(nextUp.SingerName, textX, boxY+innerPadding+145)
-		}
-	} else {
-		// Placeholder text - large and readable
-		dc.SetColor(grayColor)
-		if err := loadFont(dc, 32); err == nil {
-			dc.DrawString("Waiting for songs...", textX, boxY+innerPadding+70)
-		}
-		dc.SetColor(color.RGBA{100, 100, 100, 255})
-		if err := loadFont(dc, 24); err == nil {
-			dc.DrawString("Scan QR code to add a song!", textX, boxY+innerPadding+115)
-		}
-	}
-}
-
 // drawPlaceholderAvatar draws a placeholder avatar circle
 func (g *Generator) drawPlaceholderAvatar(dc *gg.Context, x, y, size float64) {
 	// Background circle
@@ -401,6 +302,13 @@ func (g *Generator) generateAvatar(config *models.AvatarConfig) image.Image {
 	return img
 }
 
+// fetchQRCode fetches a QR code image for data from the QR code API
+func fetchQRCode(data string, size int) (image.Image, error) {
+	qrURL := fmt.Sprintf("https://api.qrserver.com/v1/create-qr-code/?size=%dx%d&data=%s&bgcolor=ffffff&color=000000",
+		size, size, url.QueryEscape(data))
+	return fetchImage(qrURL)
+}
+
 // fetchImage fetches an image from a URL
 func fetchImage(imageURL string) (image.Image, error) {
 	var resp *http.Response
@@ -0,0 +1,610 @@
+package holdingscreen
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fogleman/gg"
+	"github.com/golang/freetype/truetype"
+	"github.com/nfnt/resize"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Content is what the holding screen shows
+type Content struct {
+	ConnectURL string
+	JoinURL    string // What the QR code opens, when it differs from ConnectURL
+	NextUp     *NextUpInfo
+	Queue      []NextUpInfo // Songs after the next one
+	Message    string
+	TopSingers []SingerStat
+	Now        time.Time
+}
+
+// SingerStat is one row of the top singers widget
+type SingerStat struct {
+	Name  string
+	Songs int
+}
+
+// systemFontPaths are tried in order for the "sans" font
+var systemFontPaths = []string{
+	"/System/Library/Fonts/SFNS.ttf",
+	"/System/Library/Fonts/SFNSRounded.ttf",
+	"/Library/Fonts/Arial.ttf",
+	"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/truetype/liberation/LiberationSans-Regular.ttf",
+	"C:\\Windows\\Fonts\\arial.ttf",
+}
+
+// builtinFonts are compiled in so every theme renders the same everywhere
+var builtinFonts = map[string][]byte{
+	FontGo:     goregular.TTF,
+	FontGoBold: gobold.TTF,
+	FontGoMono: gomono.TTF,
+}
+
+// builtinFont reports whether name is a font that needs no file
+func builtinFont(name string) bool {
+	return name == FontSans || builtinFonts[name] != nil
+}
+
+// fontCache parses each font once
+type fontCache struct {
+	mu          sync.Mutex
+	systemPaths []string
+	fonts       map[string]*truetype.Font // Keyed by built-in name or file path
+}
+
+func newFontCache(systemPaths []string) *fontCache {
+	return &fontCache{systemPaths: systemPaths, fonts: make(map[string]*truetype.Font)}
+}
+
+// get returns a built-in font or parses a font file, falling back to Go Regular
+func (c *fontCache) get(source string) *truetype.Font {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if f, ok := c.fonts[source]; ok {
+		return f
+	}
+
+	var f *truetype.Font
+	switch {
+	case source == FontSans:
+		for _, path := range c.systemPaths {
+			if f = parseFontFile(path); f != nil {
+				break
+			}
+		}
+	case builtinFonts[source] != nil:
+		f, _ = truetype.Parse(builtinFonts[source])
+	default:
+		f = parseFontFile(source)
+	}
+	if f == nil {
+		f, _ = truetype.Parse(goregular.TTF)
+	}
+	c.fonts[source] = f
+	return f
+}
+
+// parseFontFile reads a TTF file, returning nil if it can't be used
+func parseFontFile(path string) *truetype.Font {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	f, err := truetype.Parse(data)
+	if err != nil {
+		return nil
+	}
+	return f
+}
+
+// renderer draws one theme onto a canvas
+type renderer struct {
+	g       *Generator
+	theme   *Theme
+	dc      *gg.Context
+	content Content
+}
+
+// render draws the theme; overlay leaves the background transparent for a video underneath
+func (g *Generator) render(theme *Theme, content Content, overlay bool) *gg.Context {
+	if content.Now.IsZero() {
+		content.Now = time.Now()
+	}
+	r := &renderer{g: g, theme: theme, dc: gg.NewContext(canvasWidth, canvasHeight), content: content}
+	r.drawBackground(overlay)
+	for _, w := range theme.Widgets {
+		r.drawWidget(w)
+	}
+	return r.dc
+}
+
+// drawBackground fills the canvas with the theme's color and image, then dims it
+func (r *renderer) drawBackground(overlay bool) {
+	bg := r.theme.Background
+	if !overlay {
+		r.dc.SetColor(r.color(bg.Color, "#000000"))
+		r.dc.Clear()
+		if bg.Image != "" {
+			if img := r.g.coverImage(r.theme, bg.Image); img != nil {
+				r.dc.DrawImage(img, 0, 0)
+			}
+		}
+	}
+	if bg.Dim > 0 {
+		r.dc.SetRGBA(0, 0, 0, bg.Dim)
+		r.dc.DrawRectangle(0, 0, canvasWidth, canvasHeight)
+		r.dc.Fill()
+	}
+}
+
+// coverImage loads a background image scaled to cover the canvas, caching the result
+func (g *Generator) coverImage(theme *Theme, name string) image.Image {
+	key := name
+	src := g.logoImage
+	if name != LogoImage {
+		path, err := theme.file(name)
+		if err != nil {
+			return nil
+		}
+		key = path
+		if img, ok := g.backgrounds[key]; ok {
+			return img
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		if src, _, err = image.Decode(f); err != nil {
+			return nil
+		}
+	} else if img, ok := g.backgrounds[key]; ok {
+		return img
+	}
+
+	img := cover(src)
+	g.backgrounds[key] = img
+	return img
+}
+
+// cover scales an image to fill the canvas while keeping its aspect ratio, cropping the overflow
+func cover(src image.Image) image.Image {
+	bounds := src.Bounds()
+	srcW := float64(bounds.Dx())
+	srcH := float64(bounds.Dy())
+
+	scale := math.Max(float64(canvasWidth)/srcW, float64(canvasHeight)/srcH)
+	newW := uint(math.Ceil(srcW * scale))
+	newH := uint(math.Ceil(srcH * scale))
+	resized := resize.Resize(newW, newH, src, resize.Lanczos3)
+
+	// Center the crop
+	offsetX := (int(newW) - canvasWidth) / 2
+	offsetY := (int(newH) - canvasHeight) / 2
+	dst := image.NewRGBA(image.Rect(0, 0, canvasWidth, canvasHeight))
+	draw.Draw(dst, dst.Bounds(), resized, image.Point{offsetX, offsetY}, draw.Src)
+	return dst
+}
+
+// drawWidget draws one widget
+func (r *renderer) drawWidget(w Widget) {
+	switch w.Type {
+	case WidgetGradient:
+		r.drawGradient(w)
+	case WidgetPanel:
+		r.drawPanel(w, "panel")
+	case WidgetText:
+		r.drawValue(w, w.Title, w.Text)
+	case WidgetMessage:
+		r.drawMessage(w)
+	case WidgetQR:
+		r.drawQR(w)
+	case WidgetNextUp:
+		r.drawNextUp(w)
+	case WidgetQueue:
+		r.drawQueue(w)
+	case WidgetClock:
+		format := w.Format
+		if format == "" {
+			format = "15:04"
+		}
+		r.drawValue(w, w.Title, r.content.Now.Format(format))
+	case WidgetTopSingers:
+		r.drawTopSingers(w)
+	case WidgetCountdown:
+		r.drawCountdown(w)
+	}
+}
+
+// color resolves a theme color, falling back to white if it can't be parsed
+func (r *renderer) color(value, fallback string) color.Color {
+	c, err := r.theme.color(value, fallback)
+	if err != nil {
+		return color.White
+	}
+	return c
+}
+
+// setFont selects a theme font ("body" by default) at size pixels
+func (r *renderer) setFont(name string, size float64) {
+	if name == "" {
+		name = "body"
+	}
+	source, ok := r.theme.Fonts[name]
+	if !ok {
+		source = name
+		if name == "body" || name == "heading" {
+			source = FontSans
+		}
+	}
+	if !builtinFont(source) {
+		if path, err := r.theme.file(source); err == nil {
+			source = path
+		}
+	}
+	f := r.g.fonts.get(source)
+	r.dc.SetFontFace(truetype.NewFace(f, &truetype.Options{Size: size}))
+}
+
+// fit shortens s with an ellipsis until it fits in maxWidth
+func (r *renderer) fit(s string, maxWidth float64) string {
+	if w, _ := r.dc.MeasureString(s); w <= maxWidth {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		t := strings.TrimSpace(string(runes)) + "..."
+		if w, _ := r.dc.MeasureString(t); w <= maxWidth {
+			return t
+		}
+	}
+	return ""
+}
+
+// text draws s in the widget's alignment across [x, x+width], vertically centered on y
+func (r *renderer) text(s string, align string, x, width, y float64) {
+	s = r.fit(stripEmoji(s), width)
+	switch align {
+	case "center":
+		r.dc.DrawStringAnchored(s, x+width/2, y, 0.5, 0.5)
+	case "right":
+		r.dc.DrawStringAnchored(s, x+width, y, 1, 0.5)
+	default:
+		r.dc.DrawStringAnchored(s, x, y, 0, 0.5)
+	}
+}
+
+// drawPanel draws the widget's box, or the fallback color when the widget doesn't set one
+func (r *renderer) drawPanel(w Widget, fallback string) {
+	if w.Panel == "" && fallback == "" {
+		return
+	}
+	reg := w.Region
+	r.dc.SetColor(r.color(w.Panel, fallback))
+	r.dc.DrawRoundedRectangle(reg.X, reg.Y, reg.W, reg.H, math.Min(16, reg.H/4))
+	r.dc.Fill()
+}
+
+// drawGradient washes from transparent at the top of the region to the panel color at the bottom
+func (r *renderer) drawGradient(w Widget) {
+	reg := w.Region
+	opacity := w.Opacity
+	if opacity == 0 {
+		opacity = 0.85
+	}
+	cr, cg, cb, _ := r.color(w.Panel, "#000000").RGBA()
+	for y := 0; y < int(reg.H); y++ {
+		r.dc.SetRGBA(float64(cr)/0xffff, float64(cg)/0xffff, float64(cb)/0xffff, float64(y)/reg.H*opacity)
+		r.dc.DrawRectangle(reg.X, reg.Y+float64(y), reg.W, 1)
+		r.dc.Fill()
+	}
+}
+
+// drawValue draws a single large value, with an optional title above it
+func (r *renderer) drawValue(w Widget, title, value string) {
+	reg := w.Region
+	r.drawPanel(w, "")
+	size := w.Size
+	valueY := reg.Y + reg.H/2
+	if title != "" {
+		if size == 0 {
+			size = reg.H * 0.45
+		}
+		titleSize := size * 0.4
+		r.setFont("heading", titleSize)
+		r.dc.SetColor(r.color(w.Accent, "accent"))
+		r.text(title, w.Align, reg.X+16, reg.W-32, reg.Y+reg.H*0.25)
+		valueY = reg.Y + reg.H*0.62
+	} else if size == 0 {
+		size = reg.H * 0.6
+	}
+	r.setFont(w.Font, size)
+	r.dc.SetColor(r.color(w.Color, "text"))
+	r.text(value, w.Align, reg.X+16, reg.W-32, valueY)
+}
+
+// drawMessage draws the admin message banner with an optional accent line along its bottom
+func (r *renderer) drawMessage(w Widget) {
+	message := stripEmoji(r.content.Message)
+	if message == "" {
+		return
+	}
+	reg := w.Region
+	r.drawPanel(w, "")
+	if w.Accent != "" {
+		r.dc.SetColor(r.color(w.Accent, "accent"))
+		r.dc.DrawRectangle(reg.X, reg.Y+reg.H-4, reg.W, 4)
+		r.dc.Fill()
+	}
+
+	size := w.Size
+	if size == 0 {
+		size = 36
+	}
+	align := w.Align
+	if align == "" {
+		align = "center"
+	}
+	r.setFont(w.Font, size)
+	r.dc.SetColor(r.color(w.Color, "text"))
+	r.text(message, align, reg.X+30, reg.W-60, reg.Y+reg.H/2)
+}
+
+// drawQR draws the join QR code with the connect URL beside it when there's room
+func (r *renderer) drawQR(w Widget) {
+	reg := w.Region
+	r.drawPanel(w, "")
+	pad := 20.0
+	size := math.Min(reg.H, reg.W) - pad*2
+	showText := reg.W-size-pad*2 >= 200
+	qrX := reg.X + pad
+	if !showText {
+		qrX = reg.X + (reg.W-size)/2
+	}
+	qrY := reg.Y + (reg.H-size)/2
+
+	target := r.content.ConnectURL
+	if r.content.JoinURL != "" {
+		target = r.content.JoinURL
+	}
+	qr, err := r.g.qrCode(target, int(size))
+	if err == nil && qr != nil {
+		if qr.Bounds().Dx() != int(size) {
+			qr = resize.Resize(uint(size), uint(size), qr, resize.NearestNeighbor)
+		}
+		r.dc.DrawImage(qr, int(qrX), int(qrY))
+	} else {
+		// Fallback placeholder
+		r.dc.SetRGBA(1, 1, 1, 0.3)
+		r.dc.DrawRoundedRectangle(qrX, qrY, size, size, 8)
+		r.dc.Fill()
+	}
+	if !showText {
+		return
+	}
+
+	title := w.Title
+	if title == "" {
+		title = "Scan to join!"
+	}
+	subtitle := w.Text
+	if subtitle == "" {
+		subtitle = "Join the karaoke session"
+	}
+	scale := size / 240
+	if w.Size > 0 {
+		scale = w.Size / 42
+	}
+	textX := qrX + size + 30
+	textW := reg.X + reg.W - textX - pad
+	lines := []struct {
+		text, font, color string
+		size, y           float64
+	}{
+		{title, "heading", r.pick(w.Accent, "accent"), 42, 35},
+		{r.content.ConnectURL, w.Font, r.pick(w.Color, "text"), 32, 95},
+		{subtitle, w.Font, "muted", 24, 140},
+	}
+	for _, line := range lines {
+		r.setFont(line.font, line.size*scale)
+		r.dc.SetColor(r.color(line.color, ""))
+		r.text(line.text, "left", textX, textW, qrY+line.y*scale)
+	}
+}
+
+// pick returns value, or fallback when it's empty
+func (r *renderer) pick(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// drawNextUp draws the next song and singer with an avatar and a left accent bar
+func (r *renderer) drawNextUp(w Widget) {
+	reg := w.Region
+	r.drawPanel(w, "")
+	accent := r.color(w.Accent, "accent")
+	r.dc.SetColor(accent)
+	r.dc.DrawRoundedRectangle(reg.X, reg.Y, 6, reg.H, 3)
+	r.dc.Fill()
+
+	scale := reg.H / 200
+	inner := 25 * scale
+	avatarSize := reg.H - 50*scale
+	avatarX := reg.X + inner
+	avatarY := reg.Y + (reg.H-avatarSize)/2
+	nextUp := r.content.NextUp
+	if nextUp != nil && nextUp.AvatarConfig != nil {
+		if img := r.g.generateAvatar(nextUp.AvatarConfig); img != nil {
+			s := avatarSize / float64(img.Bounds().Dx())
+			r.dc.Push()
+			r.dc.Translate(avatarX, avatarY)
+			r.dc.Scale(s, s)
+			r.dc.DrawImage(img, 0, 0)
+			r.dc.Pop()
+		} else {
+			r.g.drawPlaceholderAvatar(r.dc, avatarX, avatarY, avatarSize)
+		}
+	} else {
+		r.g.drawPlaceholderAvatar(r.dc, avatarX, avatarY, avatarSize)
+	}
+
+	textX := avatarX + avatarSize + 25*scale
+	textW := reg.X + reg.W - textX - inner
+	title := w.Title
+	if title == "" {
+		title = "NEXT UP"
+	}
+	r.setFont("heading", 22*scale)
+	r.dc.SetColor(accent)
+	r.text(title, "left", textX, textW, reg.Y+inner+14*scale)
+
+	type line struct {
+		text, font, color string
+		size, y           float64
+	}
+	var lines []line
+	if nextUp != nil && nextUp.SongTitle != "" {
+		lines = []line{
+			{nextUp.SongTitle, w.Font, r.pick(w.Color, "text"), 36, 53},
+			{nextUp.SongArtist, w.Font, "muted", 28, 95},
+			{nextUp.SingerName, w.Font, "primary", 24, 137},
+		}
+	} else {
+		lines = []line{
+			{"Waiting for songs...", w.Font, "muted", 32, 59},
+			{"Scan QR code to add a song!", w.Font, "faint", 24, 107},
+		}
+	}
+	for _, l := range lines {
+		r.setFont(l.font, l.size*scale)
+		r.dc.SetColor(r.color(l.color, ""))
+		r.text(l.text, "left", textX, textW, reg.Y+inner+l.y*scale)
+	}
+}
+
+// listRow is one row of a list widget: text on the left, a detail on the right
+type listRow struct {
+	left, right string
+}
+
+// drawList draws a titled list, as many rows as fit, or empty when there are none
+func (r *renderer) drawList(w Widget, title string, rows []listRow, empty string) {
+	reg := w.Region
+	r.drawPanel(w, "")
+	size := w.Size
+	if size == 0 {
+		size = 32
+	}
+	pad := 25.0
+	rowHeight := size * 1.5
+	x := reg.X + pad
+	width := reg.W - pad*2
+
+	titleSize := size * 0.75
+	r.setFont("heading", titleSize)
+	r.dc.SetColor(r.color(w.Accent, "accent"))
+	r.text(title, w.Align, x, width, reg.Y+pad+titleSize/2)
+	y := reg.Y + pad + titleSize + rowHeight/2 + 10
+
+	if len(rows) == 0 {
+		r.setFont(w.Font, size*0.85)
+		r.dc.SetColor(r.color("muted", ""))
+		r.text(empty, w.Align, x, width, y)
+		return
+	}
+
+	limit := w.Count
+	if limit == 0 {
+		limit = 5
+	}
+	for i, row := range rows {
+		if i >= limit || y+rowHeight/2 > reg.Y+reg.H {
+			break
+		}
+		detailW := 0.0
+		if row.right != "" {
+			r.setFont(w.Font, size*0.8)
+			r.dc.SetColor(r.color("primary", ""))
+			detail := r.fit(stripEmoji(row.right), width*0.4)
+			detailW, _ = r.dc.MeasureString(detail)
+			r.dc.DrawStringAnchored(detail, x+width, y, 1, 0.5)
+			detailW += 20
+		}
+		r.setFont(w.Font, size)
+		r.dc.SetColor(r.color(w.Color, "text"))
+		r.text(fmt.Sprintf("%d. %s", i+1, row.left), "left", x, width-detailW, y)
+		y += rowHeight
+	}
+}
+
+// drawQueue lists the songs coming up after the next one
+func (r *renderer) drawQueue(w Widget) {
+	rows := make([]listRow, 0, len(r.content.Queue))
+	for _, song := range r.content.Queue {
+		rows = append(rows, listRow{song.SongTitle, song.SingerName})
+	}
+	title := w.Title
+	if title == "" {
+		title = "COMING UP"
+	}
+	r.drawList(w, title, rows, "Nothing else queued yet")
+}
+
+// drawTopSingers lists tonight's busiest singers
+func (r *renderer) drawTopSingers(w Widget) {
+	rows := make([]listRow, 0, len(r.content.TopSingers))
+	for _, s := range r.content.TopSingers {
+		songs := fmt.Sprintf("%d songs", s.Songs)
+		if s.Songs == 1 {
+			songs = "1 song"
+		}
+		rows = append(rows, listRow{s.Name, songs})
+	}
+	title := w.Title
+	if title == "" {
+		title = "TOP SINGERS TONIGHT"
+	}
+	r.drawList(w, title, rows, "Be the first to sing!")
+}
+
+// drawCountdown shows the time left until the widget's target, then its text once reached
+func (r *renderer) drawCountdown(w Widget) {
+	target, err := countdownTarget(w.Target, r.content.Now)
+	if err != nil {
+		return
+	}
+	left := target.Sub(r.content.Now)
+	value := w.Text
+	if left > 0 {
+		value = formatRemaining(left)
+	}
+	if value == "" {
+		return
+	}
+	r.drawValue(w, w.Title, value)
+}
+
+// formatRemaining shows a countdown in whole minutes, rounding up
+func formatRemaining(d time.Duration) string {
+	minutes := int(math.Ceil(d.Minutes()))
+	if minutes < 60 {
+		return fmt.Sprintf("%d min", minutes)
+	}
+	return fmt.Sprintf("%dh %02dm", minutes/60, minutes%60)
+}
@@ -0,0 +1,285 @@
+package holdingscreen
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"songmartyn/internal/logging"
+)
+
+// logger is the "holding" subsystem
+var logger = logging.For("holding")
+
+// DefaultThemeID is the theme used until an admin picks another
+const DefaultThemeID = "classic"
+
+// ErrThemeNotFound is returned for an unknown theme ID
+var ErrThemeNotFound = errors.New("theme not found")
+
+// Upload limits
+const (
+	MaxThemeFiles = 100
+	MaxThemeBytes = 256 << 20 // Uncompressed; room for a background video
+)
+
+// builtinThemes are parsed once from the embedded theme files
+var builtinThemes = loadBuiltinThemes()
+
+func loadBuiltinThemes() map[string]*Theme {
+	entries, err := themeFS.ReadDir("assets/themes")
+	if err != nil {
+		panic(err)
+	}
+	themes := make(map[string]*Theme)
+	for _, entry := range entries {
+		data, err := themeFS.ReadFile("assets/themes/" + entry.Name())
+		if err != nil {
+			panic(err)
+		}
+		theme, err := ParseTheme(data, "")
+		if err != nil {
+			panic(fmt.Sprintf("built-in theme %s: %v", entry.Name(), err))
+		}
+		theme.ID = strings.TrimSuffix(entry.Name(), ".json")
+		theme.builtin = true
+		themes[theme.ID] = theme
+	}
+	return themes
+}
+
+// DefaultTheme returns the classic built-in theme
+func DefaultTheme() *Theme {
+	return builtinThemes[DefaultThemeID]
+}
+
+// Store holds the built-in themes and the ones admins upload, one folder per theme under dir
+type Store struct {
+	dir    string
+	mu     sync.RWMutex
+	themes map[string]*Theme
+}
+
+// NewStore loads the uploaded themes in dir; folders that fail to load are skipped
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create themes dir: %w", err)
+	}
+	s := &Store{dir: dir, themes: make(map[string]*Theme)}
+	for id, theme := range builtinThemes {
+		s.themes[id] = theme
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		id := entry.Name()
+		if !entry.IsDir() || !ValidThemeID(id) || builtinThemes[id] != nil {
+			continue
+		}
+		theme, err := LoadTheme(filepath.Join(dir, id))
+		if err != nil {
+			logger.Warn("Skipping theme", "theme", id, "err", err)
+			continue
+		}
+		theme.ID = id
+		s.themes[id] = theme
+	}
+	return s, nil
+}
+
+// List returns every theme, built-ins first, then by name
+func (s *Store) List() []*Theme {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	themes := make([]*Theme, 0, len(s.themes))
+	for _, theme := range s.themes {
+		themes = append(themes, theme)
+	}
+	sort.Slice(themes, func(i, j int) bool {
+		if themes[i].builtin != themes[j].builtin {
+			return themes[i].builtin
+		}
+		return strings.ToLower(themes[i].Name) < strings.ToLower(themes[j].Name)
+	})
+	return themes
+}
+
+// Get returns a theme by ID, or nil
+func (s *Store) Get(id string) *Theme {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.themes[id]
+}
+
+// Install adds or replaces an uploaded theme
+// filename picks the format: a .json theme on its own, or a .zip with theme.json and its files
+// The theme's ID comes from its "id", else its name
+func (s *Store) Install(filename string, data []byte) (*Theme, error) {
+	staging, err := os.MkdirTemp(s.dir, ".upload-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		err = os.WriteFile(filepath.Join(staging, "theme.json"), data, 0644)
+	case ".zip":
+		err = extractTheme(data, staging)
+	default:
+		err = fmt.Errorf("upload a .json theme or a .zip with theme.json and its files")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	theme, err := LoadTheme(staging)
+	if err != nil {
+		return nil, err
+	}
+	id := theme.ID
+	if id == "" {
+		id = ThemeID(theme.Name)
+	}
+	if !ValidThemeID(id) {
+		return nil, fmt.Errorf("can't make a theme id from name %q; set \"id\"", theme.Name)
+	}
+	if builtinThemes[id] != nil {
+		return nil, fmt.Errorf("theme id %q belongs to a built-in theme", id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	final := filepath.Join(s.dir, id)
+	if err := os.RemoveAll(final); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(staging, final); err != nil {
+		return nil, err
+	}
+	theme.ID = id
+	theme.dir = final
+	s.themes[id] = theme
+	return theme, nil
+}
+
+// Delete removes an uploaded theme; built-ins can't be deleted
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	theme := s.themes[id]
+	if theme == nil {
+		return ErrThemeNotFound
+	}
+	if theme.builtin {
+		return fmt.Errorf("built-in themes can't be deleted")
+	}
+	if err := os.RemoveAll(theme.dir); err != nil {
+		return err
+	}
+	delete(s.themes, id)
+	return nil
+}
+
+// extractTheme unpacks a theme archive into dir
+// theme.json may sit at the top or inside a single folder, as zipping a folder produces
+func extractTheme(data []byte, dir string) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("invalid zip: %w", err)
+	}
+	if len(zr.File) > MaxThemeFiles {
+		return fmt.Errorf("theme has more than %d files", MaxThemeFiles)
+	}
+
+	// The shortest theme.json path is the theme's root
+	root, found := "", false
+	for _, f := range zr.File {
+		if path.Base(f.Name) != "theme.json" {
+			continue
+		}
+		if dir := strings.TrimSuffix(f.Name, "theme.json"); !found || len(dir) < len(root) {
+			root, found = dir, true
+		}
+	}
+	if !found {
+		return fmt.Errorf("zip has no theme.json")
+	}
+
+	var total int64
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !strings.HasPrefix(f.Name, root) {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(f.Name, root))
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("zip entry %q is outside the theme", f.Name)
+		}
+		total += int64(f.UncompressedSize64)
+		if total > MaxThemeBytes {
+			return fmt.Errorf("theme is larger than %d MB", MaxThemeBytes>>20)
+		}
+		if err := extractFile(f, filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractFile writes one zip entry, never more than its declared size
+func extractFile(f *zip.File, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, io.LimitReader(src, int64(f.UncompressedSize64))); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// ThemeInfo summarizes a theme for the admin UI
+type ThemeInfo struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Builtin     bool     `json:"builtin"`
+	Video       bool     `json:"video"`
+	Widgets     []string `json:"widgets"`
+	Active      bool     `json:"active"`
+}
+
+// Info summarizes the theme
+func (t *Theme) Info() ThemeInfo {
+	info := ThemeInfo{
+		ID:          t.ID,
+		Name:        t.Name,
+		Description: t.Description,
+		Builtin:     t.builtin,
+		Video:       t.Background.Video != "",
+	}
+	for _, w := range t.Widgets {
+		info.Widgets = append(info.Widgets, w.Type)
+	}
+	return info
+}
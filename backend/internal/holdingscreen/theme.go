@@ -0,0 +1,374 @@
+package holdingscreen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Widget types a theme can place on the holding screen
+const (
+	WidgetGradient   = "gradient"    // Transparent-to-color wash, e.g. behind the bottom row
+	WidgetPanel      = "panel"       // Plain box
+	WidgetText       = "text"        // Fixed text such as the venue name
+	WidgetMessage    = "message"     // Admin message banner; hidden when there's no message
+	WidgetQR         = "qr"          // Join QR code with the connect URL
+	WidgetNextUp     = "next_up"     // Next song with the singer's avatar
+	WidgetQueue      = "queue"       // Songs after the next one
+	WidgetClock      = "clock"       // Current time
+	WidgetTopSingers = "top_singers" // Most songs sung tonight
+	WidgetCountdown  = "countdown"   // Time left until an event, e.g. last orders
+)
+
+var widgetTypes = map[string]bool{
+	WidgetGradient: true, WidgetPanel: true, WidgetText: true, WidgetMessage: true, WidgetQR: true,
+	WidgetNextUp: true, WidgetQueue: true, WidgetClock: true, WidgetTopSingers: true, WidgetCountdown: true,
+}
+
+// Built-in font names; themes can also name TTF files in their folder
+const (
+	FontSans   = "sans"    // System sans-serif, falling back to Go Regular
+	FontGo     = "go"      // Go Regular (always available)
+	FontGoBold = "go-bold" // Go Bold
+	FontGoMono = "go-mono" // Go Mono
+)
+
+// Background file types
+var (
+	imageExts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true}
+	videoExts = map[string]bool{".mp4": true, ".webm": true, ".mkv": true, ".mov": true}
+	fontExts  = map[string]bool{".ttf": true, ".otf": true}
+)
+
+// LogoImage names the embedded SongMartyn logo as a background image
+const LogoImage = "logo"
+
+// Default palette; themes override or add names in "colors"
+var defaultColors = map[string]string{
+	"primary": "#00bcd4", // SongMartyn cyan
+	"accent":  "#eab308", // SongMartyn yellow
+	"text":    "#ffffff",
+	"muted":   "#a0a0a0",
+	"faint":   "#646464",
+	"panel":   "#00000099",
+}
+
+// Theme is a declarative holding screen layout: a background and widgets placed in regions
+// Regions use a 1920x1080 canvas
+type Theme struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Background  Background        `json:"background"`
+	Fonts       map[string]string `json:"fonts,omitempty"`  // Font name -> built-in font or TTF file in the theme folder
+	Colors      map[string]string `json:"colors,omitempty"` // Palette name -> #rgb, #rrggbb or #rrggbbaa
+	Widgets     []Widget          `json:"widgets"`
+
+	dir     string // Folder the theme's files are relative to; empty for built-ins
+	builtin bool
+}
+
+// Background is drawn under the widgets
+type Background struct {
+	Color string  `json:"color,omitempty"` // Fill color, also shown behind a video until it starts
+	Image string  `json:"image,omitempty"` // "logo" or an image in the theme folder, scaled to cover
+	Video string  `json:"video,omitempty"` // Video in the theme folder looped behind the widgets
+	Dim   float64 `json:"dim,omitempty"`   // 0-1 black wash over the image or video
+}
+
+// Region is a widget's box on the canvas
+type Region struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+// Widget is one element of a theme; unused fields are ignored by its type
+type Widget struct {
+	Type    string  `json:"type"`
+	Region  Region  `json:"region"`
+	Font    string  `json:"font,omitempty"`    // Body font name (default "body")
+	Size    float64 `json:"size,omitempty"`    // Main text size in pixels
+	Color   string  `json:"color,omitempty"`   // Main text color (default "text")
+	Accent  string  `json:"accent,omitempty"`  // Titles and accent lines (default "accent")
+	Panel   string  `json:"panel,omitempty"`   // Box behind the widget; empty for none
+	Align   string  `json:"align,omitempty"`   // left, center or right
+	Title   string  `json:"title,omitempty"`   // Heading, e.g. "NEXT UP"
+	Text    string  `json:"text,omitempty"`    // text: the text; countdown: shown once the time is reached
+	Count   int     `json:"count,omitempty"`   // Rows in queue and top_singers lists
+	Format  string  `json:"format,omitempty"`  // clock: Go time layout (default "15:04")
+	Target  string  `json:"target,omitempty"`  // countdown: RFC 3339 time or HH:MM tonight
+	Opacity float64 `json:"opacity,omitempty"` // gradient: opacity at the bottom edge
+}
+
+var themeIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ValidThemeID reports whether id can name a theme folder
+func ValidThemeID(id string) bool {
+	return themeIDPattern.MatchString(id)
+}
+
+// ThemeID turns a name or file name into a theme ID
+func ThemeID(name string) string {
+	name = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+			dash = false
+		case b.Len() > 0 && !dash:
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	id := strings.TrimSuffix(b.String(), "-")
+	if len(id) > 64 {
+		id = id[:64]
+	}
+	return id
+}
+
+// ParseTheme decodes and validates a theme whose files live in dir
+func ParseTheme(data []byte, dir string) (*Theme, error) {
+	var theme Theme
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&theme); err != nil {
+		return nil, fmt.Errorf("invalid theme JSON: %w", err)
+	}
+	theme.dir = dir
+	if err := theme.Validate(); err != nil {
+		return nil, err
+	}
+	return &theme, nil
+}
+
+// LoadTheme reads theme.json from a theme folder
+func LoadTheme(dir string) (*Theme, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "theme.json"))
+	if err != nil {
+		return nil, err
+	}
+	return ParseTheme(data, dir)
+}
+
+// Validate checks the theme can be rendered, naming the first problem found
+func (t *Theme) Validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return fmt.Errorf("theme needs a name")
+	}
+	if t.ID != "" && !ValidThemeID(t.ID) {
+		return fmt.Errorf("invalid theme id %q: use lowercase letters, digits, - and _", t.ID)
+	}
+
+	for name, value := range t.Colors {
+		if _, err := parseHexColor(value); err != nil {
+			return fmt.Errorf("color %q: %w", name, err)
+		}
+	}
+	for name, font := range t.Fonts {
+		if err := t.checkFont(font); err != nil {
+			return fmt.Errorf("font %q: %w", name, err)
+		}
+	}
+
+	bg := t.Background
+	if bg.Color != "" {
+		if _, err := t.color(bg.Color, ""); err != nil {
+			return fmt.Errorf("background color: %w", err)
+		}
+	}
+	if bg.Image != "" && bg.Image != LogoImage {
+		if err := t.checkFile(bg.Image, imageExts); err != nil {
+			return fmt.Errorf("background image: %w", err)
+		}
+	}
+	if bg.Video != "" {
+		if err := t.checkFile(bg.Video, videoExts); err != nil {
+			return fmt.Errorf("background video: %w", err)
+		}
+	}
+	if bg.Dim < 0 || bg.Dim > 1 {
+		return fmt.Errorf("background dim must be between 0 and 1")
+	}
+
+	if len(t.Widgets) == 0 {
+		return fmt.Errorf("theme has no widgets")
+	}
+	for i, w := range t.Widgets {
+		if err := t.validateWidget(w); err != nil {
+			return fmt.Errorf("widget %d (%s): %w", i+1, w.Type, err)
+		}
+	}
+	return nil
+}
+
+// validateWidget checks one widget
+func (t *Theme) validateWidget(w Widget) error {
+	if !widgetTypes[w.Type] {
+		return fmt.Errorf("unknown widget type")
+	}
+	r := w.Region
+	if r.W <= 0 || r.H <= 0 || r.X < 0 || r.Y < 0 || r.X+r.W > canvasWidth || r.Y+r.H > canvasHeight {
+		return fmt.Errorf("region must lie within the %dx%d canvas", canvasWidth, canvasHeight)
+	}
+	for _, c := range []string{w.Color, w.Accent, w.Panel} {
+		if c == "" {
+			continue
+		}
+		if _, err := t.color(c, ""); err != nil {
+			return err
+		}
+	}
+	if w.Font != "" {
+		if _, ok := t.Fonts[w.Font]; !ok && !builtinFont(w.Font) && w.Font != "body" && w.Font != "heading" {
+			return fmt.Errorf("unknown font %q", w.Font)
+		}
+	}
+	switch w.Align {
+	case "", "left", "center", "right":
+	default:
+		return fmt.Errorf("align must be left, center or right")
+	}
+	if w.Size < 0 || w.Count < 0 || w.Opacity < 0 || w.Opacity > 1 {
+		return fmt.Errorf("size, count and opacity can't be negative (opacity at most 1)")
+	}
+	switch w.Type {
+	case WidgetText:
+		if w.Text == "" {
+			return fmt.Errorf("text widget needs text")
+		}
+	case WidgetCountdown:
+		if _, err := countdownTarget(w.Target, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFont accepts a built-in font or a font file in the theme folder
+func (t *Theme) checkFont(font string) error {
+	if builtinFont(font) {
+		return nil
+	}
+	return t.checkFile(font, fontExts)
+}
+
+// checkFile makes sure a theme file is inside the theme folder and has an allowed type
+func (t *Theme) checkFile(name string, exts map[string]bool) error {
+	if !exts[strings.ToLower(filepath.Ext(name))] {
+		return fmt.Errorf("unsupported file type %q", name)
+	}
+	if t.dir == "" {
+		return fmt.Errorf("built-in themes can't use files (%q)", name)
+	}
+	path, err := t.file(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("missing file %q", name)
+	}
+	return nil
+}
+
+// file resolves a file name within the theme folder
+func (t *Theme) file(name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file %q is outside the theme folder", name)
+	}
+	return filepath.Join(t.dir, clean), nil
+}
+
+// Builtin reports whether the theme ships with SongMartyn
+func (t *Theme) Builtin() bool {
+	return t.builtin
+}
+
+// BackgroundVideo returns the path of the theme's background video, if any
+func (t *Theme) BackgroundVideo() string {
+	if t.Background.Video == "" {
+		return ""
+	}
+	path, err := t.file(t.Background.Video)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// Ticking reports whether the theme shows the time and must be redrawn as it passes
+func (t *Theme) Ticking() bool {
+	for _, w := range t.Widgets {
+		if w.Type == WidgetClock || w.Type == WidgetCountdown {
+			return true
+		}
+	}
+	return false
+}
+
+// color resolves a palette name or hex color, using fallback when value is empty
+func (t *Theme) color(value, fallback string) (color.Color, error) {
+	if value == "" {
+		value = fallback
+	}
+	if hex, ok := t.Colors[value]; ok {
+		value = hex
+	} else if hex, ok := defaultColors[value]; ok {
+		value = hex
+	}
+	return parseHexColor(value)
+}
+
+// parseHexColor parses #rgb, #rrggbb or #rrggbbaa
+func parseHexColor(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if !strings.HasPrefix(s, "#") {
+		return nil, fmt.Errorf("unknown color %q", s)
+	}
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return nil, fmt.Errorf("invalid color %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid color %q", s)
+	}
+	return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
+
+// countdownTarget resolves a countdown target: an RFC 3339 time, or HH:MM meaning
+// the next time the clock shows it, unless that passed within the last 12 hours
+func countdownTarget(target string, now time.Time) (time.Time, error) {
+	if target == "" {
+		return time.Time{}, fmt.Errorf("countdown needs a target time")
+	}
+	if t, err := time.Parse(time.RFC3339, target); err == nil {
+		return t, nil
+	}
+	clock, err := time.ParseInLocation("15:04", target, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("countdown target %q must be RFC 3339 or HH:MM", target)
+	}
+	t := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if now.Sub(t) > 12*time.Hour {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
@@ -0,0 +1,449 @@
+package holdingscreen
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nfnt/resize"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden holding screen images")
+
+// Golden images are stored at a quarter of the canvas size to keep them small
+const (
+	goldenWidth     = canvasWidth / 4
+	goldenHeight    = canvasHeight / 4
+	goldenTolerance = 2.0 // Mean difference per channel (0-255) allowed for float rounding across platforms
+)
+
+// newTestGenerator renders with the built-in Go fonts and a local QR code so output is the same everywhere
+func newTestGenerator(t *testing.T) *Generator {
+	t.Helper()
+	g, err := NewGenerator(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	g.fonts = newFontCache(nil)
+	g.qrCode = fakeQRCode
+	return g
+}
+
+// fakeQRCode draws a QR-like grid derived from the data
+func fakeQRCode(data string, size int) (image.Image, error) {
+	h := fnv.New64a()
+	h.Write([]byte(data))
+	bits := h.Sum64()
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	cell := size / 8
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			bit := uint((y/cell)*8+x/cell) % 64
+			c := color.RGBA{255, 255, 255, 255}
+			if bits>>bit&1 == 1 {
+				c = color.RGBA{0, 0, 0, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img, nil
+}
+
+// testContent is a busy night: a message, a queue and some singers
+func testContent() Content {
+	return Content{
+		ConnectURL: "https://karaoke.local:8443",
+		NextUp:     &NextUpInfo{SongTitle: "Bohemian Rhapsody", SongArtist: "Queen", SingerName: "Freddie"},
+		Queue: []NextUpInfo{
+			{SongTitle: "Dancing Queen", SingerName: "Agnetha"},
+			{SongTitle: "A Very Long Song Title That Will Not Fit In The Queue Widget At All", SingerName: "Someone"},
+			{SongTitle: "Wonderwall", SingerName: "Liam"},
+			{SongTitle: "Hidden By The Row Count", SingerName: "Nobody"},
+		},
+		Message:    "Happy hour until 10pm! 🎉",
+		TopSingers: []SingerStat{{"Freddie", 3}, {"Agnetha", 2}, {"Liam", 1}},
+		Now:        time.Date(2024, 6, 1, 21, 30, 0, 0, time.UTC),
+	}
+}
+
+// loadTestTheme loads a theme from testdata/themes
+func loadTestTheme(t *testing.T, id string) *Theme {
+	t.Helper()
+	theme, err := LoadTheme(filepath.Join("testdata", "themes", id))
+	if err != nil {
+		t.Fatalf("Failed to load test theme %s: %v", id, err)
+	}
+	theme.ID = id
+	return theme
+}
+
+// compareGolden checks a render against testdata/golden/<name>.png, or rewrites it with -update
+func compareGolden(t *testing.T, name string, img image.Image) {
+	t.Helper()
+	small := resize.Resize(goldenWidth, goldenHeight, img, resize.Bilinear)
+	path := filepath.Join("testdata", "golden", name+".png")
+
+	if *updateGolden {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, small); err != nil {
+			t.Fatalf("Failed to encode golden image: %v", err)
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatalf("Failed to write golden image: %v", err)
+		}
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Missing golden image (run go test -update): %v", err)
+	}
+	defer f.Close()
+	want, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("Failed to decode golden image: %v", err)
+	}
+
+	var diff float64
+	for y := 0; y < goldenHeight; y++ {
+		for x := 0; x < goldenWidth; x++ {
+			r1, g1, b1, a1 := small.At(x, y).RGBA()
+			r2, g2, b2, a2 := want.At(x, y).RGBA()
+			diff += absDiff(r1, r2) + absDiff(g1, g2) + absDiff(b1, b2) + absDiff(a1, a2)
+		}
+	}
+	mean := diff / float64(goldenWidth*goldenHeight*4) / 257
+	if mean > goldenTolerance {
+		t.Errorf("Expected %s to match its golden image, mean difference %.2f", name, mean)
+	}
+}
+
+func absDiff(a, b uint32) float64 {
+	if a > b {
+		return float64(a - b)
+	}
+	return float64(b - a)
+}
+
+// ============================================================================
+// Golden Image Tests
+// ============================================================================
+
+func TestBuiltinThemesMatchGolden(t *testing.T) {
+	for _, id := range []string{"classic", "spotlight"} {
+		t.Run(id, func(t *testing.T) {
+			g := newTestGenerator(t)
+			compareGolden(t, id, g.render(builtinThemes[id], testContent(), false).Image())
+		})
+	}
+	if len(builtinThemes) != 2 {
+		t.Errorf("Expected a golden image for every built-in theme, got %d themes", len(builtinThemes))
+	}
+}
+
+func TestBuiltinThemesEmptyQueueMatchGolden(t *testing.T) {
+	for _, id := range []string{"classic", "spotlight"} {
+		t.Run(id, func(t *testing.T) {
+			g := newTestGenerator(t)
+			content := Content{ConnectURL: "https://karaoke.local:8443", Now: time.Date(2024, 6, 1, 19, 5, 0, 0, time.UTC)}
+			compareGolden(t, id+"-empty", g.render(builtinThemes[id], content, false).Image())
+		})
+	}
+}
+
+func TestUploadedThemeMatchesGolden(t *testing.T) {
+	g := newTestGenerator(t)
+	compareGolden(t, "neon", g.render(loadTestTheme(t, "neon"), testContent(), false).Image())
+
+	// Once the countdown's time has passed it shows its text instead
+	content := testContent()
+	content.Now = time.Date(2024, 6, 1, 23, 10, 0, 0, time.UTC)
+	compareGolden(t, "neon-last-orders", g.render(loadTestTheme(t, "neon"), content, false).Image())
+}
+
+// ============================================================================
+// Rendering Tests
+// ============================================================================
+
+func TestGenerateUsesActiveTheme(t *testing.T) {
+	g := newTestGenerator(t)
+	if g.Theme().ID != DefaultThemeID {
+		t.Errorf("Expected the classic theme by default, got %s", g.Theme().ID)
+	}
+
+	g.SetTheme(builtinThemes["spotlight"])
+	path, err := g.Generate(testContent())
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open holding screen: %v", err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("Failed to decode holding screen: %v", err)
+	}
+	if img.Bounds().Dx() != canvasWidth || img.Bounds().Dy() != canvasHeight {
+		t.Errorf("Expected a %dx%d image, got %v", canvasWidth, canvasHeight, img.Bounds())
+	}
+}
+
+func TestOverlayLeavesBackgroundForVideo(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "loop.mp4"), []byte("fake video"), 0644)
+	theme, err := ParseTheme([]byte(`{
+		"name": "Video",
+		"background": {"color": "#ff0000", "video": "loop.mp4", "dim": 0.5},
+		"widgets": [{"type": "panel", "region": {"x": 0, "y": 0, "w": 100, "h": 100}, "panel": "#00ff00"}]
+	}`), dir)
+	if err != nil {
+		t.Fatalf("Failed to parse theme: %v", err)
+	}
+	if theme.BackgroundVideo() != filepath.Join(dir, "loop.mp4") {
+		t.Errorf("Expected the video path, got %q", theme.BackgroundVideo())
+	}
+
+	g := newTestGenerator(t)
+	overlay := g.render(theme, testContent(), true).Image()
+	_, _, _, a := overlay.At(960, 540).RGBA()
+	if a>>8 != 127 && a>>8 != 128 {
+		t.Errorf("Expected only the half dim over the video, got alpha %d", a>>8)
+	}
+	_, green, _, _ := overlay.At(50, 50).RGBA()
+	if green>>8 < 200 {
+		t.Errorf("Expected widgets drawn on the overlay, got green %d", green>>8)
+	}
+
+	// The still version fills in the background color for when the video can't play
+	still := g.render(theme, testContent(), false).Image()
+	red, _, _, _ := still.At(960, 540).RGBA()
+	if red>>8 < 120 || red>>8 > 135 {
+		t.Errorf("Expected the dimmed background color, got red %d", red>>8)
+	}
+}
+
+func TestQRCodeOpensJoinURL(t *testing.T) {
+	g := newTestGenerator(t)
+	var encoded []string
+	g.qrCode = func(data string, size int) (image.Image, error) {
+		encoded = append(encoded, data)
+		return fakeQRCode(data, size)
+	}
+
+	content := testContent()
+	g.render(DefaultTheme(), content, false)
+	content.JoinURL = "http://karaoke.local:8080/ca/"
+	g.render(DefaultTheme(), content, false)
+
+	want := []string{content.ConnectURL, content.JoinURL}
+	if len(encoded) != 2 || encoded[0] != want[0] || encoded[1] != want[1] {
+		t.Errorf("Expected QR codes for %v, got %v", want, encoded)
+	}
+}
+
+func TestTickingThemes(t *testing.T) {
+	if builtinThemes["classic"].Ticking() {
+		t.Error("Expected the classic theme not to need redrawing as time passes")
+	}
+	if !builtinThemes["spotlight"].Ticking() {
+		t.Error("Expected a theme with a clock to need redrawing")
+	}
+}
+
+func TestCountdownTarget(t *testing.T) {
+	now := time.Date(2024, 6, 1, 1, 30, 0, 0, time.UTC)
+	tests := []struct {
+		target string
+		want   time.Time
+	}{
+		{"02:00", time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC)},
+		{"01:00", time.Date(2024, 6, 1, 1, 0, 0, 0, time.UTC)},  // Just passed
+		{"13:00", time.Date(2024, 6, 1, 13, 0, 0, 0, time.UTC)}, // Later today
+		{"23:00", time.Date(2024, 6, 1, 23, 0, 0, 0, time.UTC)}, // Tonight
+		{"2024-12-31T23:59:00Z", time.Date(2024, 12, 31, 23, 59, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := countdownTarget(tt.target, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("%s: expected %v, got %v (%v)", tt.target, tt.want, got, err)
+		}
+	}
+
+	// Past midnight, a target from the evening before is 12+ hours ago, so it means tomorrow
+	late := time.Date(2024, 6, 2, 14, 0, 0, 0, time.UTC)
+	if got, _ := countdownTarget("01:00", late); !got.Equal(time.Date(2024, 6, 3, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected tomorrow's 01:00, got %v", got)
+	}
+
+	if got := formatRemaining(89*time.Minute + 10*time.Second); got != "1h 30m" {
+		t.Errorf("Expected 1h 30m, got %s", got)
+	}
+	if got := formatRemaining(20 * time.Second); got != "1 min" {
+		t.Errorf("Expected 1 min, got %s", got)
+	}
+}
+
+// ============================================================================
+// Validation Tests
+// ============================================================================
+
+func TestThemeValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		theme string
+		want  string
+	}{
+		{"no name", `{"widgets": [{"type": "clock", "region": {"x": 0, "y": 0, "w": 10, "h": 10}}]}`, "needs a name"},
+		{"no widgets", `{"name": "Empty"}`, "no widgets"},
+		{"unknown field", `{"name": "X", "widgts": []}`, "unknown field"},
+		{"unknown widget", `{"name": "X", "widgets": [{"type": "weather", "region": {"x": 0, "y": 0, "w": 10, "h": 10}}]}`, "widget 1 (weather): unknown widget type"},
+		{"off canvas", `{"name": "X", "widgets": [{"type": "clock", "region": {"x": 1900, "y": 0, "w": 100, "h": 10}}]}`, "within the 1920x1080 canvas"},
+		{"bad color", `{"name": "X", "widgets": [{"type": "clock", "region": {"x": 0, "y": 0, "w": 10, "h": 10}, "color": "purple"}]}`, "unknown color"},
+		{"bad palette", `{"name": "X", "colors": {"brand": "#12345"}, "widgets": [{"type": "clock", "region": {"x": 0, "y": 0, "w": 10, "h": 10}}]}`, `color "brand"`},
+		{"unknown font", `{"name": "X", "widgets": [{"type": "clock", "region": {"x": 0, "y": 0, "w": 10, "h": 10}, "font": "comic"}]}`, `unknown font "comic"`},
+		{"missing image", `{"name": "X", "background": {"image": "bg.png"}, "widgets": [{"type": "clock", "region": {"x": 0, "y": 0, "w": 10, "h": 10}}]}`, `missing file "bg.png"`},
+		{"escaping file", `{"name": "X", "background": {"image": "../secret.png"}, "widgets": [{"type": "clock", "region": {"x": 0, "y": 0, "w": 10, "h": 10}}]}`, "outside the theme folder"},
+		{"wrong video type", `{"name": "X", "background": {"video": "loop.gif"}, "widgets": [{"type": "clock", "region": {"x": 0, "y": 0, "w": 10, "h": 10}}]}`, "unsupported file type"},
+		{"bad countdown", `{"name": "X", "widgets": [{"type": "countdown", "region": {"x": 0, "y": 0, "w": 10, "h": 10}, "target": "soon"}]}`, "RFC 3339 or HH:MM"},
+		{"empty text", `{"name": "X", "widgets": [{"type": "text", "region": {"x": 0, "y": 0, "w": 10, "h": 10}}]}`, "needs text"},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		_, err := ParseTheme([]byte(tt.theme), dir)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestThemeID(t *testing.T) {
+	tests := map[string]string{
+		"Neon Night":          "neon-night",
+		"neon-night.zip":      "neon-night",
+		"  Pub Quiz!! 2024  ": "pub-quiz-2024",
+		"!!!":                 "",
+	}
+	for in, want := range tests {
+		if got := ThemeID(in); got != want {
+			t.Errorf("ThemeID(%q): expected %q, got %q", in, want, got)
+		}
+	}
+}
+
+// ============================================================================
+// Store Tests
+// ============================================================================
+
+// zipTheme builds a theme archive from name -> contents
+func zipTheme(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, contents := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to add %s: %v", name, err)
+		}
+		w.Write([]byte(contents))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestStoreInstallAndDelete(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if len(store.List()) != len(builtinThemes) {
+		t.Errorf("Expected only built-in themes, got %d", len(store.List()))
+	}
+
+	bg, _ := os.ReadFile(filepath.Join("testdata", "themes", "neon", "background.png"))
+	themeJSON, _ := os.ReadFile(filepath.Join("testdata", "themes", "neon", "theme.json"))
+	// Zipping a folder puts everything under it
+	archive := zipTheme(t, map[string]string{
+		"neon/theme.json":       string(themeJSON),
+		"neon/background.png":   string(bg),
+		"__MACOSX/theme.json.x": "junk",
+	})
+	theme, err := store.Install("upload.zip", archive)
+	if err != nil {
+		t.Fatalf("Failed to install theme: %v", err)
+	}
+	if theme.ID != "neon-night" || store.Get("neon-night") == nil {
+		t.Errorf("Expected the theme installed as neon-night, got %q", theme.ID)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "neon-night", "background.png")); err != nil {
+		t.Errorf("Expected the background image installed: %v", err)
+	}
+
+	// Uploaded themes survive a restart
+	reloaded, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reload store: %v", err)
+	}
+	if reloaded.Get("neon-night") == nil {
+		t.Error("Expected the uploaded theme after reloading")
+	}
+	list := reloaded.List()
+	if !list[0].Builtin() || list[len(list)-1].ID != "neon-night" {
+		t.Errorf("Expected built-in themes listed first, got %v", list[0].ID)
+	}
+
+	if err := store.Delete(DefaultThemeID); err == nil {
+		t.Error("Expected built-in themes not to be deletable")
+	}
+	if err := store.Delete("neon-night"); err != nil {
+		t.Fatalf("Failed to delete theme: %v", err)
+	}
+	if store.Get("neon-night") != nil {
+		t.Error("Expected the theme gone after deleting")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "neon-night")); !os.IsNotExist(err) {
+		t.Errorf("Expected the theme folder removed, got %v", err)
+	}
+}
+
+func TestStoreRejectsBadUploads(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	clock := `"widgets": [{"type": "clock", "region": {"x": 0, "y": 0, "w": 10, "h": 10}}]`
+
+	tests := []struct {
+		name     string
+		filename string
+		data     []byte
+		want     string
+	}{
+		{"wrong type", "theme.txt", []byte("{}"), "upload a .json theme"},
+		{"invalid theme", "theme.json", []byte(`{"name": "X"}`), "no widgets"},
+		{"built-in id", "theme.json", []byte(`{"name": "Classic", ` + clock + `}`), "built-in theme"},
+		{"no theme.json", "theme.zip", zipTheme(t, map[string]string{"readme.txt": "hi"}), "no theme.json"},
+		{"escaping entry", "theme.zip", zipTheme(t, map[string]string{"theme.json": `{"name": "Y", ` + clock + `}`, "../evil.txt": "x"}), "outside the theme"},
+	}
+	for _, tt := range tests {
+		if _, err := store.Install(tt.filename, tt.data); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+	if len(store.List()) != len(builtinThemes) {
+		t.Errorf("Expected no theme installed, got %d themes", len(store.List()))
+	}
+}
@@ -0,0 +1,225 @@
+package library
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"songmartyn/pkg/models"
+)
+
+// Browse dimensions for BrowseGroups
+const (
+	BrowseByArtist = "artist"
+	BrowseByAlbum  = "album"
+	BrowseByGenre  = "genre"
+	BrowseByDecade = "decade"
+	BrowseByLetter = "letter"
+)
+
+// Browse page size limits
+const (
+	DefaultBrowseLimit = 50
+	MaxBrowseLimit     = 500
+)
+
+// letterExpr maps a song title to its first letter ("#" for non-alphabetic titles)
+const letterExpr = `CASE WHEN UPPER(SUBSTR(title, 1, 1)) BETWEEN 'A' AND 'Z' THEN UPPER(SUBSTR(title, 1, 1)) ELSE '#' END`
+
+// browseGroupExprs maps each browse dimension to its grouping expression and
+// the condition a song needs to belong to any group of that dimension
+var browseGroupExprs = map[string]struct {
+	expr  string
+	where string
+}{
+	BrowseByArtist: {"artist", "artist != ''"},
+	BrowseByAlbum:  {"album", "album != ''"},
+	BrowseByGenre:  {"genre", "genre != ''"},
+	BrowseByDecade: {"(year / 10) * 10", "year > 0"},
+	BrowseByLetter: {letterExpr, "1 = 1"},
+}
+
+// BrowseFilter narrows browse results; empty fields are ignored and all set fields combine
+type BrowseFilter struct {
+	Query    string `json:"q,omitempty"` // Same matching as SearchSongs
+	Artist   string `json:"artist,omitempty"`
+	Album    string `json:"album,omitempty"`
+	Genre    string `json:"genre,omitempty"`
+	Language string `json:"language,omitempty"`
+	Decade   int    `json:"decade,omitempty"` // e.g. 1980 for the 1980s
+	Letter   string `json:"letter,omitempty"` // First letter of the title, "#" for non-alphabetic
+}
+
+// BrowseGroup is one artist/album/genre/decade/letter bucket with its song count
+type BrowseGroup struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// ParseDecade parses decade values like "1980", "1980s" or "80s"
+// Returns 0 if the value is not a decade
+func ParseDecade(s string) int {
+	s = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "s")
+	year, err := strconv.Atoi(s)
+	if err != nil || year < 0 {
+		return 0
+	}
+	if year < 100 {
+		// Two-digit decades: "80s" -> 1980, "10s" -> 2010
+		if year < 30 {
+			year += 2000
+		} else {
+			year += 1900
+		}
+	}
+	return (year / 10) * 10
+}
+
+// whereClause builds the SQL condition and arguments for the filter
+func (f BrowseFilter) whereClause() (string, []interface{}) {
+	conds := []string{}
+	args := []interface{}{}
+
+	if f.Query != "" {
+		term := "%" + f.Query + "%"
+		conds = append(conds, "(title LIKE ? OR artist LIKE ? OR album LIKE ?)")
+		args = append(args, term, term, term)
+	}
+	if f.Artist != "" {
+		conds = append(conds, "artist = ? COLLATE NOCASE")
+		args = append(args, f.Artist)
+	}
+	if f.Album != "" {
+		conds = append(conds, "album = ? COLLATE NOCASE")
+		args = append(args, f.Album)
+	}
+	if f.Genre != "" {
+		conds = append(conds, "genre = ? COLLATE NOCASE")
+		args = append(args, f.Genre)
+	}
+	if f.Language != "" {
+		conds = append(conds, "language = ? COLLATE NOCASE")
+		args = append(args, f.Language)
+	}
+	if f.Decade > 0 {
+		conds = append(conds, "year >= ? AND year < ?")
+		args = append(args, f.Decade, f.Decade+10)
+	}
+	if f.Letter != "" {
+		conds = append(conds, letterExpr+" = ?")
+		args = append(args, strings.ToUpper(string([]rune(f.Letter)[:1])))
+	}
+
+	if len(conds) == 0 {
+		return "1 = 1", args
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// clampBrowsePage normalizes limit and offset for browse queries
+func clampBrowsePage(limit, offset int) (int, int) {
+	if limit <= 0 {
+		limit = DefaultBrowseLimit
+	}
+	if limit > MaxBrowseLimit {
+		limit = MaxBrowseLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// BrowseGroups returns a page of groups for a browse dimension with song counts,
+// along with the total number of groups matching the filter
+func (m *Manager) BrowseGroups(by string, filter BrowseFilter, limit, offset int) ([]BrowseGroup, int, error) {
+	defer m.observeQuery.Time("browse_groups")()
+	group, ok := browseGroupExprs[by]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown browse dimension: %s", by)
+	}
+	limit, offset = clampBrowsePage(limit, offset)
+
+	where, args := filter.whereClause()
+	where = group.where + " AND " + where
+
+	// Text groups merge case variants ("ABBA" / "Abba")
+	groupBy := group.expr
+	if by == BrowseByArtist || by == BrowseByAlbum || by == BrowseByGenre {
+		groupBy += " COLLATE NOCASE"
+	}
+
+	var total int
+	err := m.db.QueryRow(fmt.Sprintf(`
+		SELECT COUNT(*) FROM (
+			SELECT 1 FROM library_songs WHERE %s GROUP BY %s
+		)
+	`, where, groupBy), args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := m.db.Query(fmt.Sprintf(`
+		SELECT %s AS name, COUNT(*) FROM library_songs
+		WHERE %s
+		GROUP BY %s
+		ORDER BY %s
+		LIMIT ? OFFSET ?
+	`, group.expr, where, groupBy, groupBy), append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	groups := []BrowseGroup{}
+	for rows.Next() {
+		var g BrowseGroup
+		if by == BrowseByDecade {
+			var decade int
+			if err := rows.Scan(&decade, &g.Count); err != nil {
+				return nil, 0, err
+			}
+			g.Name = fmt.Sprintf("%ds", decade)
+		} else if err := rows.Scan(&g.Name, &g.Count); err != nil {
+			return nil, 0, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, total, nil
+}
+
+// BrowseSongs returns a page of songs matching the filter, ordered by title,
+// along with the total number of matching songs
+func (m *Manager) BrowseSongs(filter BrowseFilter, limit, offset int) ([]models.LibrarySong, int, error) {
+	defer m.observeQuery.Time("browse_songs")()
+	limit, offset = clampBrowsePage(limit, offset)
+	where, args := filter.whereClause()
+
+	var total int
+	err := m.db.QueryRow("SELECT COUNT(*) FROM library_songs WHERE "+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := m.db.Query(fmt.Sprintf(`
+		SELECT %s
+		FROM library_songs
+		WHERE %s
+		ORDER BY title COLLATE NOCASE, artist COLLATE NOCASE
+		LIMIT ? OFFSET ?
+	`, songColumns, where), append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	songs := []models.LibrarySong{}
+	for rows.Next() {
+		song, err := scanSong(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		songs = append(songs, song)
+	}
+	return songs, total, nil
+}
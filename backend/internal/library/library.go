@@ -4,18 +4,23 @@ import (
 	"crypto/md5"
 	"database/sql"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"songmartyn/internal/logging"
+	"songmartyn/internal/metrics"
 	"songmartyn/pkg/models"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// logger is the "library" subsystem
+var logger = logging.For("library")
+
 // Supported audio/video extensions
 var supportedExtensions = map[string]bool{
 	".mp3":  true,
@@ -38,9 +43,14 @@ var audioExtensions = map[string]bool{
 	".m4a": true,
 }
 
+// SchemaVersion is the layout of library.db, stored in its user_version so restores can
+// tell a backup from a newer SongMartyn apart from one the migrations can upgrade
+const SchemaVersion = 2
+
 // Manager handles the song library
 type Manager struct {
-	db *sql.DB
+	db           *sql.DB
+	observeQuery metrics.QueryObserver
 }
 
 // NewManager creates a new library manager
@@ -58,6 +68,11 @@ func NewManager(dbPath string) (*Manager, error) {
 	return m, nil
 }
 
+// SetQueryObserver reports how long each database operation takes, for metrics
+func (m *Manager) SetQueryObserver(o metrics.QueryObserver) {
+	m.observeQuery = o
+}
+
 // initDB creates the necessary tables
 func (m *Manager) initDB() error {
 	schema := `
@@ -135,10 +150,81 @@ func (m *Manager) initDB() error {
 	CREATE INDEX IF NOT EXISTS idx_song_selections_source ON song_selections(source);
 	`
 
-	_, err := m.db.Exec(schema)
+	if _, err := m.db.Exec(schema); err != nil {
+		return err
+	}
+
+	// Migrations: add metadata columns to existing databases (ignore errors if columns exist)
+	m.db.Exec("ALTER TABLE library_songs ADD COLUMN genre TEXT DEFAULT ''")
+	m.db.Exec("ALTER TABLE library_songs ADD COLUMN year INTEGER DEFAULT 0")
+	m.db.Exec("ALTER TABLE library_songs ADD COLUMN language TEXT DEFAULT ''")
+	m.db.Exec("ALTER TABLE library_songs ADD COLUMN explicit INTEGER DEFAULT 0")
+	m.db.Exec("ALTER TABLE library_songs ADD COLUMN manual_fields TEXT DEFAULT ''")
+	m.db.Exec("ALTER TABLE library_songs ADD COLUMN loudness_lufs REAL")
+	m.db.Exec("ALTER TABLE library_songs ADD COLUMN true_peak_dbtp REAL")
+	m.db.Exec("ALTER TABLE library_songs ADD COLUMN loudness_analyzed_at DATETIME")
+	m.db.Exec("ALTER TABLE library_songs ADD COLUMN leading_silence REAL")
+	m.db.Exec("ALTER TABLE library_songs ADD COLUMN trailing_silence REAL")
+	m.db.Exec("ALTER TABLE song_history ADD COLUMN reactions TEXT DEFAULT ''")
+	m.db.Exec("CREATE INDEX IF NOT EXISTS idx_songs_genre ON library_songs(genre)")
+	m.db.Exec("CREATE INDEX IF NOT EXISTS idx_songs_year ON library_songs(year)")
+
+	m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS metadata_rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			library_id INTEGER NOT NULL,
+			field TEXT NOT NULL,
+			pattern TEXT NOT NULL,
+			replacement TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (library_id) REFERENCES library_locations(id) ON DELETE CASCADE
+		)
+	`)
+
+	_, err := m.db.Exec(fmt.Sprintf("PRAGMA user_version = %d", SchemaVersion))
 	return err
 }
 
+// songColumns is the column list scanned by scanSong
+const songColumns = `id, title, artist, album, genre, year, language, explicit, manual_fields, duration, file_path, thumbnail_url,
+	       vocal_path, instr_path, cdg_path, audio_path, library_id, loudness_lufs, true_peak_dbtp,
+	       COALESCE(leading_silence, 0), COALESCE(trailing_silence, 0), times_sung, last_sung_at, last_sung_by, added_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSong scans a row selected with songColumns into a LibrarySong
+func scanSong(row rowScanner) (models.LibrarySong, error) {
+	var song models.LibrarySong
+	var lastSungAt sql.NullTime
+	var lastSungBy sql.NullString
+	var manualFields string
+	var loudness, truePeak sql.NullFloat64
+	if err := row.Scan(
+		&song.ID, &song.Title, &song.Artist, &song.Album, &song.Genre, &song.Year, &song.Language,
+		&song.Explicit, &manualFields, &song.Duration,
+		&song.FilePath, &song.ThumbnailURL, &song.VocalPath, &song.InstrPath,
+		&song.CDGPath, &song.AudioPath, &song.LibraryID, &loudness, &truePeak,
+		&song.LeadingSilence, &song.TrailingSilence, &song.TimesSung, &lastSungAt, &lastSungBy, &song.AddedAt,
+	); err != nil {
+		return song, err
+	}
+	if lastSungAt.Valid {
+		song.LastSungAt = &lastSungAt.Time
+	}
+	if lastSungBy.Valid {
+		song.LastSungBy = lastSungBy.String
+	}
+	if loudness.Valid && truePeak.Valid {
+		song.LoudnessLUFS = &loudness.Float64
+		song.TruePeakDBTP = &truePeak.Float64
+	}
+	song.ManualFields = splitManualFields(manualFields)
+	return song, nil
+}
+
 // AddLocation adds a new library location
 func (m *Manager) AddLocation(path, name string) (*models.LibraryLocation, error) {
 	// Validate path exists
@@ -176,6 +262,10 @@ func (m *Manager) AddLocation(path, name string) (*models.LibraryLocation, error
 // RemoveLocation removes a library location and its songs
 func (m *Manager) RemoveLocation(id int64) error {
 	_, err := m.db.Exec("DELETE FROM library_locations WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	_, err = m.db.Exec("DELETE FROM metadata_rules WHERE library_id = ?", id)
 	return err
 }
 
@@ -238,6 +328,13 @@ func (m *Manager) ScanLocation(id int64) (int, error) {
 		return 0, err
 	}
 
+	// Location rules rewrite filename-derived titles/artists (e.g. vendor prefixes)
+	rules, err := m.GetRules(id)
+	if err != nil {
+		return 0, err
+	}
+	compiled := compileRules(rules)
+
 	count := 0
 	// Process each directory
 	for _, files := range dirFiles {
@@ -280,23 +377,14 @@ func (m *Manager) ScanLocation(id int64) (int, error) {
 				// CDG + Audio pair found
 				hash := md5.Sum([]byte(cdgPath))
 				songID := hex.EncodeToString(hash[:])
-				title, artist := parseFilename(cdgPath)
-
-				_, err = m.db.Exec(`
-					INSERT INTO library_songs (id, title, artist, file_path, cdg_path, audio_path, library_id)
-					VALUES (?, ?, ?, ?, ?, ?, ?)
-					ON CONFLICT(id) DO UPDATE SET
-						title = excluded.title,
-						artist = excluded.artist,
-						file_path = excluded.file_path,
-						cdg_path = excluded.cdg_path,
-						audio_path = excluded.audio_path
-				`, songID, title, artist, cdgPath, cdgPath, audioPath, id)
+				title, artist := deriveTitleArtist(cdgPath, compiled)
+
+				err = m.upsertSong(songID, title, artist, cdgPath, cdgPath, audioPath, id, readTags(audioPath))
 				if err != nil {
-					log.Printf("Error adding CDG song %s: %v", cdgPath, err)
+					logger.Error("Error adding CDG song", "path", cdgPath, "err", err)
 				} else {
 					count++
-					log.Printf("Added CDG+Audio pair: %s", base)
+					logger.Debug("Added CDG+Audio pair", "name", base)
 				}
 			}
 		}
@@ -305,18 +393,11 @@ func (m *Manager) ScanLocation(id int64) (int, error) {
 		for _, audioPath := range audioFiles {
 			hash := md5.Sum([]byte(audioPath))
 			songID := hex.EncodeToString(hash[:])
-			title, artist := parseFilename(audioPath)
-
-			_, err = m.db.Exec(`
-				INSERT INTO library_songs (id, title, artist, file_path, library_id)
-				VALUES (?, ?, ?, ?, ?)
-				ON CONFLICT(id) DO UPDATE SET
-					title = excluded.title,
-					artist = excluded.artist,
-					file_path = excluded.file_path
-			`, songID, title, artist, audioPath, id)
+			title, artist := deriveTitleArtist(audioPath, compiled)
+
+			err = m.upsertSong(songID, title, artist, audioPath, "", "", id, readTags(audioPath))
 			if err != nil {
-				log.Printf("Error adding song %s: %v", audioPath, err)
+				logger.Error("Error adding song", "path", audioPath, "err", err)
 			} else {
 				count++
 			}
@@ -326,18 +407,11 @@ func (m *Manager) ScanLocation(id int64) (int, error) {
 		for _, filePath := range otherFiles {
 			hash := md5.Sum([]byte(filePath))
 			songID := hex.EncodeToString(hash[:])
-			title, artist := parseFilename(filePath)
-
-			_, err = m.db.Exec(`
-				INSERT INTO library_songs (id, title, artist, file_path, library_id)
-				VALUES (?, ?, ?, ?, ?)
-				ON CONFLICT(id) DO UPDATE SET
-					title = excluded.title,
-					artist = excluded.artist,
-					file_path = excluded.file_path
-			`, songID, title, artist, filePath, id)
+			title, artist := deriveTitleArtist(filePath, compiled)
+
+			err = m.upsertSong(songID, title, artist, filePath, "", "", id, readTags(filePath))
 			if err != nil {
-				log.Printf("Error adding song %s: %v", filePath, err)
+				logger.Error("Error adding song", "path", filePath, "err", err)
 			} else {
 				count++
 			}
@@ -355,13 +429,37 @@ func (m *Manager) ScanLocation(id int64) (int, error) {
 	return count, nil
 }
 
+// upsertSong inserts or refreshes a scanned song
+// Fields the admin edited by hand (manual_fields) are never overwritten
+func (m *Manager) upsertSong(songID, title, artist, filePath, cdgPath, audioPath string, libraryID int64, tags fileTags) error {
+	defer m.observeQuery.Time("upsert_song")()
+	_, err := m.db.Exec(`
+		INSERT INTO library_songs (id, title, artist, album, genre, year, language, file_path, cdg_path, audio_path, library_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			`+keepManual(FieldTitle)+`,
+			`+keepManual(FieldArtist)+`,
+			`+keepManual(FieldAlbum)+`,
+			`+keepManual(FieldGenre)+`,
+			`+keepManual(FieldYear)+`,
+			`+keepManual(FieldLanguage)+`,
+			file_path = excluded.file_path,
+			cdg_path = excluded.cdg_path,
+			audio_path = excluded.audio_path
+	`, songID, title, artist, tags.Album, tags.Genre, tags.Year, tags.Language, filePath, cdgPath, audioPath, libraryID)
+	return err
+}
+
 // parseFilename extracts title and artist from filename
 // Supports formats like "Artist - Title.mp3" or just "Title.mp3"
 func parseFilename(path string) (title, artist string) {
 	base := filepath.Base(path)
 	ext := filepath.Ext(base)
-	name := strings.TrimSuffix(base, ext)
+	return parseName(strings.TrimSuffix(base, ext))
+}
 
+// parseName splits a file base name into title and artist
+func parseName(name string) (title, artist string) {
 	// Try "Artist - Title" format
 	parts := strings.SplitN(name, " - ", 2)
 	if len(parts) == 2 {
@@ -376,14 +474,14 @@ func parseFilename(path string) (title, artist string) {
 
 // SearchSongs searches the library for songs
 func (m *Manager) SearchSongs(query string, limit int) ([]models.LibrarySong, error) {
+	defer m.observeQuery.Time("search")()
 	if limit <= 0 {
 		limit = 50
 	}
 
 	searchTerm := "%" + query + "%"
 	rows, err := m.db.Query(`
-		SELECT id, title, artist, album, duration, file_path, thumbnail_url,
-		       vocal_path, instr_path, cdg_path, audio_path, library_id, times_sung, last_sung_at, last_sung_by, added_at
+		SELECT `+songColumns+`
 		FROM library_songs
 		WHERE title LIKE ? OR artist LIKE ? OR album LIKE ?
 		ORDER BY times_sung DESC, title ASC
@@ -396,22 +494,10 @@ func (m *Manager) SearchSongs(query string, limit int) ([]models.LibrarySong, er
 
 	var songs []models.LibrarySong
 	for rows.Next() {
-		var song models.LibrarySong
-		var lastSungAt sql.NullTime
-		var lastSungBy sql.NullString
-		if err := rows.Scan(
-			&song.ID, &song.Title, &song.Artist, &song.Album, &song.Duration,
-			&song.FilePath, &song.ThumbnailURL, &song.VocalPath, &song.InstrPath,
-			&song.CDGPath, &song.AudioPath, &song.LibraryID, &song.TimesSung, &lastSungAt, &lastSungBy, &song.AddedAt,
-		); err != nil {
+		song, err := scanSong(rows)
+		if err != nil {
 			return nil, err
 		}
-		if lastSungAt.Valid {
-			song.LastSungAt = &lastSungAt.Time
-		}
-		if lastSungBy.Valid {
-			song.LastSungBy = lastSungBy.String
-		}
 		songs = append(songs, song)
 	}
 	return songs, nil
@@ -419,32 +505,20 @@ func (m *Manager) SearchSongs(query string, limit int) ([]models.LibrarySong, er
 
 // GetSong returns a song by ID
 func (m *Manager) GetSong(id string) (*models.LibrarySong, error) {
-	var song models.LibrarySong
-	var lastSungAt sql.NullTime
-	var lastSungBy sql.NullString
-	err := m.db.QueryRow(`
-		SELECT id, title, artist, album, duration, file_path, thumbnail_url,
-		       vocal_path, instr_path, cdg_path, audio_path, library_id, times_sung, last_sung_at, last_sung_by, added_at
+	defer m.observeQuery.Time("get_song")()
+	song, err := scanSong(m.db.QueryRow(`
+		SELECT `+songColumns+`
 		FROM library_songs WHERE id = ?
-	`, id).Scan(
-		&song.ID, &song.Title, &song.Artist, &song.Album, &song.Duration,
-		&song.FilePath, &song.ThumbnailURL, &song.VocalPath, &song.InstrPath,
-		&song.CDGPath, &song.AudioPath, &song.LibraryID, &song.TimesSung, &lastSungAt, &lastSungBy, &song.AddedAt,
-	)
+	`, id))
 	if err != nil {
 		return nil, err
 	}
-	if lastSungAt.Valid {
-		song.LastSungAt = &lastSungAt.Time
-	}
-	if lastSungBy.Valid {
-		song.LastSungBy = lastSungBy.String
-	}
 	return &song, nil
 }
 
 // GetSongsByIDs returns multiple songs by their IDs
 func (m *Manager) GetSongsByIDs(ids []string) ([]models.LibrarySong, error) {
+	defer m.observeQuery.Time("get_songs")()
 	if len(ids) == 0 {
 		return []models.LibrarySong{}, nil
 	}
@@ -458,10 +532,9 @@ func (m *Manager) GetSongsByIDs(ids []string) ([]models.LibrarySong, error) {
 	}
 
 	query := fmt.Sprintf(`
-		SELECT id, title, artist, album, duration, file_path, thumbnail_url,
-		       vocal_path, instr_path, cdg_path, audio_path, library_id, times_sung, last_sung_at, last_sung_by, added_at
+		SELECT %s
 		FROM library_songs WHERE id IN (%s)
-	`, strings.Join(placeholders, ","))
+	`, songColumns, strings.Join(placeholders, ","))
 
 	rows, err := m.db.Query(query, args...)
 	if err != nil {
@@ -471,23 +544,10 @@ func (m *Manager) GetSongsByIDs(ids []string) ([]models.LibrarySong, error) {
 
 	var songs []models.LibrarySong
 	for rows.Next() {
-		var song models.LibrarySong
-		var lastSungAt sql.NullTime
-		var lastSungBy sql.NullString
-		err := rows.Scan(
-			&song.ID, &song.Title, &song.Artist, &song.Album, &song.Duration,
-			&song.FilePath, &song.ThumbnailURL, &song.VocalPath, &song.InstrPath,
-			&song.CDGPath, &song.AudioPath, &song.LibraryID, &song.TimesSung, &lastSungAt, &lastSungBy, &song.AddedAt,
-		)
+		song, err := scanSong(rows)
 		if err != nil {
 			continue
 		}
-		if lastSungAt.Valid {
-			song.LastSungAt = &lastSungAt.Time
-		}
-		if lastSungBy.Valid {
-			song.LastSungBy = lastSungBy.String
-		}
 		songs = append(songs, song)
 	}
 
@@ -495,23 +555,36 @@ func (m *Manager) GetSongsByIDs(ids []string) ([]models.LibrarySong, error) {
 }
 
 // RecordSongPlayed records that a user sang a song
-func (m *Manager) RecordSongPlayed(songID, martynKey string) error {
+func (m *Manager) RecordSongPlayed(songID, martynKey string) (int64, error) {
 	// Get song details for history
 	song, err := m.GetSong(songID)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	return m.recordPlay(songID, martynKey, song.Title, song.Artist)
+}
+
+// RecordQueuedSongPlayed records a performance of a song as it was queued, without looking it up
+// Songs from outside the library, such as YouTube videos, only go in the history
+func (m *Manager) RecordQueuedSongPlayed(song models.Song, martynKey string) (int64, error) {
+	return m.recordPlay(song.ID, martynKey, song.Title, song.Artist)
+}
+
+// recordPlay adds a performance to the history and, for library songs, their stats
+func (m *Manager) recordPlay(songID, martynKey, title, artist string) (int64, error) {
+	defer m.observeQuery.Time("record_play")()
 
 	// Add to history
-	_, err = m.db.Exec(`
+	res, err := m.db.Exec(`
 		INSERT INTO song_history (song_id, martyn_key, song_title, song_artist)
 		VALUES (?, ?, ?, ?)
-	`, songID, martynKey, song.Title, song.Artist)
+	`, songID, martynKey, title, artist)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	historyID, _ := res.LastInsertId()
 
-	// Update song stats
+	// Update song stats (no rows for songs outside the library)
 	_, err = m.db.Exec(`
 		UPDATE library_songs
 		SET times_sung = times_sung + 1,
@@ -520,17 +593,28 @@ func (m *Manager) RecordSongPlayed(songID, martynKey string) error {
 		WHERE id = ?
 	`, martynKey, songID)
 
+	return historyID, err
+}
+
+// SetHistoryReactions stores the reactions guests sent during a performance
+func (m *Manager) SetHistoryReactions(historyID int64, counts map[string]int) error {
+	data, err := json.Marshal(counts)
+	if err != nil {
+		return err
+	}
+	_, err = m.db.Exec("UPDATE song_history SET reactions = ? WHERE id = ?", string(data), historyID)
 	return err
 }
 
 // GetUserHistory returns a user's song history
 func (m *Manager) GetUserHistory(martynKey string, limit int) ([]models.SongHistory, error) {
+	defer m.observeQuery.Time("user_history")()
 	if limit <= 0 {
 		limit = 50
 	}
 
 	rows, err := m.db.Query(`
-		SELECT id, song_id, martyn_key, sung_at, song_title, song_artist
+		SELECT id, song_id, martyn_key, sung_at, song_title, song_artist, COALESCE(reactions, '')
 		FROM song_history
 		WHERE martyn_key = ?
 		ORDER BY sung_at DESC
@@ -544,23 +628,63 @@ func (m *Manager) GetUserHistory(martynKey string, limit int) ([]models.SongHist
 	var history []models.SongHistory
 	for rows.Next() {
 		var h models.SongHistory
-		if err := rows.Scan(&h.ID, &h.SongID, &h.MartynKey, &h.SungAt, &h.SongTitle, &h.SongArtist); err != nil {
+		var reactions string
+		if err := rows.Scan(&h.ID, &h.SongID, &h.MartynKey, &h.SungAt, &h.SongTitle, &h.SongArtist, &reactions); err != nil {
 			return nil, err
 		}
+		if reactions != "" {
+			json.Unmarshal([]byte(reactions), &h.Reactions)
+		}
 		history = append(history, h)
 	}
 	return history, nil
 }
 
+// SingerCount is how many songs a singer has sung
+type SingerCount struct {
+	MartynKey string `json:"martyn_key"`
+	Songs     int    `json:"songs"`
+}
+
+// TopSingers returns the singers with the most songs sung since a time, busiest first
+func (m *Manager) TopSingers(since time.Time, limit int) ([]SingerCount, error) {
+	if limit <= 0 {
+		limit = 5
+	}
+
+	rows, err := m.db.Query(`
+		SELECT martyn_key, COUNT(*) AS songs
+		FROM song_history
+		WHERE sung_at >= ?
+		GROUP BY martyn_key
+		ORDER BY songs DESC, MAX(sung_at) ASC
+		LIMIT ?
+	`, since.UTC().Format("2006-01-02 15:04:05"), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var singers []SingerCount
+	for rows.Next() {
+		var s SingerCount
+		if err := rows.Scan(&s.MartynKey, &s.Songs); err != nil {
+			return nil, err
+		}
+		singers = append(singers, s)
+	}
+	return singers, rows.Err()
+}
+
 // GetPopularSongs returns the most sung songs
 func (m *Manager) GetPopularSongs(limit int) ([]models.LibrarySong, error) {
+	defer m.observeQuery.Time("popular_songs")()
 	if limit <= 0 {
 		limit = 20
 	}
 
 	rows, err := m.db.Query(`
-		SELECT id, title, artist, album, duration, file_path, thumbnail_url,
-		       vocal_path, instr_path, cdg_path, audio_path, library_id, times_sung, last_sung_at, last_sung_by, added_at
+		SELECT `+songColumns+`
 		FROM library_songs
 		WHERE times_sung > 0
 		ORDER BY times_sung DESC
@@ -573,22 +697,10 @@ func (m *Manager) GetPopularSongs(limit int) ([]models.LibrarySong, error) {
 
 	var songs []models.LibrarySong
 	for rows.Next() {
-		var song models.LibrarySong
-		var lastSungAt sql.NullTime
-		var lastSungBy sql.NullString
-		if err := rows.Scan(
-			&song.ID, &song.Title, &song.Artist, &song.Album, &song.Duration,
-			&song.FilePath, &song.ThumbnailURL, &song.VocalPath, &song.InstrPath,
-			&song.CDGPath, &song.AudioPath, &song.LibraryID, &song.TimesSung, &lastSungAt, &lastSungBy, &song.AddedAt,
-		); err != nil {
+		song, err := scanSong(rows)
+		if err != nil {
 			return nil, err
 		}
-		if lastSungAt.Valid {
-			song.LastSungAt = &lastSungAt.Time
-		}
-		if lastSungBy.Valid {
-			song.LastSungBy = lastSungBy.String
-		}
 		songs = append(songs, song)
 	}
 	return songs, nil
@@ -606,6 +718,7 @@ func (m *Manager) GetStats() (totalSongs, totalPlays int, err error) {
 
 // LogSearch logs a search query and its results
 func (m *Manager) LogSearch(query, source string, resultsCount int, martynKey, ipAddress string) error {
+	defer m.observeQuery.Time("log_search")()
 	_, err := m.db.Exec(`
 		INSERT INTO search_logs (query, source, results_count, martyn_key, ip_address)
 		VALUES (?, ?, ?, ?, ?)
@@ -615,6 +728,7 @@ func (m *Manager) LogSearch(query, source string, resultsCount int, martynKey, i
 
 // LogSongSelection logs when a user selects a song from search results
 func (m *Manager) LogSongSelection(songID, songTitle, songArtist, source, searchQuery, martynKey, ipAddress string) error {
+	defer m.observeQuery.Time("log_selection")()
 	_, err := m.db.Exec(`
 		INSERT INTO song_selections (song_id, song_title, song_artist, source, search_query, martyn_key, ip_address)
 		VALUES (?, ?, ?, ?, ?, ?, ?)
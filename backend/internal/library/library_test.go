@@ -5,6 +5,9 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"songmartyn/pkg/models"
 )
 
 // =============================================================================
@@ -733,3 +736,839 @@ func TestGetStats(t *testing.T) {
 		t.Errorf("Expected 0 total plays, got %d", totalPlays)
 	}
 }
+
+func TestTopSingers(t *testing.T) {
+	tmpDir := t.TempDir()
+	m, err := NewManager(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer m.Close()
+
+	songsDir := filepath.Join(tmpDir, "songs")
+	os.Mkdir(songsDir, 0755)
+	os.WriteFile(filepath.Join(songsDir, "Song1.mp4"), []byte("fake"), 0644)
+	loc, _ := m.AddLocation(songsDir, "Test Songs")
+	m.ScanLocation(loc.ID)
+	songs, _ := m.SearchSongs("Song1", 1)
+	if len(songs) != 1 {
+		t.Fatalf("Expected 1 song, got %d", len(songs))
+	}
+
+	m.RecordSongPlayed(songs[0].ID, "alice")
+	m.RecordSongPlayed(songs[0].ID, "bob")
+	m.RecordSongPlayed(songs[0].ID, "bob")
+	// Last week's songs don't count tonight
+	m.db.Exec(`INSERT INTO song_history (song_id, martyn_key, song_title, sung_at) VALUES (?, 'carol', 'Old', datetime('now', '-7 days'))`, songs[0].ID)
+
+	top, err := m.TopSingers(time.Now().Add(-12*time.Hour), 5)
+	if err != nil {
+		t.Fatalf("Failed to get top singers: %v", err)
+	}
+	if len(top) != 2 {
+		t.Fatalf("Expected 2 singers tonight, got %v", top)
+	}
+	if top[0].MartynKey != "bob" || top[0].Songs != 2 || top[1].MartynKey != "alice" {
+		t.Errorf("Expected bob (2) then alice, got %v", top)
+	}
+}
+
+func TestRecordQueuedSongPlayed(t *testing.T) {
+	tmpDir := t.TempDir()
+	m, err := NewManager(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer m.Close()
+
+	songsDir := filepath.Join(tmpDir, "songs")
+	os.Mkdir(songsDir, 0755)
+	os.WriteFile(filepath.Join(songsDir, "Song1.mp4"), []byte("fake"), 0644)
+	loc, _ := m.AddLocation(songsDir, "Test Songs")
+	m.ScanLocation(loc.ID)
+	songs, _ := m.SearchSongs("Song1", 1)
+	if len(songs) != 1 {
+		t.Fatalf("Expected 1 song, got %d", len(songs))
+	}
+
+	// A YouTube video isn't in the library but still goes in the history
+	video := models.Song{ID: "yt-dQw4w9WgXcQ", Title: "Never Gonna Give You Up", Artist: "Rick Astley"}
+	if _, err := m.RecordQueuedSongPlayed(video, "alice"); err != nil {
+		t.Fatalf("Failed to record a YouTube song: %v", err)
+	}
+	library := models.Song{ID: songs[0].ID, Title: songs[0].Title, Artist: songs[0].Artist}
+	if _, err := m.RecordQueuedSongPlayed(library, "alice"); err != nil {
+		t.Fatalf("Failed to record a library song: %v", err)
+	}
+
+	history, _ := m.GetUserHistory("alice", 10)
+	if len(history) != 2 {
+		t.Fatalf("Expected both performances in the history, got %v", history)
+	}
+	titles := map[string]bool{history[0].SongTitle: true, history[1].SongTitle: true}
+	if !titles["Never Gonna Give You Up"] || !titles[songs[0].Title] {
+		t.Errorf("Expected both songs by title, got %v", history)
+	}
+	if song, _ := m.GetSong(songs[0].ID); song.TimesSung != 1 {
+		t.Errorf("Expected the library song's stats updated, got %d", song.TimesSung)
+	}
+}
+
+// =============================================================================
+// Tag Reading Tests
+// =============================================================================
+
+// writeID3File writes a minimal MP3 with an ID3v2.3 header containing the given text frames
+func writeID3File(t *testing.T, path string, frames map[string]string) {
+	t.Helper()
+	var body []byte
+	for id, text := range frames {
+		data := append([]byte{0}, []byte(text)...) // ISO-8859-1 encoding
+		size := len(data)
+		body = append(body, []byte(id)...)
+		body = append(body, byte(size>>24), byte(size>>16), byte(size>>8), byte(size), 0, 0)
+		body = append(body, data...)
+	}
+	size := len(body)
+	header := []byte{'I', 'D', '3', 3, 0, 0,
+		byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	content := append(header, body...)
+	content = append(content, []byte("fake mp3 audio")...)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("Failed to write tagged file: %v", err)
+	}
+}
+
+func TestReadTagsID3v2(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Artist - Song.mp3")
+	writeID3File(t, path, map[string]string{
+		"TALB": "Greatest Hits",
+		"TCON": "(17)",
+		"TYER": "1985",
+		"TLAN": "ENG",
+	})
+
+	tags := readTags(path)
+	if tags.Album != "Greatest Hits" {
+		t.Errorf("Expected album 'Greatest Hits', got '%s'", tags.Album)
+	}
+	if tags.Genre != "Rock" {
+		t.Errorf("Expected genre 'Rock', got '%s'", tags.Genre)
+	}
+	if tags.Year != 1985 {
+		t.Errorf("Expected year 1985, got %d", tags.Year)
+	}
+	if tags.Language != "eng" {
+		t.Errorf("Expected language 'eng', got '%s'", tags.Language)
+	}
+}
+
+func TestReadTagsID3v1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Song.mp3")
+	trailer := make([]byte, 128)
+	copy(trailer, "TAG")
+	copy(trailer[63:], "Old Album")
+	copy(trailer[93:], "1972")
+	trailer[127] = 2 // Country
+	os.WriteFile(path, append([]byte("fake mp3 audio"), trailer...), 0644)
+
+	tags := readTags(path)
+	if tags.Album != "Old Album" {
+		t.Errorf("Expected album 'Old Album', got '%s'", tags.Album)
+	}
+	if tags.Genre != "Country" {
+		t.Errorf("Expected genre 'Country', got '%s'", tags.Genre)
+	}
+	if tags.Year != 1972 {
+		t.Errorf("Expected year 1972, got %d", tags.Year)
+	}
+}
+
+func TestReadTagsUntaggedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Song.mp3")
+	os.WriteFile(path, []byte("fake"), 0644)
+
+	if tags := readTags(path); tags != (fileTags{}) {
+		t.Errorf("Expected empty tags, got %+v", tags)
+	}
+}
+
+func TestScanFillsTagMetadata(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	m, err := NewManager(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer m.Close()
+
+	songsDir := filepath.Join(tmpDir, "songs")
+	os.Mkdir(songsDir, 0755)
+	writeID3File(t, filepath.Join(songsDir, "Queen - Under Pressure.mp3"), map[string]string{
+		"TCON": "Rock",
+		"TDRC": "1981-10-26",
+	})
+
+	loc, _ := m.AddLocation(songsDir, "Test Songs")
+	m.ScanLocation(loc.ID)
+
+	songs, _ := m.SearchSongs("Under Pressure", 10)
+	if len(songs) != 1 {
+		t.Fatalf("Expected 1 song, got %d", len(songs))
+	}
+	if songs[0].Genre != "Rock" {
+		t.Errorf("Expected genre 'Rock', got '%s'", songs[0].Genre)
+	}
+	if songs[0].Year != 1981 {
+		t.Errorf("Expected year 1981, got %d", songs[0].Year)
+	}
+}
+
+// =============================================================================
+// Browse Tests
+// =============================================================================
+
+// newBrowseTestManager creates a manager with a small tagged library for browse tests
+func newBrowseTestManager(t *testing.T) *Manager {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	m, err := NewManager(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	songsDir := filepath.Join(tmpDir, "songs")
+	os.Mkdir(songsDir, 0755)
+
+	songs := []struct {
+		name, genre, year string
+	}{
+		{"ABBA - Dancing Queen.mp3", "Pop", "1976"},
+		{"ABBA - Waterloo.mp3", "Pop", "1974"},
+		{"Queen - Bohemian Rhapsody.mp3", "Rock", "1975"},
+		{"Queen - Under Pressure.mp3", "Rock", "1981"},
+		{"Toto - Africa.mp3", "Rock", "1982"},
+		{"Adele - Hello.mp3", "Pop", "2015"},
+		{"Blink-182 - 99 Red Balloons.mp3", "", ""},
+	}
+	for _, s := range songs {
+		frames := map[string]string{}
+		if s.genre != "" {
+			frames["TCON"] = s.genre
+		}
+		if s.year != "" {
+			frames["TYER"] = s.year
+		}
+		writeID3File(t, filepath.Join(songsDir, s.name), frames)
+	}
+
+	loc, _ := m.AddLocation(songsDir, "Test Songs")
+	if _, err := m.ScanLocation(loc.ID); err != nil {
+		t.Fatalf("Failed to scan location: %v", err)
+	}
+	return m
+}
+
+func TestBrowseGroupsByArtist(t *testing.T) {
+	m := newBrowseTestManager(t)
+
+	groups, total, err := m.BrowseGroups(BrowseByArtist, BrowseFilter{}, 2, 0)
+	if err != nil {
+		t.Fatalf("Failed to browse artists: %v", err)
+	}
+	if total != 5 {
+		t.Errorf("Expected 5 artists, got %d", total)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("Expected page of 2 artists, got %d", len(groups))
+	}
+	if groups[0].Name != "ABBA" || groups[0].Count != 2 {
+		t.Errorf("Expected first group ABBA (2), got %s (%d)", groups[0].Name, groups[0].Count)
+	}
+
+	// Second page continues where the first left off
+	page2, _, _ := m.BrowseGroups(BrowseByArtist, BrowseFilter{}, 2, 2)
+	if len(page2) != 2 || page2[0].Name != "Blink-182" {
+		t.Errorf("Expected second page to start with Blink-182, got %+v", page2)
+	}
+}
+
+func TestBrowseGroupsByDecade(t *testing.T) {
+	m := newBrowseTestManager(t)
+
+	groups, total, err := m.BrowseGroups(BrowseByDecade, BrowseFilter{}, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to browse decades: %v", err)
+	}
+	if total != 3 {
+		t.Fatalf("Expected 3 decades, got %d", total)
+	}
+
+	expected := []BrowseGroup{{"1970s", 3}, {"1980s", 2}, {"2010s", 1}}
+	for i, g := range expected {
+		if groups[i] != g {
+			t.Errorf("Expected group %d to be %+v, got %+v", i, g, groups[i])
+		}
+	}
+}
+
+func TestBrowseGroupsByLetter(t *testing.T) {
+	m := newBrowseTestManager(t)
+
+	groups, _, err := m.BrowseGroups(BrowseByLetter, BrowseFilter{}, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to browse letters: %v", err)
+	}
+
+	counts := make(map[string]int)
+	for _, g := range groups {
+		counts[g.Name] = g.Count
+	}
+	if counts["#"] != 1 {
+		t.Errorf("Expected 1 song under '#', got %d", counts["#"])
+	}
+	if counts["A"] != 1 {
+		t.Errorf("Expected 1 song under 'A', got %d", counts["A"])
+	}
+	if counts["U"] != 1 {
+		t.Errorf("Expected 1 song under 'U', got %d", counts["U"])
+	}
+}
+
+func TestBrowseGroupsCombineWithFilter(t *testing.T) {
+	m := newBrowseTestManager(t)
+
+	groups, total, err := m.BrowseGroups(BrowseByArtist, BrowseFilter{Genre: "rock", Decade: 1980}, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to browse artists: %v", err)
+	}
+	if total != 2 {
+		t.Fatalf("Expected 2 rock artists in the 1980s, got %d", total)
+	}
+	if groups[0].Name != "Queen" || groups[1].Name != "Toto" {
+		t.Errorf("Expected Queen and Toto, got %+v", groups)
+	}
+}
+
+func TestBrowseSongsCombinesSearchAndFilters(t *testing.T) {
+	m := newBrowseTestManager(t)
+
+	songs, total, err := m.BrowseSongs(BrowseFilter{Query: "queen", Genre: "Pop"}, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to browse songs: %v", err)
+	}
+	if total != 1 || len(songs) != 1 {
+		t.Fatalf("Expected 1 song, got %d (total %d)", len(songs), total)
+	}
+	if songs[0].Title != "Dancing Queen" {
+		t.Errorf("Expected 'Dancing Queen', got '%s'", songs[0].Title)
+	}
+
+	songs, total, _ = m.BrowseSongs(BrowseFilter{Artist: "queen", Letter: "b"}, 0, 0)
+	if total != 1 || songs[0].Title != "Bohemian Rhapsody" {
+		t.Errorf("Expected only 'Bohemian Rhapsody', got %d songs", total)
+	}
+}
+
+func TestBrowseUnknownDimension(t *testing.T) {
+	m := newBrowseTestManager(t)
+
+	if _, _, err := m.BrowseGroups("mood", BrowseFilter{}, 0, 0); err == nil {
+		t.Error("Expected error for unknown browse dimension")
+	}
+}
+
+func TestParseDecade(t *testing.T) {
+	cases := map[string]int{"1980": 1980, "1980s": 1980, "1987": 1980, "80s": 1980, "10s": 2010, "pop": 0}
+	for in, expected := range cases {
+		if got := ParseDecade(in); got != expected {
+			t.Errorf("ParseDecade(%q): expected %d, got %d", in, expected, got)
+		}
+	}
+}
+
+// =============================================================================
+// Metadata Editing Tests
+// =============================================================================
+
+// newMetadataTestManager creates a manager with one scanned location
+func newMetadataTestManager(t *testing.T, files ...string) (*Manager, int64) {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	m, err := NewManager(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	songsDir := filepath.Join(tmpDir, "songs")
+	os.Mkdir(songsDir, 0755)
+	for _, f := range files {
+		os.WriteFile(filepath.Join(songsDir, f), []byte("fake"), 0644)
+	}
+
+	loc, _ := m.AddLocation(songsDir, "Test Songs")
+	if _, err := m.ScanLocation(loc.ID); err != nil {
+		t.Fatalf("Failed to scan location: %v", err)
+	}
+	return m, loc.ID
+}
+
+func strPtr(s string) *string { return &s }
+
+func TestUpdateSongMetadata(t *testing.T) {
+	m, _ := newMetadataTestManager(t, "Bohemian Rhapsody - Queen.mp4")
+
+	songs, _ := m.SearchSongs("Queen", 10)
+	if len(songs) != 1 {
+		t.Fatalf("Expected 1 song, got %d", len(songs))
+	}
+
+	explicit := true
+	year := 1975
+	song, err := m.UpdateSongMetadata(songs[0].ID, SongMetadataEdit{
+		Title:    strPtr("Bohemian Rhapsody"),
+		Artist:   strPtr("Queen"),
+		Genre:    strPtr("Rock"),
+		Year:     &year,
+		Language: strPtr("EN"),
+		Explicit: &explicit,
+	})
+	if err != nil {
+		t.Fatalf("Failed to update metadata: %v", err)
+	}
+
+	if song.Title != "Bohemian Rhapsody" || song.Artist != "Queen" {
+		t.Errorf("Expected 'Queen - Bohemian Rhapsody', got '%s - %s'", song.Artist, song.Title)
+	}
+	if song.Genre != "Rock" || song.Year != 1975 || song.Language != "en" || !song.Explicit {
+		t.Errorf("Unexpected metadata: %+v", song)
+	}
+	if len(song.ManualFields) != 6 {
+		t.Errorf("Expected 6 manual fields, got %v", song.ManualFields)
+	}
+}
+
+func TestUpdateSongMetadataRejectsEmptyTitle(t *testing.T) {
+	m, _ := newMetadataTestManager(t, "Song.mp4")
+
+	songs, _ := m.SearchSongs("Song", 10)
+	if _, err := m.UpdateSongMetadata(songs[0].ID, SongMetadataEdit{Title: strPtr("  ")}); err == nil {
+		t.Error("Expected error for empty title")
+	}
+}
+
+func TestManualEditsSurviveRescan(t *testing.T) {
+	m, locID := newMetadataTestManager(t, "Bohemian Rhapsody - Queen.mp4")
+
+	songs, _ := m.SearchSongs("Queen", 10)
+	id := songs[0].ID
+	m.UpdateSongMetadata(id, SongMetadataEdit{
+		Title:  strPtr("Bohemian Rhapsody"),
+		Artist: strPtr("Queen"),
+	})
+
+	if _, err := m.ScanLocation(locID); err != nil {
+		t.Fatalf("Failed to rescan: %v", err)
+	}
+
+	song, _ := m.GetSong(id)
+	if song.Title != "Bohemian Rhapsody" || song.Artist != "Queen" {
+		t.Errorf("Expected manual edit to survive rescan, got '%s - %s'", song.Artist, song.Title)
+	}
+}
+
+func TestRevertSongMetadata(t *testing.T) {
+	m, _ := newMetadataTestManager(t, "Queen - Bohemian Rhapsody.mp4")
+
+	songs, _ := m.SearchSongs("Queen", 10)
+	id := songs[0].ID
+	m.UpdateSongMetadata(id, SongMetadataEdit{
+		Title: strPtr("Bo Rhap"),
+		Genre: strPtr("Rock"),
+	})
+
+	song, err := m.RevertSongMetadata(id, []string{FieldTitle})
+	if err != nil {
+		t.Fatalf("Failed to revert metadata: %v", err)
+	}
+	if song.Title != "Bohemian Rhapsody" {
+		t.Errorf("Expected title to revert to 'Bohemian Rhapsody', got '%s'", song.Title)
+	}
+	if song.Genre != "Rock" {
+		t.Errorf("Expected genre edit to be kept, got '%s'", song.Genre)
+	}
+	if len(song.ManualFields) != 1 || song.ManualFields[0] != FieldGenre {
+		t.Errorf("Expected only genre to remain manual, got %v", song.ManualFields)
+	}
+}
+
+// =============================================================================
+// Metadata Rule Tests
+// =============================================================================
+
+func TestRuleStripsVendorPrefix(t *testing.T) {
+	m, locID := newMetadataTestManager(t,
+		"SC8123-05 - Queen - Bohemian Rhapsody.cdg",
+		"SC8123-05 - Queen - Bohemian Rhapsody.mp3",
+		"SC8123-06 - Toto - Africa.mp4",
+	)
+
+	// Before the rule the vendor code ends up as the artist
+	songs, _ := m.SearchSongs("Africa", 10)
+	if songs[0].Artist != "SC8123-06" {
+		t.Fatalf("Expected vendor code as artist before rule, got '%s'", songs[0].Artist)
+	}
+
+	rule, changed, err := m.AddRule(MetadataRule{
+		LibraryID: locID,
+		Field:     RuleFieldFilename,
+		Pattern:   `^SC\d+-\d+\s*-\s*`,
+	})
+	if err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	if rule.ID == 0 {
+		t.Error("Expected rule to have an ID")
+	}
+	if changed != 2 {
+		t.Errorf("Expected 2 songs changed, got %d", changed)
+	}
+
+	songs, _ = m.SearchSongs("Africa", 10)
+	if songs[0].Artist != "Toto" || songs[0].Title != "Africa" {
+		t.Errorf("Expected 'Toto - Africa', got '%s - %s'", songs[0].Artist, songs[0].Title)
+	}
+	songs, _ = m.SearchSongs("Bohemian", 10)
+	if songs[0].Artist != "Queen" || songs[0].Title != "Bohemian Rhapsody" {
+		t.Errorf("Expected 'Queen - Bohemian Rhapsody', got '%s - %s'", songs[0].Artist, songs[0].Title)
+	}
+
+	// Rules are re-applied on rescan, not undone by it
+	m.ScanLocation(locID)
+	songs, _ = m.SearchSongs("Africa", 10)
+	if songs[0].Artist != "Toto" {
+		t.Errorf("Expected rule to apply after rescan, got artist '%s'", songs[0].Artist)
+	}
+}
+
+func TestRuleSkipsManualFields(t *testing.T) {
+	m, locID := newMetadataTestManager(t, "SC1 - Toto - Africa.mp4")
+
+	songs, _ := m.SearchSongs("Africa", 10)
+	m.UpdateSongMetadata(songs[0].ID, SongMetadataEdit{Artist: strPtr("TOTO")})
+
+	m.AddRule(MetadataRule{LibraryID: locID, Field: RuleFieldFilename, Pattern: `^SC\d+ - `})
+
+	song, _ := m.GetSong(songs[0].ID)
+	if song.Artist != "TOTO" {
+		t.Errorf("Expected manual artist to be kept, got '%s'", song.Artist)
+	}
+	if song.Title != "Africa" {
+		t.Errorf("Expected rule to fix title, got '%s'", song.Title)
+	}
+}
+
+func TestPreviewRuleDoesNotSave(t *testing.T) {
+	m, locID := newMetadataTestManager(t, "Toto - Africa (Karaoke Version).mp4")
+
+	changes, err := m.PreviewRule(MetadataRule{
+		LibraryID: locID,
+		Field:     RuleFieldTitle,
+		Pattern:   `\s*\(Karaoke Version\)`,
+	})
+	if err != nil {
+		t.Fatalf("Failed to preview rule: %v", err)
+	}
+	if len(changes) != 1 || changes[0].NewTitle != "Africa" {
+		t.Fatalf("Expected 1 change to 'Africa', got %+v", changes)
+	}
+
+	rules, _ := m.GetRules(locID)
+	if len(rules) != 0 {
+		t.Errorf("Expected preview not to save a rule, got %d rules", len(rules))
+	}
+	songs, _ := m.SearchSongs("Africa", 10)
+	if songs[0].Title != "Africa (Karaoke Version)" {
+		t.Errorf("Expected title unchanged by preview, got '%s'", songs[0].Title)
+	}
+}
+
+func TestDeleteRuleRestoresTitles(t *testing.T) {
+	m, locID := newMetadataTestManager(t, "Toto - Africa (Karaoke Version).mp4")
+
+	rule, _, _ := m.AddRule(MetadataRule{
+		LibraryID: locID,
+		Field:     RuleFieldTitle,
+		Pattern:   `\s*\(Karaoke Version\)`,
+	})
+
+	if _, err := m.DeleteRule(locID, rule.ID); err != nil {
+		t.Fatalf("Failed to delete rule: %v", err)
+	}
+	songs, _ := m.SearchSongs("Africa", 10)
+	if songs[0].Title != "Africa (Karaoke Version)" {
+		t.Errorf("Expected original title after deleting rule, got '%s'", songs[0].Title)
+	}
+}
+
+func TestAddRuleValidation(t *testing.T) {
+	m, locID := newMetadataTestManager(t)
+
+	if _, _, err := m.AddRule(MetadataRule{LibraryID: locID, Field: "album", Pattern: "x"}); err == nil {
+		t.Error("Expected error for unsupported rule field")
+	}
+	if _, _, err := m.AddRule(MetadataRule{LibraryID: locID, Field: RuleFieldTitle, Pattern: "("}); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+}
+
+// =============================================================================
+// Recommendation Tests
+// =============================================================================
+
+// recordSungAt records a performance and backdates it so it is not "recent"
+func recordSungAt(t *testing.T, m *Manager, songID, martynKey, age string) {
+	t.Helper()
+	historyID, err := m.RecordSongPlayed(songID, martynKey)
+	if err != nil {
+		t.Fatalf("Failed to record song: %v", err)
+	}
+	m.db.Exec("UPDATE song_history SET sung_at = datetime('now', ?) WHERE id = ?", age, historyID)
+	m.db.Exec("UPDATE library_songs SET last_sung_at = datetime('now', ?) WHERE id = ?", age, songID)
+}
+
+// songIDByTitle looks up a scanned song's ID
+func songIDByTitle(t *testing.T, m *Manager, title string) string {
+	t.Helper()
+	songs, _ := m.SearchSongs(title, 10)
+	if len(songs) == 0 {
+		t.Fatalf("Song %q not found", title)
+	}
+	return songs[0].ID
+}
+
+func hasRecommendation(recs []Recommendation, title, reason string) bool {
+	for _, r := range recs {
+		if r.Song.Title == title {
+			for _, rr := range r.Reasons {
+				if rr == reason {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func TestRecommendFromSimilarSingersAndArtist(t *testing.T) {
+	m, _ := newMetadataTestManager(t,
+		"Queen - Bohemian Rhapsody.mp4",
+		"Queen - Under Pressure.mp4",
+		"Toto - Africa.mp4",
+		"Adele - Hello.mp4",
+	)
+	rhapsody := songIDByTitle(t, m, "Bohemian Rhapsody")
+	africa := songIDByTitle(t, m, "Africa")
+
+	// Alice sang Bohemian Rhapsody last week; Bob sang it and Africa
+	recordSungAt(t, m, rhapsody, "alice", "-7 days")
+	recordSungAt(t, m, rhapsody, "bob", "-7 days")
+	recordSungAt(t, m, africa, "bob", "-7 days")
+
+	recs, err := m.Recommend("alice", RecommendOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get recommendations: %v", err)
+	}
+
+	if !hasRecommendation(recs, "Africa", ReasonSimilarSingers) {
+		t.Errorf("Expected Africa via similar singers, got %+v", recs)
+	}
+	if !hasRecommendation(recs, "Under Pressure", ReasonArtist) {
+		t.Errorf("Expected Under Pressure via artist affinity, got %+v", recs)
+	}
+	if !hasRecommendation(recs, "Bohemian Rhapsody", ReasonHistory) {
+		t.Errorf("Expected Bohemian Rhapsody via history, got %+v", recs)
+	}
+	if hasRecommendation(recs, "Hello", ReasonArtist) {
+		t.Error("Did not expect Hello to be recommended by artist")
+	}
+}
+
+func TestRecommendExcludesRecentlySung(t *testing.T) {
+	m, _ := newMetadataTestManager(t, "Toto - Africa.mp4", "Toto - Rosanna.mp4")
+	africa := songIDByTitle(t, m, "Africa")
+	rosanna := songIDByTitle(t, m, "Rosanna")
+
+	recordSungAt(t, m, rosanna, "alice", "-7 days")
+	m.RecordSongPlayed(africa, "bob") // Sung in the room just now
+
+	recs, err := m.Recommend("alice", RecommendOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get recommendations: %v", err)
+	}
+	for _, r := range recs {
+		if r.Song.ID == africa {
+			t.Error("Expected recently sung song to be excluded")
+		}
+	}
+	if !hasRecommendation(recs, "Rosanna", ReasonHistory) {
+		t.Errorf("Expected Rosanna from history, got %+v", recs)
+	}
+}
+
+func TestRecommendTrendingTonight(t *testing.T) {
+	m, _ := newMetadataTestManager(t, "Toto - Africa.mp4", "Adele - Hello.mp4")
+	hello := songIDByTitle(t, m, "Hello")
+
+	m.LogSongSelection(hello, "Hello", "Adele", "library", "adele", "carol", "")
+	m.LogSongSelection(hello, "Hello", "Adele", "library", "adele", "dave", "")
+
+	recs, err := m.Recommend("newcomer", RecommendOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get recommendations: %v", err)
+	}
+	if len(recs) == 0 || recs[0].Song.ID != hello {
+		t.Fatalf("Expected trending song first, got %+v", recs)
+	}
+	if !hasRecommendation(recs, "Hello", ReasonTrending) {
+		t.Error("Expected trending reason")
+	}
+}
+
+func TestRecommendExcludeIDsAndLimit(t *testing.T) {
+	m, _ := newMetadataTestManager(t, "Toto - Africa.mp4", "Toto - Rosanna.mp4", "Toto - Hold the Line.mp4")
+	africa := songIDByTitle(t, m, "Africa")
+	for _, title := range []string{"Africa", "Rosanna", "Hold the Line"} {
+		recordSungAt(t, m, songIDByTitle(t, m, title), "alice", "-2 days")
+	}
+
+	recs, _ := m.Recommend("alice", RecommendOptions{Limit: 1, ExcludeIDs: []string{africa}})
+	if len(recs) != 1 {
+		t.Fatalf("Expected 1 recommendation, got %d", len(recs))
+	}
+	if recs[0].Song.ID == africa {
+		t.Error("Expected excluded song to be left out")
+	}
+}
+
+// =============================================================================
+// Loudness Tests
+// =============================================================================
+
+func TestSetLoudness(t *testing.T) {
+	m, _ := newMetadataTestManager(t, "Toto - Africa.mp4", "Adele - Hello.mp4")
+	africa := songIDByTitle(t, m, "Africa")
+
+	pending, err := m.SongsNeedingLoudness()
+	if err != nil {
+		t.Fatalf("Failed to list songs needing loudness: %v", err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("Expected 2 unanalyzed songs, got %d", len(pending))
+	}
+	if pending[0].LoudnessLUFS != nil {
+		t.Error("Expected no loudness before analysis")
+	}
+
+	if err := m.SetLoudness(africa, -11.5, -0.3); err != nil {
+		t.Fatalf("Failed to set loudness: %v", err)
+	}
+	song, _ := m.GetSong(africa)
+	if song.LoudnessLUFS == nil || *song.LoudnessLUFS != -11.5 || *song.TruePeakDBTP != -0.3 {
+		t.Errorf("Expected -11.5 LUFS / -0.3 dBTP, got %v / %v", song.LoudnessLUFS, song.TruePeakDBTP)
+	}
+	if err := m.SetSilence(africa, 2.5, 4); err != nil {
+		t.Fatalf("Failed to set silence: %v", err)
+	}
+	if song, _ := m.GetSong(africa); song.LeadingSilence != 2.5 || song.TrailingSilence != 4 {
+		t.Errorf("Expected 2.5s / 4s of silence, got %.1f / %.1f", song.LeadingSilence, song.TrailingSilence)
+	}
+
+	pending, _ = m.SongsNeedingLoudness()
+	if len(pending) != 1 || pending[0].Title != "Hello" {
+		t.Errorf("Expected only Hello left to analyze, got %+v", pending)
+	}
+
+	// Rescans keep measurements
+	if _, err := m.ScanLocation(song.LibraryID); err != nil {
+		t.Fatalf("Rescan failed: %v", err)
+	}
+	if song, _ := m.GetSong(africa); song.LoudnessLUFS == nil {
+		t.Error("Expected loudness to survive a rescan")
+	}
+
+	m.ResetLoudness()
+	if pending, _ := m.SongsNeedingLoudness(); len(pending) != 2 {
+		t.Errorf("Expected reset to queue every song again, got %d", len(pending))
+	}
+	if song, _ := m.GetSong(africa); song.LeadingSilence != 0 {
+		t.Errorf("Expected reset to clear silence, got %.1f", song.LeadingSilence)
+	}
+}
+
+// =============================================================================
+// Query Observer Tests
+// =============================================================================
+
+func TestQueryObserverTimesOperations(t *testing.T) {
+	m, err := NewManager(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer m.Close()
+
+	var ops []string
+	m.SetQueryObserver(func(op string, took time.Duration) {
+		ops = append(ops, op)
+	})
+
+	m.SearchSongs("anything", 10)
+	m.GetSong("missing")
+	m.LogSearch("anything", "library", 0, "key", "127.0.0.1")
+
+	want := []string{"search", "get_song", "log_search"}
+	if strings.Join(ops, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected ops %v, got %v", want, ops)
+	}
+}
+
+// =============================================================================
+// Reaction Tests
+// =============================================================================
+
+func TestSetHistoryReactions(t *testing.T) {
+	m, _ := newMetadataTestManager(t, "Toto - Africa.mp4")
+	africa := songIDByTitle(t, m, "Africa")
+
+	historyID, err := m.RecordSongPlayed(africa, "alice")
+	if err != nil {
+		t.Fatalf("Failed to record song: %v", err)
+	}
+	m.RecordSongPlayed(africa, "alice") // A second performance with no reactions
+
+	if err := m.SetHistoryReactions(historyID, map[string]int{"clap": 3, "fire": 1}); err != nil {
+		t.Fatalf("SetHistoryReactions failed: %v", err)
+	}
+
+	history, err := m.GetUserHistory("alice", 10)
+	if err != nil {
+		t.Fatalf("GetUserHistory failed: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 history entries, got %d", len(history))
+	}
+	for _, h := range history {
+		if h.ID == historyID {
+			if h.Reactions["clap"] != 3 || h.Reactions["fire"] != 1 || len(h.Reactions) != 2 {
+				t.Errorf("Expected clap 3 and fire 1, got %v", h.Reactions)
+			}
+		} else if len(h.Reactions) != 0 {
+			t.Errorf("Expected no reactions on the other performance, got %v", h.Reactions)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package library
+
+import (
+	"songmartyn/pkg/models"
+)
+
+// SongsNeedingLoudness returns songs that have not been loudness-analyzed yet
+func (m *Manager) SongsNeedingLoudness() ([]models.LibrarySong, error) {
+	rows, err := m.db.Query(`
+		SELECT ` + songColumns + `
+		FROM library_songs WHERE loudness_analyzed_at IS NULL
+		ORDER BY times_sung DESC, title
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var songs []models.LibrarySong
+	for rows.Next() {
+		song, err := scanSong(rows)
+		if err != nil {
+			continue
+		}
+		songs = append(songs, song)
+	}
+	return songs, rows.Err()
+}
+
+// SetLoudness stores a song's measured integrated loudness and true peak
+func (m *Manager) SetLoudness(songID string, integratedLUFS, truePeakDBTP float64) error {
+	_, err := m.db.Exec(`
+		UPDATE library_songs
+		SET loudness_lufs = ?, true_peak_dbtp = ?, loudness_analyzed_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, integratedLUFS, truePeakDBTP, songID)
+	return err
+}
+
+// SetSilence stores how many seconds of silence a song has before and after its audio
+func (m *Manager) SetSilence(songID string, leading, trailing float64) error {
+	_, err := m.db.Exec(`
+		UPDATE library_songs SET leading_silence = ?, trailing_silence = ? WHERE id = ?
+	`, leading, trailing, songID)
+	return err
+}
+
+// ResetLoudness clears all measurements so the next analysis run re-measures every song
+func (m *Manager) ResetLoudness() error {
+	_, err := m.db.Exec(`
+		UPDATE library_songs
+		SET loudness_lufs = NULL, true_peak_dbtp = NULL, loudness_analyzed_at = NULL,
+		    leading_silence = NULL, trailing_silence = NULL
+	`)
+	return err
+}
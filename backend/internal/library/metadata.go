@@ -0,0 +1,429 @@
+package library
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"songmartyn/pkg/models"
+)
+
+// Editable metadata fields
+const (
+	FieldTitle     = "title"
+	FieldArtist    = "artist"
+	FieldAlbum     = "album"
+	FieldGenre     = "genre"
+	FieldYear      = "year"
+	FieldLanguage  = "language"
+	FieldExplicit  = "explicit"
+	FieldThumbnail = "thumbnail_url"
+)
+
+// Rule fields: "filename" rewrites the base name before it is split into artist/title
+const (
+	RuleFieldFilename = "filename"
+	RuleFieldTitle    = FieldTitle
+	RuleFieldArtist   = FieldArtist
+)
+
+var editableFields = map[string]bool{
+	FieldTitle: true, FieldArtist: true, FieldAlbum: true, FieldGenre: true,
+	FieldYear: true, FieldLanguage: true, FieldExplicit: true, FieldThumbnail: true,
+}
+
+// SongMetadataEdit is a partial metadata update; nil fields are left unchanged
+type SongMetadataEdit struct {
+	Title        *string `json:"title,omitempty"`
+	Artist       *string `json:"artist,omitempty"`
+	Album        *string `json:"album,omitempty"`
+	Genre        *string `json:"genre,omitempty"`
+	Year         *int    `json:"year,omitempty"`
+	Language     *string `json:"language,omitempty"`
+	Explicit     *bool   `json:"explicit,omitempty"`
+	ThumbnailURL *string `json:"thumbnail_url,omitempty"`
+}
+
+// MetadataRule rewrites filename-derived metadata with a regex across a library location
+type MetadataRule struct {
+	ID          int64     `json:"id"`
+	LibraryID   int64     `json:"library_id"`
+	Field       string    `json:"field"` // filename, title or artist
+	Pattern     string    `json:"pattern"`
+	Replacement string    `json:"replacement"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// MetadataChange describes how a rule changes a song (used for previews)
+type MetadataChange struct {
+	SongID    string `json:"song_id"`
+	OldTitle  string `json:"old_title"`
+	OldArtist string `json:"old_artist"`
+	NewTitle  string `json:"new_title"`
+	NewArtist string `json:"new_artist"`
+}
+
+// compiledRule is a MetadataRule with its pattern compiled
+type compiledRule struct {
+	MetadataRule
+	re *regexp.Regexp
+}
+
+// keepManual returns an upsert SET clause that keeps manually edited values
+func keepManual(field string) string {
+	return fmt.Sprintf(
+		"%[1]s = CASE WHEN instr(library_songs.manual_fields, ',%[1]s,') > 0 THEN library_songs.%[1]s ELSE excluded.%[1]s END",
+		field,
+	)
+}
+
+// splitManualFields parses the stored ",title,artist," form
+func splitManualFields(s string) []string {
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		if f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// joinManualFields stores fields in the ",title,artist," form used by keepManual
+func joinManualFields(fields map[string]bool) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	list := make([]string, 0, len(fields))
+	for f := range fields {
+		list = append(list, f)
+	}
+	sort.Strings(list)
+	return "," + strings.Join(list, ",") + ","
+}
+
+// UpdateSongMetadata applies an admin edit and marks the edited fields as manual
+func (m *Manager) UpdateSongMetadata(id string, edit SongMetadataEdit) (*models.LibrarySong, error) {
+	song, err := m.GetSong(id)
+	if err != nil {
+		return nil, err
+	}
+
+	manual := make(map[string]bool)
+	for _, f := range song.ManualFields {
+		manual[f] = true
+	}
+
+	var sets []string
+	var args []interface{}
+	set := func(field string, value interface{}) {
+		sets = append(sets, field+" = ?")
+		args = append(args, value)
+		manual[field] = true
+	}
+
+	if edit.Title != nil {
+		title := strings.TrimSpace(*edit.Title)
+		if title == "" {
+			return nil, fmt.Errorf("title cannot be empty")
+		}
+		set(FieldTitle, title)
+	}
+	if edit.Artist != nil {
+		set(FieldArtist, strings.TrimSpace(*edit.Artist))
+	}
+	if edit.Album != nil {
+		set(FieldAlbum, strings.TrimSpace(*edit.Album))
+	}
+	if edit.Genre != nil {
+		set(FieldGenre, strings.TrimSpace(*edit.Genre))
+	}
+	if edit.Year != nil {
+		if *edit.Year < 0 || *edit.Year > 9999 {
+			return nil, fmt.Errorf("invalid year: %d", *edit.Year)
+		}
+		set(FieldYear, *edit.Year)
+	}
+	if edit.Language != nil {
+		set(FieldLanguage, strings.ToLower(strings.TrimSpace(*edit.Language)))
+	}
+	if edit.Explicit != nil {
+		set(FieldExplicit, *edit.Explicit)
+	}
+	if edit.ThumbnailURL != nil {
+		set(FieldThumbnail, strings.TrimSpace(*edit.ThumbnailURL))
+	}
+
+	if len(sets) == 0 {
+		return song, nil
+	}
+
+	sets = append(sets, "manual_fields = ?")
+	args = append(args, joinManualFields(manual), id)
+
+	_, err = m.db.Exec("UPDATE library_songs SET "+strings.Join(sets, ", ")+" WHERE id = ?", args...)
+	if err != nil {
+		return nil, err
+	}
+	return m.GetSong(id)
+}
+
+// RevertSongMetadata drops manual edits for the given fields (all fields if empty)
+// and restores the values a scan would produce
+func (m *Manager) RevertSongMetadata(id string, fields []string) (*models.LibrarySong, error) {
+	song, err := m.GetSong(id)
+	if err != nil {
+		return nil, err
+	}
+
+	revert := make(map[string]bool)
+	for _, f := range fields {
+		if !editableFields[f] {
+			return nil, fmt.Errorf("unknown field: %s", f)
+		}
+		revert[f] = true
+	}
+	if len(revert) == 0 {
+		for f := range editableFields {
+			revert[f] = true
+		}
+	}
+
+	manual := make(map[string]bool)
+	for _, f := range song.ManualFields {
+		if !revert[f] {
+			manual[f] = true
+		}
+	}
+
+	// Recompute scanned values
+	rules, err := m.GetRules(song.LibraryID)
+	if err != nil {
+		return nil, err
+	}
+	title, artist := deriveTitleArtist(song.FilePath, compileRules(rules))
+	tagPath := song.FilePath
+	if song.AudioPath != "" {
+		tagPath = song.AudioPath
+	}
+	tags := readTags(tagPath)
+
+	scanned := map[string]interface{}{
+		FieldTitle:     title,
+		FieldArtist:    artist,
+		FieldAlbum:     tags.Album,
+		FieldGenre:     tags.Genre,
+		FieldYear:      tags.Year,
+		FieldLanguage:  tags.Language,
+		FieldExplicit:  false,
+		FieldThumbnail: "",
+	}
+
+	var sets []string
+	var args []interface{}
+	for f := range revert {
+		sets = append(sets, f+" = ?")
+		args = append(args, scanned[f])
+	}
+	sets = append(sets, "manual_fields = ?")
+	args = append(args, joinManualFields(manual), id)
+
+	_, err = m.db.Exec("UPDATE library_songs SET "+strings.Join(sets, ", ")+" WHERE id = ?", args...)
+	if err != nil {
+		return nil, err
+	}
+	return m.GetSong(id)
+}
+
+// compileRules compiles rule patterns, skipping any that no longer compile
+func compileRules(rules []MetadataRule) []compiledRule {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, r := range rules {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			continue
+		}
+		compiled = append(compiled, compiledRule{MetadataRule: r, re: re})
+	}
+	return compiled
+}
+
+// deriveTitleArtist parses a file path into title/artist, applying location rules in order
+func deriveTitleArtist(path string, rules []compiledRule) (title, artist string) {
+	base := filepath.Base(path)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+
+	for _, r := range rules {
+		if r.Field == RuleFieldFilename {
+			name = r.re.ReplaceAllString(name, r.Replacement)
+		}
+	}
+	title, artist = parseName(name)
+
+	for _, r := range rules {
+		switch r.Field {
+		case RuleFieldTitle:
+			title = strings.TrimSpace(r.re.ReplaceAllString(title, r.Replacement))
+		case RuleFieldArtist:
+			artist = strings.TrimSpace(r.re.ReplaceAllString(artist, r.Replacement))
+		}
+	}
+
+	// A rule must never leave a song without a title
+	if title == "" {
+		title = strings.TrimSpace(name)
+	}
+	return title, artist
+}
+
+// validateRule checks a rule's field and pattern
+func validateRule(rule MetadataRule) error {
+	switch rule.Field {
+	case RuleFieldFilename, RuleFieldTitle, RuleFieldArtist:
+	default:
+		return fmt.Errorf("rule field must be filename, title or artist, got %q", rule.Field)
+	}
+	if rule.Pattern == "" {
+		return fmt.Errorf("rule pattern is required")
+	}
+	if _, err := regexp.Compile(rule.Pattern); err != nil {
+		return fmt.Errorf("invalid rule pattern: %v", err)
+	}
+	return nil
+}
+
+// GetRules returns the metadata rules for a location in the order they apply
+func (m *Manager) GetRules(libraryID int64) ([]MetadataRule, error) {
+	rows, err := m.db.Query(`
+		SELECT id, library_id, field, pattern, replacement, created_at
+		FROM metadata_rules WHERE library_id = ? ORDER BY id
+	`, libraryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []MetadataRule
+	for rows.Next() {
+		var r MetadataRule
+		if err := rows.Scan(&r.ID, &r.LibraryID, &r.Field, &r.Pattern, &r.Replacement, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// AddRule saves a metadata rule for a location and applies it to existing songs
+// Returns the saved rule and the number of songs changed
+func (m *Manager) AddRule(rule MetadataRule) (*MetadataRule, int, error) {
+	if err := validateRule(rule); err != nil {
+		return nil, 0, err
+	}
+
+	result, err := m.db.Exec(`
+		INSERT INTO metadata_rules (library_id, field, pattern, replacement)
+		VALUES (?, ?, ?, ?)
+	`, rule.LibraryID, rule.Field, rule.Pattern, rule.Replacement)
+	if err != nil {
+		return nil, 0, err
+	}
+	rule.ID, _ = result.LastInsertId()
+	rule.CreatedAt = time.Now()
+
+	changed, err := m.ApplyRules(rule.LibraryID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &rule, changed, nil
+}
+
+// DeleteRule removes a metadata rule and re-applies the remaining rules for its location
+func (m *Manager) DeleteRule(libraryID, ruleID int64) (int, error) {
+	result, err := m.db.Exec("DELETE FROM metadata_rules WHERE id = ? AND library_id = ?", ruleID, libraryID)
+	if err != nil {
+		return 0, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return 0, fmt.Errorf("rule not found: %d", ruleID)
+	}
+	return m.ApplyRules(libraryID)
+}
+
+// PreviewRule reports the changes a new rule would make without saving it
+func (m *Manager) PreviewRule(rule MetadataRule) ([]MetadataChange, error) {
+	if err := validateRule(rule); err != nil {
+		return nil, err
+	}
+	rules, err := m.GetRules(rule.LibraryID)
+	if err != nil {
+		return nil, err
+	}
+	return m.ruleChanges(rule.LibraryID, compileRules(append(rules, rule)))
+}
+
+// ApplyRules re-derives titles and artists for a location from its rules
+// Manually edited fields are left alone. Returns the number of songs changed
+func (m *Manager) ApplyRules(libraryID int64) (int, error) {
+	rules, err := m.GetRules(libraryID)
+	if err != nil {
+		return 0, err
+	}
+	changes, err := m.ruleChanges(libraryID, compileRules(rules))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, c := range changes {
+		_, err := m.db.Exec(
+			"UPDATE library_songs SET title = ?, artist = ? WHERE id = ?",
+			c.NewTitle, c.NewArtist, c.SongID,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(changes), nil
+}
+
+// ruleChanges computes title/artist changes for every song in a location
+func (m *Manager) ruleChanges(libraryID int64, rules []compiledRule) ([]MetadataChange, error) {
+	rows, err := m.db.Query(`
+		SELECT id, title, artist, file_path, manual_fields
+		FROM library_songs WHERE library_id = ?
+		ORDER BY title
+	`, libraryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []MetadataChange{}
+	for rows.Next() {
+		var id, title, artist, filePath, manualFields string
+		if err := rows.Scan(&id, &title, &artist, &filePath, &manualFields); err != nil {
+			return nil, err
+		}
+
+		newTitle, newArtist := deriveTitleArtist(filePath, rules)
+		if strings.Contains(manualFields, ","+FieldTitle+",") {
+			newTitle = title
+		}
+		if strings.Contains(manualFields, ","+FieldArtist+",") {
+			newArtist = artist
+		}
+
+		if newTitle != title || newArtist != artist {
+			changes = append(changes, MetadataChange{
+				SongID:    id,
+				OldTitle:  title,
+				OldArtist: artist,
+				NewTitle:  newTitle,
+				NewArtist: newArtist,
+			})
+		}
+	}
+	return changes, nil
+}
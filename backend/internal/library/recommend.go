@@ -0,0 +1,282 @@
+package library
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"songmartyn/pkg/models"
+)
+
+// Recommendation reasons
+const (
+	ReasonHistory        = "history"         // The singer has sung it before
+	ReasonSimilarSingers = "similar_singers" // Singers with overlapping history sang it
+	ReasonArtist         = "artist"          // By an artist the singer likes
+	ReasonTrending       = "trending"        // Sung or picked in the room tonight
+	ReasonPopular        = "popular"         // All-time popular (cold start fallback)
+)
+
+// Signal weights for recommendation scoring
+const (
+	weightHistory  = 1.0 // Per previous performance, capped at maxHistoryHits
+	weightSimilar  = 3.0 // Scaled by singer similarity (0-1)
+	weightArtist   = 0.5 // Per affinity hit for the artist, capped at maxArtistHits
+	weightTrending = 1.0 // Per play/selection tonight, capped at maxTrendingHits
+	weightPopular  = 0.1
+
+	maxHistoryHits  = 3
+	maxArtistHits   = 4
+	maxTrendingHits = 5
+
+	// Bound the number of candidate songs pulled per artist
+	artistCandidateLimit = 200
+)
+
+// Recommendation is a suggested song with the signals that picked it
+type Recommendation struct {
+	Song    models.LibrarySong `json:"song"`
+	Score   float64            `json:"score"`
+	Reasons []string           `json:"reasons"`
+}
+
+// RecommendOptions tunes Recommend; zero values use defaults
+type RecommendOptions struct {
+	Limit          int           // Max results (default 10)
+	RecentWindow   time.Duration // Skip songs sung in the room within this window (default 3h)
+	TrendingWindow time.Duration // How far back counts as "tonight" (default 6h)
+	ExcludeIDs     []string      // Songs to leave out, e.g. already queued
+}
+
+// candidate accumulates score for a song
+type candidate struct {
+	score   float64
+	reasons map[string]bool
+}
+
+// Recommend suggests library songs for a singer from their history, similar
+// singers, artist affinity and tonight's trends
+func (m *Manager) Recommend(martynKey string, opts RecommendOptions) ([]Recommendation, error) {
+	defer m.observeQuery.Time("recommend")()
+	if opts.Limit <= 0 {
+		opts.Limit = 10
+	}
+	if opts.RecentWindow <= 0 {
+		opts.RecentWindow = 3 * time.Hour
+	}
+	if opts.TrendingWindow <= 0 {
+		opts.TrendingWindow = 6 * time.Hour
+	}
+
+	candidates := make(map[string]*candidate)
+	add := func(songID string, score float64, reason string) {
+		c, ok := candidates[songID]
+		if !ok {
+			c = &candidate{reasons: make(map[string]bool)}
+			candidates[songID] = c
+		}
+		c.score += score
+		c.reasons[reason] = true
+	}
+
+	// Signal 1: songs the singer has sung before
+	history, err := m.countRows(`
+		SELECT song_id, COUNT(*) FROM song_history
+		WHERE martyn_key = ? GROUP BY song_id
+	`, martynKey)
+	if err != nil {
+		return nil, err
+	}
+	for songID, n := range history {
+		add(songID, weightHistory*float64(minInt(n, maxHistoryHits)), ReasonHistory)
+	}
+
+	// Signal 2: songs sung by singers whose history overlaps
+	if len(history) > 0 {
+		if err := m.addSimilarSingerSignal(martynKey, history, add); err != nil {
+			return nil, err
+		}
+	}
+
+	// Signal 3: artist affinity from performances and selections
+	artists, err := m.countRows(`
+		SELECT artist, SUM(n) FROM (
+			SELECT song_artist AS artist, COUNT(*) AS n FROM song_history
+			WHERE martyn_key = ? AND song_artist != '' GROUP BY song_artist
+			UNION ALL
+			SELECT song_artist AS artist, COUNT(*) AS n FROM song_selections
+			WHERE martyn_key = ? AND song_artist != '' GROUP BY song_artist
+		) GROUP BY artist COLLATE NOCASE
+	`, martynKey, martynKey)
+	if err != nil {
+		return nil, err
+	}
+	for artist, n := range artists {
+		rows, err := m.db.Query(
+			"SELECT id FROM library_songs WHERE artist = ? COLLATE NOCASE LIMIT ?",
+			artist, artistCandidateLimit,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var songID string
+			if err := rows.Scan(&songID); err == nil {
+				add(songID, weightArtist*float64(minInt(n, maxArtistHits)), ReasonArtist)
+			}
+		}
+		rows.Close()
+	}
+
+	// Signal 4: trending tonight (performances and library picks by anyone)
+	since := time.Now().UTC().Add(-opts.TrendingWindow).Format("2006-01-02 15:04:05")
+	trending, err := m.countRows(`
+		SELECT song_id, SUM(n) FROM (
+			SELECT song_id, COUNT(*) AS n FROM song_history
+			WHERE sung_at >= ? GROUP BY song_id
+			UNION ALL
+			SELECT song_id, COUNT(*) AS n FROM song_selections
+			WHERE selected_at >= ? AND source = 'library' GROUP BY song_id
+		) GROUP BY song_id
+	`, since, since)
+	if err != nil {
+		return nil, err
+	}
+	for songID, n := range trending {
+		add(songID, weightTrending*float64(minInt(n, maxTrendingHits)), ReasonTrending)
+	}
+
+	// Cold start: fall back to all-time popular songs
+	if len(candidates) < opts.Limit {
+		popular, err := m.GetPopularSongs(opts.Limit * 3)
+		if err != nil {
+			return nil, err
+		}
+		for _, song := range popular {
+			add(song.ID, weightPopular*float64(song.TimesSung), ReasonPopular)
+		}
+	}
+
+	exclude := make(map[string]bool, len(opts.ExcludeIDs))
+	for _, id := range opts.ExcludeIDs {
+		exclude[id] = true
+	}
+	ids := make([]string, 0, len(candidates))
+	for id := range candidates {
+		if !exclude[id] {
+			ids = append(ids, id)
+		}
+	}
+
+	// Only fetch the strongest candidates; leave headroom for recently sung songs
+	sort.Slice(ids, func(i, j int) bool { return candidates[ids[i]].score > candidates[ids[j]].score })
+	if maxCandidates := opts.Limit*5 + 100; len(ids) > maxCandidates {
+		ids = ids[:maxCandidates]
+	}
+
+	songs, err := m.GetSongsByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	recentCutoff := time.Now().Add(-opts.RecentWindow)
+	recs := []Recommendation{}
+	for _, song := range songs {
+		// Skip songs the room has heard recently
+		if song.LastSungAt != nil && song.LastSungAt.After(recentCutoff) {
+			continue
+		}
+		c := candidates[song.ID]
+		recs = append(recs, Recommendation{
+			Song:    song,
+			Score:   c.score,
+			Reasons: sortedReasons(c.reasons),
+		})
+	}
+
+	sort.Slice(recs, func(i, j int) bool {
+		if recs[i].Score != recs[j].Score {
+			return recs[i].Score > recs[j].Score
+		}
+		if recs[i].Song.TimesSung != recs[j].Song.TimesSung {
+			return recs[i].Song.TimesSung > recs[j].Song.TimesSung
+		}
+		return strings.ToLower(recs[i].Song.Title) < strings.ToLower(recs[j].Song.Title)
+	})
+
+	if len(recs) > opts.Limit {
+		recs = recs[:opts.Limit]
+	}
+	return recs, nil
+}
+
+// addSimilarSingerSignal scores songs sung by singers who share songs with this singer
+func (m *Manager) addSimilarSingerSignal(martynKey string, history map[string]int, add func(string, float64, string)) error {
+	overlap, err := m.countRows(`
+		SELECT h.martyn_key, COUNT(DISTINCT h.song_id) FROM song_history h
+		WHERE h.martyn_key != ?
+		  AND h.song_id IN (SELECT song_id FROM song_history WHERE martyn_key = ?)
+		GROUP BY h.martyn_key
+	`, martynKey, martynKey)
+	if err != nil {
+		return err
+	}
+
+	for other, shared := range overlap {
+		similarity := float64(shared) / float64(len(history))
+		songs, err := m.countRows(`
+			SELECT song_id, COUNT(*) FROM song_history
+			WHERE martyn_key = ? GROUP BY song_id
+		`, other)
+		if err != nil {
+			return err
+		}
+		for songID := range songs {
+			if _, sungBefore := history[songID]; sungBefore {
+				continue
+			}
+			add(songID, weightSimilar*similarity, ReasonSimilarSingers)
+		}
+	}
+	return nil
+}
+
+// countRows runs a two-column (key, count) query into a map
+func (m *Manager) countRows(query string, args ...interface{}) (map[string]int, error) {
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var key string
+		var n int
+		if err := rows.Scan(&key, &n); err != nil {
+			return nil, err
+		}
+		counts[key] += n
+	}
+	return counts, rows.Err()
+}
+
+// sortedReasons returns reasons in a stable order, strongest signal first
+func sortedReasons(reasons map[string]bool) []string {
+	order := []string{ReasonHistory, ReasonSimilarSingers, ReasonArtist, ReasonTrending, ReasonPopular}
+	var list []string
+	for _, r := range order {
+		if reasons[r] {
+			list = append(list, r)
+		}
+	}
+	return list
+}
+
+// minInt returns the smaller of two ints
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
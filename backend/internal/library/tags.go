@@ -0,0 +1,269 @@
+package library
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// fileTags holds the metadata we care about from embedded file tags
+type fileTags struct {
+	Album    string
+	Genre    string
+	Year     int
+	Language string
+}
+
+// id3v1Genres is the standard ID3v1 genre table (indexes 0-79)
+var id3v1Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop",
+	"Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B", "Rap",
+	"Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska", "Death Metal", "Pranks",
+	"Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance",
+	"Classical", "Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative", "Instrumental Pop", "Instrumental Rock",
+	"Ethnic", "Gothic", "Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap", "Pop/Funk", "Jungle",
+	"Native American", "Cabaret", "New Wave", "Psychedelic", "Rave", "Showtunes", "Trailer", "Lo-Fi",
+	"Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+}
+
+// readTags reads ID3v2 (falling back to ID3v1) tags from an MP3 file
+// Files without tags, or of other formats, return empty tags
+func readTags(path string) fileTags {
+	if strings.ToLower(strings.TrimSpace(fileExt(path))) != ".mp3" {
+		return fileTags{}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fileTags{}
+	}
+	defer f.Close()
+
+	tags := readID3v2(f)
+
+	// Fill any gaps from the ID3v1 trailer
+	v1 := readID3v1(f)
+	if tags.Album == "" {
+		tags.Album = v1.Album
+	}
+	if tags.Genre == "" {
+		tags.Genre = v1.Genre
+	}
+	if tags.Year == 0 {
+		tags.Year = v1.Year
+	}
+
+	return tags
+}
+
+// fileExt returns the extension of a path (including the dot)
+func fileExt(path string) string {
+	if idx := strings.LastIndex(path, "."); idx >= 0 {
+		return path[idx:]
+	}
+	return ""
+}
+
+// readID3v2 parses text frames from an ID3v2.3 or ID3v2.4 header
+func readID3v2(r io.ReadSeeker) fileTags {
+	var tags fileTags
+
+	header := make([]byte, 10)
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return tags
+	}
+	if _, err := io.ReadFull(r, header); err != nil {
+		return tags
+	}
+	if string(header[:3]) != "ID3" {
+		return tags
+	}
+
+	version := header[3]
+	if version != 3 && version != 4 {
+		return tags
+	}
+	flags := header[5]
+	size := syncsafe(header[6:10])
+	if size <= 0 || size > 16<<20 {
+		return tags
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return tags
+	}
+
+	pos := 0
+	// Skip extended header if present
+	if flags&0x40 != 0 && len(body) >= 4 {
+		extSize := int(binary.BigEndian.Uint32(body[:4]))
+		if version == 4 {
+			extSize = syncsafe(body[:4])
+		} else {
+			extSize += 4 // v2.3 size excludes the size field itself
+		}
+		pos = extSize
+	}
+
+	for pos+10 <= len(body) {
+		id := string(body[pos : pos+4])
+		if id[0] == 0 {
+			break // Reached padding
+		}
+
+		var frameSize int
+		if version == 4 {
+			frameSize = syncsafe(body[pos+4 : pos+8])
+		} else {
+			frameSize = int(binary.BigEndian.Uint32(body[pos+4 : pos+8]))
+		}
+		pos += 10
+		if frameSize <= 0 || pos+frameSize > len(body) {
+			break
+		}
+		data := body[pos : pos+frameSize]
+		pos += frameSize
+
+		switch id {
+		case "TALB":
+			tags.Album = decodeTextFrame(data)
+		case "TCON":
+			tags.Genre = normalizeGenre(decodeTextFrame(data))
+		case "TYER", "TDRC":
+			tags.Year = parseYear(decodeTextFrame(data))
+		case "TLAN":
+			tags.Language = strings.ToLower(decodeTextFrame(data))
+		}
+	}
+
+	return tags
+}
+
+// readID3v1 parses the fixed 128-byte ID3v1 trailer
+func readID3v1(r io.ReadSeeker) fileTags {
+	var tags fileTags
+
+	if _, err := r.Seek(-128, io.SeekEnd); err != nil {
+		return tags
+	}
+	trailer := make([]byte, 128)
+	if _, err := io.ReadFull(r, trailer); err != nil {
+		return tags
+	}
+	if string(trailer[:3]) != "TAG" {
+		return tags
+	}
+
+	tags.Album = trimID3v1(trailer[63:93])
+	tags.Year = parseYear(trimID3v1(trailer[93:97]))
+	if g := int(trailer[127]); g < len(id3v1Genres) {
+		tags.Genre = id3v1Genres[g]
+	}
+	return tags
+}
+
+// trimID3v1 trims NUL padding and whitespace from a fixed-width field
+func trimID3v1(b []byte) string {
+	if idx := bytes.IndexByte(b, 0); idx >= 0 {
+		b = b[:idx]
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// syncsafe decodes a 4-byte ID3v2 syncsafe integer
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// decodeTextFrame decodes an ID3v2 text frame using its leading encoding byte
+func decodeTextFrame(data []byte) string {
+	if len(data) < 2 {
+		return ""
+	}
+	enc, text := data[0], data[1:]
+
+	var s string
+	switch enc {
+	case 1, 2: // UTF-16 with BOM / UTF-16BE
+		bigEndian := enc == 2
+		if len(text) >= 2 {
+			if text[0] == 0xFF && text[1] == 0xFE {
+				bigEndian = false
+				text = text[2:]
+			} else if text[0] == 0xFE && text[1] == 0xFF {
+				bigEndian = true
+				text = text[2:]
+			}
+		}
+		units := make([]uint16, 0, len(text)/2)
+		for i := 0; i+1 < len(text); i += 2 {
+			var u uint16
+			if bigEndian {
+				u = binary.BigEndian.Uint16(text[i:])
+			} else {
+				u = binary.LittleEndian.Uint16(text[i:])
+			}
+			if u == 0 {
+				break
+			}
+			units = append(units, u)
+		}
+		s = string(utf16.Decode(units))
+	case 3: // UTF-8
+		if idx := bytes.IndexByte(text, 0); idx >= 0 {
+			text = text[:idx]
+		}
+		s = string(text)
+	default: // ISO-8859-1
+		if idx := bytes.IndexByte(text, 0); idx >= 0 {
+			text = text[:idx]
+		}
+		runes := make([]rune, len(text))
+		for i, b := range text {
+			runes[i] = rune(b)
+		}
+		s = string(runes)
+	}
+	return strings.TrimSpace(s)
+}
+
+// normalizeGenre resolves numeric ID3 genre references like "(17)" or "17"
+func normalizeGenre(genre string) string {
+	g := strings.TrimSpace(genre)
+	if strings.HasPrefix(g, "(") {
+		if end := strings.Index(g, ")"); end > 0 {
+			if n, err := strconv.Atoi(g[1:end]); err == nil {
+				if rest := strings.TrimSpace(g[end+1:]); rest != "" {
+					return rest // "(17)Rock" - prefer the refinement text
+				}
+				if n >= 0 && n < len(id3v1Genres) {
+					return id3v1Genres[n]
+				}
+			}
+		}
+	}
+	if n, err := strconv.Atoi(g); err == nil && n >= 0 && n < len(id3v1Genres) {
+		return id3v1Genres[n]
+	}
+	return g
+}
+
+// parseYear extracts a four digit year from a date-like string ("1985", "1985-06-01")
+func parseYear(s string) int {
+	s = strings.TrimSpace(s)
+	if len(s) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(s[:4])
+	if err != nil || year < 1000 {
+		return 0
+	}
+	return year
+}
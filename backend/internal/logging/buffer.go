@@ -0,0 +1,167 @@
+package logging
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Attr is one key/value of an entry, in the order it was logged
+type Attr struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Entry is a log record as kept in the buffer and sent to the log viewer
+type Entry struct {
+	Seq       uint64    `json:"seq"` // Increases by one per entry, for resuming a tail
+	Time      time.Time `json:"time"`
+	Level     string    `json:"level"` // DEBUG, INFO, WARN or ERROR
+	Subsystem string    `json:"subsystem"`
+	Message   string    `json:"message"`
+	Attrs     []Attr    `json:"attrs,omitempty"`
+}
+
+// Filter picks entries for the log viewer; the zero value matches info and above
+type Filter struct {
+	MinLevel   slog.Level // Entries below this are skipped
+	Subsystems []string   // Empty = every subsystem
+	Contains   string     // Case-insensitive text in the message or attribute values
+	AfterSeq   uint64     // Only entries newer than this
+}
+
+// Match reports whether e passes the filter
+func (f Filter) Match(e Entry) bool {
+	if e.Seq <= f.AfterSeq {
+		return false
+	}
+	if level, err := ParseLevel(e.Level); err == nil && level < f.MinLevel {
+		return false
+	}
+	if len(f.Subsystems) > 0 {
+		found := false
+		for _, s := range f.Subsystems {
+			if s == e.Subsystem {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Contains != "" {
+		needle := strings.ToLower(f.Contains)
+		if strings.Contains(strings.ToLower(e.Message), needle) {
+			return true
+		}
+		for _, a := range e.Attrs {
+			if strings.Contains(strings.ToLower(a.Value), needle) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Buffer keeps the most recent entries and passes new ones to subscribers
+type Buffer struct {
+	mu      sync.Mutex
+	entries []Entry // Ring; oldest at start once full
+	start   int
+	size    int
+	seq     uint64
+	subs    map[chan Entry]struct{}
+}
+
+// NewBuffer creates a buffer keeping size entries
+func NewBuffer(size int) *Buffer {
+	if size < 1 {
+		size = 1
+	}
+	return &Buffer{size: size, subs: make(map[chan Entry]struct{})}
+}
+
+// Add numbers e, stores it and sends it to subscribers that keep up
+func (b *Buffer) Add(e Entry) Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e.Seq = b.seq
+	if len(b.entries) < b.size {
+		b.entries = append(b.entries, e)
+	} else {
+		b.entries[b.start] = e
+		b.start = (b.start + 1) % b.size
+	}
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default: // A slow viewer misses entries rather than stalling logging
+		}
+	}
+	return e
+}
+
+// ordered returns the entries oldest first; caller holds mu
+func (b *Buffer) ordered() []Entry {
+	out := make([]Entry, 0, len(b.entries))
+	out = append(out, b.entries[b.start:]...)
+	return append(out, b.entries[:b.start]...)
+}
+
+// Entries returns up to limit of the newest entries matching f, oldest first
+// A limit of 0 or less returns every match
+func (b *Buffer) Entries(f Filter, limit int) []Entry {
+	b.mu.Lock()
+	all := b.ordered()
+	b.mu.Unlock()
+
+	var matched []Entry
+	for _, e := range all {
+		if f.Match(e) {
+			matched = append(matched, e)
+		}
+	}
+	if limit > 0 && len(matched) > limit {
+		matched = matched[len(matched)-limit:]
+	}
+	return matched
+}
+
+// Resize changes how many entries are kept, dropping the oldest if it shrinks
+func (b *Buffer) Resize(size int) {
+	if size < 1 {
+		size = 1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	all := b.ordered()
+	if len(all) > size {
+		all = all[len(all)-size:]
+	}
+	b.entries = all
+	b.start = 0
+	b.size = size
+}
+
+// Subscribe returns a channel receiving every new entry and a func to stop
+// The channel holds backlog entries; when it's full new entries are dropped
+func (b *Buffer) Subscribe(backlog int) (<-chan Entry, func()) {
+	ch := make(chan Entry, backlog)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+		})
+	}
+}
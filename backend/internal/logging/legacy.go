@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// legacyWriter turns standard log output into entries, reading the "[MPV]" and
+// "[WS DEBUG]" style prefixes the code used before subsystem loggers
+type legacyWriter struct {
+	log *Log
+}
+
+// LegacyWriter returns a writer for log.SetOutput that sends standard log
+// output through the subsystem levels. Use it with log.SetFlags(0).
+func (l *Log) LegacyWriter() io.Writer {
+	return &legacyWriter{log: l}
+}
+
+func (w *legacyWriter) Write(p []byte) (int, error) {
+	subsystem, level, msg := parseLegacy(strings.TrimRight(string(p), "\n"))
+	logger := w.log.Logger(subsystem)
+	logger.Log(context.Background(), level, msg)
+	return len(p), nil
+}
+
+// legacyLevels are level words that may follow a prefix, as in "[WS DEBUG]"
+var legacyLevels = map[string]slog.Level{
+	"DEBUG": slog.LevelDebug,
+	"INFO":  slog.LevelInfo,
+	"WARN":  slog.LevelWarn,
+	"ERROR": slog.LevelError,
+}
+
+// parseLegacy splits a standard log line into subsystem, level and message
+//
+//	"[MPV] Started"           -> mpv, info
+//	"[WS DEBUG] Connected"    -> ws, debug
+//	"[DEBUG] Countdown"       -> app, debug
+//	"Warning: No display"     -> app, warn
+//	"Failed to load: ..."     -> app, error
+func parseLegacy(line string) (string, slog.Level, string) {
+	subsystem, level, msg := DefaultSubsystem, slog.LevelInfo, line
+
+	if strings.HasPrefix(line, "[") {
+		if end := strings.Index(line, "]"); end > 0 {
+			words := strings.Fields(line[1:end])
+			msg = strings.TrimSpace(line[end+1:])
+			for i, word := range words {
+				if l, ok := legacyLevels[strings.ToUpper(word)]; ok {
+					level = l
+				} else if i == 0 {
+					subsystem = strings.ToLower(word)
+				}
+			}
+			return subsystem, level, msg
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(line, "Warning: "):
+		return subsystem, slog.LevelWarn, strings.TrimPrefix(line, "Warning: ")
+	case strings.HasPrefix(line, "Failed"), strings.HasPrefix(line, "Error"):
+		return subsystem, slog.LevelError, msg
+	}
+	return subsystem, level, msg
+}
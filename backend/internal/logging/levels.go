@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Levels is the minimum level logged, with overrides per subsystem
+type Levels struct {
+	mu         sync.RWMutex
+	def        slog.Level
+	subsystems map[string]slog.Level
+}
+
+// NewLevels creates levels logging def and above everywhere
+func NewLevels(def slog.Level) *Levels {
+	return &Levels{def: def, subsystems: make(map[string]slog.Level)}
+}
+
+// Enabled reports whether subsystem logs at level
+func (l *Levels) Enabled(subsystem string, level slog.Level) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	min, ok := l.subsystems[subsystem]
+	if !ok {
+		min = l.def
+	}
+	return level >= min
+}
+
+// Default returns the level for subsystems without an override
+func (l *Levels) Default() slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.def
+}
+
+// Overrides returns a copy of the per-subsystem levels
+func (l *Levels) Overrides() map[string]slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	overrides := make(map[string]slog.Level, len(l.subsystems))
+	for name, level := range l.subsystems {
+		overrides[name] = level
+	}
+	return overrides
+}
+
+// SetDefault changes the level for subsystems without an override
+func (l *Levels) SetDefault(level slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.def = level
+}
+
+// Set overrides the level for one subsystem
+func (l *Levels) Set(subsystem string, level slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.subsystems[subsystem] = level
+}
+
+// Clear removes a subsystem's override so it follows the default again
+func (l *Levels) Clear(subsystem string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.subsystems, subsystem)
+}
+
+// Replace sets the default and swaps every override for overrides
+func (l *Levels) Replace(def slog.Level, overrides map[string]slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.def = def
+	l.subsystems = make(map[string]slog.Level, len(overrides))
+	for name, level := range overrides {
+		l.subsystems[name] = level
+	}
+}
+
+// ParseOverrides reads per-subsystem levels written as "ws=debug,mpv=warn"
+func ParseOverrides(spec string) (map[string]slog.Level, error) {
+	overrides := make(map[string]slog.Level)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, levelName, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%q should be subsystem=level", part)
+		}
+		level, err := ParseLevel(levelName)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		overrides[name] = level
+	}
+	return overrides, nil
+}
+
+// FormatOverrides writes per-subsystem levels the way ParseOverrides reads them, sorted
+func FormatOverrides(overrides map[string]slog.Level) string {
+	parts := make([]string, 0, len(overrides))
+	for name, level := range overrides {
+		parts = append(parts, name+"="+strings.ToLower(level.String()))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,179 @@
+// Package logging is SongMartyn's structured logger: log/slog loggers per subsystem
+// ("ws", "mpv", ...) with their own levels, a ring buffer of recent entries for the
+// admin log viewer, and a bridge for code still using the standard log package.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// DefaultBufferSize is how many entries the ring buffer keeps unless configured
+const DefaultBufferSize = 2000
+
+// DefaultSubsystem is where standard log output without a prefix goes
+const DefaultSubsystem = "app"
+
+// Default is the process-wide log every For logger writes to
+var Default = New(os.Stderr, DefaultBufferSize)
+
+// For returns the logger for a subsystem of the process-wide log
+func For(subsystem string) *slog.Logger {
+	return Default.Logger(subsystem)
+}
+
+// Log routes entries from every subsystem through the level filter to the output and buffer
+type Log struct {
+	Levels *Levels
+	Buffer *Buffer
+
+	mu         sync.Mutex // Guards out and subsystems
+	out        io.Writer
+	subsystems map[string]bool
+}
+
+// New creates a log writing text lines to out and keeping size recent entries
+func New(out io.Writer, size int) *Log {
+	return &Log{
+		Levels:     NewLevels(slog.LevelInfo),
+		Buffer:     NewBuffer(size),
+		out:        out,
+		subsystems: make(map[string]bool),
+	}
+}
+
+// SetOutput changes where text lines are written
+func (l *Log) SetOutput(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out = w
+}
+
+// Logger returns a logger for subsystem
+func (l *Log) Logger(subsystem string) *slog.Logger {
+	l.mu.Lock()
+	l.subsystems[subsystem] = true
+	l.mu.Unlock()
+	return slog.New(&handler{log: l, subsystem: subsystem})
+}
+
+// Subsystems lists every subsystem that has a logger, sorted
+func (l *Log) Subsystems() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	names := make([]string, 0, len(l.subsystems))
+	for name := range l.subsystems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// write records an entry and prints it
+func (l *Log) write(e Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e = l.Buffer.Add(e)
+
+	var b strings.Builder
+	b.WriteString(e.Time.Format("2006/01/02 15:04:05 "))
+	fmt.Fprintf(&b, "%-5s %s: %s", e.Level, e.Subsystem, e.Message)
+	for _, a := range e.Attrs {
+		b.WriteString(" " + a.Key + "=" + quoteIfNeeded(a.Value))
+	}
+	b.WriteByte('\n')
+	io.WriteString(l.out, b.String())
+}
+
+func quoteIfNeeded(s string) string {
+	if s == "" || strings.IndexFunc(s, func(r rune) bool { return unicode.IsSpace(r) || r == '"' || r == '=' }) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// handler is the slog.Handler behind every subsystem logger
+type handler struct {
+	log       *Log
+	subsystem string
+	attrs     []Attr
+	group     string // Prefix for attribute keys, from WithGroup
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.log.Levels.Enabled(h.subsystem, level)
+}
+
+func (h *handler) Handle(_ context.Context, r slog.Record) error {
+	e := Entry{
+		Time:      r.Time,
+		Level:     r.Level.String(),
+		Subsystem: h.subsystem,
+		Message:   r.Message,
+		Attrs:     append([]Attr(nil), h.attrs...),
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		e.Attrs = appendAttr(e.Attrs, h.group, a)
+		return true
+	})
+	h.log.write(e)
+	return nil
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.attrs = append([]Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		next.attrs = appendAttr(next.attrs, h.group, a)
+	}
+	return &next
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	next := *h
+	next.group = h.group + name + "."
+	return &next
+}
+
+// appendAttr flattens a, prefixing keys with the group path
+func appendAttr(attrs []Attr, group string, a slog.Attr) []Attr {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		prefix := group
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			attrs = appendAttr(attrs, prefix, ga)
+		}
+		return attrs
+	}
+	if a.Key == "" {
+		return attrs
+	}
+	return append(attrs, Attr{Key: group + a.Key, Value: v.String()})
+}
+
+// ParseLevel reads a level name: debug, info, warn or error (any case)
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (use debug, info, warn or error)", s)
+	}
+	return level, nil
+}
@@ -0,0 +1,230 @@
+package logging
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// Logger Tests
+// =============================================================================
+
+func TestSubsystemLevels(t *testing.T) {
+	var out bytes.Buffer
+	l := New(&out, 10)
+	ws := l.Logger("ws")
+	mpv := l.Logger("mpv")
+
+	ws.Debug("connection attempt")
+	mpv.Info("started", "pid", 42)
+	if strings.Contains(out.String(), "connection attempt") {
+		t.Error("Expected debug to be off by default")
+	}
+	if !strings.Contains(out.String(), "INFO  mpv: started pid=42\n") {
+		t.Errorf("Expected the mpv line, got %q", out.String())
+	}
+
+	l.Levels.Set("ws", slog.LevelDebug)
+	ws.Debug("connection attempt", "ip", "10.0.0.2")
+	mpv.Debug("position")
+	if !strings.Contains(out.String(), "DEBUG ws: connection attempt ip=10.0.0.2") {
+		t.Errorf("Expected ws debug after the override, got %q", out.String())
+	}
+	if strings.Contains(out.String(), "position") {
+		t.Error("Expected mpv to stay at info")
+	}
+
+	l.Levels.Clear("ws")
+	l.Levels.SetDefault(slog.LevelWarn)
+	if l.Levels.Enabled("ws", slog.LevelInfo) {
+		t.Error("Expected ws to follow the new default")
+	}
+
+	if got := strings.Join(l.Subsystems(), ","); got != "mpv,ws" {
+		t.Errorf("Expected subsystems mpv,ws, got %s", got)
+	}
+}
+
+func TestAttrsAndGroupsAreFlattened(t *testing.T) {
+	var out bytes.Buffer
+	l := New(&out, 10)
+	logger := l.Logger("ws").With("client", "10.0.0.2").WithGroup("msg")
+
+	logger.Info("received", "type", "queue_add", slog.Group("size", "bytes", 120))
+
+	entries := l.Buffer.Entries(Filter{}, 0)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+	want := []Attr{{"client", "10.0.0.2"}, {"msg.type", "queue_add"}, {"msg.size.bytes", "120"}}
+	if len(entries[0].Attrs) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, entries[0].Attrs)
+	}
+	for i, a := range want {
+		if entries[0].Attrs[i] != a {
+			t.Errorf("Expected %v, got %v", a, entries[0].Attrs[i])
+		}
+	}
+	if !strings.Contains(out.String(), `received client=10.0.0.2 msg.type=queue_add msg.size.bytes=120`) {
+		t.Errorf("Unexpected text line %q", out.String())
+	}
+}
+
+func TestValuesWithSpacesAreQuoted(t *testing.T) {
+	var out bytes.Buffer
+	l := New(&out, 10)
+	l.Logger("app").Info("playing", "title", "Take On Me")
+	if !strings.Contains(out.String(), `title="Take On Me"`) {
+		t.Errorf("Expected a quoted value, got %q", out.String())
+	}
+}
+
+// =============================================================================
+// Level Parsing Tests
+// =============================================================================
+
+func TestParseOverrides(t *testing.T) {
+	overrides, err := ParseOverrides(" ws=debug, mpv=WARN ,")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if overrides["ws"] != slog.LevelDebug || overrides["mpv"] != slog.LevelWarn {
+		t.Errorf("Unexpected overrides %v", overrides)
+	}
+	if got := FormatOverrides(overrides); got != "mpv=warn,ws=debug" {
+		t.Errorf("Expected mpv=warn,ws=debug, got %s", got)
+	}
+
+	for _, bad := range []string{"ws", "=debug", "ws=loud"} {
+		if _, err := ParseOverrides(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+// =============================================================================
+// Buffer Tests
+// =============================================================================
+
+func TestBufferKeepsNewestEntries(t *testing.T) {
+	b := NewBuffer(3)
+	for _, msg := range []string{"a", "b", "c", "d", "e"} {
+		b.Add(Entry{Level: "INFO", Subsystem: "app", Message: msg})
+	}
+
+	entries := b.Entries(Filter{}, 0)
+	if len(entries) != 3 || entries[0].Message != "c" || entries[2].Message != "e" {
+		t.Fatalf("Expected c,d,e, got %+v", entries)
+	}
+	if entries[2].Seq != 5 {
+		t.Errorf("Expected seq 5, got %d", entries[2].Seq)
+	}
+	if got := b.Entries(Filter{}, 2); len(got) != 2 || got[0].Message != "d" {
+		t.Errorf("Expected the 2 newest, got %+v", got)
+	}
+
+	b.Resize(2)
+	if got := b.Entries(Filter{}, 0); len(got) != 2 || got[0].Message != "d" {
+		t.Errorf("Expected d,e after shrinking, got %+v", got)
+	}
+	b.Add(Entry{Message: "f"})
+	b.Resize(4)
+	b.Add(Entry{Message: "g"})
+	b.Add(Entry{Message: "h"})
+	if got := b.Entries(Filter{}, 0); len(got) != 4 || got[0].Message != "e" || got[3].Message != "h" {
+		t.Errorf("Expected e,f,g,h after growing, got %+v", got)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	b := NewBuffer(10)
+	b.Add(Entry{Level: "DEBUG", Subsystem: "ws", Message: "connection attempt"})
+	b.Add(Entry{Level: "ERROR", Subsystem: "mpv", Message: "load failed", Attrs: []Attr{{"path", "/songs/Africa.mp4"}}})
+	b.Add(Entry{Level: "INFO", Subsystem: "ws", Message: "client connected"})
+
+	cases := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{"everything", Filter{MinLevel: slog.LevelDebug}, 3},
+		{"info and up", Filter{MinLevel: slog.LevelInfo}, 2},
+		{"subsystem", Filter{MinLevel: slog.LevelDebug, Subsystems: []string{"ws"}}, 2},
+		{"text in attrs", Filter{MinLevel: slog.LevelDebug, Contains: "africa"}, 1},
+		{"after seq", Filter{MinLevel: slog.LevelDebug, AfterSeq: 2}, 1},
+	}
+	for _, c := range cases {
+		if got := len(b.Entries(c.filter, 0)); got != c.want {
+			t.Errorf("%s: expected %d entries, got %d", c.name, c.want, got)
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	b := NewBuffer(10)
+	ch, stop := b.Subscribe(1)
+
+	b.Add(Entry{Message: "first"})
+	b.Add(Entry{Message: "dropped"}) // Channel full
+	if e := <-ch; e.Message != "first" {
+		t.Errorf("Expected first, got %s", e.Message)
+	}
+
+	stop()
+	stop() // Safe to call twice
+	b.Add(Entry{Message: "after stop"})
+	select {
+	case e := <-ch:
+		t.Errorf("Expected nothing after stop, got %s", e.Message)
+	default:
+	}
+}
+
+// =============================================================================
+// Legacy Bridge Tests
+// =============================================================================
+
+func TestLegacyPrefixes(t *testing.T) {
+	cases := []struct {
+		line, subsystem string
+		level           slog.Level
+		msg             string
+	}{
+		{"[MPV] Started with PID 12", "mpv", slog.LevelInfo, "Started with PID 12"},
+		{"[WS DEBUG] Connection attempt", "ws", slog.LevelDebug, "Connection attempt"},
+		{"[WS ERROR] Write failed", "ws", slog.LevelError, "Write failed"},
+		{"[DEBUG] startPlayCountdown called", "app", slog.LevelDebug, "startPlayCountdown called"},
+		{"[MPV Monitor] Stopped", "mpv", slog.LevelInfo, "Stopped"},
+		{"Warning: Target display not found", "app", slog.LevelWarn, "Target display not found"},
+		{"Failed to load song", "app", slog.LevelError, "Failed to load song"},
+		{"Client connected", "app", slog.LevelInfo, "Client connected"},
+	}
+	for _, c := range cases {
+		subsystem, level, msg := parseLegacy(c.line)
+		if subsystem != c.subsystem || level != c.level || msg != c.msg {
+			t.Errorf("%q: expected %s/%s/%q, got %s/%s/%q", c.line, c.subsystem, c.level, c.msg, subsystem, level, msg)
+		}
+	}
+}
+
+func TestLegacyWriterUsesLevels(t *testing.T) {
+	var out bytes.Buffer
+	l := New(&out, 10)
+	std := log.New(l.LegacyWriter(), "", 0)
+
+	std.Printf("[WS DEBUG] Connection attempt from %s", "10.0.0.2")
+	std.Printf("[BGM] Starting")
+	entries := l.Buffer.Entries(Filter{MinLevel: slog.LevelDebug}, 0)
+	if len(entries) != 1 || entries[0].Subsystem != "bgm" || entries[0].Message != "Starting" {
+		t.Fatalf("Expected only the bgm entry, got %+v", entries)
+	}
+
+	l.Levels.Set("ws", slog.LevelDebug)
+	std.Printf("[WS DEBUG] Connection attempt from %s", "10.0.0.3")
+	if got := l.Buffer.Entries(Filter{MinLevel: slog.LevelDebug, Subsystems: []string{"ws"}}, 0); len(got) != 1 {
+		t.Errorf("Expected the ws debug entry once enabled, got %+v", got)
+	}
+}
@@ -0,0 +1,94 @@
+package loudness
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultDecoder decodes any media file to the raw PCM the analyzer reads
+const DefaultDecoder = "ffmpeg -nostdin -v error -i {input} -vn -f s16le -ac 2 -ar 48000 -"
+
+// Decoder output format expected on stdout
+const (
+	DecoderSampleRate = 48000
+	DecoderChannels   = 2
+)
+
+// Analyzer measures media files, decoding them with an external command
+type Analyzer struct {
+	decoder []string
+}
+
+// NewAnalyzer creates an analyzer; decoder is a command line in which {input}
+// is replaced by the file path and which writes s16le stereo 48 kHz PCM to stdout
+func NewAnalyzer(decoder string) *Analyzer {
+	if strings.TrimSpace(decoder) == "" {
+		decoder = DefaultDecoder
+	}
+	return &Analyzer{decoder: strings.Fields(decoder)}
+}
+
+// Available reports whether the decoder command can be found
+func (a *Analyzer) Available() error {
+	if len(a.decoder) == 0 {
+		return fmt.Errorf("no loudness decoder configured")
+	}
+	if _, err := exec.LookPath(a.decoder[0]); err != nil {
+		return fmt.Errorf("loudness decoder %q not found: %w", a.decoder[0], err)
+	}
+	return nil
+}
+
+// Analyze measures a file; PCM WAV files are read directly without the decoder
+func (a *Analyzer) Analyze(ctx context.Context, path string) (Result, error) {
+	if strings.EqualFold(filepath.Ext(path), ".wav") {
+		if result, err := a.analyzeWAV(path); err == nil {
+			return result, nil
+		}
+		// Compressed or unusual WAV: fall back to the decoder
+	}
+
+	args := make([]string, len(a.decoder))
+	hasInput := false
+	for i, arg := range a.decoder {
+		if strings.Contains(arg, "{input}") {
+			hasInput = true
+		}
+		args[i] = strings.ReplaceAll(arg, "{input}", path)
+	}
+	if !hasInput {
+		args = append(args, path)
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return Result{}, err
+	}
+	if err := cmd.Start(); err != nil {
+		return Result{}, fmt.Errorf("starting decoder: %w", err)
+	}
+	result, measureErr := MeasurePCM(stdout, DecoderSampleRate, DecoderChannels)
+	if err := cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return Result{}, fmt.Errorf("decoder failed: %v: %s", err, msg)
+		}
+		return Result{}, fmt.Errorf("decoder failed: %w", err)
+	}
+	return result, measureErr
+}
+
+func (a *Analyzer) analyzeWAV(path string) (Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+	return MeasureWAV(f)
+}
@@ -0,0 +1,169 @@
+package loudness
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"songmartyn/internal/logging"
+	"songmartyn/pkg/models"
+)
+
+// logger is the "loudness" subsystem
+var logger = logging.For("loudness")
+
+// ErrJobRunning is returned when an analysis run is already in progress
+var ErrJobRunning = errors.New("loudness analysis already running")
+
+// Store is where the job finds unmeasured songs and saves results
+type Store interface {
+	SongsNeedingLoudness() ([]models.LibrarySong, error)
+	SetLoudness(songID string, integratedLUFS, truePeakDBTP float64) error
+	SetSilence(songID string, leading, trailing float64) error
+}
+
+// JobStatus reports the progress of an analysis run
+type JobStatus struct {
+	Running    bool       `json:"running"`
+	Total      int        `json:"total"`
+	Done       int        `json:"done"`
+	Failed     int        `json:"failed"`
+	Current    string     `json:"current,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Job measures every library song that has no loudness yet, one at a time
+type Job struct {
+	store    Store
+	analyzer *Analyzer
+
+	mu     sync.Mutex
+	status JobStatus
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewJob creates an analysis job
+func NewJob(store Store, analyzer *Analyzer) *Job {
+	return &Job{store: store, analyzer: analyzer}
+}
+
+// Start begins a run in the background
+func (j *Job) Start() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.status.Running {
+		return ErrJobRunning
+	}
+	if err := j.Available(); err != nil {
+		return err
+	}
+
+	songs, err := j.store.SongsNeedingLoudness()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	j.status = JobStatus{Running: true, Total: len(songs), StartedAt: &now}
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+	j.done = make(chan struct{})
+	go j.run(ctx, songs, j.done)
+	return nil
+}
+
+// Available reports whether songs can be analyzed (the decoder is installed)
+func (j *Job) Available() error {
+	return j.analyzer.Available()
+}
+
+// Stop cancels a run and waits for it to finish
+func (j *Job) Stop() {
+	j.mu.Lock()
+	cancel, done := j.cancel, j.done
+	j.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Wait blocks until the current run (if any) finishes
+func (j *Job) Wait() {
+	j.mu.Lock()
+	done := j.done
+	j.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+// Status returns a snapshot of the job's progress
+func (j *Job) Status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+func (j *Job) run(ctx context.Context, songs []models.LibrarySong, done chan struct{}) {
+	defer close(done)
+	if len(songs) > 0 {
+		logger.Info("Analyzing songs", "songs", len(songs))
+	}
+
+	for _, song := range songs {
+		if ctx.Err() != nil {
+			break
+		}
+		j.mu.Lock()
+		j.status.Current = song.Title
+		j.mu.Unlock()
+
+		err := j.analyze(ctx, song)
+
+		j.mu.Lock()
+		if err != nil && ctx.Err() == nil {
+			j.status.Failed++
+			j.status.LastError = song.Title + ": " + err.Error()
+			logger.Warn("Failed to analyze", "title", song.Title, "err", err)
+		} else if err == nil {
+			j.status.Done++
+		}
+		j.mu.Unlock()
+	}
+
+	j.mu.Lock()
+	now := time.Now()
+	j.status.Running = false
+	j.status.Current = ""
+	j.status.FinishedAt = &now
+	j.cancel = nil
+	if len(songs) > 0 {
+		logger.Info("Analysis finished", "measured", j.status.Done, "failed", j.status.Failed)
+	}
+	j.mu.Unlock()
+}
+
+func (j *Job) analyze(ctx context.Context, song models.LibrarySong) error {
+	result, err := j.analyzer.Analyze(ctx, SourcePath(song))
+	if err != nil {
+		return err
+	}
+	if err := j.store.SetLoudness(song.ID, result.IntegratedLUFS, result.TruePeakDBTP); err != nil {
+		return err
+	}
+	return j.store.SetSilence(song.ID, result.LeadingSilence, result.TrailingSilence)
+}
+
+// SourcePath returns the file whose audio is heard when a song plays
+// (the paired audio for CD+G songs, otherwise the song file itself)
+func SourcePath(song models.LibrarySong) string {
+	if song.CDGPath != "" && song.AudioPath != "" {
+		return song.AudioPath
+	}
+	return song.FilePath
+}
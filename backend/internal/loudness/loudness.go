@@ -0,0 +1,264 @@
+package loudness
+
+import (
+	"fmt"
+	"math"
+)
+
+// Measurement constants from EBU R128 / ITU-R BS.1770-4
+const (
+	AbsoluteGateLUFS = -70.0 // Blocks quieter than this are ignored (also reported for silence)
+	RelativeGateLU   = -10.0 // Blocks this far below the ungated mean are ignored
+	MaxTruePeakDBTP  = -1.0  // Normalization never pushes the true peak above this
+	MaxBoostDB       = 12.0  // Quiet tracks are boosted at most this much
+	SilenceLUFS      = -60.0 // 100ms sub-blocks quieter than this count as leading or trailing silence
+
+	blockSeconds = 0.4 // Gating block length
+	hopSeconds   = 0.1 // Gating blocks overlap by 75%
+)
+
+// Result holds the loudness of a track
+type Result struct {
+	IntegratedLUFS  float64 `json:"integrated_lufs"`
+	TruePeakDBTP    float64 `json:"true_peak_dbtp"`
+	LeadingSilence  float64 `json:"leading_silence"`  // Seconds before the audio starts
+	TrailingSilence float64 `json:"trailing_silence"` // Seconds after the audio ends
+}
+
+// GainDB returns the gain that brings a track to targetLUFS without its true peak
+// exceeding MaxTruePeakDBTP or boosting it by more than MaxBoostDB
+func GainDB(r Result, targetLUFS float64) float64 {
+	gain := targetLUFS - r.IntegratedLUFS
+	if headroom := MaxTruePeakDBTP - r.TruePeakDBTP; gain > headroom {
+		gain = headroom
+	}
+	if gain > MaxBoostDB {
+		gain = MaxBoostDB
+	}
+	return gain
+}
+
+// biquad is a direct form II transposed second-order filter
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.z1
+	f.z1 = f.b1*x - f.a1*y + f.z2
+	f.z2 = f.b2*x - f.a2*y
+	return y
+}
+
+// kWeighting returns the two BS.1770 pre-filter stages designed for sampleRate
+// (the same analogue prototypes libebur128 uses, so any rate is supported)
+func kWeighting(sampleRate int) (shelf, highPass biquad) {
+	rate := float64(sampleRate)
+
+	// Stage 1: high shelf modelling the acoustic effect of the head
+	f0, gain, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / rate)
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf = biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	// Stage 2: RLB high-pass
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / rate)
+	a0 = 1 + k/q + k*k
+	highPass = biquad{
+		b0: 1, b1: -2, b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	return shelf, highPass
+}
+
+// channelWeight returns the BS.1770 weighting for a channel; 5.1 layouts
+// (L R C LFE Ls Rs) drop the LFE and boost the surrounds
+func channelWeight(channel, channels int) float64 {
+	if channels == 6 {
+		switch channel {
+		case 3:
+			return 0
+		case 4, 5:
+			return 1.41
+		}
+	}
+	return 1
+}
+
+// Meter measures integrated loudness and true peak over a stream of samples
+type Meter struct {
+	channels int
+	weights  []float64
+	shelf    []biquad
+	highPass []biquad
+	peaks    []*peakDetector
+
+	hopFrames int       // Frames per 100ms sub-block
+	hopFill   int       // Frames in the current sub-block
+	hopSums   []float64 // Per-channel sum of squares in the current sub-block
+	hopEnergy []float64 // Weighted mean square of recent sub-blocks
+	blockHops int       // Sub-blocks per gating block
+	blocks    []float64 // Weighted mean square of each gating block
+	truePeak  float64
+
+	hops       int  // Sub-blocks finished
+	heard      bool // A sub-block above SilenceLUFS has been seen
+	firstSound int  // First sub-block above SilenceLUFS
+	lastSound  int  // Sub-block after the last one above SilenceLUFS
+}
+
+// NewMeter creates a meter for interleaved samples in [-1, 1]
+func NewMeter(sampleRate, channels int) (*Meter, error) {
+	if sampleRate < 8000 {
+		return nil, fmt.Errorf("unsupported sample rate: %d", sampleRate)
+	}
+	if channels < 1 || channels > 8 {
+		return nil, fmt.Errorf("unsupported channel count: %d", channels)
+	}
+	m := &Meter{
+		channels:  channels,
+		weights:   make([]float64, channels),
+		shelf:     make([]biquad, channels),
+		highPass:  make([]biquad, channels),
+		peaks:     make([]*peakDetector, channels),
+		hopFrames: int(float64(sampleRate) * hopSeconds),
+		hopSums:   make([]float64, channels),
+		blockHops: int(math.Round(blockSeconds / hopSeconds)),
+	}
+	for ch := 0; ch < channels; ch++ {
+		m.weights[ch] = channelWeight(ch, channels)
+		m.shelf[ch], m.highPass[ch] = kWeighting(sampleRate)
+		m.peaks[ch] = newPeakDetector()
+	}
+	return m, nil
+}
+
+// Write adds interleaved frames to the measurement; a trailing partial frame is ignored
+func (m *Meter) Write(samples []float64) {
+	frames := len(samples) / m.channels
+	for i := 0; i < frames; i++ {
+		frame := samples[i*m.channels : (i+1)*m.channels]
+		for ch, x := range frame {
+			if p := m.peaks[ch].add(x); p > m.truePeak {
+				m.truePeak = p
+			}
+			y := m.highPass[ch].process(m.shelf[ch].process(x))
+			m.hopSums[ch] += y * y
+		}
+		m.hopFill++
+		if m.hopFill == m.hopFrames {
+			m.finishHop()
+		}
+	}
+}
+
+// finishHop closes a 100ms sub-block and, once enough have arrived, a gating block
+func (m *Meter) finishHop() {
+	var energy float64
+	for ch, sum := range m.hopSums {
+		energy += m.weights[ch] * sum / float64(m.hopFrames)
+		m.hopSums[ch] = 0
+	}
+	m.hopFill = 0
+
+	if toLUFS(energy) > SilenceLUFS {
+		if !m.heard {
+			m.heard = true
+			m.firstSound = m.hops
+		}
+		m.lastSound = m.hops + 1
+	}
+	m.hops++
+
+	m.hopEnergy = append(m.hopEnergy, energy)
+	if len(m.hopEnergy) > m.blockHops {
+		m.hopEnergy = m.hopEnergy[1:]
+	}
+	if len(m.hopEnergy) == m.blockHops {
+		var block float64
+		for _, e := range m.hopEnergy {
+			block += e
+		}
+		m.blocks = append(m.blocks, block/float64(m.blockHops))
+	}
+}
+
+// Result returns the gated integrated loudness, true peak and silences measured so far.
+// Silent or very short input reports AbsoluteGateLUFS and no silence to trim.
+func (m *Meter) Result() Result {
+	r := Result{
+		IntegratedLUFS: integrated(m.blocks),
+		TruePeakDBTP:   toDB(m.truePeak),
+	}
+	if m.heard {
+		r.LeadingSilence = float64(m.firstSound) * hopSeconds
+		r.TrailingSilence = float64(m.hops-m.lastSound) * hopSeconds
+	}
+	return r
+}
+
+// integrated applies the absolute and relative gates to block energies
+func integrated(blocks []float64) float64 {
+	absGate := fromLUFS(AbsoluteGateLUFS)
+	var sum float64
+	var n int
+	for _, e := range blocks {
+		if e > absGate {
+			sum += e
+			n++
+		}
+	}
+	if n == 0 {
+		return AbsoluteGateLUFS
+	}
+
+	relGate := fromLUFS(toLUFS(sum/float64(n)) + RelativeGateLU)
+	sum, n = 0, 0
+	for _, e := range blocks {
+		if e > absGate && e > relGate {
+			sum += e
+			n++
+		}
+	}
+	if n == 0 {
+		return AbsoluteGateLUFS
+	}
+	return toLUFS(sum / float64(n))
+}
+
+func toLUFS(energy float64) float64 {
+	return -0.691 + 10*math.Log10(energy)
+}
+
+func fromLUFS(lufs float64) float64 {
+	return math.Pow(10, (lufs+0.691)/10)
+}
+
+// toDB converts a linear peak to dB, flooring silence at -144 dB (below 24-bit resolution)
+func toDB(peak float64) float64 {
+	if peak <= 0 {
+		return -144
+	}
+	return math.Max(-144, 20*math.Log10(peak))
+}
+
+// Measure is a convenience wrapper measuring a complete interleaved buffer
+func Measure(samples []float64, sampleRate, channels int) (Result, error) {
+	m, err := NewMeter(sampleRate, channels)
+	if err != nil {
+		return Result{}, err
+	}
+	m.Write(samples)
+	return m.Result(), nil
+}
@@ -0,0 +1,307 @@
+package loudness
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"songmartyn/pkg/models"
+)
+
+// sine returns interleaved frames of a sine at dBFS peak level on every channel
+func sine(freq, dbfs, seconds float64, rate, channels int, phase float64) []float64 {
+	amp := math.Pow(10, dbfs/20)
+	frames := int(seconds * float64(rate))
+	samples := make([]float64, 0, frames*channels)
+	for i := 0; i < frames; i++ {
+		v := amp * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)+phase)
+		for ch := 0; ch < channels; ch++ {
+			samples = append(samples, v)
+		}
+	}
+	return samples
+}
+
+// writeWAV writes samples as a WAV fixture; bits 32 writes IEEE float
+func writeWAV(t *testing.T, samples []float64, rate, channels, bits int) string {
+	t.Helper()
+	width := bits / 8
+	data := make([]byte, 0, len(samples)*width)
+	for _, s := range samples {
+		switch bits {
+		case 16:
+			data = binary.LittleEndian.AppendUint16(data, uint16(int16(math.Round(s*32767))))
+		case 24:
+			v := int32(math.Round(s * 8388607))
+			data = append(data, byte(v), byte(v>>8), byte(v>>16))
+		case 32:
+			data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(s)))
+		}
+	}
+
+	tag := uint16(wavFormatPCM)
+	if bits == 32 {
+		tag = wavFormatFloat
+	}
+	var header []byte
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(36+8+4+len(data)))
+	header = append(header, "WAVE"...)
+	header = append(header, "fmt "...)
+	header = binary.LittleEndian.AppendUint32(header, 16)
+	header = binary.LittleEndian.AppendUint16(header, tag)
+	header = binary.LittleEndian.AppendUint16(header, uint16(channels))
+	header = binary.LittleEndian.AppendUint32(header, uint32(rate))
+	header = binary.LittleEndian.AppendUint32(header, uint32(rate*channels*width))
+	header = binary.LittleEndian.AppendUint16(header, uint16(channels*width))
+	header = binary.LittleEndian.AppendUint16(header, uint16(bits))
+	// An unrelated chunk before the data, as many encoders write
+	header = append(header, "LIST"...)
+	header = binary.LittleEndian.AppendUint32(header, 4)
+	header = append(header, "INFO"...)
+	header = append(header, "data"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(data)))
+
+	path := filepath.Join(t.TempDir(), "fixture.wav")
+	if err := os.WriteFile(path, append(header, data...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func expectNear(t *testing.T, what string, got, want, tolerance float64) {
+	t.Helper()
+	if math.Abs(got-want) > tolerance {
+		t.Errorf("Expected %s %.2f (±%.2f), got %.3f", what, want, tolerance, got)
+	}
+}
+
+// ============================================================================
+// Loudness Tests
+// ============================================================================
+
+// A stereo 1 kHz sine at -23 dBFS is -23 LUFS (EBU Tech 3341 case 1)
+func TestSineReferenceLevel(t *testing.T) {
+	result, err := Measure(sine(1000, -23, 10, 48000, 2, 0), 48000, 2)
+	if err != nil {
+		t.Fatalf("Measure failed: %v", err)
+	}
+	expectNear(t, "integrated loudness", result.IntegratedLUFS, -23, 0.1)
+	expectNear(t, "true peak", result.TruePeakDBTP, -23, 0.1)
+}
+
+// Quiet passages fall below the relative gate (EBU Tech 3341 case 3)
+func TestRelativeGateIgnoresQuietPassages(t *testing.T) {
+	var samples []float64
+	samples = append(samples, sine(1000, -36, 5, 48000, 2, 0)...)
+	samples = append(samples, sine(1000, -23, 20, 48000, 2, 0)...)
+	samples = append(samples, sine(1000, -36, 5, 48000, 2, 0)...)
+
+	result, _ := Measure(samples, 48000, 2)
+	expectNear(t, "integrated loudness", result.IntegratedLUFS, -23, 0.1)
+}
+
+func TestSilenceReportsAbsoluteGate(t *testing.T) {
+	result, _ := Measure(make([]float64, 48000*2*2), 48000, 2)
+	if result.IntegratedLUFS != AbsoluteGateLUFS {
+		t.Errorf("Expected %.0f LUFS for silence, got %.2f", AbsoluteGateLUFS, result.IntegratedLUFS)
+	}
+}
+
+func TestLeadingAndTrailingSilence(t *testing.T) {
+	var samples []float64
+	samples = append(samples, make([]float64, 48000*2*3/2)...)
+	samples = append(samples, sine(1000, -20, 5, 48000, 2, 0)...)
+	samples = append(samples, make([]float64, 48000*2*4)...)
+
+	result, _ := Measure(samples, 48000, 2)
+	expectNear(t, "leading silence", result.LeadingSilence, 1.5, 0.1)
+	expectNear(t, "trailing silence", result.TrailingSilence, 4, 0.1)
+
+	// A silent track has nothing to trim
+	result, _ = Measure(make([]float64, 48000*2*2), 48000, 2)
+	if result.LeadingSilence != 0 || result.TrailingSilence != 0 {
+		t.Errorf("Expected no silence to trim, got %.1f / %.1f", result.LeadingSilence, result.TrailingSilence)
+	}
+}
+
+// A quarter-rate sine sampled 45° off its crest peaks between samples
+func TestTruePeakFindsInterSamplePeaks(t *testing.T) {
+	result, _ := Measure(sine(12000, 0, 1, 48000, 1, math.Pi/4), 48000, 1)
+	expectNear(t, "true peak", result.TruePeakDBTP, 0, 0.3)
+}
+
+func TestNewMeterValidation(t *testing.T) {
+	if _, err := NewMeter(100, 2); err == nil {
+		t.Error("Expected error for a tiny sample rate")
+	}
+	if _, err := NewMeter(48000, 0); err == nil {
+		t.Error("Expected error for zero channels")
+	}
+}
+
+func TestGainDB(t *testing.T) {
+	tests := []struct {
+		name   string
+		result Result
+		want   float64
+	}{
+		{"turn loud track down", Result{IntegratedLUFS: -8, TruePeakDBTP: 0}, -10},
+		{"boost quiet track", Result{IntegratedLUFS: -24, TruePeakDBTP: -12}, 6},
+		{"boost limited by true peak", Result{IntegratedLUFS: -24, TruePeakDBTP: -4}, 3},
+		{"boost limited by maximum", Result{IntegratedLUFS: -50, TruePeakDBTP: -40}, MaxBoostDB},
+	}
+	for _, tt := range tests {
+		if got := GainDB(tt.result, -18); got != tt.want {
+			t.Errorf("%s: expected %.1f dB, got %.1f dB", tt.name, tt.want, got)
+		}
+	}
+}
+
+// ============================================================================
+// WAV and Decoder Tests
+// ============================================================================
+
+func TestMeasureWAVFormats(t *testing.T) {
+	for _, bits := range []int{16, 24, 32} {
+		path := writeWAV(t, sine(1000, -23, 5, 44100, 2, 0), 44100, 2, bits)
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := MeasureWAV(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%d-bit: MeasureWAV failed: %v", bits, err)
+		}
+		expectNear(t, "integrated loudness", result.IntegratedLUFS, -23, 0.1)
+	}
+}
+
+func TestMeasureWAVRejectsOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "song.mp3")
+	os.WriteFile(path, []byte("ID3 not a wave file"), 0644)
+	f, _ := os.Open(path)
+	defer f.Close()
+	if _, err := MeasureWAV(f); err == nil {
+		t.Error("Expected an error for a non-WAV file")
+	}
+}
+
+func TestAnalyzerReadsWAVDirectly(t *testing.T) {
+	path := writeWAV(t, sine(1000, -20, 5, 48000, 2, 0), 48000, 2, 16)
+	analyzer := NewAnalyzer("decoder-that-does-not-exist {input}")
+
+	result, err := analyzer.Analyze(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	expectNear(t, "integrated loudness", result.IntegratedLUFS, -20, 0.1)
+}
+
+func TestAnalyzerRunsDecoder(t *testing.T) {
+	// "Decode" a raw s16le file by copying it to stdout
+	samples := sine(1000, -14, 3, DecoderSampleRate, DecoderChannels, 0)
+	raw := make([]byte, 0, len(samples)*2)
+	for _, s := range samples {
+		raw = binary.LittleEndian.AppendUint16(raw, uint16(int16(math.Round(s*32767))))
+	}
+	path := filepath.Join(t.TempDir(), "song.pcm")
+	os.WriteFile(path, raw, 0644)
+
+	result, err := NewAnalyzer("cat {input}").Analyze(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	expectNear(t, "integrated loudness", result.IntegratedLUFS, -14, 0.1)
+
+	if _, err := NewAnalyzer("cat").Analyze(context.Background(), filepath.Join(t.TempDir(), "missing.mp3")); err == nil {
+		t.Error("Expected a decoder failure to be reported")
+	}
+}
+
+// ============================================================================
+// Job Tests
+// ============================================================================
+
+type fakeStore struct {
+	mu      sync.Mutex
+	pending []models.LibrarySong
+	saved   map[string]Result
+}
+
+func (s *fakeStore) SongsNeedingLoudness() ([]models.LibrarySong, error) {
+	return s.pending, nil
+}
+
+func (s *fakeStore) SetLoudness(songID string, integratedLUFS, truePeakDBTP float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved[songID] = Result{IntegratedLUFS: integratedLUFS, TruePeakDBTP: truePeakDBTP}
+	return nil
+}
+
+func (s *fakeStore) SetSilence(songID string, leading, trailing float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.saved[songID]
+	r.LeadingSilence, r.TrailingSilence = leading, trailing
+	s.saved[songID] = r
+	return nil
+}
+
+func TestJobAnalyzesPendingSongs(t *testing.T) {
+	loud := writeWAV(t, sine(1000, -10, 2, 48000, 2, 0), 48000, 2, 16)
+	quiet := writeWAV(t, sine(1000, -30, 2, 48000, 2, 0), 48000, 2, 16)
+	store := &fakeStore{
+		pending: []models.LibrarySong{
+			{ID: "loud", Title: "Loud", FilePath: loud},
+			{ID: "cdg", Title: "Karaoke", FilePath: "/songs/karaoke.cdg", CDGPath: "/songs/karaoke.cdg", AudioPath: quiet},
+			{ID: "gone", Title: "Gone", FilePath: filepath.Join(t.TempDir(), "gone.wav")},
+		},
+		saved: map[string]Result{},
+	}
+	job := NewJob(store, NewAnalyzer("false"))
+
+	if err := job.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	job.Wait()
+
+	status := job.Status()
+	if status.Running || status.Total != 3 || status.Done != 2 || status.Failed != 1 {
+		t.Errorf("Expected 2 done and 1 failed of 3, got %+v", status)
+	}
+	if status.LastError == "" || status.FinishedAt == nil {
+		t.Errorf("Expected the failure and finish time to be reported, got %+v", status)
+	}
+	expectNear(t, "loud song", store.saved["loud"].IntegratedLUFS, -10, 0.1)
+	expectNear(t, "CDG audio", store.saved["cdg"].IntegratedLUFS, -30, 0.1)
+	if _, ok := store.saved["gone"]; ok {
+		t.Error("Expected no result for a missing file")
+	}
+}
+
+func TestJobRejectsConcurrentRuns(t *testing.T) {
+	store := &fakeStore{saved: map[string]Result{}}
+	for i := 0; i < 50; i++ {
+		store.pending = append(store.pending, models.LibrarySong{ID: "s", FilePath: "5"})
+	}
+	job := NewJob(store, NewAnalyzer("sleep {input}")) // Each "decode" takes 5 seconds
+
+	if err := job.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := job.Start(); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("Expected ErrJobRunning, got %v", err)
+	}
+	job.Stop()
+	if status := job.Status(); status.Running || status.Done+status.Failed == 50 {
+		t.Errorf("Expected the run to be cancelled early, got %+v", status)
+	}
+}
@@ -0,0 +1,160 @@
+package loudness
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WAV format tags
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// pcmFormat describes interleaved little-endian samples
+type pcmFormat struct {
+	sampleRate int
+	channels   int
+	bits       int  // 8, 16, 24 or 32
+	float      bool // IEEE float (32-bit only)
+}
+
+// MeasurePCM measures raw interleaved signed 16-bit little-endian PCM,
+// the format the decoder command is asked to produce
+func MeasurePCM(r io.Reader, sampleRate, channels int) (Result, error) {
+	return measureStream(r, pcmFormat{sampleRate: sampleRate, channels: channels, bits: 16})
+}
+
+// MeasureWAV measures a RIFF/WAVE stream holding integer or float PCM
+func MeasureWAV(r io.Reader) (Result, error) {
+	br := bufio.NewReader(r)
+	format, err := readWAVHeader(br)
+	if err != nil {
+		return Result{}, err
+	}
+	return measureStream(br, format)
+}
+
+// readWAVHeader reads chunks up to the start of the sample data
+func readWAVHeader(r io.Reader) (pcmFormat, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return pcmFormat{}, fmt.Errorf("reading WAV header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return pcmFormat{}, errors.New("not a WAV file")
+	}
+
+	var format pcmFormat
+	haveFormat := false
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return pcmFormat{}, errors.New("WAV file has no data chunk")
+		}
+		id := string(header[0:4])
+		size := int64(binary.LittleEndian.Uint32(header[4:8]))
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return pcmFormat{}, errors.New("WAV fmt chunk too short")
+			}
+			chunk := make([]byte, size)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return pcmFormat{}, fmt.Errorf("reading WAV fmt chunk: %w", err)
+			}
+			tag := binary.LittleEndian.Uint16(chunk[0:2])
+			if tag == wavFormatExtensible && size >= 26 {
+				tag = binary.LittleEndian.Uint16(chunk[24:26]) // Sub-format GUID starts with the real tag
+			}
+			format = pcmFormat{
+				channels:   int(binary.LittleEndian.Uint16(chunk[2:4])),
+				sampleRate: int(binary.LittleEndian.Uint32(chunk[4:8])),
+				bits:       int(binary.LittleEndian.Uint16(chunk[14:16])),
+				float:      tag == wavFormatFloat,
+			}
+			if tag != wavFormatPCM && tag != wavFormatFloat {
+				return pcmFormat{}, fmt.Errorf("unsupported WAV encoding: %d", tag)
+			}
+			if format.float && format.bits != 32 {
+				return pcmFormat{}, fmt.Errorf("unsupported float WAV: %d-bit", format.bits)
+			}
+			haveFormat = true
+			size = 0
+		case "data":
+			if !haveFormat {
+				return pcmFormat{}, errors.New("WAV data chunk before fmt chunk")
+			}
+			return format, nil
+		}
+
+		// Skip unknown chunks (and the pad byte after odd-sized ones)
+		if size%2 == 1 {
+			size++
+		}
+		if _, err := io.CopyN(io.Discard, r, size); err != nil {
+			return pcmFormat{}, fmt.Errorf("reading WAV chunk %q: %w", id, err)
+		}
+	}
+}
+
+// measureStream decodes samples until EOF and feeds them to a meter
+func measureStream(r io.Reader, format pcmFormat) (Result, error) {
+	switch format.bits {
+	case 8, 16, 24, 32:
+	default:
+		return Result{}, fmt.Errorf("unsupported sample size: %d bits", format.bits)
+	}
+	meter, err := NewMeter(format.sampleRate, format.channels)
+	if err != nil {
+		return Result{}, err
+	}
+
+	width := format.bits / 8
+	frameBytes := width * format.channels
+	buf := make([]byte, frameBytes*4096)
+	samples := make([]float64, 0, format.channels*4096)
+	for {
+		n, err := io.ReadFull(r, buf)
+		n -= n % frameBytes
+		if n > 0 {
+			samples = samples[:0]
+			for i := 0; i < n; i += width {
+				samples = append(samples, decodeSample(buf[i:i+width], format))
+			}
+			meter.Write(samples)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return Result{}, err
+		}
+	}
+	return meter.Result(), nil
+}
+
+// decodeSample converts one little-endian sample to [-1, 1]
+func decodeSample(b []byte, format pcmFormat) float64 {
+	switch format.bits {
+	case 8:
+		return (float64(b[0]) - 128) / 128 // 8-bit WAV is unsigned
+	case 16:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / 32768
+	case 24:
+		v := int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+		return float64(v) / 8388608
+	default:
+		u := binary.LittleEndian.Uint32(b)
+		if format.float {
+			return float64(math.Float32frombits(u))
+		}
+		return float64(int32(u)) / 2147483648
+	}
+}
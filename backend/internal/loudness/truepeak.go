@@ -0,0 +1,67 @@
+package loudness
+
+import "math"
+
+// True peak is estimated by 4x oversampling with a windowed-sinc interpolator,
+// as BS.1770-4 Annex 2 describes
+const (
+	oversample = 4
+	peakTaps   = 12 // Input samples contributing to each interpolated point
+)
+
+// peakCoeffs[p] interpolates the point p/oversample of the way between two samples
+var peakCoeffs = func() [oversample][peakTaps]float64 {
+	var coeffs [oversample][peakTaps]float64
+	half := peakTaps / 2
+	for p := 1; p < oversample; p++ {
+		frac := float64(p) / oversample
+		var sum float64
+		for i := 0; i < peakTaps; i++ {
+			// Tap i is the input sample at offset i-half+1 from the left neighbour
+			t := frac - float64(i-half+1)
+			h := sinc(t) * 0.5 * (1 + math.Cos(math.Pi*t/float64(half)))
+			coeffs[p][i] = h
+			sum += h
+		}
+		for i := range coeffs[p] {
+			coeffs[p][i] /= sum
+		}
+	}
+	return coeffs
+}()
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// peakDetector tracks the true peak of one channel
+type peakDetector struct {
+	history [peakTaps]float64 // Ring buffer of the latest samples
+	next    int
+}
+
+func newPeakDetector() *peakDetector {
+	return &peakDetector{}
+}
+
+// add feeds one sample and returns the largest absolute value among it and the
+// points interpolated around the sample half a window earlier
+func (d *peakDetector) add(x float64) float64 {
+	d.history[d.next] = x
+	d.next = (d.next + 1) % peakTaps
+
+	peak := math.Abs(x)
+	for p := 1; p < oversample; p++ {
+		var y float64
+		for i, c := range peakCoeffs[p] {
+			y += c * d.history[(d.next+i)%peakTaps]
+		}
+		if y = math.Abs(y); y > peak {
+			peak = y
+		}
+	}
+	return peak
+}
@@ -0,0 +1,326 @@
+// Package metrics is a small Prometheus text-format registry. It covers the
+// counters, gauges and histograms SongMartyn exposes on /metrics without
+// pulling in the full client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentType is the Prometheus text exposition format served by Handler
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets suit latencies measured in seconds, from 1ms to 10s
+var DefBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// ByteBuckets suit message sizes, from 64B to 1MiB
+var ByteBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576}
+
+// QueryObserver is told how long a database operation took. Managers keep
+// one so they can be timed without knowing about the registry.
+type QueryObserver func(op string, took time.Duration)
+
+// Time starts timing op; call the returned func when the operation is done.
+// A nil observer costs nothing.
+func (o QueryObserver) Time(op string) func() {
+	if o == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() { o(op, time.Since(start)) }
+}
+
+// metric is anything the registry can write out
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds metrics in registration order
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes every metric in the Prometheus text format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler serves the registry for Prometheus to scrape
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteText(w)
+	})
+}
+
+// desc is the name, help and label names shared by every metric kind
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d desc) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.kind)
+}
+
+// key joins label values so they can index a map
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelString renders {a="x",b="y"}, with extra appended (used for le)
+func (d desc) labelString(key string, extra ...string) string {
+	var pairs []string
+	if len(d.labels) > 0 {
+		for i, v := range strings.Split(key, "\xff") {
+			pairs = append(pairs, d.labels[i]+`="`+escapeLabel(v)+`"`)
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// sortedKeys returns map keys in a stable order for output
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter is a monotonically increasing value, optionally split by labels
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter registers a counter
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name, help, "counter", labels}, values: make(map[string]float64)}
+	if len(labels) == 0 {
+		c.values[""] = 0
+	}
+	r.register(name, c)
+	return c
+}
+
+// Inc adds one
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.name + " cannot decrease")
+	}
+	key := c.key(labelValues)
+	c.mu.Lock()
+	c.values[key] += v
+	c.mu.Unlock()
+}
+
+// Value returns the current count for the given labels
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key]
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.header(w)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelString(key), formatFloat(c.values[key]))
+	}
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	desc
+	mu    sync.Mutex
+	value float64
+}
+
+// NewGauge registers a gauge
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{desc: desc{name: name, help: help, kind: "gauge"}}
+	r.register(name, g)
+	return g
+}
+
+// Set replaces the gauge's value
+func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
+	g.value = v
+	g.mu.Unlock()
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.header(w)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.value))
+}
+
+// gaugeFunc is a gauge read from fn at scrape time
+type gaugeFunc struct {
+	desc
+	fn func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value comes from fn on every scrape
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &gaugeFunc{desc: desc{name: name, help: help, kind: "gauge"}, fn: fn})
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	g.header(w)
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.fn()))
+}
+
+// Histogram counts observations into cumulative buckets
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram registers a histogram with the given upper bounds
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	bs := append([]float64(nil), buckets...)
+	sort.Float64s(bs)
+	h := &Histogram{desc: desc{name, help, "histogram", labels}, buckets: bs, series: make(map[string]*histogramSeries)}
+	r.register(name, h)
+	return h
+}
+
+// Observe records one value
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.series[key]
+	if s == nil {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// ObserveDuration records d in seconds
+func (h *Histogram) ObserveDuration(d time.Duration, labelValues ...string) {
+	h.Observe(d.Seconds(), labelValues...)
+}
+
+// Count returns how many values were observed for the given labels
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s := h.series[key]; s != nil {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.header(w)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(key, "le", formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(key, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelString(key), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelString(key), s.count)
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+	return b.String()
+}
+
+func expectLines(t *testing.T, out string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Expected line %q in output:\n%s", line, out)
+		}
+	}
+}
+
+// =============================================================================
+// Counter and Gauge Tests
+// =============================================================================
+
+func TestCounterWithoutLabels(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("songs_total", "Songs played")
+
+	// An unlabelled counter is exported as zero before anything happens
+	expectLines(t, scrape(t, r), "# HELP songs_total Songs played", "# TYPE songs_total counter", "songs_total 0")
+
+	c.Inc()
+	c.Add(2)
+	if c.Value() != 3 {
+		t.Errorf("Expected 3, got %v", c.Value())
+	}
+	expectLines(t, scrape(t, r), "songs_total 3")
+}
+
+func TestCounterLabelsAreSortedAndEscaped(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("messages_total", "Messages", "direction", "type")
+	c.Inc("out", "state")
+	c.Inc("in", `say "hi"`)
+	c.Inc("in", `say "hi"`)
+
+	out := scrape(t, r)
+	expectLines(t, out,
+		`messages_total{direction="in",type="say \"hi\""} 2`,
+		`messages_total{direction="out",type="state"} 1`,
+	)
+	if strings.Index(out, `direction="in"`) > strings.Index(out, `direction="out"`) {
+		t.Errorf("Expected series in sorted order, got:\n%s", out)
+	}
+}
+
+func TestCounterPanicsOnWrongLabelCount(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("x_total", "x", "a")
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for missing label value")
+		}
+	}()
+	c.Inc()
+}
+
+func TestDuplicateNamePanics(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("dup", "x")
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for duplicate metric")
+		}
+	}()
+	r.NewGauge("dup", "x")
+}
+
+func TestGauges(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGauge("start_time_seconds", "Start time")
+	g.Set(1.5)
+	n := 4
+	r.NewGaugeFunc("clients", "Connected clients", func() float64 { return float64(n) })
+
+	expectLines(t, scrape(t, r), "# TYPE start_time_seconds gauge", "start_time_seconds 1.5", "clients 4")
+	n = 7
+	expectLines(t, scrape(t, r), "clients 7")
+}
+
+func TestHelpIsEscaped(t *testing.T) {
+	r := NewRegistry()
+	r.NewGauge("g", "line one\nback\\slash")
+	expectLines(t, scrape(t, r), `# HELP g line one\nback\\slash`)
+}
+
+// =============================================================================
+// Histogram Tests
+// =============================================================================
+
+func TestHistogramBucketsAreCumulative(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("latency_seconds", "Latency", []float64{1, 0.1}, "source")
+	h.Observe(0.05, "library")
+	h.Observe(0.5, "library")
+	h.Observe(5, "library")
+
+	if h.Count("library") != 3 {
+		t.Errorf("Expected 3 observations, got %d", h.Count("library"))
+	}
+	if h.Count("youtube") != 0 {
+		t.Errorf("Expected 0 observations, got %d", h.Count("youtube"))
+	}
+	expectLines(t, scrape(t, r),
+		"# TYPE latency_seconds histogram",
+		`latency_seconds_bucket{source="library",le="0.1"} 1`,
+		`latency_seconds_bucket{source="library",le="1"} 2`,
+		`latency_seconds_bucket{source="library",le="+Inf"} 3`,
+		`latency_seconds_sum{source="library"} 5.55`,
+		`latency_seconds_count{source="library"} 3`,
+	)
+}
+
+func TestHistogramObserveDuration(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("d", "d", DefBuckets)
+	h.ObserveDuration(20 * time.Millisecond)
+	expectLines(t, scrape(t, r), `d_bucket{le="0.01"} 0`, `d_bucket{le="0.025"} 1`, "d_count 1")
+}
+
+// =============================================================================
+// Handler and Observer Tests
+// =============================================================================
+
+func TestHandlerServesTextFormat(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("hits_total", "Hits").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Expected content type %q, got %q", ContentType, ct)
+	}
+	expectLines(t, rec.Body.String(), "hits_total 1")
+}
+
+func TestQueryObserver(t *testing.T) {
+	var nilObserver QueryObserver
+	nilObserver.Time("noop")() // must not panic
+
+	var gotOp string
+	var gotTook time.Duration
+	o := QueryObserver(func(op string, took time.Duration) {
+		gotOp, gotTook = op, took
+	})
+	done := o.Time("search")
+	time.Sleep(2 * time.Millisecond)
+	done()
+
+	if gotOp != "search" {
+		t.Errorf("Expected op search, got %q", gotOp)
+	}
+	if gotTook < 2*time.Millisecond {
+		t.Errorf("Expected at least 2ms, got %v", gotTook)
+	}
+}
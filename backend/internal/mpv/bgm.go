@@ -0,0 +1,153 @@
+package mpv
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dexterlb/mpvipc"
+)
+
+// bgmTrack is background music playing in its own audio-only mpv
+// Keeping it out of the main player lets it fade out under the next song instead of being replaced by it
+type bgmTrack struct {
+	cmd    *exec.Cmd
+	conn   *mpvipc.Connection
+	volume float64 // mpv volume at full level
+
+	mu    sync.Mutex
+	level float64 // 0-1, scales volume
+	gen   int     // Bumped to cancel a running ramp
+}
+
+// bgmSocketPath returns the IPC socket for a controller's background music
+func bgmSocketPath(socketPath string) string {
+	if strings.HasSuffix(socketPath, ".sock") {
+		return strings.TrimSuffix(socketPath, ".sock") + "-bgm.sock"
+	}
+	return socketPath + "-bgm"
+}
+
+// startBGMTrack launches an audio-only mpv looping url, starting silent
+func startBGMTrack(executable, socketPath, url string, volume float64) (*bgmTrack, error) {
+	quitStaleBGM(socketPath)
+
+	cmd := exec.Command(executable,
+		"--no-video",
+		"--idle=yes",
+		"--loop-file=inf",
+		"--volume=0",
+		"--input-ipc-server="+socketPath,
+		audioOutputArg(true),
+		url,
+	)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start BGM player: %w", err)
+	}
+	go cmd.Wait() // Reap the process whenever it exits
+
+	waitForSocket(socketPath)
+	conn := mpvipc.NewConnection(socketPath)
+	if err := conn.Open(); err != nil {
+		cmd.Process.Kill()
+		return nil, fmt.Errorf("failed to connect to BGM player: %w", err)
+	}
+	logger.Info("Started BGM player", "pid", cmd.Process.Pid)
+	return &bgmTrack{cmd: cmd, conn: conn, volume: volume}, nil
+}
+
+// quitStaleBGM closes background music left playing by a process that exited without stopping it
+func quitStaleBGM(socketPath string) {
+	conn := mpvipc.NewConnection(socketPath)
+	if err := conn.Open(); err == nil {
+		logger.Info("Quitting stale BGM player", "socket", socketPath)
+		conn.Call("quit")
+		conn.Close()
+	}
+	if runtime.GOOS != "windows" {
+		os.Remove(socketPath)
+	}
+}
+
+// setLevel jumps to level, cancelling any ramp
+func (t *bgmTrack) setLevel(level float64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.gen++
+	return t.applyLocked(level)
+}
+
+// ramp moves the level to `to` over d in steps, stopping early if setLevel or another ramp takes over
+func (t *bgmTrack) ramp(to float64, d time.Duration) {
+	const steps = 20
+	t.mu.Lock()
+	t.gen++
+	gen := t.gen
+	from := t.level
+	t.mu.Unlock()
+
+	for i := 1; i <= steps; i++ {
+		time.Sleep(d / steps)
+		t.mu.Lock()
+		if gen != t.gen {
+			t.mu.Unlock()
+			return
+		}
+		t.applyLocked(from + (to-from)*float64(i)/steps)
+		t.mu.Unlock()
+	}
+}
+
+// applyLocked sets the mpv volume for level; caller holds mu
+func (t *bgmTrack) applyLocked(level float64) error {
+	t.level = level
+	return t.conn.Set("volume", t.volume*level)
+}
+
+// close quits the music's mpv
+func (t *bgmTrack) close() {
+	t.mu.Lock()
+	t.gen++
+	t.mu.Unlock()
+	t.conn.Call("quit")
+	t.conn.Close()
+	if t.cmd.Process != nil {
+		t.cmd.Process.Kill()
+	}
+}
+
+// takeBGM detaches the playing background music from the controller
+func (c *Controller) takeBGM() *bgmTrack {
+	c.bgmMu.Lock()
+	defer c.bgmMu.Unlock()
+	track := c.bgm
+	c.bgm = nil
+	return track
+}
+
+// stopBGMTrack quits the background music's mpv, if any
+func (c *Controller) stopBGMTrack() {
+	if track := c.takeBGM(); track != nil {
+		track.close()
+	}
+}
+
+// SetBGMFade scales the background music (0-1) without touching the main player's fade
+func (c *Controller) SetBGMFade(level float64) error {
+	c.bgmMu.Lock()
+	defer c.bgmMu.Unlock()
+	if c.bgm == nil {
+		return nil
+	}
+	return c.bgm.setLevel(level)
+}
+
+// StopBGM stops the background music, leaving whatever the main player has loaded playing
+func (c *Controller) StopBGM() error {
+	c.stopBGMTrack()
+	return nil
+}
@@ -0,0 +1,733 @@
+package mpv
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"songmartyn/pkg/models"
+)
+
+// DefaultFakeDuration is the length of fake media without an explicit duration
+const DefaultFakeDuration = 180.0
+
+// FakePlayer is an in-process Player that simulates playback without mpv
+// Tests move time forward with Advance; when a monitored song reaches its end
+// OnTrackEnd fires just as it does from the mpv playback monitor
+type FakePlayer struct {
+	mu sync.Mutex
+
+	running  bool
+	startErr error
+	detached bool // Detach left the media playing for the next Start to adopt
+	adopted  bool
+
+	// Loaded media
+	current     string // Path of the loaded media ("" when stopped)
+	image       bool   // Current media is a still image (holding screen)
+	bgmAudio    string // BGM audio URL playing; it keeps playing under later loads until stopped
+	bgmFade     float64
+	backdrop    string // Video looping under the image (LoadHoldingVideo)
+	playingSong bool
+	monitoring  bool
+	paused      bool
+	position    float64
+	duration    float64
+
+	// Audio and on-screen state
+	volume  float64
+	pitch   int
+	tempo   float64
+	gain    float64
+	fade    float64
+	overlay string
+
+	// Vocal stem mix (set by SetVocalMix, cleared by any other load)
+	vocalMix  bool
+	vocalGain float64
+	ticker    []TickerEntry
+	reactions []Reaction
+
+	// Trim applied to the next song (SetTrim)
+	trimLead float64
+	trimTail float64
+
+	// Scripted behaviour and history
+	durations map[string]float64
+	failures  map[string]error
+	loaded    []string
+	display   DisplaySettings
+
+	onStateChange func(state models.PlayerState)
+	onTrackEnd    func()
+}
+
+var _ Player = (*FakePlayer)(nil)
+
+// NewFakePlayer creates a stopped fake player; call Start before loading media
+func NewFakePlayer() *FakePlayer {
+	return &FakePlayer{
+		volume:    100,
+		tempo:     1.0,
+		fade:      1.0,
+		durations: make(map[string]float64),
+		failures:  make(map[string]error),
+	}
+}
+
+// SetDuration sets the simulated length of a media file in seconds
+func (f *FakePlayer) SetDuration(path string, seconds float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.durations[path] = seconds
+}
+
+// FailLoad makes every load of path fail with err (nil clears the failure)
+func (f *FakePlayer) FailLoad(path string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.failures, path)
+		return
+	}
+	f.failures[path] = err
+}
+
+// FailStart makes Start and Restart fail with err (nil clears the failure)
+func (f *FakePlayer) FailStart(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.startErr = err
+}
+
+// Crash simulates the player process dying: it stops running and forgets its media
+func (f *FakePlayer) Crash() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running = false
+	f.unload()
+}
+
+// Advance moves simulated playback forward by d (scaled by tempo)
+// Reaching the end of a monitored song fires OnTrackEnd
+func (f *FakePlayer) Advance(d time.Duration) {
+	f.mu.Lock()
+	if !f.running || f.current == "" || f.image || f.paused {
+		f.mu.Unlock()
+		return
+	}
+	f.position += d.Seconds() * f.tempo
+	if f.position < f.duration {
+		f.mu.Unlock()
+		return
+	}
+
+	f.position = f.duration
+	ended := f.playingSong && f.monitoring
+	if ended {
+		f.playingSong = false
+		f.monitoring = false
+	}
+	onTrackEnd := f.onTrackEnd
+	f.mu.Unlock()
+
+	if ended && onTrackEnd != nil {
+		onTrackEnd()
+	}
+}
+
+// FinishTrack advances playback to the end of the current media
+func (f *FakePlayer) FinishTrack() {
+	f.mu.Lock()
+	remaining := f.duration - f.position
+	tempo := f.tempo
+	f.mu.Unlock()
+	if remaining < 0 {
+		remaining = 0
+	}
+	f.Advance(time.Duration(remaining / tempo * float64(time.Second)))
+}
+
+// Current returns the loaded media path ("" when nothing is loaded)
+func (f *FakePlayer) Current() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.current
+}
+
+// ShowingImage reports whether a still image (holding screen) is displayed
+func (f *FakePlayer) ShowingImage() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.image && f.current != ""
+}
+
+// BGMAudio returns the background music URL playing, if any
+func (f *FakePlayer) BGMAudio() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.bgmAudio
+}
+
+// Backdrop returns the video looping under the holding screen, if any
+func (f *FakePlayer) Backdrop() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.backdrop
+}
+
+// Loaded returns every media path successfully loaded, in order
+func (f *FakePlayer) Loaded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.loaded...)
+}
+
+// LastOverlay returns the most recent overlay text
+func (f *FakePlayer) LastOverlay() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.overlay
+}
+
+// Reactions returns every reaction shown, in order
+func (f *FakePlayer) Reactions() []Reaction {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Reaction(nil), f.reactions...)
+}
+
+// Ticker returns the ticker entries on screen (nil when hidden)
+func (f *FakePlayer) Ticker() []TickerEntry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]TickerEntry(nil), f.ticker...)
+}
+
+// Pitch returns the pitch shift in semitones
+func (f *FakePlayer) Pitch() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pitch
+}
+
+// Tempo returns the playback speed multiplier
+func (f *FakePlayer) Tempo() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.tempo
+}
+
+// Gain returns the loudness normalization gain in dB
+func (f *FakePlayer) Gain() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.gain
+}
+
+// Fade returns the transition level (0-1)
+func (f *FakePlayer) Fade() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fade
+}
+
+// BGMFade returns the background music level (0-1)
+func (f *FakePlayer) BGMFade() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.bgmFade
+}
+
+// VocalGain returns the vocal stem level (0-1) of the playing stems
+func (f *FakePlayer) VocalGain() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.vocalGain
+}
+
+// Start starts the fake player, adopting it if it was detached
+func (f *FakePlayer) Start() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.startErr != nil {
+		return f.startErr
+	}
+	f.running = true
+	f.adopted = f.detached
+	f.detached = false
+	return nil
+}
+
+// Detach disconnects but keeps the loaded media, as a detached mpv keeps playing
+// Background music stops, as the Controller quits its mpv
+func (f *FakePlayer) Detach() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running = false
+	f.detached = true
+	f.monitoring = false
+	f.bgmAudio = ""
+	return nil
+}
+
+// Adopted reports whether the last Start picked up a detached player
+func (f *FakePlayer) Adopted() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.adopted
+}
+
+// Stop stops the fake player
+func (f *FakePlayer) Stop() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running = false
+	f.adopted = false
+	f.unload()
+	f.bgmAudio = ""
+	return nil
+}
+
+// Restart stops and starts the fake player
+func (f *FakePlayer) Restart() error {
+	f.Stop()
+	return f.Start()
+}
+
+// IsRunning returns true if the fake player is started
+func (f *FakePlayer) IsRunning() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.running
+}
+
+// SetDisplaySettings records the display settings
+func (f *FakePlayer) SetDisplaySettings(settings DisplaySettings) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.display = settings
+}
+
+// LoadFile loads a media file; like mpv it doesn't mark it as a song by itself
+func (f *FakePlayer) LoadFile(path string) error {
+	return f.load(path, false, false)
+}
+
+// LoadImage displays an image indefinitely
+func (f *FakePlayer) LoadImage(path string) error {
+	return f.load(path, true, false)
+}
+
+// LoadHoldingVideo displays the holding screen image over a looping video
+func (f *FakePlayer) LoadHoldingVideo(videoPath, overlayPath string) error {
+	if err := f.failure(videoPath); err != nil {
+		return err
+	}
+	if err := f.load(overlayPath, true, false); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.backdrop = videoPath
+	f.mu.Unlock()
+	return nil
+}
+
+// LoadCDG loads CDG graphics with their audio and starts end detection
+func (f *FakePlayer) LoadCDG(cdgPath, audioPath string) error {
+	if err := f.failure(audioPath); err != nil {
+		return err
+	}
+	if err := f.load(cdgPath, false, true); err != nil {
+		return err
+	}
+	f.StartPlaybackMonitor()
+	return nil
+}
+
+// SetVocalMix loads the instrumental stem with the vocal stem mixed in and starts end detection
+func (f *FakePlayer) SetVocalMix(instrumentalPath, vocalPath string, vocalGain float64) error {
+	if err := f.failure(vocalPath); err != nil {
+		return err
+	}
+	if err := f.load(instrumentalPath, false, true); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.vocalMix = true
+	f.vocalGain = models.ClampVocalGain(vocalGain)
+	f.mu.Unlock()
+	f.StartPlaybackMonitor()
+	return nil
+}
+
+// LoadBGMWithImage shows an image with background music under it
+func (f *FakePlayer) LoadBGMWithImage(imagePath, audioURL string, targetVolume float64) error {
+	if err := f.failure(audioURL); err != nil {
+		return err
+	}
+	if err := f.load(imagePath, true, false); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.bgmAudio = audioURL
+	f.bgmFade = 1
+	f.volume = targetVolume
+	f.mu.Unlock()
+	return nil
+}
+
+// UpdateBGMImage swaps the image under the background music
+func (f *FakePlayer) UpdateBGMImage(imagePath string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return fmt.Errorf("mpv not connected")
+	}
+	f.current = imagePath
+	f.image = true
+	f.loaded = append(f.loaded, imagePath)
+	return nil
+}
+
+// StopBGMWithFade stops the background music and the image immediately (no simulated fade)
+func (f *FakePlayer) StopBGMWithFade(fadeDuration time.Duration) error {
+	if err := f.StopBGM(); err != nil {
+		return err
+	}
+	return f.StopPlayback()
+}
+
+// StopBGM stops the background music, leaving the loaded media playing
+func (f *FakePlayer) StopBGM() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return fmt.Errorf("mpv not connected")
+	}
+	f.bgmAudio = ""
+	return nil
+}
+
+// SetTrim cuts lead seconds from the start and tail seconds from the end of the next song
+func (f *FakePlayer) SetTrim(lead, tail float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return fmt.Errorf("mpv not connected")
+	}
+	f.trimLead, f.trimTail = lead, tail
+	return nil
+}
+
+// StopPlayback stops playback without stopping the player
+func (f *FakePlayer) StopPlayback() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return fmt.Errorf("mpv not connected")
+	}
+	f.unload()
+	return nil
+}
+
+// Play resumes playback
+func (f *FakePlayer) Play() error {
+	return f.setPaused(false)
+}
+
+// Pause pauses playback
+func (f *FakePlayer) Pause() error {
+	return f.setPaused(true)
+}
+
+// Seek seeks to a position in seconds
+func (f *FakePlayer) Seek(position float64) error {
+	f.mu.Lock()
+	if !f.running {
+		f.mu.Unlock()
+		return fmt.Errorf("mpv not connected")
+	}
+	if position < 0 {
+		position = 0
+	}
+	if position > f.duration {
+		position = f.duration
+	}
+	f.position = position
+	f.mu.Unlock()
+	f.notifyStateChange()
+	return nil
+}
+
+// SetVolume sets the playback volume (0-100)
+func (f *FakePlayer) SetVolume(volume float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return fmt.Errorf("mpv not connected")
+	}
+	f.volume = volume
+	return nil
+}
+
+// SetPitch sets the pitch shift in semitones
+func (f *FakePlayer) SetPitch(semitones int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return fmt.Errorf("mpv not connected")
+	}
+	f.pitch = semitones
+	return nil
+}
+
+// SetTempo sets the playback speed multiplier
+func (f *FakePlayer) SetTempo(speed float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return fmt.Errorf("mpv not connected")
+	}
+	if speed <= 0 {
+		return fmt.Errorf("invalid tempo: %.2f", speed)
+	}
+	f.tempo = speed
+	return nil
+}
+
+// SetGain sets the loudness normalization gain in dB
+func (f *FakePlayer) SetGain(db float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return fmt.Errorf("mpv not connected")
+	}
+	f.gain = db
+	return nil
+}
+
+// SetFade sets the transition level (0-1)
+func (f *FakePlayer) SetFade(level float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return fmt.Errorf("mpv not connected")
+	}
+	f.fade = level
+	return nil
+}
+
+// SetBGMFade sets the background music level (0-1)
+func (f *FakePlayer) SetBGMFade(level float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return fmt.Errorf("mpv not connected")
+	}
+	f.bgmFade = level
+	return nil
+}
+
+// SetVocalGain changes the vocal stem level without reloading
+func (f *FakePlayer) SetVocalGain(gain float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return fmt.Errorf("mpv not connected")
+	}
+	if !f.vocalMix {
+		return fmt.Errorf("no vocal mix loaded")
+	}
+	f.vocalGain = models.ClampVocalGain(gain)
+	return nil
+}
+
+// ShowOverlay records overlay text
+func (f *FakePlayer) ShowOverlay(text string, durationMs int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return fmt.Errorf("mpv not connected")
+	}
+	f.overlay = text
+	return nil
+}
+
+// ShowReaction records a reaction; the fake screen never fills up
+func (f *FakePlayer) ShowReaction(r Reaction) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return fmt.Errorf("mpv not connected")
+	}
+	f.reactions = append(f.reactions, r)
+	return nil
+}
+
+// ShowTicker records the ticker entries
+func (f *FakePlayer) ShowTicker(entries []TickerEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return fmt.Errorf("mpv not connected")
+	}
+	f.ticker = append([]TickerEntry(nil), entries...)
+	return nil
+}
+
+// HideTicker clears the ticker
+func (f *FakePlayer) HideTicker() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return fmt.Errorf("mpv not connected")
+	}
+	f.ticker = nil
+	return nil
+}
+
+// GetState returns the simulated player state
+func (f *FakePlayer) GetState() (models.PlayerState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return models.PlayerState{}, fmt.Errorf("mpv not connected")
+	}
+	return f.stateLocked(), nil
+}
+
+// SetPlayingSong marks whether a song (vs holding screen or BGM) is playing
+func (f *FakePlayer) SetPlayingSong(playing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.playingSong = playing
+}
+
+// IsPlayingSong reports whether a song is loaded
+func (f *FakePlayer) IsPlayingSong() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.playingSong
+}
+
+// StartPlaybackMonitor enables end-of-song detection for the current media
+func (f *FakePlayer) StartPlaybackMonitor() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.monitoring = true
+}
+
+// OnStateChange sets the callback for state changes
+func (f *FakePlayer) OnStateChange(fn func(state models.PlayerState)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.onStateChange = fn
+}
+
+// OnTrackEnd sets the callback for when a song finishes
+func (f *FakePlayer) OnTrackEnd(fn func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.onTrackEnd = fn
+}
+
+// load replaces the current media, honouring scripted failures
+func (f *FakePlayer) load(path string, image, song bool) error {
+	f.mu.Lock()
+	if !f.running {
+		f.mu.Unlock()
+		return fmt.Errorf("mpv not connected")
+	}
+	if err := f.failures[path]; err != nil {
+		f.mu.Unlock()
+		return err
+	}
+
+	wasSong := f.playingSong
+	f.unload()
+	f.current = path
+	f.image = image
+	switch {
+	case image:
+		f.playingSong = false
+	case song:
+		f.playingSong = true
+	default:
+		f.playingSong = wasSong // Plain loads keep the flag set by SetPlayingSong
+	}
+	if image {
+		f.trimLead, f.trimTail = 0, 0
+	} else {
+		f.duration = DefaultFakeDuration
+		if d, ok := f.durations[path]; ok {
+			f.duration = d
+		}
+		// Trimmed songs start late and end early, as mpv's start and end options do
+		f.position = f.trimLead
+		f.duration -= f.trimTail
+	}
+	f.loaded = append(f.loaded, path)
+	f.mu.Unlock()
+
+	f.notifyStateChange()
+	return nil
+}
+
+// failure returns the scripted load error for a path, if any
+func (f *FakePlayer) failure(path string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.failures[path]
+}
+
+// unload clears the current media; caller holds mu
+func (f *FakePlayer) unload() {
+	f.current = ""
+	f.image = false
+	f.backdrop = ""
+	f.vocalMix = false
+	f.vocalGain = 0
+	f.playingSong = false
+	f.monitoring = false
+	f.paused = false
+	f.position = 0
+	f.duration = 0
+}
+
+// setPaused changes the pause state
+func (f *FakePlayer) setPaused(paused bool) error {
+	f.mu.Lock()
+	if !f.running {
+		f.mu.Unlock()
+		return fmt.Errorf("mpv not connected")
+	}
+	f.paused = paused
+	f.mu.Unlock()
+	f.notifyStateChange()
+	return nil
+}
+
+// stateLocked builds the player state; caller holds mu
+func (f *FakePlayer) stateLocked() models.PlayerState {
+	return models.PlayerState{
+		Position:  f.position,
+		Duration:  f.duration,
+		IsPlaying: f.current != "" && !f.paused,
+		Volume:    f.volume,
+	}
+}
+
+// notifyStateChange fires the state change callback like an mpv property change
+func (f *FakePlayer) notifyStateChange() {
+	f.mu.Lock()
+	fn := f.onStateChange
+	state := f.stateLocked()
+	f.mu.Unlock()
+	if fn != nil {
+		fn(state)
+	}
+}
@@ -0,0 +1,172 @@
+package mpv
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newStartedFake(t *testing.T) *FakePlayer {
+	t.Helper()
+	f := NewFakePlayer()
+	if err := f.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	return f
+}
+
+// TestFakeTrackEndRequiresMonitor verifies end-of-track only fires for monitored songs,
+// matching the controller, where holding screens and BGM never end a song
+func TestFakeTrackEndRequiresMonitor(t *testing.T) {
+	f := newStartedFake(t)
+	ended := 0
+	f.OnTrackEnd(func() { ended++ })
+
+	f.SetDuration("/songs/a.mp4", 10)
+	f.LoadFile("/songs/a.mp4")
+	f.Advance(20 * time.Second)
+	if ended != 0 {
+		t.Errorf("Expected no track end without a song/monitor, got %d", ended)
+	}
+
+	f.SetPlayingSong(true)
+	f.LoadFile("/songs/a.mp4")
+	f.StartPlaybackMonitor()
+	f.Advance(9 * time.Second)
+	if ended != 0 {
+		t.Errorf("Expected no track end before the end, got %d", ended)
+	}
+	f.Advance(time.Second)
+	if ended != 1 {
+		t.Errorf("Expected one track end, got %d", ended)
+	}
+	if f.IsPlayingSong() {
+		t.Error("Expected playingSong to be cleared after the track ended")
+	}
+
+	// Further time at the end doesn't fire again
+	f.Advance(time.Second)
+	if ended != 1 {
+		t.Errorf("Expected track end to fire once, got %d", ended)
+	}
+}
+
+// TestFakeStemsAndCDGStartMonitor verifies stem and CDG loads detect song end on their own
+func TestFakeStemsAndCDGStartMonitor(t *testing.T) {
+	f := newStartedFake(t)
+	ended := 0
+	f.OnTrackEnd(func() { ended++ })
+
+	f.SetVocalMix("/songs/instr.wav", "/songs/vocal.wav", 0.3)
+	f.FinishTrack()
+	f.LoadCDG("/songs/a.cdg", "/songs/a.mp3")
+	f.FinishTrack()
+
+	if ended != 2 {
+		t.Errorf("Expected two track ends, got %d", ended)
+	}
+}
+
+// TestFakeVocalGainChangesLive verifies the vocal level changes without a reload
+func TestFakeVocalGainChangesLive(t *testing.T) {
+	f := newStartedFake(t)
+
+	if err := f.SetVocalGain(0.5); err == nil {
+		t.Error("Expected SetVocalGain to fail without stems loaded")
+	}
+
+	f.SetVocalMix("/songs/instr.wav", "/songs/vocal.wav", 0.15)
+	f.Advance(30 * time.Second)
+	if err := f.SetVocalGain(0.6); err != nil {
+		t.Fatalf("SetVocalGain failed: %v", err)
+	}
+	if f.VocalGain() != 0.6 {
+		t.Errorf("Expected vocal gain 0.6, got %v", f.VocalGain())
+	}
+	if state, _ := f.GetState(); state.Position != 30 {
+		t.Errorf("Expected position to be kept at 30s, got %v", state.Position)
+	}
+	if len(f.Loaded()) != 1 {
+		t.Errorf("Expected a single load, got %v", f.Loaded())
+	}
+
+	f.LoadImage("/tmp/holding.png")
+	if f.VocalGain() != 0 {
+		t.Errorf("Expected vocal gain cleared by the next load, got %v", f.VocalGain())
+	}
+}
+
+// TestFakeImageNeverEnds verifies holding screens don't advance or end
+func TestFakeImageNeverEnds(t *testing.T) {
+	f := newStartedFake(t)
+	f.OnTrackEnd(func() { t.Error("Holding screen should not end") })
+
+	f.SetPlayingSong(true)
+	f.LoadImage("/tmp/holding.png")
+	f.Advance(time.Hour)
+
+	if !f.ShowingImage() {
+		t.Error("Expected image to be showing")
+	}
+	if f.IsPlayingSong() {
+		t.Error("Expected LoadImage to clear playingSong")
+	}
+}
+
+// TestFakePauseAndTempo verifies position only moves while playing, scaled by tempo
+func TestFakePauseAndTempo(t *testing.T) {
+	f := newStartedFake(t)
+	f.LoadFile("/songs/a.mp4")
+
+	f.Pause()
+	f.Advance(5 * time.Second)
+	if state, _ := f.GetState(); state.Position != 0 || state.IsPlaying {
+		t.Errorf("Expected paused at 0, got %+v", state)
+	}
+
+	f.Play()
+	f.SetTempo(1.5)
+	f.Advance(2 * time.Second)
+	if state, _ := f.GetState(); state.Position != 3 || !state.IsPlaying {
+		t.Errorf("Expected playing at 3s, got %+v", state)
+	}
+
+	f.Seek(1000)
+	if state, _ := f.GetState(); state.Position != DefaultFakeDuration {
+		t.Errorf("Expected seek clamped to %.0f, got %.1f", DefaultFakeDuration, state.Position)
+	}
+}
+
+// TestFakeFailuresAndCrash verifies scripted load errors and a dead player
+func TestFakeFailuresAndCrash(t *testing.T) {
+	f := newStartedFake(t)
+	loadErr := errors.New("file not found")
+	f.FailLoad("/songs/missing.mp4", loadErr)
+
+	if err := f.LoadFile("/songs/missing.mp4"); err != loadErr {
+		t.Errorf("Expected scripted error, got %v", err)
+	}
+	if len(f.Loaded()) != 0 {
+		t.Errorf("Expected failed load not to be recorded, got %v", f.Loaded())
+	}
+
+	f.Crash()
+	if f.IsRunning() {
+		t.Error("Expected crashed player not to be running")
+	}
+	if err := f.LoadFile("/songs/a.mp4"); err == nil {
+		t.Error("Expected load on a crashed player to fail")
+	}
+	if _, err := f.GetState(); err == nil {
+		t.Error("Expected GetState on a crashed player to fail")
+	}
+
+	f.FailStart(errors.New("no display"))
+	if err := f.Restart(); err == nil {
+		t.Error("Expected restart to fail")
+	}
+	f.FailStart(nil)
+	if err := f.Restart(); err != nil || !f.IsRunning() {
+		t.Errorf("Expected restart to recover, got %v", err)
+	}
+}
@@ -0,0 +1,395 @@
+package mpv
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dexterlb/mpvipc"
+	"songmartyn/internal/logging"
+	"songmartyn/pkg/models"
+)
+
+// micLogger is the "mic" subsystem
+var micLogger = logging.For("mic")
+
+// NoiseGate mutes the mic below a threshold so handling noise and bleed stay quiet
+type NoiseGate struct {
+	Threshold float64 // Linear level (0-1) below which the gate closes
+	Ratio     float64 // Reduction ratio below the threshold
+	AttackMs  float64
+	ReleaseMs float64
+}
+
+// Compressor evens out loud and quiet passages
+type Compressor struct {
+	Threshold float64 // Linear level (0-1) above which gain is reduced
+	Ratio     float64
+	AttackMs  float64
+	ReleaseMs float64
+	Makeup    float64 // Linear makeup gain (1-64)
+}
+
+// Echo is a single distinct repeat
+type Echo struct {
+	DelayMs float64
+	Decay   float64 // Level of the repeat (0-1)
+}
+
+// Reverb is approximated with a cluster of short echoes whose levels fall away
+type Reverb struct {
+	RoomMs float64 // Delay of the first reflection; larger rooms sound bigger
+	Decay  float64 // Level ratio between successive reflections (0-1)
+	Mix    float64 // Level of the first reflection (0-1)
+}
+
+// reverbTaps spreads reflections at roughly prime ratios so they don't ring
+var reverbTaps = []float64{1, 1.43, 2.13, 3.05}
+
+// MicEffects is a microphone effects chain; nil stages are bypassed.
+// Stages run in the order gate, compressor, echo, reverb.
+type MicEffects struct {
+	Gate       *NoiseGate
+	Compressor *Compressor
+	Echo       *Echo
+	Reverb     *Reverb
+}
+
+// MicPresetInfo describes a preset for the singer's phone
+type MicPresetInfo struct {
+	ID          models.MicPreset `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+}
+
+// micPresets lists the presets in the order phones show them
+var micPresets = []struct {
+	info    MicPresetInfo
+	effects MicEffects
+}{
+	{
+		MicPresetInfo{models.MicPresetDry, "Dry", "Your voice as it is"},
+		MicEffects{},
+	},
+	{
+		MicPresetInfo{models.MicPresetStudio, "Studio", "Clean and even with a touch of room"},
+		MicEffects{
+			Gate:       &NoiseGate{Threshold: 0.01, Ratio: 4, AttackMs: 5, ReleaseMs: 250},
+			Compressor: &Compressor{Threshold: 0.125, Ratio: 3, AttackMs: 10, ReleaseMs: 150, Makeup: 2},
+			Reverb:     &Reverb{RoomMs: 23, Decay: 0.6, Mix: 0.25},
+		},
+	},
+	{
+		MicPresetInfo{models.MicPresetHall, "Concert Hall", "Smooth, long reverb for ballads"},
+		MicEffects{
+			Gate:       &NoiseGate{Threshold: 0.01, Ratio: 4, AttackMs: 5, ReleaseMs: 300},
+			Compressor: &Compressor{Threshold: 0.125, Ratio: 3, AttackMs: 10, ReleaseMs: 200, Makeup: 2},
+			Reverb:     &Reverb{RoomMs: 61, Decay: 0.8, Mix: 0.45},
+		},
+	},
+	{
+		MicPresetInfo{models.MicPresetEcho, "Slapback", "Quick rock'n'roll echo"},
+		MicEffects{
+			Gate:       &NoiseGate{Threshold: 0.01, Ratio: 4, AttackMs: 5, ReleaseMs: 250},
+			Compressor: &Compressor{Threshold: 0.125, Ratio: 3, AttackMs: 10, ReleaseMs: 150, Makeup: 2},
+			Echo:       &Echo{DelayMs: 120, Decay: 0.4},
+		},
+	},
+	{
+		MicPresetInfo{models.MicPresetStadium, "Stadium", "Big, loud and echoing"},
+		MicEffects{
+			Gate:       &NoiseGate{Threshold: 0.015, Ratio: 6, AttackMs: 5, ReleaseMs: 300},
+			Compressor: &Compressor{Threshold: 0.0625, Ratio: 6, AttackMs: 5, ReleaseMs: 200, Makeup: 3},
+			Echo:       &Echo{DelayMs: 380, Decay: 0.3},
+			Reverb:     &Reverb{RoomMs: 89, Decay: 0.85, Mix: 0.5},
+		},
+	},
+}
+
+// MicPresets returns the available presets
+func MicPresets() []MicPresetInfo {
+	infos := make([]MicPresetInfo, len(micPresets))
+	for i, p := range micPresets {
+		infos[i] = p.info
+	}
+	return infos
+}
+
+// MicEffectsFor returns the effects chain of a preset; "" is the dry preset
+func MicEffectsFor(preset models.MicPreset) (MicEffects, error) {
+	if preset == "" {
+		preset = models.MicPresetDry
+	}
+	for _, p := range micPresets {
+		if p.info.ID == preset {
+			return p.effects, nil
+		}
+	}
+	return MicEffects{}, fmt.Errorf("unknown mic preset: %s", preset)
+}
+
+// num formats a filter parameter without trailing zeros
+func num(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// FilterChain returns the lavfi filter chain for the effects
+func (e MicEffects) FilterChain() string {
+	var stages []string
+	if g := e.Gate; g != nil {
+		stages = append(stages, fmt.Sprintf("agate=threshold=%s:ratio=%s:attack=%s:release=%s",
+			num(g.Threshold), num(g.Ratio), num(g.AttackMs), num(g.ReleaseMs)))
+	}
+	if c := e.Compressor; c != nil {
+		stages = append(stages, fmt.Sprintf("acompressor=threshold=%s:ratio=%s:attack=%s:release=%s:makeup=%s",
+			num(c.Threshold), num(c.Ratio), num(c.AttackMs), num(c.ReleaseMs), num(c.Makeup)))
+	}
+	if ec := e.Echo; ec != nil {
+		stages = append(stages, fmt.Sprintf("aecho=0.8:0.9:%s:%s", num(ec.DelayMs), num(ec.Decay)))
+	}
+	if r := e.Reverb; r != nil {
+		delays := make([]string, len(reverbTaps))
+		decays := make([]string, len(reverbTaps))
+		level := r.Mix
+		for i, ratio := range reverbTaps {
+			delays[i] = num(float64(int(r.RoomMs*ratio + 0.5)))
+			decays[i] = num(float64(int(level*1000+0.5)) / 1000)
+			level *= r.Decay
+		}
+		stages = append(stages, fmt.Sprintf("aecho=0.8:0.9:%s:%s",
+			strings.Join(delays, "|"), strings.Join(decays, "|")))
+	}
+	if len(stages) == 0 {
+		return "anull"
+	}
+	return strings.Join(stages, ",")
+}
+
+// AudioFilter returns the labelled mpv audio filter for the effects
+func (e MicEffects) AudioFilter() string {
+	return "@micfx:lavfi=[" + e.FilterChain() + "]"
+}
+
+// micLevelLabel labels the level meter ahead of the effects; its astats
+// measurements show up in mpv's af-metadata property
+const micLevelLabel = "miclevel"
+
+// micLevelFilter measures the dry mic over a few frames at a time
+const micLevelFilter = "@" + micLevelLabel + ":lavfi=[astats=metadata=1:reset=5]"
+
+// MicSilenceDB is reported when the mic is digitally silent
+const MicSilenceDB = -120.0
+
+// parseMicLevel reads the overall RMS level from astats metadata
+func parseMicLevel(metadata interface{}) (float64, error) {
+	meta, ok := metadata.(map[string]interface{})
+	if !ok {
+		return 0, fmt.Errorf("no mic level metadata")
+	}
+	value, ok := meta["lavfi.astats.Overall.RMS_level"].(string)
+	if !ok {
+		return 0, fmt.Errorf("no mic level metadata")
+	}
+	if value == "-inf" {
+		return MicSilenceDB, nil
+	}
+	level, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad mic level %q: %w", value, err)
+	}
+	if level < MicSilenceDB {
+		level = MicSilenceDB
+	}
+	return level, nil
+}
+
+// DefaultMicDevice returns the system default capture device as an mpv URL
+// (empty on Windows, where DirectShow device names have to be configured)
+func DefaultMicDevice() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "av://avfoundation::0"
+	case "windows":
+		return ""
+	default:
+		return "av://pulse:default"
+	}
+}
+
+// MicInput routes a live microphone through an effects chain in its own
+// headless mpv instance, so effects can change without touching the backing track
+type MicInput struct {
+	executable string
+	device     string
+	socketPath string
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	conn    *mpvipc.Connection
+	effects MicEffects
+}
+
+// NewMicInput creates a mic input capturing from device (an mpv URL such as av://pulse:default)
+func NewMicInput(executable, device string) *MicInput {
+	if executable == "" {
+		executable = "mpv"
+	}
+	return &MicInput{
+		executable: executable,
+		device:     device,
+		socketPath: getSocketPathFor("mic"),
+	}
+}
+
+// Device returns the capture device URL
+func (m *MicInput) Device() string {
+	return m.device
+}
+
+// args returns the mpv command line for low-latency monitoring of the mic
+func (m *MicInput) args() []string {
+	args := []string{
+		"--no-video",
+		"--idle=no",
+		"--input-ipc-server=" + m.socketPath,
+		"--profile=low-latency",
+		"--untimed",
+		"--cache=no",
+		"--audio-buffer=0.05",
+		"--volume=100",
+		"--af=" + m.filterChain(),
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		args = append(args, "--ao=coreaudio")
+	case "windows":
+		args = append(args, "--ao=wasapi")
+	default:
+		args = append(args, "--ao=pipewire,pulse,alsa")
+	}
+	return append(args, m.device)
+}
+
+// filterChain returns the level meter followed by the effects; caller holds mu
+func (m *MicInput) filterChain() string {
+	return micLevelFilter + "," + m.effects.AudioFilter()
+}
+
+// Start launches the mic mpv instance with the current effects
+func (m *MicInput) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.device == "" {
+		return fmt.Errorf("no microphone device configured")
+	}
+	if m.conn != nil {
+		return nil
+	}
+	os.Remove(m.socketPath)
+
+	m.cmd = exec.Command(m.executable, m.args()...)
+	if err := m.cmd.Start(); err != nil {
+		m.cmd = nil
+		return fmt.Errorf("failed to start mic input: %w", err)
+	}
+	micLogger.Info("Capturing", "device", m.device, "pid", m.cmd.Process.Pid)
+
+	// Wait for the IPC socket
+	for i := 0; i < 50; i++ {
+		if runtime.GOOS != "windows" {
+			if _, err := os.Stat(m.socketPath); err == nil {
+				break
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	conn := mpvipc.NewConnection(m.socketPath)
+	if err := conn.Open(); err != nil {
+		m.cmd.Process.Kill()
+		m.cmd = nil
+		return fmt.Errorf("failed to connect to mic input IPC: %w", err)
+	}
+	m.conn = conn
+
+	// Reap the process so a capture failure shows up in IsRunning
+	cmd := m.cmd
+	go func() {
+		cmd.Wait()
+		m.mu.Lock()
+		if m.cmd == cmd {
+			micLogger.Info("Input stopped")
+			m.conn.Close()
+			m.conn = nil
+			m.cmd = nil
+		}
+		m.mu.Unlock()
+	}()
+	return nil
+}
+
+// Stop ends mic monitoring
+func (m *MicInput) Stop() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.conn != nil {
+		m.conn.Call("quit")
+		m.conn.Close()
+		m.conn = nil
+	}
+	if m.cmd != nil && m.cmd.Process != nil {
+		m.cmd.Process.Kill()
+	}
+	m.cmd = nil
+	os.Remove(m.socketPath)
+	return nil
+}
+
+// IsRunning reports whether the mic is being monitored
+func (m *MicInput) IsRunning() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.conn != nil
+}
+
+// Effects returns the current effects chain
+func (m *MicInput) Effects() MicEffects {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.effects
+}
+
+// SetEffects swaps the effects chain live; when stopped it is used on the next Start
+func (m *MicInput) SetEffects(effects MicEffects) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.effects = effects
+	if m.conn == nil {
+		return nil
+	}
+	_, err := m.conn.Call("af", "set", m.filterChain())
+	return err
+}
+
+// Level returns the mic's recent RMS level in dBFS, measured before the effects
+func (m *MicInput) Level() (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.conn == nil {
+		return 0, fmt.Errorf("mic input not running")
+	}
+	metadata, err := m.conn.Get("af-metadata/" + micLevelLabel)
+	if err != nil {
+		return 0, err
+	}
+	return parseMicLevel(metadata)
+}
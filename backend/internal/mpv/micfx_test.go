@@ -0,0 +1,157 @@
+package mpv
+
+import (
+	"strings"
+	"testing"
+
+	"songmartyn/pkg/models"
+)
+
+// =============================================================================
+// Mic Effects Filter Tests
+// =============================================================================
+
+// TestMicFilterChainStages verifies each stage renders as its lavfi filter, in chain order
+func TestMicFilterChainStages(t *testing.T) {
+	effects := MicEffects{
+		Gate:       &NoiseGate{Threshold: 0.01, Ratio: 4, AttackMs: 5, ReleaseMs: 250},
+		Compressor: &Compressor{Threshold: 0.125, Ratio: 3, AttackMs: 10, ReleaseMs: 150, Makeup: 2},
+		Echo:       &Echo{DelayMs: 120, Decay: 0.4},
+		Reverb:     &Reverb{RoomMs: 20, Decay: 0.5, Mix: 0.4},
+	}
+
+	expected := "agate=threshold=0.01:ratio=4:attack=5:release=250," +
+		"acompressor=threshold=0.125:ratio=3:attack=10:release=150:makeup=2," +
+		"aecho=0.8:0.9:120:0.4," +
+		"aecho=0.8:0.9:20|29|43|61:0.4|0.2|0.1|0.05"
+	if got := effects.FilterChain(); got != expected {
+		t.Errorf("Expected filter chain:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+// TestMicFilterChainBypassesMissingStages verifies nil stages are left out
+func TestMicFilterChainBypassesMissingStages(t *testing.T) {
+	effects := MicEffects{Echo: &Echo{DelayMs: 380, Decay: 0.3}}
+	if got := effects.FilterChain(); got != "aecho=0.8:0.9:380:0.3" {
+		t.Errorf("Expected a lone echo, got %s", got)
+	}
+
+	if got := (MicEffects{}).FilterChain(); got != "anull" {
+		t.Errorf("Expected anull for a dry chain, got %s", got)
+	}
+}
+
+// TestMicAudioFilterIsLabelled verifies the chain is wrapped so af set can replace it
+func TestMicAudioFilterIsLabelled(t *testing.T) {
+	effects, _ := MicEffectsFor(models.MicPresetEcho)
+	filter := effects.AudioFilter()
+	if !strings.HasPrefix(filter, "@micfx:lavfi=[") || !strings.HasSuffix(filter, "]") {
+		t.Errorf("Expected a labelled lavfi filter, got %s", filter)
+	}
+	if !strings.Contains(filter, "aecho=0.8:0.9:120:0.4") {
+		t.Errorf("Expected the slapback echo, got %s", filter)
+	}
+}
+
+// =============================================================================
+// Mic Preset Tests
+// =============================================================================
+
+// TestMicPresetsAllBuild verifies every listed preset resolves to a chain
+func TestMicPresetsAllBuild(t *testing.T) {
+	presets := MicPresets()
+	if len(presets) == 0 || presets[0].ID != models.MicPresetDry {
+		t.Fatalf("Expected dry to be listed first, got %+v", presets)
+	}
+	for _, p := range presets {
+		effects, err := MicEffectsFor(p.ID)
+		if err != nil {
+			t.Errorf("Preset %s: %v", p.ID, err)
+		}
+		if p.ID != models.MicPresetDry && effects.FilterChain() == "anull" {
+			t.Errorf("Preset %s has no effects", p.ID)
+		}
+	}
+}
+
+// TestMicEffectsForDefaultsAndUnknown verifies "" is dry and unknown names fail
+func TestMicEffectsForDefaultsAndUnknown(t *testing.T) {
+	effects, err := MicEffectsFor("")
+	if err != nil || effects.FilterChain() != "anull" {
+		t.Errorf("Expected empty preset to be dry, got %s (%v)", effects.FilterChain(), err)
+	}
+	if _, err := MicEffectsFor("karaoke-robot"); err == nil {
+		t.Error("Expected error for an unknown preset")
+	}
+}
+
+// =============================================================================
+// Mic Input Tests
+// =============================================================================
+
+// TestMicInputArgs verifies the capture device and effects are passed to mpv
+func TestMicInputArgs(t *testing.T) {
+	m := NewMicInput("", "av://pulse:default")
+	effects, _ := MicEffectsFor(models.MicPresetHall)
+	m.SetEffects(effects)
+
+	args := m.args()
+	if args[len(args)-1] != "av://pulse:default" {
+		t.Errorf("Expected the device last, got %v", args)
+	}
+	found := false
+	for _, arg := range args {
+		if arg == "--af="+micLevelFilter+","+effects.AudioFilter() {
+			found = true
+		}
+		if arg == "--video" || strings.HasPrefix(arg, "--force-window") {
+			t.Errorf("Mic input should not open a window, got %s", arg)
+		}
+	}
+	if !found {
+		t.Errorf("Expected the level meter and hall filter in %v", args)
+	}
+}
+
+// TestParseMicLevel verifies astats metadata is read as dBFS
+func TestParseMicLevel(t *testing.T) {
+	level, err := parseMicLevel(map[string]interface{}{"lavfi.astats.Overall.RMS_level": "-23.5"})
+	if err != nil || level != -23.5 {
+		t.Errorf("Expected -23.5 dB, got %v (%v)", level, err)
+	}
+	level, err = parseMicLevel(map[string]interface{}{"lavfi.astats.Overall.RMS_level": "-inf"})
+	if err != nil || level != MicSilenceDB {
+		t.Errorf("Expected silence at %v dB, got %v (%v)", MicSilenceDB, level, err)
+	}
+	if _, err := parseMicLevel(map[string]interface{}{}); err == nil {
+		t.Error("Expected an error without astats metadata")
+	}
+	if _, err := parseMicLevel(nil); err == nil {
+		t.Error("Expected an error without metadata")
+	}
+}
+
+// TestMicLevelRequiresRunningInput verifies Level fails before Start
+func TestMicLevelRequiresRunningInput(t *testing.T) {
+	if _, err := NewMicInput("", "").Level(); err == nil {
+		t.Error("Expected an error from a stopped mic input")
+	}
+}
+
+// TestMicInputSetEffectsWhileStopped verifies effects are kept until Start
+func TestMicInputSetEffectsWhileStopped(t *testing.T) {
+	m := NewMicInput("", "")
+	effects, _ := MicEffectsFor(models.MicPresetStudio)
+	if err := m.SetEffects(effects); err != nil {
+		t.Errorf("Expected SetEffects to succeed while stopped, got %v", err)
+	}
+	if m.Effects().Reverb == nil {
+		t.Error("Expected the studio reverb to be kept")
+	}
+	if err := m.Start(); err == nil {
+		t.Error("Expected Start to fail without a device")
+	}
+	if m.IsRunning() {
+		t.Error("Expected mic input not to be running")
+	}
+}
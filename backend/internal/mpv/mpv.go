@@ -3,7 +3,7 @@ package mpv
 import (
 	"bufio"
 	"fmt"
-	"log"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -14,9 +14,13 @@ import (
 	"time"
 
 	"github.com/dexterlb/mpvipc"
+	"songmartyn/internal/logging"
 	"songmartyn/pkg/models"
 )
 
+// logger is the "mpv" subsystem; the playback monitor logs positions at debug level
+var logger = logging.For("mpv")
+
 // DisplaySettings configures which display to use for the player
 type DisplaySettings struct {
 	TargetDisplay  string // Name of display to use (empty = auto/primary)
@@ -33,6 +37,8 @@ type Controller struct {
 	executable string
 	mu         sync.RWMutex
 	adopted    bool // true if we adopted an existing MPV instance
+	name       string // Output name ("" for the primary output)
+	audio      bool   // false for video-only outputs (audio goes to the primary)
 
 	// Display settings
 	displaySettings DisplaySettings
@@ -42,8 +48,22 @@ type Controller struct {
 	currentPlaylistID int64   // playlist_entry_id of current content
 	songDuration      float64 // duration of current song in seconds
 	lastPosition      float64 // last known position for end detection
+	trimTail          float64 // seconds cut from the end of songs by SetTrim
 	stopMonitor       chan struct{} // channel to stop playback monitor
 
+	// Holding screen video (LoadHoldingVideo); its own lock since LoadFile only holds mu for reading
+	backdropMu     sync.Mutex
+	backdrop       string // Video looping under the holding screen ("" when none)
+	backdropTracks int    // Overlay images added on top of it so far
+
+	// Reactions floating on screen (see ShowReaction); each holds an overlay ID
+	reactionMu    sync.Mutex
+	reactionSlots [MaxReactions]bool
+
+	// Background music plays in its own mpv (see bgmTrack)
+	bgmMu sync.Mutex
+	bgm   *bgmTrack
+
 	// Callbacks
 	onStateChange func(state models.PlayerState)
 	onTrackEnd    func()
@@ -61,6 +81,7 @@ func NewController(executable string) *Controller {
 		socketPath: socketPath,
 		pidFile:    pidFile,
 		executable: executable,
+		audio:      true,
 		displaySettings: DisplaySettings{
 			ScreenIndex:    -1,   // Auto
 			AutoFullscreen: true, // Default to fullscreen
@@ -68,13 +89,37 @@ func NewController(executable string) *Controller {
 	}
 }
 
+// NewOutputController creates a controller for an additional named output
+// Each output gets its own socket and PID file so instances don't collide
+func NewOutputController(name, executable string, audio bool) *Controller {
+	c := NewController(executable)
+	c.name = name
+	c.audio = audio
+	c.socketPath = getSocketPathFor(name)
+	c.pidFile = getPidFilePathFor(name)
+	return c
+}
+
+// NewRoomController creates the primary player for an extra karaoke room
+// Each room gets its own socket and PID file so rooms never adopt each other's mpv
+func NewRoomController(room, executable string) *Controller {
+	c := NewController(executable)
+	c.socketPath = getSocketPathFor("room-" + room)
+	c.pidFile = getPidFilePathFor("room-" + room)
+	return c
+}
+
+// Name returns the output name ("" for the primary output)
+func (c *Controller) Name() string {
+	return c.name
+}
+
 // SetDisplaySettings configures which display to use for the player
 func (c *Controller) SetDisplaySettings(settings DisplaySettings) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.displaySettings = settings
-	log.Printf("[MPV] Display settings updated: screen=%d, fullscreen=%v, target=%s",
-		settings.ScreenIndex, settings.AutoFullscreen, settings.TargetDisplay)
+	logger.Info("Display settings updated", "screen", settings.ScreenIndex, "fullscreen", settings.AutoFullscreen, "target", settings.TargetDisplay)
 }
 
 // GetDisplaySettings returns the current display settings
@@ -97,6 +142,59 @@ func getSocketPath() string {
 	return filepath.Join(os.TempDir(), "songmartyn-mpv.sock")
 }
 
+// getPidFilePathFor returns the PID file path for a named output
+func getPidFilePathFor(name string) string {
+	if name == "" {
+		return getPidFilePath()
+	}
+	return filepath.Join(os.TempDir(), "songmartyn-mpv-"+name+".pid")
+}
+
+// getSocketPathFor returns the IPC socket path for a named output
+func getSocketPathFor(name string) string {
+	if name == "" {
+		return getSocketPath()
+	}
+	if runtime.GOOS == "windows" {
+		return `\\.\pipe\songmartyn-mpv-` + name
+	}
+	return filepath.Join(os.TempDir(), "songmartyn-mpv-"+name+".sock")
+}
+
+// audioOutputArg returns the platform's mpv audio output
+// Video-only outputs decode audio for timing but never play it
+func audioOutputArg(audio bool) string {
+	switch {
+	case !audio:
+		return "--ao=null"
+	case runtime.GOOS == "darwin":
+		return "--ao=coreaudio"
+	case runtime.GOOS == "windows":
+		return "--ao=wasapi"
+	default: // Linux and others
+		return "--ao=pipewire,pulse,alsa"
+	}
+}
+
+// waitForSocket waits up to 5 seconds for a newly started mpv's IPC socket
+func waitForSocket(socketPath string) {
+	for i := 0; i < 50; i++ {
+		if runtime.GOOS == "windows" {
+			// On Windows, try to connect to named pipe
+			conn := mpvipc.NewConnection(socketPath)
+			if err := conn.Open(); err == nil {
+				conn.Close()
+				return
+			}
+		} else {
+			if _, err := os.Stat(socketPath); err == nil {
+				return
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
 // tryReconnect attempts to connect to an existing MPV instance
 // Returns true if successfully connected and the instance is healthy
 func (c *Controller) tryReconnect() bool {
@@ -107,23 +205,23 @@ func (c *Controller) tryReconnect() bool {
 		}
 	}
 
-	log.Printf("[MPV] Attempting to reconnect to existing instance at %s", c.socketPath)
+	logger.Info("Attempting to reconnect to existing instance", "socket", c.socketPath)
 
 	conn := mpvipc.NewConnection(c.socketPath)
 	if err := conn.Open(); err != nil {
-		log.Printf("[MPV] Failed to connect to existing socket: %v", err)
+		logger.Info("No existing instance to reconnect to", "err", err)
 		return false
 	}
 
 	// Test the connection by getting a property
 	_, err := conn.Get("mpv-version")
 	if err != nil {
-		log.Printf("[MPV] Existing connection unhealthy: %v", err)
+		logger.Warn("Existing connection unhealthy", "err", err)
 		conn.Close()
 		return false
 	}
 
-	log.Printf("[MPV] Successfully reconnected to existing MPV instance")
+	logger.Info("Reconnected to existing instance")
 	c.conn = conn
 	c.adopted = true
 
@@ -149,7 +247,7 @@ func (c *Controller) readPid() (int, error) {
 
 // cleanupOrphans finds and kills orphaned MPV processes that belong to us
 func (c *Controller) cleanupOrphans() {
-	log.Printf("[MPV] Cleaning up orphaned processes...")
+	logger.Info("Cleaning up orphaned processes")
 
 	// First, try to gracefully quit via socket if it exists
 	if c.tryGracefulQuit() {
@@ -186,14 +284,14 @@ func (c *Controller) tryGracefulQuit() bool {
 	}
 	defer conn.Close()
 
-	log.Printf("[MPV] Sending graceful quit command to existing instance")
+	logger.Info("Sending quit to existing instance")
 	conn.Call("quit")
 	return true
 }
 
 // killProcessByPid kills a process by its PID
 func (c *Controller) killProcessByPid(pid int) {
-	log.Printf("[MPV] Attempting to kill process with PID %d", pid)
+	logger.Info("Killing process", "pid", pid)
 
 	proc, err := os.FindProcess(pid)
 	if err != nil {
@@ -228,7 +326,7 @@ func (c *Controller) killOrphansUnix() {
 	// Method 1: Use lsof to find process using our socket
 	if pids := c.findPidsByLsof(); len(pids) > 0 {
 		for _, pid := range pids {
-			log.Printf("[MPV] Killing orphaned process %d (found via lsof)", pid)
+			logger.Info("Killing orphaned process", "pid", pid, "found_via", "lsof")
 			c.killProcessByPid(pid)
 		}
 		return
@@ -237,7 +335,7 @@ func (c *Controller) killOrphansUnix() {
 	// Method 2: Use pgrep to find mpv processes with our socket in args
 	if pids := c.findPidsByPgrep(); len(pids) > 0 {
 		for _, pid := range pids {
-			log.Printf("[MPV] Killing orphaned process %d (found via pgrep)", pid)
+			logger.Info("Killing orphaned process", "pid", pid, "found_via", "pgrep")
 			c.killProcessByPid(pid)
 		}
 	}
@@ -279,6 +377,21 @@ func (c *Controller) findPidsByPgrep() []int {
 	return pids
 }
 
+// usesSocket reports whether a command line references exactly this socket path
+// (the primary's pipe name is a prefix of every named output's pipe name)
+func usesSocket(cmdline, socketPath string) bool {
+	for rest := cmdline; ; {
+		idx := strings.Index(rest, socketPath)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(socketPath):]
+		if rest == "" || (rest[0] != '-' && rest[0] != '.') {
+			return true
+		}
+	}
+}
+
 // killOrphansWindows finds and kills MPV processes on Windows
 func (c *Controller) killOrphansWindows() {
 	// Use tasklist/taskkill with window title or command line matching
@@ -292,12 +405,12 @@ func (c *Controller) killOrphansWindows() {
 	scanner := bufio.NewScanner(strings.NewReader(string(output)))
 	for scanner.Scan() {
 		line := scanner.Text()
-		if strings.Contains(line, c.socketPath) {
+		if usesSocket(line, c.socketPath) {
 			// Extract PID from CSV line (format: Node,CommandLine,ProcessId)
 			parts := strings.Split(line, ",")
 			if len(parts) >= 3 {
 				if pid, err := strconv.Atoi(strings.TrimSpace(parts[len(parts)-1])); err == nil {
-					log.Printf("[MPV] Killing orphaned Windows process %d", pid)
+					logger.Info("Killing orphaned process", "pid", pid, "found_via", "tasklist")
 					exec.Command("taskkill", "/F", "/PID", strconv.Itoa(pid)).Run()
 				}
 			}
@@ -313,15 +426,16 @@ func (c *Controller) Start() error {
 
 	// Strategy 1: Try to reconnect to existing healthy instance
 	if c.tryReconnect() {
-		log.Printf("[MPV] Adopted existing MPV instance")
+		logger.Info("Adopted existing instance")
 		return nil
 	}
 
 	// Strategy 2: Clean up any orphaned processes
 	c.cleanupOrphans()
+	quitStaleBGM(bgmSocketPath(c.socketPath))
 
 	// Strategy 3: Start fresh MPV instance
-	log.Printf("[MPV] Starting fresh MPV instance")
+	logger.Info("Starting fresh instance")
 
 	args := []string{
 		"--idle=yes",
@@ -339,26 +453,18 @@ func (c *Controller) Start() error {
 		screenArg := fmt.Sprintf("--screen=%d", c.displaySettings.ScreenIndex)
 		fsScreenArg := fmt.Sprintf("--fs-screen=%d", c.displaySettings.ScreenIndex)
 		args = append(args, screenArg, fsScreenArg)
-		log.Printf("[MPV] Using screen index: %d", c.displaySettings.ScreenIndex)
+		logger.Info("Using screen", "screen", c.displaySettings.ScreenIndex)
 	}
 
 	// Fullscreen setting
 	if c.displaySettings.AutoFullscreen {
 		args = append(args, "--fullscreen=yes")
-		log.Printf("[MPV] Starting in fullscreen mode")
+		logger.Info("Starting in fullscreen mode")
 	} else {
 		args = append(args, "--fullscreen=no")
 	}
 
-	// Platform-specific audio output
-	switch runtime.GOOS {
-	case "darwin":
-		args = append(args, "--ao=coreaudio")
-	case "windows":
-		args = append(args, "--ao=wasapi")
-	default: // Linux and others
-		args = append(args, "--ao=pipewire,pulse,alsa")
-	}
+	args = append(args, audioOutputArg(c.audio))
 
 	c.cmd = exec.Command(c.executable, args...)
 	c.adopted = false
@@ -369,24 +475,9 @@ func (c *Controller) Start() error {
 
 	// Save PID for future cleanup
 	c.savePid(c.cmd.Process.Pid)
-	log.Printf("[MPV] Started with PID %d", c.cmd.Process.Pid)
+	logger.Info("Started", "pid", c.cmd.Process.Pid)
 
-	// Wait for socket to be ready
-	for i := 0; i < 50; i++ {
-		if runtime.GOOS == "windows" {
-			// On Windows, try to connect to named pipe
-			conn := mpvipc.NewConnection(c.socketPath)
-			if err := conn.Open(); err == nil {
-				conn.Close()
-				break
-			}
-		} else {
-			if _, err := os.Stat(c.socketPath); err == nil {
-				break
-			}
-		}
-		time.Sleep(100 * time.Millisecond)
-	}
+	waitForSocket(c.socketPath)
 
 	// Connect to IPC
 	conn := mpvipc.NewConnection(c.socketPath)
@@ -405,6 +496,8 @@ func (c *Controller) Start() error {
 
 // Stop terminates the mpv process
 func (c *Controller) Stop() error {
+	c.stopBGMTrack()
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -452,6 +545,32 @@ func (c *Controller) IsRunning() bool {
 	return c.conn != nil && c.cmd != nil && c.cmd.Process != nil
 }
 
+// Detach disconnects from mpv but leaves it running, so the next process adopts it on Start
+// The PID file stays so a failed adoption can still clean the instance up
+// Background music is stopped; the next process starts it again
+func (c *Controller) Detach() error {
+	c.stopPlaybackMonitor()
+	c.stopBGMTrack()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+	c.cmd = nil
+	c.adopted = false
+	logger.Info("Detached, leaving mpv running for the next process")
+	return nil
+}
+
+// Adopted reports whether Start reconnected to an mpv instance that was already running
+func (c *Controller) Adopted() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.adopted
+}
+
 // Restart restarts the mpv process
 func (c *Controller) Restart() error {
 	// Stop if running
@@ -498,6 +617,8 @@ func (c *Controller) LoadFile(path string) error {
 
 	// Reset loop settings from image display
 	c.conn.Set("loop-file", "no")
+	c.clearVocalMix()
+	c.clearBackdrop()
 
 	_, err := c.conn.Call("loadfile", path)
 	return err
@@ -519,13 +640,70 @@ func (c *Controller) LoadImage(path string) error {
 	// First set the properties for image display
 	c.conn.Set("image-display-duration", "inf")
 	c.conn.Set("loop-file", "inf")
+	c.clearVocalMix()
+	c.clearBackdrop()
+	c.clearTrim()
 
 	// Then load the image
 	_, err := c.conn.Call("loadfile", path, "replace")
 	return err
 }
 
-// LoadBGMWithImage loads BGM audio while keeping a static image displayed
+// LoadHoldingVideo loops a background video, muted, with the holding screen image over it
+// overlayPath is a transparent PNG; loading a new overlay over the same video doesn't restart it
+func (c *Controller) LoadHoldingVideo(videoPath, overlayPath string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return fmt.Errorf("mpv not connected")
+	}
+
+	c.playingSong = false
+	c.clearVocalMix()
+	c.clearTrim()
+
+	c.backdropMu.Lock()
+	defer c.backdropMu.Unlock()
+	if c.backdrop != videoPath {
+		c.conn.Set("lavfi-complex", "")
+		c.conn.Set("image-display-duration", "inf")
+		c.conn.Set("loop-file", "inf")
+		c.conn.Set("aid", "no")
+		if _, err := c.conn.Call("loadfile", videoPath, "replace"); err != nil {
+			c.backdrop = ""
+			return err
+		}
+		c.backdrop = videoPath
+		c.backdropTracks = 0
+	}
+
+	// Each overlay becomes another video track; the filter draws the newest one over the video
+	if _, err := c.conn.Call("video-add", overlayPath, "auto"); err != nil {
+		return fmt.Errorf("failed to add holding screen overlay: %w", err)
+	}
+	c.backdropTracks++
+	graph := fmt.Sprintf("[vid1] [vid%d] overlay [vo]", c.backdropTracks+1)
+	if err := c.conn.Set("lavfi-complex", graph); err != nil {
+		return fmt.Errorf("failed to overlay holding screen: %w", err)
+	}
+	return nil
+}
+
+// clearBackdrop removes the holding video's overlay and unmutes audio for the next file
+func (c *Controller) clearBackdrop() {
+	c.backdropMu.Lock()
+	defer c.backdropMu.Unlock()
+	if c.backdrop == "" {
+		return
+	}
+	c.backdrop = ""
+	c.backdropTracks = 0
+	c.conn.Set("lavfi-complex", "")
+	c.conn.Set("aid", "auto")
+}
+
+// LoadBGMWithImage shows a static image and starts BGM audio under it in its own mpv
 func (c *Controller) LoadBGMWithImage(imagePath, audioURL string, targetVolume float64) error {
 	c.mu.Lock()
 
@@ -534,44 +712,35 @@ func (c *Controller) LoadBGMWithImage(imagePath, audioURL string, targetVolume f
 		return fmt.Errorf("mpv not connected")
 	}
 
-	log.Printf("[MPV] Loading BGM with image: %s, audio: %s", imagePath, audioURL)
-
-	// Set volume to 0 for fade-in
-	c.conn.Set("volume", 0)
+	logger.Info("Loading BGM", "image", imagePath, "audio", audioURL)
 
 	// Set image to display infinitely and loop
 	c.conn.Set("image-display-duration", "inf")
 	c.conn.Set("loop-file", "inf")
+	c.clearVocalMix()
+	c.clearBackdrop()
+	c.clearTrim()
 
-	// Load the image first
 	_, err := c.conn.Call("loadfile", imagePath, "replace")
+	c.mu.Unlock()
 	if err != nil {
-		c.mu.Unlock()
-		log.Printf("[MPV] Failed to load image: %v", err)
+		logger.Error("Failed to load image", "err", err)
 		return err
 	}
 
-	c.mu.Unlock()
-
-	// Wait for image to start loading (outside mutex)
-	time.Sleep(500 * time.Millisecond)
-
-	// Now add the audio track
-	c.mu.Lock()
-	if c.conn != nil {
-		_, err = c.conn.Call("audio-add", audioURL, "select")
-		if err != nil {
-			log.Printf("[MPV] audio-add failed: %v - BGM will play without holding screen", err)
-			// Fallback: just load the audio directly
-			c.conn.Call("loadfile", audioURL, "replace")
-		} else {
-			log.Printf("[MPV] Successfully added audio track")
-		}
+	// Replace any music still playing
+	c.stopBGMTrack()
+	track, err := startBGMTrack(c.executable, bgmSocketPath(c.socketPath), audioURL, targetVolume)
+	if err != nil {
+		logger.Error("Failed to start BGM audio", "err", err)
+		return err
 	}
-	c.mu.Unlock()
+	c.bgmMu.Lock()
+	c.bgm = track
+	c.bgmMu.Unlock()
 
 	// Fade in the volume over 2 seconds
-	go c.fadeVolume(0, targetVolume, 2*time.Second)
+	go track.ramp(1, 2*time.Second)
 
 	return nil
 }
@@ -585,84 +754,40 @@ func (c *Controller) UpdateBGMImage(imagePath string) error {
 		return fmt.Errorf("mpv not connected")
 	}
 
-	log.Printf("[MPV] Updating BGM image to: %s", imagePath)
+	logger.Info("Updating BGM image", "image", imagePath)
 
-	// Get current volume to preserve it
-	vol, _ := c.conn.Get("volume")
-	currentVol, ok := vol.(float64)
-	if !ok {
-		currentVol = 50
-	}
-
-	// Get current audio URL - we need to re-add it after loading new image
-	// We'll use the audio-reload command or just load the image as external file
-
-	// Use video-add to replace the video track while keeping audio
-	// This loads the image into the video slot without affecting audio
+	// Use video-add to replace the video track; the music plays in its own mpv
 	_, err := c.conn.Call("video-add", imagePath, "select")
 	if err != nil {
-		log.Printf("[MPV] video-add failed: %v, trying loadfile approach", err)
+		logger.Warn("video-add failed", "err", err)
 		// Fallback: The image update will happen when BGM stops
 		return err
 	}
-
-	// Restore volume in case it was affected
-	c.conn.Set("volume", currentVol)
-
 	return nil
 }
 
-// StopBGMWithFade stops BGM with a fade out effect
+// StopBGMWithFade fades the BGM out (blocking), then stops it and clears the image
 func (c *Controller) StopBGMWithFade(fadeDuration time.Duration) error {
-	c.mu.Lock()
-	if c.conn == nil {
-		c.mu.Unlock()
+	c.mu.RLock()
+	connected := c.conn != nil
+	c.mu.RUnlock()
+	if !connected {
 		return fmt.Errorf("mpv not connected")
 	}
 
-	// Get current volume
-	vol, err := c.conn.Get("volume")
-	c.mu.Unlock()
-
-	if err != nil {
-		log.Printf("[MPV] Failed to get volume for fade: %v, using default", err)
-		vol = 50.0
-	}
-
-	currentVol, ok := vol.(float64)
-	if !ok {
-		currentVol = 50
+	if track := c.takeBGM(); track != nil {
+		logger.Info("Stopping BGM with fade", "volume", track.volume)
+		track.ramp(0, fadeDuration)
+		track.close()
 	}
 
-	log.Printf("[MPV] Stopping BGM with fade from volume %.0f", currentVol)
-
-	// Fade out (blocking)
-	c.fadeVolume(currentVol, 0, fadeDuration)
-
-	// Stop playback after fade completes
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	_, err = c.conn.Call("stop")
-	return err
-}
-
-// fadeVolume smoothly transitions volume from start to end over duration
-func (c *Controller) fadeVolume(start, end float64, duration time.Duration) {
-	steps := 20
-	stepDuration := duration / time.Duration(steps)
-	volumeStep := (end - start) / float64(steps)
-
-	for i := 0; i <= steps; i++ {
-		vol := start + volumeStep*float64(i)
-		c.mu.Lock()
-		if c.conn != nil {
-			c.conn.Set("volume", vol)
-		}
-		c.mu.Unlock()
-		if i < steps {
-			time.Sleep(stepDuration)
-		}
+	if c.conn == nil {
+		return fmt.Errorf("mpv not connected")
 	}
+	_, err := c.conn.Call("stop")
+	return err
 }
 
 // LoadCDG loads a CDG file with its paired audio file
@@ -683,6 +808,8 @@ func (c *Controller) LoadCDG(cdgPath, audioPath string) error {
 
 	// Clear any previous audio-files setting
 	c.conn.Set("audio-files", "")
+	c.clearVocalMix()
+	c.clearBackdrop()
 
 	// Load CDG file first
 	_, err := c.conn.Call("loadfile", cdgPath, "replace")
@@ -701,7 +828,7 @@ func (c *Controller) LoadCDG(cdgPath, audioPath string) error {
 	if c.conn != nil {
 		_, err = c.conn.Call("audio-add", audioPath, "select")
 		if err != nil {
-			log.Printf("[MPV] audio-add failed: %v, trying loadfile with audio-files", err)
+			logger.Warn("audio-add failed, trying loadfile with audio-files", "err", err)
 			// Fallback: try loadfile with escaped audio-files option
 			escapedPath := strings.ReplaceAll(audioPath, "\\", "\\\\")
 			escapedPath = strings.ReplaceAll(escapedPath, " ", "\\ ")
@@ -798,6 +925,78 @@ func (c *Controller) SetTempo(speed float64) error {
 	return c.conn.Set("speed", speed)
 }
 
+// SetGain applies a loudness normalization gain in dB (0 removes it)
+// The @songgain label lets each track replace the previous track's gain
+func (c *Controller) SetGain(db float64) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.conn == nil {
+		return fmt.Errorf("mpv not connected")
+	}
+
+	// Removing a filter that isn't there fails harmlessly
+	c.conn.Call("af", "remove", "@songgain")
+	if db == 0 {
+		return nil
+	}
+	_, err := c.conn.Call("af", "add", fmt.Sprintf("@songgain:volume=volume=%.2fdB", db))
+	return err
+}
+
+// fadeLabel names the audio filter SetFade adjusts
+const fadeLabel = "fade"
+
+// SetFade sets the transition level (0-1) of the audio and picture
+// Audio goes through a labelled volume filter changed in place; the picture dims via brightness
+func (c *Controller) SetFade(level float64) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.conn == nil {
+		return fmt.Errorf("mpv not connected")
+	}
+
+	level = math.Max(0, math.Min(1, level))
+	c.conn.Set("brightness", int(math.Round((level-1)*100)))
+	if _, err := c.conn.Call("af-command", fadeLabel, "volume", fmt.Sprintf("%.3f", level)); err == nil {
+		return nil
+	}
+	// First fade: the filter isn't there yet
+	_, err := c.conn.Call("af", "add", fmt.Sprintf("@%s:volume=volume=%.3f", fadeLabel, level))
+	return err
+}
+
+// SetTrim cuts lead seconds from the start and tail seconds from the end of the next song loaded
+func (c *Controller) SetTrim(lead, tail float64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return fmt.Errorf("mpv not connected")
+	}
+
+	start, end := "none", "none"
+	if lead > 0 {
+		start = fmt.Sprintf("+%.2f", lead)
+	}
+	if tail > 0 {
+		end = fmt.Sprintf("-%.2f", tail)
+	} else {
+		tail = 0
+	}
+	c.trimTail = tail
+	c.conn.Set("start", start)
+	return c.conn.Set("end", end)
+}
+
+// clearTrim plays the next file in full; caller holds mu
+func (c *Controller) clearTrim() {
+	c.trimTail = 0
+	c.conn.Set("start", "none")
+	c.conn.Set("end", "none")
+}
+
 // ShowOverlay displays text on screen for a specified duration
 // Used for singer name announcements at song start
 func (c *Controller) ShowOverlay(text string, durationMs int) error {
@@ -919,6 +1118,39 @@ type TickerEntry struct {
 	SongTitle  string
 }
 
+// panelOverlayID is the OSD overlay the next-up panel draws on (reactions use the IDs below it)
+const panelOverlayID = MaxReactions
+
+// maxPanelEntries is how many upcoming singers the next-up panel lists
+const maxPanelEntries = 5
+
+// nextUpPanel returns the ASS events for a panel listing upcoming singers in the top right corner
+// Returns "" when nobody is up next
+func nextUpPanel(entries []TickerEntry) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	if len(entries) > maxPanelEntries {
+		entries = entries[:maxPanelEntries]
+	}
+
+	var panel strings.Builder
+	panel.WriteString(`{\an9\pos(1880,40)\bord3\shad0\3c&H000000&}{\fs44\b1}Up Next{\b0\fs34}`)
+	for i, entry := range entries {
+		fmt.Fprintf(&panel, `\N%d. %s - %s`, i+1, assEscape(entry.SingerName), assEscape(entry.SongTitle))
+	}
+	return panel.String()
+}
+
+// ShowPanel draws ASS events as a persistent panel that stays up across file loads
+// An empty panel removes it
+func (c *Controller) ShowPanel(events string) error {
+	if events == "" {
+		return c.call("osd-overlay", panelOverlayID, "none", "")
+	}
+	return c.call("osd-overlay", panelOverlayID, "ass-events", events, 1920, 1080)
+}
+
 // GetState returns the current player state
 func (c *Controller) GetState() (models.PlayerState, error) {
 	c.mu.RLock()
@@ -937,10 +1169,10 @@ func (c *Controller) GetState() (models.PlayerState, error) {
 		}
 	}
 
-	// Get duration
+	// Get duration (songs end early when trimmed)
 	if dur, err := c.conn.Get("duration"); err == nil && dur != nil {
 		if f, ok := dur.(float64); ok {
-			state.Duration = f
+			state.Duration = f - c.trimTail
 		}
 	}
 
@@ -961,8 +1193,31 @@ func (c *Controller) GetState() (models.PlayerState, error) {
 	return state, nil
 }
 
-// SetVocalMix configures the audio filter for vocal assist mixing
-// Uses lavfi-complex to mix instrumental and vocal tracks
+// vocalMixLabel labels the af filter that blends the vocal stem back in
+const vocalMixLabel = "vocalmix"
+
+// stemsGraph lays both stems side by side as one 4-channel stream
+// (instrumental left/right, then vocal left/right) so the af chain can mix them
+const stemsGraph = "[aid1]aformat=channel_layouts=stereo[instr];[aid2]aformat=channel_layouts=stereo[vox];[instr][vox]amerge=inputs=2[ao]"
+
+// VocalMixFilter returns the labelled af filter mixing the stems to stereo with
+// the vocal stem at gain (0-1). Its volume@vocal stage takes live "volume" commands.
+func VocalMixFilter(gain float64) string {
+	graph := fmt.Sprintf("asplit=2[a][b];[a]pan=stereo|c0=c0|c1=c1[instr];"+
+		"[b]pan=stereo|c0=c2|c1=c3,volume@vocal=%.3f[vox];"+
+		"[instr][vox]amerge=inputs=2,pan=stereo|c0=c0+c2|c1=c1+c3", models.ClampVocalGain(gain))
+	// %n% quoting, since the graph has brackets of its own
+	return fmt.Sprintf("@%s:lavfi=graph=%%%d%%%s", vocalMixLabel, len(graph), graph)
+}
+
+// clearVocalMix removes the stem mixer before loading anything else; caller holds mu
+func (c *Controller) clearVocalMix() {
+	// Removing a filter that isn't there fails harmlessly
+	c.conn.Call("af", "remove", "@"+vocalMixLabel)
+}
+
+// SetVocalMix loads the instrumental stem with the vocal stem mixed in at vocalGain (0-1)
+// The vocal stem is always loaded so SetVocalGain can bring it in later without a reload
 func (c *Controller) SetVocalMix(instrumentalPath, vocalPath string, vocalGain float64) error {
 	c.mu.Lock()
 
@@ -974,23 +1229,16 @@ func (c *Controller) SetVocalMix(instrumentalPath, vocalPath string, vocalGain f
 	// Mark that we're playing a song
 	c.playingSong = true
 
-	var err error
-	if vocalGain <= 0 {
-		// No vocals needed, just load instrumental
-		_, err = c.conn.Call("loadfile", instrumentalPath)
-	} else {
-		// Build lavfi-complex filter for mixing
+	c.conn.Set("loop-file", "no")
+	c.clearVocalMix()
+	c.clearBackdrop()
+	_, err := c.conn.Call("af", "add", VocalMixFilter(vocalGain))
+	if err == nil {
 		// [aid1] = instrumental, [aid2] = vocals
-		filter := fmt.Sprintf(
-			"[aid1]volume=1.0[instr];[aid2]volume=%.2f[vox];[instr][vox]amix=inputs=2:duration=longest[ao]",
-			vocalGain,
-		)
-
-		// Load with external audio file and filter
 		_, err = c.conn.Call("loadfile", instrumentalPath,
 			"replace",
 			fmt.Sprintf("audio-files=%s", vocalPath),
-			fmt.Sprintf("lavfi-complex=%s", filter),
+			fmt.Sprintf("lavfi-complex=%s", stemsGraph),
 		)
 	}
 	c.mu.Unlock()
@@ -1002,6 +1250,18 @@ func (c *Controller) SetVocalMix(instrumentalPath, vocalPath string, vocalGain f
 	return err
 }
 
+// SetVocalGain changes the vocal stem level (0-1) of the playing stems without reloading
+func (c *Controller) SetVocalGain(gain float64) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.conn == nil {
+		return fmt.Errorf("mpv not connected")
+	}
+	_, err := c.conn.Call("af-command", vocalMixLabel, "volume", fmt.Sprintf("%.3f", models.ClampVocalGain(gain)))
+	return err
+}
+
 // SetPlayingSong marks whether we're currently playing a song (vs holding screen or BGM)
 // Used to determine if onTrackEnd should fire when playback ends
 func (c *Controller) SetPlayingSong(playing bool) {
@@ -1010,6 +1270,13 @@ func (c *Controller) SetPlayingSong(playing bool) {
 	c.playingSong = playing
 }
 
+// IsPlayingSong reports whether a song (rather than a holding screen or BGM) is loaded
+func (c *Controller) IsPlayingSong() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.playingSong
+}
+
 // OnStateChange sets the callback for state changes
 func (c *Controller) OnStateChange(fn func(state models.PlayerState)) {
 	c.onStateChange = fn
@@ -1044,7 +1311,7 @@ func (c *Controller) StartPlaybackMonitor() {
 			if dur, err := c.conn.Get("duration"); err == nil && dur != nil {
 				if f, ok := dur.(float64); ok && f > 0 {
 					c.songDuration = f
-					log.Printf("[MPV Monitor] Song duration: %.1f seconds", f)
+					logger.Debug("Song duration", "seconds", f)
 				}
 			}
 		}
@@ -1054,7 +1321,7 @@ func (c *Controller) StartPlaybackMonitor() {
 		for {
 			select {
 			case <-stopChan:
-				log.Println("[MPV Monitor] Stopped")
+				logger.Debug("Playback monitor stopped")
 				return
 			case <-ticker.C:
 				c.mu.RLock()
@@ -1075,7 +1342,7 @@ func (c *Controller) StartPlaybackMonitor() {
 				}
 				if dur, err := c.conn.Get("duration"); err == nil && dur != nil {
 					if f, ok := dur.(float64); ok {
-						duration = f
+						duration = f - c.trimTail
 					}
 				}
 				if p, err := c.conn.Get("pause"); err == nil && p != nil {
@@ -1096,7 +1363,7 @@ func (c *Controller) StartPlaybackMonitor() {
 
 				// Log position every 10 seconds for debugging
 				if int(position)%10 == 0 && position > 0 && int(position) != int(lastPos) {
-					log.Printf("[MPV Monitor] Position: %.1f / %.1f (paused: %v)", position, duration, paused)
+					logger.Debug("Position", "position", position, "duration", duration, "paused", paused)
 				}
 
 				// Detect song end: position near duration OR position reset (looped)
@@ -1104,7 +1371,7 @@ func (c *Controller) StartPlaybackMonitor() {
 				if duration > 0 && position > 0 {
 					// Song ended if we're within 1 second of the end (or paused at end)
 					if position >= duration-1.0 {
-						log.Printf("[MPV Monitor] Song ended (position: %.1f, duration: %.1f)", position, duration)
+						logger.Info("Song ended", "position", position, "duration", duration)
 						c.mu.Lock()
 						c.playingSong = false
 						c.mu.Unlock()
@@ -1117,7 +1384,7 @@ func (c *Controller) StartPlaybackMonitor() {
 
 					// Detect if position jumped backwards (indicating loop restart)
 					if lastPos > 5 && position < 2 && lastPos > position+5 {
-						log.Printf("[MPV Monitor] Song looped (last: %.1f, now: %.1f) - treating as end", lastPos, position)
+						logger.Info("Song looped, treating as end", "last", lastPos, "now", position)
 						c.mu.Lock()
 						c.playingSong = false
 						c.mu.Unlock()
@@ -1187,7 +1454,7 @@ func (c *Controller) listenEvents() {
 				c.mu.Lock()
 				c.playingSong = false // Song has ended
 				c.mu.Unlock()
-				log.Println("[MPV] Song finished naturally - triggering onTrackEnd")
+				logger.Info("Song finished naturally")
 				c.onTrackEnd()
 			}
 		case "property-change":
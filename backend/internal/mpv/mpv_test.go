@@ -1,6 +1,8 @@
 package mpv
 
 import (
+	"strconv"
+	"strings"
 	"testing"
 
 	"songmartyn/pkg/models"
@@ -444,6 +446,84 @@ func TestSetVocalMixRequiresConnection(t *testing.T) {
 	}
 }
 
+// TestSetVocalGainRequiresConnection verifies SetVocalGain fails without connection
+func TestSetVocalGainRequiresConnection(t *testing.T) {
+	c := NewController("")
+
+	if err := c.SetVocalGain(0.5); err == nil {
+		t.Error("Expected error from SetVocalGain when not connected")
+	}
+}
+
+// TestTransitionControlsRequireConnection verifies SetFade and SetTrim fail without connection
+func TestTransitionControlsRequireConnection(t *testing.T) {
+	c := NewController("")
+
+	if err := c.SetFade(0.5); err == nil {
+		t.Error("Expected error from SetFade when not connected")
+	}
+	if err := c.SetTrim(2, 5); err == nil {
+		t.Error("Expected error from SetTrim when not connected")
+	}
+}
+
+// TestBGMControlsWithoutMusic verifies fading or stopping absent background music is a no-op
+func TestBGMControlsWithoutMusic(t *testing.T) {
+	c := NewController("")
+
+	if err := c.SetBGMFade(0.5); err != nil {
+		t.Errorf("Expected no error from SetBGMFade with no music playing, got %v", err)
+	}
+	if err := c.StopBGM(); err != nil {
+		t.Errorf("Expected no error from StopBGM with no music playing, got %v", err)
+	}
+}
+
+// TestBGMSocketPath verifies the background music gets its own socket beside the player's
+func TestBGMSocketPath(t *testing.T) {
+	tests := []struct{ socket, want string }{
+		{"/tmp/songmartyn-mpv.sock", "/tmp/songmartyn-mpv-bgm.sock"},
+		{"/tmp/songmartyn-mpv-room-2.sock", "/tmp/songmartyn-mpv-room-2-bgm.sock"},
+		{`\\.\pipe\songmartyn-mpv`, `\\.\pipe\songmartyn-mpv-bgm`},
+	}
+	for _, tt := range tests {
+		if got := bgmSocketPath(tt.socket); got != tt.want {
+			t.Errorf("bgmSocketPath(%q) = %q, want %q", tt.socket, got, tt.want)
+		}
+	}
+}
+
+// TestVocalMixFilter verifies the stem mixer is labelled, quoted and clamps its gain
+func TestVocalMixFilter(t *testing.T) {
+	filter := VocalMixFilter(0.45)
+	prefix := "@vocalmix:lavfi=graph=%"
+	if !strings.HasPrefix(filter, prefix) {
+		t.Fatalf("Expected filter to start with %s, got %s", prefix, filter)
+	}
+
+	// The %n% length must cover exactly the graph
+	rest := strings.TrimPrefix(filter, prefix)
+	sep := strings.Index(rest, "%")
+	n, err := strconv.Atoi(rest[:sep])
+	if err != nil {
+		t.Fatalf("Expected a length prefix, got %s", rest)
+	}
+	graph := rest[sep+1:]
+	if n != len(graph) {
+		t.Errorf("Expected length %d, got %d", len(graph), n)
+	}
+	if !strings.Contains(graph, "volume@vocal=0.450") {
+		t.Errorf("Expected vocal volume 0.450 in %s", graph)
+	}
+
+	if !strings.Contains(VocalMixFilter(1.7), "volume@vocal=1.000") {
+		t.Error("Expected gain above 1 to be clamped")
+	}
+	if !strings.Contains(VocalMixFilter(-1), "volume@vocal=0.000") {
+		t.Error("Expected negative gain to be clamped")
+	}
+}
+
 // =============================================================================
 // Empty Ticker Test
 // =============================================================================
@@ -529,17 +609,20 @@ func TestBGMStateManagementDocumentation(t *testing.T) {
 	// This test documents the BGM state management:
 	//
 	// BGM Start Flow:
-	// 1. Set volume to 0 (for fade-in)
-	// 2. Load holding screen image with loop=inf
-	// 3. Wait 500ms for image to load
-	// 4. Add audio track with audio-add command
-	// 5. Fade volume from 0 to targetVolume over 2 seconds
+	// 1. Load holding screen image with loop=inf
+	// 2. Start the BGM audio in its own audio-only mpv, silent
+	// 3. Fade its volume from 0 to targetVolume over 2 seconds
 	//
 	// BGM Stop Flow:
-	// 1. Get current volume
-	// 2. Fade volume from current to 0 over 2 seconds
+	// 1. Fade the BGM's volume from its level to 0 over 2 seconds
+	// 2. Quit the BGM's mpv
 	// 3. Call "stop" command to clear playlist
 	//
+	// BGM Crossfade Flow:
+	// 1. The song loads into the main mpv and fades in with SetFade
+	// 2. SetBGMFade fades the BGM out over the same time
+	// 3. StopBGM quits the BGM's mpv, leaving the song playing
+	//
 	// Key invariant: app.bgmActive tracks whether BGM is playing
 	// When bgmActive is true:
 	// - showHoldingScreen() should skip (to not disrupt audio)
@@ -0,0 +1,478 @@
+package mpv
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"songmartyn/internal/logging"
+	"songmartyn/pkg/models"
+)
+
+// outputsLogger is the "outputs" subsystem, for extra screens
+var outputsLogger = logging.For("outputs")
+
+// Role describes what an output screen is for
+type Role string
+
+// Output roles
+const (
+	RoleMain     Role = "main"     // Primary output: audio and the full room view
+	RoleSinger   Role = "singer"   // Confidence monitor facing the singer: lyrics, no ticker
+	RoleAudience Role = "audience" // Crowd-facing screen: video, ticker and next-up info
+)
+
+// RoleProfile describes which content is routed to outputs of a role
+type RoleProfile struct {
+	Audio    bool // Plays audio (only the primary output does)
+	Ticker   bool // Shows the scrolling up-next ticker
+	Overlays bool // Shows singer announcement overlays
+	NextUp   bool // Shows a panel listing the next singers over the song
+}
+
+// roleProfiles maps each role to the content it shows
+var roleProfiles = map[Role]RoleProfile{
+	RoleMain:     {Audio: true, Ticker: true, Overlays: true},
+	RoleSinger:   {Audio: false, Ticker: false, Overlays: true},
+	RoleAudience: {Audio: false, Ticker: true, Overlays: true, NextUp: true},
+}
+
+// ProfileFor returns the content profile for a role
+func ProfileFor(role Role) RoleProfile {
+	return roleProfiles[role]
+}
+
+// ParseRole validates a role name
+func ParseRole(s string) (Role, error) {
+	role := Role(s)
+	if _, ok := roleProfiles[role]; !ok {
+		return "", fmt.Errorf("unknown output role: %s", s)
+	}
+	return role, nil
+}
+
+// Position sync settings
+const (
+	DefaultSyncInterval  = 2 * time.Second
+	DefaultSyncTolerance = 0.3 // Seconds of drift allowed before a follower is re-seeked
+)
+
+// outputNameRegex limits output names to safe socket/file name characters
+var outputNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// OutputConfig configures an additional output screen
+type OutputConfig struct {
+	Name           string `json:"name"`
+	Role           Role   `json:"role"`
+	TargetDisplay  string `json:"target_display,omitempty"` // Display name (resolved to ScreenIndex by the caller)
+	ScreenIndex    int    `json:"screen_index"`             // -1 = auto
+	AutoFullscreen bool   `json:"auto_fullscreen"`
+}
+
+// Validate checks an output config
+// Extra outputs follow the primary, so they can't take the main role
+func (cfg OutputConfig) Validate() error {
+	if !outputNameRegex.MatchString(cfg.Name) {
+		return fmt.Errorf("invalid output name %q (use lowercase letters, digits, - and _)", cfg.Name)
+	}
+	if cfg.Name == string(RoleMain) {
+		return fmt.Errorf("output name %q is reserved for the primary output", cfg.Name)
+	}
+	if _, err := ParseRole(string(cfg.Role)); err != nil {
+		return err
+	}
+	if cfg.Role == RoleMain {
+		return fmt.Errorf("only the primary output can have the main role")
+	}
+	return nil
+}
+
+// OutputStatus describes an output for display in the admin UI
+type OutputStatus struct {
+	OutputConfig
+	Running bool `json:"running"`
+}
+
+// output is one additional screen driven by its own mpv instance
+type output struct {
+	config OutputConfig
+	ctrl   *Controller
+}
+
+// Outputs drives additional output screens that follow the primary controller
+// The primary plays audio and decides when songs end; followers mirror its
+// media, stay in sync on position and get role-specific holding screens and overlays
+type Outputs struct {
+	primary    Player
+	executable string
+	outputs    map[string]*output
+	mu         sync.RWMutex
+
+	syncTolerance float64
+	stopSync      chan struct{}
+
+	upNext []TickerEntry // Upcoming singers for next-up panels
+}
+
+// NewOutputs creates an output group following the primary controller
+func NewOutputs(primary Player, executable string) *Outputs {
+	return &Outputs{
+		primary:       primary,
+		executable:    executable,
+		outputs:       make(map[string]*output),
+		syncTolerance: DefaultSyncTolerance,
+	}
+}
+
+// Add registers and starts an output
+// If mpv fails to start the output stays registered so it can be restarted
+func (o *Outputs) Add(cfg OutputConfig) error {
+	if err := o.Register(cfg); err != nil {
+		return err
+	}
+	return o.Start(cfg.Name)
+}
+
+// Register adds an output without starting its player
+func (o *Outputs) Register(cfg OutputConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, exists := o.outputs[cfg.Name]; exists {
+		return fmt.Errorf("output %q already exists", cfg.Name)
+	}
+	ctrl := NewOutputController(cfg.Name, o.executable, ProfileFor(cfg.Role).Audio)
+	ctrl.SetDisplaySettings(DisplaySettings{
+		TargetDisplay:  cfg.TargetDisplay,
+		ScreenIndex:    cfg.ScreenIndex,
+		AutoFullscreen: cfg.AutoFullscreen,
+	})
+	o.outputs[cfg.Name] = &output{config: cfg, ctrl: ctrl}
+	return nil
+}
+
+// Start starts a registered output's player
+func (o *Outputs) Start(name string) error {
+	o.mu.RLock()
+	out, ok := o.outputs[name]
+	o.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("output %q not found", name)
+	}
+
+	if err := out.ctrl.Start(); err != nil {
+		return fmt.Errorf("failed to start output %q: %w", name, err)
+	}
+	outputsLogger.Info("Started output", "role", out.config.Role, "name", name, "screen", out.config.ScreenIndex)
+	return nil
+}
+
+// Remove stops and removes an output
+func (o *Outputs) Remove(name string) error {
+	o.mu.Lock()
+	out, ok := o.outputs[name]
+	if ok {
+		delete(o.outputs, name)
+	}
+	o.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("output %q not found", name)
+	}
+	return out.ctrl.Stop()
+}
+
+// Restart restarts an output's mpv instance (e.g. after its window was closed)
+func (o *Outputs) Restart(name string) error {
+	o.mu.RLock()
+	out, ok := o.outputs[name]
+	o.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("output %q not found", name)
+	}
+	return out.ctrl.Restart()
+}
+
+// StopAll stops every output
+func (o *Outputs) StopAll() {
+	o.StopSync()
+	for _, out := range o.list() {
+		out.ctrl.Stop()
+	}
+}
+
+// DetachAll leaves every output running for the next process to adopt
+func (o *Outputs) DetachAll() {
+	o.StopSync()
+	for _, out := range o.list() {
+		out.ctrl.Detach()
+	}
+}
+
+// Configs returns the configuration of every output, sorted by name
+func (o *Outputs) Configs() []OutputConfig {
+	outs := o.list()
+	configs := make([]OutputConfig, len(outs))
+	for i, out := range outs {
+		configs[i] = out.config
+	}
+	return configs
+}
+
+// Status returns every output with its running state, sorted by name
+func (o *Outputs) Status() []OutputStatus {
+	outs := o.list()
+	status := make([]OutputStatus, len(outs))
+	for i, out := range outs {
+		status[i] = OutputStatus{OutputConfig: out.config, Running: out.ctrl.IsRunning()}
+	}
+	return status
+}
+
+// list returns a snapshot of the outputs sorted by name
+func (o *Outputs) list() []*output {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	outs := make([]*output, 0, len(o.outputs))
+	for _, out := range o.outputs {
+		outs = append(outs, out)
+	}
+	sort.Slice(outs, func(i, j int) bool { return outs[i].config.Name < outs[j].config.Name })
+	return outs
+}
+
+// each runs fn on every running output that matches the filter (nil = all)
+func (o *Outputs) each(filter func(RoleProfile) bool, fn func(out *output) error) {
+	for _, out := range o.list() {
+		if filter != nil && !filter(ProfileFor(out.config.Role)) {
+			continue
+		}
+		if !out.ctrl.IsRunning() {
+			continue
+		}
+		if err := fn(out); err != nil {
+			outputsLogger.Warn("Output failed", "name", out.config.Name, "err", err)
+		}
+	}
+}
+
+// LoadSong mirrors the current song onto every output
+// CDG songs load the graphics file; everything else loads the video
+// Outputs never play audio, so stems and vocal mixing are left to the primary
+// Audience screens also get the next-up panel, while the singer's monitor is left clear for the lyrics
+func (o *Outputs) LoadSong(videoPath, cdgPath string) {
+	path := videoPath
+	if cdgPath != "" {
+		path = cdgPath
+	}
+	if path == "" {
+		return
+	}
+	upNext := o.upNextEntries()
+	o.each(nil, func(out *output) error {
+		out.ctrl.SetPlayingSong(true)
+		if err := out.ctrl.LoadFile(path); err != nil {
+			return err
+		}
+		return out.ctrl.ShowPanel(songPanel(out.config.Role, upNext))
+	})
+}
+
+// songPanel returns the panel an output of a role shows over a song, or "" for none
+func songPanel(role Role, upNext []TickerEntry) string {
+	if !ProfileFor(role).NextUp {
+		return ""
+	}
+	return nextUpPanel(upNext)
+}
+
+// SetUpNext updates the upcoming singers, redrawing the panel on outputs showing a song
+func (o *Outputs) SetUpNext(entries []TickerEntry) {
+	o.mu.Lock()
+	o.upNext = entries
+	o.mu.Unlock()
+
+	o.each(func(p RoleProfile) bool { return p.NextUp }, func(out *output) error {
+		if !out.ctrl.IsPlayingSong() {
+			return nil
+		}
+		return out.ctrl.ShowPanel(songPanel(out.config.Role, entries))
+	})
+}
+
+// upNextEntries returns the upcoming singers for next-up panels
+func (o *Outputs) upNextEntries() []TickerEntry {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.upNext
+}
+
+// ShowHolding loads the holding screen for each output's role
+// Roles without their own image fall back to the main image
+func (o *Outputs) ShowHolding(images map[Role]string) {
+	o.each(nil, func(out *output) error {
+		path := images[out.config.Role]
+		if path == "" {
+			path = images[RoleMain]
+		}
+		out.ctrl.ShowPanel("")
+		if path == "" {
+			return nil
+		}
+		return out.ctrl.LoadImage(path)
+	})
+}
+
+// Play resumes playback on every output
+func (o *Outputs) Play() {
+	o.each(nil, func(out *output) error { return out.ctrl.Play() })
+}
+
+// Pause pauses every output
+func (o *Outputs) Pause() {
+	o.each(nil, func(out *output) error { return out.ctrl.Pause() })
+}
+
+// Seek seeks every output to a position in seconds
+func (o *Outputs) Seek(position float64) {
+	o.each(nil, func(out *output) error { return out.ctrl.Seek(position) })
+}
+
+// SetTempo sets the playback speed on every output
+func (o *Outputs) SetTempo(speed float64) {
+	o.each(nil, func(out *output) error { return out.ctrl.SetTempo(speed) })
+}
+
+// StopPlayback stops playback on every output without closing them
+func (o *Outputs) StopPlayback() {
+	o.each(nil, func(out *output) error {
+		out.ctrl.SetPlayingSong(false)
+		out.ctrl.ShowPanel("")
+		return out.ctrl.StopPlayback()
+	})
+}
+
+// ShowOverlay shows a text overlay on outputs whose role shows overlays
+func (o *Outputs) ShowOverlay(text string, durationMs int) {
+	o.each(func(p RoleProfile) bool { return p.Overlays }, func(out *output) error {
+		return out.ctrl.ShowOverlay(text, durationMs)
+	})
+}
+
+// ShowReaction floats a reaction on outputs whose role shows overlays
+func (o *Outputs) ShowReaction(r Reaction) {
+	o.each(func(p RoleProfile) bool { return p.Overlays }, func(out *output) error {
+		return out.ctrl.ShowReaction(r)
+	})
+}
+
+// ShowTicker shows the up-next ticker on outputs whose role shows it
+func (o *Outputs) ShowTicker(entries []TickerEntry) {
+	o.each(func(p RoleProfile) bool { return p.Ticker }, func(out *output) error {
+		return out.ctrl.ShowTicker(entries)
+	})
+}
+
+// HideTicker hides the ticker on every output
+func (o *Outputs) HideTicker() {
+	o.each(nil, func(out *output) error { return out.ctrl.HideTicker() })
+}
+
+// syncAction is what a follower needs to match the primary
+type syncAction struct {
+	Seek     bool
+	Position float64
+	SetPause bool
+	Pause    bool
+}
+
+// planSync decides how a follower should be corrected to match the primary
+func planSync(primary, follower models.PlayerState, tolerance float64) syncAction {
+	var action syncAction
+	if primary.IsPlaying != follower.IsPlaying {
+		action.SetPause = true
+		action.Pause = !primary.IsPlaying
+	}
+	if math.Abs(primary.Position-follower.Position) > tolerance {
+		action.Seek = true
+		action.Position = primary.Position
+	}
+	return action
+}
+
+// SyncOnce brings every output in line with the primary's position and pause state
+// Nothing is synced while the primary shows a holding screen or BGM
+func (o *Outputs) SyncOnce() {
+	if o.primary == nil || !o.primary.IsPlayingSong() {
+		return
+	}
+	primaryState, err := o.primary.GetState()
+	if err != nil {
+		return
+	}
+
+	o.each(nil, func(out *output) error {
+		state, err := out.ctrl.GetState()
+		if err != nil {
+			return err
+		}
+		action := planSync(primaryState, state, o.syncTolerance)
+		if action.SetPause {
+			if action.Pause {
+				out.ctrl.Pause()
+			} else {
+				out.ctrl.Play()
+			}
+		}
+		if action.Seek {
+			return out.ctrl.Seek(action.Position)
+		}
+		return nil
+	})
+}
+
+// StartSync periodically syncs outputs to the primary until StopSync is called
+func (o *Outputs) StartSync(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultSyncInterval
+	}
+
+	o.mu.Lock()
+	if o.stopSync != nil {
+		o.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	o.stopSync = stop
+	o.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				o.SyncOnce()
+			}
+		}
+	}()
+}
+
+// StopSync stops the sync loop
+func (o *Outputs) StopSync() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.stopSync != nil {
+		close(o.stopSync)
+		o.stopSync = nil
+	}
+}
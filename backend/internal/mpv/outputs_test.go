@@ -0,0 +1,224 @@
+package mpv
+
+import (
+	"strings"
+	"testing"
+
+	"songmartyn/pkg/models"
+)
+
+// TestParseRole verifies known roles parse and unknown ones are rejected
+func TestParseRole(t *testing.T) {
+	for _, name := range []string{"main", "singer", "audience"} {
+		if _, err := ParseRole(name); err != nil {
+			t.Errorf("Expected role %q to parse, got %v", name, err)
+		}
+	}
+	if _, err := ParseRole("projector"); err == nil {
+		t.Error("Expected error for unknown role")
+	}
+}
+
+// TestRoleProfiles documents what each role shows
+func TestRoleProfiles(t *testing.T) {
+	if !ProfileFor(RoleMain).Audio {
+		t.Error("Main output should play audio")
+	}
+	if ProfileFor(RoleSinger).Audio || ProfileFor(RoleAudience).Audio {
+		t.Error("Only the main output should play audio")
+	}
+	if ProfileFor(RoleSinger).Ticker {
+		t.Error("Singer confidence monitor should not show the ticker")
+	}
+	if !ProfileFor(RoleAudience).Ticker {
+		t.Error("Audience screen should show the ticker")
+	}
+	if !ProfileFor(RoleAudience).NextUp || ProfileFor(RoleSinger).NextUp {
+		t.Error("Only the audience screen should show the next-up panel")
+	}
+}
+
+// TestRolesGetDifferentSongOverlays verifies the audience sees who's up next while the singer's monitor stays clear
+func TestRolesGetDifferentSongOverlays(t *testing.T) {
+	upNext := []TickerEntry{{SingerName: "Alice", SongTitle: "Jolene"}, {SingerName: "Bob", SongTitle: "Africa"}}
+
+	if panel := songPanel(RoleSinger, upNext); panel != "" {
+		t.Errorf("Expected no panel on the singer monitor, got %q", panel)
+	}
+	audience := songPanel(RoleAudience, upNext)
+	for _, want := range []string{"Up Next", "1. Alice - Jolene", "2. Bob - Africa"} {
+		if !strings.Contains(audience, want) {
+			t.Errorf("Expected audience panel to contain %q, got %q", want, audience)
+		}
+	}
+	if panel := songPanel(RoleAudience, nil); panel != "" {
+		t.Errorf("Expected no panel with nobody up next, got %q", panel)
+	}
+}
+
+// TestNextUpPanelEscapesAndLimits verifies names can't inject ASS tags and the list stays short
+func TestNextUpPanelEscapesAndLimits(t *testing.T) {
+	var entries []TickerEntry
+	for i := 0; i < maxPanelEntries+3; i++ {
+		entries = append(entries, TickerEntry{SingerName: `{\fs200}Loud`, SongTitle: "Song"})
+	}
+
+	panel := nextUpPanel(entries)
+	if strings.Contains(panel, `{\fs200}`) {
+		t.Errorf("Expected singer name to be escaped, got %q", panel)
+	}
+	if got := strings.Count(panel, `\N`); got != maxPanelEntries {
+		t.Errorf("Expected %d entries, got %d", maxPanelEntries, got)
+	}
+}
+
+// TestOutputConfigValidate verifies name and role validation
+func TestOutputConfigValidate(t *testing.T) {
+	tests := []struct {
+		cfg     OutputConfig
+		wantErr bool
+	}{
+		{OutputConfig{Name: "stage", Role: RoleSinger}, false},
+		{OutputConfig{Name: "crowd-1", Role: RoleAudience}, false},
+		{OutputConfig{Name: "", Role: RoleSinger}, true},
+		{OutputConfig{Name: "Bad Name", Role: RoleSinger}, true},
+		{OutputConfig{Name: "../escape", Role: RoleSinger}, true},
+		{OutputConfig{Name: "main", Role: RoleAudience}, true},
+		{OutputConfig{Name: "second", Role: RoleMain}, true},
+		{OutputConfig{Name: "second", Role: "projector"}, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.cfg.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v): expected error=%v, got %v", tt.cfg, tt.wantErr, err)
+		}
+	}
+}
+
+// TestNamedOutputPaths verifies each output gets its own socket and PID file
+func TestNamedOutputPaths(t *testing.T) {
+	primary := NewController("")
+	singer := NewOutputController("singer", "", false)
+
+	if singer.socketPath == primary.socketPath {
+		t.Error("Named output should not share the primary socket")
+	}
+	if singer.pidFile == primary.pidFile {
+		t.Error("Named output should not share the primary PID file")
+	}
+	if !strings.Contains(singer.socketPath, "singer") {
+		t.Errorf("Expected socket path to contain output name, got %s", singer.socketPath)
+	}
+	if singer.audio {
+		t.Error("Expected video-only output to have audio disabled")
+	}
+	if !primary.audio {
+		t.Error("Expected primary output to have audio enabled")
+	}
+}
+
+// TestUsesSocket verifies orphan matching doesn't confuse the primary with named outputs
+func TestUsesSocket(t *testing.T) {
+	primary := `\\.\pipe\songmartyn-mpv`
+	if !usesSocket(`mpv.exe --input-ipc-server=\\.\pipe\songmartyn-mpv --idle=yes`, primary) {
+		t.Error("Expected primary pipe to match")
+	}
+	if usesSocket(`mpv.exe --input-ipc-server=\\.\pipe\songmartyn-mpv-singer --idle=yes`, primary) {
+		t.Error("Primary pipe should not match a named output's pipe")
+	}
+	if !usesSocket(`mpv.exe --input-ipc-server=\\.\pipe\songmartyn-mpv-singer`, primary+"-singer") {
+		t.Error("Expected named pipe to match itself")
+	}
+}
+
+// TestAddRejectsDuplicateAndInvalid verifies Add validates before starting mpv
+func TestAddRejectsDuplicateAndInvalid(t *testing.T) {
+	outputs := NewOutputs(NewController(""), "/nonexistent/mpv")
+
+	if err := outputs.Add(OutputConfig{Name: "x", Role: RoleMain}); err == nil {
+		t.Error("Expected error adding a second main output")
+	}
+
+	// Start fails (no mpv) but the output stays registered for a later restart
+	if err := outputs.Add(OutputConfig{Name: "stage", Role: RoleSinger, ScreenIndex: 1}); err == nil {
+		t.Error("Expected start error with missing executable")
+	}
+	if err := outputs.Add(OutputConfig{Name: "stage", Role: RoleSinger}); err == nil {
+		t.Error("Expected duplicate name error")
+	}
+
+	status := outputs.Status()
+	if len(status) != 1 || status[0].Name != "stage" || status[0].Running {
+		t.Errorf("Expected one stopped output 'stage', got %+v", status)
+	}
+
+	if err := outputs.Remove("stage"); err != nil {
+		t.Errorf("Remove failed: %v", err)
+	}
+	if err := outputs.Remove("stage"); err == nil {
+		t.Error("Expected error removing unknown output")
+	}
+}
+
+// TestMirroringSkipsStoppedOutputs verifies commands don't fail on outputs that aren't running
+func TestMirroringSkipsStoppedOutputs(t *testing.T) {
+	outputs := NewOutputs(NewController(""), "/nonexistent/mpv")
+	outputs.Add(OutputConfig{Name: "crowd", Role: RoleAudience})
+
+	// None of these should panic without a connection
+	outputs.LoadSong("/song.mp4", "")
+	outputs.ShowHolding(map[Role]string{RoleMain: "/holding.png"})
+	outputs.Play()
+	outputs.Pause()
+	outputs.Seek(10)
+	outputs.ShowOverlay("hi", 1000)
+	outputs.ShowTicker([]TickerEntry{{SingerName: "A", SongTitle: "B"}})
+	outputs.HideTicker()
+	outputs.SetUpNext([]TickerEntry{{SingerName: "A", SongTitle: "B"}})
+	outputs.StopPlayback()
+	outputs.SyncOnce()
+}
+
+// ============================================================================
+// Position Sync Tests
+// ============================================================================
+
+// TestPlanSyncWithinTolerance verifies small drift is left alone
+func TestPlanSyncWithinTolerance(t *testing.T) {
+	primary := models.PlayerState{Position: 42.0, IsPlaying: true}
+	follower := models.PlayerState{Position: 42.2, IsPlaying: true}
+
+	action := planSync(primary, follower, DefaultSyncTolerance)
+	if action.Seek || action.SetPause {
+		t.Errorf("Expected no correction, got %+v", action)
+	}
+}
+
+// TestPlanSyncSeeksOnDrift verifies followers are seeked to the primary's position
+func TestPlanSyncSeeksOnDrift(t *testing.T) {
+	primary := models.PlayerState{Position: 42.0, IsPlaying: true}
+
+	for _, pos := range []float64{40.0, 43.5} {
+		action := planSync(primary, models.PlayerState{Position: pos, IsPlaying: true}, DefaultSyncTolerance)
+		if !action.Seek || action.Position != 42.0 {
+			t.Errorf("Follower at %.1f: expected seek to 42.0, got %+v", pos, action)
+		}
+	}
+}
+
+// TestPlanSyncMirrorsPause verifies pause state follows the primary
+func TestPlanSyncMirrorsPause(t *testing.T) {
+	paused := models.PlayerState{Position: 10, IsPlaying: false}
+	playing := models.PlayerState{Position: 10, IsPlaying: true}
+
+	action := planSync(paused, playing, DefaultSyncTolerance)
+	if !action.SetPause || !action.Pause {
+		t.Errorf("Expected follower to pause, got %+v", action)
+	}
+
+	action = planSync(playing, paused, DefaultSyncTolerance)
+	if !action.SetPause || action.Pause {
+		t.Errorf("Expected follower to resume, got %+v", action)
+	}
+}
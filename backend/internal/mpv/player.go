@@ -0,0 +1,61 @@
+package mpv
+
+import (
+	"time"
+
+	"songmartyn/pkg/models"
+)
+
+// Player is the playback backend the app drives
+// Controller implements it with a real mpv process; FakePlayer simulates one in-process
+type Player interface {
+	// Lifecycle
+	Start() error
+	Stop() error
+	Restart() error
+	IsRunning() bool
+	Detach() error // Disconnect but leave the player running for the next process to adopt
+	Adopted() bool // Start reconnected to a player an earlier process detached from
+	SetDisplaySettings(settings DisplaySettings)
+
+	// Loading content
+	LoadFile(path string) error
+	LoadImage(path string) error
+	LoadHoldingVideo(videoPath, overlayPath string) error
+	LoadCDG(cdgPath, audioPath string) error
+	SetVocalMix(instrumentalPath, vocalPath string, vocalGain float64) error
+	LoadBGMWithImage(imagePath, audioURL string, targetVolume float64) error
+	UpdateBGMImage(imagePath string) error
+	StopBGMWithFade(fadeDuration time.Duration) error
+	StopBGM() error // Stops the background music, leaving whatever else is loaded playing
+	StopPlayback() error
+	SetTrim(lead, tail float64) error
+
+	// Transport and audio
+	Play() error
+	Pause() error
+	Seek(position float64) error
+	SetVolume(volume float64) error
+	SetPitch(semitones int) error
+	SetTempo(speed float64) error
+	SetGain(db float64) error
+	SetVocalGain(gain float64) error
+	SetFade(level float64) error
+	SetBGMFade(level float64) error // Background music has its own level so it can fade under a song
+
+	// On-screen text
+	ShowOverlay(text string, durationMs int) error
+	ShowTicker(entries []TickerEntry) error
+	HideTicker() error
+	ShowReaction(r Reaction) error // Returns ErrReactionsBusy when the screen is full
+
+	// State and end-of-track detection
+	GetState() (models.PlayerState, error)
+	SetPlayingSong(playing bool)
+	IsPlayingSong() bool
+	StartPlaybackMonitor()
+	OnStateChange(fn func(state models.PlayerState))
+	OnTrackEnd(fn func())
+}
+
+var _ Player = (*Controller)(nil)
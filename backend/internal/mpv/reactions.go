@@ -0,0 +1,198 @@
+package mpv
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// Reaction is an animated sprite floated up the screen over whatever is playing
+type Reaction struct {
+	Frames   []*image.RGBA // Square frames of equal size, looped while it floats
+	FPS      float64       // Frame rate of the loop
+	Label    string        // Shown under the sprite (e.g. the guest's name)
+	X        float64       // Horizontal position, 0 (left) to 1 (right)
+	Duration time.Duration // Time to float from the bottom of the screen to the top
+}
+
+// MaxReactions is how many reactions can float on screen at once
+const MaxReactions = 8
+
+// ErrReactionsBusy is returned when MaxReactions are already on screen
+var ErrReactionsBusy = errors.New("too many reactions on screen")
+
+// reactionTick is how often a floating reaction moves
+const reactionTick = 33 * time.Millisecond
+
+// Validate checks a reaction can be shown
+func (r Reaction) Validate() error {
+	if len(r.Frames) == 0 {
+		return fmt.Errorf("reaction has no frames")
+	}
+	size := r.Frames[0].Bounds()
+	if size.Dx() != size.Dy() {
+		return fmt.Errorf("reaction frames must be square")
+	}
+	for _, frame := range r.Frames {
+		if frame.Bounds() != size {
+			return fmt.Errorf("reaction frames must be the same size")
+		}
+	}
+	if r.FPS <= 0 || r.Duration <= 0 {
+		return fmt.Errorf("reaction needs a frame rate and duration")
+	}
+	return nil
+}
+
+// frameAt returns the index of the frame showing after elapsed
+func (r Reaction) frameAt(elapsed time.Duration) int {
+	return int(elapsed.Seconds()*r.FPS) % len(r.Frames)
+}
+
+// position returns where a size-pixel sprite is on a width x height screen at progress (0-1)
+// It rises from the bottom edge to the top, swaying either side of X
+func (r Reaction) position(progress float64, width, height, size int) (x, y int) {
+	room := float64(width - size)
+	fx := math.Max(0, math.Min(1, r.X))*room + math.Sin(progress*4*math.Pi)*float64(size)*0.25
+	fx = math.Max(0, math.Min(room, fx))
+	fy := float64(height-size) * (1 - progress)
+	return int(fx), int(math.Max(0, fy))
+}
+
+// bgra packs frames one after another as premultiplied BGRA, the format overlay-add reads
+func bgra(frames []*image.RGBA) []byte {
+	size := frames[0].Bounds()
+	frameBytes := size.Dx() * size.Dy() * 4
+	out := make([]byte, 0, frameBytes*len(frames))
+	for _, frame := range frames {
+		// image.RGBA is already premultiplied; only the channel order differs
+		for y := size.Min.Y; y < size.Max.Y; y++ {
+			row := frame.Pix[frame.PixOffset(size.Min.X, y):frame.PixOffset(size.Max.X, y)]
+			for i := 0; i < len(row); i += 4 {
+				out = append(out, row[i+2], row[i+1], row[i], row[i+3])
+			}
+		}
+	}
+	return out
+}
+
+// assEscape keeps a label from being read as ASS override tags
+func assEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `{`, `\{`, `}`, `\}`, "\n", " ").Replace(text)
+}
+
+// ShowReaction floats a reaction up the screen
+// The frames go to mpv as one raw BGRA file that overlay-add steps through by offset,
+// and the label is an ASS OSD overlay that moves with them
+func (c *Controller) ShowReaction(r Reaction) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	c.mu.RLock()
+	connected := c.conn != nil
+	c.mu.RUnlock()
+	if !connected {
+		return fmt.Errorf("mpv not connected")
+	}
+
+	id, ok := c.takeReactionSlot()
+	if !ok {
+		return ErrReactionsBusy
+	}
+	path := fmt.Sprintf("%s-reaction%d.bgra", strings.TrimSuffix(c.pidFile, ".pid"), id)
+	if err := os.WriteFile(path, bgra(r.Frames), 0644); err != nil {
+		c.releaseReactionSlot(id)
+		return fmt.Errorf("failed to write reaction frames: %w", err)
+	}
+
+	go c.floatReaction(id, path, r)
+	return nil
+}
+
+// floatReaction moves a reaction up the screen, then removes it
+func (c *Controller) floatReaction(id int, path string, r Reaction) {
+	defer c.releaseReactionSlot(id)
+	defer os.Remove(path)
+
+	width, height := c.osdSize()
+	size := r.Frames[0].Bounds().Dx()
+	frameBytes := size * size * 4
+
+	ticker := time.NewTicker(reactionTick)
+	defer ticker.Stop()
+	start := time.Now()
+	for {
+		elapsed := time.Since(start)
+		if elapsed >= r.Duration {
+			break
+		}
+		x, y := r.position(float64(elapsed)/float64(r.Duration), width, height, size)
+		offset := r.frameAt(elapsed) * frameBytes
+		if err := c.call("overlay-add", id, x, y, path, offset, "bgra", size, size, size*4); err != nil {
+			logger.Debug("Reaction overlay failed", "err", err)
+			break
+		}
+		if r.Label != "" {
+			label := fmt.Sprintf(`{\an8\pos(%d,%d)\fs30\bord3\shad0}%s`, x+size/2, y+size, assEscape(r.Label))
+			c.call("osd-overlay", id, "ass-events", label, width, height)
+		}
+		<-ticker.C
+	}
+
+	c.call("overlay-remove", id)
+	if r.Label != "" {
+		c.call("osd-overlay", id, "none", "")
+	}
+}
+
+// call runs an mpv command if connected
+func (c *Controller) call(args ...interface{}) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.conn == nil {
+		return fmt.Errorf("mpv not connected")
+	}
+	_, err := c.conn.Call(args...)
+	return err
+}
+
+// osdSize returns the OSD size in pixels, assuming 1080p until mpv knows its window
+func (c *Controller) osdSize() (int, int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.conn == nil {
+		return 1920, 1080
+	}
+	w, errW := c.conn.Get("osd-width")
+	h, errH := c.conn.Get("osd-height")
+	width, okW := w.(float64)
+	height, okH := h.(float64)
+	if errW != nil || errH != nil || !okW || !okH || width <= 0 || height <= 0 {
+		return 1920, 1080
+	}
+	return int(width), int(height)
+}
+
+// takeReactionSlot reserves an overlay ID for a reaction
+func (c *Controller) takeReactionSlot() (int, bool) {
+	c.reactionMu.Lock()
+	defer c.reactionMu.Unlock()
+	for id, used := range c.reactionSlots {
+		if !used {
+			c.reactionSlots[id] = true
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// releaseReactionSlot frees a reaction's overlay ID
+func (c *Controller) releaseReactionSlot(id int) {
+	c.reactionMu.Lock()
+	defer c.reactionMu.Unlock()
+	c.reactionSlots[id] = false
+}
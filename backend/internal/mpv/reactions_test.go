@@ -0,0 +1,133 @@
+package mpv
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+func testReaction(frames, size int) Reaction {
+	r := Reaction{FPS: 10, X: 0.5, Duration: time.Second}
+	for i := 0; i < frames; i++ {
+		r.Frames = append(r.Frames, image.NewRGBA(image.Rect(0, 0, size, size)))
+	}
+	return r
+}
+
+func TestReactionValidate(t *testing.T) {
+	if err := testReaction(3, 8).Validate(); err != nil {
+		t.Errorf("Expected valid reaction, got %v", err)
+	}
+
+	none := testReaction(0, 8)
+	if err := none.Validate(); err == nil {
+		t.Error("Expected error for no frames")
+	}
+
+	mixed := testReaction(2, 8)
+	mixed.Frames = append(mixed.Frames, image.NewRGBA(image.Rect(0, 0, 16, 16)))
+	if err := mixed.Validate(); err == nil {
+		t.Error("Expected error for frames of different sizes")
+	}
+
+	wide := testReaction(1, 8)
+	wide.Frames[0] = image.NewRGBA(image.Rect(0, 0, 16, 8))
+	if err := wide.Validate(); err == nil {
+		t.Error("Expected error for non-square frames")
+	}
+
+	still := testReaction(1, 8)
+	still.FPS = 0
+	if err := still.Validate(); err == nil {
+		t.Error("Expected error for zero frame rate")
+	}
+}
+
+func TestReactionFloatsUpAndStaysOnScreen(t *testing.T) {
+	r := testReaction(4, 100)
+	for _, x := range []float64{-1, 0, 0.5, 1, 2} {
+		r.X = x
+		lastY := 1 << 30
+		for step := 0; step <= 20; step++ {
+			px, py := r.position(float64(step)/20, 1920, 1080, 100)
+			if px < 0 || px > 1820 || py < 0 || py > 980 {
+				t.Fatalf("X %v step %d: position (%d, %d) off screen", x, step, px, py)
+			}
+			if py > lastY {
+				t.Errorf("X %v step %d: expected to rise, y went %d -> %d", x, step, lastY, py)
+			}
+			lastY = py
+		}
+		if _, y := r.position(0, 1920, 1080, 100); y != 980 {
+			t.Errorf("Expected to start at the bottom, got y %d", y)
+		}
+	}
+}
+
+func TestReactionFrameLoops(t *testing.T) {
+	r := testReaction(4, 8) // 10 fps
+	tests := map[time.Duration]int{0: 0, 150 * time.Millisecond: 1, 350 * time.Millisecond: 3, 450 * time.Millisecond: 0}
+	for elapsed, want := range tests {
+		if got := r.frameAt(elapsed); got != want {
+			t.Errorf("frameAt(%v) = %d, want %d", elapsed, got, want)
+		}
+	}
+}
+
+func TestReactionBGRA(t *testing.T) {
+	r := testReaction(2, 2)
+	r.Frames[1].SetRGBA(1, 0, color.RGBA{R: 10, G: 20, B: 30, A: 40})
+
+	raw := bgra(r.Frames)
+	if len(raw) != 2*2*2*4 {
+		t.Fatalf("Expected %d bytes, got %d", 2*2*2*4, len(raw))
+	}
+	// Second frame, second pixel
+	got := raw[16+4 : 16+8]
+	if got[0] != 30 || got[1] != 20 || got[2] != 10 || got[3] != 40 {
+		t.Errorf("Expected BGRA 30,20,10,40, got %v", got)
+	}
+}
+
+func TestFakeShowReaction(t *testing.T) {
+	f := NewFakePlayer()
+	if err := f.ShowReaction(testReaction(1, 8)); err == nil {
+		t.Error("Expected error before start")
+	}
+	f.Start()
+	if err := f.ShowReaction(testReaction(0, 8)); err == nil {
+		t.Error("Expected error for invalid reaction")
+	}
+
+	r := testReaction(2, 8)
+	r.Label = "Alice"
+	if err := f.ShowReaction(r); err != nil {
+		t.Fatalf("ShowReaction failed: %v", err)
+	}
+	if got := f.Reactions(); len(got) != 1 || got[0].Label != "Alice" {
+		t.Errorf("Expected Alice's reaction, got %v", got)
+	}
+}
+
+func TestReactionSlots(t *testing.T) {
+	c := &Controller{}
+	for i := 0; i < MaxReactions; i++ {
+		if _, ok := c.takeReactionSlot(); !ok {
+			t.Fatalf("Expected slot %d to be free", i)
+		}
+	}
+	if _, ok := c.takeReactionSlot(); ok {
+		t.Error("Expected no slot once the screen is full")
+	}
+	c.releaseReactionSlot(3)
+	if id, ok := c.takeReactionSlot(); !ok || id != 3 {
+		t.Errorf("Expected released slot 3, got %d (%v)", id, ok)
+	}
+
+	// Not connected, so nothing to float over
+	if err := c.ShowReaction(testReaction(1, 8)); err == nil || errors.Is(err, ErrReactionsBusy) {
+		t.Errorf("Expected not connected error, got %v", err)
+	}
+}
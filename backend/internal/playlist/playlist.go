@@ -0,0 +1,546 @@
+package playlist
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+	"songmartyn/internal/metrics"
+	"songmartyn/pkg/models"
+)
+
+// Errors returned by playlist operations
+var (
+	ErrNotFound     = errors.New("playlist not found")
+	ErrNotAllowed   = errors.New("not allowed to change this playlist")
+	ErrInvalidName  = errors.New("playlist name is required")
+	ErrDuplicate    = errors.New("song is already in this playlist")
+	ErrSongNotFound = errors.New("song is not in this playlist")
+)
+
+// MaxNameLength limits playlist names
+const MaxNameLength = 64
+
+// SchemaVersion is the layout of playlists.db, stored in its user_version so restores can
+// tell a backup from a newer SongMartyn apart from one the migrations can upgrade
+const SchemaVersion = 2
+
+// Manager handles singer playlists (named, ordered, shareable song lists)
+type Manager struct {
+	db           *sql.DB
+	observeQuery metrics.QueryObserver
+}
+
+// NewManager creates a new playlist manager with SQLite persistence
+func NewManager(dbPath string) (*Manager, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS playlists (
+			id TEXT PRIMARY KEY,
+			owner_key TEXT NOT NULL,
+			name TEXT NOT NULL,
+			share_token TEXT UNIQUE,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS playlist_songs (
+			playlist_id TEXT NOT NULL,
+			song_id TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			added_by TEXT DEFAULT '',
+			added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (playlist_id, song_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS playlist_shares (
+			playlist_id TEXT NOT NULL,
+			martyn_key TEXT NOT NULL,
+			can_edit INTEGER DEFAULT 0,
+			shared_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (playlist_id, martyn_key)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_playlists_owner ON playlists(owner_key);
+		CREATE INDEX IF NOT EXISTS idx_playlist_shares_key ON playlist_shares(martyn_key);
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Migration: shares used to all be editable; existing members become view-only
+	// until the owner grants edit again
+	db.Exec("ALTER TABLE playlist_shares ADD COLUMN can_edit INTEGER DEFAULT 0")
+
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", SchemaVersion)); err != nil {
+		return nil, err
+	}
+
+	return &Manager{db: db}, nil
+}
+
+// SetQueryObserver reports how long each database operation takes, for metrics
+func (m *Manager) SetQueryObserver(o metrics.QueryObserver) {
+	m.observeQuery = o
+}
+
+// Close closes the database connection
+func (m *Manager) Close() error {
+	return m.db.Close()
+}
+
+// normalizeName trims and validates a playlist name
+func normalizeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", ErrInvalidName
+	}
+	if len([]rune(name)) > MaxNameLength {
+		name = string([]rune(name)[:MaxNameLength])
+	}
+	return name, nil
+}
+
+// Create creates a new playlist for a singer, optionally seeded with songs
+func (m *Manager) Create(ownerKey, name string, songIDs []string) (*models.Playlist, error) {
+	defer m.observeQuery.Time("create")()
+	name, err := normalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	id := uuid.New().String()
+	tx, err := m.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("INSERT INTO playlists (id, owner_key, name) VALUES (?, ?, ?)", id, ownerKey, name); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	position := 0
+	for _, songID := range songIDs {
+		if songID == "" || seen[songID] {
+			continue
+		}
+		seen[songID] = true
+		if _, err := tx.Exec(
+			"INSERT INTO playlist_songs (playlist_id, song_id, position, added_by) VALUES (?, ?, ?, ?)",
+			id, songID, position, ownerKey,
+		); err != nil {
+			return nil, err
+		}
+		position++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return m.Get(id)
+}
+
+// Get returns a playlist by ID
+func (m *Manager) Get(id string) (*models.Playlist, error) {
+	defer m.observeQuery.Time("get")()
+	var p models.Playlist
+	var shareToken sql.NullString
+	err := m.db.QueryRow(`
+		SELECT id, owner_key, name, share_token, created_at, updated_at
+		FROM playlists WHERE id = ?
+	`, id).Scan(&p.ID, &p.OwnerKey, &p.Name, &shareToken, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.ShareToken = shareToken.String
+
+	if p.SongIDs, err = m.songIDs(id); err != nil {
+		return nil, err
+	}
+	if p.SharedWith, p.Editors, err = m.sharedWith(id); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// songIDs returns a playlist's songs in order
+func (m *Manager) songIDs(id string) ([]string, error) {
+	rows, err := m.db.Query("SELECT song_id FROM playlist_songs WHERE playlist_id = ? ORDER BY position", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var songID string
+		if err := rows.Scan(&songID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, songID)
+	}
+	return ids, nil
+}
+
+// sharedWith returns the MartynKeys a playlist is shared with, and those of them who may edit it
+func (m *Manager) sharedWith(id string) (keys, editors []string, err error) {
+	rows, err := m.db.Query("SELECT martyn_key, can_edit FROM playlist_shares WHERE playlist_id = ? ORDER BY shared_at", id)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	keys, editors = []string{}, []string{}
+	for rows.Next() {
+		var key string
+		var canEdit bool
+		if err := rows.Scan(&key, &canEdit); err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, key)
+		if canEdit {
+			editors = append(editors, key)
+		}
+	}
+	return keys, editors, nil
+}
+
+// ListForUser returns playlists a singer owns or has been shared, owned first
+func (m *Manager) ListForUser(martynKey string) ([]models.Playlist, error) {
+	defer m.observeQuery.Time("list")()
+	rows, err := m.db.Query(`
+		SELECT id FROM playlists WHERE owner_key = ?
+		UNION ALL
+		SELECT p.id FROM playlists p
+		JOIN playlist_shares s ON s.playlist_id = p.id
+		WHERE s.martyn_key = ? AND p.owner_key != ?
+	`, martynKey, martynKey, martynKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	playlists := []models.Playlist{}
+	for _, id := range ids {
+		p, err := m.Get(id)
+		if err != nil {
+			continue
+		}
+		playlists = append(playlists, *p)
+	}
+	return playlists, nil
+}
+
+// CanView reports whether a singer may see and queue a playlist
+func CanView(p *models.Playlist, martynKey string) bool {
+	return p.OwnerKey == martynKey || slices.Contains(p.SharedWith, martynKey)
+}
+
+// CanEdit reports whether a singer may change a playlist's songs
+// Only the owner and the members they granted edit can change the song list
+func CanEdit(p *models.Playlist, martynKey string) bool {
+	return p.OwnerKey == martynKey || slices.Contains(p.Editors, martynKey)
+}
+
+// getForEdit loads a playlist and checks the actor may change its songs
+func (m *Manager) getForEdit(id, actorKey string) (*models.Playlist, error) {
+	p, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !CanEdit(p, actorKey) {
+		return nil, ErrNotAllowed
+	}
+	return p, nil
+}
+
+// getForOwner loads a playlist and checks the actor owns it
+func (m *Manager) getForOwner(id, actorKey string) (*models.Playlist, error) {
+	p, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if p.OwnerKey != actorKey {
+		return nil, ErrNotAllowed
+	}
+	return p, nil
+}
+
+// touch bumps a playlist's updated_at
+func (m *Manager) touch(id string) {
+	m.db.Exec("UPDATE playlists SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", id)
+}
+
+// Rename changes a playlist's name (owner only)
+func (m *Manager) Rename(id, actorKey, name string) (*models.Playlist, error) {
+	name, err := normalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.getForOwner(id, actorKey); err != nil {
+		return nil, err
+	}
+	if _, err := m.db.Exec("UPDATE playlists SET name = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", name, id); err != nil {
+		return nil, err
+	}
+	return m.Get(id)
+}
+
+// Delete removes a playlist with its songs and shares (owner only)
+func (m *Manager) Delete(id, actorKey string) error {
+	if _, err := m.getForOwner(id, actorKey); err != nil {
+		return err
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, q := range []string{
+		"DELETE FROM playlist_songs WHERE playlist_id = ?",
+		"DELETE FROM playlist_shares WHERE playlist_id = ?",
+		"DELETE FROM playlists WHERE id = ?",
+	} {
+		if _, err := tx.Exec(q, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// AddSong appends a song to a playlist
+func (m *Manager) AddSong(id, actorKey, songID string) (*models.Playlist, error) {
+	p, err := m.getForEdit(id, actorKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, existing := range p.SongIDs {
+		if existing == songID {
+			return nil, ErrDuplicate
+		}
+	}
+
+	_, err = m.db.Exec(
+		"INSERT INTO playlist_songs (playlist_id, song_id, position, added_by) VALUES (?, ?, ?, ?)",
+		id, songID, len(p.SongIDs), actorKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.touch(id)
+	return m.Get(id)
+}
+
+// RemoveSong removes a song from a playlist
+func (m *Manager) RemoveSong(id, actorKey, songID string) (*models.Playlist, error) {
+	p, err := m.getForEdit(id, actorKey)
+	if err != nil {
+		return nil, err
+	}
+
+	songIDs := make([]string, 0, len(p.SongIDs))
+	for _, existing := range p.SongIDs {
+		if existing != songID {
+			songIDs = append(songIDs, existing)
+		}
+	}
+	if len(songIDs) == len(p.SongIDs) {
+		return nil, ErrSongNotFound
+	}
+
+	if err := m.saveOrder(id, songIDs, true); err != nil {
+		return nil, err
+	}
+	return m.Get(id)
+}
+
+// MoveSong moves a song within a playlist from one index to another
+func (m *Manager) MoveSong(id, actorKey string, from, to int) (*models.Playlist, error) {
+	p, err := m.getForEdit(id, actorKey)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(p.SongIDs)
+	if from < 0 || from >= n || to < 0 || to >= n {
+		return nil, errors.New("invalid position")
+	}
+	if from == to {
+		return p, nil
+	}
+
+	songIDs := append([]string{}, p.SongIDs...)
+	moved := songIDs[from]
+	songIDs = append(songIDs[:from], songIDs[from+1:]...)
+	songIDs = append(songIDs[:to], append([]string{moved}, songIDs[to:]...)...)
+
+	if err := m.saveOrder(id, songIDs, false); err != nil {
+		return nil, err
+	}
+	return m.Get(id)
+}
+
+// saveOrder rewrites song positions; if prune is set, songs not listed are removed
+func (m *Manager) saveOrder(id string, songIDs []string, prune bool) error {
+	defer m.observeQuery.Time("save_order")()
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if prune {
+		placeholders := make([]string, len(songIDs))
+		args := []interface{}{id}
+		for i, songID := range songIDs {
+			placeholders[i] = "?"
+			args = append(args, songID)
+		}
+		q := "DELETE FROM playlist_songs WHERE playlist_id = ?"
+		if len(songIDs) > 0 {
+			q += " AND song_id NOT IN (" + strings.Join(placeholders, ",") + ")"
+		}
+		if _, err := tx.Exec(q, args...); err != nil {
+			return err
+		}
+	}
+
+	for i, songID := range songIDs {
+		if _, err := tx.Exec(
+			"UPDATE playlist_songs SET position = ? WHERE playlist_id = ? AND song_id = ?",
+			i, id, songID,
+		); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("UPDATE playlists SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Share gives another singer access to a playlist (owner only)
+// canEdit lets them change its songs too; sharing again with the same singer changes it
+func (m *Manager) Share(id, actorKey, targetKey string, canEdit bool) (*models.Playlist, error) {
+	if targetKey == "" || targetKey == actorKey {
+		return nil, errors.New("invalid share target")
+	}
+	if _, err := m.getForOwner(id, actorKey); err != nil {
+		return nil, err
+	}
+	if _, err := m.db.Exec(`
+		INSERT INTO playlist_shares (playlist_id, martyn_key, can_edit) VALUES (?, ?, ?)
+		ON CONFLICT (playlist_id, martyn_key) DO UPDATE SET can_edit = excluded.can_edit
+	`, id, targetKey, canEdit); err != nil {
+		return nil, err
+	}
+	return m.Get(id)
+}
+
+// Unshare revokes a singer's access (the owner, or the singer leaving it themselves)
+func (m *Manager) Unshare(id, actorKey, targetKey string) (*models.Playlist, error) {
+	p, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if p.OwnerKey != actorKey && targetKey != actorKey {
+		return nil, ErrNotAllowed
+	}
+	if _, err := m.db.Exec(
+		"DELETE FROM playlist_shares WHERE playlist_id = ? AND martyn_key = ?",
+		id, targetKey,
+	); err != nil {
+		return nil, err
+	}
+	return m.Get(id)
+}
+
+// CreateShareLink returns the playlist's share token, generating one if needed (owner only)
+// Set rotate to replace an existing token and invalidate old links
+func (m *Manager) CreateShareLink(id, actorKey string, rotate bool) (string, error) {
+	p, err := m.getForOwner(id, actorKey)
+	if err != nil {
+		return "", err
+	}
+	if p.ShareToken != "" && !rotate {
+		return p.ShareToken, nil
+	}
+
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(bytes)
+
+	if _, err := m.db.Exec("UPDATE playlists SET share_token = ? WHERE id = ?", token, id); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetByShareToken returns the playlist a share link points to
+func (m *Manager) GetByShareToken(token string) (*models.Playlist, error) {
+	if token == "" {
+		return nil, ErrNotFound
+	}
+	var id string
+	err := m.db.QueryRow("SELECT id FROM playlists WHERE share_token = ?", token).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m.Get(id)
+}
+
+// JoinByShareToken adds a singer to the playlist a share link points to
+// Link joins are view-only; the owner grants edit with Share
+func (m *Manager) JoinByShareToken(token, martynKey string) (*models.Playlist, error) {
+	p, err := m.GetByShareToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if p.OwnerKey == martynKey {
+		return p, nil
+	}
+	if _, err := m.db.Exec(
+		"INSERT OR IGNORE INTO playlist_shares (playlist_id, martyn_key) VALUES (?, ?)",
+		p.ID, martynKey,
+	); err != nil {
+		return nil, err
+	}
+	return m.Get(p.ID)
+}
+
+// Members returns everyone who should see updates to a playlist (owner + shares)
+func Members(p *models.Playlist) []string {
+	return append([]string{p.OwnerKey}, p.SharedWith...)
+}
@@ -0,0 +1,263 @@
+package playlist
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	manager, err := NewManager(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	t.Cleanup(func() { manager.Close() })
+	return manager
+}
+
+// ============================================================================
+// Playlist CRUD Tests
+// ============================================================================
+
+func TestCreateAndGet(t *testing.T) {
+	manager := newTestManager(t)
+
+	p, err := manager.Create("alice", "  Warmups  ", []string{"s1", "s2", "s1", ""})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if p.Name != "Warmups" {
+		t.Errorf("Expected trimmed name 'Warmups', got %q", p.Name)
+	}
+	if !reflect.DeepEqual(p.SongIDs, []string{"s1", "s2"}) {
+		t.Errorf("Expected songs [s1 s2], got %v", p.SongIDs)
+	}
+
+	got, err := manager.Get(p.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.OwnerKey != "alice" {
+		t.Errorf("Expected owner alice, got %s", got.OwnerKey)
+	}
+
+	if _, err := manager.Create("alice", "   ", nil); err != ErrInvalidName {
+		t.Errorf("Expected ErrInvalidName, got %v", err)
+	}
+	if _, err := manager.Get("missing"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestRenameAndDeleteOwnerOnly(t *testing.T) {
+	manager := newTestManager(t)
+	p, _ := manager.Create("alice", "Duets", nil)
+	manager.Share(p.ID, "alice", "bob", true)
+
+	if _, err := manager.Rename(p.ID, "bob", "Bob's now"); err != ErrNotAllowed {
+		t.Errorf("Expected ErrNotAllowed for shared user rename, got %v", err)
+	}
+	renamed, err := manager.Rename(p.ID, "alice", "Power Duets")
+	if err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if renamed.Name != "Power Duets" {
+		t.Errorf("Expected 'Power Duets', got %q", renamed.Name)
+	}
+
+	if err := manager.Delete(p.ID, "bob"); err != ErrNotAllowed {
+		t.Errorf("Expected ErrNotAllowed for shared user delete, got %v", err)
+	}
+	if err := manager.Delete(p.ID, "alice"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := manager.Get(p.ID); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	if list, _ := manager.ListForUser("bob"); len(list) != 0 {
+		t.Errorf("Expected deleted playlist to disappear for shared user, got %d", len(list))
+	}
+}
+
+// ============================================================================
+// Song Ordering Tests
+// ============================================================================
+
+func TestAddRemoveSong(t *testing.T) {
+	manager := newTestManager(t)
+	p, _ := manager.Create("alice", "Set", []string{"s1"})
+
+	p, err := manager.AddSong(p.ID, "alice", "s2")
+	if err != nil {
+		t.Fatalf("AddSong failed: %v", err)
+	}
+	if _, err := manager.AddSong(p.ID, "alice", "s2"); err != ErrDuplicate {
+		t.Errorf("Expected ErrDuplicate, got %v", err)
+	}
+	if _, err := manager.AddSong(p.ID, "mallory", "s3"); err != ErrNotAllowed {
+		t.Errorf("Expected ErrNotAllowed for stranger, got %v", err)
+	}
+
+	p, err = manager.RemoveSong(p.ID, "alice", "s1")
+	if err != nil {
+		t.Fatalf("RemoveSong failed: %v", err)
+	}
+	if !reflect.DeepEqual(p.SongIDs, []string{"s2"}) {
+		t.Errorf("Expected [s2], got %v", p.SongIDs)
+	}
+	if _, err := manager.RemoveSong(p.ID, "alice", "s1"); err != ErrSongNotFound {
+		t.Errorf("Expected ErrSongNotFound, got %v", err)
+	}
+
+	// Appending after a removal keeps positions consistent
+	p, _ = manager.AddSong(p.ID, "alice", "s3")
+	if !reflect.DeepEqual(p.SongIDs, []string{"s2", "s3"}) {
+		t.Errorf("Expected [s2 s3], got %v", p.SongIDs)
+	}
+}
+
+func TestMoveSong(t *testing.T) {
+	manager := newTestManager(t)
+	p, _ := manager.Create("alice", "Set", []string{"a", "b", "c", "d"})
+
+	p, err := manager.MoveSong(p.ID, "alice", 3, 0)
+	if err != nil {
+		t.Fatalf("MoveSong failed: %v", err)
+	}
+	if !reflect.DeepEqual(p.SongIDs, []string{"d", "a", "b", "c"}) {
+		t.Errorf("Expected [d a b c], got %v", p.SongIDs)
+	}
+
+	p, _ = manager.MoveSong(p.ID, "alice", 0, 2)
+	if !reflect.DeepEqual(p.SongIDs, []string{"a", "b", "d", "c"}) {
+		t.Errorf("Expected [a b d c], got %v", p.SongIDs)
+	}
+
+	if _, err := manager.MoveSong(p.ID, "alice", 0, 4); err == nil {
+		t.Error("Expected error for out of range move")
+	}
+}
+
+// ============================================================================
+// Sharing Tests
+// ============================================================================
+
+func TestShareAndUnshare(t *testing.T) {
+	manager := newTestManager(t)
+	p, _ := manager.Create("alice", "Duets", []string{"s1"})
+
+	if _, err := manager.Share(p.ID, "bob", "carol", false); err != ErrNotAllowed {
+		t.Errorf("Expected only the owner to share, got %v", err)
+	}
+	p, err := manager.Share(p.ID, "alice", "bob", true)
+	if err != nil {
+		t.Fatalf("Share failed: %v", err)
+	}
+	if !CanEdit(p, "bob") || !CanView(p, "bob") {
+		t.Error("Shared user should be able to view and edit")
+	}
+	if CanView(p, "carol") {
+		t.Error("Unshared user should not be able to view")
+	}
+
+	// Shared users can edit songs
+	if _, err := manager.AddSong(p.ID, "bob", "s2"); err != nil {
+		t.Errorf("Shared user AddSong failed: %v", err)
+	}
+
+	list, _ := manager.ListForUser("bob")
+	if len(list) != 1 || list[0].ID != p.ID {
+		t.Errorf("Expected bob to see the shared playlist, got %v", list)
+	}
+
+	// Shared users may leave on their own, but not remove others
+	manager.Share(p.ID, "alice", "carol", false)
+	if _, err := manager.Unshare(p.ID, "bob", "carol"); err != ErrNotAllowed {
+		t.Errorf("Expected ErrNotAllowed removing another user, got %v", err)
+	}
+	p, err = manager.Unshare(p.ID, "bob", "bob")
+	if err != nil {
+		t.Fatalf("Unshare failed: %v", err)
+	}
+	if !reflect.DeepEqual(p.SharedWith, []string{"carol"}) {
+		t.Errorf("Expected [carol], got %v", p.SharedWith)
+	}
+}
+
+func TestShareLinkJoinIsViewOnly(t *testing.T) {
+	manager := newTestManager(t)
+	p, _ := manager.Create("alice", "Party", []string{"s1", "s2"})
+	token, _ := manager.CreateShareLink(p.ID, "alice", false)
+
+	joined, err := manager.JoinByShareToken(token, "dave")
+	if err != nil {
+		t.Fatalf("JoinByShareToken failed: %v", err)
+	}
+	if CanEdit(joined, "dave") {
+		t.Error("Expected a link join to be view-only")
+	}
+	if _, err := manager.AddSong(p.ID, "dave", "s3"); err != ErrNotAllowed {
+		t.Errorf("Expected ErrNotAllowed adding a song, got %v", err)
+	}
+	if _, err := manager.RemoveSong(p.ID, "dave", "s1"); err != ErrNotAllowed {
+		t.Errorf("Expected ErrNotAllowed removing a song, got %v", err)
+	}
+	if _, err := manager.MoveSong(p.ID, "dave", 0, 1); err != ErrNotAllowed {
+		t.Errorf("Expected ErrNotAllowed moving a song, got %v", err)
+	}
+
+	// The owner grants edit explicitly, and can take it back
+	p, err = manager.Share(p.ID, "alice", "dave", true)
+	if err != nil {
+		t.Fatalf("Share failed: %v", err)
+	}
+	if !reflect.DeepEqual(p.Editors, []string{"dave"}) {
+		t.Errorf("Expected dave as the only editor, got %v", p.Editors)
+	}
+	if _, err := manager.AddSong(p.ID, "dave", "s3"); err != nil {
+		t.Errorf("Expected dave to add a song once granted edit, got %v", err)
+	}
+	p, _ = manager.Share(p.ID, "alice", "dave", false)
+	if CanEdit(p, "dave") || !CanView(p, "dave") {
+		t.Error("Expected dave back to view-only")
+	}
+}
+
+func TestShareLink(t *testing.T) {
+	manager := newTestManager(t)
+	p, _ := manager.Create("alice", "Party", []string{"s1"})
+
+	if _, err := manager.CreateShareLink(p.ID, "bob", false); err != ErrNotAllowed {
+		t.Errorf("Expected ErrNotAllowed, got %v", err)
+	}
+	token, err := manager.CreateShareLink(p.ID, "alice", false)
+	if err != nil {
+		t.Fatalf("CreateShareLink failed: %v", err)
+	}
+	if again, _ := manager.CreateShareLink(p.ID, "alice", false); again != token {
+		t.Errorf("Expected stable token, got %s then %s", token, again)
+	}
+
+	joined, err := manager.JoinByShareToken(token, "dave")
+	if err != nil {
+		t.Fatalf("JoinByShareToken failed: %v", err)
+	}
+	if !CanView(joined, "dave") {
+		t.Error("Joined user should have access")
+	}
+
+	// Joining again by link never takes away edit the owner granted
+	manager.Share(p.ID, "alice", "dave", true)
+	if joined, _ = manager.JoinByShareToken(token, "dave"); !CanEdit(joined, "dave") {
+		t.Error("Expected a repeat link join to keep edit access")
+	}
+
+	rotated, _ := manager.CreateShareLink(p.ID, "alice", true)
+	if rotated == token {
+		t.Error("Expected rotated token to differ")
+	}
+	if _, err := manager.GetByShareToken(token); err != ErrNotFound {
+		t.Errorf("Expected old token to stop working, got %v", err)
+	}
+}
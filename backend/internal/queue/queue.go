@@ -3,14 +3,20 @@ package queue
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"math/rand"
 	"sync"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+	"songmartyn/internal/metrics"
 	"songmartyn/pkg/models"
 )
 
+// SchemaVersion is the layout of queue.db, stored in its user_version so restores can
+// tell a backup from a newer SongMartyn apart from one the migrations can upgrade
+const SchemaVersion = 1
+
 // Manager handles the song queue with persistence
 type Manager struct {
 	db           *sql.DB
@@ -21,7 +27,8 @@ type Manager struct {
 	mu           sync.RWMutex
 
 	// Callbacks
-	onChange func()
+	onChange     func()
+	observeQuery metrics.QueryObserver
 }
 
 // NewManager creates a new queue manager with SQLite persistence
@@ -66,9 +73,13 @@ func NewManager(dbPath string) (*Manager, error) {
 
 	// Add autoplay column if it doesn't exist (migration for existing DBs)
 	db.Exec(`ALTER TABLE queue_state ADD COLUMN autoplay INTEGER DEFAULT 0`)
+	db.Exec(`ALTER TABLE queue ADD COLUMN vocal_gain REAL DEFAULT 0`)
 
 	// Initialize state if not exists (autoplay defaults to OFF)
 	db.Exec(`INSERT OR IGNORE INTO queue_state (id, position, autoplay) VALUES (1, 0, 0)`)
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", SchemaVersion)); err != nil {
+		return nil, err
+	}
 
 	m := &Manager{
 		db:    db,
@@ -89,6 +100,7 @@ func NewManager(dbPath string) (*Manager, error) {
 
 // loadQueue loads the queue from SQLite
 func (m *Manager) loadQueue() error {
+	defer m.observeQuery.Time("load")()
 	// Load position and autoplay
 	var autoplayInt int
 	row := m.db.QueryRow(`SELECT position, COALESCE(autoplay, 0) FROM queue_state WHERE id = 1`)
@@ -98,7 +110,7 @@ func (m *Manager) loadQueue() error {
 	// Load songs
 	rows, err := m.db.Query(`
 		SELECT id, title, artist, duration, thumbnail_url, video_url,
-		       vocal_path, instr_path, vocal_assist, added_by, added_at
+		       vocal_path, instr_path, vocal_assist, COALESCE(vocal_gain, 0), added_by, added_at
 		FROM queue
 		ORDER BY queue_order ASC
 	`)
@@ -123,6 +135,7 @@ func (m *Manager) loadQueue() error {
 			&vocalPath,
 			&instrPath,
 			&song.VocalAssist,
+			&song.VocalGain,
 			&song.AddedBy,
 			&addedAt,
 		)
@@ -182,6 +195,34 @@ func (m *Manager) Add(song models.Song) error {
 	return err
 }
 
+// AddMany adds several songs in order (e.g. a whole playlist), notifying listeners once
+// With fair rotation enabled each song is placed as if it had been added on its own
+func (m *Manager) AddMany(songs []models.Song) error {
+	if len(songs) == 0 {
+		return nil
+	}
+
+	m.mu.Lock()
+	now := time.Now()
+	for _, song := range songs {
+		song.AddedAt = now
+		if m.fairRotation {
+			insertPos := m.findFairInsertPosition(song.AddedBy)
+			m.songs = append(m.songs[:insertPos], append([]models.Song{song}, m.songs[insertPos:]...)...)
+		} else {
+			m.songs = append(m.songs, song)
+		}
+	}
+	err := m.reorderQueue()
+	onChange := m.onChange
+	m.mu.Unlock()
+
+	if onChange != nil {
+		onChange()
+	}
+	return err
+}
+
 // findFairInsertPosition finds the optimal position to insert a song for fair rotation
 // The algorithm ensures singers take turns fairly
 func (m *Manager) findFairInsertPosition(singerKey string) int {
@@ -678,6 +719,26 @@ func (m *Manager) UpdateSongPaths(songID, vocalPath, instrPath string) error {
 	return nil
 }
 
+// UpdateCurrentVocal changes the vocal assist of the song that is playing
+// Returns false when there is no current song
+func (m *Manager) UpdateCurrentVocal(level models.VocalAssistLevel, gain float64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.position < 0 || m.position >= len(m.songs) {
+		return false, nil
+	}
+	song := &m.songs[m.position]
+	song.VocalAssist = level
+	song.VocalGain = gain
+
+	_, err := m.db.Exec(
+		`UPDATE queue SET vocal_assist = ?, vocal_gain = ? WHERE id = ?`,
+		level, gain, song.ID,
+	)
+	return true, err
+}
+
 // OnChange sets the callback for queue changes
 func (m *Manager) OnChange(fn func()) {
 	m.onChange = fn
@@ -685,11 +746,12 @@ func (m *Manager) OnChange(fn func()) {
 
 // saveSong persists a song to SQLite
 func (m *Manager) saveSong(song models.Song, order int) error {
+	defer m.observeQuery.Time("save_song")()
 	_, err := m.db.Exec(`
 		INSERT OR REPLACE INTO queue
 		(id, title, artist, duration, thumbnail_url, video_url,
-		 vocal_path, instr_path, vocal_assist, added_by, added_at, queue_order)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 vocal_path, instr_path, vocal_assist, vocal_gain, added_by, added_at, queue_order)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		song.ID,
 		song.Title,
@@ -700,6 +762,7 @@ func (m *Manager) saveSong(song models.Song, order int) error {
 		song.VocalPath,
 		song.InstrPath,
 		song.VocalAssist,
+		song.VocalGain,
 		song.AddedBy,
 		song.AddedAt.Format(time.RFC3339),
 		order,
@@ -710,6 +773,7 @@ func (m *Manager) saveSong(song models.Song, order int) error {
 // reorderQueue saves all songs with their current position in the queue
 // Must be called with lock held
 func (m *Manager) reorderQueue() error {
+	defer m.observeQuery.Time("reorder")()
 	for i, song := range m.songs {
 		if err := m.saveSong(song, i); err != nil {
 			return err
@@ -720,9 +784,15 @@ func (m *Manager) reorderQueue() error {
 
 // savePosition persists the queue position
 func (m *Manager) savePosition() {
+	defer m.observeQuery.Time("save_position")()
 	m.db.Exec(`UPDATE queue_state SET position = ? WHERE id = 1`, m.position)
 }
 
+// SetQueryObserver reports how long each database operation takes, for metrics
+func (m *Manager) SetQueryObserver(o metrics.QueryObserver) {
+	m.observeQuery = o
+}
+
 // Close closes the database connection
 func (m *Manager) Close() error {
 	return m.db.Close()
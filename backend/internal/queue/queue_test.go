@@ -745,6 +745,42 @@ func TestUpdateSongPaths(t *testing.T) {
 	}
 }
 
+func TestUpdateCurrentVocal(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "queue_test_*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpFile.Name())
+	tmpFile.Close()
+
+	manager, err := NewManager(tmpFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := manager.UpdateCurrentVocal(models.VocalCustom, 0.3); ok {
+		t.Error("UpdateCurrentVocal should report false on an empty queue")
+	}
+
+	manager.Add(createTestSong("song1", "Song One", "Artist", "user1"))
+	if ok, err := manager.UpdateCurrentVocal(models.VocalCustom, 0.3); !ok || err != nil {
+		t.Fatalf("UpdateCurrentVocal failed: %v, %v", ok, err)
+	}
+	manager.Close()
+
+	// Reopen manager
+	manager2, err := NewManager(tmpFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager2.Close()
+
+	current := manager2.Current()
+	if current.VocalAssist != models.VocalCustom || current.VocalGain != 0.3 {
+		t.Errorf("Expected CUSTOM at 0.3 after reload, got %s at %v", current.VocalAssist, current.VocalGain)
+	}
+}
+
 // =============================================================================
 // History-Related Tests
 // =============================================================================
@@ -1165,3 +1201,93 @@ func TestShuffleSingleSong(t *testing.T) {
 		t.Error("Single song should remain unchanged after shuffle")
 	}
 }
+
+// =============================================================================
+// Batch Add Tests
+// =============================================================================
+
+// TestAddManyRespectsFairRotation verifies a batch lands where one-by-one adds would
+func TestAddManyRespectsFairRotation(t *testing.T) {
+	newFairManager := func() *Manager {
+		tmpFile, err := os.CreateTemp("", "queue_test_*.db")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.Remove(tmpFile.Name()) })
+		tmpFile.Close()
+
+		manager, err := NewManager(tmpFile.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { manager.Close() })
+		manager.SetFairRotation(true)
+
+		manager.Add(createTestSong("a1", "A1", "Artist", "alice"))
+		manager.Add(createTestSong("a2", "A2", "Artist", "alice"))
+		manager.Add(createTestSong("c1", "C1", "Artist", "carol"))
+		manager.Add(createTestSong("a3", "A3", "Artist", "alice"))
+		return manager
+	}
+
+	batch := []models.Song{
+		createTestSong("b1", "B1", "Artist", "bob"),
+		createTestSong("b2", "B2", "Artist", "bob"),
+		createTestSong("b3", "B3", "Artist", "bob"),
+	}
+
+	// Reference: add one at a time
+	single := newFairManager()
+	for _, song := range batch {
+		single.Add(song)
+	}
+
+	batched := newFairManager()
+	changes := 0
+	batched.OnChange(func() { changes++ })
+	if err := batched.AddMany(batch); err != nil {
+		t.Fatalf("AddMany failed: %v", err)
+	}
+
+	if changes != 1 {
+		t.Errorf("Expected 1 change notification, got %d", changes)
+	}
+
+	expected := single.GetState().Songs
+	got := batched.GetState().Songs
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d songs, got %d", len(expected), len(got))
+	}
+	for i := range expected {
+		if got[i].ID != expected[i].ID {
+			t.Errorf("Position %d: expected %s, got %s", i, expected[i].ID, got[i].ID)
+		}
+	}
+}
+
+// TestAddManyFIFO verifies batch adds append in order without fair rotation
+func TestAddManyFIFO(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "queue_test_*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpFile.Name())
+	tmpFile.Close()
+
+	manager, err := NewManager(tmpFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	manager.Add(createTestSong("a1", "A1", "Artist", "alice"))
+	manager.AddMany([]models.Song{
+		createTestSong("b1", "B1", "Artist", "bob"),
+		createTestSong("b2", "B2", "Artist", "bob"),
+	})
+
+	state := manager.GetState()
+	if len(state.Songs) != 3 || state.Songs[2].ID != "b2" {
+		t.Errorf("Expected b2 last, got %+v", state.Songs)
+	}
+}
@@ -0,0 +1,516 @@
+package recording
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"songmartyn/internal/logging"
+)
+
+// logger is the "recording" subsystem
+var logger = logging.For("recording")
+
+// DefaultCommand captures what the speakers play (the PulseAudio/PipeWire monitor
+// of the default output, i.e. backing track plus mic) until interrupted
+const DefaultCommand = "ffmpeg -nostdin -v error -f pulse -i default.monitor -ac 2 -c:a libopus -b:a 128k {output}"
+
+// Recording statuses
+const (
+	StatusRecording = "recording"
+	StatusComplete  = "complete"
+	StatusFailed    = "failed"
+)
+
+// stopGrace is how long the capture command gets to finish its file after an interrupt
+const stopGrace = 5 * time.Second
+
+var (
+	ErrNotFound    = errors.New("recording not found")
+	ErrLinkExpired = errors.New("download link expired")
+)
+
+// Options configures the recorder
+type Options struct {
+	Dir       string        // Where recordings are written
+	Command   string        // Capture command; {output} is replaced by the file path
+	Extension string        // File extension matching the command's output format
+	MaxBytes  int64         // Total size cap across recordings (0 = unlimited)
+	MaxAge    time.Duration // Recordings older than this are deleted (0 = keep)
+	LinkTTL   time.Duration // How long download links stay valid
+}
+
+// Entry identifies the queue entry being recorded
+type Entry struct {
+	SongID    string
+	Title     string
+	Artist    string
+	MartynKey string
+}
+
+// Recording is one captured performance
+type Recording struct {
+	ID         int64      `json:"id"`
+	HistoryID  int64      `json:"history_id,omitempty"` // song_history row, once the song finished
+	SongID     string     `json:"song_id"`
+	SongTitle  string     `json:"song_title"`
+	SongArtist string     `json:"song_artist"`
+	MartynKey  string     `json:"martyn_key"`
+	FileName   string     `json:"-"`
+	SizeBytes  int64      `json:"size_bytes"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Duration returns how long the recording ran
+func (r Recording) Duration() time.Duration {
+	if r.FinishedAt == nil {
+		return 0
+	}
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// Link is a private, expiring download link for a recording
+type Link struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// DownloadPath is where download links are served
+const DownloadPath = "/api/recordings/"
+
+// active is the capture in progress
+type active struct {
+	id   int64
+	path string
+	cmd  *exec.Cmd
+	done chan error
+}
+
+// SchemaVersion is the layout of recordings.db, stored in its user_version so restores can
+// tell a backup from a newer SongMartyn apart from one the migrations can upgrade
+const SchemaVersion = 1
+
+// Manager records performances and keeps their files within the retention policy
+type Manager struct {
+	db      *sql.DB
+	opts    Options
+	command []string
+
+	mu        sync.Mutex
+	active    *active
+	finishing sync.WaitGroup // Captures Finish is wrapping up in the background
+}
+
+// NewManager creates a recorder storing its index in dbPath
+func NewManager(dbPath string, opts Options) (*Manager, error) {
+	if opts.Command == "" {
+		opts.Command = DefaultCommand
+	}
+	if opts.Extension == "" {
+		opts.Extension = "ogg"
+	}
+	opts.Extension = strings.TrimPrefix(opts.Extension, ".")
+	if opts.LinkTTL <= 0 {
+		opts.LinkTTL = 24 * time.Hour
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manager{db: db, opts: opts, command: strings.Fields(opts.Command)}
+	if err := m.initDB(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// Captures interrupted by a crash never finished
+	m.db.Exec(`UPDATE recordings SET status = ? WHERE status = ?`, StatusFailed, StatusRecording)
+	if err := m.Prune(); err != nil {
+		logger.Warn("Prune failed", "err", err)
+	}
+	return m, nil
+}
+
+// initDB creates the necessary tables
+func (m *Manager) initDB() error {
+	_, err := m.db.Exec(`
+	CREATE TABLE IF NOT EXISTS recordings (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		history_id INTEGER DEFAULT 0,
+		song_id TEXT NOT NULL,
+		song_title TEXT NOT NULL,
+		song_artist TEXT DEFAULT '',
+		martyn_key TEXT NOT NULL,
+		file_name TEXT DEFAULT '',
+		size_bytes INTEGER DEFAULT 0,
+		status TEXT NOT NULL,
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS recording_links (
+		token TEXT PRIMARY KEY,
+		recording_id INTEGER NOT NULL,
+		expires_at DATETIME NOT NULL,
+		FOREIGN KEY (recording_id) REFERENCES recordings(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_recordings_martyn ON recordings(martyn_key);
+	CREATE INDEX IF NOT EXISTS idx_recording_links_recording ON recording_links(recording_id);
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = m.db.Exec(fmt.Sprintf("PRAGMA user_version = %d", SchemaVersion))
+	return err
+}
+
+// Close stops any capture and closes the database
+func (m *Manager) Close() error {
+	m.Stop(0)
+	m.finishing.Wait()
+	return m.db.Close()
+}
+
+// Start begins recording an entry, finishing any capture still running
+func (m *Manager) Start(entry Entry) (*Recording, error) {
+	// Hold the lock across the swap so a concurrent Start can't leave a capture untracked
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if prev := m.active; prev != nil {
+		m.active = nil
+		if _, err := m.finish(prev, 0); err != nil {
+			logger.Warn("Failed to finish previous recording", "err", err)
+		}
+	}
+
+	now := time.Now()
+	res, err := m.db.Exec(`
+		INSERT INTO recordings (song_id, song_title, song_artist, martyn_key, status, started_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, entry.SongID, entry.Title, entry.Artist, entry.MartynKey, StatusRecording, now)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+
+	fileName := fmt.Sprintf("%d-%s.%s", id, now.Format("20060102-150405"), m.opts.Extension)
+	path := filepath.Join(m.opts.Dir, fileName)
+	m.db.Exec(`UPDATE recordings SET file_name = ? WHERE id = ?`, fileName, id)
+
+	args := make([]string, len(m.command))
+	for i, arg := range m.command {
+		args[i] = strings.ReplaceAll(arg, "{output}", path)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	if err := cmd.Start(); err != nil {
+		m.db.Exec(`UPDATE recordings SET status = ? WHERE id = ?`, StatusFailed, id)
+		return nil, fmt.Errorf("failed to start recorder: %w", err)
+	}
+
+	a := &active{id: id, path: path, cmd: cmd, done: make(chan error, 1)}
+	go func() { a.done <- cmd.Wait() }()
+	m.active = a
+	logger.Info("Recording", "title", entry.Title, "singer", shortKey(entry.MartynKey))
+
+	return m.Get(id)
+}
+
+// Stop finishes the current capture and links it to a song_history row
+// (0 when the song didn't finish, e.g. it was skipped)
+func (m *Manager) Stop(historyID int64) (*Recording, error) {
+	a := m.detach()
+	if a == nil {
+		return nil, nil
+	}
+	rec, err := m.finish(a, historyID)
+	if err := m.Prune(); err != nil {
+		logger.Warn("Prune failed", "err", err)
+	}
+	return rec, err
+}
+
+// Finish is Stop without the wait: the capture is detached at once and wrapped up in
+// the background, since the command can take up to stopGrace to exit. done, if set,
+// gets the finished recording (nil when nothing was recording)
+func (m *Manager) Finish(historyID int64, done func(*Recording, error)) {
+	a := m.detach()
+	if a == nil {
+		if done != nil {
+			done(nil, nil)
+		}
+		return
+	}
+
+	m.finishing.Add(1)
+	go func() {
+		defer m.finishing.Done()
+		rec, err := m.finish(a, historyID)
+		if err := m.Prune(); err != nil {
+			logger.Warn("Prune failed", "err", err)
+		}
+		if done != nil {
+			done(rec, err)
+		}
+	}()
+}
+
+// detach takes the running capture, if any, so nothing else will stop it
+func (m *Manager) detach() *active {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a := m.active
+	m.active = nil
+	return a
+}
+
+// finish stops a detached capture and records how it went
+func (m *Manager) finish(a *active, historyID int64) (*Recording, error) {
+	waitErr := interrupt(a)
+	status := StatusComplete
+	var size int64
+	if info, err := os.Stat(a.path); err == nil && info.Size() > 0 {
+		size = info.Size()
+	} else {
+		status = StatusFailed
+		logger.Warn("Capture produced no audio", "exit", waitErr)
+	}
+
+	_, err := m.db.Exec(`
+		UPDATE recordings SET history_id = ?, size_bytes = ?, status = ?, finished_at = ?
+		WHERE id = ?
+	`, historyID, size, status, time.Now(), a.id)
+	if err != nil {
+		return nil, err
+	}
+	return m.Get(a.id)
+}
+
+// Discard stops the current capture and deletes it (e.g. the song failed to load)
+func (m *Manager) Discard() {
+	a := m.detach()
+	if a == nil {
+		return
+	}
+	interrupt(a)
+	m.delete(a.id)
+}
+
+// interrupt asks the capture command to finish its file, killing it after stopGrace
+func interrupt(a *active) error {
+	select {
+	case err := <-a.done:
+		return err // Already exited
+	default:
+	}
+	if runtime.GOOS == "windows" {
+		a.cmd.Process.Kill()
+	} else {
+		a.cmd.Process.Signal(os.Interrupt)
+	}
+	select {
+	case err := <-a.done:
+		return err
+	case <-time.After(stopGrace):
+		a.cmd.Process.Kill()
+		return <-a.done
+	}
+}
+
+// Recording reports whether a capture is running
+func (m *Manager) Recording() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.active != nil
+}
+
+const recordingColumns = `id, history_id, song_id, song_title, song_artist, martyn_key, file_name,
+	size_bytes, status, started_at, finished_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRecording(row rowScanner) (Recording, error) {
+	var r Recording
+	var finished sql.NullTime
+	err := row.Scan(&r.ID, &r.HistoryID, &r.SongID, &r.SongTitle, &r.SongArtist, &r.MartynKey,
+		&r.FileName, &r.SizeBytes, &r.Status, &r.StartedAt, &finished)
+	if finished.Valid {
+		r.FinishedAt = &finished.Time
+	}
+	return r, err
+}
+
+// Get returns a recording by ID
+func (m *Manager) Get(id int64) (*Recording, error) {
+	r, err := scanRecording(m.db.QueryRow(`SELECT `+recordingColumns+` FROM recordings WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ForSinger returns a singer's finished recordings, newest first
+func (m *Manager) ForSinger(martynKey string) ([]Recording, error) {
+	return m.query(`
+		SELECT `+recordingColumns+` FROM recordings
+		WHERE martyn_key = ? AND status = ?
+		ORDER BY started_at DESC, id DESC
+	`, martynKey, StatusComplete)
+}
+
+// List returns every recording, newest first
+func (m *Manager) List() ([]Recording, error) {
+	return m.query(`SELECT ` + recordingColumns + ` FROM recordings ORDER BY started_at DESC, id DESC`)
+}
+
+func (m *Manager) query(q string, args ...interface{}) ([]Recording, error) {
+	rows, err := m.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recs []Recording
+	for rows.Next() {
+		r, err := scanRecording(rows)
+		if err != nil {
+			continue
+		}
+		recs = append(recs, r)
+	}
+	return recs, rows.Err()
+}
+
+// Link issues a new private download link for a recording
+func (m *Manager) Link(id int64) (*Link, error) {
+	rec, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Status != StatusComplete {
+		return nil, fmt.Errorf("recording %d is not complete", id)
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(buf)
+	expires := time.Now().Add(m.opts.LinkTTL)
+
+	// Expired links are useless; drop them while we're here
+	m.db.Exec(`DELETE FROM recording_links WHERE expires_at < ?`, time.Now())
+	if _, err := m.db.Exec(`
+		INSERT INTO recording_links (token, recording_id, expires_at) VALUES (?, ?, ?)
+	`, token, id, expires); err != nil {
+		return nil, err
+	}
+	return &Link{Token: token, URL: DownloadPath + token, ExpiresAt: expires}, nil
+}
+
+// Resolve returns the recording a download token points at, with its file path
+func (m *Manager) Resolve(token string) (*Recording, string, error) {
+	var id int64
+	var expires time.Time
+	err := m.db.QueryRow(`SELECT recording_id, expires_at FROM recording_links WHERE token = ?`, token).Scan(&id, &expires)
+	if err == sql.ErrNoRows {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if time.Now().After(expires) {
+		return nil, "", ErrLinkExpired
+	}
+	rec, err := m.Get(id)
+	if err != nil {
+		return nil, "", err
+	}
+	return rec, filepath.Join(m.opts.Dir, rec.FileName), nil
+}
+
+// Delete removes a recording and its file
+func (m *Manager) Delete(id int64) error {
+	rec, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+	if rec.Status == StatusRecording {
+		return fmt.Errorf("recording %d is still running", id)
+	}
+	return m.delete(id)
+}
+
+func (m *Manager) delete(id int64) error {
+	var fileName string
+	m.db.QueryRow(`SELECT file_name FROM recordings WHERE id = ?`, id).Scan(&fileName)
+	if fileName != "" {
+		os.Remove(filepath.Join(m.opts.Dir, fileName))
+	}
+	m.db.Exec(`DELETE FROM recording_links WHERE recording_id = ?`, id)
+	_, err := m.db.Exec(`DELETE FROM recordings WHERE id = ?`, id)
+	return err
+}
+
+// Prune applies the retention policy: failed captures and recordings older than
+// MaxAge are deleted, then the oldest go until the total fits in MaxBytes
+func (m *Manager) Prune() error {
+	recs, err := m.List()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	var keep []Recording
+	for _, r := range recs {
+		switch {
+		case r.Status == StatusRecording: // Still capturing or being finished
+		case r.Status == StatusFailed,
+			m.opts.MaxAge > 0 && time.Since(r.StartedAt) > m.opts.MaxAge:
+			m.delete(r.ID)
+		default:
+			total += r.SizeBytes
+			keep = append(keep, r)
+		}
+	}
+
+	// keep is newest first, so trim from the end
+	for i := len(keep) - 1; i >= 0 && m.opts.MaxBytes > 0 && total > m.opts.MaxBytes; i-- {
+		logger.Info("Deleting to stay under the size cap", "title", keep[i].SongTitle, "bytes", keep[i].SizeBytes)
+		m.delete(keep[i].ID)
+		total -= keep[i].SizeBytes
+	}
+	return nil
+}
+
+// shortKey abbreviates a MartynKey for logs
+func shortKey(key string) string {
+	if len(key) > 8 {
+		return key[:8]
+	}
+	return key
+}
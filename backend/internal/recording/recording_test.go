@@ -0,0 +1,329 @@
+package recording
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// captureScript stands in for ffmpeg: it writes $2 bytes to $1 and then
+// runs until interrupted, like a capture would
+const captureScript = `trap 'exit 0' INT TERM
+head -c "$2" /dev/zero > "$1"
+while :; do sleep 0.05; done
+`
+
+// slowCaptureScript takes a while to finish its file once interrupted
+const slowCaptureScript = `trap 'sleep 0.5; exit 0' INT TERM
+head -c "$2" /dev/zero > "$1"
+while :; do sleep 0.05; done
+`
+
+func newTestManager(t *testing.T, opts Options, size int) *Manager {
+	t.Helper()
+	return newScriptManager(t, captureScript, opts, size)
+}
+
+func newScriptManager(t *testing.T, capture string, opts Options, size int) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "capture.sh")
+	if err := os.WriteFile(script, []byte(capture), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts.Dir = filepath.Join(dir, "recordings")
+	opts.Command = "sh " + script + " {output} " + strconv.Itoa(size)
+	m, err := NewManager(filepath.Join(dir, "recordings.db"), opts)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+// record runs one capture to completion
+func record(t *testing.T, m *Manager, entry Entry, historyID int64) *Recording {
+	t.Helper()
+	rec, err := m.Start(entry)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	waitForFile(t, filepath.Join(m.opts.Dir, rec.FileName))
+	done, err := m.Stop(historyID)
+	if err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	return done
+}
+
+func waitForFile(t *testing.T, path string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Capture never wrote %s", path)
+}
+
+// ============================================================================
+// Recording Tests
+// ============================================================================
+
+func TestRecordAndLinkHistory(t *testing.T) {
+	m := newTestManager(t, Options{}, 1000)
+
+	rec, err := m.Start(Entry{SongID: "s1", Title: "Song One", Artist: "Artist", MartynKey: "alice"})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if rec.Status != StatusRecording || !m.Recording() {
+		t.Errorf("Expected a running recording, got status %s", rec.Status)
+	}
+	waitForFile(t, filepath.Join(m.opts.Dir, rec.FileName))
+
+	done, err := m.Stop(42)
+	if err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if done.Status != StatusComplete {
+		t.Errorf("Expected status complete, got %s", done.Status)
+	}
+	if done.HistoryID != 42 {
+		t.Errorf("Expected history ID 42, got %d", done.HistoryID)
+	}
+	if done.SizeBytes != 1000 {
+		t.Errorf("Expected 1000 bytes, got %d", done.SizeBytes)
+	}
+	if done.FinishedAt == nil {
+		t.Error("Expected a finish time")
+	}
+	if m.Recording() {
+		t.Error("Expected no running recording after Stop")
+	}
+
+	// A second Stop has nothing to do
+	if again, err := m.Stop(1); again != nil || err != nil {
+		t.Errorf("Expected no-op Stop, got %v, %v", again, err)
+	}
+}
+
+func TestStartFinishesPreviousRecording(t *testing.T) {
+	m := newTestManager(t, Options{}, 500)
+
+	first, _ := m.Start(Entry{SongID: "s1", Title: "One", MartynKey: "alice"})
+	waitForFile(t, filepath.Join(m.opts.Dir, first.FileName))
+	second := record(t, m, Entry{SongID: "s2", Title: "Two", MartynKey: "bob"}, 7)
+
+	got, err := m.Get(first.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Status != StatusComplete || got.HistoryID != 0 {
+		t.Errorf("Expected skipped recording complete and unlinked, got %s/%d", got.Status, got.HistoryID)
+	}
+	if second.HistoryID != 7 {
+		t.Errorf("Expected history ID 7, got %d", second.HistoryID)
+	}
+
+	alice, _ := m.ForSinger("alice")
+	if len(alice) != 1 || alice[0].ID != first.ID {
+		t.Errorf("Expected alice to have only her recording, got %v", alice)
+	}
+}
+
+func TestFinishRunsInBackground(t *testing.T) {
+	m := newScriptManager(t, slowCaptureScript, Options{}, 500)
+
+	first, _ := m.Start(Entry{SongID: "s1", Title: "One", MartynKey: "alice"})
+	waitForFile(t, filepath.Join(m.opts.Dir, first.FileName))
+
+	done := make(chan *Recording, 1)
+	start := time.Now()
+	m.Finish(42, func(rec *Recording, err error) {
+		if err != nil {
+			t.Errorf("Finish failed: %v", err)
+		}
+		done <- rec
+	})
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("Expected Finish to return at once, took %v", elapsed)
+	}
+	if m.Recording() {
+		t.Error("Expected no running recording after Finish")
+	}
+
+	// The next capture starts while the last one is still finishing
+	second, err := m.Start(Entry{SongID: "s2", Title: "Two", MartynKey: "bob"})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := m.Delete(first.ID); err == nil {
+		t.Error("Expected Delete to refuse a recording still finishing")
+	}
+
+	select {
+	case rec := <-done:
+		if rec == nil || rec.ID != first.ID || rec.Status != StatusComplete || rec.HistoryID != 42 {
+			t.Errorf("Expected the first recording complete and linked, got %+v", rec)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Finish never reported back")
+	}
+	if got, _ := m.Get(second.ID); got == nil || got.Status != StatusRecording {
+		t.Errorf("Expected the second capture still running, got %+v", got)
+	}
+}
+
+func TestConcurrentStartsKeepOneCapture(t *testing.T) {
+	m := newTestManager(t, Options{}, 100)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := m.Start(Entry{SongID: strconv.Itoa(i), Title: "Song", MartynKey: "alice"}); err != nil {
+				t.Errorf("Start failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	m.Stop(0)
+
+	// Every capture was finished by the next Start or the Stop, none left running
+	recs, _ := m.List()
+	for _, r := range recs {
+		if r.Status == StatusRecording {
+			t.Errorf("Recording %d was left running", r.ID)
+		}
+	}
+}
+
+func TestDiscardDeletesRecording(t *testing.T) {
+	m := newTestManager(t, Options{}, 500)
+
+	rec, _ := m.Start(Entry{SongID: "s1", Title: "One", MartynKey: "alice"})
+	path := filepath.Join(m.opts.Dir, rec.FileName)
+	waitForFile(t, path)
+	m.Discard()
+
+	if _, err := m.Get(rec.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected file to be removed, got %v", err)
+	}
+}
+
+func TestEmptyCaptureFails(t *testing.T) {
+	m := newTestManager(t, Options{}, 0)
+
+	rec, err := m.Start(Entry{SongID: "s1", Title: "One", MartynKey: "alice"})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	done, err := m.Stop(1)
+	if err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if done.Status != StatusFailed {
+		t.Errorf("Expected status failed, got %s", done.Status)
+	}
+	if _, err := m.Get(rec.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected failed capture to be pruned, got %v", err)
+	}
+}
+
+// ============================================================================
+// Download Link Tests
+// ============================================================================
+
+func TestLinkResolves(t *testing.T) {
+	m := newTestManager(t, Options{}, 100)
+	rec := record(t, m, Entry{SongID: "s1", Title: "One", MartynKey: "alice"}, 1)
+
+	link, err := m.Link(rec.ID)
+	if err != nil {
+		t.Fatalf("Link failed: %v", err)
+	}
+	if link.URL != DownloadPath+link.Token || len(link.Token) != 32 {
+		t.Errorf("Unexpected link %+v", link)
+	}
+
+	got, path, err := m.Resolve(link.Token)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if got.ID != rec.ID || filepath.Base(path) != rec.FileName {
+		t.Errorf("Expected recording %d at %s, got %d at %s", rec.ID, rec.FileName, got.ID, path)
+	}
+
+	if _, _, err := m.Resolve("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for unknown token, got %v", err)
+	}
+}
+
+func TestLinkExpires(t *testing.T) {
+	m := newTestManager(t, Options{LinkTTL: 20 * time.Millisecond}, 100)
+	rec := record(t, m, Entry{SongID: "s1", Title: "One", MartynKey: "alice"}, 1)
+
+	link, err := m.Link(rec.ID)
+	if err != nil {
+		t.Fatalf("Link failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, _, err := m.Resolve(link.Token); !errors.Is(err, ErrLinkExpired) {
+		t.Errorf("Expected ErrLinkExpired, got %v", err)
+	}
+}
+
+// ============================================================================
+// Retention Tests
+// ============================================================================
+
+func TestPruneEnforcesSizeCap(t *testing.T) {
+	m := newTestManager(t, Options{MaxBytes: 2500}, 1000)
+
+	var recs []*Recording
+	for _, title := range []string{"One", "Two", "Three"} {
+		recs = append(recs, record(t, m, Entry{SongID: title, Title: title, MartynKey: "alice"}, 1))
+	}
+
+	all, _ := m.List()
+	if len(all) != 2 {
+		t.Fatalf("Expected 2 recordings under the cap, got %d", len(all))
+	}
+	if _, err := m.Get(recs[0].ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected oldest recording to be deleted, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(m.opts.Dir, recs[0].FileName)); !os.IsNotExist(err) {
+		t.Errorf("Expected oldest file to be removed, got %v", err)
+	}
+}
+
+func TestPruneEnforcesMaxAge(t *testing.T) {
+	m := newTestManager(t, Options{}, 100)
+	old := record(t, m, Entry{SongID: "s1", Title: "Old", MartynKey: "alice"}, 1)
+	fresh := record(t, m, Entry{SongID: "s2", Title: "Fresh", MartynKey: "alice"}, 2)
+
+	m.db.Exec(`UPDATE recordings SET started_at = ? WHERE id = ?`, time.Now().Add(-48*time.Hour), old.ID)
+	m.opts.MaxAge = 24 * time.Hour
+	if err := m.Prune(); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+
+	if _, err := m.Get(old.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected old recording to be deleted, got %v", err)
+	}
+	if _, err := m.Get(fresh.ID); err != nil {
+		t.Errorf("Expected fresh recording to be kept, got %v", err)
+	}
+}
@@ -3,21 +3,44 @@ package session
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
 	"songmartyn/internal/avatar"
+	"songmartyn/internal/metrics"
 	"songmartyn/internal/names"
 	"songmartyn/pkg/models"
 )
 
+// SchemaVersion is the layout of sessions.db, stored in its user_version so restores can
+// tell a backup from a newer SongMartyn apart from one the migrations can upgrade
+const SchemaVersion = 2
+
+// Errors returned by the saved avatar gallery
+var (
+	ErrAvatarNotFound    = errors.New("saved avatar not found")
+	ErrAvatarName        = errors.New("avatar name is required")
+	ErrAvatarGalleryFull = fmt.Errorf("you can save up to %d avatars", MaxSavedAvatars)
+)
+
+// Saved avatar gallery limits
+const (
+	MaxSavedAvatars     = 12
+	MaxAvatarNameLength = 32
+)
+
 // Manager handles session persistence (The Martyn Handshake)
 type Manager struct {
 	db       *sql.DB
 	sessions map[string]*models.Session // In-memory cache
 	mu       sync.RWMutex
+
+	observeQuery metrics.QueryObserver
 }
 
 // NewManager creates a new session manager with SQLite persistence
@@ -55,6 +78,10 @@ func NewManager(dbPath string) (*Manager, error) {
 	db.Exec(`ALTER TABLE sessions ADD COLUMN avatar_config TEXT DEFAULT ''`)
 	db.Exec(`ALTER TABLE sessions ADD COLUMN name_locked INTEGER DEFAULT 0`)
 	db.Exec(`ALTER TABLE sessions ADD COLUMN favorites TEXT DEFAULT '[]'`)
+	db.Exec(`ALTER TABLE sessions ADD COLUMN mic_preset TEXT DEFAULT ''`)
+	db.Exec(`ALTER TABLE sessions ADD COLUMN record_performances INTEGER DEFAULT 0`)
+	db.Exec(`ALTER TABLE sessions ADD COLUMN vocal_gain REAL DEFAULT 0`)
+	db.Exec(`ALTER TABLE sessions ADD COLUMN saved_avatars TEXT DEFAULT '[]'`)
 
 	// Create blocked_users table
 	_, err = db.Exec(`
@@ -68,6 +95,9 @@ func NewManager(dbPath string) (*Manager, error) {
 	if err != nil {
 		return nil, err
 	}
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", SchemaVersion)); err != nil {
+		return nil, err
+	}
 
 	m := &Manager{
 		db:       db,
@@ -84,13 +114,16 @@ func NewManager(dbPath string) (*Manager, error) {
 
 // loadSessions loads all sessions from SQLite into memory
 func (m *Manager) loadSessions() error {
+	defer m.observeQuery.Time("load")()
 	rows, err := m.db.Query(`
 		SELECT martyn_key, display_name, vocal_assist, search_history,
 		       current_song_id, connected_at, last_seen_at,
 		       COALESCE(ip_address, ''), COALESCE(device_name, ''),
 		       COALESCE(user_agent, ''), COALESCE(is_admin, 0),
 		       COALESCE(avatar_config, ''), COALESCE(name_locked, 0),
-		       COALESCE(favorites, '[]')
+		       COALESCE(favorites, '[]'), COALESCE(mic_preset, ''),
+		       COALESCE(record_performances, 0), COALESCE(vocal_gain, 0),
+		       COALESCE(saved_avatars, '[]')
 		FROM sessions
 	`)
 	if err != nil {
@@ -103,9 +136,10 @@ func (m *Manager) loadSessions() error {
 		var searchHistoryJSON string
 		var currentSongID sql.NullString
 		var connectedAt, lastSeenAt string
-		var isAdmin, nameLocked int
+		var isAdmin, nameLocked, recordPerformances int
 		var avatarConfigJSON string
 		var favoritesJSON string
+		var savedAvatarsJSON string
 
 		err := rows.Scan(
 			&session.MartynKey,
@@ -122,6 +156,10 @@ func (m *Manager) loadSessions() error {
 			&avatarConfigJSON,
 			&nameLocked,
 			&favoritesJSON,
+			&session.MicPreset,
+			&recordPerformances,
+			&session.VocalGain,
+			&savedAvatarsJSON,
 		)
 		if err != nil {
 			continue
@@ -129,6 +167,7 @@ func (m *Manager) loadSessions() error {
 
 		json.Unmarshal([]byte(searchHistoryJSON), &session.SearchHistory)
 		json.Unmarshal([]byte(favoritesJSON), &session.Favorites)
+		json.Unmarshal([]byte(savedAvatarsJSON), &session.SavedAvatars)
 		if currentSongID.Valid {
 			session.CurrentSongID = currentSongID.String
 		}
@@ -136,6 +175,7 @@ func (m *Manager) loadSessions() error {
 		session.LastSeenAt, _ = time.Parse(time.RFC3339, lastSeenAt)
 		session.IsAdmin = isAdmin == 1
 		session.NameLocked = nameLocked == 1
+		session.RecordPerformances = recordPerformances == 1
 
 		// Load avatar config from JSON
 		if avatarConfigJSON != "" {
@@ -242,6 +282,21 @@ func (m *Manager) Get(martynKey string) *models.Session {
 	return m.sessions[martynKey]
 }
 
+// GetByPublicID retrieves a session by the PublicID other singers know it by
+func (m *Manager) GetByPublicID(publicID string) *models.Session {
+	if publicID == "" {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for key, session := range m.sessions {
+		if models.PublicID(key) == publicID {
+			return session
+		}
+	}
+	return nil
+}
+
 // Update updates a session's properties
 func (m *Manager) Update(session *models.Session) error {
 	m.mu.Lock()
@@ -267,6 +322,55 @@ func (m *Manager) UpdateVocalAssist(martynKey string, level models.VocalAssistLe
 	return m.saveSession(session)
 }
 
+// UpdateVocalGain sets a session's continuous vocal assist level (0-1) and switches it to CUSTOM
+// unless the singer is in AUTO mode, where the gain is the starting level
+func (m *Manager) UpdateVocalGain(martynKey string, gain float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[martynKey]
+	if !ok {
+		return nil
+	}
+
+	session.VocalGain = models.ClampVocalGain(gain)
+	if session.VocalAssist != models.VocalAuto {
+		session.VocalAssist = models.VocalCustom
+	}
+	session.LastSeenAt = time.Now()
+	return m.saveSession(session)
+}
+
+// UpdateMicPreset updates a session's microphone effects preset
+func (m *Manager) UpdateMicPreset(martynKey string, preset models.MicPreset) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[martynKey]
+	if !ok {
+		return nil
+	}
+
+	session.MicPreset = preset
+	session.LastSeenAt = time.Now()
+	return m.saveSession(session)
+}
+
+// UpdateRecordPerformances sets whether a singer's performances are recorded
+func (m *Manager) UpdateRecordPerformances(martynKey string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[martynKey]
+	if !ok {
+		return nil
+	}
+
+	session.RecordPerformances = enabled
+	session.LastSeenAt = time.Now()
+	return m.saveSession(session)
+}
+
 // AddSearchHistory adds a search query to the session's history
 func (m *Manager) AddSearchHistory(martynKey, query string) error {
 	m.mu.Lock()
@@ -306,8 +410,10 @@ func (m *Manager) GetActiveSessions() []models.Session {
 
 // saveSession persists a session to SQLite
 func (m *Manager) saveSession(session *models.Session) error {
+	defer m.observeQuery.Time("save_session")()
 	searchHistoryJSON, _ := json.Marshal(session.SearchHistory)
 	favoritesJSON, _ := json.Marshal(session.Favorites)
+	savedAvatarsJSON, _ := json.Marshal(session.SavedAvatars)
 
 	isAdmin := 0
 	if session.IsAdmin {
@@ -319,6 +425,11 @@ func (m *Manager) saveSession(session *models.Session) error {
 		nameLocked = 1
 	}
 
+	recordPerformances := 0
+	if session.RecordPerformances {
+		recordPerformances = 1
+	}
+
 	// Serialize avatar config to JSON
 	avatarConfigJSON := ""
 	if session.AvatarConfig != nil {
@@ -331,8 +442,9 @@ func (m *Manager) saveSession(session *models.Session) error {
 		INSERT OR REPLACE INTO sessions
 		(martyn_key, display_name, vocal_assist, search_history,
 		 current_song_id, connected_at, last_seen_at,
-		 ip_address, device_name, user_agent, is_admin, avatar_config, name_locked, favorites)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ip_address, device_name, user_agent, is_admin, avatar_config, name_locked, favorites, mic_preset,
+		 record_performances, vocal_gain, saved_avatars)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		session.MartynKey,
 		session.DisplayName,
@@ -348,10 +460,19 @@ func (m *Manager) saveSession(session *models.Session) error {
 		avatarConfigJSON,
 		nameLocked,
 		string(favoritesJSON),
+		session.MicPreset,
+		recordPerformances,
+		session.VocalGain,
+		string(savedAvatarsJSON),
 	)
 	return err
 }
 
+// SetQueryObserver reports how long each database operation takes, for metrics
+func (m *Manager) SetQueryObserver(o metrics.QueryObserver) {
+	m.observeQuery = o
+}
+
 // Close closes the database connection
 func (m *Manager) Close() error {
 	return m.db.Close()
@@ -470,6 +591,95 @@ func (m *Manager) UpdateAvatarConfig(martynKey string, config *models.AvatarConf
 	return m.saveSession(session)
 }
 
+// SaveAvatar keeps config in a singer's gallery under name, replacing the saved avatar
+// with that name if there is one. It returns the gallery.
+func (m *Manager) SaveAvatar(martynKey, name string, config models.AvatarConfig) ([]models.SavedAvatar, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrAvatarName
+	}
+	if len([]rune(name)) > MaxAvatarNameLength {
+		name = string([]rune(name)[:MaxAvatarNameLength])
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[martynKey]
+	if !ok {
+		return nil, fmt.Errorf("session not found")
+	}
+
+	saved := models.SavedAvatar{Name: name, Config: config, SavedAt: time.Now()}
+	gallery := append([]models.SavedAvatar(nil), session.SavedAvatars...)
+	if i := findSavedAvatar(gallery, name); i >= 0 {
+		gallery[i] = saved
+	} else if len(gallery) >= MaxSavedAvatars {
+		return nil, ErrAvatarGalleryFull
+	} else {
+		gallery = append(gallery, saved)
+	}
+	session.SavedAvatars = gallery
+	session.LastSeenAt = time.Now()
+	return gallery, m.saveSession(session)
+}
+
+// UseSavedAvatar switches a singer to one of their saved avatars and returns it
+func (m *Manager) UseSavedAvatar(martynKey, name string) (*models.AvatarConfig, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[martynKey]
+	if !ok {
+		return nil, fmt.Errorf("session not found")
+	}
+	i := findSavedAvatar(session.SavedAvatars, name)
+	if i < 0 {
+		return nil, ErrAvatarNotFound
+	}
+
+	config := session.SavedAvatars[i].Config
+	if config.Colors != nil {
+		colors := *config.Colors
+		config.Colors = &colors
+	}
+	session.AvatarConfig = &config
+	session.LastSeenAt = time.Now()
+	return &config, m.saveSession(session)
+}
+
+// DeleteSavedAvatar removes an avatar from a singer's gallery
+// The avatar they're using stays, even if it was the one saved.
+func (m *Manager) DeleteSavedAvatar(martynKey, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[martynKey]
+	if !ok {
+		return fmt.Errorf("session not found")
+	}
+	i := findSavedAvatar(session.SavedAvatars, name)
+	if i < 0 {
+		return ErrAvatarNotFound
+	}
+
+	gallery := append([]models.SavedAvatar(nil), session.SavedAvatars[:i]...)
+	session.SavedAvatars = append(gallery, session.SavedAvatars[i+1:]...)
+	session.LastSeenAt = time.Now()
+	return m.saveSession(session)
+}
+
+// findSavedAvatar returns the index of the saved avatar called name (ignoring case), or -1
+func findSavedAvatar(gallery []models.SavedAvatar, name string) int {
+	name = strings.TrimSpace(name)
+	for i, saved := range gallery {
+		if strings.EqualFold(saved.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
 // UpdateDeviceInfo updates a session's device information
 func (m *Manager) UpdateDeviceInfo(martynKey, ipAddress, userAgent, deviceName string) error {
 	m.mu.Lock()
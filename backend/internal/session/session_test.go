@@ -1,9 +1,12 @@
 package session
 
 import (
+	"fmt"
 	"os"
 	"testing"
 	"time"
+
+	"songmartyn/pkg/models"
 )
 
 func TestNewManager(t *testing.T) {
@@ -444,6 +447,9 @@ func TestPersistence(t *testing.T) {
 		session1.DisplayName = "PersistentName"
 		manager1.SetAdmin(savedKey, true)
 		manager1.SetNameLocked(savedKey, true)
+		manager1.UpdateMicPreset(savedKey, models.MicPresetHall)
+		manager1.UpdateRecordPerformances(savedKey, true)
+		manager1.UpdateVocalGain(savedKey, 0.35)
 	}()
 
 	// Reopen manager
@@ -467,6 +473,18 @@ func TestPersistence(t *testing.T) {
 	if !session2.IsAdmin {
 		t.Error("IsAdmin should be persisted as true")
 	}
+
+	if session2.MicPreset != models.MicPresetHall {
+		t.Errorf("Expected MicPreset 'hall', got '%s'", session2.MicPreset)
+	}
+
+	if !session2.RecordPerformances {
+		t.Error("RecordPerformances should be persisted as true")
+	}
+
+	if session2.VocalAssist != models.VocalCustom || session2.VocalGain != 0.35 {
+		t.Errorf("Expected CUSTOM vocal assist at 0.35, got %s at %v", session2.VocalAssist, session2.VocalGain)
+	}
 }
 
 func TestGetAllSessions(t *testing.T) {
@@ -560,3 +578,80 @@ func TestFlushSessions(t *testing.T) {
 		t.Errorf("Expected 0 sessions after flush, got %d", manager.GetSessionCount())
 	}
 }
+
+func TestSavedAvatarGallery(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "session_test_*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpFile.Name())
+	tmpFile.Close()
+
+	manager, err := NewManager(tmpFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := manager.GetOrCreate("", "TestUser")
+
+	party := models.AvatarConfig{Env: 1, Clo: 2, Head: 3, Mouth: 4, Eyes: 5, Top: 6, Colors: &models.AvatarColors{Top: "#FF00FF"}}
+	if _, err := manager.SaveAvatar(session.MartynKey, "  Party  ", party); err != nil {
+		t.Fatalf("SaveAvatar failed: %v", err)
+	}
+	if _, err := manager.SaveAvatar(session.MartynKey, "Work", models.AvatarConfig{Top: 9}); err != nil {
+		t.Fatalf("SaveAvatar failed: %v", err)
+	}
+	// Saving under an existing name replaces it
+	party.Mouth = 7
+	gallery, err := manager.SaveAvatar(session.MartynKey, "party", party)
+	if err != nil {
+		t.Fatalf("SaveAvatar failed: %v", err)
+	}
+	if len(gallery) != 2 || gallery[0].Name != "party" || gallery[0].Config.Mouth != 7 {
+		t.Fatalf("Expected party replaced in place, got %+v", gallery)
+	}
+	if _, err := manager.SaveAvatar(session.MartynKey, " ", party); err != ErrAvatarName {
+		t.Errorf("Expected ErrAvatarName, got %v", err)
+	}
+
+	config, err := manager.UseSavedAvatar(session.MartynKey, "PARTY")
+	if err != nil {
+		t.Fatalf("UseSavedAvatar failed: %v", err)
+	}
+	if session.AvatarConfig.Mouth != 7 || config.Colors.Top != "#FF00FF" {
+		t.Errorf("Expected the party avatar in use, got %+v", session.AvatarConfig)
+	}
+	// The avatar in use is a copy, so changing it leaves the saved one alone
+	session.AvatarConfig.Colors.Top = "#000000"
+	if session.SavedAvatars[0].Config.Colors.Top != "#FF00FF" {
+		t.Error("Expected the saved avatar to be unchanged")
+	}
+	if _, err := manager.UseSavedAvatar(session.MartynKey, "Holiday"); err != ErrAvatarNotFound {
+		t.Errorf("Expected ErrAvatarNotFound, got %v", err)
+	}
+
+	if err := manager.DeleteSavedAvatar(session.MartynKey, "work"); err != nil {
+		t.Fatalf("DeleteSavedAvatar failed: %v", err)
+	}
+
+	// The gallery survives a restart
+	manager.Close()
+	manager, err = NewManager(tmpFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+	reloaded := manager.Get(session.MartynKey)
+	if len(reloaded.SavedAvatars) != 1 || reloaded.SavedAvatars[0].Name != "party" || reloaded.SavedAvatars[0].Config.Mouth != 7 {
+		t.Errorf("Expected the party avatar after reload, got %+v", reloaded.SavedAvatars)
+	}
+
+	// The gallery has room for MaxSavedAvatars
+	for i := 1; i < MaxSavedAvatars; i++ {
+		if _, err := manager.SaveAvatar(session.MartynKey, fmt.Sprintf("Look %d", i), party); err != nil {
+			t.Fatalf("SaveAvatar %d failed: %v", i, err)
+		}
+	}
+	if _, err := manager.SaveAvatar(session.MartynKey, "One too many", party); err != ErrAvatarGalleryFull {
+		t.Errorf("Expected ErrAvatarGalleryFull, got %v", err)
+	}
+}
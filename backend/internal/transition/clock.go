@@ -0,0 +1,118 @@
+package transition
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and runs functions later
+// SystemClock is the real one; tests drive a FakeClock by hand
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, fn func()) Timer
+}
+
+// Timer is a pending AfterFunc call
+type Timer interface {
+	Stop() bool
+}
+
+// SystemClock is the wall clock
+type SystemClock struct{}
+
+// Now returns the current time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// AfterFunc calls fn in its own goroutine after d
+func (SystemClock) AfterFunc(d time.Duration, fn func()) Timer {
+	return time.AfterFunc(d, fn)
+}
+
+// FakeClock only moves when Advance is called; timers fire inside Advance
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    int
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	at    time.Time
+	seq   int // Breaks ties so timers due together fire in the order they were set
+	fn    func()
+}
+
+var _ Clock = (*FakeClock)(nil)
+
+// NewFakeClock creates a fake clock
+func NewFakeClock() *FakeClock {
+	return &FakeClock{now: time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)}
+}
+
+// Now returns the fake time
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc schedules fn to run once the clock has advanced by d
+func (c *FakeClock) AfterFunc(d time.Duration, fn func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	t := &fakeTimer{clock: c, at: c.now.Add(d), seq: c.seq, fn: fn}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, firing due timers in order
+// Timers set by those functions fire too if they fall within d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		sort.Slice(c.timers, func(i, j int) bool {
+			if c.timers[i].at.Equal(c.timers[j].at) {
+				return c.timers[i].seq < c.timers[j].seq
+			}
+			return c.timers[i].at.Before(c.timers[j].at)
+		})
+		if len(c.timers) == 0 || c.timers[0].at.After(end) {
+			break
+		}
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.at
+		c.mu.Unlock()
+		t.fn()
+		c.mu.Lock()
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+// Pending returns how many timers are waiting to fire
+func (c *FakeClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// Stop cancels the timer, reporting whether it was still pending
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
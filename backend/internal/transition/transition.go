@@ -0,0 +1,341 @@
+// Package transition schedules fades between songs, background music and the holding screen
+package transition
+
+import (
+	"sync"
+	"time"
+
+	"songmartyn/pkg/models"
+)
+
+// Scheduler timing
+const (
+	FadeStep      = 50 * time.Millisecond  // How often a fade updates the level
+	WatchInterval = 250 * time.Millisecond // How often a playing song is checked for its fade-out
+)
+
+// Silence trimming leaves a little silence so songs don't start or stop abruptly
+const (
+	MinSilence  = 1.0 // Seconds; shorter silences are left alone
+	TrimPadding = 0.3 // Seconds of silence kept before the audio starts and after it ends
+)
+
+// Settings configures transitions; a zero duration turns that transition off
+type Settings struct {
+	BGMCrossfade  time.Duration // BGM fades out while the next song fades in over the same time
+	SongFadeOut   time.Duration // Songs fade out over their last seconds
+	HoldingFadeIn time.Duration // The holding screen fades in from black
+	TrimSilence   bool          // Skip leading and trailing silence found by loudness analysis
+}
+
+// Target is the player being faded
+// Background music has its own level so it can fade out under a song fading in
+type Target interface {
+	SetFade(level float64) error
+	SetBGMFade(level float64) error
+	GetState() (models.PlayerState, error)
+}
+
+// Scheduler runs transitions on a clock by ramping the player's fade level (0-1)
+// Starting a fade or cut replaces the one in progress
+type Scheduler struct {
+	clock    Clock
+	target   Target
+	settings Settings
+
+	mu        sync.Mutex
+	level     float64
+	fadeGen   int   // Bumped to cancel the running fade
+	fadeTimer Timer // Next fade step
+	watchGen  int   // Bumped to cancel the end-of-song watch
+	watch     Timer // Next end-of-song check
+	fadingOut bool  // The end-of-song fade has started
+	holding   bool  // The holding screen has been faded in
+
+	bgmLevel float64
+	bgmGen   int   // Bumped to cancel the running BGM fade
+	bgmTimer Timer // Next BGM fade step
+}
+
+// NewScheduler creates a scheduler at full level
+func NewScheduler(clock Clock, target Target, settings Settings) *Scheduler {
+	return &Scheduler{clock: clock, target: target, settings: settings, level: 1, bgmLevel: 1}
+}
+
+// Settings returns the transition settings
+func (s *Scheduler) Settings() Settings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settings
+}
+
+// SetSettings changes the transition settings from the next transition on
+func (s *Scheduler) SetSettings(settings Settings) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings = settings
+}
+
+// Level returns the current fade level
+func (s *Scheduler) Level() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.level
+}
+
+// Cut cancels any fade and jumps to level
+func (s *Scheduler) Cut(level float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancelFadeLocked()
+	s.setLocked(level)
+}
+
+// Fade ramps the level to `to` over d and then calls done
+// done is not called if another fade or cut replaces this one first
+func (s *Scheduler) Fade(to float64, d time.Duration, done func()) {
+	s.mu.Lock()
+	s.cancelFadeLocked()
+	gen := s.fadeGen
+	from := s.level
+	start := s.clock.Now()
+	s.mu.Unlock()
+	s.step(gen, from, to, start, d, done)
+}
+
+// StartSong fades a song in over fadeIn (0 = full level at once)
+// and, with SongFadeOut set, watches for its end to fade it out
+func (s *Scheduler) StartSong(fadeIn time.Duration) {
+	s.mu.Lock()
+	s.stopWatchLocked()
+	s.holding = false
+	if fadeIn <= 0 {
+		s.cancelFadeLocked()
+		s.setLocked(1)
+	}
+	if s.settings.SongFadeOut > 0 {
+		s.fadingOut = false
+		s.startWatchLocked()
+	}
+	s.mu.Unlock()
+
+	if fadeIn > 0 {
+		s.Cut(0)
+		s.Fade(1, fadeIn, nil)
+	}
+}
+
+// ShowHolding fades the holding screen in from black the first time it's shown
+// Refreshing an already visible holding screen leaves the level alone
+func (s *Scheduler) ShowHolding() {
+	s.mu.Lock()
+	s.stopWatchLocked()
+	if s.holding {
+		s.mu.Unlock()
+		return
+	}
+	s.holding = true
+	d := s.settings.HoldingFadeIn
+	s.mu.Unlock()
+
+	if d > 0 {
+		s.Cut(0)
+		s.Fade(1, d, nil)
+		return
+	}
+	s.Cut(1)
+}
+
+// FadeOut fades whatever is playing out over d and then calls done
+func (s *Scheduler) FadeOut(d time.Duration, done func()) {
+	s.mu.Lock()
+	s.stopWatchLocked()
+	s.holding = false
+	s.mu.Unlock()
+	s.Fade(0, d, done)
+}
+
+// BGMLevel returns the current background music level
+func (s *Scheduler) BGMLevel() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bgmLevel
+}
+
+// CutBGM cancels any background music fade and jumps to level
+func (s *Scheduler) CutBGM(level float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancelBGMLocked()
+	s.setBGMLocked(level)
+}
+
+// FadeOutBGM fades the background music out over d and then calls done
+// It runs alongside the song level, so a song can fade in over the same time
+// done is not called if CutBGM or another BGM fade replaces this one first
+func (s *Scheduler) FadeOutBGM(d time.Duration, done func()) {
+	s.mu.Lock()
+	s.cancelBGMLocked()
+	gen := s.bgmGen
+	from := s.bgmLevel
+	start := s.clock.Now()
+	s.mu.Unlock()
+	s.bgmStep(gen, from, start, d, done)
+}
+
+// Stop cancels every pending transition
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancelFadeLocked()
+	s.cancelBGMLocked()
+	s.stopWatchLocked()
+}
+
+// step sets one point of a fade and schedules the next
+func (s *Scheduler) step(gen int, from, to float64, start time.Time, d time.Duration, done func()) {
+	s.mu.Lock()
+	if gen != s.fadeGen {
+		s.mu.Unlock()
+		return
+	}
+	elapsed := s.clock.Now().Sub(start)
+	finished := elapsed >= d
+	level := to
+	if !finished {
+		level = from + (to-from)*float64(elapsed)/float64(d)
+		s.fadeTimer = s.clock.AfterFunc(FadeStep, func() { s.step(gen, from, to, start, d, done) })
+	} else {
+		s.fadeTimer = nil
+	}
+	s.setLocked(level)
+	s.mu.Unlock()
+
+	if finished && done != nil {
+		done()
+	}
+}
+
+// bgmStep sets one point of a background music fade-out and schedules the next
+func (s *Scheduler) bgmStep(gen int, from float64, start time.Time, d time.Duration, done func()) {
+	s.mu.Lock()
+	if gen != s.bgmGen {
+		s.mu.Unlock()
+		return
+	}
+	elapsed := s.clock.Now().Sub(start)
+	finished := elapsed >= d
+	level := 0.0
+	if !finished {
+		level = from * (1 - float64(elapsed)/float64(d))
+		s.bgmTimer = s.clock.AfterFunc(FadeStep, func() { s.bgmStep(gen, from, start, d, done) })
+	} else {
+		s.bgmTimer = nil
+	}
+	s.setBGMLocked(level)
+	s.mu.Unlock()
+
+	if finished && done != nil {
+		done()
+	}
+}
+
+// checkEnd starts the fade-out once a song is within SongFadeOut of its end,
+// and undoes it if the song is seeked back out of that window
+func (s *Scheduler) checkEnd(gen int) {
+	state, err := s.target.GetState()
+
+	s.mu.Lock()
+	if gen != s.watchGen {
+		s.mu.Unlock()
+		return
+	}
+	var fadeOut, restore bool
+	var fadeFor time.Duration
+	if err == nil && state.Duration > 0 && state.Position > 0 {
+		remaining := time.Duration((state.Duration - state.Position) * float64(time.Second))
+		switch {
+		case !s.fadingOut && remaining <= s.settings.SongFadeOut:
+			s.fadingOut = true
+			fadeOut, fadeFor = true, remaining
+		case s.fadingOut && remaining > s.settings.SongFadeOut:
+			s.fadingOut = false
+			restore = true
+		}
+	}
+	s.watch = s.clock.AfterFunc(WatchInterval, func() { s.checkEnd(gen) })
+	s.mu.Unlock()
+
+	switch {
+	case fadeOut:
+		s.Fade(0, fadeFor, nil)
+	case restore:
+		s.Cut(1)
+	}
+}
+
+// setLocked applies a level to the player; caller holds mu
+func (s *Scheduler) setLocked(level float64) {
+	if level < 0 {
+		level = 0
+	} else if level > 1 {
+		level = 1
+	}
+	s.level = level
+	s.target.SetFade(level)
+}
+
+// setBGMLocked applies a background music level to the player; caller holds mu
+func (s *Scheduler) setBGMLocked(level float64) {
+	if level < 0 {
+		level = 0
+	} else if level > 1 {
+		level = 1
+	}
+	s.bgmLevel = level
+	s.target.SetBGMFade(level)
+}
+
+// cancelBGMLocked stops the running background music fade; caller holds mu
+func (s *Scheduler) cancelBGMLocked() {
+	s.bgmGen++
+	if s.bgmTimer != nil {
+		s.bgmTimer.Stop()
+		s.bgmTimer = nil
+	}
+}
+
+// cancelFadeLocked stops the running fade; caller holds mu
+func (s *Scheduler) cancelFadeLocked() {
+	s.fadeGen++
+	if s.fadeTimer != nil {
+		s.fadeTimer.Stop()
+		s.fadeTimer = nil
+	}
+}
+
+// startWatchLocked begins checking for the end of the song; caller holds mu
+func (s *Scheduler) startWatchLocked() {
+	gen := s.watchGen
+	s.watch = s.clock.AfterFunc(WatchInterval, func() { s.checkEnd(gen) })
+}
+
+// stopWatchLocked stops checking for the end of the song; caller holds mu
+func (s *Scheduler) stopWatchLocked() {
+	s.watchGen++
+	if s.watch != nil {
+		s.watch.Stop()
+		s.watch = nil
+	}
+}
+
+// Trim returns how much of a song's leading and trailing silence to cut
+func Trim(leading, trailing float64) (lead, tail float64) {
+	if leading >= MinSilence {
+		lead = leading - TrimPadding
+	}
+	if trailing >= MinSilence {
+		tail = trailing - TrimPadding
+	}
+	return lead, tail
+}
@@ -0,0 +1,264 @@
+package transition
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"songmartyn/pkg/models"
+)
+
+// fakeTarget records fade levels and reports a scripted playback state
+type fakeTarget struct {
+	mu        sync.Mutex
+	levels    []float64
+	bgmLevels []float64
+	state     models.PlayerState
+}
+
+func (f *fakeTarget) SetFade(level float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.levels = append(f.levels, level)
+	return nil
+}
+
+func (f *fakeTarget) SetBGMFade(level float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.bgmLevels = append(f.bgmLevels, level)
+	return nil
+}
+
+func (f *fakeTarget) GetState() (models.PlayerState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state, nil
+}
+
+func (f *fakeTarget) setPosition(position, duration float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state.Position = position
+	f.state.Duration = duration
+}
+
+func (f *fakeTarget) last() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.levels) == 0 {
+		return -1
+	}
+	return f.levels[len(f.levels)-1]
+}
+
+func newTestScheduler(settings Settings) (*Scheduler, *FakeClock, *fakeTarget) {
+	clock := NewFakeClock()
+	target := &fakeTarget{}
+	return NewScheduler(clock, target, settings), clock, target
+}
+
+func expectLevel(t *testing.T, what string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("Expected %s level %.2f, got %.2f", what, want, got)
+	}
+}
+
+// ============================================================================
+// Fake Clock Tests
+// ============================================================================
+
+func TestFakeClockFiresInOrder(t *testing.T) {
+	clock := NewFakeClock()
+	start := clock.Now()
+	var fired []string
+
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, "b") })
+	clock.AfterFunc(time.Second, func() {
+		fired = append(fired, "a")
+		// Timers set while firing still fire within the same Advance
+		clock.AfterFunc(500*time.Millisecond, func() { fired = append(fired, "a2") })
+	})
+	stopped := clock.AfterFunc(time.Second, func() { fired = append(fired, "stopped") })
+	if !stopped.Stop() {
+		t.Error("Expected Stop to cancel a pending timer")
+	}
+
+	clock.Advance(1600 * time.Millisecond)
+	if len(fired) != 2 || fired[0] != "a" || fired[1] != "a2" {
+		t.Errorf("Expected [a a2], got %v", fired)
+	}
+	if got := clock.Now().Sub(start); got != 1600*time.Millisecond {
+		t.Errorf("Expected clock to move 1.6s, got %v", got)
+	}
+
+	clock.Advance(time.Second)
+	if len(fired) != 3 || clock.Pending() != 0 {
+		t.Errorf("Expected b to fire and nothing pending, got %v (%d pending)", fired, clock.Pending())
+	}
+}
+
+// ============================================================================
+// Fade Tests
+// ============================================================================
+
+func TestFadeRampsAndCallsDone(t *testing.T) {
+	s, clock, target := newTestScheduler(Settings{})
+	done := false
+
+	s.Fade(0, time.Second, func() { done = true })
+	clock.Advance(500 * time.Millisecond)
+	expectLevel(t, "halfway", s.Level(), 0.5)
+	if done {
+		t.Error("Expected done only at the end of the fade")
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	expectLevel(t, "final", target.last(), 0)
+	if !done {
+		t.Error("Expected done after the fade")
+	}
+	if clock.Pending() != 0 {
+		t.Errorf("Expected no steps left, got %d", clock.Pending())
+	}
+}
+
+func TestCutCancelsFade(t *testing.T) {
+	s, clock, target := newTestScheduler(Settings{})
+	done := false
+
+	s.Fade(0, time.Second, func() { done = true })
+	clock.Advance(300 * time.Millisecond)
+	s.Cut(1)
+	clock.Advance(2 * time.Second)
+
+	expectLevel(t, "after cut", target.last(), 1)
+	if done {
+		t.Error("Expected a cancelled fade not to call done")
+	}
+}
+
+// ============================================================================
+// Transition Tests
+// ============================================================================
+
+func TestStartSongFadesIn(t *testing.T) {
+	s, clock, target := newTestScheduler(Settings{})
+
+	s.StartSong(time.Second)
+	expectLevel(t, "start", target.levels[0], 0)
+	clock.Advance(time.Second)
+	expectLevel(t, "after fade-in", target.last(), 1)
+
+	// No fade-in jumps straight to full level
+	s.Cut(0.2)
+	s.StartSong(0)
+	expectLevel(t, "without fade-in", target.last(), 1)
+}
+
+func TestSongFadesOutBeforeItsEnd(t *testing.T) {
+	s, clock, target := newTestScheduler(Settings{SongFadeOut: 4 * time.Second})
+	s.StartSong(0)
+
+	target.setPosition(100, 200)
+	clock.Advance(time.Second)
+	expectLevel(t, "mid-song", s.Level(), 1)
+
+	// Four seconds from the end the fade-out starts and reaches silence at the end
+	target.setPosition(196, 200)
+	clock.Advance(WatchInterval)
+	clock.Advance(2 * time.Second)
+	expectLevel(t, "halfway through the fade-out", s.Level(), 0.5)
+	clock.Advance(2 * time.Second)
+	expectLevel(t, "at the end", s.Level(), 0)
+
+	// Seeking back restores the level
+	target.setPosition(50, 200)
+	clock.Advance(WatchInterval)
+	expectLevel(t, "after seeking back", s.Level(), 1)
+}
+
+func TestHoldingFadesInOnce(t *testing.T) {
+	s, clock, target := newTestScheduler(Settings{HoldingFadeIn: time.Second, SongFadeOut: 2 * time.Second})
+	s.StartSong(0)
+
+	s.ShowHolding()
+	expectLevel(t, "holding start", s.Level(), 0)
+	if clock.Pending() != 1 {
+		t.Errorf("Expected only the fade step pending (watch stopped), got %d", clock.Pending())
+	}
+	clock.Advance(time.Second)
+	expectLevel(t, "holding shown", s.Level(), 1)
+
+	// Refreshing the holding screen doesn't fade it again
+	fades := len(target.levels)
+	s.ShowHolding()
+	if len(target.levels) != fades {
+		t.Errorf("Expected no new fade on refresh, got levels %v", target.levels[fades:])
+	}
+}
+
+func TestBGMCrossfadeOverlaps(t *testing.T) {
+	s, clock, _ := newTestScheduler(Settings{BGMCrossfade: 2 * time.Second})
+	d := s.Settings().BGMCrossfade
+	stopped := false
+
+	s.ShowHolding()
+	s.FadeOutBGM(d, func() { stopped = true })
+	s.StartSong(d)
+	expectLevel(t, "BGM at the start", s.BGMLevel(), 1)
+	expectLevel(t, "song at the start", s.Level(), 0)
+
+	// Both fades run at once: the BGM is on its way out while the song comes in
+	clock.Advance(d / 2)
+	expectLevel(t, "BGM halfway", s.BGMLevel(), 0.5)
+	expectLevel(t, "song halfway", s.Level(), 0.5)
+	if stopped {
+		t.Fatal("Expected the BGM to keep playing until its fade ends")
+	}
+
+	clock.Advance(d / 2)
+	expectLevel(t, "BGM faded out", s.BGMLevel(), 0)
+	expectLevel(t, "song faded in", s.Level(), 1)
+	if !stopped {
+		t.Error("Expected done once the BGM fade ends")
+	}
+}
+
+func TestCutBGMCancelsFade(t *testing.T) {
+	s, clock, target := newTestScheduler(Settings{})
+	stopped := false
+
+	s.FadeOutBGM(time.Second, func() { stopped = true })
+	clock.Advance(500 * time.Millisecond)
+	s.CutBGM(1)
+	clock.Advance(time.Second)
+
+	if stopped {
+		t.Error("Expected a cut to cancel the fade before it finished")
+	}
+	expectLevel(t, "BGM after cut", s.BGMLevel(), 1)
+	if got := target.bgmLevels[len(target.bgmLevels)-1]; got != 1 {
+		t.Errorf("Expected the player left at full BGM level, got %.2f", got)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	tests := []struct {
+		name               string
+		leading, trailing  float64
+		wantLead, wantTail float64
+	}{
+		{"no silence", 0, 0, 0, 0},
+		{"short silences kept", 0.8, 0.5, 0, 0},
+		{"long silences trimmed with padding", 3, 10.3, 2.7, 10},
+	}
+	for _, tt := range tests {
+		lead, tail := Trim(tt.leading, tt.trailing)
+		if math.Abs(lead-tt.wantLead) > 1e-9 || math.Abs(tail-tt.wantTail) > 1e-9 {
+			t.Errorf("%s: expected %.1f / %.1f, got %.1f / %.1f", tt.name, tt.wantLead, tt.wantTail, lead, tail)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Package vocalassist drives the vocal stem level from the singer's mic level
+package vocalassist
+
+import (
+	"time"
+
+	"songmartyn/pkg/models"
+)
+
+// Defaults for Auto
+const (
+	DefaultQuietDB   = -45.0 // Mic RMS below this counts as not singing
+	DefaultSingingDB = -32.0 // Mic RMS above this counts as singing
+	DefaultMaxGain   = 0.80  // Full vocal lead, as the HIGH level
+	DefaultHold      = 1500 * time.Millisecond
+	DefaultRiseTime  = 2 * time.Second
+	DefaultFallTime  = 500 * time.Millisecond
+)
+
+// Auto raises the vocal stem while the singer is quiet and lowers it while they sing.
+// Levels between QuietDB and SingingDB hold the current gain, so breaths and
+// soft passages don't make the guide vocal pump.
+type Auto struct {
+	MinGain   float64       // Gain while the singer is singing (0-1)
+	MaxGain   float64       // Gain once the singer has gone quiet (0-1)
+	QuietDB   float64       // Level at or below which the singer is quiet
+	SingingDB float64       // Level at or above which the singer is singing
+	Hold      time.Duration // How long the singer must be quiet before the vocal comes in
+	RiseTime  time.Duration // Time to go from MinGain to MaxGain
+	FallTime  time.Duration // Time to go from MaxGain to MinGain
+
+	gain     float64
+	quietFor time.Duration
+}
+
+// NewAuto creates an auto assist with default thresholds starting at gain
+func NewAuto(gain float64) *Auto {
+	a := &Auto{
+		MaxGain:   DefaultMaxGain,
+		QuietDB:   DefaultQuietDB,
+		SingingDB: DefaultSingingDB,
+		Hold:      DefaultHold,
+		RiseTime:  DefaultRiseTime,
+		FallTime:  DefaultFallTime,
+	}
+	a.Reset(gain)
+	return a
+}
+
+// Reset starts over from gain, e.g. for a new song
+func (a *Auto) Reset(gain float64) {
+	a.gain = a.clamp(gain)
+	a.quietFor = 0
+}
+
+// Gain returns the current vocal gain (0-1)
+func (a *Auto) Gain() float64 {
+	return a.gain
+}
+
+// Update feeds a mic level reading taken dt after the previous one and returns the new gain
+func (a *Auto) Update(levelDB float64, dt time.Duration) float64 {
+	span := a.MaxGain - a.MinGain
+	switch {
+	case levelDB >= a.SingingDB:
+		a.quietFor = 0
+		a.gain -= ramp(span, dt, a.FallTime)
+	case levelDB <= a.QuietDB:
+		a.quietFor += dt
+		if a.quietFor >= a.Hold {
+			a.gain += ramp(span, dt, a.RiseTime)
+		}
+	default:
+		a.quietFor = 0
+	}
+	a.gain = a.clamp(a.gain)
+	return a.gain
+}
+
+// ramp returns how far a full-span move of length total gets in dt
+func ramp(span float64, dt, total time.Duration) float64 {
+	if total <= 0 {
+		return span
+	}
+	return span * float64(dt) / float64(total)
+}
+
+// clamp limits gain to MinGain-MaxGain
+func (a *Auto) clamp(gain float64) float64 {
+	gain = models.ClampVocalGain(gain)
+	if gain < a.MinGain {
+		return a.MinGain
+	}
+	if gain > a.MaxGain {
+		return a.MaxGain
+	}
+	return gain
+}
@@ -0,0 +1,78 @@
+package vocalassist
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+const tick = 100 * time.Millisecond
+
+// feed sends the same level for d in tick steps and returns the final gain
+func feed(a *Auto, levelDB float64, d time.Duration) float64 {
+	for elapsed := time.Duration(0); elapsed < d; elapsed += tick {
+		a.Update(levelDB, tick)
+	}
+	return a.Gain()
+}
+
+func expectGain(t *testing.T, what string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("Expected %s gain %.3f, got %.3f", what, want, got)
+	}
+}
+
+// ============================================================================
+// Auto Assist Tests
+// ============================================================================
+
+func TestQuietSingerGetsVocalAfterHold(t *testing.T) {
+	a := NewAuto(0)
+
+	// Short pauses (breaths, gaps between lines) don't bring the vocal in
+	expectGain(t, "during hold", feed(a, -60, DefaultHold-tick), 0)
+
+	// Then it rises to the maximum over RiseTime
+	feed(a, -60, tick)
+	feed(a, -60, DefaultRiseTime/2)
+	if g := a.Gain(); g <= 0.3 || g >= 0.5 {
+		t.Errorf("Expected roughly half gain midway through the rise, got %.3f", g)
+	}
+	expectGain(t, "after rise", feed(a, -60, DefaultRiseTime), DefaultMaxGain)
+}
+
+func TestSingingLowersVocal(t *testing.T) {
+	a := NewAuto(DefaultMaxGain)
+
+	feed(a, -20, DefaultFallTime/2)
+	if g := a.Gain(); g <= 0.3 || g >= 0.5 {
+		t.Errorf("Expected roughly half gain midway through the fall, got %.3f", g)
+	}
+	expectGain(t, "while singing", feed(a, -20, DefaultFallTime), 0)
+}
+
+func TestBetweenThresholdsHoldsGain(t *testing.T) {
+	a := NewAuto(0.45)
+
+	expectGain(t, "soft singing", feed(a, -38, 10*time.Second), 0.45)
+
+	// A soft passage also restarts the quiet hold
+	feed(a, -60, DefaultHold-tick)
+	a.Update(-38, tick)
+	expectGain(t, "after interrupted hold", feed(a, -60, DefaultHold-tick), 0.45)
+}
+
+func TestGainStaysInRange(t *testing.T) {
+	a := NewAuto(2)
+	expectGain(t, "clamped start", a.Gain(), DefaultMaxGain)
+
+	a.MinGain = 0.1
+	a.Reset(0)
+	expectGain(t, "clamped to minimum", a.Gain(), 0.1)
+	expectGain(t, "singing floor", feed(a, -10, time.Second), 0.1)
+
+	// A long gap between readings can't overshoot
+	a.Update(-80, time.Minute)
+	expectGain(t, "after long gap", a.Update(-80, time.Minute), DefaultMaxGain)
+}
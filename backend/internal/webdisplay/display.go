@@ -0,0 +1,881 @@
+package webdisplay
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"songmartyn/internal/logging"
+	"songmartyn/internal/mpv"
+	"songmartyn/pkg/models"
+)
+
+// logger is the "webdisplay" subsystem
+var logger = logging.For("webdisplay")
+
+// Paths the display is served under
+const (
+	PagePath  = "/display"
+	WSPath    = "/display/ws"
+	MediaPath = "/display/media/"
+)
+
+// maxMediaTokens limits how many media files stay reachable at once
+const maxMediaTokens = 32
+
+// Command is sent to display pages over the display websocket
+type Command struct {
+	Type       string        `json:"type"`                  // load, image, bgm, bgm_image, bgm_fade, bgm_stop, play, pause, seek, stop, volume, tempo, gain, vocal_gain, fade, overlay, ticker, hide_ticker, reaction
+	ID         int64         `json:"id,omitempty"`          // Media load ID; reports echo it back
+	Kind       string        `json:"kind,omitempty"`        // load: video, cdg or stems
+	URL        string        `json:"url,omitempty"`         // Media URL (video, image, CDG file or instrumental stem); reaction: PNG strip of frames
+	AudioURL   string        `json:"audio_url,omitempty"`   // CDG or BGM audio
+	VideoURL   string        `json:"video_url,omitempty"`   // image: video looping muted under the image
+	VocalURL   string        `json:"vocal_url,omitempty"`   // Vocal stem mixed in at VocalGain
+	VocalGain  float64       `json:"vocal_gain,omitempty"`  // 0-1
+	Value      float64       `json:"value,omitempty"`       // seek position, volume, tempo, gain (dB), vocal gain, fade or BGM level (0-1) or reaction position (0-1)
+	TrimStart  float64       `json:"trim_start,omitempty"`  // load: seconds of leading silence to skip
+	TrimEnd    float64       `json:"trim_end,omitempty"`    // load: seconds of trailing silence to skip
+	Position   float64       `json:"position,omitempty"`    // Resume position when replaying to a new page
+	Paused     bool          `json:"paused,omitempty"`      // Start paused when replaying to a new page
+	Text       string        `json:"text,omitempty"`        // Overlay text or reaction label
+	DurationMs int           `json:"duration_ms,omitempty"` // Overlay duration, fade length or reaction float time
+	Frames     int           `json:"frames,omitempty"`      // reaction: frames in the strip
+	FPS        float64       `json:"fps,omitempty"`         // reaction: frame rate
+	Entries    []TickerEntry `json:"entries,omitempty"`     // Ticker entries
+}
+
+// TickerEntry is one up-next ticker item
+type TickerEntry struct {
+	Singer string `json:"singer"`
+	Song   string `json:"song"`
+}
+
+// Report is sent by display pages: periodic state, end of media, or a media error
+type Report struct {
+	Type     string  `json:"type"` // state, ended, error
+	ID       int64   `json:"id"`
+	Position float64 `json:"position"`
+	Duration float64 `json:"duration"`
+	Paused   bool    `json:"paused"`
+	Message  string  `json:"message,omitempty"`
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		// Smart TV browsers may send no origin or "null"; anything else must be this server
+		origin := r.Header.Get("Origin")
+		if origin == "" || origin == "null" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		return err == nil && u.Host == r.Host
+	},
+}
+
+// page is one connected display page
+type page struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+// Display is a Player backend that drives full-screen browser pages instead of mpv
+// Pages receive commands over a websocket, stream media over HTTP with range
+// support, and report position and end of track back
+type Display struct {
+	mu  sync.Mutex
+	key string // Pairing key pages must pass ("" lets no page in)
+
+	started bool
+	pages   map[*page]bool
+
+	// Media reachable through MediaPath
+	media      map[string]string // token -> file path
+	mediaOrder []string
+
+	// Current content, replayed to pages that connect later
+	loadID      int64
+	current     *Command
+	ticker      *Command
+	image       bool
+	playingSong bool
+	monitoring  bool
+	paused      bool
+	position    float64
+	duration    float64
+	volume      float64
+	tempo       float64
+	gain        float64
+	fade        float64
+	pitch       int
+	trimLead    float64 // Trim for the next song (SetTrim)
+	trimTail    float64
+	display     mpv.DisplaySettings
+
+	onStateChange func(state models.PlayerState)
+	onTrackEnd    func()
+}
+
+var _ mpv.Player = (*Display)(nil)
+
+// New creates a web display; pages must pass key as ?key=
+func New(key string) *Display {
+	return &Display{
+		key:    key,
+		pages:  make(map[*page]bool),
+		media:  make(map[string]string),
+		volume: 100,
+		tempo:  1.0,
+		fade:   1.0,
+	}
+}
+
+// LoadOrCreateKey returns the pairing key saved at path, generating and saving a
+// random one the first time so the display is never open to the whole network
+func LoadOrCreateKey(path string) (string, error) {
+	if data, err := os.ReadFile(path); err == nil {
+		if key := strings.TrimSpace(string(data)); key != "" {
+			return key, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(secret)
+	if err := os.WriteFile(path, []byte(key), 0600); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// PageURL returns the display page path with its pairing key, for admins to open on the TV
+func (d *Display) PageURL() string {
+	return PagePath + "?key=" + url.QueryEscape(d.key)
+}
+
+// ConnectedPages returns how many display pages are connected
+func (d *Display) ConnectedPages() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.pages)
+}
+
+// ============================================================================
+// HTTP handlers
+// ============================================================================
+
+// ServePage serves the full-screen display page
+func (d *Display) ServePage(w http.ResponseWriter, r *http.Request) {
+	if !d.authorized(r) {
+		http.Error(w, "invalid display key", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(pageHTML)
+}
+
+// ServeMedia streams a registered media file with HTTP range support
+func (d *Display) ServeMedia(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, MediaPath)
+	token = strings.TrimSuffix(token, filepath.Ext(token)) // URLs carry the extension for the browser
+	d.mu.Lock()
+	path, ok := d.media[token]
+	d.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	// ServeContent handles Range/If-Range and sets the type from the extension
+	http.ServeContent(w, r, filepath.Base(path), info.ModTime(), f)
+}
+
+// ServeWS accepts a display page connection
+func (d *Display) ServeWS(w http.ResponseWriter, r *http.Request) {
+	if !d.authorized(r) {
+		http.Error(w, "invalid display key", http.StatusForbidden)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Error("Upgrade failed", "err", err)
+		return
+	}
+
+	p := &page{conn: conn, send: make(chan []byte, 64)}
+	d.mu.Lock()
+	d.pages[p] = true
+	replay := d.replayLocked()
+	d.mu.Unlock()
+	logger.Info("Page connected", "addr", r.RemoteAddr)
+
+	for _, cmd := range replay {
+		d.sendTo(p, cmd)
+	}
+
+	go p.writePump()
+	d.readPump(p)
+}
+
+// authorized checks the pairing key
+func (d *Display) authorized(r *http.Request) bool {
+	key := r.URL.Query().Get("key")
+	return d.key != "" && subtle.ConstantTimeCompare([]byte(key), []byte(d.key)) == 1
+}
+
+// replayLocked returns the commands that bring a new page up to date; caller holds mu
+func (d *Display) replayLocked() []Command {
+	var cmds []Command
+	if d.current != nil {
+		cmd := *d.current
+		cmd.Position = d.position
+		cmd.Paused = d.paused
+		cmds = append(cmds, cmd)
+	}
+	if d.volume != 100 {
+		cmds = append(cmds, Command{Type: "volume", Value: d.volume})
+	}
+	if d.tempo != 1.0 {
+		cmds = append(cmds, Command{Type: "tempo", Value: d.tempo})
+	}
+	if d.gain != 0 {
+		cmds = append(cmds, Command{Type: "gain", Value: d.gain})
+	}
+	if d.fade != 1.0 {
+		cmds = append(cmds, Command{Type: "fade", Value: d.fade})
+	}
+	if d.ticker != nil {
+		cmds = append(cmds, *d.ticker)
+	}
+	return cmds
+}
+
+// writePump sends queued commands to the page
+func (p *page) writePump() {
+	defer p.conn.Close()
+	for msg := range p.send {
+		if err := p.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			return
+		}
+	}
+}
+
+// readPump handles reports from the page until it disconnects
+func (d *Display) readPump(p *page) {
+	defer func() {
+		d.mu.Lock()
+		if d.pages[p] {
+			delete(d.pages, p)
+			close(p.send)
+		}
+		d.mu.Unlock()
+		p.conn.Close()
+		logger.Info("Page disconnected")
+	}()
+
+	for {
+		_, data, err := p.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var report Report
+		if err := json.Unmarshal(data, &report); err != nil {
+			continue
+		}
+		d.handleReport(report)
+	}
+}
+
+// handleReport applies a page report, firing callbacks outside the lock
+func (d *Display) handleReport(report Report) {
+	d.mu.Lock()
+	if report.ID != d.loadID || d.current == nil {
+		d.mu.Unlock() // Stale report for media that has since been replaced
+		return
+	}
+
+	switch report.Type {
+	case "state":
+		changed := report.Paused != d.paused || report.Duration != d.duration
+		d.position = report.Position
+		d.duration = report.Duration
+		d.paused = report.Paused
+		fn := d.onStateChange
+		state := d.stateLocked()
+		d.mu.Unlock()
+		if changed && fn != nil {
+			fn(state)
+		}
+
+	case "ended", "error":
+		if report.Type == "error" {
+			logger.Error("Media error", "url", d.current.URL, "message", report.Message)
+		}
+		// A song that can't play in the browser ends so the queue keeps moving
+		ended := d.playingSong && d.monitoring
+		if ended {
+			d.playingSong = false
+			d.monitoring = false
+			if report.Type == "ended" && d.duration > 0 {
+				d.position = d.duration
+			}
+		}
+		fn := d.onTrackEnd
+		d.mu.Unlock()
+		if ended && fn != nil {
+			logger.Info("Song finished")
+			fn()
+		}
+
+	default:
+		d.mu.Unlock()
+	}
+}
+
+// ============================================================================
+// Commands
+// ============================================================================
+
+// broadcast sends a command to every connected page; caller must not hold mu
+func (d *Display) broadcast(cmd Command) {
+	d.mu.Lock()
+	pages := make([]*page, 0, len(d.pages))
+	for p := range d.pages {
+		pages = append(pages, p)
+	}
+	d.mu.Unlock()
+	for _, p := range pages {
+		d.sendTo(p, cmd)
+	}
+}
+
+// sendTo queues a command for one page, dropping it if the page is backed up
+func (d *Display) sendTo(p *page, cmd Command) {
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.pages[p] {
+		return
+	}
+	select {
+	case p.send <- data:
+	default:
+		logger.Warn("Page send buffer full, dropping command")
+	}
+}
+
+// mediaURL makes a local file reachable by pages; remote URLs pass through
+func (d *Display) mediaURL(path string) string {
+	if path == "" {
+		return ""
+	}
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for token, p := range d.media {
+		if p == path {
+			return MediaPath + token + filepath.Ext(path)
+		}
+	}
+
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	token := hex.EncodeToString(buf)
+	d.media[token] = path
+	d.mediaOrder = append(d.mediaOrder, token)
+	if len(d.mediaOrder) > maxMediaTokens {
+		delete(d.media, d.mediaOrder[0])
+		d.mediaOrder = d.mediaOrder[1:]
+	}
+	return MediaPath + token + filepath.Ext(path)
+}
+
+// load replaces the current content and sends it to the pages
+func (d *Display) load(cmd Command, image, song bool) error {
+	d.mu.Lock()
+	if !d.started {
+		d.mu.Unlock()
+		return fmt.Errorf("web display not started")
+	}
+	d.loadID++
+	cmd.ID = d.loadID
+	if image {
+		d.trimLead, d.trimTail = 0, 0
+	} else {
+		cmd.TrimStart, cmd.TrimEnd = d.trimLead, d.trimTail
+	}
+	d.current = &cmd
+	d.image = image
+	switch {
+	case image:
+		d.playingSong = false
+	case song:
+		d.playingSong = true
+	}
+	d.monitoring = false
+	d.paused = false
+	d.position = 0
+	d.duration = 0
+	d.mu.Unlock()
+
+	d.broadcast(cmd)
+	return nil
+}
+
+// withStarted runs fn if the display is started
+func (d *Display) withStarted(fn func()) error {
+	d.mu.Lock()
+	started := d.started
+	d.mu.Unlock()
+	if !started {
+		return fmt.Errorf("web display not started")
+	}
+	fn()
+	return nil
+}
+
+// Start makes the display accept content; pages can connect before or after
+func (d *Display) Start() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.started = true
+	logger.Info("Ready, open the page on the display screen", "path", PagePath)
+	return nil
+}
+
+// Stop clears the pages and stops accepting content
+func (d *Display) Stop() error {
+	d.broadcast(Command{Type: "stop"})
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.started = false
+	d.current = nil
+	d.playingSong = false
+	d.monitoring = false
+	return nil
+}
+
+// Restart reloads connected pages' content; the browser itself can't be relaunched
+func (d *Display) Restart() error {
+	d.mu.Lock()
+	d.started = true
+	connected := len(d.pages) > 0
+	d.mu.Unlock()
+	if !connected {
+		return fmt.Errorf("no web display connected - open %s on the display screen", PagePath)
+	}
+	return nil
+}
+
+// Detach stops sending content but leaves the pages playing; they reconnect to the next process
+func (d *Display) Detach() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.started = false
+	d.monitoring = false
+	return nil
+}
+
+// Adopted is always false: the next process doesn't know what the pages are playing
+func (d *Display) Adopted() bool {
+	return false
+}
+
+// IsRunning reports whether at least one display page is connected
+func (d *Display) IsRunning() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.started && len(d.pages) > 0
+}
+
+// SetDisplaySettings records the display settings (the browser picks its own screen)
+func (d *Display) SetDisplaySettings(settings mpv.DisplaySettings) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.display = settings
+}
+
+// LoadFile loads a media file; like mpv it doesn't mark it as a song by itself
+func (d *Display) LoadFile(path string) error {
+	return d.load(Command{Type: "load", Kind: "video", URL: d.mediaURL(path)}, false, false)
+}
+
+// LoadImage displays an image indefinitely (holding screen)
+func (d *Display) LoadImage(path string) error {
+	return d.load(Command{Type: "image", URL: d.mediaURL(path)}, true, false)
+}
+
+// LoadHoldingVideo shows the holding screen image over a muted, looping video
+func (d *Display) LoadHoldingVideo(videoPath, overlayPath string) error {
+	return d.load(Command{Type: "image", URL: d.mediaURL(overlayPath), VideoURL: d.mediaURL(videoPath)}, true, false)
+}
+
+// LoadCDG plays CDG audio; browsers can't render CDG graphics, so the page shows a notice
+func (d *Display) LoadCDG(cdgPath, audioPath string) error {
+	err := d.load(Command{Type: "load", Kind: "cdg", URL: d.mediaURL(cdgPath), AudioURL: d.mediaURL(audioPath)}, false, true)
+	if err == nil {
+		d.StartPlaybackMonitor()
+	}
+	return err
+}
+
+// SetVocalMix plays the instrumental stem with the vocal stem mixed in at vocalGain
+func (d *Display) SetVocalMix(instrumentalPath, vocalPath string, vocalGain float64) error {
+	// The vocal stem always loads so SetVocalGain can bring it in later
+	cmd := Command{
+		Type:      "load",
+		Kind:      "stems",
+		URL:       d.mediaURL(instrumentalPath),
+		VocalURL:  d.mediaURL(vocalPath),
+		VocalGain: models.ClampVocalGain(vocalGain),
+	}
+	err := d.load(cmd, false, true)
+	if err == nil {
+		d.StartPlaybackMonitor()
+	}
+	return err
+}
+
+// LoadBGMWithImage shows an image with background music fading in under it
+func (d *Display) LoadBGMWithImage(imagePath, audioURL string, targetVolume float64) error {
+	return d.load(Command{
+		Type:       "bgm",
+		URL:        d.mediaURL(imagePath),
+		AudioURL:   d.mediaURL(audioURL),
+		Value:      targetVolume,
+		DurationMs: 2000,
+	}, true, false)
+}
+
+// UpdateBGMImage swaps the image while the background music keeps playing
+func (d *Display) UpdateBGMImage(imagePath string) error {
+	url := d.mediaURL(imagePath)
+	d.mu.Lock()
+	if !d.started || d.current == nil || d.current.Type != "bgm" {
+		d.mu.Unlock()
+		return fmt.Errorf("no background music playing")
+	}
+	d.current.URL = url
+	d.mu.Unlock()
+
+	d.broadcast(Command{Type: "bgm_image", URL: url})
+	return nil
+}
+
+// StopBGMWithFade fades the background music out, then stops it
+func (d *Display) StopBGMWithFade(fadeDuration time.Duration) error {
+	err := d.withStarted(func() {
+		d.broadcast(Command{Type: "stop", DurationMs: int(fadeDuration / time.Millisecond)})
+	})
+	if err != nil {
+		return err
+	}
+	if d.ConnectedPages() > 0 {
+		time.Sleep(fadeDuration) // Like mpv, return once the fade is done
+	}
+	d.clear()
+	return nil
+}
+
+// SetTrim skips lead seconds at the start and tail seconds at the end of the next song
+func (d *Display) SetTrim(lead, tail float64) error {
+	return d.withStarted(func() {
+		d.mu.Lock()
+		d.trimLead, d.trimTail = lead, tail
+		d.mu.Unlock()
+	})
+}
+
+// StopPlayback stops playback without disconnecting the pages
+func (d *Display) StopPlayback() error {
+	err := d.withStarted(func() { d.broadcast(Command{Type: "stop"}) })
+	if err == nil {
+		d.clear()
+	}
+	return err
+}
+
+// clear forgets the current content
+func (d *Display) clear() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.loadID++
+	d.current = nil
+	d.image = false
+	d.playingSong = false
+	d.monitoring = false
+	d.position = 0
+	d.duration = 0
+}
+
+// Play resumes playback
+func (d *Display) Play() error {
+	return d.withStarted(func() {
+		d.mu.Lock()
+		d.paused = false
+		d.mu.Unlock()
+		d.broadcast(Command{Type: "play"})
+	})
+}
+
+// Pause pauses playback
+func (d *Display) Pause() error {
+	return d.withStarted(func() {
+		d.mu.Lock()
+		d.paused = true
+		d.mu.Unlock()
+		d.broadcast(Command{Type: "pause"})
+	})
+}
+
+// Seek seeks to a position in seconds
+func (d *Display) Seek(position float64) error {
+	return d.withStarted(func() {
+		d.mu.Lock()
+		d.position = position
+		d.mu.Unlock()
+		d.broadcast(Command{Type: "seek", Value: position})
+	})
+}
+
+// SetVolume sets the playback volume (0-100)
+func (d *Display) SetVolume(volume float64) error {
+	return d.withStarted(func() {
+		d.mu.Lock()
+		d.volume = volume
+		d.mu.Unlock()
+		d.broadcast(Command{Type: "volume", Value: volume})
+	})
+}
+
+// SetPitch records the pitch shift; browsers can't shift pitch independently of tempo
+func (d *Display) SetPitch(semitones int) error {
+	return d.withStarted(func() {
+		d.mu.Lock()
+		d.pitch = semitones
+		d.mu.Unlock()
+		if semitones != 0 {
+			logger.Warn("Pitch shift is not supported by the web display", "semitones", semitones)
+		}
+	})
+}
+
+// SetTempo sets the playback speed, keeping pitch
+func (d *Display) SetTempo(speed float64) error {
+	if speed <= 0 {
+		return fmt.Errorf("invalid tempo: %.2f", speed)
+	}
+	return d.withStarted(func() {
+		d.mu.Lock()
+		d.tempo = speed
+		d.mu.Unlock()
+		d.broadcast(Command{Type: "tempo", Value: speed})
+	})
+}
+
+// SetGain sets the loudness normalization gain in dB; pages can only attenuate,
+// so boosts above the current volume are capped at full volume
+func (d *Display) SetGain(db float64) error {
+	return d.withStarted(func() {
+		d.mu.Lock()
+		d.gain = db
+		d.mu.Unlock()
+		d.broadcast(Command{Type: "gain", Value: db})
+	})
+}
+
+// SetVocalGain changes the vocal stem level (0-1) without reloading
+func (d *Display) SetVocalGain(gain float64) error {
+	gain = models.ClampVocalGain(gain)
+	d.mu.Lock()
+	if d.current == nil || d.current.VocalURL == "" {
+		d.mu.Unlock()
+		return fmt.Errorf("no vocal mix loaded")
+	}
+	d.current.VocalGain = gain // Replayed to pages that connect later
+	d.mu.Unlock()
+
+	return d.withStarted(func() {
+		d.broadcast(Command{Type: "vocal_gain", Value: gain})
+	})
+}
+
+// SetFade sets the transition level (0-1) of the audio and picture
+func (d *Display) SetFade(level float64) error {
+	return d.withStarted(func() {
+		d.mu.Lock()
+		d.fade = level
+		d.mu.Unlock()
+		d.broadcast(Command{Type: "fade", Value: level})
+	})
+}
+
+// SetBGMFade sets the level (0-1) of the background music, which pages play apart from songs
+func (d *Display) SetBGMFade(level float64) error {
+	return d.withStarted(func() {
+		d.broadcast(Command{Type: "bgm_fade", Value: level})
+	})
+}
+
+// StopBGM stops the background music, leaving whatever is loaded playing
+func (d *Display) StopBGM() error {
+	return d.withStarted(func() {
+		d.broadcast(Command{Type: "bgm_stop"})
+	})
+}
+
+// ShowOverlay displays text on the pages for durationMs
+func (d *Display) ShowOverlay(text string, durationMs int) error {
+	return d.withStarted(func() {
+		d.broadcast(Command{Type: "overlay", Text: text, DurationMs: durationMs})
+	})
+}
+
+// ShowReaction floats a reaction up the pages
+// The frames are sent inline as one PNG strip that the page steps through
+func (d *Display) ShowReaction(r mpv.Reaction) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	size := r.Frames[0].Bounds().Dx()
+	strip := image.NewRGBA(image.Rect(0, 0, size*len(r.Frames), size))
+	for i, frame := range r.Frames {
+		draw.Draw(strip, image.Rect(i*size, 0, (i+1)*size, size), frame, frame.Bounds().Min, draw.Src)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, strip); err != nil {
+		return err
+	}
+	return d.withStarted(func() {
+		d.broadcast(Command{
+			Type:       "reaction",
+			URL:        "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+			Frames:     len(r.Frames),
+			FPS:        r.FPS,
+			Value:      r.X,
+			Text:       r.Label,
+			DurationMs: int(r.Duration / time.Millisecond),
+		})
+	})
+}
+
+// ShowTicker shows the scrolling up-next ticker
+func (d *Display) ShowTicker(entries []mpv.TickerEntry) error {
+	if len(entries) == 0 {
+		return d.HideTicker()
+	}
+	cmd := Command{Type: "ticker", Entries: make([]TickerEntry, len(entries))}
+	for i, e := range entries {
+		cmd.Entries[i] = TickerEntry{Singer: e.SingerName, Song: e.SongTitle}
+	}
+	return d.withStarted(func() {
+		d.mu.Lock()
+		d.ticker = &cmd
+		d.mu.Unlock()
+		d.broadcast(cmd)
+	})
+}
+
+// HideTicker hides the ticker
+func (d *Display) HideTicker() error {
+	return d.withStarted(func() {
+		d.mu.Lock()
+		d.ticker = nil
+		d.mu.Unlock()
+		d.broadcast(Command{Type: "hide_ticker"})
+	})
+}
+
+// GetState returns the state last reported by the pages
+func (d *Display) GetState() (models.PlayerState, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.started || len(d.pages) == 0 {
+		return models.PlayerState{}, fmt.Errorf("no web display connected")
+	}
+	return d.stateLocked(), nil
+}
+
+// stateLocked builds the player state; caller holds mu
+func (d *Display) stateLocked() models.PlayerState {
+	return models.PlayerState{
+		Position:  d.position,
+		Duration:  d.duration,
+		IsPlaying: d.current != nil && !d.image && !d.paused,
+		Volume:    d.volume,
+	}
+}
+
+// SetPlayingSong marks whether a song (vs holding screen or BGM) is playing
+func (d *Display) SetPlayingSong(playing bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.playingSong = playing
+}
+
+// IsPlayingSong reports whether a song is loaded
+func (d *Display) IsPlayingSong() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.playingSong
+}
+
+// StartPlaybackMonitor turns the pages' end-of-media reports into track ends
+func (d *Display) StartPlaybackMonitor() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.monitoring = true
+}
+
+// OnStateChange sets the callback for state changes
+func (d *Display) OnStateChange(fn func(state models.PlayerState)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.onStateChange = fn
+}
+
+// OnTrackEnd sets the callback for when a song finishes
+func (d *Display) OnTrackEnd(fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.onTrackEnd = fn
+}
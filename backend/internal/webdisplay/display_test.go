@@ -0,0 +1,400 @@
+package webdisplay
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"songmartyn/internal/mpv"
+)
+
+// testKey pairs test pages with the display
+const testKey = "test-key"
+
+// newTestServer serves a started display the way the app mounts it
+func newTestServer(t *testing.T, key string) (*Display, *httptest.Server) {
+	t.Helper()
+	d := New(key)
+	d.Start()
+	mux := http.NewServeMux()
+	mux.HandleFunc(PagePath, d.ServePage)
+	mux.HandleFunc(WSPath, d.ServeWS)
+	mux.HandleFunc(MediaPath, d.ServeMedia)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return d, srv
+}
+
+// connectPage opens a display websocket and waits until the display sees it
+func connectPage(t *testing.T, d *Display, srv *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+	before := d.ConnectedPages()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + WSPath + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	waitFor(t, "page to register", func() bool { return d.ConnectedPages() > before })
+	return conn
+}
+
+// readCommand reads the next command sent to a page
+func readCommand(t *testing.T, conn *websocket.Conn) Command {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var cmd Command
+	if err := conn.ReadJSON(&cmd); err != nil {
+		t.Fatalf("Expected a command: %v", err)
+	}
+	return cmd
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func writeMediaFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// ============================================================================
+// Command Tests
+// ============================================================================
+
+func TestLoadSendsMediaURL(t *testing.T) {
+	d, srv := newTestServer(t, testKey)
+	conn := connectPage(t, d, srv, "?key="+testKey)
+	path := writeMediaFile(t, "song.mp4", "0123456789")
+
+	d.SetPlayingSong(true)
+	if err := d.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	cmd := readCommand(t, conn)
+	if cmd.Type != "load" || cmd.Kind != "video" || cmd.ID == 0 {
+		t.Fatalf("Expected a video load command, got %+v", cmd)
+	}
+	if !strings.HasPrefix(cmd.URL, MediaPath) || !strings.HasSuffix(cmd.URL, ".mp4") {
+		t.Errorf("Expected a tokenised media URL ending in .mp4, got %s", cmd.URL)
+	}
+	if strings.Contains(cmd.URL, "song") {
+		t.Errorf("Expected the file path to stay private, got %s", cmd.URL)
+	}
+	if !d.IsPlayingSong() {
+		t.Error("Expected LoadFile to keep the playing-song flag")
+	}
+
+	d.Pause()
+	if cmd := readCommand(t, conn); cmd.Type != "pause" {
+		t.Errorf("Expected pause, got %+v", cmd)
+	}
+	d.Seek(42)
+	if cmd := readCommand(t, conn); cmd.Type != "seek" || cmd.Value != 42 {
+		t.Errorf("Expected seek to 42, got %+v", cmd)
+	}
+	d.ShowTicker([]mpv.TickerEntry{{SingerName: "Alice", SongTitle: "Song"}})
+	if cmd := readCommand(t, conn); cmd.Type != "ticker" || len(cmd.Entries) != 1 || cmd.Entries[0].Singer != "Alice" {
+		t.Errorf("Expected ticker with Alice, got %+v", cmd)
+	}
+}
+
+func TestStemsAndRemoteURLs(t *testing.T) {
+	d, srv := newTestServer(t, testKey)
+	conn := connectPage(t, d, srv, "?key="+testKey)
+
+	d.SetVocalMix(writeMediaFile(t, "instr.wav", "i"), writeMediaFile(t, "vocal.wav", "v"), 0.4)
+	cmd := readCommand(t, conn)
+	if cmd.Kind != "stems" || cmd.VocalURL == "" || cmd.VocalGain != 0.4 {
+		t.Errorf("Expected stems with a vocal track at 0.4, got %+v", cmd)
+	}
+
+	// The vocal level changes live, and late pages get the new level
+	if err := d.SetVocalGain(0.7); err != nil {
+		t.Fatalf("SetVocalGain failed: %v", err)
+	}
+	if cmd := readCommand(t, conn); cmd.Type != "vocal_gain" || cmd.Value != 0.7 {
+		t.Errorf("Expected vocal_gain 0.7, got %+v", cmd)
+	}
+	late := connectPage(t, d, srv, "?key="+testKey)
+	if cmd := readCommand(t, late); cmd.Kind != "stems" || cmd.VocalGain != 0.7 {
+		t.Errorf("Expected late page to get stems at 0.7, got %+v", cmd)
+	}
+
+	d.LoadBGMWithImage(writeMediaFile(t, "holding.png", "png"), "https://radio.example/stream", 40)
+	cmd = readCommand(t, conn)
+	if cmd.Type != "bgm" || cmd.AudioURL != "https://radio.example/stream" || cmd.Value != 40 {
+		t.Errorf("Expected BGM with the remote stream passed through, got %+v", cmd)
+	}
+}
+
+func TestBGMFadesUnderSong(t *testing.T) {
+	d, srv := newTestServer(t, testKey)
+	conn := connectPage(t, d, srv, "?key="+testKey)
+
+	d.LoadBGMWithImage(writeMediaFile(t, "holding.png", "png"), "https://radio.example/stream", 40)
+	if cmd := readCommand(t, conn); cmd.Type != "bgm" {
+		t.Fatalf("Expected bgm, got %+v", cmd)
+	}
+
+	// A song loads over the music, which fades and stops on its own commands
+	d.LoadFile(writeMediaFile(t, "song.mp4", "video"))
+	if cmd := readCommand(t, conn); cmd.Type != "load" {
+		t.Fatalf("Expected load, got %+v", cmd)
+	}
+	d.SetBGMFade(0.5)
+	if cmd := readCommand(t, conn); cmd.Type != "bgm_fade" || cmd.Value != 0.5 {
+		t.Errorf("Expected bgm_fade 0.5, got %+v", cmd)
+	}
+	d.StopBGM()
+	if cmd := readCommand(t, conn); cmd.Type != "bgm_stop" {
+		t.Errorf("Expected bgm_stop, got %+v", cmd)
+	}
+}
+
+func TestLatePageGetsCurrentContent(t *testing.T) {
+	d, srv := newTestServer(t, testKey)
+	d.SetPlayingSong(true)
+	d.LoadFile(writeMediaFile(t, "song.mp4", "x"))
+	d.SetTempo(1.25)
+	d.SetGain(-6.5)
+	d.ShowTicker([]mpv.TickerEntry{{SingerName: "Bob", SongTitle: "Tune"}})
+
+	conn := connectPage(t, d, srv, "?key="+testKey)
+	if cmd := readCommand(t, conn); cmd.Type != "load" {
+		t.Errorf("Expected the current song first, got %+v", cmd)
+	}
+	if cmd := readCommand(t, conn); cmd.Type != "tempo" || cmd.Value != 1.25 {
+		t.Errorf("Expected tempo 1.25, got %+v", cmd)
+	}
+	if cmd := readCommand(t, conn); cmd.Type != "gain" || cmd.Value != -6.5 {
+		t.Errorf("Expected gain -6.5 dB, got %+v", cmd)
+	}
+	if cmd := readCommand(t, conn); cmd.Type != "ticker" {
+		t.Errorf("Expected the ticker, got %+v", cmd)
+	}
+}
+
+func TestReactionSentAsSpriteStrip(t *testing.T) {
+	d, srv := newTestServer(t, testKey)
+	conn := connectPage(t, d, srv, "?key="+testKey)
+
+	r := mpv.Reaction{FPS: 12, X: 0.25, Label: "Alice", Duration: 4 * time.Second}
+	for i := 0; i < 3; i++ {
+		r.Frames = append(r.Frames, image.NewRGBA(image.Rect(0, 0, 16, 16)))
+	}
+	if err := d.ShowReaction(r); err != nil {
+		t.Fatalf("ShowReaction failed: %v", err)
+	}
+	cmd := readCommand(t, conn)
+	if cmd.Type != "reaction" || cmd.Frames != 3 || cmd.FPS != 12 || cmd.Value != 0.25 || cmd.Text != "Alice" || cmd.DurationMs != 4000 {
+		t.Fatalf("Expected Alice's reaction, got %+v", cmd)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(cmd.URL, "data:image/png;base64,"))
+	if err != nil {
+		t.Fatalf("Expected a PNG data URL: %v", err)
+	}
+	strip, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode strip: %v", err)
+	}
+	if b := strip.Bounds(); b.Dx() != 48 || b.Dy() != 16 {
+		t.Errorf("Expected a 48x16 strip, got %dx%d", b.Dx(), b.Dy())
+	}
+
+	// Invalid reactions never reach the page
+	if err := d.ShowReaction(mpv.Reaction{}); err == nil {
+		t.Error("Expected error for a reaction without frames")
+	}
+}
+
+func TestPairingKey(t *testing.T) {
+	d, srv := newTestServer(t, "secret")
+
+	resp, err := http.Get(srv.URL + PagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 without key, got %d", resp.StatusCode)
+	}
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + WSPath
+	if _, _, err := websocket.DefaultDialer.Dial(url, nil); err == nil {
+		t.Error("Expected websocket without key to be rejected")
+	}
+	connectPage(t, d, srv, "?key=secret")
+
+	// Pages from another site can't connect even with the key
+	header := http.Header{"Origin": {"http://evil.example"}}
+	if _, _, err := websocket.DefaultDialer.Dial(url+"?key=secret", header); err == nil {
+		t.Error("Expected websocket from another origin to be rejected")
+	}
+}
+
+func TestNoKeyLetsNoPageIn(t *testing.T) {
+	_, srv := newTestServer(t, "")
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + WSPath
+	for _, query := range []string{"", "?key="} {
+		if _, _, err := websocket.DefaultDialer.Dial(url+query, nil); err == nil {
+			t.Errorf("Expected websocket with %q to be rejected by a display without a key", query)
+		}
+	}
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "display.key")
+	key, err := LoadOrCreateKey(path)
+	if err != nil || len(key) != 32 {
+		t.Fatalf("Expected a generated key, got %q, %v", key, err)
+	}
+	if again, _ := LoadOrCreateKey(path); again != key {
+		t.Errorf("Expected the saved key %q to be reused, got %q", key, again)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the key file readable only by its owner, got %v", info.Mode().Perm())
+	}
+	if got := New(key).PageURL(); got != PagePath+"?key="+key {
+		t.Errorf("Expected the page URL to carry the key, got %s", got)
+	}
+}
+
+// ============================================================================
+// Media Streaming Tests
+// ============================================================================
+
+func TestMediaRangeRequests(t *testing.T) {
+	d, srv := newTestServer(t, testKey)
+	conn := connectPage(t, d, srv, "?key="+testKey)
+	d.LoadFile(writeMediaFile(t, "song.mp4", "0123456789"))
+	cmd := readCommand(t, conn)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+cmd.URL, nil)
+	req.Header.Set("Range", "bytes=2-5")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Errorf("Expected 206, got %d", resp.StatusCode)
+	}
+	if string(body) != "2345" {
+		t.Errorf("Expected bytes 2345, got %q", body)
+	}
+	if got := resp.Header.Get("Content-Range"); got != "bytes 2-5/10" {
+		t.Errorf("Expected Content-Range bytes 2-5/10, got %q", got)
+	}
+
+	resp, _ = http.Get(srv.URL + MediaPath + "not-a-token.mp4")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown media, got %d", resp.StatusCode)
+	}
+}
+
+// ============================================================================
+// Report Tests
+// ============================================================================
+
+func TestReportsDriveStateAndTrackEnd(t *testing.T) {
+	d, srv := newTestServer(t, testKey)
+	conn := connectPage(t, d, srv, "?key="+testKey)
+	ended := make(chan struct{}, 2)
+	d.OnTrackEnd(func() { ended <- struct{}{} })
+
+	d.SetPlayingSong(true)
+	d.LoadFile(writeMediaFile(t, "song.mp4", "x"))
+	d.StartPlaybackMonitor()
+	load := readCommand(t, conn)
+
+	conn.WriteJSON(Report{Type: "state", ID: load.ID, Position: 61.5, Duration: 200})
+	waitFor(t, "position report", func() bool {
+		state, _ := d.GetState()
+		return state.Position == 61.5
+	})
+	if state, _ := d.GetState(); state.Duration != 200 || !state.IsPlaying {
+		t.Errorf("Expected playing with duration 200, got %+v", state)
+	}
+
+	// Reports for media that was replaced are ignored
+	conn.WriteJSON(Report{Type: "ended", ID: load.ID - 1})
+	conn.WriteJSON(Report{Type: "ended", ID: load.ID})
+	conn.WriteJSON(Report{Type: "ended", ID: load.ID})
+
+	select {
+	case <-ended:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected track end")
+	}
+	select {
+	case <-ended:
+		t.Error("Expected track end to fire once")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if d.IsPlayingSong() {
+		t.Error("Expected playing-song flag to clear")
+	}
+}
+
+func TestHoldingScreenNeverEnds(t *testing.T) {
+	d, srv := newTestServer(t, testKey)
+	conn := connectPage(t, d, srv, "?key="+testKey)
+	d.OnTrackEnd(func() { t.Error("Holding screen should not end a song") })
+
+	d.LoadImage(writeMediaFile(t, "holding.png", "png"))
+	cmd := readCommand(t, conn)
+	conn.WriteJSON(Report{Type: "ended", ID: cmd.ID})
+	time.Sleep(50 * time.Millisecond)
+}
+
+func TestIsRunningNeedsAPage(t *testing.T) {
+	d, srv := newTestServer(t, testKey)
+	if d.IsRunning() {
+		t.Error("Expected not running without a page")
+	}
+	if err := d.Restart(); err == nil {
+		t.Error("Expected restart to fail without a page")
+	}
+	// Content loaded while no page is connected is kept for when one connects
+	if err := d.LoadImage(writeMediaFile(t, "holding.png", "png")); err != nil {
+		t.Errorf("Expected load without a page to succeed, got %v", err)
+	}
+
+	conn := connectPage(t, d, srv, "?key="+testKey)
+	if !d.IsRunning() {
+		t.Error("Expected running once a page connects")
+	}
+	if cmd := readCommand(t, conn); cmd.Type != "image" {
+		t.Errorf("Expected the holding screen on connect, got %+v", cmd)
+	}
+
+	conn.Close()
+	waitFor(t, "page to disconnect", func() bool { return !d.IsRunning() })
+}
@@ -0,0 +1,8 @@
+package webdisplay
+
+import _ "embed"
+
+// pageHTML is the self-contained full-screen display page
+//
+//go:embed display.html
+var pageHTML []byte
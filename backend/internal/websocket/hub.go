@@ -1,18 +1,23 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
-	"log"
 	"net"
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"songmartyn/internal/device"
+	"songmartyn/internal/logging"
 	"songmartyn/pkg/models"
 )
 
+// logger is the "ws" subsystem; connection chatter is at debug level
+var logger = logging.For("ws")
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -37,6 +42,7 @@ const (
 	MsgSkip           MessageType = "skip"            // Skip current song
 	MsgSeek           MessageType = "seek"            // Seek to position
 	MsgVocalAssist    MessageType = "vocal_assist"    // Set vocal assist level
+	MsgVocalGain      MessageType = "vocal_gain"      // Set continuous vocal assist (0-1)
 	MsgVolume         MessageType = "volume"          // Set volume
 	MsgKeyChange      MessageType = "key_change"      // Set pitch shift in semitones
 	MsgTempoChange    MessageType = "tempo_change"    // Set tempo/speed multiplier
@@ -47,6 +53,27 @@ const (
 	MsgSetAFK         MessageType = "set_afk"         // Set AFK status
 	MsgAddFavorite    MessageType = "add_favorite"    // Add song to favorites
 	MsgRemoveFavorite MessageType = "remove_favorite" // Remove song from favorites
+	MsgGetRecommendations MessageType = "get_recommendations" // Request song recommendations
+	MsgPlaylistList       MessageType = "playlist_list"        // List own and shared playlists
+	MsgPlaylistCreate     MessageType = "playlist_create"      // Create a playlist
+	MsgPlaylistRename     MessageType = "playlist_rename"      // Rename a playlist (owner)
+	MsgPlaylistDelete     MessageType = "playlist_delete"      // Delete a playlist (owner)
+	MsgPlaylistAddSong    MessageType = "playlist_add_song"    // Append a song to a playlist
+	MsgPlaylistRemoveSong MessageType = "playlist_remove_song" // Remove a song from a playlist
+	MsgPlaylistMoveSong   MessageType = "playlist_move_song"   // Reorder a song within a playlist
+	MsgPlaylistShare      MessageType = "playlist_share"       // Share a playlist with another singer by public ID, or change their edit access (owner)
+	MsgPlaylistUnshare    MessageType = "playlist_unshare"     // Revoke a share, or leave a shared playlist
+	MsgPlaylistShareLink  MessageType = "playlist_share_link"  // Get (or rotate) a share link; also the reply
+	MsgPlaylistJoin       MessageType = "playlist_join"        // Join a playlist from a share link token
+	MsgPlaylistQueue      MessageType = "playlist_queue"       // Queue every song in a playlist
+	MsgStateResync        MessageType = "state_resync"         // Request a full state snapshot (delta sync gap)
+	MsgMicPreset          MessageType = "mic_preset"           // Choose a microphone effects preset
+	MsgSetRecording       MessageType = "set_recording"        // Opt in/out of performance recording
+	MsgGetRecordings      MessageType = "get_recordings"       // List own recordings with fresh download links
+	MsgReaction           MessageType = "reaction"             // Float own avatar with a reaction (clap, heart, fire, laugh) on the display
+	MsgAvatarSave         MessageType = "avatar_save"          // Save an avatar (the current one by default) to the gallery by name
+	MsgAvatarUse          MessageType = "avatar_use"           // Switch to a saved avatar
+	MsgAvatarDelete       MessageType = "avatar_delete"        // Remove a saved avatar
 
 	// Admin messages (Client -> Server)
 	MsgAdminSetAdmin    MessageType = "admin_set_admin"     // Promote/demote user to admin
@@ -69,6 +96,14 @@ const (
 	MsgError        MessageType = "error"         // Error message
 	MsgClientList   MessageType = "client_list"   // List of connected clients (admin)
 	MsgKicked       MessageType = "kicked"        // You've been kicked
+	MsgRecommendations MessageType = "recommendations" // Song recommendations for this singer
+	MsgPlaylists       MessageType = "playlists"       // This singer's playlists
+	MsgRecordings      MessageType = "recordings"      // This singer's recordings with download links
+	MsgRecordingReady  MessageType = "recording_ready" // A performance recording finished
+	MsgStateSnapshot   MessageType = "state_snapshot"  // Full room state with sequence number (delta sync)
+	MsgStateDelta      MessageType = "state_delta"     // Room state changes since the previous sequence number
+	MsgPosition        MessageType = "position"        // Playback position tick (delta sync)
+	MsgRestarting      MessageType = "server_restarting" // Server is going away; reconnect after the hint
 )
 
 // Message represents a WebSocket message
@@ -81,12 +116,22 @@ type Message struct {
 type HandshakePayload struct {
 	MartynKey   string `json:"martyn_key,omitempty"` // Empty for new sessions
 	DisplayName string `json:"display_name,omitempty"`
+	StateProtocol int  `json:"state_protocol,omitempty"` // StateProtocolDelta opts in to snapshots and deltas
 }
 
 // WelcomePayload is sent to client after handshake
 type WelcomePayload struct {
-	Session   models.Session   `json:"session"`
-	RoomState models.RoomState `json:"room_state"`
+	Session   models.Session `json:"session"`
+	PublicID  string         `json:"public_id"`     // How the client's own songs and entry appear to guests
+	RoomState interface{}    `json:"room_state"`    // Projected for the client (see ProjectRoomState)
+	Seq       uint64         `json:"seq,omitempty"` // State sequence number (delta sync clients only)
+}
+
+// RestartPayload tells clients the server is going away and when to come back
+type RestartPayload struct {
+	Reason      string `json:"reason"`
+	ReconnectMs int    `json:"reconnect_ms"`  // Wait this long before reconnecting
+	URL         string `json:"url,omitempty"` // Reconnect here instead when the address changed
 }
 
 // Client represents a connected WebSocket client
@@ -95,8 +140,19 @@ type Client struct {
 	conn      *websocket.Conn
 	send      chan []byte
 	session   *models.Session
+	room      string // Room the client joined (DefaultRoom unless it asked for another)
 	ipAddress string
 	userAgent string
+
+	// Drain: writePump sends closeFrame when it reaches a nil message, then closes done
+	closeFrame []byte
+	done       chan struct{}
+
+	// Delta state sync (see statesync.go)
+	deltaSync bool
+	stateSeq  uint64
+	lastState interface{} // Last state document sent; nil forces a snapshot
+	stateMu   sync.Mutex
 }
 
 // ClientInfo contains client connection info for admin display
@@ -109,6 +165,7 @@ type ClientInfo struct {
 	DeviceBrowser string               `json:"device_browser"` // Safari, Chrome, Firefox, etc.
 	IPAddress     string               `json:"ip_address"`
 	IsAdmin       bool                 `json:"is_admin"`
+	Room          string               `json:"room"`
 	IsOnline      bool                 `json:"is_online"`
 	IsAFK         bool                 `json:"is_afk"`
 	IsBlocked     bool                 `json:"is_blocked"`
@@ -166,6 +223,12 @@ type SetDisplayNamePayload struct {
 	AvatarConfig *models.AvatarConfig `json:"avatar_config,omitempty"`
 }
 
+// AvatarGalleryPayload is the payload for saved avatar messages
+type AvatarGalleryPayload struct {
+	Name   string               `json:"name"`
+	Config *models.AvatarConfig `json:"config,omitempty"` // Save only; the current avatar when empty
+}
+
 // QueueAddPayload is the payload for adding a song to the queue
 type QueueAddPayload struct {
 	SongID      string                  `json:"song_id"`
@@ -178,58 +241,56 @@ type QueueMovePayload struct {
 	To   int `json:"to"`
 }
 
+// PlaylistPayload is the payload for all playlist messages; fields used depend on the action
+type PlaylistPayload struct {
+	PlaylistID  string                  `json:"playlist_id,omitempty"`
+	Name        string                  `json:"name,omitempty"`
+	SongID      string                  `json:"song_id,omitempty"`
+	SongIDs     []string                `json:"song_ids,omitempty"`
+	From        int                     `json:"from,omitempty"`
+	To          int                     `json:"to,omitempty"`
+	PublicID    string                  `json:"public_id,omitempty"` // Singer to share with or remove
+	CanEdit     bool                    `json:"can_edit,omitempty"`  // Share: let the singer change its songs
+	ShareToken  string                  `json:"share_token,omitempty"`
+	Rotate      bool                    `json:"rotate,omitempty"`
+	VocalAssist models.VocalAssistLevel `json:"vocal_assist,omitempty"`
+}
+
+// DefaultRoom is the room clients join when they don't ask for one
+const DefaultRoom = "main"
+
+// hubRoom holds one room's message handlers and its most recent state
+type hubRoom struct {
+	handlers  HubHandlers
+	lastState *models.RoomState // Most recent broadcast state, for resync requests
+}
+
 // Hub manages all WebSocket connections (The Nest Hub)
+// Every room shares the connections; handlers and state broadcasts are routed per room
 type Hub struct {
 	clients    map[*Client]bool
-	broadcast  chan []byte
+	broadcast  chan broadcastMessage
 	register   chan *Client
 	unregister chan *Client
 	mu         sync.RWMutex
+	draining   bool // Refusing new connections while the server restarts
+
+	rooms   map[string]*hubRoom // Handlers and last broadcast state per room
+	roomsMu sync.RWMutex
 
-	// Callbacks for handling messages
-	onHandshake        func(client *Client, payload HandshakePayload) (*models.Session, *models.RoomState)
-	onSearch           func(client *Client, query string)
-	onQueueAdd         func(client *Client, songID string, vocalAssist models.VocalAssistLevel)
-	onQueueRemove      func(client *Client, songID string)
-	onQueueMove        func(client *Client, from int, to int)
-	onQueueClear       func(client *Client)
-	onPlay             func(client *Client)
-	onPause            func(client *Client)
-	onSkip             func(client *Client)
-	onSeek             func(client *Client, position float64)
-	onVocalAssist      func(client *Client, level models.VocalAssistLevel)
-	onVolume           func(client *Client, volume float64)
-	onKeyChange        func(client *Client, semitones int)
-	onTempoChange      func(client *Client, speed float64)
-	onSetDisplayName   func(client *Client, name string, avatarID string, avatarConfig *models.AvatarConfig)
-	onAutoplay         func(client *Client, enabled bool)
-	onQueueShuffle     func(client *Client)
-	onQueueRequeue     func(client *Client, songID string, martynKey string)
-	onSetAFK           func(client *Client, isAFK bool)
-	onAddFavorite      func(client *Client, songID string)
-	onRemoveFavorite   func(client *Client, songID string)
-	onAdminSetAdmin    func(client *Client, martynKey string, isAdmin bool) error
-	onAdminKick        func(client *Client, martynKey string, reason string) error
-	onAdminBlock       func(client *Client, martynKey string, durationMinutes int, reason string) error
-	onAdminUnblock     func(client *Client, martynKey string) error
-	onAdminSetAFK      func(client *Client, martynKey string, isAFK bool) error
-	onAdminPlayNext    func(client *Client) error
-	onAdminStartNow    func(client *Client) error
-	onAdminStop        func(client *Client) error
-	onAdminSetName     func(client *Client, martynKey string, displayName string) error
-	onAdminSetNameLock func(client *Client, martynKey string, locked bool) error
-	onAdminToggleBGM   func(client *Client) error
-	onAdminSetMessage  func(client *Client, message string) error
-	onClientDisconnect func(client *Client)
+	observer      Observer             // Metrics hooks, set before Run
+	receivedTypes map[MessageType]bool // Incoming types reported so far, capped
+	receivedMu    sync.Mutex
 }
 
 // NewHub creates a new WebSocket hub
 func NewHub() *Hub {
 	return &Hub{
 		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte, 256),
+		broadcast:  make(chan broadcastMessage, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		rooms:      map[string]*hubRoom{DefaultRoom: {}},
 	}
 }
 
@@ -241,7 +302,7 @@ func (h *Hub) Run() {
 			h.mu.Lock()
 			h.clients[client] = true
 			h.mu.Unlock()
-			log.Printf("Client connected: %d total clients", len(h.clients))
+			logger.Info("Client connected", "clients", len(h.clients))
 
 		case client := <-h.unregister:
 			h.mu.Lock()
@@ -254,22 +315,26 @@ func (h *Hub) Run() {
 			h.mu.Unlock()
 
 			// Call disconnect callback AFTER releasing lock to avoid deadlock
-			if wasConnected && h.onClientDisconnect != nil {
-				h.onClientDisconnect(client)
+			if on := h.handlers(client.room); wasConnected && on.OnClientDisconnect != nil {
+				on.OnClientDisconnect(client)
 			}
-			log.Printf("Client disconnected: %d total clients", clientCount)
+			logger.Info("Client disconnected", "clients", clientCount)
 
 		case message := <-h.broadcast:
 			h.mu.RLock()
+			sent := 0
 			for client := range h.clients {
 				select {
-				case client.send <- message:
+				case client.send <- message.data:
+					h.observeSend(message.msgType, len(message.data))
+					sent++
 				default:
 					close(client.send)
 					delete(h.clients, client)
 				}
 			}
 			h.mu.RUnlock()
+			h.observeBroadcast(message, sent)
 		}
 	}
 }
@@ -291,13 +356,48 @@ func (h *Hub) Broadcast(msgType MessageType, payload interface{}) error {
 		return err
 	}
 
-	h.broadcast <- msgBytes
+	h.broadcast <- broadcastMessage{msgType: msgType, data: msgBytes, queued: time.Now()}
 	return nil
 }
 
-// BroadcastState sends the current room state to all clients
+// BroadcastState sends the main room's state to its clients
 func (h *Hub) BroadcastState(state models.RoomState) error {
-	return h.Broadcast(MsgStateUpdate, state)
+	return h.broadcastRoomState(DefaultRoom, state)
+}
+
+// broadcastRoomState sends a room's current state to the clients in that room
+// Each client gets the projection for its own session, so guest data only reaches admins
+// Delta sync clients get a sequenced delta (or snapshot) instead of the full state
+func (h *Hub) broadcastRoomState(room string, state models.RoomState) error {
+	h.roomsMu.Lock()
+	if r := h.rooms[room]; r != nil {
+		r.lastState = &state
+	}
+	h.roomsMu.Unlock()
+
+	// Hold the read lock while sending so Run can't close a client's channel mid-send
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	start := time.Now()
+	sent := 0
+	defer func() {
+		h.observeBroadcast(broadcastMessage{msgType: MsgStateUpdate, queued: start}, sent)
+	}()
+	for client := range h.clients {
+		if client.room != room {
+			continue
+		}
+		sent++
+		if client.usesDeltaSync() {
+			client.sendState(ProjectRoomState(state, client.session))
+			continue
+		}
+		if err := h.SendTo(client, MsgStateUpdate, ProjectRoomState(state, client.session)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // SendTo sends a message to a specific client
@@ -319,34 +419,72 @@ func (h *Hub) SendTo(client *Client, msgType MessageType, payload interface{}) e
 
 	select {
 	case client.send <- msgBytes:
+		h.observeSend(msgType, len(msgBytes))
 		return nil
 	default:
 		return nil // Channel full, drop message
 	}
 }
 
+// SendToMartynKey sends a message to every connection of a session
+// Returns the number of connections the message was queued for
+func (h *Hub) SendToMartynKey(martynKey string, msgType MessageType, payload interface{}) int {
+	h.mu.RLock()
+	var targets []*Client
+	for client := range h.clients {
+		if client.session != nil && client.session.MartynKey == martynKey {
+			targets = append(targets, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range targets {
+		h.SendTo(client, msgType, payload)
+	}
+	return len(targets)
+}
+
 // ServeWS handles WebSocket upgrade requests
+// The room query parameter picks the room; clients without one join the main room
 func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
-	// Debug: Log incoming connection attempt
+	h.mu.RLock()
+	draining := h.draining
+	h.mu.RUnlock()
+	if draining {
+		w.Header().Set("Retry-After", "2")
+		http.Error(w, "Server restarting", http.StatusServiceUnavailable)
+		return
+	}
+
+	room := r.URL.Query().Get("room")
+	if room == "" {
+		room = DefaultRoom
+	}
+	if !h.HasRoom(room) {
+		http.Error(w, "Unknown room", http.StatusNotFound)
+		return
+	}
+
 	ipAddress := getClientIP(r)
 	userAgent := r.Header.Get("User-Agent")
-	log.Printf("[WS DEBUG] Connection attempt from %s (Origin: %s, UA: %s)",
-		ipAddress, r.Header.Get("Origin"), userAgent[:min(50, len(userAgent))])
+	logger.Debug("Connection attempt", "ip", ipAddress, "origin", r.Header.Get("Origin"), "user_agent", userAgent[:min(50, len(userAgent))])
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("[WS ERROR] WebSocket upgrade failed for %s: %v", ipAddress, err)
+		logger.Error("WebSocket upgrade failed", "ip", ipAddress, "err", err)
 		return
 	}
 
-	log.Printf("[WS DEBUG] WebSocket upgraded successfully for %s", ipAddress)
+	logger.Debug("WebSocket upgraded", "ip", ipAddress)
 
 	client := &Client{
 		hub:       h,
 		conn:      conn,
 		send:      make(chan []byte, 256),
+		room:      room,
 		ipAddress: ipAddress,
 		userAgent: userAgent,
+		done:      make(chan struct{}),
 	}
 
 	h.register <- client
@@ -386,42 +524,41 @@ func getClientIP(r *http.Request) string {
 	return host
 }
 
-// SetHandlers sets the message handler callbacks
+// SetHandlers sets the main room's message handler callbacks
 func (h *Hub) SetHandlers(handlers HubHandlers) {
-	h.onHandshake = handlers.OnHandshake
-	h.onSearch = handlers.OnSearch
-	h.onQueueAdd = handlers.OnQueueAdd
-	h.onQueueRemove = handlers.OnQueueRemove
-	h.onQueueMove = handlers.OnQueueMove
-	h.onQueueClear = handlers.OnQueueClear
-	h.onPlay = handlers.OnPlay
-	h.onPause = handlers.OnPause
-	h.onSkip = handlers.OnSkip
-	h.onSeek = handlers.OnSeek
-	h.onVocalAssist = handlers.OnVocalAssist
-	h.onVolume = handlers.OnVolume
-	h.onKeyChange = handlers.OnKeyChange
-	h.onTempoChange = handlers.OnTempoChange
-	h.onSetDisplayName = handlers.OnSetDisplayName
-	h.onAutoplay = handlers.OnAutoplay
-	h.onQueueShuffle = handlers.OnQueueShuffle
-	h.onQueueRequeue = handlers.OnQueueRequeue
-	h.onSetAFK = handlers.OnSetAFK
-	h.onAddFavorite = handlers.OnAddFavorite
-	h.onRemoveFavorite = handlers.OnRemoveFavorite
-	h.onAdminSetAdmin = handlers.OnAdminSetAdmin
-	h.onAdminKick = handlers.OnAdminKick
-	h.onAdminBlock = handlers.OnAdminBlock
-	h.onAdminUnblock = handlers.OnAdminUnblock
-	h.onAdminSetAFK = handlers.OnAdminSetAFK
-	h.onAdminPlayNext = handlers.OnAdminPlayNext
-	h.onAdminStartNow = handlers.OnAdminStartNow
-	h.onAdminStop = handlers.OnAdminStop
-	h.onAdminSetName = handlers.OnAdminSetName
-	h.onAdminSetNameLock = handlers.OnAdminSetNameLock
-	h.onAdminToggleBGM = handlers.OnAdminToggleBGM
-	h.onAdminSetMessage = handlers.OnAdminSetMessage
-	h.onClientDisconnect = handlers.OnClientDisconnect
+	h.setRoomHandlers(DefaultRoom, handlers)
+}
+
+// setRoomHandlers sets a room's message handler callbacks, adding the room if it's new
+func (h *Hub) setRoomHandlers(room string, handlers HubHandlers) {
+	h.roomsMu.Lock()
+	defer h.roomsMu.Unlock()
+	if r := h.rooms[room]; r != nil {
+		r.handlers = handlers
+		return
+	}
+	h.rooms[room] = &hubRoom{handlers: handlers}
+}
+
+// handlers returns a room's message handler callbacks
+// Clients of a removed room get no handlers, so their messages are ignored
+func (h *Hub) handlers(room string) HubHandlers {
+	h.roomsMu.RLock()
+	defer h.roomsMu.RUnlock()
+	if r := h.rooms[room]; r != nil {
+		return r.handlers
+	}
+	return HubHandlers{}
+}
+
+// roomState returns the state last broadcast to a room
+func (h *Hub) roomState(room string) *models.RoomState {
+	h.roomsMu.RLock()
+	defer h.roomsMu.RUnlock()
+	if r := h.rooms[room]; r != nil {
+		return r.lastState
+	}
+	return nil
 }
 
 // HubHandlers contains all handler callbacks
@@ -437,6 +574,11 @@ type HubHandlers struct {
 	OnSkip             func(client *Client)
 	OnSeek             func(client *Client, position float64)
 	OnVocalAssist      func(client *Client, level models.VocalAssistLevel)
+	OnVocalGain        func(client *Client, gain float64)
+	OnMicPreset        func(client *Client, preset models.MicPreset)
+	OnSetRecording     func(client *Client, enabled bool)
+	OnGetRecordings    func(client *Client)
+	OnReaction         func(client *Client, reaction string) error
 	OnVolume           func(client *Client, volume float64)
 	OnKeyChange        func(client *Client, semitones int)
 	OnTempoChange      func(client *Client, speed float64)
@@ -447,6 +589,9 @@ type HubHandlers struct {
 	OnSetAFK           func(client *Client, isAFK bool)
 	OnAddFavorite      func(client *Client, songID string)
 	OnRemoveFavorite   func(client *Client, songID string)
+	OnGetRecommendations func(client *Client)
+	OnPlaylist         func(client *Client, action MessageType, payload PlaylistPayload) error
+	OnAvatarGallery    func(client *Client, action MessageType, payload AvatarGalleryPayload) error
 	OnAdminSetAdmin    func(client *Client, martynKey string, isAdmin bool) error
 	OnAdminKick        func(client *Client, martynKey string, reason string) error
 	OnAdminBlock       func(client *Client, martynKey string, durationMinutes int, reason string) error
@@ -460,15 +605,16 @@ type HubHandlers struct {
 	OnAdminToggleBGM   func(client *Client) error
 	OnAdminSetMessage  func(client *Client, message string) error
 	OnClientDisconnect func(client *Client)
+	IsRoomAdmin        func(client *Client) bool // Optional: grants admin actions in this room only
 }
 
 // readPump pumps messages from the WebSocket to the hub
 func (c *Client) readPump() {
 	clientID := c.ipAddress // Use IP for logging before session is established
-	log.Printf("[WS DEBUG] readPump started for %s", clientID)
+	logger.Debug("readPump started", "ip", clientID)
 
 	defer func() {
-		log.Printf("[WS DEBUG] readPump closing for %s (session: %v)", clientID, c.session != nil)
+		logger.Debug("readPump closing", "ip", clientID, "session", c.session != nil)
 		c.hub.unregister <- c
 		c.conn.Close()
 	}()
@@ -478,22 +624,23 @@ func (c *Client) readPump() {
 		if err != nil {
 			// Log all close errors with details
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure, websocket.CloseNormalClosure) {
-				log.Printf("[WS ERROR] Unexpected close for %s: %v", clientID, err)
+				logger.Error("Unexpected close", "ip", clientID, "err", err)
 			} else {
-				log.Printf("[WS DEBUG] Connection closed for %s: %v", clientID, err)
+				logger.Debug("Connection closed", "ip", clientID, "err", err)
 			}
 			break
 		}
 
-		log.Printf("[WS DEBUG] Received message type %d from %s (%d bytes)", messageType, clientID, len(data))
+		logger.Debug("Received message", "ip", clientID, "frame", messageType, "bytes", len(data))
 
 		var msg Message
 		if err := json.Unmarshal(data, &msg); err != nil {
-			log.Printf("[WS ERROR] Invalid message format from %s: %v (data: %s)", clientID, err, string(data[:min(100, len(data))]))
+			logger.Error("Invalid message format", "ip", clientID, "err", err, "data", string(data[:min(100, len(data))]))
 			continue
 		}
 
-		log.Printf("[WS DEBUG] Processing message '%s' from %s", msg.Type, clientID)
+		c.hub.observeReceive(msg.Type, len(data))
+		logger.Debug("Processing message", "ip", clientID, "type", msg.Type)
 		c.handleMessage(msg)
 	}
 }
@@ -501,40 +648,56 @@ func (c *Client) readPump() {
 // writePump pumps messages from the hub to the WebSocket
 func (c *Client) writePump() {
 	clientID := c.ipAddress
-	log.Printf("[WS DEBUG] writePump started for %s", clientID)
+	logger.Debug("writePump started", "ip", clientID)
 
 	defer func() {
-		log.Printf("[WS DEBUG] writePump closing for %s", clientID)
+		logger.Debug("writePump closing", "ip", clientID)
 		c.conn.Close()
+		close(c.done)
 	}()
 
 	for message := range c.send {
-		log.Printf("[WS DEBUG] Sending message to %s (%d bytes)", clientID, len(message))
+		if message == nil {
+			c.conn.WriteControl(websocket.CloseMessage, c.closeFrame, time.Now().Add(time.Second))
+			return
+		}
+		logger.Debug("Sending message", "ip", clientID, "bytes", len(message))
 		if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
-			log.Printf("[WS ERROR] Write failed for %s: %v", clientID, err)
+			logger.Error("Write failed", "ip", clientID, "err", err)
 			return
 		}
 	}
-	log.Printf("[WS DEBUG] Send channel closed for %s", clientID)
+	logger.Debug("Send channel closed", "ip", clientID)
 }
 
 // handleMessage processes incoming messages
 func (c *Client) handleMessage(msg Message) {
+	on := c.hub.handlers(c.room)
 	switch msg.Type {
 	case MsgHandshake:
 		var payload HandshakePayload
 		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
-			log.Printf("Invalid handshake payload: %v", err)
+			logger.Warn("Invalid handshake payload", "err", err)
 			return
 		}
-		if c.hub.onHandshake != nil {
-			session, roomState := c.hub.onHandshake(c, payload)
+		if on.OnHandshake != nil {
+			session, roomState := on.OnHandshake(c, payload)
 			if session != nil {
 				c.session = session
-				c.hub.SendTo(c, MsgWelcome, WelcomePayload{
+				welcome := WelcomePayload{
 					Session:   *session,
-					RoomState: *roomState,
-				})
+					PublicID:  models.PublicID(session.MartynKey),
+					RoomState: ProjectRoomState(*roomState, session),
+				}
+				if payload.StateProtocol >= StateProtocolDelta {
+					welcome.Seq = c.resetState(welcome.RoomState)
+					c.hub.roomsMu.Lock()
+					if r := c.hub.rooms[c.room]; r != nil && r.lastState == nil {
+						r.lastState = roomState
+					}
+					c.hub.roomsMu.Unlock()
+				}
+				c.hub.SendTo(c, MsgWelcome, welcome)
 			} else {
 				// Session rejected (e.g., user is blocked) - close connection
 				c.conn.Close()
@@ -542,13 +705,21 @@ func (c *Client) handleMessage(msg Message) {
 			}
 		}
 
+	case MsgStateResync:
+		if !c.usesDeltaSync() || c.session == nil {
+			return
+		}
+		if state := c.hub.roomState(c.room); state != nil {
+			c.sendSnapshot(ProjectRoomState(*state, c.session))
+		}
+
 	case MsgSearch:
 		var query string
 		if err := json.Unmarshal(msg.Payload, &query); err != nil {
 			return
 		}
-		if c.hub.onSearch != nil {
-			c.hub.onSearch(c, query)
+		if on.OnSearch != nil {
+			on.OnSearch(c, query)
 		}
 
 	case MsgQueueAdd:
@@ -556,8 +727,8 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
 			return
 		}
-		if c.hub.onQueueAdd != nil {
-			c.hub.onQueueAdd(c, payload.SongID, payload.VocalAssist)
+		if on.OnQueueAdd != nil {
+			on.OnQueueAdd(c, payload.SongID, payload.VocalAssist)
 		}
 
 	case MsgQueueRemove:
@@ -565,8 +736,8 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &songID); err != nil {
 			return
 		}
-		if c.hub.onQueueRemove != nil {
-			c.hub.onQueueRemove(c, songID)
+		if on.OnQueueRemove != nil {
+			on.OnQueueRemove(c, songID)
 		}
 
 	case MsgQueueMove:
@@ -574,28 +745,28 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
 			return
 		}
-		if c.hub.onQueueMove != nil {
-			c.hub.onQueueMove(c, payload.From, payload.To)
+		if on.OnQueueMove != nil {
+			on.OnQueueMove(c, payload.From, payload.To)
 		}
 
 	case MsgQueueClear:
-		if c.hub.onQueueClear != nil {
-			c.hub.onQueueClear(c)
+		if on.OnQueueClear != nil {
+			on.OnQueueClear(c)
 		}
 
 	case MsgPlay:
-		if c.hub.onPlay != nil {
-			c.hub.onPlay(c)
+		if on.OnPlay != nil {
+			on.OnPlay(c)
 		}
 
 	case MsgPause:
-		if c.hub.onPause != nil {
-			c.hub.onPause(c)
+		if on.OnPause != nil {
+			on.OnPause(c)
 		}
 
 	case MsgSkip:
-		if c.hub.onSkip != nil {
-			c.hub.onSkip(c)
+		if on.OnSkip != nil {
+			on.OnSkip(c)
 		}
 
 	case MsgSeek:
@@ -603,8 +774,8 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &position); err != nil {
 			return
 		}
-		if c.hub.onSeek != nil {
-			c.hub.onSeek(c, position)
+		if on.OnSeek != nil {
+			on.OnSeek(c, position)
 		}
 
 	case MsgVocalAssist:
@@ -612,8 +783,57 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &level); err != nil {
 			return
 		}
-		if c.hub.onVocalAssist != nil {
-			c.hub.onVocalAssist(c, level)
+		if on.OnVocalAssist != nil {
+			on.OnVocalAssist(c, level)
+		}
+
+	case MsgVocalGain:
+		var gain float64
+		if err := json.Unmarshal(msg.Payload, &gain); err != nil {
+			return
+		}
+		if on.OnVocalGain != nil {
+			on.OnVocalGain(c, gain)
+		}
+
+	case MsgMicPreset:
+		var preset models.MicPreset
+		if err := json.Unmarshal(msg.Payload, &preset); err != nil {
+			return
+		}
+		if on.OnMicPreset != nil {
+			on.OnMicPreset(c, preset)
+		}
+
+	case MsgSetRecording:
+		var enabled bool
+		if err := json.Unmarshal(msg.Payload, &enabled); err != nil {
+			return
+		}
+		if on.OnSetRecording != nil {
+			on.OnSetRecording(c, enabled)
+		}
+
+	case MsgGetRecordings:
+		if c.session == nil {
+			return
+		}
+		if on.OnGetRecordings != nil {
+			on.OnGetRecordings(c)
+		}
+
+	case MsgReaction:
+		if c.session == nil {
+			return
+		}
+		var reaction string
+		if err := json.Unmarshal(msg.Payload, &reaction); err != nil {
+			return
+		}
+		if on.OnReaction != nil {
+			if err := on.OnReaction(c, reaction); err != nil {
+				c.hub.SendTo(c, MsgError, map[string]string{"error": err.Error()})
+			}
 		}
 
 	case MsgVolume:
@@ -621,13 +841,13 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &volume); err != nil {
 			return
 		}
-		if c.hub.onVolume != nil {
-			c.hub.onVolume(c, volume)
+		if on.OnVolume != nil {
+			on.OnVolume(c, volume)
 		}
 
 	case MsgKeyChange:
 		// Admin only - change pitch/key
-		if c.session == nil || !c.session.IsAdmin {
+		if !c.isAdmin(on) {
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Not authorized"})
 			return
 		}
@@ -635,13 +855,13 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &semitones); err != nil {
 			return
 		}
-		if c.hub.onKeyChange != nil {
-			c.hub.onKeyChange(c, semitones)
+		if on.OnKeyChange != nil {
+			on.OnKeyChange(c, semitones)
 		}
 
 	case MsgTempoChange:
 		// Admin only - change tempo/speed
-		if c.session == nil || !c.session.IsAdmin {
+		if !c.isAdmin(on) {
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Not authorized"})
 			return
 		}
@@ -649,8 +869,8 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &speed); err != nil {
 			return
 		}
-		if c.hub.onTempoChange != nil {
-			c.hub.onTempoChange(c, speed)
+		if on.OnTempoChange != nil {
+			on.OnTempoChange(c, speed)
 		}
 
 	case MsgSetDisplayName:
@@ -658,13 +878,13 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
 			return
 		}
-		if c.hub.onSetDisplayName != nil {
-			c.hub.onSetDisplayName(c, payload.DisplayName, payload.AvatarID, payload.AvatarConfig)
+		if on.OnSetDisplayName != nil {
+			on.OnSetDisplayName(c, payload.DisplayName, payload.AvatarID, payload.AvatarConfig)
 		}
 
 	case MsgAutoplay:
 		// Admin only - toggle autoplay
-		if c.session == nil || !c.session.IsAdmin {
+		if !c.isAdmin(on) {
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Not authorized"})
 			return
 		}
@@ -672,23 +892,23 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &enabled); err != nil {
 			return
 		}
-		if c.hub.onAutoplay != nil {
-			c.hub.onAutoplay(c, enabled)
+		if on.OnAutoplay != nil {
+			on.OnAutoplay(c, enabled)
 		}
 
 	case MsgQueueShuffle:
 		// Admin only - shuffle queue
-		if c.session == nil || !c.session.IsAdmin {
+		if !c.isAdmin(on) {
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Not authorized"})
 			return
 		}
-		if c.hub.onQueueShuffle != nil {
-			c.hub.onQueueShuffle(c)
+		if on.OnQueueShuffle != nil {
+			on.OnQueueShuffle(c)
 		}
 
 	case MsgQueueRequeue:
 		// Admin only - re-add song from history with new user
-		if c.session == nil || !c.session.IsAdmin {
+		if !c.isAdmin(on) {
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Not authorized"})
 			return
 		}
@@ -699,8 +919,8 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
 			return
 		}
-		if c.hub.onQueueRequeue != nil {
-			c.hub.onQueueRequeue(c, payload.SongID, payload.MartynKey)
+		if on.OnQueueRequeue != nil {
+			on.OnQueueRequeue(c, payload.SongID, payload.MartynKey)
 		}
 
 	case MsgSetAFK:
@@ -712,8 +932,8 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &isAFK); err != nil {
 			return
 		}
-		if c.hub.onSetAFK != nil {
-			c.hub.onSetAFK(c, isAFK)
+		if on.OnSetAFK != nil {
+			on.OnSetAFK(c, isAFK)
 		}
 
 	case MsgAddFavorite:
@@ -725,8 +945,8 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &songID); err != nil {
 			return
 		}
-		if c.hub.onAddFavorite != nil {
-			c.hub.onAddFavorite(c, songID)
+		if on.OnAddFavorite != nil {
+			on.OnAddFavorite(c, songID)
 		}
 
 	case MsgRemoveFavorite:
@@ -738,12 +958,55 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &songID); err != nil {
 			return
 		}
-		if c.hub.onRemoveFavorite != nil {
-			c.hub.onRemoveFavorite(c, songID)
+		if on.OnRemoveFavorite != nil {
+			on.OnRemoveFavorite(c, songID)
+		}
+
+	case MsgGetRecommendations:
+		// User asks for song suggestions
+		if c.session == nil {
+			return
+		}
+		if on.OnGetRecommendations != nil {
+			on.OnGetRecommendations(c)
+		}
+
+	case MsgPlaylistList, MsgPlaylistCreate, MsgPlaylistRename, MsgPlaylistDelete,
+		MsgPlaylistAddSong, MsgPlaylistRemoveSong, MsgPlaylistMoveSong,
+		MsgPlaylistShare, MsgPlaylistUnshare, MsgPlaylistShareLink,
+		MsgPlaylistJoin, MsgPlaylistQueue:
+		// Playlist actions need a session; permissions are checked per playlist
+		if c.session == nil {
+			return
+		}
+		var payload PlaylistPayload
+		if len(msg.Payload) > 0 {
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				return
+			}
+		}
+		if on.OnPlaylist != nil {
+			if err := on.OnPlaylist(c, msg.Type, payload); err != nil {
+				c.hub.SendTo(c, MsgError, map[string]string{"error": err.Error()})
+			}
+		}
+
+	case MsgAvatarSave, MsgAvatarUse, MsgAvatarDelete:
+		if c.session == nil {
+			return
+		}
+		var payload AvatarGalleryPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return
+		}
+		if on.OnAvatarGallery != nil {
+			if err := on.OnAvatarGallery(c, msg.Type, payload); err != nil {
+				c.hub.SendTo(c, MsgError, map[string]string{"error": err.Error()})
+			}
 		}
 
 	case MsgAdminSetAdmin:
-		// Check if client is admin
+		// Venue admins only - sessions are shared by every room
 		if c.session == nil || !c.session.IsAdmin {
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Not authorized"})
 			return
@@ -752,15 +1015,15 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
 			return
 		}
-		if c.hub.onAdminSetAdmin != nil {
-			if err := c.hub.onAdminSetAdmin(c, payload.MartynKey, payload.IsAdmin); err != nil {
+		if on.OnAdminSetAdmin != nil {
+			if err := on.OnAdminSetAdmin(c, payload.MartynKey, payload.IsAdmin); err != nil {
 				c.hub.SendTo(c, MsgError, map[string]string{"error": err.Error()})
 			}
 		}
 
 	case MsgAdminKick:
 		// Check if client is admin
-		if c.session == nil || !c.session.IsAdmin {
+		if !c.isAdmin(on) {
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Not authorized"})
 			return
 		}
@@ -768,14 +1031,14 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
 			return
 		}
-		if c.hub.onAdminKick != nil {
-			if err := c.hub.onAdminKick(c, payload.MartynKey, payload.Reason); err != nil {
+		if on.OnAdminKick != nil {
+			if err := on.OnAdminKick(c, payload.MartynKey, payload.Reason); err != nil {
 				c.hub.SendTo(c, MsgError, map[string]string{"error": err.Error()})
 			}
 		}
 
 	case MsgAdminBlock:
-		// Check if client is admin
+		// Venue admins only - sessions are shared by every room
 		if c.session == nil || !c.session.IsAdmin {
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Not authorized"})
 			return
@@ -784,14 +1047,14 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
 			return
 		}
-		if c.hub.onAdminBlock != nil {
-			if err := c.hub.onAdminBlock(c, payload.MartynKey, payload.Duration, payload.Reason); err != nil {
+		if on.OnAdminBlock != nil {
+			if err := on.OnAdminBlock(c, payload.MartynKey, payload.Duration, payload.Reason); err != nil {
 				c.hub.SendTo(c, MsgError, map[string]string{"error": err.Error()})
 			}
 		}
 
 	case MsgAdminUnblock:
-		// Check if client is admin
+		// Venue admins only - sessions are shared by every room
 		if c.session == nil || !c.session.IsAdmin {
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Not authorized"})
 			return
@@ -800,15 +1063,15 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
 			return
 		}
-		if c.hub.onAdminUnblock != nil {
-			if err := c.hub.onAdminUnblock(c, payload.MartynKey); err != nil {
+		if on.OnAdminUnblock != nil {
+			if err := on.OnAdminUnblock(c, payload.MartynKey); err != nil {
 				c.hub.SendTo(c, MsgError, map[string]string{"error": err.Error()})
 			}
 		}
 
 	case MsgAdminSetAFK:
 		// Check if client is admin
-		if c.session == nil || !c.session.IsAdmin {
+		if !c.isAdmin(on) {
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Not authorized"})
 			return
 		}
@@ -816,67 +1079,64 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
 			return
 		}
-		if c.hub.onAdminSetAFK != nil {
-			if err := c.hub.onAdminSetAFK(c, payload.MartynKey, payload.IsAFK); err != nil {
+		if on.OnAdminSetAFK != nil {
+			if err := on.OnAdminSetAFK(c, payload.MartynKey, payload.IsAFK); err != nil {
 				c.hub.SendTo(c, MsgError, map[string]string{"error": err.Error()})
 			}
 		}
 
 	case MsgAdminPlayNext:
-		log.Printf("[DEBUG] MsgAdminPlayNext received from %s", c.conn.RemoteAddr())
+		logger.Debug("Admin play next received", "addr", c.conn.RemoteAddr())
 		// Check if client is admin
 		if c.session == nil {
-			log.Printf("[DEBUG] MsgAdminPlayNext REJECTED: session is nil")
+			logger.Debug("Admin play next rejected: no session")
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Not authorized - no session"})
 			return
 		}
-		if !c.session.IsAdmin {
-			log.Printf("[DEBUG] MsgAdminPlayNext REJECTED: user %s is not admin (IsAdmin=%v)", c.session.DisplayName, c.session.IsAdmin)
+		if !c.isAdmin(on) {
+			logger.Debug("Admin play next rejected: not admin", "user", c.session.DisplayName)
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Not authorized - not admin"})
 			return
 		}
-		log.Printf("[DEBUG] MsgAdminPlayNext AUTHORIZED for admin %s", c.session.DisplayName)
-		if c.hub.onAdminPlayNext != nil {
-			log.Printf("[DEBUG] Calling onAdminPlayNext handler")
-			if err := c.hub.onAdminPlayNext(c); err != nil {
-				log.Printf("[DEBUG] onAdminPlayNext returned error: %v", err)
+		logger.Debug("Admin play next authorized", "user", c.session.DisplayName)
+		if on.OnAdminPlayNext != nil {
+			if err := on.OnAdminPlayNext(c); err != nil {
+				logger.Warn("Admin play next failed", "err", err)
 				c.hub.SendTo(c, MsgError, map[string]string{"error": err.Error()})
-			} else {
-				log.Printf("[DEBUG] onAdminPlayNext completed successfully")
 			}
 		} else {
-			log.Printf("[DEBUG] onAdminPlayNext handler is nil!")
+			logger.Warn("Admin play next has no handler")
 		}
 
 	case MsgAdminStartNow:
-		log.Printf("[DEBUG] MsgAdminStartNow received from %s", c.conn.RemoteAddr())
+		logger.Debug("Admin start now received", "addr", c.conn.RemoteAddr())
 		// Check if client is admin
-		if c.session == nil || !c.session.IsAdmin {
+		if !c.isAdmin(on) {
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Not authorized"})
 			return
 		}
-		log.Printf("[DEBUG] MsgAdminStartNow AUTHORIZED for admin %s", c.session.DisplayName)
-		if c.hub.onAdminStartNow != nil {
-			if err := c.hub.onAdminStartNow(c); err != nil {
+		logger.Debug("Admin start now authorized", "user", c.session.DisplayName)
+		if on.OnAdminStartNow != nil {
+			if err := on.OnAdminStartNow(c); err != nil {
 				c.hub.SendTo(c, MsgError, map[string]string{"error": err.Error()})
 			}
 		}
 
 	case MsgAdminStop:
 		// Check if client is admin
-		if c.session == nil || !c.session.IsAdmin {
+		if !c.isAdmin(on) {
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Not authorized"})
 			return
 		}
-		if c.hub.onAdminStop != nil {
-			if err := c.hub.onAdminStop(c); err != nil {
+		if on.OnAdminStop != nil {
+			if err := on.OnAdminStop(c); err != nil {
 				c.hub.SendTo(c, MsgError, map[string]string{"error": err.Error()})
 			}
 		}
 
 	case MsgAdminSetName:
 		// Check if client is admin
-		if c.session == nil || !c.session.IsAdmin {
+		if !c.isAdmin(on) {
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Not authorized"})
 			return
 		}
@@ -888,15 +1148,15 @@ func (c *Client) handleMessage(msg Message) {
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Missing martyn_key or display_name"})
 			return
 		}
-		if c.hub.onAdminSetName != nil {
-			if err := c.hub.onAdminSetName(c, payload.MartynKey, payload.DisplayName); err != nil {
+		if on.OnAdminSetName != nil {
+			if err := on.OnAdminSetName(c, payload.MartynKey, payload.DisplayName); err != nil {
 				c.hub.SendTo(c, MsgError, map[string]string{"error": err.Error()})
 			}
 		}
 
 	case MsgAdminSetNameLock:
 		// Check if client is admin
-		if c.session == nil || !c.session.IsAdmin {
+		if !c.isAdmin(on) {
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Not authorized"})
 			return
 		}
@@ -908,30 +1168,30 @@ func (c *Client) handleMessage(msg Message) {
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Missing martyn_key"})
 			return
 		}
-		if c.hub.onAdminSetNameLock != nil {
-			if err := c.hub.onAdminSetNameLock(c, payload.MartynKey, payload.Locked); err != nil {
+		if on.OnAdminSetNameLock != nil {
+			if err := on.OnAdminSetNameLock(c, payload.MartynKey, payload.Locked); err != nil {
 				c.hub.SendTo(c, MsgError, map[string]string{"error": err.Error()})
 			}
 		}
 
 	case MsgAdminToggleBGM:
-		log.Printf("[DEBUG] MsgAdminToggleBGM received from %s", c.conn.RemoteAddr())
+		logger.Debug("Admin toggle BGM received", "addr", c.conn.RemoteAddr())
 		// Check if client is admin
-		if c.session == nil || !c.session.IsAdmin {
-			log.Printf("[DEBUG] MsgAdminToggleBGM REJECTED - not admin")
+		if !c.isAdmin(on) {
+			logger.Debug("Admin toggle BGM rejected: not admin")
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Admin access required"})
 			return
 		}
-		log.Printf("[DEBUG] MsgAdminToggleBGM AUTHORIZED for admin %s", c.session.DisplayName)
-		if c.hub.onAdminToggleBGM != nil {
-			if err := c.hub.onAdminToggleBGM(c); err != nil {
+		logger.Debug("Admin toggle BGM authorized", "user", c.session.DisplayName)
+		if on.OnAdminToggleBGM != nil {
+			if err := on.OnAdminToggleBGM(c); err != nil {
 				c.hub.SendTo(c, MsgError, map[string]string{"error": err.Error()})
 			}
 		}
 
 	case MsgAdminSetMessage:
 		// Check if client is admin
-		if c.session == nil || !c.session.IsAdmin {
+		if !c.isAdmin(on) {
 			c.hub.SendTo(c, MsgError, map[string]string{"error": "Admin access required"})
 			return
 		}
@@ -939,14 +1199,23 @@ func (c *Client) handleMessage(msg Message) {
 		if err := json.Unmarshal(msg.Payload, &message); err != nil {
 			return
 		}
-		if c.hub.onAdminSetMessage != nil {
-			if err := c.hub.onAdminSetMessage(c, message); err != nil {
+		if on.OnAdminSetMessage != nil {
+			if err := on.OnAdminSetMessage(c, message); err != nil {
 				c.hub.SendTo(c, MsgError, map[string]string{"error": err.Error()})
 			}
 		}
 	}
 }
 
+// isAdmin reports whether the client may run admin actions in its room
+// Venue admins can act anywhere; room admins only in their own room
+func (c *Client) isAdmin(on HubHandlers) bool {
+	if c.session == nil {
+		return false
+	}
+	return c.session.IsAdmin || (on.IsRoomAdmin != nil && on.IsRoomAdmin(c))
+}
+
 // GetSession returns the client's session
 func (c *Client) GetSession() *models.Session {
 	return c.session
@@ -962,6 +1231,11 @@ func (c *Client) GetUserAgent() string {
 	return c.userAgent
 }
 
+// GetRoom returns the ID of the room the client joined
+func (c *Client) GetRoom() string {
+	return c.room
+}
+
 // GetConnectedClients returns info about all connected clients (deduplicated by MartynKey)
 func (h *Hub) GetConnectedClients() []ClientInfo {
 	h.mu.RLock()
@@ -984,6 +1258,7 @@ func (h *Hub) GetConnectedClients() []ClientInfo {
 				DeviceBrowser: deviceInfo.Browser,
 				IPAddress:     client.ipAddress,
 				IsAdmin:       client.session.IsAdmin,
+				Room:          client.room,
 				IsOnline:      true,
 				IsAFK:         client.session.IsAFK,
 				AvatarConfig:  client.session.AvatarConfig,
@@ -1035,34 +1310,56 @@ func (h *Hub) DisconnectAll(reason string) {
 	}
 }
 
-// BroadcastToAdmins sends a message only to admin clients
-func (h *Hub) BroadcastToAdmins(msgType MessageType, payload interface{}) error {
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return err
+// Drain tells every client the server is going away, then closes each connection with a
+// "service restart" close frame once the notice is written (or timeout passes)
+// New connections are refused until Resume
+func (h *Hub) Drain(payload RestartPayload, timeout time.Duration) {
+	// Close frames are limited to 123 bytes, reason included
+	reason := payload.Reason
+	if len(reason) > 100 {
+		reason = reason[:100]
 	}
+	closeFrame := websocket.FormatCloseMessage(websocket.CloseServiceRestart, reason)
 
-	msg := Message{
-		Type:    msgType,
-		Payload: payloadBytes,
+	// Hold the read lock while sending so Run can't close a client's channel mid-send
+	h.mu.Lock()
+	h.draining = true
+	h.mu.Unlock()
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for client := range h.clients {
+		client.closeFrame = closeFrame
+		h.SendTo(client, MsgRestarting, payload)
+		select {
+		case client.send <- nil: // writePump closes the connection after the notice
+		default:
+		}
+		clients = append(clients, client)
 	}
+	h.mu.RUnlock()
 
-	msgBytes, err := json.Marshal(msg)
-	if err != nil {
-		return err
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for _, client := range clients {
+		select {
+		case <-client.done:
+		case <-ctx.Done():
+		}
+		client.conn.Close()
+	}
+	if len(clients) > 0 {
+		logger.Info("Drained WebSocket clients", "clients", len(clients), "reason", payload.Reason)
 	}
+}
 
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+// Resume accepts new connections again after Drain
+func (h *Hub) Resume() {
+	h.mu.Lock()
+	h.draining = false
+	h.mu.Unlock()
+}
 
-	for client := range h.clients {
-		if client.session != nil && client.session.IsAdmin {
-			select {
-			case client.send <- msgBytes:
-			default:
-				// Channel full, skip
-			}
-		}
-	}
-	return nil
+// BroadcastToAdmins sends a message only to admin clients (venue admins and main room admins)
+func (h *Hub) BroadcastToAdmins(msgType MessageType, payload interface{}) error {
+	return h.broadcastToAdmins(DefaultRoom, msgType, payload)
 }
@@ -0,0 +1,235 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// waitForConnections waits for the hub to register n connections
+func waitForConnections(t *testing.T, h *Hub, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for h.ConnectionCount() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d connections, have %d", n, h.ConnectionCount())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// ============================================================================
+// Drain Tests
+// ============================================================================
+
+func TestDrainNotifiesAndClosesClients(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+	srv := httptest.NewServer(http.HandlerFunc(h.ServeWS))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	waitForConnections(t, h, 1)
+
+	h.Drain(RestartPayload{Reason: "Server restarting", ReconnectMs: 2000, URL: "https://karaoke.local:9443"}, time.Second)
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Expected the restart notice: %v", err)
+	}
+	if msg.Type != MsgRestarting {
+		t.Fatalf("Expected %s, got %s", MsgRestarting, msg.Type)
+	}
+	var payload RestartPayload
+	json.Unmarshal(msg.Payload, &payload)
+	if payload.ReconnectMs != 2000 || payload.URL != "https://karaoke.local:9443" {
+		t.Errorf("Expected the reconnect hint, got %+v", payload)
+	}
+
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseServiceRestart) {
+		t.Errorf("Expected a service restart close, got %v", err)
+	}
+
+	// New connections wait until the server is back
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 while draining, got %v", err)
+	}
+	h.Resume()
+	again, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Expected connections after Resume: %v", err)
+	}
+	again.Close()
+}
+
+// ============================================================================
+// Observer Tests
+// ============================================================================
+
+func TestObserverSeesTraffic(t *testing.T) {
+	var mu sync.Mutex
+	received := map[MessageType]int{}
+	sent := map[MessageType]int{}
+	var broadcasts []int
+
+	h := NewHub()
+	h.SetObserver(Observer{
+		OnReceive: func(msgType MessageType, bytes int) {
+			mu.Lock()
+			defer mu.Unlock()
+			received[msgType]++
+		},
+		OnSend: func(msgType MessageType, bytes int) {
+			mu.Lock()
+			defer mu.Unlock()
+			sent[msgType]++
+			if bytes == 0 {
+				t.Errorf("Expected a message size for %s", msgType)
+			}
+		},
+		OnBroadcast: func(msgType MessageType, clients int, took time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			broadcasts = append(broadcasts, clients)
+		},
+	})
+	go h.Run()
+	srv := httptest.NewServer(http.HandlerFunc(h.ServeWS))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	for i := 0; i < 2; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer conn.Close()
+		if i == 0 {
+			conn.WriteJSON(Message{Type: MsgPlay})
+		}
+	}
+	waitForConnections(t, h, 2)
+
+	h.Broadcast(MsgError, map[string]string{"error": "test"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		done := len(broadcasts) == 1 && received[MsgPlay] == 1
+		mu.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for observer events")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if broadcasts[0] != 2 {
+		t.Errorf("Expected broadcast to reach 2 clients, got %d", broadcasts[0])
+	}
+	if sent[MsgError] != 2 {
+		t.Errorf("Expected 2 sends of %s, got %d", MsgError, sent[MsgError])
+	}
+}
+
+func TestObserverCapsIncomingTypes(t *testing.T) {
+	var got []MessageType
+	h := NewHub()
+	h.SetObserver(Observer{OnReceive: func(msgType MessageType, bytes int) {
+		got = append(got, msgType)
+	}})
+
+	for i := 0; i < maxReceivedTypes; i++ {
+		h.observeReceive(MessageType("type_"+strconv.Itoa(i)), 10)
+	}
+	h.observeReceive("one_too_many", 10)
+	h.observeReceive("type_0", 10)
+
+	if got[maxReceivedTypes] != MsgTypeOther {
+		t.Errorf("Expected %s past the cap, got %s", MsgTypeOther, got[maxReceivedTypes])
+	}
+	if got[maxReceivedTypes+1] != "type_0" {
+		t.Errorf("Expected known types to keep their name, got %s", got[maxReceivedTypes+1])
+	}
+}
+
+// ============================================================================
+// Room Tests
+// ============================================================================
+
+func TestRoomsRouteJoinsAndState(t *testing.T) {
+	h := NewHub()
+	main := newTestClient(h, nil)
+	go h.Run()
+	srv := httptest.NewServer(http.HandlerFunc(h.ServeWS))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "?room=bar"
+
+	// Rooms have to exist before anyone can join them
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected 404 for an unknown room, got %v", err)
+	}
+
+	bar := h.Room("bar")
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	waitForConnections(t, h, 2)
+	if counts := h.RoomCounts(); counts["bar"] != 1 || counts[DefaultRoom] != 1 {
+		t.Errorf("Expected one connection in each room, got %v", counts)
+	}
+
+	// State only reaches the room it belongs to
+	if err := bar.BroadcastState(newProjectionTestState()); err != nil {
+		t.Fatalf("BroadcastState failed: %v", err)
+	}
+	select {
+	case <-main.send:
+		t.Error("Expected the main room not to get bar's state")
+	default:
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg Message
+	if err := conn.ReadJSON(&msg); err != nil || msg.Type != MsgStateUpdate {
+		t.Fatalf("Expected bar's state, got %s (%v)", msg.Type, err)
+	}
+
+	// Removing the room sends its guests away
+	h.RemoveRoom("bar", "This room has closed")
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	waitForConnections(t, h, 1)
+	if h.HasRoom("bar") {
+		t.Error("Expected bar to be gone")
+	}
+	h.RemoveRoom(DefaultRoom, "")
+	if !h.HasRoom(DefaultRoom) {
+		t.Error("Expected the main room to stay")
+	}
+}
@@ -0,0 +1,68 @@
+package websocket
+
+import "time"
+
+// maxReceivedTypes caps how many distinct incoming message types are reported,
+// since clients choose them; the rest are reported as MsgTypeOther
+const maxReceivedTypes = 64
+
+// MsgTypeOther stands in for incoming message types past maxReceivedTypes
+const MsgTypeOther MessageType = "other"
+
+// Observer receives hub traffic events for metrics. Any hook may be nil.
+// Hooks run on the sending or receiving goroutine and must not block.
+type Observer struct {
+	OnReceive   func(msgType MessageType, bytes int)                       // A client message was parsed
+	OnSend      func(msgType MessageType, bytes int)                       // A message was queued for one client
+	OnBroadcast func(msgType MessageType, clients int, took time.Duration) // A broadcast reached every client
+}
+
+// broadcastMessage is a Broadcast waiting for Run to fan it out
+type broadcastMessage struct {
+	msgType MessageType
+	data    []byte
+	queued  time.Time
+}
+
+// SetObserver installs metrics hooks; call it before Run
+func (h *Hub) SetObserver(o Observer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.observer = o
+	h.receivedTypes = make(map[MessageType]bool)
+}
+
+// ConnectionCount returns the number of open WebSocket connections
+func (h *Hub) ConnectionCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+func (h *Hub) observeSend(msgType MessageType, bytes int) {
+	if h.observer.OnSend != nil {
+		h.observer.OnSend(msgType, bytes)
+	}
+}
+
+func (h *Hub) observeBroadcast(msg broadcastMessage, clients int) {
+	if h.observer.OnBroadcast != nil {
+		h.observer.OnBroadcast(msg.msgType, clients, time.Since(msg.queued))
+	}
+}
+
+func (h *Hub) observeReceive(msgType MessageType, bytes int) {
+	if h.observer.OnReceive == nil {
+		return
+	}
+	h.receivedMu.Lock()
+	if !h.receivedTypes[msgType] {
+		if len(h.receivedTypes) >= maxReceivedTypes {
+			msgType = MsgTypeOther
+		} else {
+			h.receivedTypes[msgType] = true
+		}
+	}
+	h.receivedMu.Unlock()
+	h.observer.OnReceive(msgType, bytes)
+}
@@ -0,0 +1,78 @@
+package websocket
+
+import (
+	"songmartyn/pkg/models"
+)
+
+// ProjectRoomState returns the room state a viewer is allowed to see
+// Admins get the full state; everyone else gets public profiles, plus their
+// own personal settings on their own entry. A nil viewer is treated as a guest.
+// MartynKeys are credentials, so guests only ever see their own: other singers
+// appear by PublicID, including who queued each song.
+func ProjectRoomState(state models.RoomState, viewer *models.Session) interface{} {
+	if viewer != nil && viewer.IsAdmin {
+		return state
+	}
+
+	viewerKey := ""
+	if viewer != nil {
+		viewerKey = viewer.MartynKey
+	}
+
+	sessions := make([]models.SessionView, 0, len(state.Sessions))
+	for _, s := range state.Sessions {
+		sessions = append(sessions, projectSession(s, s.MartynKey == viewerKey && viewerKey != ""))
+	}
+
+	player := state.Player
+	if player.CurrentSong != nil {
+		song := projectSong(*player.CurrentSong)
+		player.CurrentSong = &song
+	}
+	queue := state.Queue
+	queue.Songs = make([]models.Song, len(state.Queue.Songs))
+	for i, song := range state.Queue.Songs {
+		queue.Songs[i] = projectSong(song)
+	}
+	countdown := state.Countdown
+	countdown.NextSingerKey = models.PublicID(countdown.NextSingerKey)
+
+	return models.RoomStateView{
+		Player:    player,
+		Queue:     queue,
+		Sessions:  sessions,
+		Countdown: countdown,
+	}
+}
+
+// projectSong replaces who queued a song with their PublicID
+func projectSong(song models.Song) models.Song {
+	song.AddedBy = models.PublicID(song.AddedBy)
+	return song
+}
+
+// projectSession strips a session down to what a non-admin viewer may see
+func projectSession(s models.Session, self bool) models.SessionView {
+	view := models.SessionView{
+		PublicID:     models.PublicID(s.MartynKey),
+		DisplayName:  s.DisplayName,
+		AvatarID:     s.AvatarID,
+		AvatarConfig: s.AvatarConfig,
+		IsOnline:     s.IsOnline,
+		IsAFK:        s.IsAFK,
+	}
+	if self {
+		view.MartynKey = s.MartynKey
+		view.VocalAssist = s.VocalAssist
+		view.VocalGain = s.VocalGain
+		view.SearchHistory = s.SearchHistory
+		view.Favorites = s.Favorites
+		if view.Favorites == nil {
+			view.Favorites = []string{}
+		}
+		view.SavedAvatars = s.SavedAvatars
+		view.NameLocked = s.NameLocked
+		view.IsAdmin = s.IsAdmin
+	}
+	return view
+}
@@ -0,0 +1,263 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"songmartyn/pkg/models"
+)
+
+// Sensitive values that must only ever reach admin clients
+const (
+	secretIP     = "203.0.113.77"
+	secretUA     = "SecretBrowser/9.9 (SecretOS)"
+	secretDevice = "Bobs-Secret-Phone"
+)
+
+func newProjectionTestState() models.RoomState {
+	return models.RoomState{
+		Sessions: []models.Session{
+			{
+				MartynKey:     "key-alice",
+				DisplayName:   "Alice",
+				IPAddress:     "198.51.100.1",
+				UserAgent:     "AliceAgent/1.0",
+				DeviceName:    "Alices-Phone",
+				Favorites:     []string{"song-alice"},
+				SearchHistory: []string{"alice search"},
+				SavedAvatars:  []models.SavedAvatar{{Name: "Party"}},
+				IsOnline:      true,
+			},
+			{
+				MartynKey:     "key-bob",
+				DisplayName:   "Bob",
+				IPAddress:     secretIP,
+				UserAgent:     secretUA,
+				DeviceName:    secretDevice,
+				IsAdmin:       true,
+				Favorites:     []string{"song-bob"},
+				SearchHistory: []string{"bob search"},
+				SavedAvatars:  []models.SavedAvatar{{Name: "Work"}},
+				IsOnline:      true,
+			},
+		},
+		Player: models.PlayerState{
+			CurrentSong: &models.Song{ID: "song-1", AddedBy: "key-bob"},
+		},
+		Queue: models.QueueState{
+			Songs: []models.Song{
+				{ID: "song-1", AddedBy: "key-bob"},
+				{ID: "song-2", AddedBy: "key-alice"},
+			},
+		},
+		Countdown: models.CountdownState{Active: true, NextSongID: "song-2", NextSingerKey: "key-alice"},
+	}
+}
+
+// assertNoSensitiveFields fails if any admin-only data appears in a payload
+func assertNoSensitiveFields(t *testing.T, label string, payload []byte) {
+	t.Helper()
+	body := string(payload)
+	for _, secret := range []string{
+		secretIP, secretUA, secretDevice, "198.51.100.1", "AliceAgent", "Alices-Phone",
+		`"ip_address"`, `"user_agent"`, `"device_name"`,
+	} {
+		if strings.Contains(body, secret) {
+			t.Errorf("%s: payload leaks %q: %s", label, secret, body)
+		}
+	}
+}
+
+// ============================================================================
+// Projection Tests
+// ============================================================================
+
+func TestProjectRoomStateGuest(t *testing.T) {
+	state := newProjectionTestState()
+
+	data, err := json.Marshal(ProjectRoomState(state, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNoSensitiveFields(t, "guest", data)
+
+	if strings.Contains(string(data), `"is_admin"`) {
+		t.Errorf("Guest payload should not contain admin flags: %s", data)
+	}
+	if strings.Contains(string(data), "song-bob") || strings.Contains(string(data), "bob search") {
+		t.Errorf("Guest payload should not contain other singers' favorites or searches: %s", data)
+	}
+	if !strings.Contains(string(data), `"display_name":"Bob"`) {
+		t.Errorf("Guest payload should keep public profiles: %s", data)
+	}
+}
+
+func TestProjectRoomStateSelf(t *testing.T) {
+	state := newProjectionTestState()
+	viewer := state.Sessions[0] // Alice, not an admin
+
+	view, ok := ProjectRoomState(state, &viewer).(models.RoomStateView)
+	if !ok {
+		t.Fatal("Expected a RoomStateView for a non-admin viewer")
+	}
+
+	var self, other models.SessionView
+	for _, s := range view.Sessions {
+		if s.MartynKey == "key-alice" {
+			self = s
+		} else {
+			other = s
+		}
+	}
+
+	if len(self.Favorites) != 1 || self.Favorites[0] != "song-alice" {
+		t.Errorf("Expected own favorites, got %v", self.Favorites)
+	}
+	if len(self.SearchHistory) != 1 {
+		t.Errorf("Expected own search history, got %v", self.SearchHistory)
+	}
+	if len(self.SavedAvatars) != 1 || self.SavedAvatars[0].Name != "Party" {
+		t.Errorf("Expected own saved avatars, got %v", self.SavedAvatars)
+	}
+	if other.Favorites != nil || other.SearchHistory != nil || other.SavedAvatars != nil {
+		t.Errorf("Expected other singer's personal data to be stripped, got %+v", other)
+	}
+	if other.IsAdmin {
+		t.Error("Non-admin should not learn who the admins are")
+	}
+
+	data, _ := json.Marshal(view)
+	assertNoSensitiveFields(t, "self", data)
+	if strings.Contains(string(data), `"is_admin"`) {
+		t.Errorf("Non-admin self payload should not contain admin flags: %s", data)
+	}
+}
+
+func TestProjectRoomStateHidesOtherKeys(t *testing.T) {
+	state := newProjectionTestState()
+	viewer := state.Sessions[0] // Alice, not an admin
+
+	guest, _ := json.Marshal(ProjectRoomState(state, nil))
+	self, _ := json.Marshal(ProjectRoomState(state, &viewer))
+	for _, tt := range []struct {
+		label   string
+		payload []byte
+		hidden  []string
+	}{
+		{"guest", guest, []string{"key-alice", "key-bob"}},
+		{"self", self, []string{"key-bob"}},
+	} {
+		for _, key := range tt.hidden {
+			if strings.Contains(string(tt.payload), key) {
+				t.Errorf("%s payload leaks MartynKey %q: %s", tt.label, key, tt.payload)
+			}
+		}
+	}
+
+	view := ProjectRoomState(state, &viewer).(models.RoomStateView)
+	if view.Sessions[0].MartynKey != "key-alice" {
+		t.Errorf("Expected the viewer's own key on their entry, got %q", view.Sessions[0].MartynKey)
+	}
+	bob := models.PublicID("key-bob")
+	if view.Sessions[1].PublicID != bob || view.Queue.Songs[0].AddedBy != bob || view.Player.CurrentSong.AddedBy != bob {
+		t.Errorf("Expected Bob to appear by his public ID %q everywhere", bob)
+	}
+	if view.Countdown.NextSingerKey != models.PublicID("key-alice") {
+		t.Errorf("Expected the next singer by public ID, got %q", view.Countdown.NextSingerKey)
+	}
+	if state.Queue.Songs[0].AddedBy != "key-bob" || state.Player.CurrentSong.AddedBy != "key-bob" {
+		t.Error("Projection should not modify the room state")
+	}
+}
+
+func TestProjectedQueueIdentifiesSingers(t *testing.T) {
+	state := newProjectionTestState()
+	viewer := state.Sessions[0] // Alice, not an admin
+	view := ProjectRoomState(state, &viewer).(models.RoomStateView)
+
+	// Guests look singers up by public ID and spot their own songs by the welcome's public ID
+	names := make(map[string]string)
+	for _, s := range view.Sessions {
+		names[s.PublicID] = s.DisplayName
+	}
+	own := models.PublicID(viewer.MartynKey)
+	for i, want := range []string{"Bob", "Alice"} {
+		song := view.Queue.Songs[i]
+		if names[song.AddedBy] != want {
+			t.Errorf("Expected %s queued by %s, got %q", song.ID, want, names[song.AddedBy])
+		}
+		if (song.AddedBy == own) != (want == "Alice") {
+			t.Errorf("Expected only Alice's song marked as her own, got %s", song.ID)
+		}
+	}
+	if names[view.Countdown.NextSingerKey] != "Alice" {
+		t.Errorf("Expected the countdown to name Alice, got %q", names[view.Countdown.NextSingerKey])
+	}
+}
+
+func TestProjectRoomStateAdmin(t *testing.T) {
+	state := newProjectionTestState()
+	viewer := state.Sessions[1] // Bob, an admin
+
+	full, ok := ProjectRoomState(state, &viewer).(models.RoomState)
+	if !ok {
+		t.Fatal("Expected full RoomState for an admin viewer")
+	}
+	if full.Sessions[0].IPAddress != "198.51.100.1" || full.Sessions[1].DeviceName != secretDevice {
+		t.Error("Admin should get full session details")
+	}
+}
+
+// ============================================================================
+// Broadcast Tests
+// ============================================================================
+
+// newTestClient registers a client without a websocket connection
+func newTestClient(h *Hub, session *models.Session) *Client {
+	c := &Client{hub: h, send: make(chan []byte, 4), session: session, room: DefaultRoom}
+	h.clients[c] = true
+	return c
+}
+
+// readState reads the room_state payload of the next queued message
+func readState(t *testing.T, c *Client) []byte {
+	t.Helper()
+	select {
+	case raw := <-c.send:
+		var msg Message
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type != MsgStateUpdate {
+			t.Fatalf("Expected %s, got %s", MsgStateUpdate, msg.Type)
+		}
+		return msg.Payload
+	default:
+		t.Fatal("Expected a queued state update")
+		return nil
+	}
+}
+
+func TestBroadcastStateProjectsPerClient(t *testing.T) {
+	h := NewHub()
+	state := newProjectionTestState()
+
+	anonymous := newTestClient(h, nil)
+	guest := newTestClient(h, &state.Sessions[0])
+	admin := newTestClient(h, &state.Sessions[1])
+
+	if err := h.BroadcastState(state); err != nil {
+		t.Fatalf("BroadcastState failed: %v", err)
+	}
+
+	assertNoSensitiveFields(t, "anonymous", readState(t, anonymous))
+	assertNoSensitiveFields(t, "guest", readState(t, guest))
+
+	adminPayload := string(readState(t, admin))
+	for _, want := range []string{secretIP, secretUA, secretDevice, `"is_admin":true`} {
+		if !strings.Contains(adminPayload, want) {
+			t.Errorf("Admin payload missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package websocket
+
+import (
+	"encoding/json"
+
+	"songmartyn/pkg/models"
+)
+
+// RoomHub is the hub as seen by one room
+// Handlers and state broadcasts are scoped to the room; everything else is shared
+type RoomHub struct {
+	*Hub
+	room string
+}
+
+// Room returns the hub view for a room, adding the room if it's new
+func (h *Hub) Room(id string) *RoomHub {
+	h.roomsMu.Lock()
+	if h.rooms[id] == nil {
+		h.rooms[id] = &hubRoom{}
+	}
+	h.roomsMu.Unlock()
+	return &RoomHub{Hub: h, room: id}
+}
+
+// HasRoom reports whether clients can join a room
+func (h *Hub) HasRoom(id string) bool {
+	h.roomsMu.RLock()
+	defer h.roomsMu.RUnlock()
+	return h.rooms[id] != nil
+}
+
+// RemoveRoom closes a room, kicking its clients with a reason
+// The main room can't be removed
+func (h *Hub) RemoveRoom(id string, reason string) {
+	if id == DefaultRoom {
+		return
+	}
+	h.roomsMu.Lock()
+	delete(h.rooms, id)
+	h.roomsMu.Unlock()
+
+	h.mu.RLock()
+	var clients []*Client
+	for client := range h.clients {
+		if client.room == id {
+			clients = append(clients, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		h.KickClient(client, reason)
+	}
+}
+
+// RoomCounts returns the number of connections in each room
+func (h *Hub) RoomCounts() map[string]int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	counts := make(map[string]int)
+	for client := range h.clients {
+		counts[client.room]++
+	}
+	return counts
+}
+
+// ID returns the room's ID
+func (r *RoomHub) ID() string {
+	return r.room
+}
+
+// SetHandlers sets the room's message handler callbacks
+func (r *RoomHub) SetHandlers(handlers HubHandlers) {
+	r.setRoomHandlers(r.room, handlers)
+}
+
+// BroadcastState sends the room's state to its clients
+func (r *RoomHub) BroadcastState(state models.RoomState) error {
+	return r.broadcastRoomState(r.room, state)
+}
+
+// BroadcastPosition sends a playback position tick to the room's delta-sync clients
+func (r *RoomHub) BroadcastPosition(player models.PlayerState) {
+	r.broadcastRoomPosition(r.room, player)
+}
+
+// BroadcastToAdmins sends a message to venue admins and to the room's own admins
+func (r *RoomHub) BroadcastToAdmins(msgType MessageType, payload interface{}) error {
+	return r.broadcastToAdmins(r.room, msgType, payload)
+}
+
+// broadcastToAdmins sends a message to venue admins and to room admins of a room
+func (h *Hub) broadcastToAdmins(room string, msgType MessageType, payload interface{}) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	msgBytes, err := json.Marshal(Message{Type: msgType, Payload: payloadBytes})
+	if err != nil {
+		return err
+	}
+
+	on := h.handlers(room)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.clients {
+		if client.session == nil {
+			continue
+		}
+		if !client.session.IsAdmin && (client.room != room || !client.isAdmin(on)) {
+			continue
+		}
+		select {
+		case client.send <- msgBytes:
+			h.observeSend(msgType, len(msgBytes))
+		default:
+			// Channel full, skip
+		}
+	}
+	return nil
+}
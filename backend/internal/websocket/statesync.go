@@ -0,0 +1,270 @@
+package websocket
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"songmartyn/pkg/models"
+)
+
+// StateProtocolDelta is the handshake state_protocol value for clients that
+// understand sequenced snapshots, deltas and position ticks
+// Clients that don't ask for it keep getting full state_update messages
+const StateProtocolDelta = 2
+
+// PatchOp is one JSON-patch (RFC 6902) operation: add, remove or replace
+type PatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"` // JSON pointer (RFC 6901)
+	Value interface{} `json:"value"`
+}
+
+// StateSnapshotPayload carries the full (projected) room state
+type StateSnapshotPayload struct {
+	Seq   uint64      `json:"seq"`
+	State interface{} `json:"state"`
+}
+
+// StateDeltaPayload carries the changes since the previous sequence number
+// A client that sees a gap in Seq should send state_resync
+type StateDeltaPayload struct {
+	Seq uint64    `json:"seq"`
+	Ops []PatchOp `json:"ops"`
+}
+
+// PositionPayload is the lightweight playback position tick
+type PositionPayload struct {
+	Position  float64 `json:"position"`
+	Duration  float64 `json:"duration"`
+	IsPlaying bool    `json:"is_playing"`
+}
+
+// stateDocument converts a projected state to generic JSON for diffing
+// Playback position is left out; it travels in position ticks instead
+func stateDocument(projection interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(projection)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if player, ok := doc["player"].(map[string]interface{}); ok {
+		delete(player, "position")
+	}
+	return doc, nil
+}
+
+// Diff returns the patch operations that turn one generic JSON value into another
+func Diff(from, to interface{}) []PatchOp {
+	var ops []PatchOp
+	diffValue("", from, to, &ops)
+	return ops
+}
+
+// diffValue appends the operations needed to change the value at path
+func diffValue(path string, from, to interface{}, ops *[]PatchOp) {
+	switch a := from.(type) {
+	case map[string]interface{}:
+		if b, ok := to.(map[string]interface{}); ok {
+			diffObject(path, a, b, ops)
+			return
+		}
+	case []interface{}:
+		if b, ok := to.([]interface{}); ok {
+			diffArray(path, a, b, ops)
+			return
+		}
+	}
+	if !reflect.DeepEqual(from, to) {
+		*ops = append(*ops, PatchOp{Op: "replace", Path: path, Value: to})
+	}
+}
+
+// diffObject compares object members in sorted key order
+func diffObject(path string, a, b map[string]interface{}, ops *[]PatchOp) {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		child := path + "/" + escapePointer(k)
+		av, inA := a[k]
+		bv, inB := b[k]
+		switch {
+		case !inB:
+			*ops = append(*ops, PatchOp{Op: "remove", Path: child})
+		case !inA:
+			*ops = append(*ops, PatchOp{Op: "add", Path: child, Value: bv})
+		default:
+			diffValue(child, av, bv, ops)
+		}
+	}
+}
+
+// diffArray trims the common prefix and suffix, diffs the overlapping middle
+// element by element, then removes or adds the rest
+// Adding or removing one queue entry produces a single operation
+func diffArray(path string, a, b []interface{}, ops *[]PatchOp) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && reflect.DeepEqual(a[prefix], b[prefix]) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		reflect.DeepEqual(a[len(a)-1-suffix], b[len(b)-1-suffix]) {
+		suffix++
+	}
+
+	aMid := a[prefix : len(a)-suffix]
+	bMid := b[prefix : len(b)-suffix]
+	common := len(aMid)
+	if len(bMid) < common {
+		common = len(bMid)
+	}
+
+	for i := 0; i < common; i++ {
+		diffValue(path+"/"+strconv.Itoa(prefix+i), aMid[i], bMid[i], ops)
+	}
+	// Remove from the end so earlier indexes stay valid
+	for i := len(aMid) - 1; i >= common; i-- {
+		*ops = append(*ops, PatchOp{Op: "remove", Path: path + "/" + strconv.Itoa(prefix+i)})
+	}
+	for i := common; i < len(bMid); i++ {
+		*ops = append(*ops, PatchOp{Op: "add", Path: path + "/" + strconv.Itoa(prefix+i), Value: bMid[i]})
+	}
+}
+
+// escapePointer escapes a JSON pointer reference token
+func escapePointer(token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	return strings.ReplaceAll(token, "/", "~1")
+}
+
+// sendState sends a delta-sync client whatever it needs to match the new state:
+// a snapshot if it has none (or missed a message), a delta if something changed,
+// or nothing at all
+func (c *Client) sendState(projection interface{}) {
+	doc, err := stateDocument(projection)
+	if err != nil {
+		return
+	}
+
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	if c.lastState == nil {
+		c.sendSnapshotLocked(doc)
+		return
+	}
+
+	ops := Diff(c.lastState, doc)
+	if len(ops) == 0 {
+		return
+	}
+	c.stateSeq++
+	if c.hub.trySend(c, MsgStateDelta, StateDeltaPayload{Seq: c.stateSeq, Ops: ops}) {
+		c.lastState = doc
+	} else {
+		// Dropped: the next broadcast falls back to a snapshot
+		c.lastState = nil
+	}
+}
+
+// sendSnapshot sends the full projected state with a new sequence number
+func (c *Client) sendSnapshot(projection interface{}) {
+	doc, err := stateDocument(projection)
+	if err != nil {
+		return
+	}
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.sendSnapshotLocked(doc)
+}
+
+// sendSnapshotLocked sends a snapshot; caller holds stateMu
+func (c *Client) sendSnapshotLocked(doc map[string]interface{}) {
+	c.stateSeq++
+	if c.hub.trySend(c, MsgStateSnapshot, StateSnapshotPayload{Seq: c.stateSeq, State: doc}) {
+		c.lastState = doc
+	} else {
+		c.lastState = nil
+	}
+}
+
+// resetState switches a client to delta sync and records the state it got
+// in its welcome message, returning the welcome's sequence number
+func (c *Client) resetState(projection interface{}) uint64 {
+	doc, err := stateDocument(projection)
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.deltaSync = true
+	c.stateSeq++
+	if err == nil {
+		c.lastState = doc
+	} else {
+		c.lastState = nil
+	}
+	return c.stateSeq
+}
+
+// usesDeltaSync reports whether the client opted in to delta sync
+func (c *Client) usesDeltaSync() bool {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.deltaSync
+}
+
+// trySend queues a message for a client, reporting whether it fit in the send buffer
+func (h *Hub) trySend(client *Client, msgType MessageType, payload interface{}) bool {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return false
+	}
+	msgBytes, err := json.Marshal(Message{Type: msgType, Payload: payloadBytes})
+	if err != nil {
+		return false
+	}
+
+	select {
+	case client.send <- msgBytes:
+		h.observeSend(msgType, len(msgBytes))
+		return true
+	default:
+		return false
+	}
+}
+
+// BroadcastPosition sends a playback position tick to the main room's delta-sync clients
+func (h *Hub) BroadcastPosition(player models.PlayerState) {
+	h.broadcastRoomPosition(DefaultRoom, player)
+}
+
+// broadcastRoomPosition sends a playback position tick to a room's delta-sync clients
+// Legacy clients get the position in their full state updates
+func (h *Hub) broadcastRoomPosition(room string, player models.PlayerState) {
+	tick := PositionPayload{
+		Position:  player.Position,
+		Duration:  player.Duration,
+		IsPlaying: player.IsPlaying,
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.clients {
+		if client.room == room && client.usesDeltaSync() {
+			h.trySend(client, MsgPosition, tick)
+		}
+	}
+}
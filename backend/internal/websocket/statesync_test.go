@@ -0,0 +1,281 @@
+package websocket
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"songmartyn/pkg/models"
+)
+
+// applyPatch applies patch operations to a generic JSON document, the way a client would
+func applyPatch(t *testing.T, doc interface{}, ops []PatchOp) interface{} {
+	t.Helper()
+	for _, op := range ops {
+		doc = applyOp(t, doc, op, splitPointer(op.Path))
+	}
+	return doc
+}
+
+func splitPointer(path string) []string {
+	if path == "" {
+		return nil
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, tok := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(tok, "~1", "/"), "~0", "~")
+	}
+	return tokens
+}
+
+func applyOp(t *testing.T, node interface{}, op PatchOp, tokens []string) interface{} {
+	t.Helper()
+	if len(tokens) == 0 {
+		return op.Value
+	}
+	key, rest := tokens[0], tokens[1:]
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(rest) > 0 {
+			n[key] = applyOp(t, n[key], op, rest)
+		} else if op.Op == "remove" {
+			delete(n, key)
+		} else {
+			n[key] = op.Value
+		}
+		return n
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil {
+			t.Fatalf("Bad array index %q in %s", key, op.Path)
+		}
+		if len(rest) > 0 {
+			n[i] = applyOp(t, n[i], op, rest)
+			return n
+		}
+		switch op.Op {
+		case "remove":
+			return append(n[:i:i], n[i+1:]...)
+		case "add":
+			out := append(n[:i:i], op.Value)
+			return append(out, n[i:]...)
+		default:
+			n[i] = op.Value
+			return n
+		}
+	}
+	t.Fatalf("Cannot apply %s %s", op.Op, op.Path)
+	return nil
+}
+
+// toDoc round-trips a JSON literal into a generic document
+func toDoc(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// nextMessage reads the next queued message for a client
+func nextMessage(t *testing.T, c *Client) Message {
+	t.Helper()
+	select {
+	case raw := <-c.send:
+		var msg Message
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	default:
+		t.Fatal("Expected a queued message")
+		return Message{}
+	}
+}
+
+func newDeltaTestClient(h *Hub, state models.RoomState) *Client {
+	c := newTestClient(h, &models.Session{MartynKey: "key-alice"})
+	c.resetState(ProjectRoomState(state, c.session))
+	return c
+}
+
+func newSyncTestState() models.RoomState {
+	state := newProjectionTestState()
+	state.Queue.Songs = []models.Song{{ID: "s1", Title: "One"}, {ID: "s2", Title: "Two"}}
+	state.Player.Position = 12
+	state.Player.Duration = 200
+	return state
+}
+
+// ============================================================================
+// Diff Tests
+// ============================================================================
+
+func TestDiffRoundTrip(t *testing.T) {
+	cases := []struct {
+		name     string
+		from, to string
+		maxOps   int
+	}{
+		{"unchanged", `{"a":1,"b":[1,2]}`, `{"a":1,"b":[1,2]}`, 0},
+		{"scalar change", `{"a":1}`, `{"a":2}`, 1},
+		{"key added and removed", `{"a":1,"b":2}`, `{"a":1,"c":3}`, 2},
+		{"insert in middle", `{"q":[1,2,3,4]}`, `{"q":[1,2,9,3,4]}`, 1},
+		{"remove from middle", `{"q":[1,2,3,4]}`, `{"q":[1,3,4]}`, 1},
+		{"append", `{"q":[1]}`, `{"q":[1,2,3]}`, 2},
+		{"reorder", `{"q":[1,2,3]}`, `{"q":[3,1,2]}`, 3},
+		{"nested field", `{"q":[{"id":"a","n":1},{"id":"b","n":1}]}`, `{"q":[{"id":"a","n":1},{"id":"b","n":2}]}`, 1},
+		{"type change", `{"a":{"x":1}}`, `{"a":null}`, 1},
+		{"escaped keys", `{"a/b":1,"c~d":1}`, `{"a/b":2,"c~d":2}`, 2},
+		{"clear", `{"q":[1,2,3]}`, `{"q":[]}`, 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ops := Diff(toDoc(t, tc.from), toDoc(t, tc.to))
+			if len(ops) > tc.maxOps {
+				t.Errorf("Expected at most %d ops, got %d: %+v", tc.maxOps, len(ops), ops)
+			}
+			got := applyPatch(t, toDoc(t, tc.from), ops)
+			if want := toDoc(t, tc.to); !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected %v after patch, got %v (ops %+v)", want, got, ops)
+			}
+		})
+	}
+}
+
+func TestDiffEscapesPointer(t *testing.T) {
+	ops := Diff(toDoc(t, `{"a/b":1}`), toDoc(t, `{"a/b":2}`))
+	if len(ops) != 1 || ops[0].Path != "/a~1b" {
+		t.Errorf("Expected path /a~1b, got %+v", ops)
+	}
+}
+
+// ============================================================================
+// Sequenced Sync Tests
+// ============================================================================
+
+func TestBroadcastStateSendsDeltas(t *testing.T) {
+	h := NewHub()
+	state := newSyncTestState()
+	c := newDeltaTestClient(h, state) // welcome was seq 1
+	legacy := newTestClient(h, &models.Session{MartynKey: "key-legacy"})
+
+	state.Queue.Songs = append(state.Queue.Songs, models.Song{ID: "s3", Title: "Three"})
+	h.BroadcastState(state)
+
+	msg := nextMessage(t, c)
+	if msg.Type != MsgStateDelta {
+		t.Fatalf("Expected %s, got %s", MsgStateDelta, msg.Type)
+	}
+	var delta StateDeltaPayload
+	json.Unmarshal(msg.Payload, &delta)
+	if delta.Seq != 2 {
+		t.Errorf("Expected seq 2, got %d", delta.Seq)
+	}
+	if len(delta.Ops) != 1 || delta.Ops[0].Op != "add" || delta.Ops[0].Path != "/queue/songs/2" {
+		t.Errorf("Expected a single add at /queue/songs/2, got %+v", delta.Ops)
+	}
+
+	// Legacy clients still get the full state
+	if legacyMsg := nextMessage(t, legacy); legacyMsg.Type != MsgStateUpdate {
+		t.Errorf("Expected legacy client to get %s, got %s", MsgStateUpdate, legacyMsg.Type)
+	}
+}
+
+func TestBroadcastStateSkipsPositionOnlyChanges(t *testing.T) {
+	h := NewHub()
+	state := newSyncTestState()
+	c := newDeltaTestClient(h, state)
+
+	state.Player.Position = 99
+	h.BroadcastState(state)
+
+	select {
+	case raw := <-c.send:
+		t.Errorf("Expected no message for a position-only change, got %s", raw)
+	default:
+	}
+}
+
+func TestDroppedDeltaFallsBackToSnapshot(t *testing.T) {
+	h := NewHub()
+	state := newSyncTestState()
+	c := newDeltaTestClient(h, state)
+
+	// Fill the send buffer so the next delta is dropped
+	for i := 0; i < cap(c.send); i++ {
+		c.send <- []byte(`{}`)
+	}
+	state.Queue.Autoplay = true
+	h.BroadcastState(state)
+	for len(c.send) > 0 {
+		<-c.send
+	}
+
+	state.Queue.Position = 1
+	h.BroadcastState(state)
+
+	msg := nextMessage(t, c)
+	if msg.Type != MsgStateSnapshot {
+		t.Fatalf("Expected %s after a dropped delta, got %s", MsgStateSnapshot, msg.Type)
+	}
+	var snap StateSnapshotPayload
+	json.Unmarshal(msg.Payload, &snap)
+	if snap.Seq != 3 {
+		t.Errorf("Expected seq 3 (2 was dropped), got %d", snap.Seq)
+	}
+}
+
+func TestResyncSendsSnapshot(t *testing.T) {
+	h := NewHub()
+	state := newSyncTestState()
+	c := newDeltaTestClient(h, state)
+
+	state.Queue.Position = 1
+	h.BroadcastState(state)
+	nextMessage(t, c)
+
+	c.handleMessage(Message{Type: MsgStateResync})
+	msg := nextMessage(t, c)
+	if msg.Type != MsgStateSnapshot {
+		t.Fatalf("Expected %s, got %s", MsgStateSnapshot, msg.Type)
+	}
+	var snap struct {
+		Seq   uint64               `json:"seq"`
+		State models.RoomStateView `json:"state"`
+	}
+	json.Unmarshal(msg.Payload, &snap)
+	if snap.Seq != 3 {
+		t.Errorf("Expected seq 3, got %d", snap.Seq)
+	}
+	if snap.State.Queue.Position != 1 || len(snap.State.Queue.Songs) != 2 {
+		t.Errorf("Expected snapshot of the latest state, got %+v", snap.State.Queue)
+	}
+	assertNoSensitiveFields(t, "snapshot", msg.Payload)
+}
+
+func TestBroadcastPositionOnlyToDeltaClients(t *testing.T) {
+	h := NewHub()
+	c := newDeltaTestClient(h, newSyncTestState())
+	legacy := newTestClient(h, &models.Session{MartynKey: "key-legacy"})
+
+	h.BroadcastPosition(models.PlayerState{Position: 42.5, Duration: 200, IsPlaying: true})
+
+	msg := nextMessage(t, c)
+	if msg.Type != MsgPosition {
+		t.Fatalf("Expected %s, got %s", MsgPosition, msg.Type)
+	}
+	var tick PositionPayload
+	json.Unmarshal(msg.Payload, &tick)
+	if tick.Position != 42.5 || !tick.IsPlaying {
+		t.Errorf("Expected position 42.5 playing, got %+v", tick)
+	}
+	if len(legacy.send) != 0 {
+		t.Error("Expected legacy client to get no position ticks")
+	}
+}
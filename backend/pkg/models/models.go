@@ -1,18 +1,24 @@
 package models
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
 
 // VocalAssistLevel represents the Chortle vocal assist intensity
 type VocalAssistLevel string
 
 const (
-	VocalOff  VocalAssistLevel = "OFF"  // 0% gain
-	VocalLow  VocalAssistLevel = "LOW"  // 15% gain - pitch reference
-	VocalMed  VocalAssistLevel = "MED"  // 45% gain - melody support
-	VocalHigh VocalAssistLevel = "HIGH" // 80% gain - full vocal lead
+	VocalOff    VocalAssistLevel = "OFF"    // 0% gain
+	VocalLow    VocalAssistLevel = "LOW"    // 15% gain - pitch reference
+	VocalMed    VocalAssistLevel = "MED"    // 45% gain - melody support
+	VocalHigh   VocalAssistLevel = "HIGH"   // 80% gain - full vocal lead
+	VocalCustom VocalAssistLevel = "CUSTOM" // Continuous gain from VocalGain
+	VocalAuto   VocalAssistLevel = "AUTO"   // Follows the singer's mic: more help when they go quiet
 )
 
-// VocalGainMap maps assist levels to their gain percentages
+// VocalGainMap maps assist levels to their vocal stem gains (0-1)
 var VocalGainMap = map[VocalAssistLevel]float64{
 	VocalOff:  0.0,
 	VocalLow:  0.15,
@@ -20,6 +26,53 @@ var VocalGainMap = map[VocalAssistLevel]float64{
 	VocalHigh: 0.80,
 }
 
+// VocalGainFor returns the vocal stem gain (0-1) for an assist level
+// CUSTOM uses customGain; AUTO starts from customGain and is then driven by the mic
+func VocalGainFor(level VocalAssistLevel, customGain float64) float64 {
+	if level == VocalCustom || level == VocalAuto {
+		return ClampVocalGain(customGain)
+	}
+	return VocalGainMap[level]
+}
+
+// ClampVocalGain limits a vocal gain to 0-1
+func ClampVocalGain(gain float64) float64 {
+	if gain < 0 {
+		return 0
+	}
+	if gain > 1 {
+		return 1
+	}
+	return gain
+}
+
+// PublicID is the ID other guests see for a session. The MartynKey itself is the
+// session's credential, so only its owner (and admins) may ever see it.
+func PublicID(martynKey string) string {
+	if martynKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte("songmartyn-public-id:" + martynKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ValidVocalAssist reports whether level is a known assist level
+func ValidVocalAssist(level VocalAssistLevel) bool {
+	_, preset := VocalGainMap[level]
+	return preset || level == VocalCustom || level == VocalAuto
+}
+
+// MicPreset names a live microphone effects preset
+type MicPreset string
+
+const (
+	MicPresetDry     MicPreset = "dry"     // No effects
+	MicPresetStudio  MicPreset = "studio"  // Noise gate, gentle compression, small room
+	MicPresetHall    MicPreset = "hall"    // Compression and a long, smooth reverb
+	MicPresetEcho    MicPreset = "echo"    // Slapback echo
+	MicPresetStadium MicPreset = "stadium" // Heavy compression, big reverb and echo
+)
+
 // Song represents a queued karaoke track
 type Song struct {
 	ID           string           `json:"id"`
@@ -33,9 +86,10 @@ type Song struct {
 	CDGPath      string           `json:"cdg_path,omitempty"`      // Path to CDG graphics file
 	AudioPath    string           `json:"audio_path,omitempty"`    // Path to audio file (for CDG)
 	VocalAssist  VocalAssistLevel `json:"vocal_assist"`
+	VocalGain    float64          `json:"vocal_gain,omitempty"`    // 0-1, used when VocalAssist is CUSTOM or AUTO
 	KeyChange    int              `json:"key_change"`              // Semitones (-12 to +12)
 	TempoChange  float64          `json:"tempo_change"`            // Speed multiplier (0.5 to 2.0, 1.0 = normal)
-	AddedBy      string           `json:"added_by"`                // MartynKey of who added it
+	AddedBy      string           `json:"added_by"`                // MartynKey of who added it (PublicID for guests)
 	AddedAt      time.Time        `json:"added_at"`
 }
 
@@ -60,13 +114,24 @@ type AvatarConfig struct {
 	Colors *AvatarColors `json:"colors,omitempty"` // Optional custom colors
 }
 
+// SavedAvatar is one of a singer's named avatars, kept to switch back to later
+type SavedAvatar struct {
+	Name    string       `json:"name"`
+	Config  AvatarConfig `json:"config"`
+	SavedAt time.Time    `json:"saved_at"`
+}
+
 // Session represents a connected client session (The Martyn Handshake)
 type Session struct {
 	MartynKey      string           `json:"martyn_key"` // UUID
 	DisplayName    string           `json:"display_name"`
 	AvatarID       string           `json:"avatar_id,omitempty"`     // Legacy pixel avatar identifier
 	AvatarConfig   *AvatarConfig    `json:"avatar_config,omitempty"` // Multiavatar configuration
+	SavedAvatars   []SavedAvatar    `json:"saved_avatars,omitempty"` // Named avatars to switch between
 	VocalAssist    VocalAssistLevel `json:"vocal_assist"`
+	VocalGain      float64          `json:"vocal_gain"`           // 0-1, the singer's CUSTOM level
+	MicPreset      MicPreset        `json:"mic_preset,omitempty"` // Microphone effects used while this singer performs
+	RecordPerformances bool         `json:"record_performances"`  // Singer opted in to performance recording
 	SearchHistory  []string         `json:"search_history"`
 	Favorites      []string         `json:"favorites"`               // Favorite song IDs
 	CurrentSongID  string           `json:"current_song_id,omitempty"`
@@ -90,6 +155,7 @@ type PlayerState struct {
 	IsPlaying     bool             `json:"is_playing"`
 	Volume        float64          `json:"volume"`        // 0-100
 	VocalAssist   VocalAssistLevel `json:"vocal_assist"`
+	VocalGain     float64          `json:"vocal_gain"`    // 0-1, live vocal stem level
 	BGMActive     bool             `json:"bgm_active"`    // Background music playing
 	BGMEnabled    bool             `json:"bgm_enabled"`   // BGM feature enabled
 	Idle          bool             `json:"idle"`          // Showing holding screen (not playing a song)
@@ -133,7 +199,7 @@ type CountdownState struct {
 	Active           bool   `json:"active"`             // Countdown is running
 	SecondsRemaining int    `json:"seconds_remaining"`  // Seconds until auto-play
 	NextSongID       string `json:"next_song_id"`       // ID of next song
-	NextSingerKey    string `json:"next_singer_key"`    // MartynKey of next singer
+	NextSingerKey    string `json:"next_singer_key"`    // MartynKey of next singer (PublicID for guests)
 	RequiresApproval bool   `json:"requires_approval"`  // Admin must start (different user)
 }
 
@@ -145,6 +211,35 @@ type RoomState struct {
 	Countdown CountdownState `json:"countdown"` // Inter-song countdown
 }
 
+// SessionView is a session as seen by a non-admin client
+// Other singers only get the public profile; the viewer's own entry also carries
+// their personal settings. IP, user agent and device name are never included.
+type SessionView struct {
+	PublicID     string        `json:"public_id"`
+	DisplayName  string        `json:"display_name"`
+	AvatarID     string        `json:"avatar_id,omitempty"`
+	AvatarConfig *AvatarConfig `json:"avatar_config,omitempty"`
+	IsOnline     bool          `json:"is_online"`
+	IsAFK        bool          `json:"is_afk"`
+	// Viewer's own entry only
+	MartynKey     string           `json:"martyn_key,omitempty"`
+	VocalAssist   VocalAssistLevel `json:"vocal_assist,omitempty"`
+	VocalGain     float64          `json:"vocal_gain,omitempty"`
+	SearchHistory []string         `json:"search_history,omitempty"`
+	Favorites     []string         `json:"favorites"`
+	SavedAvatars  []SavedAvatar    `json:"saved_avatars,omitempty"`
+	NameLocked    bool             `json:"name_locked,omitempty"`
+	IsAdmin       bool             `json:"is_admin,omitempty"`
+}
+
+// RoomStateView is the room state sent to non-admin clients
+type RoomStateView struct {
+	Player    PlayerState    `json:"player"`
+	Queue     QueueState     `json:"queue"`
+	Sessions  []SessionView  `json:"sessions"`
+	Countdown CountdownState `json:"countdown"`
+}
+
 // LibraryLocation represents a folder containing media files
 type LibraryLocation struct {
 	ID        int64     `json:"id"`
@@ -161,6 +256,11 @@ type LibrarySong struct {
 	Title        string    `json:"title"`
 	Artist       string    `json:"artist"`
 	Album        string    `json:"album,omitempty"`
+	Genre        string    `json:"genre,omitempty"`
+	Year         int       `json:"year,omitempty"`
+	Language     string    `json:"language,omitempty"` // ISO 639 code from tags or admin edits
+	Explicit     bool      `json:"explicit"`
+	ManualFields []string  `json:"manual_fields,omitempty"` // Fields edited by an admin; rescans leave these alone
 	Duration     int       `json:"duration"`      // seconds
 	FilePath     string    `json:"file_path"`
 	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
@@ -169,13 +269,53 @@ type LibrarySong struct {
 	CDGPath      string    `json:"cdg_path,omitempty"`   // Path to CDG graphics file
 	AudioPath    string    `json:"audio_path,omitempty"` // Path to audio file (for CDG)
 	LibraryID    int64     `json:"library_id"`
+	// Loudness (nil until analyzed)
+	LoudnessLUFS *float64 `json:"loudness_lufs,omitempty"`  // EBU R128 integrated loudness
+	TruePeakDBTP *float64 `json:"true_peak_dbtp,omitempty"` // True peak in dBTP
+	LeadingSilence  float64 `json:"leading_silence,omitempty"`  // Seconds of silence before the audio starts
+	TrailingSilence float64 `json:"trailing_silence,omitempty"` // Seconds of silence after the audio ends
 	// Stats
 	TimesSung    int       `json:"times_sung"`
 	LastSungAt   *time.Time `json:"last_sung_at,omitempty"`
-	LastSungBy   string    `json:"last_sung_by,omitempty"` // MartynKey
+	LastSungBy   string    `json:"-"` // MartynKey, never sent to clients
 	AddedAt      time.Time `json:"added_at"`
 }
 
+// Playlist is a singer's named, ordered list of library songs
+type Playlist struct {
+	ID         string    `json:"id"`
+	OwnerKey   string    `json:"owner_key"`   // MartynKey of the creator
+	Name       string    `json:"name"`
+	SongIDs    []string  `json:"song_ids"`    // Library song IDs in order
+	SharedWith []string  `json:"shared_with"` // MartynKeys that can view and queue it
+	Editors    []string  `json:"editors"`     // Those of SharedWith the owner lets change its songs
+	ShareToken string    `json:"share_token,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// PlaylistMember is a singer a playlist belongs to or is shared with
+type PlaylistMember struct {
+	PublicID    string `json:"public_id"`
+	DisplayName string `json:"display_name"`
+	CanEdit     bool   `json:"can_edit"`
+}
+
+// PlaylistView is a playlist as sent to one of its members
+// MartynKeys are replaced with public profiles, and only the owner gets the share token.
+type PlaylistView struct {
+	ID         string           `json:"id"`
+	Name       string           `json:"name"`
+	SongIDs    []string         `json:"song_ids"`
+	Owner      PlaylistMember   `json:"owner"`
+	IsOwner    bool             `json:"is_owner"`
+	CanEdit    bool             `json:"can_edit"` // The viewer may change its songs
+	SharedWith []PlaylistMember `json:"shared_with"`
+	ShareToken string           `json:"share_token,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
+
 // SongHistory tracks when a user sang a song
 type SongHistory struct {
 	ID        int64     `json:"id"`
@@ -185,6 +325,8 @@ type SongHistory struct {
 	// Denormalized for easy display
 	SongTitle  string `json:"song_title"`
 	SongArtist string `json:"song_artist"`
+	// Reactions guests sent during the performance, by reaction
+	Reactions map[string]int `json:"reactions,omitempty"`
 }
 
 // QueueMode represents how songs are ordered in the queue
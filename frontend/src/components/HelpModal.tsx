@@ -55,7 +55,7 @@ The free tier includes 10,000 quota units/day (about 100 searches).`,
         content: `
 - Consider restricting your API key to this server's IP address
 - YouTube API keys are free but have daily quotas
-- The key is stored locally in your .env file`,
+- The key is stored locally in your songmartyn.toml file`,
       },
     ],
   },
@@ -1,10 +1,10 @@
-import { useRoomStore, selectCurrentSong, selectIsPlaying, selectActiveSessions } from '../stores/roomStore';
+import { useRoomStore, selectCurrentSong, selectIsPlaying, selectActiveSessions, isSinger } from '../stores/roomStore';
 import { buildAvatarUrl } from './AvatarCreator';
 import type { Session, AvatarConfig } from '../types';
 
-// Helper to get session info from martyn_key
+// Helper to get session info from who queued a song
 function getSingerInfo(addedBy: string, sessions: Session[]): { name: string; avatarConfig?: AvatarConfig } {
-  const session = sessions.find(s => s.martyn_key === addedBy);
+  const session = sessions.find(s => isSinger(s, addedBy));
   return {
     name: session?.display_name || 'Unknown Singer',
     avatarConfig: session?.avatar_config,
@@ -1,4 +1,4 @@
-import { useRoomStore, selectQueue, selectQueuePosition, selectSession, selectActiveSessions } from '../stores/roomStore';
+import { useRoomStore, selectQueue, selectQueuePosition, selectSession, selectActiveSessions, isSinger } from '../stores/roomStore';
 import { wsService } from '../services/websocket';
 import { buildAvatarUrl } from './AvatarCreator';
 import type { Song, Session, AvatarConfig } from '../types';
@@ -9,9 +9,9 @@ function formatDuration(seconds: number): string {
   return `${mins}:${secs.toString().padStart(2, '0')}`;
 }
 
-// Helper to get session info from martyn_key
+// Helper to get session info from who queued a song
 function getSingerInfo(addedBy: string, sessions: Session[]): { name: string; avatarConfig?: AvatarConfig } {
-  const session = sessions.find(s => s.martyn_key === addedBy);
+  const session = sessions.find(s => isSinger(s, addedBy));
   return {
     name: session?.display_name || 'Unknown Singer',
     avatarConfig: session?.avatar_config,
@@ -159,7 +159,7 @@ export function Queue() {
         {upcomingSongs.map((song, displayIndex) => {
           const actualIndex = position + displayIndex;
           const singerInfo = getSingerInfo(song.added_by, sessions);
-          const isOwnSong = session?.martyn_key === song.added_by;
+          const isOwnSong = isSinger(session, song.added_by);
 
           return (
             <QueueItem
@@ -17,7 +17,7 @@ export function useWebSocket() {
       store.setConnected(true);
       store.setConnecting(false);
       store.setBlocked(false);
-      store.setSession(payload.session);
+      store.setSession({ ...payload.session, public_id: payload.public_id });
       store.updateState(payload.room_state);
       store.addNotification('success', `Welcome, ${payload.session.display_name}!`);
       console.log('Session restored:', payload.session.display_name);
@@ -0,0 +1,58 @@
+// Applies the JSON-patch (RFC 6902) deltas the server sends for delta state sync.
+// Only the operations the server produces are supported: add, remove and replace.
+
+import type { PatchOp } from '../types';
+
+type Container = Record<string, unknown> | unknown[];
+
+// Decode one JSON pointer reference token
+function unescapeToken(token: string): string {
+  return token.replace(/~1/g, '/').replace(/~0/g, '~');
+}
+
+// applyPatch returns a copy of doc with ops applied, leaving doc untouched.
+// Throws if an operation doesn't fit the document, so the caller can resync.
+export function applyPatch<T>(doc: T, ops: PatchOp[]): T {
+  let root: unknown = structuredClone(doc);
+
+  for (const { op, path, value } of ops) {
+    if (path === '') {
+      if (op === 'remove') throw new Error('cannot remove the whole document');
+      root = structuredClone(value);
+      continue;
+    }
+
+    const tokens = path.split('/').slice(1).map(unescapeToken);
+    const last = tokens.pop() as string;
+    let parent = root as Container;
+    for (const token of tokens) {
+      const next = Array.isArray(parent) ? parent[Number(token)] : parent[token];
+      if (next === null || typeof next !== 'object') {
+        throw new Error(`patch path not found: ${path}`);
+      }
+      parent = next as Container;
+    }
+
+    if (Array.isArray(parent)) {
+      const index = last === '-' ? parent.length : Number(last);
+      if (!Number.isInteger(index) || index < 0 || index > parent.length) {
+        throw new Error(`patch index out of range: ${path}`);
+      }
+      if (op === 'add') {
+        parent.splice(index, 0, structuredClone(value));
+      } else if (index >= parent.length) {
+        throw new Error(`patch index out of range: ${path}`);
+      } else if (op === 'remove') {
+        parent.splice(index, 1);
+      } else {
+        parent[index] = structuredClone(value);
+      }
+    } else if (op === 'remove') {
+      delete parent[last];
+    } else {
+      parent[last] = structuredClone(value);
+    }
+  }
+
+  return root as T;
+}
@@ -7,11 +7,18 @@ import type {
   SearchResult,
   ClientInfo,
   AvatarConfig,
+  StateSnapshotPayload,
+  StateDeltaPayload,
+  PositionPayload,
 } from '../types';
+import { applyPatch } from './statePatch';
 
 const MARTYN_KEY_STORAGE = 'songmartyn_key';
+const ROOM_STORAGE = 'songmartyn_room';
 const RECONNECT_DELAY = 1000;
 const MAX_RECONNECT_DELAY = 30000;
+// Ask for sequenced snapshots and deltas instead of the full state on every change
+const STATE_PROTOCOL_DELTA = 2;
 
 type MessageHandler = {
   welcome: (payload: WelcomePayload) => void;
@@ -30,13 +37,21 @@ class WebSocketService {
   private isConnecting = false;
   private displayName: string = '';
   private wasKicked = false; // Prevent reconnection after being kicked/blocked
+  private roomState: RoomState | null = null; // Delta sync: the state deltas apply to
+  private stateSeq = 0;
 
   constructor() {
     // Default to current host for WebSocket
     // Always use wss:// since server always runs HTTPS
     const protocol = 'wss:';
     const host = import.meta.env.DEV ? 'localhost:8443' : window.location.host;
-    this.url = `${protocol}//${host}/ws`;
+    // Join the room named in the link or QR code (?room=) for as long as the tab is open
+    const room = new URLSearchParams(window.location.search).get('room');
+    if (room) {
+      sessionStorage.setItem(ROOM_STORAGE, room);
+    }
+    const joined = sessionStorage.getItem(ROOM_STORAGE);
+    this.url = `${protocol}//${host}/ws` + (joined ? `?room=${encodeURIComponent(joined)}` : '');
   }
 
   // Get stored MartynKey for session persistence
@@ -75,6 +90,7 @@ class WebSocketService {
         this.send('handshake', {
           martyn_key: martynKey,
           display_name: this.displayName,
+          state_protocol: STATE_PROTOCOL_DELTA,
         });
       };
 
@@ -129,12 +145,44 @@ class WebSocketService {
         const payload = message.payload as WelcomePayload;
         // Store MartynKey for session persistence
         this.setMartynKey(payload.session.martyn_key);
+        this.roomState = payload.room_state;
+        this.stateSeq = payload.seq ?? 0;
         this.handlers.welcome?.(payload);
         break;
       }
       case 'state_update':
         this.handlers.state_update?.(message.payload as RoomState);
         break;
+      case 'state_snapshot': {
+        const payload = message.payload as StateSnapshotPayload;
+        // Snapshots leave out the position; keep the last tick's
+        const position = this.roomState?.player.position ?? 0;
+        this.setRoomState({ ...payload.state, player: { ...payload.state.player, position } }, payload.seq);
+        break;
+      }
+      case 'state_delta': {
+        const payload = message.payload as StateDeltaPayload;
+        if (!this.roomState) break; // Waiting for the snapshot we asked for
+        if (payload.seq !== this.stateSeq + 1) {
+          this.resync(); // Missed a delta
+          break;
+        }
+        try {
+          this.setRoomState(applyPatch(this.roomState, payload.ops), payload.seq);
+        } catch {
+          this.resync(); // Our copy has drifted from the server's
+        }
+        break;
+      }
+      case 'position': {
+        if (!this.roomState) break;
+        const tick = message.payload as PositionPayload;
+        this.setRoomState({
+          ...this.roomState,
+          player: { ...this.roomState.player, ...tick },
+        }, this.stateSeq);
+        break;
+      }
       case 'search_result':
         this.handlers.search_result?.(message.payload as SearchResult[]);
         break;
@@ -152,6 +200,19 @@ class WebSocketService {
     }
   }
 
+  // Record the delta-synced state and pass it on like a full state update
+  private setRoomState(state: RoomState, seq: number): void {
+    this.roomState = state;
+    this.stateSeq = seq;
+    this.handlers.state_update?.(state);
+  }
+
+  // Drop our copy of the state and ask for a fresh snapshot
+  private resync(): void {
+    this.roomState = null;
+    this.send('state_resync', null);
+  }
+
   // Send a message to the server
   private send<T>(type: MessageType, payload: T): void {
     console.log(`[WS SEND] type=${type}, readyState=${this.ws?.readyState}, payload=`, payload);
@@ -207,6 +207,11 @@ export const selectActiveSessions = (state: RoomStore): Session[] =>
 export const selectNotifications = (state: RoomStore): Notification[] =>
   state.notifications;
 
+// isSinger reports whether a session queued a song: guests see singers by public_id,
+// admins by martyn_key
+export const isSinger = (session: Session | null | undefined, addedBy: string): boolean =>
+  !!session && !!addedBy && (session.public_id === addedBy || session.martyn_key === addedBy);
+
 // Stable empty array to prevent infinite re-renders in selectors
 const EMPTY_FAVORITES: string[] = [];
 
@@ -28,7 +28,7 @@ export interface Song {
   vocal_assist: VocalAssistLevel;
   key_change: number;      // Semitones (-12 to +12)
   tempo_change: number;    // Speed multiplier (0.5 to 2.0, 1.0 = normal)
-  added_by: string;
+  added_by: string;        // Public ID of who queued it (MartynKey for admins)
   added_at: string;
 }
 
@@ -55,7 +55,8 @@ export interface AvatarConfig {
 
 // Session (The Martyn Handshake)
 export interface Session {
-  martyn_key: string;
+  martyn_key: string;   // Only on your own entry; other singers are identified by public_id
+  public_id?: string;   // Matches added_by and next_singer_key (admins get MartynKeys instead)
   display_name: string;
   avatar_id?: string;
   avatar_config?: AvatarConfig;
@@ -185,8 +186,12 @@ export type MessageType =
   | 'admin_set_name_lock'
   | 'admin_toggle_bgm'
   | 'admin_set_message'
+  | 'state_resync'
   | 'welcome'
   | 'state_update'
+  | 'state_snapshot'
+  | 'state_delta'
+  | 'position'
   | 'search_result'
   | 'error'
   | 'client_list'
@@ -199,7 +204,33 @@ export interface WebSocketMessage<T = unknown> {
 
 export interface WelcomePayload {
   session: Session;
+  public_id: string; // How your songs and entry appear to other guests
   room_state: RoomState;
+  seq?: number; // Delta sync: the sequence number room_state is at
+}
+
+// Delta state sync (handshake state_protocol 2)
+export interface StateSnapshotPayload {
+  seq: number;
+  state: RoomState; // Without player.position, which arrives in position ticks
+}
+
+// One JSON-patch (RFC 6902) operation in a state delta
+export interface PatchOp {
+  op: 'add' | 'remove' | 'replace';
+  path: string;   // JSON pointer (RFC 6901)
+  value?: unknown;
+}
+
+export interface StateDeltaPayload {
+  seq: number;
+  ops: PatchOp[];
+}
+
+export interface PositionPayload {
+  position: number;
+  duration: number;
+  is_playing: boolean;
 }
 
 export interface SearchResult {
@@ -257,7 +288,6 @@ export interface LibrarySong {
   library_id: number;
   times_sung: number;
   last_sung_at?: string;
-  last_sung_by?: string;
   added_at: string;
 }
 